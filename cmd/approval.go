@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+)
+
+// approveMounts asks the user to approve mounts a project hasn't used before
+func approveMounts(project string, mounts []string) (bool, error) {
+	if runApprove {
+		return true, nil
+	}
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return false, fmt.Errorf("%s needs approval for %d new mount(s); run interactively once or pass --approve-mounts", project, len(mounts))
+	}
+
+	fmt.Fprintf(os.Stderr, "packnplay will give the sandbox for %s access to:\n", project)
+	for _, mount := range mounts {
+		fmt.Fprintf(os.Stderr, "  %s\n", mount)
+	}
+
+	var approved bool
+	err := huh.NewConfirm().
+		Title("Allow these mounts for this project?").
+		Description("Your answer is remembered; you'll only be asked again if the mounts change").
+		Affirmative("Allow").
+		Negative("Cancel").
+		Value(&approved).
+		Run()
+	if err != nil {
+		return false, fmt.Errorf("mount approval failed: %w", err)
+	}
+	return approved, nil
+}
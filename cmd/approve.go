@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+	"github.com/obra/packnplay/pkg/fsguard"
+	"github.com/spf13/cobra"
+)
+
+var (
+	approveAllow []string
+	approveDeny  []string
+	approveWatch bool
+)
+
+var approveCmd = &cobra.Command{
+	Use:   "approve <container>",
+	Short: "Answer a session's writes that are waiting for permission",
+	Long: `Allow or deny writes a prompt-mode session (workspace_mode: prompt) is waiting on.
+
+Writes the project's write policy allows go ahead, and ones it denies fail, without
+asking; any other write pauses the agent until it's answered here. Answers hold for
+the rest of the session, for one path or for a whole directory. Unanswered writes
+fail after 10 minutes.
+
+With --allow or --deny, answers are given up front without prompting, e.g.
+  packnplay approve my-session --allow docs/ --deny Makefile
+A trailing / covers a directory and everything in it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName := args[0]
+		if !fsguard.Active(containerName) {
+			return fmt.Errorf("%s doesn't prompt for writes (start it with workspace_mode: prompt)", containerName)
+		}
+
+		if len(approveAllow) > 0 || len(approveDeny) > 0 {
+			for _, pattern := range approveAllow {
+				if err := fsguard.Decide(containerName, pattern, fsguard.Allow); err != nil {
+					return err
+				}
+			}
+			for _, pattern := range approveDeny {
+				if err := fsguard.Decide(containerName, pattern, fsguard.Deny); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return fmt.Errorf("answering writes needs a terminal; pass --allow or --deny instead")
+		}
+		for {
+			pending, err := fsguard.Pending(containerName)
+			if err != nil {
+				return err
+			}
+			// One at a time: a directory answer can settle the writes behind it
+			if len(pending) > 0 {
+				if err := answerWrite(containerName, pending[0]); err != nil {
+					return err
+				}
+				continue
+			}
+			if !approveWatch {
+				fmt.Printf("No writes are waiting in %s\n", containerName)
+				return nil
+			}
+			time.Sleep(time.Second)
+		}
+	},
+}
+
+// writeAnswer is one choice in a write prompt
+type writeAnswer struct {
+	decision fsguard.Decision
+	pattern  string
+}
+
+// answerWrite asks the user about one waiting write and passes the answer to the session
+func answerWrite(containerName string, req fsguard.Request) error {
+	dir := filepath.Dir(req.Path) + "/"
+	options := []huh.Option[writeAnswer]{huh.NewOption("Allow", writeAnswer{fsguard.Allow, req.Path})}
+	if dir != "./" {
+		options = append(options, huh.NewOption("Allow everything in "+dir, writeAnswer{fsguard.Allow, dir}))
+	}
+	options = append(options, huh.NewOption("Deny", writeAnswer{fsguard.Deny, req.Path}))
+	if dir != "./" {
+		options = append(options, huh.NewOption("Deny everything in "+dir, writeAnswer{fsguard.Deny, dir}))
+	}
+
+	var answer writeAnswer
+	err := huh.NewSelect[writeAnswer]().
+		Title(fmt.Sprintf("%s wants to %s %s", containerName, req.Op, req.Path)).
+		Description(fmt.Sprintf("process %d, waiting since %s; the answer holds for the rest of the session", req.PID, req.Since.Local().Format("15:04:05"))).
+		Options(options...).
+		Value(&answer).
+		Run()
+	if err != nil {
+		return fmt.Errorf("write prompt failed: %w", err)
+	}
+	return fsguard.Decide(containerName, answer.pattern, answer.decision)
+}
+
+func init() {
+	rootCmd.AddCommand(approveCmd)
+
+	approveCmd.Flags().StringArrayVar(&approveAllow, "allow", nil, "Allow writes to a path, or a directory with a trailing / (repeatable)")
+	approveCmd.Flags().StringArrayVar(&approveDeny, "deny", nil, "Deny writes to a path, or a directory with a trailing / (repeatable)")
+	approveCmd.Flags().BoolVar(&approveWatch, "watch", false, "Keep waiting for writes to answer instead of exiting")
+}
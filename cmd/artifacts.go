@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/artifacts"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/spf13/cobra"
+)
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts <container>",
+	Short: "Upload a session's artifacts to object storage now",
+	Long: `Upload the paths listed under "artifacts" in the config to S3, GCS or Azure Blob
+Storage, under <destination>/<container>/<timestamp>/. 'packnplay stop' does this
+automatically before removing a container; use this to upload from a session
+that keeps running.
+
+Uploads use the aws, gcloud or az CLI on the host with its usual credentials.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadWithoutRuntimeCheck()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if !cfg.Artifacts.Enabled() {
+			return fmt.Errorf("no artifacts destination configured (set artifacts.destination in %s)", config.GetConfigPath())
+		}
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+		return uploadArtifacts(dockerClient, args[0], cfg.Artifacts)
+	},
+}
+
+// uploadArtifacts stages a session's declared outputs (and its diff) and uploads them
+func uploadArtifacts(dockerClient *docker.Client, containerName string, settings config.ArtifactsConfig) error {
+	dest, err := artifacts.ParseDestination(settings.Destination)
+	if err != nil {
+		return err
+	}
+
+	format := fmt.Sprintf(`{{range .Mounts}}{{if eq .Destination "/workspace"}}{{.Source}}{{end}}{{end}}|{{index .Config.Labels %q}}`, container.StartCommitLabel)
+	output, err := dockerClient.Run("inspect", "--format", format, containerName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w\n%s", containerName, err, output)
+	}
+	workspace, startCommit, _ := strings.Cut(strings.TrimSpace(output), "|")
+
+	stageDir, err := os.MkdirTemp("", "packnplay-artifacts-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stageDir)
+
+	collected, err := artifacts.Collect(stageDir, workspace, settings.Paths, func(containerPath, hostPath string) error {
+		_, err := dockerClient.Run("cp", containerName+":"+containerPath, hostPath)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if settings.Diff && workspace != "" {
+		if err := artifacts.WriteDiff(stageDir, workspace, startCommit); err != nil {
+			return err
+		}
+		collected = append(collected, artifacts.DiffFile)
+	}
+	if len(collected) == 0 {
+		fmt.Printf("No artifacts found for %s\n", containerName)
+		return nil
+	}
+
+	prefix := dest.SessionPrefix(containerName, time.Now())
+	fmt.Printf("Uploading %d artifact(s) to %s...\n", len(collected), dest.String(prefix))
+	return artifacts.Upload(dest, stageDir, prefix)
+}
+
+func init() {
+	rootCmd.AddCommand(artifactsCmd)
+}
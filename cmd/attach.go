@@ -3,13 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-	"syscall"
 
 	"github.com/obra/packnplay/pkg/container"
 	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/runner"
+	"github.com/obra/packnplay/pkg/sessionenv"
 	"github.com/spf13/cobra"
 )
 
@@ -53,32 +53,29 @@ var attachCmd = &cobra.Command{
 			return fmt.Errorf("failed to initialize docker: %w", err)
 		}
 
-		// Check if container is running
-		output, err := dockerClient.Run("ps", "--filter", fmt.Sprintf("name=%s", containerName), "--format", "{{.Names}}")
-		if err != nil {
-			return fmt.Errorf("failed to check container status: %w", err)
-		}
+		return attachContainer(dockerClient, containerName, worktreeName)
+	},
+}
 
-		if strings.TrimSpace(output) != containerName {
-			return fmt.Errorf("no running container found for worktree '%s'", worktreeName)
-		}
+// attachContainer replaces packnplay with an interactive shell in the worktree's running container
+func attachContainer(dockerClient *docker.Client, containerName, worktreeName string) error {
+	// Check if container is running
+	output, err := dockerClient.Run("ps", "--filter", fmt.Sprintf("name=%s", containerName), "--format", "{{.Names}}")
+	if err != nil {
+		return fmt.Errorf("failed to check container status: %w", err)
+	}
 
-		// Execute docker exec with interactive shell
-		cmdPath, err := exec.LookPath(dockerClient.Command())
-		if err != nil {
-			return fmt.Errorf("failed to find docker command: %w", err)
-		}
+	if strings.TrimSpace(output) != containerName {
+		return fmt.Errorf("no running container found for worktree '%s'", worktreeName)
+	}
 
-		argv := []string{
-			filepath.Base(cmdPath),
-			"exec",
-			"-it",
-			containerName,
-			"/bin/bash",
-		}
+	// Execute docker exec with interactive shell
+	execArgs := []string{"exec", "-it"}
+	execArgs = append(execArgs, runner.TerminalEnvArgs()...)
+	execArgs = append(execArgs, containerName)
+	execArgs = append(execArgs, sessionenv.Shell()...)
 
-		return syscall.Exec(cmdPath, argv, os.Environ())
-	},
+	return dockerClient.Handoff(execArgs...)
 }
 
 func init() {
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachContainer(t *testing.T) {
+	for _, key := range []string{"COLORTERM", "TERM_PROGRAM", "TERM_PROGRAM_VERSION", "LANG", "LANGUAGE", "LC_ALL", "LC_CTYPE"} {
+		t.Setenv(key, "")
+	}
+	client, replayer := replay(t, "attach.json")
+
+	if err := attachContainer(client, "packnplay-app-main", "main"); err != nil {
+		t.Fatalf("attachContainer() error = %v", err)
+	}
+	// A worktree without a running container isn't handed to the runtime
+	if err := attachContainer(client, "packnplay-app-other", "other"); err == nil || !strings.Contains(err.Error(), "no running container") {
+		t.Errorf("attachContainer() of a stopped worktree error = %v", err)
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Error(err)
+	}
+}
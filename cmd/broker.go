@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/broker"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	brokerSocket    string
+	brokerContainer string
+	brokerRuntime   string
+	brokerAllow     []string
+	brokerGitHosts  []string
+	brokerRepo      string
+	brokerWorkspace string
+)
+
+var brokerCmd = &cobra.Command{
+	Use:    "host-broker",
+	Short:  "Serve whitelisted host actions to a container",
+	Long:   `Background daemon that performs a fixed set of host actions (open URL, copy, notify) on behalf of one container.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-broker")()
+
+		server, err := broker.NewServer(brokerAllow, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := server.SetGitHosts(brokerGitHosts); err != nil {
+			return err
+		}
+		server.SetRepo(brokerRepo)
+		server.SetWorkspace(brokerWorkspace)
+
+		listener, err := broker.Listen(brokerSocket)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+
+		log.Printf("Host broker for %s listening on %s (allowed: %s)", brokerContainer, brokerSocket, strings.Join(brokerAllow, ", "))
+
+		// Follow config edits, so actions can be allowed or revoked without restarting the session
+		stopWatch, err := config.Watch(func(cfg *config.Config, err error) {
+			if err != nil {
+				log.Printf("Ignoring config change: %v", err)
+				return
+			}
+			allow := cfg.HostBroker.Allow
+			if !cfg.HostBroker.Enabled {
+				allow = nil
+			}
+			if changed, err := server.SetAllowed(allow); err != nil {
+				log.Printf("Ignoring host_broker change: %v", err)
+			} else if changed {
+				log.Printf("Reloaded host broker actions for %s (allowed: %s)", brokerContainer, strings.Join(server.Allowed(), ", "))
+			}
+			if changed, err := server.SetGitHosts(cfg.HostBroker.GitHosts); err != nil {
+				log.Printf("Ignoring host_broker.git_hosts change: %v", err)
+			} else if changed {
+				log.Printf("Reloaded trusted git hosts for %s: %s", brokerContainer, strings.Join(cfg.HostBroker.GitHosts, ", "))
+			}
+		})
+		if err != nil {
+			log.Printf("Config changes won't apply until the session restarts: %v", err)
+		} else {
+			defer stopWatch()
+		}
+
+		// Exit once the container is gone so brokers don't accumulate
+		go func() {
+			for {
+				time.Sleep(30 * time.Second)
+				if !isContainerRunning(brokerRuntime, brokerContainer) {
+					log.Printf("Container %s stopped, exiting host broker", brokerContainer)
+					listener.Close()
+					return
+				}
+			}
+		}()
+
+		if err := http.Serve(listener, server); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+			return fmt.Errorf("host broker failed: %w", err)
+		}
+		return nil
+	},
+}
+
+// isContainerRunning checks whether a named container is running
+func isContainerRunning(runtime, name string) bool {
+	output, err := docker.DefaultExecutor(runtime).Exec(runtime, []string{"ps", "--filter", fmt.Sprintf("name=^%s$", name), "--format", "{{.Names}}"})
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(output) == name
+}
+
+func init() {
+	rootCmd.AddCommand(brokerCmd)
+
+	brokerCmd.Flags().StringVar(&brokerSocket, "socket", "", "Unix socket path to listen on")
+	brokerCmd.Flags().StringVar(&brokerContainer, "container", "", "Container this broker serves")
+	brokerCmd.Flags().StringVar(&brokerRuntime, "runtime", "docker", "Container runtime used to check container liveness")
+	brokerCmd.Flags().StringSliceVar(&brokerAllow, "allow", []string{}, "Allowed actions (open, copy, notify, git-push, sparse-add)")
+	brokerCmd.Flags().StringSliceVar(&brokerGitHosts, "git-hosts", []string{}, "Hosts (and path prefixes) git-push may push to")
+	brokerCmd.Flags().StringVar(&brokerRepo, "repo", "", "Host checkout git-push pushes from")
+	brokerCmd.Flags().StringVar(&brokerWorkspace, "workspace", "", "Sparse checkout sparse-add materializes paths in")
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/obra/packnplay/pkg/bundle"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/devcontainer"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/imagebuild"
+	"github.com/obra/packnplay/pkg/netpolicy"
+	"github.com/obra/packnplay/pkg/projecttype"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleOutput   string
+	bundleImages   []string
+	bundleProjects []string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle [-o file.tar.zst]",
+	Short: "Package images and policies for a machine without network access",
+	Long: `Bundle everything sessions need from the network into one archive, for
+air-gapped machines: the default image, each project's devcontainer image and the
+layers packnplay built for it (agent CLIs, packages, shell, setup), your Rego
+policies and updated network blocklists.
+
+  packnplay bundle --project ~/src/api --project ~/src/web -o packnplay-bundle.tar.zst
+
+Run a session in each project first, so its layers are built. Without --project
+the current project is bundled. Copy the archive over and run
+'packnplay bundle install' on the target machine.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadWithoutRuntimeCheck()
+		if err != nil {
+			return err
+		}
+		dockerClient, err := docker.NewClientWithRuntime(cfg.ContainerRuntime, false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize container runtime: %w", err)
+		}
+
+		projects := bundleProjects
+		if len(projects) == 0 {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+			projects = []string{cwd}
+		}
+
+		images := []string{cfg.DefaultImage}
+		for _, project := range projects {
+			root, err := filepath.Abs(project)
+			if err != nil {
+				return fmt.Errorf("failed to resolve path: %w", err)
+			}
+			if gitRoot, err := git.FindRoot(root); err == nil {
+				root = gitRoot
+			}
+			if devConfig, err := devcontainer.LoadConfig(root); err == nil && devConfig != nil && devConfig.Image != "" {
+				images = append(images, devConfig.Image)
+			} else if image, projectType := projecttype.Image(root, cfg.LanguageImages, ""); projectType != "" {
+				images = append(images, image)
+			}
+			layers := imagebuild.ProjectImages(dockerClient, filepath.Base(root))
+			if len(layers) == 0 {
+				fmt.Fprintf(os.Stderr, "Warning: no layers built for %s yet; run a session there first to bundle its agent CLIs\n", root)
+			}
+			images = append(images, layers...)
+		}
+		images = append(images, bundleImages...)
+
+		contents := bundle.Contents{
+			Images:        dedupe(images),
+			DefaultImage:  cfg.DefaultImage,
+			Policies:      cfg.Policies,
+			BlocklistsDir: netpolicy.GetBlocklistsDir(),
+		}
+		fmt.Fprintf(os.Stderr, "Saving %d images...\n", len(contents.Images))
+		manifest, err := bundle.Create(dockerClient, contents, bundleOutput)
+		if err != nil {
+			os.Remove(bundleOutput)
+			return err
+		}
+
+		fmt.Printf("Bundled %d images, %d policies and %d blocklists into %s\n",
+			len(manifest.Images), len(manifest.Policies), len(manifest.Blocklists), bundleOutput)
+		return nil
+	},
+}
+
+var bundleInstallCmd = &cobra.Command{
+	Use:   "install <file.tar.zst>",
+	Short: "Install a bundle made by 'packnplay bundle'",
+	Long: `Load a bundle's images into the container runtime and install its policies and
+network blocklists. Installed policies are added to the config's "policies", and the
+bundle's default image becomes "default_image" if none is set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runtime := "docker"
+		cfg, err := config.LoadWithoutRuntimeCheck()
+		if err == nil && cfg.ContainerRuntime != "" {
+			runtime = cfg.ContainerRuntime
+		}
+		dockerClient, err := docker.NewClientWithRuntime(runtime, false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize container runtime: %w", err)
+		}
+
+		xdgDataHome := os.Getenv("XDG_DATA_HOME")
+		if xdgDataHome == "" {
+			homeDir, _ := os.UserHomeDir()
+			xdgDataHome = filepath.Join(homeDir, ".local", "share")
+		}
+		policyRoot := filepath.Join(xdgDataHome, "packnplay", "policies")
+
+		manifest, policies, err := bundle.Install(dockerClient, args[0], policyRoot, netpolicy.GetBlocklistsDir())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Loaded %d images and %d blocklists\n", len(manifest.Images), len(manifest.Blocklists))
+
+		if len(policies) == 0 && manifest.DefaultImage == "" {
+			return nil
+		}
+		if cfg == nil {
+			// The first run's setup writes a fresh config, so leave settings to the user
+			for _, path := range policies {
+				fmt.Printf("Installed policy %s; add it to \"policies\" in %s\n", path, config.GetConfigPath())
+			}
+			return nil
+		}
+		return applyBundleSettings(cfg, manifest, policies)
+	},
+}
+
+// applyBundleSettings points the user config at a bundle's policies and default image
+func applyBundleSettings(cfg *config.Config, manifest *bundle.Manifest, policies []string) error {
+	configPath := config.GetConfigPath()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	merged := dedupe(append(append([]string(nil), cfg.Policies...), policies...))
+	if len(merged) != len(cfg.Policies) {
+		values := make([]any, len(merged))
+		for i, path := range merged {
+			values[i] = path
+		}
+		if data, err = config.SetJSON(data, []string{"policies"}, values); err != nil {
+			return fmt.Errorf("failed to update config: %w", err)
+		}
+		fmt.Printf("Added %d policies to %s\n", len(merged)-len(cfg.Policies), configPath)
+	}
+	// LoadWithoutRuntimeCheck fills in the default image, so check the file itself
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err == nil && manifest.DefaultImage != "" {
+		if value, ok := config.ValueAt(doc, []string{"default_image"}); !ok || value == nil || value == "" {
+			if data, err = config.SetJSON(data, []string{"default_image"}, manifest.DefaultImage); err != nil {
+				return fmt.Errorf("failed to update config: %w", err)
+			}
+			fmt.Printf("Set default_image to %s\n", manifest.DefaultImage)
+		}
+	}
+
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// dedupe drops repeated and empty entries, keeping the first of each
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleInstallCmd)
+
+	bundleCmd.Flags().StringVarP(&bundleOutput, "output", "o", "packnplay-bundle.tar.zst", "Bundle path (.tar.zst or .tar.gz)")
+	bundleCmd.Flags().StringSliceVar(&bundleImages, "image", []string{}, "Additional image to include (repeatable)")
+	bundleCmd.Flags().StringSliceVar(&bundleProjects, "project", []string{}, "Project whose images to include (repeatable, default: the current project)")
+}
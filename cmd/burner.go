@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/obra/packnplay/pkg/burner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	burnerContainer string
+	burnerRuntime   string
+)
+
+var burnerCmd = &cobra.Command{
+	Use:    "burner-revoker",
+	Short:  "Revoke a container's burner keys once it stops",
+	Long:   `Background daemon that waits for a container to stop, then revokes the per-session API keys minted for it.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-burner")()
+
+		log.Printf("Waiting for %s to stop before revoking its keys", burnerContainer)
+		for isContainerRunning(burnerRuntime, burnerContainer) {
+			time.Sleep(30 * time.Second)
+		}
+
+		if err := burner.RevokeSession(burnerContainer); err != nil {
+			log.Printf("Failed to revoke keys for %s: %v", burnerContainer, err)
+			return err
+		}
+		log.Printf("Container %s stopped, revoked its keys", burnerContainer)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(burnerCmd)
+
+	burnerCmd.Flags().StringVar(&burnerContainer, "container", "", "Container whose keys to revoke")
+	burnerCmd.Flags().StringVar(&burnerRuntime, "runtime", "docker", "Container runtime used to check container liveness")
+}
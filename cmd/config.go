@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/hostopen"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configProject bool
+	configUser    bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect, change and check packnplay's config files",
+	Long: `Show and set values in the user config and the project's .packnplay.yaml,
+validate both files, or print their schemas.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show the effective configuration or one value in it",
+	Long: `Show the configuration packnplay runs with: the user config with its defaults
+filled in, and the current project's .packnplay.yaml. Give a dotted key to show
+one value:
+
+  packnplay config get
+  packnplay config get network_policy.blocklists
+  packnplay config get packages.apt
+
+Keys are looked up in whichever file has them; --user or --project picks one.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userDoc, projectDoc, err := effectiveConfig()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			if !configProject {
+				data, err := json.MarshalIndent(userDoc, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to encode config: %w", err)
+				}
+				fmt.Printf("# %s\n%s\n", config.GetConfigPath(), data)
+			}
+			if !configUser {
+				fmt.Printf("# %s\n", projectConfigPath())
+				encoder := yaml.NewEncoder(os.Stdout)
+				encoder.SetIndent(2)
+				if err := encoder.Encode(projectDoc); err != nil {
+					return fmt.Errorf("failed to encode config: %w", err)
+				}
+			}
+			return nil
+		}
+
+		project, path, _, err := resolveConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		doc := userDoc
+		if project {
+			doc = projectDoc
+		}
+		value, ok := config.ValueAt(doc, path)
+		if !ok || value == nil {
+			return fmt.Errorf("%s is not set", args[0])
+		}
+		if _, scalar := value.(string); scalar {
+			fmt.Println(value)
+			return nil
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", args[0], err)
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a value in the user or project config",
+	Long: `Set a value by its dotted key, writing it to the file that holds the key:
+~/.config/packnplay/config.json, or .packnplay.yaml at the root of the current
+project. The value is checked against the key's type first.
+
+  packnplay config set network_policy.blocklists exfiltration,typosquat
+  packnplay config set record_sessions true
+  packnplay config set packages.apt jq,protobuf-compiler --project
+  packnplay config set caches.gomod '{"target": "/home/vscode/go/pkg/mod"}'
+
+Lists can be given comma-separated or as JSON; objects as JSON. Other settings
+and comments in .packnplay.yaml are kept as they are.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		project, path, schema, err := resolveConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		value, err := config.ParseValue(schema, args[1])
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", args[0], err)
+		}
+
+		file := config.GetConfigPath()
+		if project {
+			if file = projectConfigPath(); file == "" {
+				return fmt.Errorf("failed to find the current project")
+			}
+		}
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if project {
+			data, err = config.SetYAML(data, path, value)
+		} else {
+			data, err = config.SetJSON(data, path, value)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set %s in %s: %w", args[0], file, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		fmt.Printf("Set %s in %s\n", args[0], file)
+		return nil
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the user or project config in your editor",
+	Long: `Open ~/.config/packnplay/config.json, or the project's .packnplay.yaml with
+--project, in $VISUAL or $EDITOR (vi without either), then validate it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, empty := config.GetConfigPath(), "{}\n"
+		if configProject {
+			if file, empty = projectConfigPath(), ""; file == "" {
+				return fmt.Errorf("failed to find the current project")
+			}
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return fmt.Errorf("failed to create config directory: %w", err)
+			}
+			if err := os.WriteFile(file, []byte(empty), 0644); err != nil {
+				return fmt.Errorf("failed to create %s: %w", file, err)
+			}
+		}
+
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+		}
+		editorArgs := hostopen.EditorCommand(editor, file, 0, runtime.GOOS)
+		editorCmd := exec.Command(editorArgs[0], editorArgs[1:]...)
+		editorCmd.Stdin = os.Stdin
+		editorCmd.Stdout = os.Stdout
+		editorCmd.Stderr = os.Stderr
+		if err := editorCmd.Run(); err != nil {
+			return fmt.Errorf("failed to run %s: %w", strings.Join(editorArgs, " "), err)
+		}
+
+		issues, err := validateConfigFile(file)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			fmt.Printf("%s:%s\n", file, issue)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("found %d problems", len(issues))
+		}
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check config files for typos and wrong types",
+	Long: `Check config files against their schema, reporting each unknown field or
+mistyped value with its line and column:
+
+  packnplay config validate
+  packnplay config validate ~/src/api/.packnplay.yaml
+
+With no files, checks ~/.config/packnplay/config.json and the current project's
+.packnplay.yaml, if they exist. Files ending in .yaml or .yml are project
+configs; anything else is read as the user config.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			files = defaultConfigFiles()
+			if len(files) == 0 {
+				fmt.Println("No config files found")
+				return nil
+			}
+		}
+
+		problems := 0
+		for _, file := range files {
+			issues, err := validateConfigFile(file)
+			if err != nil {
+				return err
+			}
+			if len(issues) == 0 {
+				fmt.Printf("✓ %s\n", file)
+				continue
+			}
+			for _, issue := range issues {
+				fmt.Printf("%s:%s\n", file, issue)
+			}
+			problems += len(issues)
+		}
+		if problems > 0 {
+			return fmt.Errorf("found %d problems", problems)
+		}
+		return nil
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema [user|project]",
+	Short: "Print a config file's JSON Schema",
+	Long: `Print the JSON Schema of the user config (the default) or .packnplay.yaml, for
+editors that complete and check config files as you type.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema := config.UserSchema()
+		if len(args) == 1 {
+			switch args[0] {
+			case "user":
+			case "project":
+				schema = config.ProjectSchema()
+			default:
+				return fmt.Errorf("unknown schema '%s' (use user or project)", args[0])
+			}
+		}
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode schema: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+// defaultConfigFiles returns the user config and the current project's config, if they exist
+func defaultConfigFiles() []string {
+	var files []string
+	if _, err := os.Stat(config.GetConfigPath()); err == nil {
+		files = append(files, config.GetConfigPath())
+	}
+	if projectFile := projectConfigPath(); projectFile != "" {
+		if _, err := os.Stat(projectFile); err == nil {
+			files = append(files, projectFile)
+		}
+	}
+	return files
+}
+
+// projectConfigPath returns the .packnplay.yaml at the root of the current project
+func projectConfigPath() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	root := cwd
+	if gitRoot, err := git.FindRoot(cwd); err == nil {
+		root = gitRoot
+	}
+	return filepath.Join(root, config.ProjectConfigFile)
+}
+
+// resolveConfigKey finds the file a dotted key belongs in, and its path and type there
+// The two files share no top-level keys, so the key decides unless --user or --project does.
+func resolveConfigKey(key string) (bool, []string, *config.Schema, error) {
+	if !configProject {
+		path, schema, err := config.Resolve(config.UserSchema(), key)
+		if err == nil || configUser {
+			return false, path, schema, err
+		}
+		top, _, _ := strings.Cut(key, ".")
+		if _, ok := config.ProjectSchema().Properties[top]; !ok {
+			return false, nil, nil, err
+		}
+	}
+	path, schema, err := config.Resolve(config.ProjectSchema(), key)
+	if err != nil {
+		return true, nil, nil, fmt.Errorf("%w in %s", err, config.ProjectConfigFile)
+	}
+	return true, path, schema, nil
+}
+
+// effectiveConfig loads both config files as plain values, with the user config's defaults
+func effectiveConfig() (map[string]any, map[string]any, error) {
+	cfg, err := config.LoadWithoutRuntimeCheck()
+	if err != nil {
+		if _, statErr := os.Stat(config.GetConfigPath()); !os.IsNotExist(statErr) {
+			return nil, nil, err
+		}
+		cfg = &config.Config{}
+	}
+	userDoc, err := config.Generic(cfg, "json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	projectCfg := &config.ProjectConfig{}
+	if projectFile := projectConfigPath(); projectFile != "" {
+		if projectCfg, err = config.LoadProjectConfig(filepath.Dir(projectFile)); err != nil {
+			return nil, nil, err
+		}
+	}
+	projectDoc, err := config.Generic(projectCfg, "yaml")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode %s: %w", config.ProjectConfigFile, err)
+	}
+	return userDoc, projectDoc, nil
+}
+
+// validateConfigFile checks a file against the schema its name implies
+func validateConfigFile(file string) ([]config.Issue, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yaml" || ext == ".yml" {
+		issues, err := config.Validate(data, config.ProjectSchema(), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		return issues, nil
+	}
+	issues, err := config.Validate(data, config.UserSchema(), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return issues, nil
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+
+	for _, c := range []*cobra.Command{configGetCmd, configSetCmd} {
+		c.Flags().BoolVar(&configProject, "project", false, "Use the project's .packnplay.yaml")
+		c.Flags().BoolVar(&configUser, "user", false, "Use the user config")
+	}
+	configEditCmd.Flags().BoolVar(&configProject, "project", false, "Edit the project's .packnplay.yaml")
+}
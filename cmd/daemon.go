@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+
+	"github.com/obra/packnplay/pkg/systemd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonSystem  bool
+	daemonUser    string
+	daemonListen  string
+	daemonRuntime string
+	daemonPrint   bool
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run 'packnplay serve' as a systemd service",
+	Long: `Install 'packnplay serve' as a socket-activated systemd service, for shared dev
+servers where metrics and session events should always be available:
+
+  packnplay daemon install                      # user unit, for your sessions
+  sudo packnplay daemon install --system --user packnplay
+
+systemd listens on --listen and starts the service on the first connection. Logs
+go to the journal (journalctl -t packnplay-serve). System units run as --user
+(defaulting to the account that ran sudo) with the rest of the host read-only;
+that account needs access to the container runtime, e.g. the docker group.`,
+	Args: cobra.NoArgs,
+}
+
+var daemonInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Write and enable the systemd units",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find packnplay binary: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+
+		opts := systemd.Options{
+			Executable: executable,
+			Listen:     daemonListen,
+			Runtime:    daemonRuntime,
+			System:     daemonSystem,
+		}
+		if daemonSystem {
+			name := daemonUser
+			if name == "" {
+				name = os.Getenv("SUDO_USER")
+			}
+			account, err := user.Lookup(name)
+			if err != nil {
+				return fmt.Errorf("failed to look up user '%s' (set --user): %w", name, err)
+			}
+			opts.User, opts.HomeDir = account.Username, account.HomeDir
+		}
+		if err := systemd.Validate(opts); err != nil {
+			return err
+		}
+
+		units := map[string]string{
+			systemd.SocketUnit:  systemd.Socket(opts),
+			systemd.ServiceUnit: systemd.Service(opts),
+		}
+		if daemonPrint {
+			for _, name := range []string{systemd.SocketUnit, systemd.ServiceUnit} {
+				fmt.Printf("# %s\n%s\n", name, units[name])
+			}
+			return nil
+		}
+
+		dir := systemd.UnitDir(daemonSystem)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		for name, content := range units {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			fmt.Printf("Wrote %s\n", path)
+		}
+
+		if err := systemctl(daemonSystem, "daemon-reload"); err != nil {
+			return err
+		}
+		if err := systemctl(daemonSystem, "enable", "--now", systemd.SocketUnit); err != nil {
+			return err
+		}
+		fmt.Printf("Listening on %s; packnplay serve starts on the first connection\n", daemonListen)
+		return nil
+	},
+}
+
+var daemonUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the systemd units",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Already stopped or never enabled is fine; the units are removed either way
+		_ = systemctl(daemonSystem, "disable", "--now", systemd.SocketUnit, systemd.ServiceUnit)
+
+		dir := systemd.UnitDir(daemonSystem)
+		for _, name := range []string{systemd.SocketUnit, systemd.ServiceUnit} {
+			path := filepath.Join(dir, name)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+		if err := systemctl(daemonSystem, "daemon-reload"); err != nil {
+			return err
+		}
+		fmt.Println("Removed packnplay daemon")
+		return nil
+	},
+}
+
+// systemctl runs systemctl against the system or the user's service manager
+func systemctl(system bool, args ...string) error {
+	if !system {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %v failed: %w\n%s", args, err, output)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonInstallCmd)
+	daemonCmd.AddCommand(daemonUninstallCmd)
+
+	daemonCmd.PersistentFlags().BoolVar(&daemonSystem, "system", false, "Use a system unit in /etc/systemd/system instead of a user unit")
+	daemonInstallCmd.Flags().StringVar(&daemonUser, "user", "", "Account a system unit runs as (default: $SUDO_USER)")
+	daemonInstallCmd.Flags().StringVar(&daemonListen, "listen", "127.0.0.1:9464", "Address or Unix socket path systemd listens on")
+	daemonInstallCmd.Flags().StringVar(&daemonRuntime, "runtime", "", "Container runtime for serve to query (docker/podman)")
+	daemonInstallCmd.Flags().BoolVar(&daemonPrint, "print", false, "Print the units instead of installing them")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/obra/packnplay/pkg/accel"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the container runtime and accelerators",
+	Long: `Report the container runtime packnplay will use and the GPUs or other accelerators
+on this machine, with whether that runtime can pass each one through to a session.
+
+Use it to find out why a local-model sidecar runs on the CPU before launching one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host := accel.LocalHost()
+
+		runtimeCmd := ""
+		var run func(args ...string) (string, error)
+		if dockerClient, err := docker.NewClient(false); err != nil {
+			fmt.Printf("Runtime:      none found (%v)\n", err)
+		} else {
+			runtimeCmd = dockerClient.Command()
+			run = dockerClient.Run
+			fmt.Printf("Runtime:      %s\n", runtimeCmd)
+		}
+
+		found := host.Detect()
+		if len(found) == 0 {
+			fmt.Println("Accelerators: none found; sessions run models on the CPU")
+			return nil
+		}
+
+		fmt.Println("Accelerators:")
+		for _, a := range found {
+			fmt.Printf("  %s (%s)\n", a.Name, a.Kind)
+			if run == nil {
+				continue
+			}
+			pass := host.CheckPassthrough(a.Kind, runtimeCmd, run)
+			status := "not available"
+			if pass.OK {
+				status = "available"
+			}
+			fmt.Printf("    passthrough with %s: %s - %s\n", runtimeCmd, status, pass.Detail)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
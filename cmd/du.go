@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/diskusage"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	duClean   bool
+	duProject string
+	duKind    string
+)
+
+var duCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show disk space used per project, and what can be cleaned up",
+	Long: `Summarize the space packnplay uses per project: images it built, shared cache
+volumes, split-mode overlays, persisted homes, worktrees and session recordings.
+
+What can safely go is listed with the command that removes it:
+  - image layers superseded by a newer build
+  - overlays of sessions whose container is gone (their snapshots are on the session branch)
+  - homes of sessions whose container is gone, unused for 14 days
+  - recordings older than 30 days
+
+--clean removes those, narrowed with --project and --kind. Worktrees and caches are
+only reported; remove worktrees with git, caches with 'docker volume rm'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if duKind != "" && !validDiskKind(duKind) {
+			return fmt.Errorf("unknown kind '%s' (use %s)", duKind, strings.Join(diskKindNames(), ", "))
+		}
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+
+		items := diskusage.Scan(dockerClient)
+		if duClean {
+			return cleanDiskUsage(dockerClient, items)
+		}
+		printDiskUsage(items)
+		return nil
+	},
+}
+
+// printDiskUsage prints the per-project table, then what can be reclaimed and how
+func printDiskUsage(items []diskusage.Item) {
+	var shown []diskusage.Item
+	for _, item := range items {
+		if duProject == "" || item.Project == duProject {
+			shown = append(shown, item)
+		}
+	}
+	if len(shown) == 0 {
+		fmt.Println("packnplay isn't using any disk space")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := []string{"PROJECT"}
+	for _, kind := range diskusage.Kinds {
+		header = append(header, strings.ToUpper(string(kind))+"S")
+	}
+	fmt.Fprintln(w, strings.Join(append(header, "TOTAL", "RECLAIMABLE"), "\t"))
+	for _, usage := range diskusage.Summarize(shown) {
+		row := []string{projectLabel(usage.Project)}
+		for _, kind := range diskusage.Kinds {
+			row = append(row, diskusage.FormatBytes(usage.Bytes[kind]))
+		}
+		row = append(row, diskusage.FormatBytes(usage.Total), diskusage.FormatBytes(usage.Reclaimable))
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	// Recommendations, grouped so each comes with one command
+	type group struct {
+		project string
+		kind    diskusage.Kind
+		count   int
+		bytes   int64
+		reason  string
+	}
+	groups := make(map[string]*group)
+	var total int64
+	for _, item := range shown {
+		if item.Reclaim == "" {
+			continue
+		}
+		key := item.Project + "\x00" + string(item.Kind)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{project: item.Project, kind: item.Kind, reason: item.Reclaim}
+			groups[key] = g
+		}
+		g.count++
+		g.bytes += max(item.Bytes, 0)
+		total += max(item.Bytes, 0)
+	}
+	if len(groups) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return groups[keys[i]].bytes > groups[keys[j]].bytes })
+
+	fmt.Println("\nRecommendations:")
+	for _, key := range keys {
+		g := groups[key]
+		command := "packnplay du --clean --kind " + string(g.kind)
+		if g.project != "" {
+			command += " --project " + g.project
+		}
+		fmt.Printf("  %s: %d %s (%s): %s\n    %s\n", projectLabel(g.project), g.count, pluralKind(g.kind, g.count), diskusage.FormatBytes(g.bytes), g.reason, command)
+	}
+	if len(groups) > 1 {
+		command := "packnplay du --clean"
+		if duProject != "" {
+			command += " --project " + duProject
+		}
+		fmt.Printf("\nReclaim all %s with: %s\n", diskusage.FormatBytes(total), command)
+	}
+}
+
+// cleanDiskUsage removes the reclaimable items matching --project and --kind
+func cleanDiskUsage(dockerClient *docker.Client, items []diskusage.Item) error {
+	image := ""
+	if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil {
+		image = cfg.DefaultImage
+	}
+
+	var reclaimed int64
+	removed, failed := 0, 0
+	for _, item := range items {
+		if item.Reclaim == "" || (duProject != "" && item.Project != duProject) || (duKind != "" && string(item.Kind) != duKind) {
+			continue
+		}
+		if err := diskusage.Remove(dockerClient, item, image); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Printf("Removed %s %s (%s)\n", item.Kind, item.Name, diskusage.FormatBytes(item.Bytes))
+		reclaimed += max(item.Bytes, 0)
+		removed++
+	}
+
+	if removed == 0 && failed == 0 {
+		fmt.Println("Nothing to clean up")
+		return nil
+	}
+	fmt.Printf("Reclaimed %s\n", diskusage.FormatBytes(reclaimed))
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d item(s)", failed)
+	}
+	return nil
+}
+
+// projectLabel names a project in the report; "-" is shared caches and sessions of unknown projects
+func projectLabel(project string) string {
+	if project == "" {
+		return "-"
+	}
+	return project
+}
+
+func pluralKind(kind diskusage.Kind, n int) string {
+	if n == 1 {
+		return string(kind)
+	}
+	return string(kind) + "s"
+}
+
+func diskKindNames() []string {
+	names := make([]string, 0, len(diskusage.Kinds))
+	for _, kind := range diskusage.Kinds {
+		names = append(names, string(kind))
+	}
+	return names
+}
+
+func validDiskKind(kind string) bool {
+	for _, name := range diskKindNames() {
+		if name == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(duCmd)
+
+	duCmd.Flags().BoolVar(&duClean, "clean", false, "Remove what's recommended for cleanup")
+	duCmd.Flags().StringVar(&duProject, "project", "", "Only this project")
+	duCmd.Flags().StringVar(&duKind, "kind", "", "Only this kind: image, overlay, home or transcript")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/sessionenv"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Change the environment of a session's new processes",
+	Long: `Set or remove environment variables for the processes a running session starts
+from now on, e.g. to supply a rotated API key to the agent's commands without
+restarting the session.
+
+That covers the agent's commands, shells from 'packnplay attach', and the agent
+itself when it's relaunched with --reconnect. It doesn't change the environment
+of processes already running, the agent included.
+
+Values are kept in the OS keychain (see 'packnplay secret') and in a tmpfs in
+the container, never in a file on the host.`,
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <container> KEY[=VALUE]...",
+	Short: "Set variables for a session's new processes",
+	Long:  `Set variables for a session's new processes. A bare KEY takes its value from your shell, like --env.`,
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName := args[0]
+		dockerClient, err := checkSessionEnv(containerName)
+		if err != nil {
+			return err
+		}
+
+		values := make(map[string]string)
+		for _, arg := range args[1:] {
+			key, value, err := sessionenv.ParseAssignment(arg, os.Getenv)
+			if err != nil {
+				return err
+			}
+			values[key] = value
+		}
+		if err := sessionenv.Set(dockerClient, containerName, values); err != nil {
+			return err
+		}
+		fmt.Printf("Set %d variable(s) in %s for new processes\n", len(values), containerName)
+		return nil
+	},
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <container> KEY...",
+	Short: "Remove variables from a session's new processes",
+	Long:  `Remove variables from a session's new processes, including ones it was started with.`,
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName := args[0]
+		dockerClient, err := checkSessionEnv(containerName)
+		if err != nil {
+			return err
+		}
+		if err := sessionenv.Unset(dockerClient, containerName, args[1:]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d variable(s) from %s for new processes\n", len(args)-1, containerName)
+		return nil
+	},
+}
+
+// checkSessionEnv makes sure a container is running and was started able to take changes
+func checkSessionEnv(containerName string) (*docker.Client, error) {
+	dockerClient, err := docker.NewClient(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize docker: %w", err)
+	}
+	if !isContainerRunning(dockerClient.Command(), containerName) {
+		return nil, fmt.Errorf("container %s is not running", containerName)
+	}
+	if !sessionenv.Active(containerName) {
+		return nil, fmt.Errorf("%s was started by an older packnplay that can't take 'packnplay env' changes; restart it to use them", containerName)
+	}
+	return dockerClient, nil
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envUnsetCmd)
+}
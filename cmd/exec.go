@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var execPromptStdin bool
+
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] <agent>[@dir] [--prompt-stdin] [agent args...]",
+	Short: "Run an agent headlessly, for scripts",
+	Long: `Run an agent in its non-interactive mode: no terminal is allocated, so its
+output can be captured or piped. Takes the same flags as 'packnplay run'.
+
+With --prompt-stdin the prompt is read from stdin, so large context can be fed in
+without temp files or extra mounts:
+
+  cat bug_report.md | packnplay exec claude --prompt-stdin
+  git diff | packnplay exec codex@~/src/api --prompt-stdin > review.md
+
+Otherwise the prompt is passed as arguments and stdin isn't read:
+
+  packnplay exec claude "summarize the failing tests"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agentName, args, promptStdin, err := headlessCommand(args)
+		if err != nil {
+			return err
+		}
+		promptStdin = promptStdin || execPromptStdin
+
+		if promptStdin && term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("--prompt-stdin reads the prompt from stdin; pipe it in, e.g. cat prompt.md | packnplay exec %s --prompt-stdin", agentName)
+		}
+		return startSession(cmd, args, true, promptStdin)
+	},
+}
+
+// headlessCommand puts the agent's headless arguments after it, returning the agent's
+// name and whether --prompt-stdin was given after the agent (where flag parsing has stopped)
+func headlessCommand(args []string) (string, []string, bool, error) {
+	var rest []string
+	promptStdin := false
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if arg == "--prompt-stdin" {
+			promptStdin = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(rest) == 0 {
+		return "", nil, false, fmt.Errorf("exec needs an agent, e.g. packnplay exec claude --prompt-stdin")
+	}
+
+	name := rest[0]
+	if agent, _, ok := splitAgentTarget(name); ok {
+		name = agent
+	}
+	agent := agents.Lookup(name)
+	if agent == nil {
+		return "", nil, false, fmt.Errorf("unknown agent '%s'", name)
+	}
+	headless, ok := agent.(agents.HeadlessRunner)
+	if !ok {
+		return "", nil, false, fmt.Errorf("%s has no headless mode packnplay knows how to run", agent.Name())
+	}
+
+	// An explicit -- command is run as given
+	if !slices.Contains(rest, "--") {
+		rest = append(append([]string{rest[0]}, headless.HeadlessArgs()...), rest[1:]...)
+	}
+	return agent.Name(), rest, promptStdin, nil
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolVar(&execPromptStdin, "prompt-stdin", false, "Read the prompt from stdin")
+}
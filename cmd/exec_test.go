@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadlessCommand(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		wantArgs        []string
+		wantPromptStdin bool
+		wantErr         bool
+	}{
+		{
+			name:            "prompt from stdin",
+			args:            []string{"claude", "--prompt-stdin"},
+			wantArgs:        []string{"claude", "-p"},
+			wantPromptStdin: true,
+		},
+		{
+			name:     "prompt as argument",
+			args:     []string{"codex", "--model", "o3", "fix the build"},
+			wantArgs: []string{"codex", "exec", "--model", "o3", "fix the build"},
+		},
+		{
+			name:            "agent in another directory",
+			args:            []string{"claude@../api", "--prompt-stdin"},
+			wantArgs:        []string{"claude@../api", "-p"},
+			wantPromptStdin: true,
+		},
+		{
+			name:     "explicit command runs as given",
+			args:     []string{"claude", "--", "claude", "--print", "--prompt-stdin"},
+			wantArgs: []string{"claude", "--", "claude", "--print", "--prompt-stdin"},
+		},
+		{name: "no agent", args: []string{"--prompt-stdin"}, wantErr: true},
+		{name: "unknown agent", args: []string{"bash"}, wantErr: true},
+		{name: "agent without headless mode", args: []string{"copilot"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args, promptStdin, err := headlessCommand(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("headlessCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) || promptStdin != tt.wantPromptStdin {
+				t.Errorf("headlessCommand() = %v, %v; want %v, %v", args, promptStdin, tt.wantArgs, tt.wantPromptStdin)
+			}
+		})
+	}
+}
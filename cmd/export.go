@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutput     string
+	exportEncrypt    bool
+	exportRecipients []string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <container> -o <file.tar.zst>",
+	Short: "Export a session to move it to another machine",
+	Long: `Bundle a session's workspace into a single archive: the worktree branch with its
+history, uncommitted and untracked changes, the agent config files the session changed
+(such as the conversation history, but not credentials), its recordings, and session
+metadata.
+
+Use the container name shown by 'packnplay list'. Restore with 'packnplay import'.
+
+With --encrypt (or "encrypt_storage": true in config) the archive is encrypted with
+your storage key. Add --recipient with another machine's public key to import it there.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName := args[0]
+
+		encrypt := exportEncrypt || len(exportRecipients) > 0 || strings.HasSuffix(exportOutput, encryption.Extension)
+		if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil && cfg.EncryptStorage {
+			encrypt = true
+		}
+
+		if exportOutput == "" {
+			exportOutput = containerName + ".tar.zst"
+		}
+		if encrypt && !strings.HasSuffix(exportOutput, encryption.Extension) {
+			exportOutput += encryption.Extension
+		}
+
+		var recipients []age.Recipient
+		if encrypt {
+			identity, err := encryption.LoadIdentity(true)
+			if err != nil {
+				return err
+			}
+			extra, err := encryption.ParseRecipients(exportRecipients)
+			if err != nil {
+				return err
+			}
+			recipients = append([]age.Recipient{identity.Recipient()}, extra...)
+		}
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+
+		// Workspace mount source and labels identify where the session lives on this host
+		format := `{{range .Mounts}}{{if eq .Destination "/workspace"}}{{.Source}}{{end}}{{end}}|` +
+			`{{index .Config.Labels "packnplay-project"}}|{{index .Config.Labels "packnplay-worktree"}}|{{.Config.Image}}`
+		output, err := dockerClient.Run("inspect", "--format", format, containerName)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container %s: %w\n%s", containerName, err, output)
+		}
+
+		fields := strings.Split(strings.TrimSpace(output), "|")
+		if len(fields) != 4 || fields[0] == "" {
+			return fmt.Errorf("container %s is not a packnplay session (no /workspace mount)", containerName)
+		}
+
+		meta := session.Metadata{
+			Container: containerName,
+			Project:   fields[1],
+			Worktree:  fields[2],
+			Image:     fields[3],
+		}
+
+		extras, err := sessionExtras(dockerClient, containerName)
+		if err != nil {
+			return err
+		}
+
+		if err := session.Export(fields[0], meta, extras, exportOutput, recipients); err != nil {
+			return err
+		}
+
+		fmt.Printf("Exported %s to %s\n", containerName, exportOutput)
+		return nil
+	},
+}
+
+// sessionExtras collects what the session changed in its agents' config dirs since it
+// started, and its recordings
+func sessionExtras(dockerClient *docker.Client, containerName string) (session.Extras, error) {
+	output, err := dockerClient.Run("inspect", "--format", "{{.State.StartedAt}}|{{json .Mounts}}", containerName)
+	if err != nil {
+		return session.Extras{}, fmt.Errorf("failed to inspect container %s: %w\n%s", containerName, err, output)
+	}
+	startedAt, mountsJSON, _ := strings.Cut(strings.TrimSpace(output), "|")
+	started, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil {
+		return session.Extras{}, fmt.Errorf("failed to parse start time of %s: %w", containerName, err)
+	}
+	var mounts []session.Mount
+	if err := json.Unmarshal([]byte(mountsJSON), &mounts); err != nil {
+		return session.Extras{}, fmt.Errorf("failed to parse mounts of %s: %w", containerName, err)
+	}
+
+	var extras session.Extras
+	if extras.AgentFiles, err = session.AgentDeltas(mounts, started); err != nil {
+		return session.Extras{}, err
+	}
+	recordings, err := recording.List()
+	if err != nil {
+		return session.Extras{}, err
+	}
+	for _, r := range recordings {
+		if r.Session == containerName {
+			extras.Transcripts = append(extras.Transcripts, r.Path)
+		}
+	}
+	return extras, nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Archive path (.tar.zst or .tar.gz, default: <container>.tar.zst)")
+	exportCmd.Flags().BoolVar(&exportEncrypt, "encrypt", false, "Encrypt the archive with your storage key (adds .age)")
+	exportCmd.Flags().StringSliceVar(&exportRecipients, "recipient", []string{}, "Additional age public key that can decrypt the archive (implies --encrypt)")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/fsguard"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fsguardContainer string
+	fsguardRuntime   string
+	fsguardSource    string
+)
+
+var fsguardCmd = &cobra.Command{
+	Use:    "fsguard",
+	Short:  "Hold writes outside the allow list until the host user answers",
+	Long:   `Background daemon that mounts a guarded view of a workspace for one container and asks the host user before writes the project's write policy doesn't allow.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-fsguard")()
+
+		projectConfig, err := config.LoadProjectConfig(fsguardSource)
+		if err != nil {
+			return err
+		}
+		guard := fsguard.NewGuard(projectConfig.Writes, func(req fsguard.Request) {
+			notifyPendingWrite(fsguardContainer, req)
+		})
+
+		// Files the container creates get the modes it asks for, not ones masked by ours
+		syscall.Umask(0)
+		mountPoint := fsguard.MountPoint(fsguardContainer)
+		server, err := fsguard.Mount(fsguardSource, mountPoint, guard)
+		if err != nil {
+			return err
+		}
+		defer fsguard.Forget(fsguardContainer)
+
+		// The socket appearing tells 'packnplay run' the mount is ready
+		listener, err := fsguard.Listen(fsguardContainer)
+		if err != nil {
+			_ = fsguard.Unmount(mountPoint)
+			return err
+		}
+		defer listener.Close()
+		go func() {
+			if err := http.Serve(listener, guard); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+				log.Printf("Write guard control socket failed: %v", err)
+			}
+		}()
+
+		log.Printf("Guarding writes to %s for %s at %s", fsguardSource, fsguardContainer, mountPoint)
+
+		// Unmount once the container is gone, which ends Serve
+		go func() {
+			for {
+				time.Sleep(30 * time.Second)
+				if !isContainerRunning(fsguardRuntime, fsguardContainer) {
+					log.Printf("Container %s stopped, unmounting its workspace", fsguardContainer)
+					if err := fsguard.Unmount(mountPoint); err != nil {
+						log.Printf("%v", err)
+					}
+					return
+				}
+			}
+		}()
+
+		if err := server.Serve(); err != nil {
+			return fmt.Errorf("write guard failed: %w", err)
+		}
+		return nil
+	},
+}
+
+// notifyPendingWrite tells the host user a write is waiting, best effort
+func notifyPendingWrite(containerName string, req fsguard.Request) {
+	message := fmt.Sprintf("%s wants to %s %s. Run 'packnplay approve %s'.", containerName, req.Op, req.Path, containerName)
+	log.Print(message)
+	if err := exec.Command("notify-send", "packnplay", message).Run(); err != nil {
+		log.Printf("Desktop notification failed: %v", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(fsguardCmd)
+
+	fsguardCmd.Flags().StringVar(&fsguardContainer, "container", "", "Container whose workspace to guard")
+	fsguardCmd.Flags().StringVar(&fsguardRuntime, "runtime", "docker", "Container runtime used to check container liveness")
+	fsguardCmd.Flags().StringVar(&fsguardSource, "source", "", "Host workspace to guard")
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/githubapp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	githubTokenContainer string
+	githubTokenRuntime   string
+)
+
+var githubTokenCmd = &cobra.Command{
+	Use:    "github-token-refresher",
+	Short:  "Keep a container's GitHub App token fresh",
+	Long:   `Background daemon that replaces a session's GitHub App token before it expires, then revokes it once the container stops.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-github-token")()
+
+		dockerClient, err := docker.NewClientWithRuntime(githubTokenRuntime, false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+
+		log.Printf("Refreshing the GitHub token for %s until it stops", githubTokenContainer)
+		for isContainerRunning(githubTokenRuntime, githubTokenContainer) {
+			state, err := githubapp.Load(githubTokenContainer)
+			if err != nil {
+				log.Printf("Failed to read token state: %v", err)
+			} else if state == nil {
+				log.Printf("Token for %s was revoked, exiting", githubTokenContainer)
+				return nil
+			} else if state.NeedsRefresh(time.Now()) {
+				// A failure is retried on the next poll, while the old token is still valid
+				if err := githubapp.Refresh(dockerClient, githubTokenContainer); err != nil {
+					log.Printf("Failed to refresh token: %v", err)
+				} else {
+					log.Printf("Refreshed token for %s/%s", state.Owner, state.Repo)
+				}
+			}
+			time.Sleep(30 * time.Second)
+		}
+
+		if err := githubapp.RevokeSession(githubTokenContainer); err != nil {
+			log.Printf("Failed to revoke token for %s: %v", githubTokenContainer, err)
+			return err
+		}
+		log.Printf("Container %s stopped, revoked its GitHub token", githubTokenContainer)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(githubTokenCmd)
+
+	githubTokenCmd.Flags().StringVar(&githubTokenContainer, "container", "", "Container whose token to refresh")
+	githubTokenCmd.Flags().StringVar(&githubTokenRuntime, "runtime", "docker", "Container runtime used to check container liveness and publish tokens")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/cmdhistory"
+	"github.com/spf13/cobra"
+)
+
+var historyJSON bool
+
+var historyCmd = &cobra.Command{
+	Use:   "history <session>",
+	Short: "Show the commands run in a session, in order",
+	Long: `Show every command run in a session's container, oldest first: the bash -c
+commands agents run their tool calls with, and lines typed at an interactive
+shell. Sessions are named after their container, and their history is kept after
+they're stopped, for post-mortems:
+
+  packnplay history packnplay-app-main
+  packnplay history --json packnplay-app-main > commands.jsonl
+
+The history is recorded inside the sandbox, so it's the session's own account of
+what ran: commands started without bash (sh -c, or a binary run directly) aren't
+in it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := cmdhistory.Load(args[0])
+		if err != nil {
+			return err
+		}
+		if historyJSON {
+			return cmdhistory.WriteJSON(os.Stdout, entries)
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No commands recorded for %s\n", args[0])
+			return nil
+		}
+		for _, entry := range entries {
+			// Shown like a prompt, with continuation lines indented past the time and source
+			command := strings.ReplaceAll(entry.Command, "\n", "\n"+strings.Repeat(" ", 30))
+			fmt.Printf("%s  %-7s  %s$ %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Source, entry.Dir, command)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Print JSON lines, one per command")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/obra/packnplay/pkg/encryption"
+
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importPath     string
+	importIdentity string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file.tar.zst>",
+	Short: "Import a session exported on another machine",
+	Long: `Restore a session created by 'packnplay export'.
+
+For git projects, run this from your clone of the same repository: the session branch
+is fetched from the archive, checked out as a packnplay worktree, and uncommitted and
+untracked changes are reapplied. Continue with 'packnplay run --worktree=<branch> ...'.
+The session's agent config files and recordings are restored too, keeping any that
+already exist.
+
+Encrypted (.age) archives are decrypted with your storage key, or with --identity.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectPath := importPath
+		if projectPath == "" {
+			var err error
+			projectPath, err = os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+		}
+
+		projectPath, err := filepath.Abs(projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		var identities []age.Identity
+		if strings.HasSuffix(args[0], encryption.Extension) {
+			if identity, err := encryption.LoadIdentity(false); err == nil {
+				identities = append(identities, identity)
+			}
+			if importIdentity != "" {
+				fromFile, err := encryption.LoadIdentityFile(importIdentity)
+				if err != nil {
+					return err
+				}
+				identities = append(identities, fromFile...)
+			}
+			if len(identities) == 0 {
+				return fmt.Errorf("%s is encrypted but no storage key was found; pass --identity", args[0])
+			}
+		}
+
+		// Git sessions become a worktree; other workspaces are extracted into the project path
+		targetPath := projectPath
+		if git.IsGitRepo(projectPath) {
+			meta, err := session.ReadArchiveMetadata(args[0], identities)
+			if err != nil {
+				return err
+			}
+			if meta.Git {
+				targetPath = git.DetermineWorktreePath(projectPath, meta.Branch)
+			}
+		}
+
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+
+		meta, err := session.Import(args[0], projectPath, targetPath, homeDir, identities)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Imported session %s into %s\n", meta.Container, targetPath)
+		if meta.Git {
+			fmt.Printf("\nResume with:\n  packnplay run --worktree=%s <command>\n", meta.Branch)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVar(&importPath, "path", "", "Project path (default: pwd)")
+	importCmd.Flags().StringVar(&importIdentity, "identity", "", "age identity file for archives encrypted on another machine")
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"text/tabwriter"
 
 	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/session"
 	"github.com/spf13/cobra"
 )
 
@@ -16,11 +18,20 @@ type ContainerInfo struct {
 	Labels string `json:"Labels"`
 }
 
+var listAllHosts bool
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all packnplay-managed containers",
-	Long:  `Display all running containers managed by packnplay.`,
+	Long: `Display all running containers managed by packnplay.
+
+With --all-hosts, list every machine's sessions from the shared session store
+(session_store in the config) instead.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if listAllHosts {
+			return listSharedSessions()
+		}
+
 		// Initialize Docker client
 		dockerClient, err := docker.NewClient(false)
 		if err != nil {
@@ -42,9 +53,15 @@ var listCmd = &cobra.Command{
 			return nil
 		}
 
+		// Session lifecycle states; containers packnplay doesn't track show "-"
+		entries, err := session.LoadStore()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
 		// Parse JSON output
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "CONTAINER\tSTATUS\tPROJECT\tWORKTREE")
+		fmt.Fprintln(w, "CONTAINER\tSTATUS\tSESSION\tPROJECT\tWORKTREE")
 
 		// Docker outputs one JSON object per line
 		lines := splitLines(output)
@@ -62,9 +79,15 @@ var listCmd = &cobra.Command{
 			// Parse labels to extract project and worktree
 			project, worktree := parseLabels(info.Labels)
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			state := "-"
+			if entry, ok := entries[info.Names]; ok {
+				state = string(entry.CurrentState())
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
 				info.Names,
 				info.Status,
+				state,
 				project,
 				worktree,
 			)
@@ -75,6 +98,38 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// listSharedSessions prints the sessions every machine has recorded in the session store
+func listSharedSessions() error {
+	all, err := session.LoadAllHosts()
+	if err != nil {
+		return err
+	}
+	hosts := make([]string, 0, len(all))
+	for host := range all {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "HOST\tCONTAINER\tSESSION\tPROJECT\tWORKTREE\tAGENT\tSTARTED")
+	for _, host := range hosts {
+		names := make([]string, 0, len(all[host]))
+		for name := range all[host] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			entry := all[host][name]
+			agent := entry.Agent
+			if agent == "" {
+				agent = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", host, name, entry.CurrentState(), entry.Project, entry.Worktree, agent, entry.StartedAt.Local().Format("2006-01-02 15:04"))
+		}
+	}
+	return w.Flush()
+}
+
 func splitLines(s string) []string {
 	var lines []string
 	start := 0
@@ -132,4 +187,6 @@ func splitByEquals(s string) []string {
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolVar(&listAllHosts, "all-hosts", false, "List every machine's sessions from the shared session store")
 }
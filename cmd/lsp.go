@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/lsp"
+	"github.com/obra/packnplay/pkg/sessionenv"
+	"github.com/spf13/cobra"
+)
+
+var lspListen string
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp [--listen addr] <container> <server> [args...]",
+	Short: "Run a language server in a session for your host editor",
+	Long: `Run a language server inside a session's container, with the toolchain and
+dependencies the agent uses, and connect your host editor to it. Diagnostics
+then match what the agent sees.
+
+By default the server speaks LSP on stdin/stdout, so point your editor's server
+command at packnplay:
+
+  packnplay lsp packnplay-app-main gopls
+  packnplay lsp packnplay-app-main typescript-language-server --stdio
+
+With --listen the server is offered on a socket instead (host:port, or a path for
+a Unix socket), for editors that connect to a running server. Each connection
+gets its own server process.
+
+File URIs are translated between the host checkout and the container's paths in
+both directions.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName, server := args[0], args[1:]
+		if server[0] == "--" {
+			server = server[1:]
+		}
+		if len(server) == 0 {
+			return fmt.Errorf("lsp needs a language server command, e.g. packnplay lsp %s gopls", containerName)
+		}
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+		if !isContainerRunning(dockerClient.Command(), containerName) {
+			return fmt.Errorf("container %s is not running", containerName)
+		}
+		_, mounts, err := containerMounts(dockerClient, containerName)
+		if err != nil {
+			return err
+		}
+		var mappings []lsp.Mapping
+		for _, m := range mounts {
+			if m.Type == "bind" && m.Source != m.Destination {
+				mappings = append(mappings, lsp.Mapping{Host: m.Source, Container: m.Destination})
+			}
+		}
+		rewriter := lsp.NewRewriter(mappings)
+
+		if lspListen == "" {
+			return runLanguageServer(dockerClient, containerName, server, rewriter, os.Stdin, os.Stdout)
+		}
+
+		network := "tcp"
+		if strings.Contains(lspListen, "/") {
+			network = "unix"
+			os.Remove(lspListen)
+		}
+		listener, err := net.Listen(network, lspListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", lspListen, err)
+		}
+		defer listener.Close()
+		fmt.Fprintf(os.Stderr, "Serving %s from %s on %s\n", strings.Join(server, " "), containerName, listener.Addr())
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return fmt.Errorf("failed to accept connection: %w", err)
+			}
+			go func() {
+				defer conn.Close()
+				if err := runLanguageServer(dockerClient, containerName, server, rewriter, conn, conn); err != nil {
+					fmt.Fprintf(os.Stderr, "packnplay lsp: %v\n", err)
+				}
+			}()
+		}
+	},
+}
+
+// runLanguageServer starts server in the container and relays the editor's messages to it
+// until either side hangs up
+func runLanguageServer(dockerClient *docker.Client, containerName string, server []string, rewriter *lsp.Rewriter, in io.Reader, out io.Writer) error {
+	// Servers are started like the agent's commands, with the session's env changes applied
+	args := append([]string{"exec", "-i", containerName}, sessionenv.Wrap(server)...)
+	serverCmd := dockerClient.Stream(args...)
+	serverCmd.Stderr = os.Stderr
+	serverIn, err := serverCmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to language server: %w", err)
+	}
+	serverOut, err := serverCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to language server: %w", err)
+	}
+	if err := serverCmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", server[0], err)
+	}
+
+	go func() {
+		if err := lsp.Forward(serverIn, in, rewriter.ToContainer); err != nil {
+			fmt.Fprintf(os.Stderr, "packnplay lsp: %v\n", err)
+		}
+		serverIn.Close()
+	}()
+	forwardErr := lsp.Forward(out, serverOut, rewriter.ToHost)
+	if err := serverCmd.Wait(); err != nil {
+		return fmt.Errorf("%s exited: %w", server[0], err)
+	}
+	return forwardErr
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+
+	// Flags after the container belong to the server
+	lspCmd.Flags().SetInterspersed(false)
+	lspCmd.Flags().StringVar(&lspListen, "listen", "", "Serve on a socket (host:port or a Unix socket path) instead of stdin/stdout")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/obra/packnplay/pkg/mdns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mdnsContainer string
+	mdnsRuntime   string
+	mdnsName      string
+)
+
+var mdnsCmd = &cobra.Command{
+	Use:    "mdns-publisher",
+	Short:  "Publish a session's .local name on the LAN while it runs",
+	Long:   `Background daemon that answers mDNS queries for a session's .local name with this host's LAN addresses, until the container stops.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run --lan'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-mdns")()
+
+		ips, err := mdns.LANAddresses()
+		if err != nil {
+			return err
+		}
+		if len(ips) == 0 {
+			return fmt.Errorf("no LAN address to publish %s on", mdnsName)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			for isContainerRunning(mdnsRuntime, mdnsContainer) {
+				time.Sleep(10 * time.Second)
+			}
+			cancel()
+		}()
+
+		log.Printf("Publishing %s at %v for %s", mdnsName, ips, mdnsContainer)
+		responder := &mdns.Responder{Name: mdnsName, IPs: ips}
+		if err := responder.Serve(ctx); err != nil {
+			log.Printf("mDNS for %s: %v", mdnsContainer, err)
+			return err
+		}
+		log.Printf("Container %s stopped, withdrew %s", mdnsContainer, mdnsName)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mdnsCmd)
+
+	mdnsCmd.Flags().StringVar(&mdnsContainer, "container", "", "Container whose name to publish")
+	mdnsCmd.Flags().StringVar(&mdnsRuntime, "runtime", "docker", "Container runtime used to check container liveness")
+	mdnsCmd.Flags().StringVar(&mdnsName, "name", "", "The .local name to publish")
+}
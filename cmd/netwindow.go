@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	windowContainer string
+	windowRuntime   string
+	windowUntil     string
+	windowAllow     string
+)
+
+var netWindowCmd = &cobra.Command{
+	Use:    "network-window",
+	Short:  "Cut off a container's network when its window closes",
+	Long:   `Background daemon that waits until a container's network window closes, then limits it to the allowed domains.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-network-window")()
+
+		deadline, err := time.Parse(time.RFC3339, windowUntil)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		dockerClient, err := docker.NewClientWithRuntime(windowRuntime, false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+
+		log.Printf("Network for %s is open until %s", windowContainer, deadline.Format(time.RFC3339))
+		for time.Now().Before(deadline) {
+			if !isContainerRunning(windowRuntime, windowContainer) {
+				log.Printf("Container %s stopped before its window closed", windowContainer)
+				return nil
+			}
+			time.Sleep(min(time.Until(deadline), 30*time.Second))
+		}
+
+		outcome, err := runner.CutOffNetwork(dockerClient, windowContainer, strings.Split(windowAllow, ","))
+		if err != nil {
+			// Last resort: a sandbox whose network can't be closed doesn't keep running
+			log.Printf("%v; stopping %s", err, windowContainer)
+			_, _ = dockerClient.Run("stop", windowContainer)
+			return err
+		}
+		log.Printf("Network window for %s closed: %s", windowContainer, outcome)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(netWindowCmd)
+
+	netWindowCmd.Flags().StringVar(&windowContainer, "container", "", "Container whose network to cut off")
+	netWindowCmd.Flags().StringVar(&windowRuntime, "runtime", "docker", "Container runtime")
+	netWindowCmd.Flags().StringVar(&windowUntil, "until", "", "When the window closes (RFC 3339)")
+	netWindowCmd.Flags().StringVar(&windowAllow, "allow", "", "Comma-separated domains still reachable afterwards")
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/hostopen"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+	"github.com/obra/packnplay/pkg/writeback"
+	"github.com/spf13/cobra"
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open <container> <path>[:line]",
+	Short: "Open a file an agent mentioned in your editor",
+	Long: `Open a path printed inside a container in your editor on the host.
+
+The container path is mapped back to the host directory mounted there, so
+/workspace/src/main.go:42 opens src/main.go of your checkout at line 42. Relative
+paths are taken from the container's working directory. The editor is $VISUAL or
+$EDITOR; without either the file goes to your desktop's default application (the
+default text editor on macOS). Directories are refused, and so are executables
+unless an editor is set, since the default application would run them.
+
+In sync mode the session's pending edits are written back first, so you see the
+agent's current version.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName := args[0]
+		containerPath, line := hostopen.SplitLocation(args[1])
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+		hostPath, err := resolveHostPath(dockerClient, containerName, containerPath)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			return fmt.Errorf("%s maps to %s, which doesn't exist on the host", containerPath, hostPath)
+		}
+
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if err := hostopen.CheckOpenable(info, editor); err != nil {
+			return err
+		}
+		editorArgs := hostopen.EditorCommand(editor, hostPath, line, runtime.GOOS)
+		editorCmd := exec.Command(editorArgs[0], editorArgs[1:]...)
+		editorCmd.Stdin = os.Stdin
+		editorCmd.Stdout = os.Stdout
+		editorCmd.Stderr = os.Stderr
+		if err := editorCmd.Run(); err != nil {
+			return fmt.Errorf("failed to run %s: %w", strings.Join(editorArgs, " "), err)
+		}
+		return nil
+	},
+}
+
+// resolveHostPath maps a path in a container to the host file behind it
+func resolveHostPath(dockerClient *docker.Client, containerName, containerPath string) (string, error) {
+	// Flush a sync-mode session's edits so the host copy is current
+	if writeback.Active(containerName) {
+		if _, err := writeback.Flush(dockerClient, containerName, true); err != nil {
+			return "", err
+		}
+	}
+	workdir, mounts, err := containerMounts(dockerClient, containerName)
+	if err != nil {
+		return "", err
+	}
+
+	hostPath, err := hostopen.HostPath(mounts, containerPath, workdir)
+	if err != nil && sessionbranch.Active(containerName) {
+		return "", fmt.Errorf("%w (split-mode edits reach the host as commits on the session branch)", err)
+	}
+	return hostPath, err
+}
+
+// containerMounts returns a container's working directory and mounts
+// A sync-mode workspace is a volume whose edits land in the checkout, so it's
+// reported as a bind mount of the checkout.
+func containerMounts(dockerClient *docker.Client, containerName string) (string, []hostopen.Mount, error) {
+	output, err := dockerClient.Run("inspect", "--format", "{{.Config.WorkingDir}}|{{json .Mounts}}", containerName)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to inspect container %s: %w\n%s", containerName, err, output)
+	}
+	workdir, mountsJSON, _ := strings.Cut(strings.TrimSpace(output), "|")
+	var mounts []hostopen.Mount
+	if err := json.Unmarshal([]byte(mountsJSON), &mounts); err != nil {
+		return "", nil, fmt.Errorf("failed to parse mounts of %s: %w", containerName, err)
+	}
+
+	if writeback.Active(containerName) {
+		workspace, err := writeback.Workspace(containerName)
+		if err != nil {
+			return "", nil, err
+		}
+		for i := range mounts {
+			if mounts[i].Destination == "/workspace" {
+				mounts[i].Type, mounts[i].Source = "bind", workspace
+			}
+		}
+	}
+	return workdir, mounts, nil
+}
+
+func init() {
+	rootCmd.AddCommand(openCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+	"github.com/obra/packnplay/pkg/runner"
+	"github.com/obra/packnplay/pkg/session"
+)
+
+// handleOrphan asks what to do with a session container packnplay had lost track of
+// Without a terminal the container is just adopted, so scripts never block or lose work.
+func handleOrphan(orphan session.Entry) (runner.OrphanAction, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return runner.OrphanKeep, nil
+	}
+
+	options := []huh.Option[runner.OrphanAction]{
+		huh.NewOption("Keep it", runner.OrphanKeep),
+	}
+	if orphan.Running {
+		options = append(options, huh.NewOption("Attach to it instead", runner.OrphanAttach))
+	}
+	options = append(options, huh.NewOption("Stop and remove it", runner.OrphanRemove))
+
+	description := fmt.Sprintf("project %s, worktree %s", orphan.Project, orphan.Worktree)
+	if !orphan.StartedAt.IsZero() {
+		description += fmt.Sprintf(", created %s", orphan.StartedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	action := runner.OrphanKeep
+	err := huh.NewSelect[runner.OrphanAction]().
+		Title(fmt.Sprintf("Found untracked session %s", orphan.Container)).
+		Description(description).
+		Options(options...).
+		Value(&action).
+		Run()
+	if err != nil {
+		return runner.OrphanKeep, fmt.Errorf("session prompt failed: %w", err)
+	}
+	return action, nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/spf13/cobra"
+)
+
+// pairAgent is the second agent of a 'packnplay pair' session, "" for other sessions
+var pairAgent string
+
+var pairCmd = &cobra.Command{
+	Use:   "pair [flags] <agent>[@dir] <agent>",
+	Short: "Run two agents side by side in one sandbox",
+	Long: `Run two agents in the same container, in side-by-side tmux panes on the host,
+for pair-of-agents experiments. Takes the same flags as 'packnplay run':
+
+  packnplay pair claude codex
+  packnplay pair --yolo claude@~/src/api gemini
+
+Both agents share the workspace, caches and everything else in the container, and
+each keeps its own config dir. Each pane's agent isn't given the API key and other
+variables only the other agent uses, but both run as the same user, so either can read
+the other's credentials; run separate sessions to keep agents apart. Detach with tmux
+(Ctrl-b d); running the same 'packnplay pair' again returns to the panes, and
+'packnplay stop' ends the session. Needs tmux on the host.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		first := args[0]
+		if agent, _, ok := splitAgentTarget(first); ok {
+			first = agent
+		}
+		for _, name := range []string{first, args[1]} {
+			if agents.Lookup(name) == nil {
+				return fmt.Errorf("unknown agent '%s'", name)
+			}
+		}
+		if agents.Lookup(first).Name() == agents.Lookup(args[1]).Name() {
+			return fmt.Errorf("pair needs two different agents; run 'packnplay run --reconnect %s' for another %s in the same session", first, first)
+		}
+
+		if runNoContainer {
+			return fmt.Errorf("pair runs both agents in one container; it can't be used with --no-container")
+		}
+
+		pairAgent = agents.Lookup(args[1]).Name()
+		// Pairing again returns to the running session's panes
+		runReconnect = true
+		return startSession(cmd, args[:1], false, false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pairCmd)
+
+	pairCmd.Flags().SetInterspersed(false)
+}
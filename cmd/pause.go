@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var pauseCheckpoint bool
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <container>",
+	Short: "Pause a session to free its CPU",
+	Long: `Freeze every process in a session container so it stops using CPU, without
+killing the agent. Its terminal state is kept: after 'packnplay resume', an
+attached agent carries on where it was.
+
+Paused memory stays allocated (the kernel may swap it out). With --checkpoint,
+the processes are written to disk with CRIU and the container is stopped, freeing
+memory too. That needs CRIU on the host, and Docker's experimental mode or Podman
+as root; terminals attached to the session are disconnected, so reattach with
+'packnplay attach' after resuming. Sessions with a network window or burner keys
+can't be checkpointed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+		if err := runner.Pause(dockerClient, args[0], pauseCheckpoint); err != nil {
+			return err
+		}
+		if pauseCheckpoint {
+			fmt.Printf("Checkpointed %s; 'packnplay resume %s' restores it\n", args[0], args[0])
+		} else {
+			fmt.Printf("Paused %s; 'packnplay resume %s' continues it\n", args[0], args[0])
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+
+	pauseCmd.Flags().BoolVar(&pauseCheckpoint, "checkpoint", false, "Checkpoint to disk with CRIU and stop the container, freeing its memory")
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+	"github.com/obra/packnplay/pkg/agents"
+)
+
+// pickAgent interactively asks which agent to launch and returns its command
+func pickAgent() (string, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("no command given (e.g. 'packnplay run claude')")
+	}
+
+	supported := agents.GetSupportedAgents()
+	options := make([]huh.Option[string], 0, len(supported))
+	for _, agent := range supported {
+		label := agent.Name()
+		if agent.Command() != agent.Name() {
+			label = fmt.Sprintf("%s (%s)", agent.Name(), agent.Command())
+		}
+		options = append(options, huh.NewOption(label, agent.Command()))
+	}
+
+	var command string
+	err := huh.NewSelect[string]().
+		Title("Select an agent").
+		Description("Runs the agent CLI inside the container").
+		Options(options...).
+		Value(&command).
+		Run()
+	if err != nil {
+		return "", fmt.Errorf("agent selection failed: %w", err)
+	}
+
+	return command, nil
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pipelinePath     string
+	pipelineWorktree string
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline <file.yaml>",
+	Short: "Run agents in sequence, each handed the previous one's work",
+	Long: `Run a pipeline of headless agents on one worktree. Each step runs in a fresh
+sandbox and is handed what earlier steps printed (their summary) and the diff
+they made, so one agent can implement and another review:
+
+  name: implement-review
+  steps:
+    - name: implement
+      agent: codex
+      prompt: Add a --json flag to 'list'.
+    - name: review
+      agent: claude
+      prompt: Review the change below. Fix any bugs you find and summarize what you changed.
+
+A step sees the step before it; 'from: [implement, test]' hands it the results of
+the named earlier steps instead. Every step works in the worktree given by
+'worktree:' (default pipeline-<name>); --worktree overrides it.
+
+Each step's output and diff are saved under ~/.local/share/packnplay/pipelines/.
+A failing step stops the pipeline.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := pipeline.Load(args[0])
+		if err != nil {
+			return err
+		}
+		if pipelineWorktree != "" {
+			p.Worktree = pipelineWorktree
+		}
+
+		projectPath := pipelinePath
+		if projectPath == "" {
+			if projectPath, err = os.Getwd(); err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+		}
+		root, err := git.FindRoot(projectPath)
+		if err != nil {
+			return fmt.Errorf("pipelines hand diffs between agents, so they need a git repository: %w", err)
+		}
+		worktreePath, err := pipelineWorktreePath(root, p.WorktreeName())
+		if err != nil {
+			return err
+		}
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+		containerName := container.GenerateContainerName(root, p.WorktreeName())
+		if _, err := dockerClient.Run("inspect", "--format", "{{.Name}}", containerName); err == nil {
+			return fmt.Errorf("a session is already running in worktree %s; stop it first with 'packnplay stop %s'", p.WorktreeName(), containerName)
+		}
+
+		outputDir, err := pipelineOutputDir(p.Name)
+		if err != nil {
+			return err
+		}
+
+		self, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find packnplay executable: %w", err)
+		}
+		results := make(map[string]pipeline.Result)
+		for i, step := range p.Steps {
+			fmt.Fprintf(os.Stderr, "==> Step %d/%d: %s (%s)\n", i+1, len(p.Steps), step.Name, step.Agent)
+
+			before, err := git.SnapshotTree(worktreePath)
+			if err != nil {
+				return err
+			}
+			var summary bytes.Buffer
+			stepCmd := exec.Command(self, "exec", "--path", root, "--worktree", p.WorktreeName(), step.Agent, "--prompt-stdin")
+			stepCmd.Stdin = strings.NewReader(p.Prompt(i, results))
+			stepCmd.Stdout = io.MultiWriter(os.Stdout, &summary)
+			stepCmd.Stderr = os.Stderr
+			runErr := stepCmd.Run()
+
+			// Every step gets a fresh sandbox; stopping also writes back sync-mode edits before the diff
+			if _, err := dockerClient.Run("inspect", "--format", "{{.Name}}", containerName); err == nil {
+				if err := stopContainer(dockerClient, containerName); err != nil {
+					return fmt.Errorf("failed to stop step %s: %w", step.Name, err)
+				}
+			}
+			if runErr != nil {
+				return fmt.Errorf("step %s failed: %w\nOutputs so far are in %s", step.Name, runErr, outputDir)
+			}
+
+			after, err := git.SnapshotTree(worktreePath)
+			if err != nil {
+				return err
+			}
+			diff, err := git.DiffTrees(worktreePath, before, after)
+			if err != nil {
+				return err
+			}
+			results[step.Name] = pipeline.Result{Step: step.Name, Agent: step.Agent, Summary: summary.String(), Diff: diff}
+
+			base := filepath.Join(outputDir, fmt.Sprintf("%02d-%s", i+1, step.Name))
+			if err := os.WriteFile(base+".md", summary.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to save step output: %w", err)
+			}
+			if err := os.WriteFile(base+".diff", diff, 0644); err != nil {
+				return fmt.Errorf("failed to save step diff: %w", err)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "Pipeline %s finished in %s\nStep outputs and diffs: %s\n", p.Name, worktreePath, outputDir)
+		return nil
+	},
+}
+
+// pipelineWorktreePath returns the worktree a pipeline works in, creating it if needed
+// Each step's diff is taken there, so it has to exist before the first agent starts.
+func pipelineWorktreePath(root, name string) (string, error) {
+	exists, err := git.WorktreeExists(root, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to check worktree: %w", err)
+	}
+	if exists {
+		return git.GetWorktreePath(root, name)
+	}
+	path := git.DetermineWorktreePath(root, name)
+	if err := git.CreateWorktree(root, path, name, false); err != nil {
+		return "", fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return path, nil
+}
+
+// pipelineOutputDir creates the directory a pipeline run's step outputs are saved in
+func pipelineOutputDir(name string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	dir := filepath.Join(xdgDataHome, "packnplay", "pipelines", fmt.Sprintf("%s-%s", name, time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create pipeline output directory: %w", err)
+	}
+	return dir, nil
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+
+	pipelineCmd.Flags().StringVar(&pipelinePath, "path", "", "Project path (default: pwd)")
+	pipelineCmd.Flags().StringVar(&pipelineWorktree, "worktree", "", "Worktree every step works in (default: the pipeline's)")
+}
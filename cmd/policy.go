@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/netpolicy"
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage sandbox network policy",
+	Long:  `Inspect and update the network policy applied to packnplay containers.`,
+}
+
+var policyUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Download the latest domain blocklists",
+	Long: `Download each blocklist listed under network_policy.blocklist_sources in the
+config file. Updated lists replace the builtin copies for future runs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadWithoutRuntimeCheck()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		sources := cfg.NetworkPolicy.BlocklistSources
+		if len(sources) == 0 {
+			fmt.Println("No blocklist sources configured (set network_policy.blocklist_sources in config)")
+			return nil
+		}
+
+		names := make([]string, 0, len(sources))
+		for name := range sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var failed []string
+		for _, name := range names {
+			count, err := netpolicy.UpdateBlocklist(name, sources[name])
+			if err != nil {
+				fmt.Printf("✗ %s: %v\n", name, err)
+				failed = append(failed, name)
+				continue
+			}
+			fmt.Printf("✓ %s: %d domains\n", name, count)
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to update blocklists: %s", strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+var policyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the active network policy",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadWithoutRuntimeCheck()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		fmt.Printf("Available blocklists: %s\n", strings.Join(netpolicy.BuiltinBlocklists(), ", "))
+		if len(cfg.NetworkPolicy.Blocklists) == 0 {
+			fmt.Println("Enabled blocklists:   (none)")
+			return nil
+		}
+
+		fmt.Printf("Enabled blocklists:   %s\n", strings.Join(cfg.NetworkPolicy.Blocklists, ", "))
+		domains, err := netpolicy.ResolveBlockedDomains(cfg.NetworkPolicy.Blocklists)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Blocked domains:      %d (exact names only; subdomains aren't blocked)\n", len(domains))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyUpdateCmd)
+	policyCmd.AddCommand(policyShowCmd)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/redact"
+	"github.com/spf13/cobra"
+)
+
+var redactInPlace bool
+
+var redactCmd = &cobra.Command{
+	Use:   "redact [file...]",
+	Short: "Mask secrets in transcripts and logs",
+	Long: `Mask credentials in agent transcripts, logs, or terminal captures before sharing them.
+
+Masks the current values of every API key packnplay forwards into sandboxes, plus
+well-known formats: AWS keys, JWTs, Anthropic/OpenAI/GitHub/Slack/Google tokens and
+PEM private keys. Reads stdin when no files are given and writes to stdout unless
+--in-place is set.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envVars := agents.GetDefaultEnvVars()
+		if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil {
+			envVars = append(envVars, cfg.DefaultEnvVars...)
+		}
+		redactor := redact.FromEnv(envVars, os.Getenv)
+
+		if len(args) == 0 {
+			if redactInPlace {
+				return fmt.Errorf("--in-place needs file arguments")
+			}
+			w := redact.NewWriter(redactor, os.Stdout)
+			if _, err := io.Copy(w, os.Stdin); err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			return w.Close()
+		}
+
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			redacted := redactor.Redact(string(data))
+
+			if !redactInPlace {
+				fmt.Print(redacted)
+				continue
+			}
+			if redacted == string(data) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+			if err := os.WriteFile(path, []byte(redacted), info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			fmt.Fprintf(os.Stderr, "Redacted %s\n", path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(redactCmd)
+
+	redactCmd.Flags().BoolVarP(&redactInPlace, "in-place", "i", false, "Rewrite files instead of printing")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replaySpeed   float64
+	replayMaxIdle time.Duration
+	replayList    bool
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay [session | file.cast]",
+	Short: "Play back a recorded session",
+	Long: `Play back a session recorded with 'packnplay run --record' (or record_sessions in config).
+
+Sessions are named after their container; with several recordings for one session, the latest is played.
+Recordings are asciicast v2 files, so 'asciinema play' and asciinema-player work on them too.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if replayList || len(args) == 0 {
+			return listRecordings()
+		}
+
+		path, err := recording.Find(args[0])
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open recording: %w", err)
+		}
+		defer file.Close()
+
+		_, events, err := recording.Read(file)
+		if err != nil {
+			return err
+		}
+
+		return recording.Play(os.Stdout, events, recording.PlayOptions{Speed: replaySpeed, MaxIdle: replayMaxIdle})
+	},
+}
+
+// listRecordings prints the stored recordings, newest last
+func listRecordings() error {
+	recordings, err := recording.List()
+	if err != nil {
+		return err
+	}
+	if len(recordings) == 0 {
+		fmt.Println("No recorded sessions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tSTARTED\tFILE")
+	for _, rec := range recordings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rec.Session, rec.Started.Format("2006-01-02 15:04:05"), rec.Path)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().Float64VarP(&replaySpeed, "speed", "s", 1, "Playback speed multiplier (e.g. 2 for double speed)")
+	replayCmd.Flags().DurationVar(&replayMaxIdle, "max-idle", 2*time.Second, "Shorten pauses longer than this (0 keeps them)")
+	replayCmd.Flags().BoolVar(&replayList, "list", false, "List recorded sessions")
+}
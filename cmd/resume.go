@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <container>",
+	Short: "Resume a paused or checkpointed session",
+	Long:  `Continue a session stopped with 'packnplay pause', unpausing it or restoring its checkpoint.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+		how, err := runner.Resume(dockerClient, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Resumed %s (%s)\n", args[0], how)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
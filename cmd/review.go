@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/review"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/sessionenv"
+	"github.com/spf13/cobra"
+)
+
+var reviewTask string
+
+var reviewCmd = &cobra.Command{
+	Use:   "review <container>",
+	Short: "Send a session's changes to the configured reviewers",
+	Long: `Send everything a session changed since it started to the reviewers in the
+config's "reviewers": a host command given the diff on stdin, another agent run
+headless in the session, or a review service the diff is POSTed to as JSON.
+
+  "reviewers": [
+    {"name": "second-opinion", "agent": "codex"},
+    {"name": "review-bot", "url": "https://review.internal/packnplay",
+     "headers": {"Authorization": "Bearer ${REVIEW_BOT_TOKEN}"}, "gate": true}
+  ]
+
+Reviewers answer APPROVE or REJECT on their first line, or JSON like
+{"decision": "reject", "summary": "..."}. Verdicts are attached to the session
+and shown by 'packnplay sessions'. A gating reviewer that rejects, or can't be
+reached, makes this command fail and blocks 'packnplay verify --auto-pr'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		containerName := args[0]
+		cfg, err := config.LoadWithoutRuntimeCheck()
+		if err != nil {
+			return err
+		}
+		if len(cfg.Reviewers) == 0 {
+			return fmt.Errorf("no reviewers configured; add \"reviewers\" to %s", config.GetConfigPath())
+		}
+		dockerClient, err := docker.NewClientWithRuntime(cfg.ContainerRuntime, false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize container runtime: %w", err)
+		}
+
+		format := fmt.Sprintf(`{{range .Mounts}}{{if eq .Destination "/workspace"}}{{.Source}}{{end}}{{end}}|{{index .Config.Labels "packnplay-project"}}|{{index .Config.Labels "packnplay-worktree"}}|{{index .Config.Labels %q}}`, container.StartCommitLabel)
+		output, err := dockerClient.Run("inspect", "--format", format, containerName)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container %s: %w\n%s", containerName, err, output)
+		}
+		fields := strings.Split(strings.TrimSpace(output), "|")
+		if len(fields) != 4 || fields[0] == "" {
+			return fmt.Errorf("container %s is not a packnplay session (no /workspace mount)", containerName)
+		}
+		workspace, startCommit := fields[0], fields[3]
+
+		task := reviewTask
+		if task == "" {
+			task = fields[2]
+		}
+		verdicts, err := reviewSession(dockerClient, cfg.Reviewers, containerName, workspace, startCommit, review.Request{
+			Session:  containerName,
+			Project:  fields[1],
+			Worktree: fields[2],
+			Task:     task,
+		})
+		if err != nil {
+			return err
+		}
+		if blocking := blockingVerdicts(verdicts); len(blocking) > 0 {
+			return fmt.Errorf("%d gating reviewer(s) did not approve", len(blocking))
+		}
+		return nil
+	},
+}
+
+// reviewSession sends the session's diff since base to each reviewer, attaches their
+// verdicts to the session and prints them. No changes means nothing to review.
+func reviewSession(dockerClient *docker.Client, reviewers []config.ReviewerConfig, containerName, workspace, base string, req review.Request) ([]review.Verdict, error) {
+	if err := review.Validate(reviewers); err != nil {
+		return nil, err
+	}
+	diff, err := git.DiffSince(workspace, base)
+	if err != nil {
+		return nil, err
+	}
+	if len(diff) == 0 {
+		fmt.Println("No changes to review")
+		return nil, nil
+	}
+	req.Diff = string(diff)
+
+	// Agent reviewers run in the session's container, where its agents are installed and signed in
+	agentCommand := func(name string) ([]string, error) {
+		agent := agents.Lookup(name)
+		headless, ok := agent.(agents.HeadlessRunner)
+		if !ok {
+			return nil, fmt.Errorf("%s has no headless mode", name)
+		}
+		return append([]string{dockerClient.Command(), "exec", "-i", containerName},
+			sessionenv.Wrap(append([]string{agent.Command()}, headless.HeadlessArgs()...))...), nil
+	}
+
+	fmt.Printf("\nSending changes to %d reviewer(s)...\n", len(reviewers))
+	verdicts := review.RunAll(context.Background(), reviewers, req, agentCommand)
+	for _, verdict := range verdicts {
+		gate := ""
+		if verdict.Gate {
+			gate = " (gating)"
+		}
+		fmt.Printf("  %s%s: %s\n", verdict.Reviewer, gate, verdict.Decision)
+		if verdict.Summary != "" {
+			fmt.Printf("    %s\n", strings.ReplaceAll(verdict.Summary, "\n", "\n    "))
+		}
+		if err := session.AddReview(containerName, verdict); err != nil && !errors.Is(err, session.ErrUntracked) {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return verdicts, nil
+}
+
+// blockingVerdicts returns the verdicts that stop publishing
+func blockingVerdicts(verdicts []review.Verdict) []review.Verdict {
+	var blocking []review.Verdict
+	for _, verdict := range verdicts {
+		if verdict.Blocks() {
+			blocking = append(blocking, verdict)
+		}
+	}
+	return blocking
+}
+
+// describeVerdicts lists the verdicts for a pull request description
+func describeVerdicts(verdicts []review.Verdict) string {
+	var b strings.Builder
+	for _, verdict := range verdicts {
+		summary, _, _ := strings.Cut(verdict.Summary, "\n")
+		fmt.Fprintf(&b, "- **%s**: %s", verdict.Reviewer, verdict.Decision)
+		if summary != "" {
+			fmt.Fprintf(&b, " — %s", summary)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+
+	reviewCmd.Flags().StringVar(&reviewTask, "task", "", "What the session was asked to do, for the reviewers (default: worktree name)")
+}
@@ -2,8 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"reflect"
+	"sync"
 
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/logging"
+	"github.com/obra/packnplay/pkg/session"
 	"github.com/spf13/cobra"
 )
 
@@ -20,9 +27,68 @@ Configuration:
 
 Default container: ghcr.io/obra/packnplay-default:latest
   Includes: Node.js, Claude Code, OpenAI Codex, Google Gemini, GitHub CLI,
-            GitHub Copilot, Qwen Code, Cursor CLI, Sourcegraph Amp
+            GitHub Copilot, Qwen Code, Cursor CLI, Sourcegraph Amp,
+            Amazon Q Developer, Augment Auggie, Grok CLI,
+            Crush, Cline, Kilo Code
 
-Supported AI agents: claude, codex, gemini, copilot, qwen, cursor, amp, deepseek`,
+Supported AI agents: claude, codex, gemini, copilot, qwen, cursor, amp, deepseek,
+                     amazonq, auggie, grok, crush, cline, kilocode, roo`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// A team's shared session registry replaces the local session file for every command,
+		// and encrypt_storage applies to everything any command stores
+		if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil {
+			encryption.ConfigureStorage(cfg.EncryptStorage)
+			return session.Configure(cfg.SessionStore)
+		}
+		return nil
+	},
+}
+
+// setupDaemonLogging sends a background process's log output to the configured driver
+// Without a logging config the output is left alone (discarded for detached daemons).
+// The config is watched, so logging changes apply without restarting the daemon.
+func setupDaemonLogging(tag string) func() {
+	cfg, err := config.LoadWithoutRuntimeCheck()
+	if err != nil {
+		return func() {}
+	}
+	closeLog, err := logging.Setup(cfg.Logging, tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	var mu sync.Mutex
+	current := cfg.Logging
+	stopWatch, err := config.Watch(func(cfg *config.Config, err error) {
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if reflect.DeepEqual(cfg.Logging, current) {
+			return
+		}
+		if err := logging.Validate(cfg.Logging); err != nil {
+			log.Printf("Ignoring logging change: %v", err)
+			return
+		}
+		closeLog()
+		current = cfg.Logging
+		if closeLog, err = logging.Setup(current, tag); err != nil {
+			log.Printf("Failed to apply logging change: %v", err)
+			return
+		}
+		log.Printf("Reloaded logging config")
+	})
+	if err != nil {
+		return closeLog
+	}
+	return func() {
+		stopWatch()
+		mu.Lock()
+		defer mu.Unlock()
+		closeLog()
+	}
 }
 
 func Execute() {
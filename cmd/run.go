@@ -4,12 +4,28 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/broker"
+	"github.com/obra/packnplay/pkg/burner"
 	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/display"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/githubapp"
+	"github.com/obra/packnplay/pkg/imagebuild"
+	"github.com/obra/packnplay/pkg/imagetrust"
+	"github.com/obra/packnplay/pkg/logging"
+	"github.com/obra/packnplay/pkg/netpolicy"
+	"github.com/obra/packnplay/pkg/ptyproxy"
 	"github.com/obra/packnplay/pkg/runner"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/tailnet"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +39,17 @@ var (
 	runConfig       string
 	runReconnect    bool
 	runPublishPorts []string
+	runNoContainer  bool
+	runApprove      bool
+	runRecord       bool
+	runLAN          bool
+	runDisplay      string
+	runTailnet      string
+	runTags         []string
+	runYolo         bool
+	runDetachKeys   string
+	runRepo         string
+	runCloneDepth   int
 	// Credential flags
 	runGitCreds *bool
 	runSSHCreds *bool
@@ -33,109 +60,370 @@ var (
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run [flags] [command...]",
+	Use:   "run [flags] [agent] [-- command...]",
 	Short: "Run command in container",
-	Long:  `Start a container and execute the specified command inside it.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Start a container and execute the specified command inside it.
+
+With no command, pick an AI agent to launch interactively. Arguments after the
+command are passed through unchanged (no shell), so these are equivalent:
+
+  packnplay run claude --resume --model opus
+  packnplay run claude -- claude --resume --model opus
+
+Naming an agent before -- records the session as that agent's while running
+any command, e.g. 'packnplay run claude -- bash'.
+
+Use <agent>@<dir> to run in another project without cd-ing into it; packnplay
+finds the directory's git root and .packnplay.yaml as if it had been run there:
+
+  packnplay run claude@~/src/api
+  packnplay run codex@../web -- codex --full-auto
+
+Use --repo to work on a remote repository without a local checkout; packnplay
+keeps a managed clone of it and checks the branch out as a worktree:
+
+  packnplay run --repo git@github.com:org/repo.git@fix-login claude
+
+--yolo passes the agent's flag for acting without asking (claude
+--dangerously-skip-permissions, codex --full-auto, gemini --yolo, ...), since
+the container is the sandbox. It also drops SSH, GPG, npm and GitHub
+credentials unless their flags are given, turns off host actions, sinkholes
+every builtin blocklist and mounts only the agent's credential files where it
+can run with just those.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Ensure credential watcher is running (auto-managed daemon)
-		if err := ensureCredentialWatcher(); err != nil {
-			return fmt.Errorf("failed to start credential watcher: %w", err)
-		}
+		return startSession(cmd, args, false, false)
+	},
+}
 
-		// If --runtime specified, we can skip config loading for runtime selection
-		// But still need config for credentials
-		var cfg *config.Config
-		var err error
-
-		if runRuntime != "" {
-			// Runtime specified on command line - load config but don't fail if missing runtime
-			cfg, err = config.LoadWithoutRuntimeCheck()
-			if err != nil {
-				// Config doesn't exist - use defaults
-				cfg = &config.Config{
-					ContainerRuntime: runRuntime,
-					DefaultImage:     "ghcr.io/obra/packnplay-default:latest",
-					DefaultCredentials: config.Credentials{
-						Git: true,  // Always copy .gitconfig
-						SSH: false, // SSH keys are credentials - user choice
-						GH:  false, // GitHub auth - user choice
-					},
-				}
-			}
-		} else {
-			// No runtime flag - load config (will prompt if runtime not set)
-			cfg, err = config.Load()
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
-			}
+// startSession runs the command (or picked agent) in args with run's flags
+// Headless sessions get no terminal, so their output can be piped; pipeStdin also
+// forwards stdin to the command.
+func startSession(cmd *cobra.Command, args []string, headless, pipeStdin bool) error {
+	if len(args) == 0 {
+		agentCommand, err := pickAgent()
+		if err != nil {
+			return err
 		}
+		args = []string{agentCommand}
+	}
 
-		// Determine which credentials to use (flags override config)
-		creds := cfg.DefaultCredentials
+	projectPath := runPath
+	if agent, dir, ok := splitAgentTarget(args[0]); ok {
+		if runPath != "" {
+			return fmt.Errorf("use either %s or --path, not both", args[0])
+		}
+		args[0], projectPath = agent, dir
+	}
 
-		// Check if flags were explicitly set
-		if cmd.Flags().Changed("git-creds") {
-			creds.Git = *runGitCreds
+	worktree := runWorktree
+	if runRepo != "" {
+		if projectPath != "" {
+			return fmt.Errorf("use either --repo or a project directory, not both")
 		}
-		if cmd.Flags().Changed("ssh-creds") {
-			creds.SSH = *runSSHCreds
+		url, branch, err := git.ParseRepoSpec(runRepo)
+		if err != nil {
+			return err
 		}
-		if cmd.Flags().Changed("gh-creds") {
-			creds.GH = *runGHCreds
+		fmt.Fprintf(os.Stderr, "Updating managed clone of %s...\n", url)
+		if projectPath, err = git.EnsureClone(url, branch, runCloneDepth, runVerbose); err != nil {
+			return err
 		}
-		if cmd.Flags().Changed("gpg-creds") {
-			creds.GPG = *runGPGCreds
+		// Other branches of the clone are worktrees, like in a local checkout
+		if worktree == "" {
+			worktree = branch
 		}
-		if cmd.Flags().Changed("npm-creds") {
-			creds.NPM = *runNPMCreds
+	}
+
+	agentName, args, err := resolveRunCommand(args)
+	if err != nil {
+		return err
+	}
+
+	// Ensure credential watcher is running (auto-managed daemon)
+	// Without a container, agents read host credentials directly
+	if !runNoContainer {
+		if err := ensureCredentialWatcher(); err != nil {
+			return fmt.Errorf("failed to start credential watcher: %w", err)
+		}
+	}
+
+	// If --runtime specified, we can skip config loading for runtime selection
+	// But still need config for credentials
+	var cfg *config.Config
+
+	if runRuntime != "" || runNoContainer {
+		// Runtime specified on command line (or not needed) - load config but don't fail if missing runtime
+		cfg, err = config.LoadWithoutRuntimeCheck()
+		if err != nil {
+			// Config doesn't exist - use defaults
+			cfg = &config.Config{
+				ContainerRuntime: runRuntime,
+				DefaultImage:     "ghcr.io/obra/packnplay-default:latest",
+				DefaultCredentials: config.Credentials{
+					Git: true,  // Always copy .gitconfig
+					SSH: false, // SSH keys are credentials - user choice
+					GH:  false, // GitHub auth - user choice
+				},
+			}
 		}
-		if runAllCreds {
-			creds.Git = true
-			creds.SSH = true
-			creds.GH = true
-			creds.GPG = true
-			creds.NPM = true
+	} else {
+		// No runtime flag - load config (will prompt if runtime not set)
+		cfg, err = config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+
+	if runYolo {
+		if args, err = yoloCommand(agentName, args); err != nil {
+			return err
 		}
+		tightenForYolo(cfg, agentName)
+	}
 
-		// Determine which runtime to use (flag > config > detect)
-		runtime := runRuntime
-		if runtime == "" {
-			runtime = cfg.ContainerRuntime
+	var pairCommand []string
+	if pairAgent != "" {
+		pairCommand = []string{agents.Lookup(pairAgent).Command()}
+		if runYolo {
+			if pairCommand, err = yoloCommand(pairAgent, pairCommand); err != nil {
+				return err
+			}
+			tightenForYolo(cfg, pairAgent)
 		}
+	}
 
-		// Apply environment configuration if specified
-		var configEnv []string
-		if runConfig != "" {
-			if envConfig, exists := cfg.EnvConfigs[runConfig]; exists {
-				configEnv = applyEnvConfig(envConfig)
-			} else {
-				return fmt.Errorf("environment config '%s' not found in config file", runConfig)
+	// Determine which credentials to use (flags override config)
+	creds := cfg.DefaultCredentials
+
+	// Check if flags were explicitly set
+	if cmd.Flags().Changed("git-creds") {
+		creds.Git = *runGitCreds
+	}
+	if cmd.Flags().Changed("ssh-creds") {
+		creds.SSH = *runSSHCreds
+	}
+	if cmd.Flags().Changed("gh-creds") {
+		creds.GH = *runGHCreds
+	}
+	if cmd.Flags().Changed("gpg-creds") {
+		creds.GPG = *runGPGCreds
+	}
+	if cmd.Flags().Changed("npm-creds") {
+		creds.NPM = *runNPMCreds
+	}
+	if runAllCreds {
+		creds.Git = true
+		creds.SSH = true
+		creds.GH = true
+		creds.GPG = true
+		creds.NPM = true
+	}
+
+	// Determine which runtime to use (flag > config > detect)
+	runtime := runRuntime
+	if runtime == "" {
+		runtime = cfg.ContainerRuntime
+	}
+
+	// Apply environment configuration if specified
+	var configEnv []string
+	if runConfig != "" {
+		if envConfig, exists := cfg.EnvConfigs[runConfig]; exists {
+			configEnv = applyEnvConfig(envConfig)
+			if envConfig.AzureOpenAI != nil {
+				azureEnv, command, err := applyAzureOpenAI(*envConfig.AzureOpenAI, agentName, args)
+				if err != nil {
+					return fmt.Errorf("environment config '%s': %w", runConfig, err)
+				}
+				configEnv, args = append(configEnv, azureEnv...), command
 			}
+		} else {
+			return fmt.Errorf("environment config '%s' not found in config file", runConfig)
+		}
+	}
+
+	// Resolve network policy blocklists into concrete domains
+	blockedDomains, err := netpolicy.ResolveBlockedDomains(cfg.NetworkPolicy.Blocklists)
+	if err != nil {
+		return fmt.Errorf("failed to load network policy: %w", err)
+	}
+	networkWindow, err := netpolicy.ParseWindow(cfg.NetworkPolicy.Window.OpenFor, cfg.NetworkPolicy.Window.Allow)
+	if err != nil {
+		return err
+	}
+
+	var brokerActions []string
+	if cfg.HostBroker.Enabled {
+		// Validate here - the broker itself runs detached where errors go unseen
+		if _, err := broker.NewServer(cfg.HostBroker.Allow, nil); err != nil {
+			return fmt.Errorf("invalid host_broker config: %w", err)
+		}
+		if err := broker.ValidateGitHosts(cfg.HostBroker.GitHosts); err != nil {
+			return fmt.Errorf("invalid host_broker config: %w", err)
 		}
+		if slices.Contains(cfg.HostBroker.Allow, broker.ActionGitPush) && len(cfg.HostBroker.GitHosts) == 0 {
+			return fmt.Errorf("host_broker allows %s but lists no git_hosts to push to", broker.ActionGitPush)
+		}
+		brokerActions = cfg.HostBroker.Allow
+	}
 
-		runConfig := &runner.RunConfig{
-			Path:           runPath,
-			Worktree:       runWorktree,
-			NoWorktree:     runNoWorktree,
-			Env:            append(runEnv, configEnv...), // Merge user env vars with config env vars
-			Verbose:        runVerbose,
-			Runtime:        runtime,
-			Reconnect:      runReconnect,
-			DefaultImage:   cfg.DefaultImage,
-			Command:        args,
-			Credentials:    creds,
-			DefaultEnvVars: cfg.DefaultEnvVars,
-			PublishPorts:   runPublishPorts,
+	for name, mode := range cfg.AgentMounts {
+		if mode != agents.MountModeFull && mode != agents.MountModeMinimal {
+			return fmt.Errorf("invalid agent_mounts mode '%s' for %s (use %s or %s)", mode, name, agents.MountModeFull, agents.MountModeMinimal)
 		}
+	}
 
-		if err := runner.Run(runConfig); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			return err
+	for name := range cfg.AgentTemplates {
+		if agents.Lookup(name) == nil {
+			return fmt.Errorf("unknown agent '%s' in agent_templates", name)
 		}
+	}
 
-		return nil
-	},
+	if err := runner.ValidateMountConsistency(cfg.MountConsistency); err != nil {
+		return err
+	}
+
+	if err := runner.ValidateSELinuxLabels(cfg.SELinuxLabels); err != nil {
+		return err
+	}
+
+	if err := burner.Validate(cfg.BurnerCredentials); err != nil {
+		return err
+	}
+
+	if err := githubapp.Validate(cfg.GitHubApp); err != nil {
+		return err
+	}
+
+	if cfg.SecurityProfile != "" && cfg.SecurityProfile != config.SecurityProfileParanoid {
+		return fmt.Errorf("invalid security_profile '%s' (use %s, or leave it unset)", cfg.SecurityProfile, config.SecurityProfileParanoid)
+	}
+
+	if err := container.ValidateExtraLabels(cfg.Labels); err != nil {
+		return fmt.Errorf("invalid labels config: %w", err)
+	}
+
+	if err := imagebuild.ValidateShell(cfg.Shell); err != nil {
+		return err
+	}
+
+	if err := imagetrust.Validate(cfg.ImageTrust); err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("display") {
+		cfg.Display.Mode = runDisplay
+	}
+	if err := display.Validate(cfg.Display.Mode); err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("tailnet") {
+		cfg.Tailnet.Mode = runTailnet
+	}
+	if err := tailnet.Validate(cfg.Tailnet); err != nil {
+		return err
+	}
+	if cfg.Tailnet.Mode != "" && runNoContainer {
+		return fmt.Errorf("--no-container sessions can't join a tailnet; use a container runtime")
+	}
+
+	ulimits, sysctls := cfg.Limits(runConfig)
+
+	if cmd.Flags().Changed("detach-keys") {
+		cfg.DetachKeys = runDetachKeys
+	}
+	if _, err := ptyproxy.ParseKeys(cfg.DetachKeys); cfg.DetachKeys != "" && err != nil {
+		return err
+	}
+	if err := runner.ValidateStatusBar(cfg.StatusBar, cfg.DetachKeys); err != nil {
+		return err
+	}
+
+	if err := session.ValidateTags(runTags); err != nil {
+		return err
+	}
+
+	// Daemons started below log detached, so a bad logging config must fail here
+	if err := logging.Validate(cfg.Logging); err != nil {
+		return err
+	}
+
+	runConfig := &runner.RunConfig{
+		Path:             projectPath,
+		Worktree:         worktree,
+		NoWorktree:       runNoWorktree,
+		Env:              append(runEnv, configEnv...), // Merge user env vars with config env vars
+		Verbose:          runVerbose,
+		Runtime:          runtime,
+		Reconnect:        runReconnect,
+		DefaultImage:     cfg.DefaultImage,
+		LanguageImages:   cfg.LanguageImages,
+		Command:          args,
+		Agent:            agentName,
+		PairAgent:        pairAgent,
+		PairCommand:      pairCommand,
+		Credentials:      creds,
+		DefaultEnvVars:   cfg.DefaultEnvVars,
+		PublishPorts:     runPublishPorts,
+		LAN:              runLAN || cfg.PublishLAN,
+		BlockedDomains:   blockedDomains,
+		Hostname:         cfg.Hostname,
+		ExtraHosts:       cfg.ExtraHosts,
+		NetworkWindow:    networkWindow,
+		BrokerActions:    brokerActions,
+		BrokerGitHosts:   cfg.HostBroker.GitHosts,
+		Home:             cfg.Home,
+		AgentMounts:      cfg.AgentMounts,
+		AgentTemplates:   cfg.AgentTemplates,
+		MountConsistency: cfg.MountConsistency,
+		SELinuxLabels:    cfg.SELinuxLabels,
+		Policies:         cfg.Policies,
+		Caches:           cfg.Caches,
+		ApproveMounts:    approveMounts,
+		Logging:          cfg.Logging,
+		Burner:           cfg.BurnerCredentials,
+		GitHubApp:        cfg.GitHubApp,
+		Record:           (runRecord || cfg.RecordSessions) && !headless, // recording needs a terminal
+		HandleOrphan:     handleOrphan,
+		StopSession:      stopContainer,
+		MountHooks:       cfg.MountHooks,
+		Shell:            cfg.Shell,
+		Profile:          runConfig,
+		Version:          version,
+		Labels:           cfg.Labels,
+		DevCerts:         cfg.DevCerts,
+		WorkspaceMode:    cfg.WorkspaceMode,
+		ImageTrust:       cfg.ImageTrust,
+		DisableTelemetry: cfg.DisableTelemetry,
+		Display:          cfg.Display,
+		Tailnet:          cfg.Tailnet,
+		GitIdentity:      cfg.GitIdentity,
+		Ulimits:          ulimits,
+		Sysctls:          sysctls,
+		DetachKeys:       cfg.DetachKeys,
+		StatusBar:        cfg.StatusBar,
+		Integrity:        cfg.IntegrityEnabled(),
+		IntegrityWatch:   cfg.Integrity.Watch,
+		Bazel:            cfg.Bazel,
+		Tags:             runTags,
+		Summaries:        cfg.Summaries,
+		ReadOnlyRoot:     cfg.ReadOnlyRootEnabled(),
+		WritablePaths:    cfg.WritablePaths,
+		Headless:         headless,
+		PipeStdin:        pipeStdin,
+	}
+
+	run := runner.Run
+	if runNoContainer {
+		run = runner.RunLocal
+	}
+	if err := run(runConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+
+	return nil
 }
 
 func init() {
@@ -147,11 +435,22 @@ func init() {
 
 	runCmd.Flags().StringVar(&runPath, "path", "", "Project path (default: pwd)")
 	runCmd.Flags().StringVar(&runWorktree, "worktree", "", "Worktree name (creates if needed)")
+	runCmd.Flags().StringVar(&runRepo, "repo", "", "Run in a managed clone of a remote repository: <url>[@branch]")
+	runCmd.Flags().IntVar(&runCloneDepth, "clone-depth", 0, "With --repo, clone only this many commits of history (0 for all)")
 	runCmd.Flags().BoolVar(&runNoWorktree, "no-worktree", false, "Skip worktree, use directory directly")
 	runCmd.Flags().StringSliceVar(&runEnv, "env", []string{}, "Additional env vars (KEY=value)")
 	runCmd.Flags().StringArrayVarP(&runPublishPorts, "publish", "p", []string{}, "Publish container port(s) to host (format: [hostIP:]hostPort:containerPort[/protocol])")
 	runCmd.Flags().StringVar(&runRuntime, "runtime", "", "Container runtime to use (docker/podman/container)")
 	runCmd.Flags().StringVar(&runConfig, "config", "", "API config profile (anthropic, z.ai, anthropic-work, claude-personal)")
+	runCmd.Flags().BoolVar(&runNoContainer, "no-container", false, "Sandbox with OS facilities (bubblewrap/sandbox-exec) instead of a container runtime")
+	runCmd.Flags().BoolVar(&runApprove, "approve-mounts", false, "Approve this project's mounts without prompting")
+	runCmd.Flags().BoolVar(&runRecord, "record", false, "Record the session's terminal for 'packnplay replay'")
+	runCmd.Flags().BoolVar(&runLAN, "lan", false, "Publish the session on the LAN as <project>-<agent>.local, for testing from other devices")
+	runCmd.Flags().StringVar(&runDisplay, "display", "", "Give the session a display: host (forward X11/Wayland) or vnc (noVNC in a browser)")
+	runCmd.Flags().StringVar(&runTailnet, "tailnet", "", "Attach the session to a private network through a sidecar: tailscale or wireguard")
+	runCmd.Flags().StringArrayVar(&runTags, "tag", []string{}, "Tag the session, for 'packnplay sessions --tag' (repeatable)")
+	runCmd.Flags().BoolVar(&runYolo, "yolo", false, "Let the agent act without asking, with credentials, host actions and network locked down")
+	runCmd.Flags().StringVar(&runDetachKeys, "detach-keys", "", "Keys that detach from the session, leaving it running (e.g. "+ptyproxy.DefaultDetachKeys+")")
 	runCmd.Flags().BoolVar(&runReconnect, "reconnect", false, "Reconnect to existing container instead of failing")
 	runCmd.Flags().BoolVar(&runVerbose, "verbose", false, "Show all docker/git commands")
 
@@ -162,6 +461,105 @@ func init() {
 	runGPGCreds = runCmd.Flags().Bool("gpg-creds", false, "Mount GPG credentials for commit signing")
 	runNPMCreds = runCmd.Flags().Bool("npm-creds", false, "Mount npm credentials")
 	runCmd.Flags().BoolVar(&runAllCreds, "all-creds", false, "Mount all available credentials")
+
+	// exec and pair start sessions the same way, so they share run's flags and their values
+	execCmd.Flags().AddFlagSet(runCmd.Flags())
+	pairCmd.Flags().AddFlagSet(runCmd.Flags())
+}
+
+// resolveRunCommand splits `[agent] -- command...` and maps agent names to their CLI
+// Returns the agent the session is for ("" if the command isn't an agent) and the argv to run.
+func resolveRunCommand(args []string) (string, []string, error) {
+	separator := -1
+	for i, arg := range args {
+		if arg == "--" {
+			separator = i
+			break
+		}
+	}
+
+	if separator == -1 {
+		// `run cursor` launches cursor-agent; anything else runs as given
+		agent := agents.Lookup(args[0])
+		if agent == nil {
+			return "", args, nil
+		}
+		command := append([]string{agent.Command()}, args[1:]...)
+		return agent.Name(), command, nil
+	}
+
+	if separator != 1 {
+		return "", nil, fmt.Errorf("expected a single agent before --, got '%s'", strings.Join(args[:separator], " "))
+	}
+	agent := agents.Lookup(args[0])
+	if agent == nil {
+		return "", nil, fmt.Errorf("unknown agent '%s' before --", args[0])
+	}
+
+	command := args[separator+1:]
+	if len(command) == 0 {
+		command = []string{agent.Command()}
+	}
+	return agent.Name(), command, nil
+}
+
+// splitAgentTarget splits "<agent>@<dir>" into the agent and the directory, expanding ~/
+// Anything whose part before @ isn't a known agent (e.g. user@host) isn't a target.
+func splitAgentTarget(arg string) (string, string, bool) {
+	agent, dir, ok := strings.Cut(arg, "@")
+	if !ok || dir == "" || agents.Lookup(agent) == nil {
+		return "", "", false
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	return agent, dir, true
+}
+
+// yoloCommand adds the agent's flag for skipping permission prompts to its command
+// The command has to be the agent itself; packnplay can't know how to unlock anything else.
+func yoloCommand(agentName string, command []string) ([]string, error) {
+	agent := agents.Lookup(agentName)
+	if agent == nil || command[0] != agent.Command() {
+		return nil, fmt.Errorf("--yolo needs an agent to run, e.g. packnplay run --yolo claude")
+	}
+	skipper, ok := agent.(agents.PermissionSkipper)
+	if !ok {
+		return nil, fmt.Errorf("%s has no mode for skipping permission prompts packnplay knows about", agent.Name())
+	}
+	for _, arg := range skipper.SkipPermissionsArgs() {
+		if !slices.Contains(command, arg) {
+			command = append(command, arg)
+		}
+	}
+	return command, nil
+}
+
+// tightenForYolo narrows what an agent that no longer asks first can reach: no SSH, GPG, npm
+// or GitHub credentials unless their flags ask for them, no host actions, every builtin
+// blocklist, and only the agent's credential files when it can run with just those
+func tightenForYolo(cfg *config.Config, agentName string) {
+	cfg.DefaultCredentials.SSH = false
+	cfg.DefaultCredentials.GH = false
+	cfg.DefaultCredentials.GPG = false
+	cfg.DefaultCredentials.NPM = false
+
+	cfg.HostBroker.Enabled = false
+
+	for _, name := range netpolicy.BuiltinBlocklists() {
+		if !slices.Contains(cfg.NetworkPolicy.Blocklists, name) {
+			cfg.NetworkPolicy.Blocklists = append(cfg.NetworkPolicy.Blocklists, name)
+		}
+	}
+
+	if _, ok := agents.Lookup(agentName).(agents.MinimalMounter); ok && cfg.AgentMounts[agentName] == "" {
+		if cfg.AgentMounts == nil {
+			cfg.AgentMounts = make(map[string]string)
+		}
+		cfg.AgentMounts[agentName] = agents.MountModeMinimal
+	}
 }
 
 // ensureCredentialWatcher starts the credential sync daemon if not already running
@@ -211,6 +609,43 @@ func applyEnvConfig(envConfig config.EnvConfig) []string {
 	return envVars
 }
 
+// applyAzureOpenAI resolves a profile's Azure OpenAI settings into env vars for the session
+// and, for agents configured through their CLI, arguments added to the agent's command
+func applyAzureOpenAI(settings config.AzureConfig, agentName string, command []string) ([]string, []string, error) {
+	azure := agents.AzureOpenAI{
+		Endpoint:   strings.TrimSuffix(expandEnvVars(settings.Endpoint), "/"),
+		Deployment: expandEnvVars(settings.Deployment),
+		APIVersion: expandEnvVars(settings.APIVersion),
+		APIKey:     expandEnvVars(settings.APIKey),
+	}
+	if settings.APIKey == "" {
+		azure.APIKey = os.Getenv("AZURE_OPENAI_API_KEY")
+	}
+	if azure.APIVersion == "" {
+		azure.APIVersion = agents.DefaultAzureAPIVersion
+	}
+	if err := agents.ValidateAzure(azure); err != nil {
+		return nil, nil, err
+	}
+
+	// Sessions that aren't an agent's, e.g. a shell, still get the SDKs' variables
+	env := agents.AzureEnv(azure)
+	if agentName == "" {
+		return env, command, nil
+	}
+	agent := agents.Lookup(agentName)
+	configurable, ok := agent.(agents.AzureConfigurable)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s can't use Azure OpenAI (codex, crush and qwen can)", agentName)
+	}
+	agentEnv, args := configurable.AzureOpenAI(azure)
+	env = append(env, agentEnv...)
+	if len(args) > 0 && command[0] == agent.Command() {
+		command = append(command, args...)
+	}
+	return env, command, nil
+}
+
 // expandEnvVars substitutes ${VAR_NAME} with environment variable values
 func expandEnvVars(value string) string {
 	// Simple variable substitution for ${VAR_NAME} pattern
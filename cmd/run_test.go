@@ -134,4 +134,162 @@ func TestApplyEnvConfig(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestResolveRunCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantAgent   string
+		wantCommand []string
+		wantErr     bool
+	}{
+		{"agent", []string{"claude"}, "claude", []string{"claude"}, false},
+		{"agent name maps to CLI", []string{"cursor", "--help"}, "cursor", []string{"cursor-agent", "--help"}, false},
+		{"plain command", []string{"bash", "-lc", "make test"}, "", []string{"bash", "-lc", "make test"}, false},
+		{"passthrough", []string{"claude", "--", "claude", "--resume", "--model", "opus"}, "claude", []string{"claude", "--resume", "--model", "opus"}, false},
+		{"agent with other command", []string{"codex", "--", "bash"}, "codex", []string{"bash"}, false},
+		{"empty passthrough uses agent", []string{"gemini", "--"}, "gemini", []string{"gemini"}, false},
+		{"args after -- keep quoting", []string{"claude", "--", "claude", "-p", "fix the 'auth' bug"}, "claude", []string{"claude", "-p", "fix the 'auth' bug"}, false},
+		{"unknown agent", []string{"vim", "--", "vim"}, "", nil, true},
+		{"two words before --", []string{"claude", "extra", "--", "claude"}, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, command, err := resolveRunCommand(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveRunCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if agent != tt.wantAgent || strings.Join(command, "\x00") != strings.Join(tt.wantCommand, "\x00") {
+				t.Errorf("resolveRunCommand() = %q, %q, want %q, %q", agent, command, tt.wantAgent, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestSplitAgentTarget(t *testing.T) {
+	home, _ := os.UserHomeDir()
+
+	tests := []struct {
+		arg       string
+		wantAgent string
+		wantDir   string
+		wantOK    bool
+	}{
+		{arg: "claude@../api", wantAgent: "claude", wantDir: "../api", wantOK: true},
+		{arg: "codex@/src/web", wantAgent: "codex", wantDir: "/src/web", wantOK: true},
+		{arg: "claude@~/src/api", wantAgent: "claude", wantDir: home + "/src/api", wantOK: true},
+		{arg: "claude@", wantOK: false},
+		{arg: "claude", wantOK: false},
+		{arg: "git@github.com:org/repo", wantOK: false},
+	}
+	for _, tt := range tests {
+		agent, dir, ok := splitAgentTarget(tt.arg)
+		if ok != tt.wantOK || agent != tt.wantAgent || dir != tt.wantDir {
+			t.Errorf("splitAgentTarget(%q) = %q, %q, %v; want %q, %q, %v", tt.arg, agent, dir, ok, tt.wantAgent, tt.wantDir, tt.wantOK)
+		}
+	}
+}
+
+func TestYoloCommand(t *testing.T) {
+	got, err := yoloCommand("claude", []string{"claude", "--resume"})
+	if err != nil || strings.Join(got, " ") != "claude --resume --dangerously-skip-permissions" {
+		t.Errorf("yoloCommand(claude) = %v, %v", got, err)
+	}
+	// Already asked for: not passed twice
+	got, err = yoloCommand("gemini", []string{"gemini", "--yolo"})
+	if err != nil || strings.Join(got, " ") != "gemini --yolo" {
+		t.Errorf("yoloCommand(gemini --yolo) = %v, %v", got, err)
+	}
+
+	if _, err := yoloCommand("claude", []string{"bash"}); err == nil {
+		t.Error("yoloCommand() should refuse a command that isn't the agent")
+	}
+	if _, err := yoloCommand("", []string{"make"}); err == nil {
+		t.Error("yoloCommand() should refuse a session without an agent")
+	}
+	if _, err := yoloCommand("deepseek", []string{"deepseek"}); err == nil {
+		t.Error("yoloCommand() should refuse an agent without a skip-permissions flag")
+	}
+}
+
+func TestTightenForYolo(t *testing.T) {
+	cfg := &config.Config{
+		DefaultCredentials: config.Credentials{Git: true, SSH: true, GH: true, GPG: true, NPM: true},
+		HostBroker:         config.HostBroker{Enabled: true, Allow: []string{"open-url"}},
+		NetworkPolicy:      config.NetworkPolicy{Blocklists: []string{"typosquat"}},
+		AgentMounts:        map[string]string{"gemini": "full"},
+	}
+	tightenForYolo(cfg, "codex")
+
+	creds := cfg.DefaultCredentials
+	if !creds.Git || creds.SSH || creds.GH || creds.GPG || creds.NPM {
+		t.Errorf("credentials after tightening = %+v, want only git", creds)
+	}
+	if cfg.HostBroker.Enabled {
+		t.Error("host broker still enabled")
+	}
+	if strings.Join(cfg.NetworkPolicy.Blocklists, ",") != "typosquat,exfiltration" {
+		t.Errorf("blocklists = %v", cfg.NetworkPolicy.Blocklists)
+	}
+	if cfg.AgentMounts["codex"] != "minimal" || cfg.AgentMounts["gemini"] != "full" {
+		t.Errorf("agent mounts = %v", cfg.AgentMounts)
+	}
+
+	// An agent's mount mode from config is kept
+	cfg = &config.Config{AgentMounts: map[string]string{"codex": "full"}}
+	tightenForYolo(cfg, "codex")
+	if cfg.AgentMounts["codex"] != "full" {
+		t.Errorf("configured mount mode overridden: %v", cfg.AgentMounts)
+	}
+}
+
+func TestApplyAzureOpenAI(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+	t.Setenv("AZURE_RESOURCE", "contoso")
+	settings := config.AzureConfig{
+		Endpoint:   "https://${AZURE_RESOURCE}.openai.azure.com/",
+		Deployment: "gpt-5-codex",
+	}
+
+	env, command, err := applyAzureOpenAI(settings, "codex", []string{"codex", "exec", "fix it"})
+	if err != nil {
+		t.Fatalf("applyAzureOpenAI(codex) error: %v", err)
+	}
+	joined := strings.Join(env, "\n")
+	for _, want := range []string{"AZURE_OPENAI_ENDPOINT=https://contoso.openai.azure.com", "AZURE_OPENAI_API_KEY=azure-key", "OPENAI_API_VERSION=2025-04-01-preview"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("env missing %s: %v", want, env)
+		}
+	}
+	args := strings.Join(command, " ")
+	if !strings.HasPrefix(args, "codex exec fix it -c model_provider=azure -c ") ||
+		!strings.Contains(args, `base_url="https://contoso.openai.azure.com/openai"`) || !strings.HasSuffix(args, "-m gpt-5-codex") {
+		t.Errorf("codex command = %s", args)
+	}
+
+	// Qwen is configured through its OpenAI-compatible variables alone
+	env, command, err = applyAzureOpenAI(settings, "qwen", []string{"qwen"})
+	if err != nil || len(command) != 1 || !strings.Contains(strings.Join(env, "\n"), "OPENAI_BASE_URL=https://contoso.openai.azure.com/openai/v1") {
+		t.Errorf("applyAzureOpenAI(qwen) = %v, %v, %v", env, command, err)
+	}
+
+	// A shell gets the variables; its command is left alone
+	if _, command, err := applyAzureOpenAI(settings, "", []string{"bash"}); err != nil || strings.Join(command, " ") != "bash" {
+		t.Errorf("applyAzureOpenAI(bash) = %v, %v", command, err)
+	}
+
+	if _, _, err := applyAzureOpenAI(settings, "claude", []string{"claude"}); err == nil {
+		t.Error("applyAzureOpenAI() should refuse an agent that can't use Azure OpenAI")
+	}
+	if _, _, err := applyAzureOpenAI(config.AzureConfig{Endpoint: "https://x.openai.azure.com"}, "codex", []string{"codex"}); err == nil {
+		t.Error("applyAzureOpenAI() should require a deployment")
+	}
+	t.Setenv("AZURE_OPENAI_API_KEY", "")
+	if _, _, err := applyAzureOpenAI(settings, "codex", []string{"codex"}); err == nil {
+		t.Error("applyAzureOpenAI() should require a key")
+	}
+}
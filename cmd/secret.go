@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/secrets"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Keep keys packnplay uses in the OS keychain",
+	Long: `Store keys packnplay itself needs, such as burner_credentials admin keys and the
+Tailscale auth key, in the OS keychain (macOS Keychain, or GNOME Keyring/KWallet
+through secret-tool) instead of exporting them from a shell profile:
+
+  packnplay secret set OPENAI_ADMIN_KEY
+  packnplay secret delete OPENAI_ADMIN_KEY
+
+A variable set in the environment still wins over the stored secret. Tokens
+packnplay mints for sessions are kept there too. Hosts without a keychain fall
+back to 0600 files under ~/.local/share/packnplay/secrets.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Printf("Secrets are stored in: %s\n", secrets.Store())
+		return nil
+	},
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret, read from stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := secrets.ValidateName(name); err != nil {
+			return err
+		}
+
+		var value []byte
+		var err error
+		if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+			// Don't echo the secret or leave it in shell history
+			fmt.Fprintf(os.Stderr, "%s: ", name)
+			value, err = term.ReadPassword(fd)
+			fmt.Fprintln(os.Stderr)
+		} else {
+			value, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read secret: %w", err)
+		}
+		secret := strings.TrimRight(string(value), "\r\n")
+		if secret == "" {
+			return fmt.Errorf("no value given for %s", name)
+		}
+
+		if err := secrets.Set(name, secret); err != nil {
+			return err
+		}
+		fmt.Printf("Stored %s (%s)\n", name, secrets.Store())
+		return nil
+	},
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Remove a stored secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := secrets.Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretDeleteCmd)
+}
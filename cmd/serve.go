@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/events"
+	"github.com/obra/packnplay/pkg/metrics"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/systemd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen  string
+	serveRuntime string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve Prometheus metrics and live session events",
+	Long: `Run a long-lived HTTP server exposing /metrics in Prometheus text format.
+
+Running and exited sessions are read from the container runtime on each scrape;
+session starts, failures and image pull times come from the event log that
+'packnplay run' appends to.
+
+/sessions/<container>/events streams a session's events as server-sent events,
+for dashboards and editor plugins: file changes in the workspace, commands
+started with exec, container starts and stops, and session state changes.
+
+  curl -N http://127.0.0.1:9464/sessions/packnplay-app-main/events
+
+Started by systemd socket activation ('packnplay daemon install'), it serves on
+the socket systemd passes in and --listen is ignored.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-serve")()
+
+		dockerClient, err := docker.NewClientWithRuntime(serveRuntime, false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize container runtime: %w", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			var body bytes.Buffer
+			if err := writeMetrics(&body, dockerClient); err != nil {
+				log.Printf("metrics: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			_, _ = w.Write(body.Bytes())
+		})
+		mux.HandleFunc("GET /sessions/{name}/events", func(w http.ResponseWriter, r *http.Request) {
+			if err := streamSessionEvents(w, r, dockerClient, r.PathValue("name")); err != nil {
+				log.Printf("events: %v", err)
+			}
+		})
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		// Under 'packnplay daemon install', systemd owns the socket and starts us on demand
+		listeners, err := systemd.Listeners()
+		if err != nil {
+			return err
+		}
+		if len(listeners) == 0 {
+			listener, err := net.Listen("tcp", serveListen)
+			if err != nil {
+				return fmt.Errorf("failed to listen on %s: %w", serveListen, err)
+			}
+			listeners = append(listeners, listener)
+		}
+
+		errs := make(chan error, len(listeners))
+		for _, listener := range listeners {
+			log.Printf("Serving metrics on http://%s/metrics", listener.Addr())
+			go func(listener net.Listener) { errs <- http.Serve(listener, mux) }(listener)
+		}
+		if err := <-errs; err != nil {
+			return fmt.Errorf("metrics server failed: %w", err)
+		}
+		return nil
+	},
+}
+
+// writeMetrics renders the current metrics snapshot
+func writeMetrics(body *bytes.Buffer, dockerClient *docker.Client) error {
+	output, err := dockerClient.Run(
+		"ps", "-a",
+		"--filter", "label=managed-by=packnplay",
+		"--format", `{{.Names}}|{{.State}}|{{.Label "packnplay-agent"}}|{{.CreatedAt}}|{{.Status}}`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	events, err := metrics.ReadEvents(metrics.GetEventsPath())
+	if err != nil {
+		return err
+	}
+
+	metrics.WritePrometheus(body, metrics.ParseSessions(output), events, time.Now())
+	return nil
+}
+
+// sessionStatePoll is how often the session store is checked for state changes
+const sessionStatePoll = 2 * time.Second
+
+// streamSessionEvents sends a session's events to the client until it disconnects
+func streamSessionEvents(w http.ResponseWriter, r *http.Request, dockerClient *docker.Client, name string) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return fmt.Errorf("response writer can't flush")
+	}
+	_, mounts, err := containerMounts(dockerClient, name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("no session %s", name), http.StatusNotFound)
+		return err
+	}
+
+	ctx := r.Context()
+	stream := make(chan events.Event, 64)
+	emit := func(event events.Event) {
+		select {
+		case stream <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	// File changes, seen on the host side of the workspace mount
+	for _, m := range mounts {
+		if m.Destination == "/workspace" && m.Type == "bind" {
+			go func(source string) {
+				if err := events.WatchWorkspace(ctx, name, source, "/workspace", emit); err != nil {
+					log.Printf("events: %s: %v", name, err)
+				}
+			}(m.Source)
+		}
+	}
+
+	// Commands and container lifecycle, from the runtime (Apple Container has no event stream)
+	if dockerClient.Command() != "container" {
+		runtimeEvents := dockerClient.StreamContext(ctx, "events", "--format", "{{json .}}", "--filter", "container="+name)
+		output, err := runtimeEvents.StdoutPipe()
+		if err != nil {
+			return fmt.Errorf("failed to read runtime events: %w", err)
+		}
+		if err := runtimeEvents.Start(); err != nil {
+			return fmt.Errorf("failed to read runtime events: %w", err)
+		}
+		go func() {
+			scanner := bufio.NewScanner(output)
+			for scanner.Scan() {
+				if event, ok := events.ParseRuntimeEvent(scanner.Text()); ok {
+					event.Session = name
+					emit(event)
+				}
+			}
+			_ = runtimeEvents.Wait()
+		}()
+	}
+
+	// Lifecycle state transitions, from the session store
+	go func() {
+		last := ""
+		if entries, err := session.LoadStore(); err == nil {
+			if entry, ok := entries[name]; ok {
+				last = string(entry.CurrentState())
+			}
+		}
+		ticker := time.NewTicker(sessionStatePoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			entries, err := session.LoadStore()
+			if err != nil {
+				continue
+			}
+			state := ""
+			if entry, ok := entries[name]; ok {
+				state = string(entry.CurrentState())
+			}
+			if state != last && state != "" {
+				emit(events.Event{Type: events.TypeState, Time: time.Now().UTC(), Session: name, State: state})
+			}
+			last = state
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Comments keep proxies from closing an idle stream
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+		case event := <-stream:
+			if err := events.WriteSSE(w, event); err != nil {
+				return nil
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:9464", "Address to serve metrics and events on")
+	serveCmd.Flags().StringVar(&serveRuntime, "runtime", "", "Container runtime to query (docker/podman/container)")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/summary"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsTags    []string
+	sessionsSince   string
+	sessionsStatus  string
+	sessionsProject string
+	sessionsAgent   string
+)
+
+// sessionStatuses are the values --status accepts
+var sessionStatuses = []string{
+	session.StatusFailed,
+	string(session.StateCreated), string(session.StateProvisioning), string(session.StateRunning),
+	string(session.StateVerifying), string(session.StateReviewing), string(session.StateApplied),
+	string(session.StateDiscarded),
+}
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Search current and past sessions",
+	Long: `Search this machine's sessions, running or torn down, by tag, age, status,
+project and agent. Tag sessions when starting them with 'packnplay run --tag'.
+
+  packnplay sessions --tag billing-service --since 30d --status failed
+
+Running sessions with summaries turned on show what they're doing now.
+
+--since takes a duration in hours, days or weeks (12h, 30d, 2w) or a date
+(2026-01-31). --status is failed (didn't start, or failed verification) or a
+lifecycle state: running, reviewing, applied, discarded, ...`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := session.Query{Tags: sessionsTags, Project: sessionsProject, Agent: sessionsAgent, Status: sessionsStatus}
+		if sessionsStatus != "" && !slices.Contains(sessionStatuses, sessionsStatus) {
+			return fmt.Errorf("unknown status '%s' (use %s)", sessionsStatus, strings.Join(sessionStatuses, ", "))
+		}
+		if sessionsSince != "" {
+			since, err := parseSince(sessionsSince, time.Now())
+			if err != nil {
+				return err
+			}
+			query.Since = since
+		}
+
+		found, err := session.Search(query)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			fmt.Println("No matching sessions")
+			return nil
+		}
+
+		showFailures := slices.ContainsFunc(found, func(e session.Entry) bool { return e.Failure != "" })
+		showReviews := slices.ContainsFunc(found, func(e session.Entry) bool { return len(e.Reviews) > 0 })
+		// What running sessions are doing now, from their transcript summaries
+		now := make(map[string]string)
+		for _, entry := range found {
+			if entry.EndedAt.IsZero() {
+				// A summary from before this session started is of an earlier one with the same name
+				if latest, ok := summary.Latest(entry.Container); ok && latest.Time.After(entry.StartedAt) {
+					now[entry.Container] = latest.Text
+				}
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		header := "CONTAINER\tSTATUS\tPROJECT\tWORKTREE\tAGENT\tTAGS\tSTARTED\tENDED"
+		if showFailures {
+			header += "\tFAILURE"
+		}
+		if showReviews {
+			header += "\tREVIEWS"
+		}
+		if len(now) > 0 {
+			header += "\tNOW"
+		}
+		fmt.Fprintln(w, header)
+		for _, entry := range found {
+			row := []string{entry.Container, entry.Status(), entry.Project, entry.Worktree,
+				orDash(entry.Agent), orDash(strings.Join(entry.Tags, ",")),
+				entry.StartedAt.Local().Format("2006-01-02 15:04"), "-"}
+			if !entry.EndedAt.IsZero() {
+				row[7] = entry.EndedAt.Local().Format("2006-01-02 15:04")
+			}
+			if showFailures {
+				// First line only; the rest is usually runtime output
+				failure, _, _ := strings.Cut(entry.Failure, "\n")
+				row = append(row, orDash(failure))
+			}
+			if showReviews {
+				var verdicts []string
+				for _, verdict := range entry.Reviews {
+					verdicts = append(verdicts, verdict.Reviewer+":"+verdict.Decision)
+				}
+				row = append(row, orDash(strings.Join(verdicts, ",")))
+			}
+			if len(now) > 0 {
+				row = append(row, orDash(now[entry.Container]))
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	},
+}
+
+// parseSince turns "30d", "2w", "12h" or "2026-01-31" into the earliest start time to show
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 0 {
+				break
+			}
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return time.Time{}, fmt.Errorf("invalid --since '%s' (use e.g. 12h, 30d, 2w or 2026-01-31)", value)
+	}
+	return now.Add(-duration), nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+
+	sessionsCmd.Flags().StringArrayVar(&sessionsTags, "tag", []string{}, "Only sessions with this tag (repeatable; all must match)")
+	sessionsCmd.Flags().StringVar(&sessionsSince, "since", "", "Only sessions started within this long, e.g. 30d, or since a date")
+	sessionsCmd.Flags().StringVar(&sessionsStatus, "status", "", "Only sessions with this status: failed, or a lifecycle state")
+	sessionsCmd.Flags().StringVar(&sessionsProject, "project", "", "Only this project's sessions")
+	sessionsCmd.Flags().StringVar(&sessionsAgent, "agent", "", "Only sessions of this agent")
+}
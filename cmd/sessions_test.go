@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"30d": now.Add(-30 * 24 * time.Hour),
+		"2w":  now.Add(-14 * 24 * time.Hour),
+		"12h": now.Add(-12 * time.Hour),
+		"0d":  now,
+	} {
+		got, err := parseSince(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseSince(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+
+	date, err := parseSince("2026-01-31", now)
+	if err != nil || date.Year() != 2026 || date.Month() != time.January || date.Day() != 31 {
+		t.Errorf("parseSince(date) = %v, %v", date, err)
+	}
+
+	for _, value := range []string{"", "soon", "-3d", "xd", "-1h"} {
+		if _, err := parseSince(value, now); err == nil {
+			t.Errorf("parseSince(%q) should fail", value)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotContainer string
+	snapshotRuntime   string
+	snapshotInterval  time.Duration
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:    "session-snapshot",
+	Short:  "Commit a split-mode session's workspace to its branch",
+	Long:   `Background daemon that commits the agent's workspace to the session branch whenever it stops changing, until the container stops.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-snapshot")()
+
+		dockerClient, err := docker.NewClientWithRuntime(snapshotRuntime, false)
+		if err != nil {
+			return err
+		}
+
+		var previous string
+		for isContainerRunning(snapshotRuntime, snapshotContainer) {
+			time.Sleep(snapshotInterval)
+
+			tree, err := sessionbranch.Tree(dockerClient, snapshotContainer)
+			if err != nil {
+				log.Printf("Failed to snapshot %s: %v", snapshotContainer, err)
+				continue
+			}
+			// Commit between bursts of writes, not in the middle of a tool call
+			if tree == previous {
+				if committed, err := sessionbranch.Commit(snapshotContainer, tree, "packnplay: snapshot "+time.Now().Format(time.RFC3339)); err != nil {
+					log.Printf("Failed to commit snapshot of %s: %v", snapshotContainer, err)
+				} else if committed {
+					log.Printf("Committed snapshot of %s", snapshotContainer)
+				}
+			}
+			previous = tree
+		}
+
+		log.Printf("Container %s stopped, exiting session snapshots", snapshotContainer)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.Flags().StringVar(&snapshotContainer, "container", "", "Container whose workspace to commit")
+	snapshotCmd.Flags().StringVar(&snapshotRuntime, "runtime", "docker", "Container runtime used to reach the container")
+	snapshotCmd.Flags().DurationVar(&snapshotInterval, "interval", 5*time.Second, "How long the workspace must stay unchanged before a snapshot")
+}
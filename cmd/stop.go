@@ -7,8 +7,13 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/obra/packnplay/pkg/config"
 	"github.com/obra/packnplay/pkg/container"
 	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/runner"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+	"github.com/obra/packnplay/pkg/writeback"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +21,8 @@ var (
 	stopPath     string
 	stopWorktree string
 	stopAll      bool
+
+	stopSkipDepScan bool
 )
 
 var stopCmd = &cobra.Command{
@@ -64,18 +71,101 @@ var stopCmd = &cobra.Command{
 }
 
 func stopContainer(dockerClient *docker.Client, containerName string) error {
+	// Upload before anything is removed; a failed upload keeps the container so it can be retried
+	if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil && cfg.Artifacts.Enabled() {
+		if err := uploadArtifacts(dockerClient, containerName, cfg.Artifacts); err != nil {
+			return fmt.Errorf("%w\nContainer %s was kept; retry with 'packnplay artifacts %s', then stop it again", err, containerName, containerName)
+		}
+	}
+
+	// The sandbox's copy of the workspace goes with the container, so write its edits back first
+	if writeback.Active(containerName) {
+		if err := writeBackEdits(dockerClient, containerName); err != nil {
+			return fmt.Errorf("%w\nContainer %s was kept so its edits aren't lost", err, containerName)
+		}
+	}
+	var branch string
+	if sessionbranch.Active(containerName) {
+		committed, err := commitSessionBranch(dockerClient, containerName)
+		if err != nil {
+			return fmt.Errorf("%w\nContainer %s was kept so the agent's work isn't lost", err, containerName)
+		}
+		branch = committed
+	}
+
+	// From here on the session is only discarded; if packnplay dies midway, the next run finishes the job
+	if err := session.BeginRemoval(containerName); err != nil {
+		return err
+	}
 	fmt.Printf("Stopping container %s...\n", containerName)
-	_, err := dockerClient.Run("stop", containerName)
-	if err != nil {
-		return fmt.Errorf("failed to stop container: %w", err)
+	if err := runner.Teardown(dockerClient, containerName); err != nil {
+		return err
+	}
+	if branch != "" {
+		fmt.Printf("The agent's work is on branch %s\n", branch)
 	}
 
-	_, err = dockerClient.Run("rm", containerName)
+	fmt.Printf("Container %s stopped and removed\n", containerName)
+	return nil
+}
+
+// ensureRunning starts a container that stopped on its own, so its workspace can be read
+func ensureRunning(dockerClient *docker.Client, containerName string) error {
+	if isContainerRunning(dockerClient.Command(), containerName) {
+		return nil
+	}
+	if output, err := dockerClient.Run("start", containerName); err != nil {
+		return fmt.Errorf("failed to start %s to save its workspace: %w\n%s", containerName, err, output)
+	}
+	return nil
+}
+
+// commitSessionBranch commits a split-mode session's final workspace, returning its branch
+func commitSessionBranch(dockerClient *docker.Client, containerName string) (string, error) {
+	if err := ensureRunning(dockerClient, containerName); err != nil {
+		return "", err
+	}
+	tree, err := sessionbranch.Tree(dockerClient, containerName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := sessionbranch.Commit(containerName, tree, "packnplay: session end"); err != nil {
+		return "", err
+	}
+	state, err := sessionbranch.Load(containerName)
 	if err != nil {
-		return fmt.Errorf("failed to remove container: %w", err)
+		return "", err
 	}
+	return state.Branch, nil
+}
 
-	fmt.Printf("Container %s stopped and removed\n", containerName)
+// writeBackEdits applies a sync-mode session's remaining edits to the host
+func writeBackEdits(dockerClient *docker.Client, containerName string) error {
+	if err := ensureRunning(dockerClient, containerName); err != nil {
+		return err
+	}
+
+	plan, err := writeback.Flush(dockerClient, containerName, !stopSkipDepScan)
+	if err != nil {
+		return err
+	}
+	if len(plan.Changes) > 0 {
+		fmt.Printf("Wrote back %d file(s)\n", len(plan.Changes))
+	}
+	for _, path := range plan.Conflicts {
+		fmt.Fprintf(os.Stderr, "Conflict: %s changed on the host too; the sandbox's version is in %s%s\n", path, path, writeback.ConflictSuffix)
+	}
+	for _, path := range plan.Rejected {
+		fmt.Fprintf(os.Stderr, "Rejected: %s is outside the project's write policy and wasn't written back\n", path)
+	}
+	// Held manifests would go with the sandbox, so the container stays until they're reviewed
+	held, err := writeback.Held(containerName)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		return fmt.Errorf("%s add dependencies that are critically vulnerable, unknown or couldn't be checked; review them, then stop with --skip-dep-scan to write them back", strings.Join(held, ", "))
+	}
 	return nil
 }
 
@@ -121,4 +211,5 @@ func init() {
 	stopCmd.Flags().StringVar(&stopPath, "path", "", "Project path (default: pwd)")
 	stopCmd.Flags().StringVar(&stopWorktree, "worktree", "", "Worktree name")
 	stopCmd.Flags().BoolVar(&stopAll, "all", false, "Stop all packnplay-managed containers")
+	stopCmd.Flags().BoolVar(&stopSkipDepScan, "skip-dep-scan", false, "Write back sync-mode manifest edits even if their added dependencies are vulnerable or unknown")
 }
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/session"
+)
+
+// replay returns a client answering from a fixture in testdata/runtime, with packnplay's
+// state kept in a temporary home
+func replay(t *testing.T, fixture string) (*docker.Client, *docker.Replayer) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	replayer, err := docker.NewReplayer(filepath.Join("testdata", "runtime", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer.Set("HOME", home)
+	return replayer.Client(), replayer
+}
+
+func TestStopContainer(t *testing.T) {
+	client, replayer := replay(t, "stop.json")
+
+	if err := stopContainer(client, "packnplay-app-main"); err != nil {
+		t.Fatalf("stopContainer() error = %v", err)
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Error(err)
+	}
+	entries, err := session.LoadStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entries["packnplay-app-main"]; ok {
+		t.Error("stopped session is still recorded")
+	}
+}
+
+func TestStopAllContainers(t *testing.T) {
+	client, replayer := replay(t, "stop-all.json")
+
+	// A container that fails to stop is reported and the rest are still stopped
+	if err := stopAllContainers(client); err != nil {
+		t.Fatalf("stopAllContainers() error = %v", err)
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Error(err)
+	}
+	entries, err := session.LoadStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := entries["packnplay-app-main"]; !ok || !entry.Removing {
+		t.Errorf("session whose container couldn't be removed = %+v, %v; want it kept for recovery", entry, ok)
+	}
+	if _, ok := entries["packnplay-api-fix"]; ok {
+		t.Error("session whose container was already gone is still recorded")
+	}
+}
+
+func TestStopContainerOffScript(t *testing.T) {
+	client, replayer := replay(t, "stop.json")
+
+	_ = stopContainer(client, "packnplay-other-main")
+	if err := replayer.Verify(); err == nil || !strings.Contains(err.Error(), "packnplay-other-main") {
+		t.Errorf("Verify() = %v; want the unrecorded command reported", err)
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/redact"
+	"github.com/obra/packnplay/pkg/sessionenv"
+	"github.com/obra/packnplay/pkg/summary"
+	"github.com/spf13/cobra"
+)
+
+// summarizeTimeout bounds one summarizer run, so a stuck model doesn't stall the daemon
+const summarizeTimeout = 2 * time.Minute
+
+var (
+	summarizeContainer string
+	summarizeRuntime   string
+	summarizeAgent     string
+	summarizeInterval  time.Duration
+	summarizeRedactEnv []string
+)
+
+var summarizeCmd = &cobra.Command{
+	Use:    "session-summarize",
+	Short:  "Keep a one-line summary of a recorded session",
+	Long:   `Background daemon that summarizes a session's new terminal output alongside its recording, until the container stops.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-summarize")()
+		redactor := redact.FromEnv(summarizeRedactEnv, os.Getenv)
+
+		for isContainerRunning(summarizeRuntime, summarizeContainer) {
+			time.Sleep(summarizeInterval)
+
+			// The latest recording, since a reconnect starts a new one
+			path, err := recording.Find(summarizeContainer)
+			if err != nil {
+				continue
+			}
+			if err := summarizeRecording(path, redactor); err != nil {
+				log.Printf("Failed to summarize %s: %v", summarizeContainer, err)
+			}
+		}
+
+		log.Printf("Container %s stopped, exiting session summaries", summarizeContainer)
+		return nil
+	},
+}
+
+// summarizeRecording adds a summary of the output since the last one, if there's enough of it
+// Secrets are masked before the output leaves packnplay and again in the summary kept, since
+// the summarizer may be any command.
+func summarizeRecording(path string, redactor *redact.Redactor) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	_, events, err := recording.Read(file)
+	file.Close()
+	if err != nil {
+		// Usually a line still being written; the next pass reads it whole
+		return nil
+	}
+
+	text := summary.Text(events)
+	latest, _ := summary.LatestFor(path)
+	offset := latest.Offset
+	if offset > len(text) {
+		offset = 0
+	}
+	if len(text)-offset < summary.MinNewText {
+		return nil
+	}
+
+	cfg, err := config.LoadWithoutRuntimeCheck()
+	if err != nil {
+		return err
+	}
+	excerpt := redactor.Redact(summary.Excerpt(text, offset))
+	output, err := runSummarizer(cfg.Summaries.Command, summary.Prompt(latest.Text, excerpt))
+	if err != nil {
+		return err
+	}
+	line := summary.Clean(redactor.Redact(output))
+	if line == "" {
+		return fmt.Errorf("summarizer printed nothing")
+	}
+	return summary.Append(path, summary.Summary{Time: time.Now().UTC(), Offset: len(text), Text: line})
+}
+
+// runSummarizer feeds the prompt to the configured command on the host, or else to the
+// session's agent in headless mode inside its container
+func runSummarizer(command []string, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), summarizeTimeout)
+	defer cancel()
+
+	if len(command) == 0 {
+		agent := agents.Lookup(summarizeAgent)
+		headless, ok := agent.(agents.HeadlessRunner)
+		if !ok {
+			return "", fmt.Errorf("%s has no headless mode; set summaries.command", summarizeAgent)
+		}
+		command = append([]string{summarizeRuntime, "exec", "-i", summarizeContainer},
+			sessionenv.Wrap(append([]string{agent.Command()}, headless.HeadlessArgs()...))...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	summarizer := exec.CommandContext(ctx, command[0], command[1:]...)
+	summarizer.Stdin = bytes.NewBufferString(prompt)
+	summarizer.Stdout = &stdout
+	summarizer.Stderr = &stderr
+	if err := summarizer.Run(); err != nil {
+		return "", fmt.Errorf("summarizer failed: %w\n%s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func init() {
+	rootCmd.AddCommand(summarizeCmd)
+
+	summarizeCmd.Flags().StringVar(&summarizeContainer, "container", "", "Container whose recording to summarize")
+	summarizeCmd.Flags().StringVar(&summarizeRuntime, "runtime", "docker", "Container runtime used to reach the container")
+	summarizeCmd.Flags().StringVar(&summarizeAgent, "agent", "", "Agent the session runs, used as the summarizer unless one is configured")
+	summarizeCmd.Flags().DurationVar(&summarizeInterval, "interval", 2*time.Minute, "How often to look for new output")
+	summarizeCmd.Flags().StringArrayVar(&summarizeRedactEnv, "redact-env", nil, "Host variable whose value is masked before summarizing (repeatable)")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/redact"
+	"github.com/obra/packnplay/pkg/summary"
+)
+
+func TestSummarizeRecordingRedacts(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("TEST_API_TOKEN", "hunter2-secret-value")
+	prompt := filepath.Join(dir, "prompt.txt")
+	t.Setenv("SUMMARY_PROMPT", prompt)
+
+	os.MkdirAll(filepath.Join(dir, "packnplay"), 0755)
+	config := `{"summaries": {"enabled": true, "command": ["sh", "-c", "cat > \"$SUMMARY_PROMPT\"; echo \"exporting $TEST_API_TOKEN\""]}}`
+	if err := os.WriteFile(filepath.Join(dir, "packnplay", "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "session"+recording.Extension)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := recording.NewWriter(file, recording.Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.WriteEvent(recording.EventOutput, strings.Repeat("export TOKEN=hunter2-secret-value\r\n", 100))
+	file.Close()
+
+	if err := summarizeRecording(path, redact.FromEnv([]string{"TEST_API_TOKEN"}, os.Getenv)); err != nil {
+		t.Fatalf("summarizeRecording() error = %v", err)
+	}
+	sent, err := os.ReadFile(prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sent), "hunter2") || !strings.Contains(string(sent), redact.Mask) {
+		t.Error("summarizer was sent the secret unmasked")
+	}
+	latest, ok := summary.LatestFor(path)
+	if !ok || latest.Text != "exporting "+redact.Mask {
+		t.Errorf("stored summary = %q, %v; want the secret masked", latest.Text, ok)
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/ptyproxy"
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/redact"
+	"github.com/spf13/cobra"
+)
+
+var (
+	terminalSocket    string
+	terminalRecord    string
+	terminalRedactEnv []string
+	terminalTitle     string
+	terminalWidth     int
+	terminalHeight    int
+)
+
+var terminalCmd = &cobra.Command{
+	Use:    "session-terminal [flags] -- command...",
+	Short:  "Hold a session's terminal so it survives detaching",
+	Long:   `Background daemon that runs a session's command under a pseudo-terminal and lets 'packnplay run' detach from and reattach to it.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	Args:   cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-terminal")()
+
+		listener, err := ptyproxy.Listen(terminalSocket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(terminalSocket)
+
+		// args is the runtime's exec command, as runner.holdSession built it
+		command := docker.DefaultExecutor(filepath.Base(args[0])).Command(context.Background(), args[0], args[1:])
+		server, err := ptyproxy.Start(command, terminalWidth, terminalHeight)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+
+		if terminalRecord != "" {
+			redactor := redact.FromEnv(terminalRedactEnv, os.Getenv)
+			file, rec, err := recording.Create(terminalRecord, terminalTitle, terminalWidth, terminalHeight, redactor)
+			if err != nil {
+				log.Printf("Not recording %s: %v", terminalTitle, err)
+			} else {
+				defer file.Close()
+				defer rec.Flush()
+				server.Output = rec
+				server.OnResize = func(width, height int) { _ = rec.Resize(width, height) }
+			}
+		}
+
+		log.Printf("Holding the terminal of %s on %s", terminalTitle, terminalSocket)
+		code, err := server.Serve(listener)
+		if err != nil {
+			return fmt.Errorf("session terminal failed: %w", err)
+		}
+		log.Printf("%s exited with status %d", terminalTitle, code)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(terminalCmd)
+
+	terminalCmd.Flags().StringVar(&terminalSocket, "socket", "", "Unix socket clients attach through")
+	terminalCmd.Flags().StringVar(&terminalRecord, "record", "", "Also record the terminal to this asciicast file")
+	terminalCmd.Flags().StringArrayVar(&terminalRedactEnv, "redact-env", nil, "Mask the value of this environment variable in the recording")
+	terminalCmd.Flags().StringVar(&terminalTitle, "title", "", "Session the terminal belongs to")
+	terminalCmd.Flags().IntVar(&terminalWidth, "width", 80, "Initial terminal width")
+	terminalCmd.Flags().IntVar(&terminalHeight, "height", 24, "Initial terminal height")
+}
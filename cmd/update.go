@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/devcontainer"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/lockfile"
+	"github.com/obra/packnplay/pkg/projecttype"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updatePath    string
+	updateRuntime string
+	updateVerbose bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Refresh the project's .packnplay.lock",
+	Long: `Pull the project's image, pin its digest, and record the agent CLI versions it
+contains in .packnplay.lock, along with the exact npm version of each agent to
+install: the one the image ships, or the newest release matching agent_versions. Commit the lockfile so every teammate and CI run gets
+a byte-identical sandbox; 'packnplay run' uses the pinned digest when present.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectPath := updatePath
+		if projectPath == "" {
+			var err error
+			projectPath, err = os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+		}
+
+		projectPath, err := filepath.Abs(projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		runtime := updateRuntime
+		defaultImage := "ghcr.io/obra/packnplay-default:latest"
+		var languageImages map[string]string
+		if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil {
+			defaultImage, languageImages = cfg.DefaultImage, cfg.LanguageImages
+			if runtime == "" {
+				runtime = cfg.ContainerRuntime
+			}
+		}
+
+		devConfig, err := devcontainer.LoadConfig(projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to load devcontainer config: %w", err)
+		}
+
+		if err := projecttype.Validate(languageImages); err != nil {
+			return err
+		}
+		// Pinned the same way 'packnplay run' picks it, so the pin matches
+		image, _ := projecttype.Image(projectPath, languageImages, defaultImage)
+		if devConfig != nil {
+			if devConfig.DockerFile != "" {
+				return fmt.Errorf("project builds its image from %s; only prebuilt images can be pinned", devConfig.DockerFile)
+			}
+			image = devConfig.Image
+		}
+
+		dockerClient, err := docker.NewClientWithRuntime(runtime, updateVerbose)
+		if err != nil {
+			return fmt.Errorf("failed to initialize container runtime: %w", err)
+		}
+
+		old, err := lockfile.Load(projectPath)
+		if err != nil {
+			return err
+		}
+
+		projectConfig, err := config.LoadProjectConfig(projectPath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Resolving %s...\n", image)
+		lock, err := lockfile.Generate(dockerClient, image, projectConfig.AgentVersions)
+		if err != nil {
+			return err
+		}
+
+		changes := lockfile.Diff(old, lock)
+		if len(changes) == 0 {
+			fmt.Printf("%s is up to date\n", lockfile.FileName)
+			return nil
+		}
+
+		if err := lockfile.Save(projectPath, lock); err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+		fmt.Printf("Wrote %s\n", lockfile.Path(projectPath))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringVar(&updatePath, "path", "", "Project path (default: pwd)")
+	updateCmd.Flags().StringVar(&updateRuntime, "runtime", "", "Container runtime to use (docker/podman)")
+	updateCmd.Flags().BoolVar(&updateVerbose, "verbose", false, "Show all docker commands")
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/devcontainer"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/imagebuild"
+	"github.com/obra/packnplay/pkg/lockfile"
+	"github.com/obra/packnplay/pkg/projecttype"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeAgentsPath    string
+	upgradeAgentsRuntime string
+	upgradeAgentsVerbose bool
+)
+
+var upgradeAgentsCmd = &cobra.Command{
+	Use:   "upgrade-agents [agent[@version]...]",
+	Short: "Rebuild the project's agent layer with the latest agent CLIs",
+	Long: `Build an image layer on top of the project's image that installs the latest
+agent CLIs, or the versions pinned in .packnplay.yaml:
+
+  agent_versions:
+    claude: "1.x"
+
+Arguments add agents to the layer or override pins for this build, e.g.
+'packnplay upgrade-agents codex claude@2.0.1'. Installed versions are recorded as
+image labels, which every container started from the layer inherits. When
+.packnplay.lock pins agent versions, it's updated to the versions just installed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectPath := upgradeAgentsPath
+		if projectPath == "" {
+			var err error
+			projectPath, err = os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+		}
+
+		projectPath, err := filepath.Abs(projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		projectConfig, err := config.LoadProjectConfig(projectPath)
+		if err != nil {
+			return err
+		}
+		pins := make(map[string]string)
+		for name, version := range projectConfig.AgentVersions {
+			pins[name] = version
+		}
+
+		var names []string
+		for _, arg := range args {
+			if name, version, err := imagebuild.ParsePin(arg); err == nil {
+				pins[name] = version
+				continue
+			}
+			names = append(names, arg)
+		}
+
+		runtime := upgradeAgentsRuntime
+		defaultImage := ""
+		var languageImages map[string]string
+		if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil {
+			defaultImage, languageImages = cfg.DefaultImage, cfg.LanguageImages
+			if runtime == "" {
+				runtime = cfg.ContainerRuntime
+			}
+		}
+
+		devConfig, err := devcontainer.LoadConfig(projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to load devcontainer config: %w", err)
+		}
+		if devConfig == nil {
+			image, _ := projecttype.Image(projectPath, languageImages, defaultImage)
+			devConfig = devcontainer.GetDefaultConfig(image)
+		}
+
+		// Resolve the base image exactly as 'packnplay run' does, so the layer is picked up
+		baseImage := devConfig.Image
+		var lock *lockfile.Lockfile
+		if devConfig.DockerFile != "" {
+			baseImage = fmt.Sprintf("packnplay-%s-devcontainer:latest", filepath.Base(projectPath))
+		} else if lock, err = lockfile.Load(projectPath); err != nil {
+			return err
+		} else if lock != nil {
+			if baseImage, err = lock.PinnedImage(devConfig.Image); err != nil {
+				return err
+			}
+		}
+
+		dockerClient, err := docker.NewClientWithRuntime(runtime, upgradeAgentsVerbose)
+		if err != nil {
+			return fmt.Errorf("failed to initialize container runtime: %w", err)
+		}
+
+		// Upgrade whatever the base image already ships, plus anything requested
+		baseVersions, err := lockfile.DetectAgentVersions(dockerClient, baseImage)
+		if err != nil {
+			return err
+		}
+		for name := range baseVersions {
+			names = append(names, name)
+		}
+
+		installs, err := imagebuild.ResolveInstalls(names, pins)
+		if err != nil {
+			return err
+		}
+		if len(installs) == 0 {
+			return fmt.Errorf("no installable agents found in %s; name the agents to install", baseImage)
+		}
+
+		tag := imagebuild.AgentImageName(filepath.Base(projectPath))
+		previous, _ := imagebuild.InstalledVersions(dockerClient, tag)
+
+		fmt.Printf("Building %s from %s...\n", tag, baseImage)
+		versions, packages, err := imagebuild.BuildAgentImage(dockerClient, baseImage, devConfig.RemoteUser, tag, installs)
+		if err != nil {
+			return err
+		}
+
+		installed := make([]string, 0, len(versions))
+		for name := range versions {
+			installed = append(installed, name)
+		}
+		sort.Strings(installed)
+		for _, name := range installed {
+			before := previous[name]
+			if before == "" {
+				before = baseVersions[name]
+			}
+			if before != "" && before != versions[name] {
+				fmt.Printf("  %s: %s -> %s\n", name, before, versions[name])
+			} else {
+				fmt.Printf("  %s: %s\n", name, versions[name])
+			}
+		}
+
+		// 'packnplay run' rebuilds a layer that doesn't match the lock, so pin what was just installed
+		if lock != nil && len(lock.AgentPackages) > 0 {
+			lock.AgentPackages = packages
+			if err := lockfile.Save(projectPath, lock); err != nil {
+				return err
+			}
+			fmt.Printf("Updated agent versions in %s\n", lockfile.Path(projectPath))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeAgentsCmd)
+
+	upgradeAgentsCmd.Flags().StringVar(&upgradeAgentsPath, "path", "", "Project path (default: pwd)")
+	upgradeAgentsCmd.Flags().StringVar(&upgradeAgentsRuntime, "runtime", "", "Container runtime to use (docker/podman)")
+	upgradeAgentsCmd.Flags().BoolVar(&upgradeAgentsVerbose, "verbose", false, "Show all docker commands")
+}
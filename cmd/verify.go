@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/autopr"
+	"github.com/obra/packnplay/pkg/ci"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/depscan"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/review"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/summary"
+	"github.com/obra/packnplay/pkg/verify"
+	"github.com/obra/packnplay/pkg/writepolicy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyShards int
+	verifyAutoPR bool
+	verifyTask   string
+
+	verifySkipDepScan bool
+	verifySkipReview  bool
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <container> [--shards N] -- <command>...",
+	Short: "Run a test command against a session's workspace, optionally sharded",
+	Long: `Run a verification command in fresh containers that share the session's workspace
+and git mounts, so checking an agent's work doesn't tie up its session.
+
+With --shards N the command runs in N containers in parallel. Each gets
+PACKNPLAY_SHARD_INDEX (1-based) and PACKNPLAY_SHARD_TOTAL, and {shard} / {shards}
+in the command are substituted:
+
+  packnplay verify packnplay-app-main --shards 4 -- npx jest --shard={shard}/{shards}
+
+Shards share one workspace, so the suite must not write conflicting files.
+
+With --auto-pr, a passing run commits the session's changes to a branch named
+after --task, pushes it, and opens a pull request (gh), merge request (glab) or
+Bitbucket pull request (BITBUCKET_ACCESS_TOKEN). Before publishing, changes the
+project's write policy (writes in .packnplay.yaml) doesn't allow block it. Packages the
+session added to package.json, go.mod or requirements.txt are checked against OSV;
+a critical vulnerability, or a package its registry has never heard of, blocks
+the pull request (--skip-dep-scan overrides). Then the configured reviewers see the
+diff (see 'packnplay review'); a gating reviewer that rejects blocks it too
+(--skip-review overrides), and the verdicts go in the pull request description.
+
+Under GitHub Actions, GitLab CI and Bitbucket Pipelines, shard output is grouped
+in the CI's log format and pushes use the job's token (GITHUB_TOKEN, CI_JOB_TOKEN,
+or BITBUCKET_ACCESS_TOKEN). In GitLab CI without GITLAB_TOKEN the merge request is
+opened with push options, which only needs push access.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if verifyShards < 1 {
+			return fmt.Errorf("--shards must be at least 1")
+		}
+		containerName, command := args[0], args[1:]
+
+		dockerClient, err := docker.NewClient(false)
+		if err != nil {
+			return fmt.Errorf("failed to initialize docker: %w", err)
+		}
+
+		format := fmt.Sprintf(`{{.Image}}|{{range .Mounts}}{{if eq .Destination "/workspace"}}{{.Source}}{{end}}{{end}}|{{index .Config.Labels "packnplay-worktree"}}|{{index .Config.Labels %q}}|{{index .Config.Labels "packnplay-project"}}`, container.StartCommitLabel)
+		output, err := dockerClient.Run("inspect", "--format", format, containerName)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container %s: %w\n%s", containerName, err, output)
+		}
+		fields := strings.Split(strings.TrimSpace(output), "|")
+		if len(fields) != 5 {
+			return fmt.Errorf("unexpected inspect output for %s: %s", containerName, output)
+		}
+		image, workspace, worktree, startCommit, project := fields[0], fields[1], fields[2], fields[3], fields[4]
+		if verifyAutoPR && workspace == "" {
+			return fmt.Errorf("container %s is not a packnplay session (no /workspace mount)", containerName)
+		}
+
+		// Sessions packnplay doesn't track (started by an older version) are verified all the same
+		tracked := true
+		if err := session.Transition(containerName, session.StateVerifying); err != nil {
+			if !errors.Is(err, session.ErrUntracked) {
+				fmt.Fprintf(os.Stderr, "Warning: %v; verifying without recording it\n", err)
+			}
+			tracked = false
+		}
+		// The verification's outcome is kept as the session's failure, for 'packnplay sessions --status failed'
+		settle := func(state session.State, failure string) {
+			if !tracked {
+				return
+			}
+			if err := session.Transition(containerName, state); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			if err := session.SetFailure(containerName, failure); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+
+		fmt.Printf("Running %s across %d shard(s)...\n", strings.Join(command, " "), verifyShards)
+		results := verify.Run(dockerClient, containerName, image, command, verifyShards)
+
+		// In CI, fold each shard's output into a native collapsible log section
+		ciEnv := ci.Detect(os.Getenv)
+		for _, result := range results {
+			status := "passed"
+			if result.Err != nil {
+				status = "FAILED"
+			}
+			title := fmt.Sprintf("shard %d/%d %s (%s)", result.Index, verifyShards, status, result.Duration.Round(100*time.Millisecond))
+			if ciEnv != nil {
+				id := fmt.Sprintf("packnplay-shard-%d", result.Index)
+				ciEnv.GroupStart(os.Stdout, id, title)
+				fmt.Print(result.Output)
+				ciEnv.GroupEnd(os.Stdout, id)
+				continue
+			}
+			fmt.Printf("\n=== %s ===\n", title)
+			fmt.Print(result.Output)
+		}
+
+		if failed := verify.Failed(results); len(failed) > 0 {
+			err := fmt.Errorf("%d of %d shard(s) failed", len(failed), verifyShards)
+			settle(session.StateRunning, "verification failed: "+err.Error())
+			return err
+		}
+		fmt.Printf("\nAll %d shard(s) passed\n", verifyShards)
+		settle(session.StateReviewing, "")
+
+		if verifyAutoPR {
+			if err := checkWritePolicy(workspace, startCommit); err != nil {
+				return err
+			}
+			if !verifySkipDepScan {
+				if err := scanAddedDependencies(workspace, startCommit); err != nil {
+					return err
+				}
+			}
+
+			task := verifyTask
+			if task == "" {
+				task = worktree
+			}
+
+			var reviewSummary string
+			if cfg, err := config.LoadWithoutRuntimeCheck(); err == nil && len(cfg.Reviewers) > 0 && !verifySkipReview {
+				verdicts, err := reviewSession(dockerClient, cfg.Reviewers, containerName, workspace, startCommit, review.Request{
+					Session:  containerName,
+					Project:  project,
+					Worktree: worktree,
+					Task:     task,
+				})
+				if err != nil {
+					return err
+				}
+				if blocking := blockingVerdicts(verdicts); len(blocking) > 0 {
+					return fmt.Errorf("not publishing: %d gating reviewer(s) did not approve (use --skip-review to override)", len(blocking))
+				}
+				reviewSummary = describeVerdicts(verdicts)
+			}
+
+			var transcriptSummary string
+			if latest, ok := summary.Latest(containerName); ok {
+				transcriptSummary = latest.Text
+			}
+			url, err := autopr.Open(workspace, startCommit, autopr.Options{
+				Task:         task,
+				Summary:      transcriptSummary,
+				Verification: fmt.Sprintf("`%s` passed across %d shard(s)", strings.Join(command, " "), verifyShards),
+				Review:       reviewSummary,
+				CI:           ciEnv,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Opened %s\n", url)
+			settle(session.StateApplied, "")
+		}
+		return nil
+	},
+}
+
+// checkWritePolicy fails if the session changed files the project's write policy, as
+// committed when the session started, doesn't allow
+func checkWritePolicy(workspace, base string) error {
+	policy, err := writepolicy.AtCommit(workspace, base)
+	if err != nil || policy.Empty() {
+		return err
+	}
+	changed, err := git.ChangedSince(workspace, base)
+	if err != nil {
+		return err
+	}
+	if denied := writepolicy.Denied(policy, changed); len(denied) > 0 {
+		fmt.Printf("\n%s", writepolicy.Report(policy, denied))
+		return fmt.Errorf("not publishing: %d change(s) are outside the project's write policy; revert them in the session first", len(denied))
+	}
+	return nil
+}
+
+// scanAddedDependencies fails if the session added packages that are critically
+// vulnerable or don't exist; a scan that can't complete fails too
+func scanAddedDependencies(workspace, base string) error {
+	added, err := depscan.AddedSince(workspace, base)
+	if err != nil {
+		return fmt.Errorf("failed to find added dependencies: %w", err)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\nScanning %d added dependencies...\n", len(added))
+	report, err := depscan.Scan(added)
+	if err != nil {
+		return fmt.Errorf("dependency scan failed (use --skip-dep-scan to publish anyway): %w", err)
+	}
+	for _, finding := range report.Findings {
+		if finding.Unknown {
+			fmt.Printf("  %s: not found in its registry\n", finding.Package)
+		}
+		for _, v := range finding.Vulns {
+			fmt.Printf("  %s: %s [%s] %s\n", finding.Package, v.ID, v.Severity, v.Summary)
+		}
+	}
+	if blocking := report.Blocking(); len(blocking) > 0 {
+		return fmt.Errorf("not publishing: %d added dependencies are critically vulnerable or unknown (use --skip-dep-scan to override)", len(blocking))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().IntVar(&verifyShards, "shards", 1, "Number of containers to split the command across")
+	verifyCmd.Flags().BoolVar(&verifyAutoPR, "auto-pr", false, "Commit, push and open a pull request when verification passes")
+	verifyCmd.Flags().BoolVar(&verifySkipDepScan, "skip-dep-scan", false, "Publish with --auto-pr even if added dependencies are vulnerable or unknown")
+	verifyCmd.Flags().BoolVar(&verifySkipReview, "skip-review", false, "Publish with --auto-pr without sending the changes to reviewers")
+	verifyCmd.Flags().StringVar(&verifyTask, "task", "", "Task description naming the branch and pull request (default: worktree name)")
+}
@@ -19,6 +19,7 @@ var watchCmd = &cobra.Command{
 	Long:   `Background daemon that watches container credential files and syncs them to keychain and other containers.`,
 	Hidden: true, // Hide from help - internal command
 	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-watcher")()
 		return runCredentialWatcher()
 	},
 }
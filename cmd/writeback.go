@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/writeback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	writebackContainer string
+	writebackRuntime   string
+	writebackInterval  time.Duration
+)
+
+var writebackCmd = &cobra.Command{
+	Use:    "writeback",
+	Short:  "Write a sync-mode session's edits back to the host",
+	Long:   `Background daemon that applies the agent's edits to the host workspace whenever the sandbox's copy stops changing, and copies host edits into the sandbox as they happen, until the container stops.`,
+	Hidden: true, // Hide from help - internal command started by 'packnplay run'
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupDaemonLogging("packnplay-writeback")()
+
+		dockerClient, err := docker.NewClientWithRuntime(writebackRuntime, false)
+		if err != nil {
+			return err
+		}
+
+		// Host edits go in as they happen, so watchers in the sandbox see them
+		workspace, err := writeback.Workspace(writebackContainer)
+		if err != nil {
+			return err
+		}
+		stop, err := writeback.WatchHost(workspace, func(paths []string) {
+			plan, err := writeback.Pull(dockerClient, writebackContainer, paths)
+			if err != nil {
+				log.Printf("Failed to copy host edits into %s: %v", writebackContainer, err)
+			} else if len(plan.Changes) > 0 {
+				log.Printf("Copied %d host edit(s) into %s", len(plan.Changes), writebackContainer)
+			}
+		}, func(err error) {
+			log.Printf("Host edits may not reach %s: %v", writebackContainer, err)
+		})
+		if err != nil {
+			log.Printf("Failed to watch %s; host edits won't reach %s: %v", workspace, writebackContainer, err)
+		} else {
+			defer stop()
+		}
+
+		var previous, flushed writeback.Stats
+		for isContainerRunning(writebackRuntime, writebackContainer) {
+			time.Sleep(writebackInterval)
+
+			// Polling stats reads no file contents; Flush hashes only the files they show changed
+			current, err := writeback.SandboxStats(dockerClient, writebackContainer)
+			if err != nil {
+				log.Printf("Failed to check %s for edits: %v", writebackContainer, err)
+				continue
+			}
+			// Tool calls write in bursts; a workspace unchanged for a whole interval is between them
+			if writeback.Equal(current, previous) && !writeback.Equal(current, flushed) {
+				plan, err := writeback.Flush(dockerClient, writebackContainer, true)
+				if err != nil {
+					log.Printf("Failed to write back edits from %s: %v", writebackContainer, err)
+				} else {
+					flushed = current
+					logPlan(plan)
+				}
+			}
+			previous = current
+		}
+
+		log.Printf("Container %s stopped, exiting writeback scheduler", writebackContainer)
+		return nil
+	},
+}
+
+func logPlan(plan writeback.Plan) {
+	if len(plan.Changes) > 0 {
+		log.Printf("Wrote back %d file(s) from %s", len(plan.Changes), writebackContainer)
+	}
+	for _, path := range plan.Conflicts {
+		log.Printf("Conflict: %s changed on the host too; the sandbox's version is in %s%s", path, path, writeback.ConflictSuffix)
+	}
+	for _, path := range plan.Rejected {
+		log.Printf("Rejected: %s is outside the project's write policy; the edit stays in the sandbox", path)
+	}
+	for _, path := range plan.Held {
+		log.Printf("Held: %s adds dependencies that are critically vulnerable, unknown or couldn't be checked; the edit stays in the sandbox", path)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(writebackCmd)
+
+	writebackCmd.Flags().StringVar(&writebackContainer, "container", "", "Container whose edits to write back")
+	writebackCmd.Flags().StringVar(&writebackRuntime, "runtime", "docker", "Container runtime used to reach the container")
+	writebackCmd.Flags().DurationVar(&writebackInterval, "interval", 2*time.Second, "How long the workspace must stay unchanged before a writeback")
+}
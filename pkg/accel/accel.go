@@ -0,0 +1,163 @@
+package accel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Accelerator kinds
+const (
+	KindNVIDIA = "nvidia"
+	KindROCm   = "rocm"
+	KindMetal  = "metal"
+)
+
+// cdiSpecPaths are where the NVIDIA Container Toolkit writes its CDI spec
+var cdiSpecPaths = []string{"/etc/cdi/nvidia.yaml", "/etc/cdi/nvidia.json", "/var/run/cdi/nvidia.yaml", "/var/run/cdi/nvidia.json"}
+
+// Accelerator is one accelerator found on the host
+type Accelerator struct {
+	Kind string
+	Name string
+}
+
+// Passthrough says whether a runtime can give a session an accelerator, and how or why not
+type Passthrough struct {
+	OK     bool
+	Detail string
+}
+
+// Host is how the inventory looks at the machine; tests replace its functions
+type Host struct {
+	GOOS   string
+	GOARCH string
+	Exec   func(name string, args ...string) (string, error) // runs a host program
+	Exists func(path string) bool
+}
+
+// LocalHost inspects the machine packnplay is running on
+func LocalHost() Host {
+	return Host{
+		GOOS:   runtime.GOOS,
+		GOARCH: runtime.GOARCH,
+		Exec: func(name string, args ...string) (string, error) {
+			output, err := exec.Command(name, args...).Output()
+			return string(output), err
+		},
+		Exists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+	}
+}
+
+// Detect lists the accelerators on the host
+func (h Host) Detect() []Accelerator {
+	var found []Accelerator
+
+	if output, err := h.Exec("nvidia-smi", "--query-gpu=name", "--format=csv,noheader"); err == nil {
+		for _, name := range strings.Split(strings.TrimSpace(output), "\n") {
+			if name = strings.TrimSpace(name); name != "" {
+				found = append(found, Accelerator{Kind: KindNVIDIA, Name: name})
+			}
+		}
+	}
+
+	// /dev/kfd is the ROCm compute interface; rocm-smi only adds a friendlier name
+	if h.GOOS == "linux" && h.Exists("/dev/kfd") {
+		name := "AMD GPU"
+		if output, err := h.Exec("rocm-smi", "--showproductname", "--csv"); err == nil {
+			if product := rocmProductName(output); product != "" {
+				name = product
+			}
+		}
+		found = append(found, Accelerator{Kind: KindROCm, Name: name})
+	}
+
+	if h.GOOS == "darwin" && h.GOARCH == "arm64" {
+		name := "Apple Silicon GPU"
+		if output, err := h.Exec("sysctl", "-n", "machdep.cpu.brand_string"); err == nil && strings.TrimSpace(output) != "" {
+			name = strings.TrimSpace(output) + " GPU"
+		}
+		found = append(found, Accelerator{Kind: KindMetal, Name: name})
+	}
+
+	return found
+}
+
+// rocmProductName picks the first card's product name from `rocm-smi --showproductname --csv`
+func rocmProductName(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return ""
+	}
+	header := strings.Split(lines[0], ",")
+	row := strings.Split(lines[1], ",")
+	for i, column := range header {
+		if strings.Contains(column, "Card series") && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+	}
+	return ""
+}
+
+// CheckPassthrough works out whether runtime (docker, podman or container) can hand an
+// accelerator of kind to a container. run executes the runtime's CLI.
+func (h Host) CheckPassthrough(kind, runtimeCmd string, run func(args ...string) (string, error)) Passthrough {
+	switch kind {
+	case KindNVIDIA:
+		if h.GOOS != "linux" {
+			return Passthrough{Detail: "NVIDIA passthrough needs a Linux host"}
+		}
+		if runtimeCmd == "docker" {
+			if output, err := run("info", "--format", "{{json .Runtimes}}"); err == nil && strings.Contains(output, `"nvidia"`) {
+				return Passthrough{OK: true, Detail: "nvidia runtime registered (--gpus all)"}
+			}
+		}
+		for _, path := range cdiSpecPaths {
+			if h.Exists(path) {
+				return Passthrough{OK: true, Detail: "CDI spec at " + path + " (--device nvidia.com/gpu=all)"}
+			}
+		}
+		return Passthrough{Detail: "install the NVIDIA Container Toolkit and generate a CDI spec (nvidia-ctk cdi generate)"}
+
+	case KindROCm:
+		if runtimeCmd != "docker" && runtimeCmd != "podman" {
+			return Passthrough{Detail: fmt.Sprintf("%s can't pass through /dev/kfd", runtimeCmd)}
+		}
+		if !h.Exists("/dev/dri") {
+			return Passthrough{Detail: "/dev/dri is missing; the amdgpu driver isn't loaded"}
+		}
+		return Passthrough{OK: true, Detail: "device nodes (--device /dev/kfd --device /dev/dri)"}
+
+	case KindMetal:
+		// Metal only reaches Linux guests through krunkit's virtio-gpu Venus support
+		if runtimeCmd != "podman" {
+			return Passthrough{Detail: fmt.Sprintf("%s VMs have no GPU; use podman with a libkrun (krunkit) machine", runtimeCmd)}
+		}
+		output, err := run("machine", "inspect")
+		if err != nil {
+			return Passthrough{Detail: "no podman machine found"}
+		}
+		if vmType := podmanVMType(output); vmType != "libkrun" {
+			return Passthrough{Detail: fmt.Sprintf("podman machine uses %s; recreate it with CONTAINERS_MACHINE_PROVIDER=libkrun", vmType)}
+		}
+		return Passthrough{OK: true, Detail: "libkrun machine (--device /dev/dri, Vulkan via Venus)"}
+	}
+	return Passthrough{Detail: "unknown accelerator"}
+}
+
+// podmanVMType returns the VM type of the first machine in `podman machine inspect` output
+func podmanVMType(output string) string {
+	var machines []struct {
+		VMType string `json:"VMType"`
+	}
+	if err := json.Unmarshal([]byte(output), &machines); err != nil || len(machines) == 0 || machines[0].VMType == "" {
+		return "an unknown provider"
+	}
+	return machines[0].VMType
+}
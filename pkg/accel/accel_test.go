@@ -0,0 +1,99 @@
+package accel
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeHost answers commands from outputs; anything missing fails like an absent binary
+func fakeHost(goos string, outputs map[string]string, paths ...string) Host {
+	return Host{
+		GOOS:   goos,
+		GOARCH: "arm64",
+		Exec: func(name string, args ...string) (string, error) {
+			if output, ok := outputs[name]; ok {
+				return output, nil
+			}
+			return "", errors.New("not found")
+		},
+		Exists: func(path string) bool {
+			for _, p := range paths {
+				if p == path {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func TestDetect(t *testing.T) {
+	host := fakeHost("linux", map[string]string{
+		"nvidia-smi": "NVIDIA GeForce RTX 4090\nNVIDIA A100-SXM4-40GB\n",
+		"rocm-smi":   "device,Card series,Card model\ncard0,Radeon RX 7900 XTX,0x744c\n",
+	}, "/dev/kfd")
+
+	got := host.Detect()
+	want := []Accelerator{
+		{Kind: KindNVIDIA, Name: "NVIDIA GeForce RTX 4090"},
+		{Kind: KindNVIDIA, Name: "NVIDIA A100-SXM4-40GB"},
+		{Kind: KindROCm, Name: "Radeon RX 7900 XTX"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Detect() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Detect()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	mac := fakeHost("darwin", map[string]string{"sysctl": "Apple M3 Max\n"})
+	if got := mac.Detect(); len(got) != 1 || got[0] != (Accelerator{Kind: KindMetal, Name: "Apple M3 Max GPU"}) {
+		t.Errorf("Detect() on Apple Silicon = %+v", got)
+	}
+
+	if got := fakeHost("linux", nil).Detect(); len(got) != 0 {
+		t.Errorf("Detect() with no accelerators = %+v, want none", got)
+	}
+}
+
+func TestCheckPassthrough(t *testing.T) {
+	runtimeOutput := func(outputs map[string]string) func(args ...string) (string, error) {
+		return func(args ...string) (string, error) {
+			if output, ok := outputs[args[0]]; ok {
+				return output, nil
+			}
+			return "", errors.New("failed")
+		}
+	}
+
+	tests := []struct {
+		name    string
+		host    Host
+		kind    string
+		runtime string
+		outputs map[string]string
+		want    bool
+	}{
+		{"nvidia runtime", fakeHost("linux", nil), KindNVIDIA, "docker", map[string]string{"info": `{"nvidia":{},"runc":{}}`}, true},
+		{"nvidia CDI on podman", fakeHost("linux", nil, "/etc/cdi/nvidia.yaml"), KindNVIDIA, "podman", nil, true},
+		{"nvidia without toolkit", fakeHost("linux", nil), KindNVIDIA, "docker", map[string]string{"info": `{"runc":{}}`}, false},
+		{"rocm devices", fakeHost("linux", nil, "/dev/kfd", "/dev/dri"), KindROCm, "podman", nil, true},
+		{"metal on docker desktop", fakeHost("darwin", nil), KindMetal, "docker", nil, false},
+		{"metal on applehv podman", fakeHost("darwin", nil), KindMetal, "podman", map[string]string{"machine": `[{"VMType":"applehv"}]`}, false},
+		{"metal on krunkit podman", fakeHost("darwin", nil), KindMetal, "podman", map[string]string{"machine": `[{"VMType":"libkrun"}]`}, true},
+		{"metal on apple container", fakeHost("darwin", nil), KindMetal, "container", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.host.CheckPassthrough(tt.kind, tt.runtime, runtimeOutput(tt.outputs))
+			if got.OK != tt.want {
+				t.Errorf("CheckPassthrough() = %+v, want OK=%v", got, tt.want)
+			}
+			if got.Detail == "" {
+				t.Error("CheckPassthrough() should always explain itself")
+			}
+		})
+	}
+}
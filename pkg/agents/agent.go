@@ -1,18 +1,56 @@
 package agents
 
 import (
+	"os"
 	"path/filepath"
 )
 
 // Agent defines the interface for AI coding agents
 type Agent interface {
 	Name() string
+	Command() string             // CLI binary, e.g., "claude", "cursor-agent"
 	ConfigDir() string           // e.g., ".claude", ".codex", ".gemini"
 	DefaultAPIKeyEnv() string    // e.g., "ANTHROPIC_API_KEY", "OPENAI_API_KEY"
 	RequiresSpecialHandling() bool // Claude needs credential overlay, others don't
 	GetMounts(hostHomeDir string, containerUser string) []Mount
 }
 
+// EnvProvider is implemented by agents that need environment variables beyond their API key,
+// e.g. host paths that must be rewritten to their location inside the container
+type EnvProvider interface {
+	GetEnv(hostHomeDir string, containerUser string) []string
+}
+
+// InstallSpec describes how to install an agent's CLI into an image
+type InstallSpec struct {
+	NPMPackage string // installed with `npm install -g`
+	Script     string // shell snippet run as the container user when there's no npm package
+}
+
+// Installable is implemented by agents packnplay knows how to install
+type Installable interface {
+	Install() InstallSpec
+}
+
+// HeadlessRunner is implemented by agents with a non-interactive mode that takes its prompt
+// as an argument or from stdin and prints the result, for 'packnplay exec'
+type HeadlessRunner interface {
+	HeadlessArgs() []string // arguments after the CLI that select the mode
+}
+
+// PermissionSkipper is implemented by agents with a flag that stops them asking before
+// running commands or editing files, for 'packnplay run --yolo'. Only the container
+// makes that safe.
+type PermissionSkipper interface {
+	SkipPermissionsArgs() []string
+}
+
+// MinimalMounter is implemented by agents that can run with just their credential and
+// settings files, instead of a config dir that also holds history from every other project
+type MinimalMounter interface {
+	GetMinimalMounts(hostHomeDir string, containerUser string) []Mount
+}
+
 // Mount represents a directory or file mount
 type Mount struct {
 	HostPath      string
@@ -31,16 +69,37 @@ func GetSupportedAgents() []Agent {
 		&CursorAgent{},
 		&AmpAgent{},
 		&DeepSeekAgent{},
+		&AmazonQAgent{},
+		&AuggieAgent{},
+		&GrokAgent{},
+		&CrushAgent{},
+		&ClineAgent{},
+		&KilocodeAgent{},
+		&RooAgent{},
+	}
+}
+
+// Lookup finds a supported agent by name or CLI command, returning nil if none matches
+func Lookup(nameOrCommand string) Agent {
+	for _, agent := range GetSupportedAgents() {
+		if agent.Name() == nameOrCommand || agent.Command() == nameOrCommand {
+			return agent
+		}
 	}
+	return nil
 }
 
 // ClaudeAgent implements Claude Code specific requirements
 type ClaudeAgent struct{}
 
 func (c *ClaudeAgent) Name() string                { return "claude" }
+func (c *ClaudeAgent) Command() string             { return "claude" }
 func (c *ClaudeAgent) ConfigDir() string           { return ".claude" }
 func (c *ClaudeAgent) DefaultAPIKeyEnv() string    { return "ANTHROPIC_API_KEY" }
 func (c *ClaudeAgent) RequiresSpecialHandling() bool { return true } // Needs credential overlay
+func (c *ClaudeAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@anthropic-ai/claude-code"} }
+func (c *ClaudeAgent) HeadlessArgs() []string        { return []string{"-p"} }
+func (c *ClaudeAgent) SkipPermissionsArgs() []string { return []string{"--dangerously-skip-permissions"} }
 
 func (c *ClaudeAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 	containerHomeDir := "/root"
@@ -61,9 +120,13 @@ func (c *ClaudeAgent) GetMounts(hostHomeDir string, containerUser string) []Moun
 type CodexAgent struct{}
 
 func (c *CodexAgent) Name() string                { return "codex" }
+func (c *CodexAgent) Command() string             { return "codex" }
 func (c *CodexAgent) ConfigDir() string           { return ".codex" }
 func (c *CodexAgent) DefaultAPIKeyEnv() string    { return "OPENAI_API_KEY" }
 func (c *CodexAgent) RequiresSpecialHandling() bool { return false } // Simple config mount
+func (c *CodexAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@openai/codex"} }
+func (c *CodexAgent) HeadlessArgs() []string        { return []string{"exec"} }
+func (c *CodexAgent) SkipPermissionsArgs() []string { return []string{"--full-auto"} }
 
 func (c *CodexAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 	containerHomeDir := "/root"
@@ -84,32 +147,83 @@ func (c *CodexAgent) GetMounts(hostHomeDir string, containerUser string) []Mount
 type GeminiAgent struct{}
 
 func (g *GeminiAgent) Name() string                { return "gemini" }
+func (g *GeminiAgent) Command() string             { return "gemini" }
 func (g *GeminiAgent) ConfigDir() string           { return ".gemini" }
 func (g *GeminiAgent) DefaultAPIKeyEnv() string    { return "GEMINI_API_KEY" }
 func (g *GeminiAgent) RequiresSpecialHandling() bool { return false } // Simple config mount
+func (g *GeminiAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@google/gemini-cli"} }
+func (g *GeminiAgent) HeadlessArgs() []string        { return nil } // already non-interactive with a prompt or piped stdin
+func (g *GeminiAgent) SkipPermissionsArgs() []string { return []string{"--yolo"} }
 
 func (g *GeminiAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
-	containerHomeDir := "/root"
-	if containerUser != "root" {
-		containerHomeDir = "/home/" + containerUser
-	}
+	containerHomeDir := containerHome(containerUser)
 
-	return []Mount{
+	// .gemini also holds the OAuth cache (oauth_creds.json, google_accounts.json)
+	mounts := []Mount{
 		{
 			HostPath:      filepath.Join(hostHomeDir, ".gemini"),
 			ContainerPath: filepath.Join(containerHomeDir, ".gemini"),
 			ReadOnly:      false,
 		},
+		{
+			// Google Cloud Application Default Credentials from `gcloud auth application-default login`
+			HostPath:      filepath.Join(hostHomeDir, geminiADCPath),
+			ContainerPath: filepath.Join(containerHomeDir, geminiADCPath),
+			ReadOnly:      true,
+		},
+	}
+
+	// Explicit service account / ADC file pointed to by GOOGLE_APPLICATION_CREDENTIALS
+	if hostCreds := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); hostCreds != "" {
+		mounts = append(mounts, Mount{
+			HostPath:      hostCreds,
+			ContainerPath: filepath.Join(containerHomeDir, geminiCredentialsPath),
+			ReadOnly:      true,
+		})
+	}
+
+	return mounts
+}
+
+// GetEnv passes through Vertex AI / Code Assist settings and rewrites
+// GOOGLE_APPLICATION_CREDENTIALS to where the credentials file is mounted
+func (g *GeminiAgent) GetEnv(hostHomeDir string, containerUser string) []string {
+	var env []string
+
+	passthrough := []string{
+		"GOOGLE_CLOUD_PROJECT",
+		"GOOGLE_CLOUD_LOCATION",
+		"GOOGLE_GENAI_USE_VERTEXAI",
+		"GOOGLE_GENAI_USE_GCA",
 	}
+	for _, key := range passthrough {
+		if value := os.Getenv(key); value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+		env = append(env, "GOOGLE_APPLICATION_CREDENTIALS="+filepath.Join(containerHome(containerUser), geminiCredentialsPath))
+	}
+
+	return env
 }
 
+const (
+	geminiADCPath         = ".config/gcloud/application_default_credentials.json"
+	geminiCredentialsPath = ".config/gcloud/packnplay-google-credentials.json"
+)
+
 // CopilotAgent implements GitHub Copilot CLI requirements
 type CopilotAgent struct{}
 
 func (c *CopilotAgent) Name() string                { return "copilot" }
+func (c *CopilotAgent) Command() string             { return "copilot" }
 func (c *CopilotAgent) ConfigDir() string           { return ".copilot" }
 func (c *CopilotAgent) DefaultAPIKeyEnv() string    { return "GH_TOKEN" } // Uses GitHub auth
 func (c *CopilotAgent) RequiresSpecialHandling() bool { return false }
+func (c *CopilotAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@github/copilot"} }
+func (c *CopilotAgent) SkipPermissionsArgs() []string { return []string{"--allow-all-tools"} }
 
 func (c *CopilotAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 	containerHomeDir := "/root"
@@ -130,9 +244,13 @@ func (c *CopilotAgent) GetMounts(hostHomeDir string, containerUser string) []Mou
 type QwenAgent struct{}
 
 func (q *QwenAgent) Name() string                { return "qwen" }
+func (q *QwenAgent) Command() string             { return "qwen" }
 func (q *QwenAgent) ConfigDir() string           { return ".qwen" }
 func (q *QwenAgent) DefaultAPIKeyEnv() string    { return "QWEN_API_KEY" }
 func (q *QwenAgent) RequiresSpecialHandling() bool { return false }
+func (q *QwenAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@qwen-code/qwen-code"} }
+func (q *QwenAgent) HeadlessArgs() []string        { return nil } // already non-interactive with a prompt or piped stdin
+func (q *QwenAgent) SkipPermissionsArgs() []string { return []string{"--yolo"} }
 
 func (q *QwenAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 	containerHomeDir := "/root"
@@ -153,9 +271,12 @@ func (q *QwenAgent) GetMounts(hostHomeDir string, containerUser string) []Mount
 type CursorAgent struct{}
 
 func (c *CursorAgent) Name() string                { return "cursor" }
+func (c *CursorAgent) Command() string             { return "cursor-agent" }
 func (c *CursorAgent) ConfigDir() string           { return ".cursor" }
 func (c *CursorAgent) DefaultAPIKeyEnv() string    { return "CURSOR_API_KEY" } // Assuming based on pattern
 func (c *CursorAgent) RequiresSpecialHandling() bool { return false }
+func (c *CursorAgent) Install() InstallSpec          { return InstallSpec{Script: "curl -fsSL https://cursor.com/install | bash"} }
+func (c *CursorAgent) SkipPermissionsArgs() []string { return []string{"--force"} }
 
 func (c *CursorAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 	containerHomeDir := "/root"
@@ -176,9 +297,13 @@ func (c *CursorAgent) GetMounts(hostHomeDir string, containerUser string) []Moun
 type AmpAgent struct{}
 
 func (a *AmpAgent) Name() string                { return "amp" }
+func (a *AmpAgent) Command() string             { return "amp" }
 func (a *AmpAgent) ConfigDir() string           { return ".config/amp" } // Uses XDG config
 func (a *AmpAgent) DefaultAPIKeyEnv() string    { return "AMP_API_KEY" }
 func (a *AmpAgent) RequiresSpecialHandling() bool { return false }
+func (a *AmpAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@sourcegraph/amp"} }
+func (a *AmpAgent) HeadlessArgs() []string        { return []string{"-x"} }
+func (a *AmpAgent) SkipPermissionsArgs() []string { return []string{"--dangerously-allow-all"} }
 
 func (a *AmpAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 	containerHomeDir := "/root"
@@ -199,6 +324,7 @@ func (a *AmpAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
 type DeepSeekAgent struct{}
 
 func (d *DeepSeekAgent) Name() string                { return "deepseek" }
+func (d *DeepSeekAgent) Command() string             { return "deepseek" }
 func (d *DeepSeekAgent) ConfigDir() string           { return ".deepseek" }
 func (d *DeepSeekAgent) DefaultAPIKeyEnv() string    { return "DEEPSEEK_API_KEY" }
 func (d *DeepSeekAgent) RequiresSpecialHandling() bool { return false }
@@ -218,6 +344,270 @@ func (d *DeepSeekAgent) GetMounts(hostHomeDir string, containerUser string) []Mo
 	}
 }
 
+// AmazonQAgent implements Amazon Q Developer CLI requirements
+type AmazonQAgent struct{}
+
+func (a *AmazonQAgent) Name() string                { return "amazonq" }
+func (a *AmazonQAgent) Command() string             { return "q" }
+func (a *AmazonQAgent) ConfigDir() string           { return ".aws/amazonq" }
+func (a *AmazonQAgent) DefaultAPIKeyEnv() string    { return "AWS_PROFILE" } // Uses AWS SSO/IAM, not an API key
+func (a *AmazonQAgent) RequiresSpecialHandling() bool { return false }
+func (a *AmazonQAgent) Install() InstallSpec {
+	// Official installer; puts q in ~/.local/bin of the installing user
+	return InstallSpec{Script: `curl --proto '=https' --tlsv1.2 -sSf "https://desktop-release.q.us-east-1.amazonaws.com/latest/q-$(uname -m)-linux.zip" -o /tmp/q.zip && ` +
+		`unzip -q /tmp/q.zip -d /tmp && /tmp/q/install.sh --no-confirm && rm -rf /tmp/q /tmp/q.zip`}
+}
+
+func (a *AmazonQAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	return []Mount{
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".aws", "amazonq"),
+			ContainerPath: filepath.Join(containerHomeDir, ".aws", "amazonq"),
+			ReadOnly:      false,
+		},
+		{
+			// SSO token cache - written when the CLI refreshes Builder ID / IAM Identity Center tokens
+			HostPath:      filepath.Join(hostHomeDir, ".aws", "sso", "cache"),
+			ContainerPath: filepath.Join(containerHomeDir, ".aws", "sso", "cache"),
+			ReadOnly:      false,
+		},
+		{
+			// Profiles referenced by AWS_PROFILE
+			HostPath:      filepath.Join(hostHomeDir, ".aws", "config"),
+			ContainerPath: filepath.Join(containerHomeDir, ".aws", "config"),
+			ReadOnly:      true,
+		},
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".aws", "credentials"),
+			ContainerPath: filepath.Join(containerHomeDir, ".aws", "credentials"),
+			ReadOnly:      true,
+		},
+	}
+}
+
+// GetEnv passes through the AWS profile, region, and any temporary IAM credentials
+func (a *AmazonQAgent) GetEnv(hostHomeDir string, containerUser string) []string {
+	var env []string
+
+	passthrough := []string{
+		"AWS_PROFILE",
+		"AWS_REGION",
+		"AWS_DEFAULT_REGION",
+		"AWS_ACCESS_KEY_ID",
+		"AWS_SECRET_ACCESS_KEY",
+		"AWS_SESSION_TOKEN",
+	}
+	for _, key := range passthrough {
+		if value := os.Getenv(key); value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	return env
+}
+
+// AuggieAgent implements Augment Code's Auggie CLI requirements
+type AuggieAgent struct{}
+
+func (a *AuggieAgent) Name() string                { return "auggie" }
+func (a *AuggieAgent) Command() string             { return "auggie" }
+func (a *AuggieAgent) ConfigDir() string           { return ".augment" }
+func (a *AuggieAgent) DefaultAPIKeyEnv() string    { return "AUGMENT_SESSION_AUTH" } // Session JSON from `auggie tokens print`
+func (a *AuggieAgent) RequiresSpecialHandling() bool { return false }
+func (a *AuggieAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@augmentcode/auggie"} }
+
+func (a *AuggieAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	return []Mount{
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".augment"),
+			ContainerPath: filepath.Join(containerHomeDir, ".augment"),
+			ReadOnly:      false, // Session, settings, and rules
+		},
+	}
+}
+
+// GrokAgent implements xAI Grok CLI requirements
+type GrokAgent struct{}
+
+func (g *GrokAgent) Name() string                { return "grok" }
+func (g *GrokAgent) Command() string             { return "grok" }
+func (g *GrokAgent) ConfigDir() string           { return ".grok" }
+func (g *GrokAgent) DefaultAPIKeyEnv() string    { return "XAI_API_KEY" }
+func (g *GrokAgent) RequiresSpecialHandling() bool { return false }
+func (g *GrokAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@vibe-kit/grok-cli"} }
+
+func (g *GrokAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	return []Mount{
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".grok"),
+			ContainerPath: filepath.Join(containerHomeDir, ".grok"),
+			ReadOnly:      false,
+		},
+	}
+}
+
+// CrushAgent implements Charmbracelet Crush requirements
+// Crush talks to many providers; ANTHROPIC_API_KEY is only its most common one, and the
+// other provider keys are forwarded through the default env vars.
+type CrushAgent struct{}
+
+func (c *CrushAgent) Name() string                { return "crush" }
+func (c *CrushAgent) Command() string             { return "crush" }
+func (c *CrushAgent) ConfigDir() string           { return ".config/crush" } // Uses XDG config
+func (c *CrushAgent) DefaultAPIKeyEnv() string    { return "ANTHROPIC_API_KEY" }
+func (c *CrushAgent) RequiresSpecialHandling() bool { return false }
+func (c *CrushAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@charmland/crush"} }
+func (c *CrushAgent) HeadlessArgs() []string        { return []string{"run"} }
+func (c *CrushAgent) SkipPermissionsArgs() []string { return []string{"--yolo"} }
+
+func (c *CrushAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	return []Mount{
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".config", "crush"),
+			ContainerPath: filepath.Join(containerHomeDir, ".config", "crush"),
+			ReadOnly:      false,
+		},
+		{
+			// Keys entered in the TUI, the chosen models, and the provider catalog cache
+			HostPath:      filepath.Join(hostHomeDir, ".local", "share", "crush"),
+			ContainerPath: filepath.Join(containerHomeDir, ".local", "share", "crush"),
+			ReadOnly:      false,
+		},
+	}
+}
+
+// GetEnv passes through provider settings that aren't keys, and Crush's own switches
+// Crush's TUI also wants a truecolor terminal; without COLORTERM it falls back to 256 colors,
+// so the host's COLORTERM is forwarded with the other terminal settings rather than here.
+func (c *CrushAgent) GetEnv(hostHomeDir string, containerUser string) []string {
+	var env []string
+
+	passthrough := []string{
+		"AZURE_OPENAI_API_ENDPOINT",
+		"AZURE_OPENAI_API_VERSION",
+		"VERTEXAI_PROJECT",
+		"VERTEXAI_LOCATION",
+		"AWS_REGION",
+		"CRUSH_DISABLE_METRICS",
+		"CRUSH_DISABLE_PROVIDER_AUTO_UPDATE",
+	}
+	for _, key := range passthrough {
+		if value := os.Getenv(key); value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	return env
+}
+
+// ClineAgent implements Cline CLI requirements
+// The CLI keeps provider settings, secrets and task history under ~/.cline (or $CLINE_DIR),
+// so a task started on the host can be resumed in the sandbox and vice versa.
+type ClineAgent struct{}
+
+func (c *ClineAgent) Name() string                { return "cline" }
+func (c *ClineAgent) Command() string             { return "cline" }
+func (c *ClineAgent) ConfigDir() string           { return ".cline" }
+func (c *ClineAgent) DefaultAPIKeyEnv() string    { return "ANTHROPIC_API_KEY" }
+func (c *ClineAgent) RequiresSpecialHandling() bool { return false }
+func (c *ClineAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "cline"} }
+
+func (c *ClineAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	hostDir := filepath.Join(hostHomeDir, ".cline")
+	if dir := os.Getenv("CLINE_DIR"); dir != "" {
+		hostDir = dir
+	}
+
+	// Always mounted at the default location, so CLINE_DIR isn't forwarded
+	return []Mount{
+		{
+			HostPath:      hostDir,
+			ContainerPath: filepath.Join(containerHome(containerUser), ".cline"),
+			ReadOnly:      false,
+		},
+	}
+}
+
+// GetEnv passes through provider settings that aren't keys
+func (c *ClineAgent) GetEnv(hostHomeDir string, containerUser string) []string {
+	var env []string
+
+	passthrough := []string{
+		"ANTHROPIC_BASE_URL",
+		"OPENAI_BASE_URL",
+		"AWS_REGION",           // Bedrock
+		"GOOGLE_CLOUD_PROJECT", // Vertex
+		"GOOGLE_CLOUD_LOCATION",
+	}
+	for _, key := range passthrough {
+		if value := os.Getenv(key); value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	return env
+}
+
+// KilocodeAgent implements Kilo Code CLI requirements
+type KilocodeAgent struct{}
+
+func (k *KilocodeAgent) Name() string                { return "kilocode" }
+func (k *KilocodeAgent) Command() string             { return "kilocode" }
+func (k *KilocodeAgent) ConfigDir() string           { return ".kilocode" }
+func (k *KilocodeAgent) DefaultAPIKeyEnv() string    { return "KILOCODE_TOKEN" } // Kilo gateway; other providers via OPENROUTER_API_KEY etc.
+func (k *KilocodeAgent) RequiresSpecialHandling() bool { return false }
+func (k *KilocodeAgent) Install() InstallSpec          { return InstallSpec{NPMPackage: "@kilocode/cli"} }
+
+func (k *KilocodeAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	return []Mount{
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".kilocode"),
+			ContainerPath: filepath.Join(containerHomeDir, ".kilocode"),
+			ReadOnly:      false, // cli/config.json holds provider settings and keys
+		},
+	}
+}
+
+// RooAgent implements Roo Code's headless runner requirements
+// There's no published install package yet, so the image has to provide `roo` itself.
+type RooAgent struct{}
+
+func (r *RooAgent) Name() string                { return "roo" }
+func (r *RooAgent) Command() string             { return "roo" }
+func (r *RooAgent) ConfigDir() string           { return ".roo" }
+func (r *RooAgent) DefaultAPIKeyEnv() string    { return "OPENROUTER_API_KEY" }
+func (r *RooAgent) RequiresSpecialHandling() bool { return false }
+
+func (r *RooAgent) GetMounts(hostHomeDir string, containerUser string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	return []Mount{
+		{
+			HostPath:      filepath.Join(hostHomeDir, ".roo"),
+			ContainerPath: filepath.Join(containerHomeDir, ".roo"),
+			ReadOnly:      true, // Global rules and custom modes; settings come from env
+		},
+	}
+}
+
+// containerHome returns the home directory for a user inside the container
+func containerHome(containerUser string) string {
+	if containerUser == "root" {
+		return "/root"
+	}
+	return "/home/" + containerUser
+}
+
 // GetDefaultEnvVars returns default environment variables that should be proxied
 func GetDefaultEnvVars() []string {
 	return []string{
@@ -231,5 +621,15 @@ func GetDefaultEnvVars() []string {
 		"CURSOR_API_KEY",
 		"AMP_API_KEY",
 		"DEEPSEEK_API_KEY",
+		"AUGMENT_SESSION_AUTH", // Auggie
+		"AUGMENT_API_TOKEN",    // Auggie token-based auth
+		"AUGMENT_API_URL",      // Auggie tenant URL for token-based auth
+		"XAI_API_KEY",          // Grok
+		"GROK_API_KEY",         // Grok CLI fallback
+		"OPENROUTER_API_KEY",   // Crush
+		"GROQ_API_KEY",         // Crush
+		"CEREBRAS_API_KEY",     // Crush
+		"AZURE_OPENAI_API_KEY", // Crush
+		"KILOCODE_TOKEN",       // Kilo Code
 	}
 }
\ No newline at end of file
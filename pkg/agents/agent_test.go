@@ -1,6 +1,8 @@
 package agents
 
 import (
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -144,6 +146,8 @@ func TestGetDefaultEnvVars(t *testing.T) {
 		"GH_TOKEN",
 		"QWEN_API_KEY",
 		"DEEPSEEK_API_KEY",
+		"AUGMENT_SESSION_AUTH",
+		"XAI_API_KEY",
 	}
 
 	envVarMap := make(map[string]bool)
@@ -161,4 +165,304 @@ func TestGetDefaultEnvVars(t *testing.T) {
 	if len(envVars) < 6 {
 		t.Errorf("GetDefaultEnvVars() returned only %d vars, expected at least 6", len(envVars))
 	}
-}
\ No newline at end of file
+}
+func TestGeminiAgentEnterpriseAuth(t *testing.T) {
+	agent := &GeminiAgent{}
+
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/host/keys/sa.json")
+	defer os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+
+	mounts := agent.GetMounts("/home/test", "vscode")
+
+	expectedMounts := map[string]Mount{
+		"/home/test/.gemini": {
+			HostPath:      "/home/test/.gemini",
+			ContainerPath: "/home/vscode/.gemini",
+		},
+		"/home/test/.config/gcloud/application_default_credentials.json": {
+			HostPath:      "/home/test/.config/gcloud/application_default_credentials.json",
+			ContainerPath: "/home/vscode/.config/gcloud/application_default_credentials.json",
+			ReadOnly:      true,
+		},
+		"/host/keys/sa.json": {
+			HostPath:      "/host/keys/sa.json",
+			ContainerPath: "/home/vscode/.config/gcloud/packnplay-google-credentials.json",
+			ReadOnly:      true,
+		},
+	}
+
+	if len(mounts) != len(expectedMounts) {
+		t.Fatalf("GetMounts() returned %d mounts, want %d", len(mounts), len(expectedMounts))
+	}
+	for _, mount := range mounts {
+		if expected, ok := expectedMounts[mount.HostPath]; !ok || mount != expected {
+			t.Errorf("GetMounts() unexpected mount %+v", mount)
+		}
+	}
+
+	env := agent.GetEnv("/home/test", "vscode")
+	envMap := make(map[string]string)
+	for _, e := range env {
+		parts := strings.SplitN(e, "=", 2)
+		envMap[parts[0]] = parts[1]
+	}
+
+	if envMap["GOOGLE_CLOUD_PROJECT"] != "my-project" {
+		t.Errorf("GOOGLE_CLOUD_PROJECT = %q, want my-project", envMap["GOOGLE_CLOUD_PROJECT"])
+	}
+	if envMap["GOOGLE_APPLICATION_CREDENTIALS"] != "/home/vscode/.config/gcloud/packnplay-google-credentials.json" {
+		t.Errorf("GOOGLE_APPLICATION_CREDENTIALS = %q, want container path", envMap["GOOGLE_APPLICATION_CREDENTIALS"])
+	}
+}
+
+func TestAmazonQAgent(t *testing.T) {
+	agent := &AmazonQAgent{}
+
+	if agent.Name() != "amazonq" {
+		t.Errorf("Name() = %v, want amazonq", agent.Name())
+	}
+
+	if agent.Command() != "q" {
+		t.Errorf("Command() = %v, want q", agent.Command())
+	}
+
+	if agent.Install().Script == "" {
+		t.Error("Install() should provide an install script")
+	}
+
+	mounts := agent.GetMounts("/home/test", "vscode")
+	containerPaths := make(map[string]bool)
+	for _, mount := range mounts {
+		containerPaths[mount.ContainerPath] = mount.ReadOnly
+	}
+
+	expected := map[string]bool{
+		"/home/vscode/.aws/amazonq":     false,
+		"/home/vscode/.aws/sso/cache":   false,
+		"/home/vscode/.aws/config":      true,
+		"/home/vscode/.aws/credentials": true,
+	}
+	for path, readOnly := range expected {
+		got, ok := containerPaths[path]
+		if !ok {
+			t.Errorf("GetMounts() missing %s", path)
+		} else if got != readOnly {
+			t.Errorf("Mount %s ReadOnly = %v, want %v", path, got, readOnly)
+		}
+	}
+
+	os.Setenv("AWS_PROFILE", "dev-sso")
+	defer os.Unsetenv("AWS_PROFILE")
+
+	env := agent.GetEnv("/home/test", "vscode")
+	found := false
+	for _, e := range env {
+		if e == "AWS_PROFILE=dev-sso" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("GetEnv() = %v, want AWS_PROFILE=dev-sso", env)
+	}
+}
+
+func TestAuggieAgent(t *testing.T) {
+	agent := &AuggieAgent{}
+
+	if agent.Name() != "auggie" {
+		t.Errorf("Name() = %v, want auggie", agent.Name())
+	}
+
+	if agent.DefaultAPIKeyEnv() != "AUGMENT_SESSION_AUTH" {
+		t.Errorf("DefaultAPIKeyEnv() = %v, want AUGMENT_SESSION_AUTH", agent.DefaultAPIKeyEnv())
+	}
+
+	expected := Mount{
+		HostPath:      "/home/test/.augment",
+		ContainerPath: "/home/vscode/.augment",
+		ReadOnly:      false,
+	}
+	mounts := agent.GetMounts("/home/test", "vscode")
+	if len(mounts) != 1 || mounts[0] != expected {
+		t.Errorf("GetMounts() = %+v, want [%+v]", mounts, expected)
+	}
+}
+
+func TestGrokAgent(t *testing.T) {
+	agent := &GrokAgent{}
+
+	if agent.Name() != "grok" {
+		t.Errorf("Name() = %v, want grok", agent.Name())
+	}
+
+	if agent.DefaultAPIKeyEnv() != "XAI_API_KEY" {
+		t.Errorf("DefaultAPIKeyEnv() = %v, want XAI_API_KEY", agent.DefaultAPIKeyEnv())
+	}
+
+	mounts := agent.GetMounts("/home/test", "root")
+	if len(mounts) != 1 || mounts[0].ContainerPath != "/root/.grok" {
+		t.Errorf("GetMounts() for root = %+v, want /root/.grok", mounts)
+	}
+}
+
+func TestMountsFor(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	codex := &CodexAgent{}
+	minimal := MountsFor(codex, MountModeMinimal, "/home/test", "vscode")
+	want := []Mount{
+		{HostPath: "/home/test/.codex/auth.json", ContainerPath: "/home/vscode/.codex/auth.json"},
+		{HostPath: "/home/test/.codex/config.toml", ContainerPath: "/home/vscode/.codex/config.toml", ReadOnly: true},
+	}
+	if len(minimal) != len(want) || minimal[0] != want[0] || minimal[1] != want[1] {
+		t.Errorf("MountsFor(codex, minimal) = %+v, want %+v", minimal, want)
+	}
+
+	full := MountsFor(codex, MountModeFull, "/home/test", "vscode")
+	if len(full) != 1 || full[0].HostPath != "/home/test/.codex" {
+		t.Errorf("MountsFor(codex, full) = %+v, want .codex dir", full)
+	}
+
+	// Agents without a minimal file set fall back to their full mounts
+	cursor := MountsFor(&CursorAgent{}, MountModeMinimal, "/home/test", "vscode")
+	if len(cursor) != 1 || cursor[0].HostPath != "/home/test/.cursor" {
+		t.Errorf("MountsFor(cursor, minimal) = %+v, want .cursor dir", cursor)
+	}
+
+	// Gemini narrows .gemini but keeps the gcloud credential file
+	for _, mount := range MountsFor(&GeminiAgent{}, MountModeMinimal, "/home/test", "vscode") {
+		if mount.HostPath == "/home/test/.gemini" {
+			t.Error("MountsFor(gemini, minimal) still mounts the whole .gemini directory")
+		}
+	}
+}
+
+func TestRehome(t *testing.T) {
+	mounts := []Mount{
+		{HostPath: "/home/test/.codex", ContainerPath: "/home/app/.codex"},
+		{HostPath: "/home/test/.aws/config", ContainerPath: "/home/app/.aws/config", ReadOnly: true},
+		{HostPath: "/tmp/other", ContainerPath: "/home/application/x"},
+	}
+
+	got := Rehome(mounts, "app", "/var/lib/app")
+	want := []string{"/var/lib/app/.codex", "/var/lib/app/.aws/config", "/home/application/x"}
+	for i := range want {
+		if got[i].ContainerPath != want[i] {
+			t.Errorf("mount %d ContainerPath = %q, want %q", i, got[i].ContainerPath, want[i])
+		}
+	}
+	if !got[1].ReadOnly || mounts[0].ContainerPath != "/home/app/.codex" {
+		t.Error("Rehome should keep mount options and not modify its input")
+	}
+
+	env := RehomeEnv([]string{"GOOGLE_APPLICATION_CREDENTIALS=/root/.config/gcloud/creds.json", "MODE=/root-ish"}, "root", "/config")
+	if env[0] != "GOOGLE_APPLICATION_CREDENTIALS=/config/.config/gcloud/creds.json" || env[1] != "MODE=/root-ish" {
+		t.Errorf("RehomeEnv() = %v", env)
+	}
+}
+
+func TestCrushAgent(t *testing.T) {
+	t.Setenv("VERTEXAI_PROJECT", "my-project")
+	agent := &CrushAgent{}
+
+	if agent.Command() != "crush" {
+		t.Errorf("Command() = %v, want crush", agent.Command())
+	}
+
+	mounts := agent.GetMounts("/home/test", "vscode")
+	want := []string{"/home/vscode/.config/crush", "/home/vscode/.local/share/crush"}
+	if len(mounts) != len(want) {
+		t.Fatalf("GetMounts() = %+v, want %v", mounts, want)
+	}
+	for i, mount := range mounts {
+		if mount.ContainerPath != want[i] || mount.ReadOnly {
+			t.Errorf("GetMounts()[%d] = %+v, want writable %s", i, mount, want[i])
+		}
+	}
+
+	env := agent.GetEnv("/home/test", "vscode")
+	if len(env) != 1 || env[0] != "VERTEXAI_PROJECT=my-project" {
+		t.Errorf("GetEnv() = %v, want [VERTEXAI_PROJECT=my-project]", env)
+	}
+
+	if found := Lookup("crush"); found == nil || found.Name() != "crush" {
+		t.Errorf("Lookup(crush) didn't find the Crush agent")
+	}
+}
+
+func TestClineAgent(t *testing.T) {
+	agent := &ClineAgent{}
+
+	t.Setenv("CLINE_DIR", "")
+	mounts := agent.GetMounts("/home/test", "vscode")
+	expected := Mount{HostPath: "/home/test/.cline", ContainerPath: "/home/vscode/.cline"}
+	if len(mounts) != 1 || mounts[0] != expected {
+		t.Errorf("GetMounts() = %+v, want [%+v]", mounts, expected)
+	}
+
+	// A custom CLINE_DIR on the host still lands at the default path in the container
+	t.Setenv("CLINE_DIR", "/srv/cline")
+	mounts = agent.GetMounts("/home/test", "vscode")
+	if len(mounts) != 1 || mounts[0].HostPath != "/srv/cline" || mounts[0].ContainerPath != "/home/vscode/.cline" {
+		t.Errorf("GetMounts() with CLINE_DIR = %+v, want /srv/cline at /home/vscode/.cline", mounts)
+	}
+
+	t.Setenv("AWS_REGION", "us-west-2")
+	env := agent.GetEnv("/home/test", "vscode")
+	found := false
+	for _, e := range env {
+		found = found || e == "AWS_REGION=us-west-2"
+	}
+	if !found {
+		t.Errorf("GetEnv() = %v, want AWS_REGION=us-west-2", env)
+	}
+}
+
+func TestKilocodeAndRooAgents(t *testing.T) {
+	kilo := &KilocodeAgent{}
+	mounts := kilo.GetMounts("/home/test", "vscode")
+	if len(mounts) != 1 || mounts[0].ContainerPath != "/home/vscode/.kilocode" || mounts[0].ReadOnly {
+		t.Errorf("KilocodeAgent.GetMounts() = %+v, want writable /home/vscode/.kilocode", mounts)
+	}
+
+	roo := &RooAgent{}
+	if roo.DefaultAPIKeyEnv() != "OPENROUTER_API_KEY" {
+		t.Errorf("RooAgent.DefaultAPIKeyEnv() = %v, want OPENROUTER_API_KEY", roo.DefaultAPIKeyEnv())
+	}
+	mounts = roo.GetMounts("/home/test", "root")
+	if len(mounts) != 1 || mounts[0].ContainerPath != "/root/.roo" || !mounts[0].ReadOnly {
+		t.Errorf("RooAgent.GetMounts() = %+v, want read-only /root/.roo", mounts)
+	}
+
+	// Roo has no install package, so it can't be baked into an agent layer
+	if _, ok := Agent(roo).(Installable); ok {
+		t.Errorf("RooAgent shouldn't be Installable")
+	}
+}
+
+func TestTelemetryOptOutEnv(t *testing.T) {
+	env := strings.Join(TelemetryOptOutEnv(), " ")
+	for _, want := range []string{"DO_NOT_TRACK=1", "DISABLE_TELEMETRY=1", "CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC=1", "GEMINI_TELEMETRY_ENABLED=false", "CRUSH_DISABLE_METRICS=1"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("TelemetryOptOutEnv() = %s, missing %s", env, want)
+		}
+	}
+}
+
+func TestOwnMounts(t *testing.T) {
+	q := &AmazonQAgent{}
+	own := OwnMounts(q, q.GetMounts("/home/dev", "vscode"), "/home/dev")
+	if len(own) != 1 || own[0].HostPath != "/home/dev/.aws/amazonq" {
+		t.Errorf("OwnMounts(amazonq) = %+v; want only ~/.aws/amazonq", own)
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/home/dev/keys/sa.json")
+	g := &GeminiAgent{}
+	for _, mount := range OwnMounts(g, g.GetMounts("/home/dev", "vscode"), "/home/dev") {
+		if mount.HostPath != "/home/dev/.gemini" {
+			t.Errorf("OwnMounts(gemini) kept %s", mount.HostPath)
+		}
+	}
+}
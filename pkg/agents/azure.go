@@ -0,0 +1,74 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used when a profile doesn't set one
+const DefaultAzureAPIVersion = "2025-04-01-preview"
+
+// AzureOpenAI is an Azure OpenAI resource to use in place of api.openai.com
+type AzureOpenAI struct {
+	Endpoint   string // https://<resource>.openai.azure.com
+	Deployment string // the model deployment's name, which Azure uses in place of a model name
+	APIVersion string
+	APIKey     string
+}
+
+// AzureConfigurable is implemented by agents that can talk to Azure OpenAI once told where,
+// through environment variables and arguments to their CLI
+type AzureConfigurable interface {
+	AzureOpenAI(azure AzureOpenAI) (env []string, args []string)
+}
+
+// AzureEnv returns the variables the OpenAI SDKs' Azure clients read, for any agent or tool
+func AzureEnv(azure AzureOpenAI) []string {
+	return []string{
+		"AZURE_OPENAI_ENDPOINT=" + azure.Endpoint,
+		"AZURE_OPENAI_API_KEY=" + azure.APIKey,
+		"AZURE_OPENAI_DEPLOYMENT=" + azure.Deployment,
+		"AZURE_OPENAI_API_VERSION=" + azure.APIVersion,
+		"OPENAI_API_VERSION=" + azure.APIVersion,
+	}
+}
+
+// AzureOpenAI adds an azure model provider through config overrides, since Codex only reads
+// providers from config.toml; the key stays in the environment
+func (c *CodexAgent) AzureOpenAI(azure AzureOpenAI) ([]string, []string) {
+	provider := fmt.Sprintf(`model_providers.azure={name="Azure OpenAI",base_url="%s/openai",env_key="AZURE_OPENAI_API_KEY",query_params={api-version="%s"},wire_api="responses"}`,
+		azure.Endpoint, azure.APIVersion)
+	return nil, []string{"-c", "model_provider=azure", "-c", provider, "-m", azure.Deployment}
+}
+
+// AzureOpenAI sets Crush's own names for the endpoint and version; the deployment is picked
+// as a model in Crush
+func (c *CrushAgent) AzureOpenAI(azure AzureOpenAI) ([]string, []string) {
+	return []string{
+		"AZURE_OPENAI_API_ENDPOINT=" + azure.Endpoint,
+		"AZURE_OPENAI_API_VERSION=" + azure.APIVersion,
+	}, nil
+}
+
+// AzureOpenAI points Qwen Code's OpenAI-compatible client at the resource's v1 API
+func (q *QwenAgent) AzureOpenAI(azure AzureOpenAI) ([]string, []string) {
+	return []string{
+		"OPENAI_BASE_URL=" + azure.Endpoint + "/openai/v1",
+		"OPENAI_API_KEY=" + azure.APIKey,
+		"OPENAI_MODEL=" + azure.Deployment,
+	}, nil
+}
+
+// ValidateAzure checks the settings an Azure OpenAI profile needs
+func ValidateAzure(azure AzureOpenAI) error {
+	if !strings.HasPrefix(azure.Endpoint, "https://") {
+		return fmt.Errorf("azure_openai.endpoint must be an https:// URL, e.g. https://my-resource.openai.azure.com")
+	}
+	if azure.Deployment == "" {
+		return fmt.Errorf("azure_openai.deployment is required")
+	}
+	if azure.APIKey == "" {
+		return fmt.Errorf("azure_openai.api_key is empty (is AZURE_OPENAI_API_KEY set?)")
+	}
+	return nil
+}
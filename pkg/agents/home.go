@@ -0,0 +1,44 @@
+package agents
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Rehome moves mounts from the conventional home for containerUser to the image's real home
+// Agents build container paths as /home/<user> (or /root); images whose users live
+// elsewhere (e.g. /var/lib/app or /config) need them rewritten.
+func Rehome(mounts []Mount, containerUser, home string) []Mount {
+	rehomed := make([]Mount, len(mounts))
+	for i, mount := range mounts {
+		mount.ContainerPath = rehomePath(mount.ContainerPath, containerHome(containerUser), home)
+		rehomed[i] = mount
+	}
+	return rehomed
+}
+
+// RehomeEnv rewrites KEY=path env vars the same way as Rehome
+func RehomeEnv(env []string, containerUser, home string) []string {
+	rehomed := make([]string, len(env))
+	for i, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			kv = key + "=" + rehomePath(value, containerHome(containerUser), home)
+		}
+		rehomed[i] = kv
+	}
+	return rehomed
+}
+
+// rehomePath swaps the from prefix of path for to, leaving other paths alone
+func rehomePath(path, from, to string) string {
+	if from == to {
+		return path
+	}
+	if path == from {
+		return to
+	}
+	if rel, ok := strings.CutPrefix(path, from+"/"); ok {
+		return filepath.Join(to, rel)
+	}
+	return path
+}
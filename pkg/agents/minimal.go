@@ -0,0 +1,98 @@
+package agents
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Agent mount modes, selectable per agent in config
+const (
+	MountModeFull    = "full"    // the agent's whole config directory
+	MountModeMinimal = "minimal" // only credential and settings files
+)
+
+// MountsFor returns the agent's mounts for a mount mode
+// Agents without a minimal file set always get their full mounts.
+func MountsFor(agent Agent, mode, hostHomeDir, containerUser string) []Mount {
+	if mode == MountModeMinimal {
+		if minimal, ok := agent.(MinimalMounter); ok {
+			return minimal.GetMinimalMounts(hostHomeDir, containerUser)
+		}
+	}
+	return agent.GetMounts(hostHomeDir, containerUser)
+}
+
+// homeFiles mounts individual files from the home directory
+// Credential files stay writable so agents can refresh tokens; settings are read-only.
+func homeFiles(hostHomeDir, containerUser string, writable, readOnly []string) []Mount {
+	containerHomeDir := containerHome(containerUser)
+
+	var mounts []Mount
+	for _, rel := range writable {
+		mounts = append(mounts, Mount{
+			HostPath:      filepath.Join(hostHomeDir, rel),
+			ContainerPath: filepath.Join(containerHomeDir, rel),
+		})
+	}
+	for _, rel := range readOnly {
+		mounts = append(mounts, Mount{
+			HostPath:      filepath.Join(hostHomeDir, rel),
+			ContainerPath: filepath.Join(containerHomeDir, rel),
+			ReadOnly:      true,
+		})
+	}
+	return mounts
+}
+
+func (c *CodexAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	return homeFiles(hostHomeDir, containerUser, []string{".codex/auth.json"}, []string{".codex/config.toml"})
+}
+
+func (g *GeminiAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	mounts := homeFiles(hostHomeDir, containerUser,
+		[]string{".gemini/oauth_creds.json", ".gemini/google_accounts.json"},
+		[]string{".gemini/settings.json"})
+
+	// Keep the gcloud credential file mounts; only the .gemini directory is narrowed
+	geminiDir := filepath.Join(hostHomeDir, ".gemini")
+	for _, mount := range g.GetMounts(hostHomeDir, containerUser) {
+		if mount.HostPath != geminiDir {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+func (c *CopilotAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	return homeFiles(hostHomeDir, containerUser, []string{".copilot/config.json"}, nil)
+}
+
+func (q *QwenAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	return homeFiles(hostHomeDir, containerUser, []string{".qwen/oauth_creds.json"}, []string{".qwen/settings.json"})
+}
+
+func (a *AuggieAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	return homeFiles(hostHomeDir, containerUser, []string{".augment/session.json"}, []string{".augment/settings.json"})
+}
+
+func (g *GrokAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	return homeFiles(hostHomeDir, containerUser, nil, []string{".grok/user-settings.json"})
+}
+
+func (c *CrushAgent) GetMinimalMounts(hostHomeDir string, containerUser string) []Mount {
+	return homeFiles(hostHomeDir, containerUser, []string{".local/share/crush/crush.json"}, []string{".config/crush/crush.json"})
+}
+
+// OwnMounts keeps the mounts inside an agent's config dir. The rest are credentials shared
+// with other tools, like ~/.aws or gcloud's application default credentials, which only
+// sessions running the agent get.
+func OwnMounts(agent Agent, mounts []Mount, hostHomeDir string) []Mount {
+	configDir := filepath.Join(hostHomeDir, agent.ConfigDir())
+	var own []Mount
+	for _, mount := range mounts {
+		if mount.HostPath == configDir || strings.HasPrefix(mount.HostPath, configDir+string(filepath.Separator)) {
+			own = append(own, mount)
+		}
+	}
+	return own
+}
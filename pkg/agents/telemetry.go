@@ -0,0 +1,36 @@
+package agents
+
+// TelemetryOptOut is implemented by agents with documented switches that turn off
+// telemetry and other reporting to the vendor
+type TelemetryOptOut interface {
+	TelemetryOptOutEnv() []string
+}
+
+// doNotTrack is the cross-tool convention (consoledonottrack.com) some CLIs honor
+const doNotTrack = "DO_NOT_TRACK=1"
+
+// CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC also covers the autoupdater and /bug;
+// the narrower switches are set too so older releases are covered.
+func (c *ClaudeAgent) TelemetryOptOutEnv() []string {
+	return []string{"DISABLE_TELEMETRY=1", "DISABLE_ERROR_REPORTING=1", "CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC=1"}
+}
+
+func (g *GeminiAgent) TelemetryOptOutEnv() []string {
+	return []string{"GEMINI_TELEMETRY_ENABLED=false"}
+}
+
+func (c *CrushAgent) TelemetryOptOutEnv() []string {
+	return []string{"CRUSH_DISABLE_METRICS=1"}
+}
+
+// TelemetryOptOutEnv returns every supported agent's opt-out settings, plus DO_NOT_TRACK
+// All agents are covered, not just the one launched, since others can be started from a shell.
+func TelemetryOptOutEnv() []string {
+	env := []string{doNotTrack}
+	for _, agent := range GetSupportedAgents() {
+		if optOut, ok := agent.(TelemetryOptOut); ok {
+			env = append(env, optOut.TelemetryOptOutEnv()...)
+		}
+	}
+	return env
+}
@@ -0,0 +1,214 @@
+package artifacts
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gitpkg "github.com/obra/packnplay/pkg/git"
+)
+
+// Storage backends, by destination URL scheme
+const (
+	SchemeS3    = "s3" // s3://bucket/prefix, uploaded with the aws CLI
+	SchemeGCS   = "gs" // gs://bucket/prefix, uploaded with the gcloud CLI
+	SchemeAzure = "az" // az://account/container/prefix, uploaded with the az CLI
+)
+
+// DiffFile is the name of the session's change set in the uploaded artifacts
+const DiffFile = "session.diff"
+
+// Destination is where a session's artifacts are uploaded
+type Destination struct {
+	Scheme  string
+	Bucket  string // S3/GCS bucket, or Azure blob container
+	Account string // Azure storage account
+	Prefix  string
+}
+
+// ParseDestination parses an s3://, gs:// or az:// destination URL
+func ParseDestination(url string) (Destination, error) {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return Destination{}, fmt.Errorf("invalid artifacts destination '%s' (use s3://, gs:// or az://)", url)
+	}
+	parts := strings.SplitN(strings.Trim(rest, "/"), "/", 3)
+
+	dest := Destination{Scheme: scheme}
+	switch scheme {
+	case SchemeS3, SchemeGCS:
+		dest.Bucket = parts[0]
+		dest.Prefix = strings.Join(parts[1:], "/")
+	case SchemeAzure:
+		if len(parts) < 2 {
+			return Destination{}, fmt.Errorf("invalid artifacts destination '%s' (use az://account/container/prefix)", url)
+		}
+		dest.Account, dest.Bucket = parts[0], parts[1]
+		if len(parts) == 3 {
+			dest.Prefix = parts[2]
+		}
+	default:
+		return Destination{}, fmt.Errorf("unsupported artifacts destination '%s' (use s3://, gs:// or az://)", url)
+	}
+	if dest.Bucket == "" {
+		return Destination{}, fmt.Errorf("invalid artifacts destination '%s': no bucket", url)
+	}
+	return dest, nil
+}
+
+// SessionPrefix is where one session's artifacts go: <prefix>/<container>/<timestamp>
+func (d Destination) SessionPrefix(containerName string, now time.Time) string {
+	return path.Join(d.Prefix, containerName, now.UTC().Format("20060102-150405"))
+}
+
+// String formats the destination of a session prefix as a URL
+func (d Destination) String(prefix string) string {
+	if d.Scheme == SchemeAzure {
+		return fmt.Sprintf("az://%s/%s/%s", d.Account, d.Bucket, prefix)
+	}
+	return fmt.Sprintf("%s://%s/%s", d.Scheme, d.Bucket, prefix)
+}
+
+// UploadCommand returns the CLI invocation that uploads dir's contents under prefix
+// The provider CLIs handle credentials (profiles, workload identity, az login) themselves.
+func UploadCommand(d Destination, dir, prefix string) (string, []string) {
+	switch d.Scheme {
+	case SchemeGCS:
+		return "gcloud", []string{"storage", "rsync", "--recursive", dir, d.String(prefix)}
+	case SchemeAzure:
+		return "az", []string{"storage", "blob", "upload-batch", "--account-name", d.Account,
+			"--destination", d.Bucket, "--destination-path", prefix, "--source", dir, "--auth-mode", "login", "--only-show-errors"}
+	default:
+		return "aws", []string{"s3", "sync", "--no-progress", dir, d.String(prefix)}
+	}
+}
+
+// Upload uploads dir's contents under prefix
+func Upload(d Destination, dir, prefix string) error {
+	name, args := UploadCommand(d, dir, prefix)
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to upload artifacts to %s: %w\n%s", d.String(prefix), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// CopyFromContainer copies a container path into a host path (docker cp)
+type CopyFromContainer func(containerPath, hostPath string) error
+
+// Collect gathers the declared paths into stageDir, returning the ones it found
+// Relative paths and paths under /workspace are read from the host workspace (globs allowed);
+// other absolute paths are copied out of the container.
+func Collect(stageDir, workspace string, paths []string, copyOut CopyFromContainer) ([]string, error) {
+	var collected []string
+	for _, p := range paths {
+		if rel, ok := strings.CutPrefix(p, "/workspace/"); ok {
+			p = rel
+		}
+
+		if filepath.IsAbs(p) {
+			dst := filepath.Join(stageDir, "container", filepath.Clean(p))
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return nil, fmt.Errorf("failed to stage %s: %w", p, err)
+			}
+			if err := copyOut(p, dst); err == nil {
+				collected = append(collected, p)
+			}
+			continue
+		}
+
+		if workspace == "" {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(workspace, p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact path '%s': %w", p, err)
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(workspace, match)
+			if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+				continue // patterns can't reach outside the workspace
+			}
+			if err := copyTree(match, filepath.Join(stageDir, "workspace", rel)); err != nil {
+				return nil, err
+			}
+			collected = append(collected, rel)
+		}
+	}
+	return collected, nil
+}
+
+// copyTree copies a file or directory, skipping symlinks so nothing outside is pulled in
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", p, err)
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return fmt.Errorf("failed to stage %s: %w", p, err)
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to stage %s: %w", p, err)
+		}
+		return out.Close()
+	})
+}
+
+// WriteDiff writes everything that changed in the workspace since base (committed,
+// uncommitted and untracked files) to stageDir/session.diff. A throwaway index is used,
+// so the workspace's own staging area is left alone.
+func WriteDiff(stageDir, workspace, base string) error {
+	if base == "" {
+		base = "HEAD"
+	}
+	indexDir, err := os.MkdirTemp("", "packnplay-artifacts-index-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	defer os.RemoveAll(indexDir)
+	index := filepath.Join(indexDir, "index")
+
+	git := func(args ...string) ([]byte, error) {
+		cmd, err := gitpkg.Command(workspace, args...)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, "GIT_INDEX_FILE="+index)
+		return cmd.Output()
+	}
+	if _, err := git("add", "--all"); err != nil {
+		return fmt.Errorf("failed to stage workspace changes: %w", err)
+	}
+	diff, err := git("diff", "--cached", "--binary", base)
+	if err != nil {
+		return fmt.Errorf("failed to diff workspace against %s: %w", base, err)
+	}
+	if err := os.WriteFile(filepath.Join(stageDir, DiffFile), diff, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", DiffFile, err)
+	}
+	return nil
+}
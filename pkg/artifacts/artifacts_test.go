@@ -0,0 +1,142 @@
+package artifacts
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    Destination
+		wantErr bool
+	}{
+		{url: "s3://bucket/ci/runs", want: Destination{Scheme: "s3", Bucket: "bucket", Prefix: "ci/runs"}},
+		{url: "gs://bucket", want: Destination{Scheme: "gs", Bucket: "bucket"}},
+		{url: "az://acct/outputs/nightly/", want: Destination{Scheme: "az", Account: "acct", Bucket: "outputs", Prefix: "nightly"}},
+		{url: "az://acct", wantErr: true},
+		{url: "s3://", wantErr: true},
+		{url: "ftp://host/path", wantErr: true},
+		{url: "bucket/path", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDestination(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDestination(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDestination(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSessionPrefixAndUploadCommand(t *testing.T) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	s3, _ := ParseDestination("s3://bucket/ci")
+	prefix := s3.SessionPrefix("packnplay-app-main", now)
+	if prefix != "ci/packnplay-app-main/20260304-050607" {
+		t.Errorf("SessionPrefix() = %q", prefix)
+	}
+	name, args := UploadCommand(s3, "/stage", prefix)
+	if name != "aws" || args[len(args)-1] != "s3://bucket/ci/packnplay-app-main/20260304-050607" {
+		t.Errorf("UploadCommand(s3) = %s %v", name, args)
+	}
+
+	gs, _ := ParseDestination("gs://bucket")
+	name, args = UploadCommand(gs, "/stage", gs.SessionPrefix("c", now))
+	if name != "gcloud" || args[len(args)-1] != "gs://bucket/c/20260304-050607" {
+		t.Errorf("UploadCommand(gs) = %s %v", name, args)
+	}
+
+	az, _ := ParseDestination("az://acct/outputs")
+	name, args = UploadCommand(az, "/stage", az.SessionPrefix("c", now))
+	joined := strings.Join(args, " ")
+	if name != "az" || !strings.Contains(joined, "--account-name acct --destination outputs --destination-path c/20260304-050607") {
+		t.Errorf("UploadCommand(az) = %s %v", name, args)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	workspace := t.TempDir()
+	stage := t.TempDir()
+	os.MkdirAll(filepath.Join(workspace, "dist", "sub"), 0755)
+	os.WriteFile(filepath.Join(workspace, "dist", "sub", "app.bin"), []byte("bin"), 0644)
+	os.WriteFile(filepath.Join(workspace, "report.xml"), []byte("<ok/>"), 0644)
+	os.Symlink("/etc/passwd", filepath.Join(workspace, "dist", "link"))
+
+	var copied []string
+	copyOut := func(containerPath, hostPath string) error {
+		if containerPath == "/missing" {
+			return errors.New("no such file")
+		}
+		copied = append(copied, containerPath)
+		return os.WriteFile(hostPath, []byte("from container"), 0644)
+	}
+
+	got, err := Collect(stage, workspace, []string{"dist", "/workspace/*.xml", "/tmp/coverage.out", "/missing", "absent/*", "../*"}, copyOut)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := []string{"dist", "report.xml", "/tmp/coverage.out"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Collect() = %v, want %v", got, want)
+	}
+
+	for _, path := range []string{"workspace/dist/sub/app.bin", "workspace/report.xml", "container/tmp/coverage.out"} {
+		if _, err := os.Stat(filepath.Join(stage, path)); err != nil {
+			t.Errorf("expected %s to be staged: %v", path, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(stage, "workspace", "dist", "link")); err == nil {
+		t.Error("symlinks should not be staged")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workspace := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", workspace, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("one\n"), 0644)
+	git("add", "a.txt")
+	git("commit", "-qm", "start")
+	start := git("rev-parse", "HEAD")
+
+	// A commit made during the session, an uncommitted edit and a new file
+	os.WriteFile(filepath.Join(workspace, "b.txt"), []byte("committed\n"), 0644)
+	git("add", "b.txt")
+	git("commit", "-qm", "agent work")
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("two\n"), 0644)
+	os.WriteFile(filepath.Join(workspace, "c.txt"), []byte("untracked\n"), 0644)
+
+	stage := t.TempDir()
+	if err := WriteDiff(stage, workspace, start); err != nil {
+		t.Fatalf("WriteDiff() error = %v", err)
+	}
+	diff, _ := os.ReadFile(filepath.Join(stage, DiffFile))
+	for _, want := range []string{"+two", "b/b.txt", "b/c.txt"} {
+		if !strings.Contains(string(diff), want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+
+	if staged := git("diff", "--cached", "--name-only"); staged != "" {
+		t.Errorf("workspace index was modified: %s", staged)
+	}
+}
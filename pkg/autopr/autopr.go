@@ -0,0 +1,240 @@
+package autopr
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/ci"
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// Provider is the code host a pull request is opened on
+type Provider string
+
+const (
+	GitHub    Provider = "github"    // via the gh CLI
+	GitLab    Provider = "gitlab"    // via the glab CLI, or push options in CI
+	Bitbucket Provider = "bitbucket" // via the REST API with BITBUCKET_ACCESS_TOKEN
+)
+
+// maxSlugLength keeps branch names readable in PR lists
+const maxSlugLength = 48
+
+var (
+	nonSlugChars    = regexp.MustCompile(`[^a-z0-9]+`)
+	mergeRequestURL = regexp.MustCompile(`https://\S+/-/merge_requests/\d+`)
+)
+
+// Options describes the work being published
+type Options struct {
+	Task         string  // what the agent was asked to do; names the branch and PR
+	Summary      string  // the latest summary of the session's transcript, if it has one
+	Verification string  // how the change was verified, for the PR description
+	Review       string  // what reviewers said, for the PR description
+	Remote       string  // defaults to origin
+	CI           *ci.Env // when set, push and open the request with the job's credentials
+}
+
+// BranchName derives a branch name from the task, e.g. "packnplay/fix-login-redirect"
+func BranchName(task string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(task), "-"), "-")
+	if len(slug) > maxSlugLength {
+		// Cut at a word boundary rather than mid-word
+		slug = slug[:maxSlugLength]
+		if idx := strings.LastIndex(slug, "-"); idx > 0 {
+			slug = slug[:idx]
+		}
+	}
+	if slug == "" {
+		slug = "changes"
+	}
+	return "packnplay/" + slug
+}
+
+// CommitMessage generates the commit message for the agent's changes
+func CommitMessage(task, diffStat string) string {
+	return fmt.Sprintf("%s\n\nChanges made in a packnplay sandbox.\n\n%s\n", task, diffStat)
+}
+
+// Description generates the pull request body
+func Description(opts Options, diffStat string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Task\n\n%s\n\n", opts.Task)
+	if opts.Summary != "" {
+		fmt.Fprintf(&b, "## Session Summary\n\n%s\n\n", opts.Summary)
+	}
+	if opts.Verification != "" {
+		fmt.Fprintf(&b, "## Verification\n\n%s\n\n", opts.Verification)
+	}
+	if opts.Review != "" {
+		fmt.Fprintf(&b, "## Review\n\n%s\n\n", opts.Review)
+	}
+	fmt.Fprintf(&b, "## Changes\n\n```\n%s\n```\n\n", diffStat)
+	b.WriteString("_Opened automatically by packnplay._\n")
+	return b.String()
+}
+
+// DetectProvider picks the code host from a remote URL's host: github.com, gitlab.com or
+// bitbucket.org, or a self-hosted server named for its software (gitlab.example.com)
+func DetectProvider(remoteURL string) (Provider, error) {
+	host := strings.ToLower(RemoteHost(remoteURL))
+	label, _, _ := strings.Cut(host, ".")
+	switch {
+	case host == "github.com" || label == "github":
+		return GitHub, nil
+	case host == "gitlab.com" || label == "gitlab":
+		return GitLab, nil
+	case host == "bitbucket.org":
+		return Bitbucket, nil
+	}
+	return "", fmt.Errorf("can't tell whether %s is GitHub, GitLab or Bitbucket", remoteURL)
+}
+
+// RemoteHost returns the host of a remote URL, either a URL (https://, ssh://) or the
+// scp-like user@host:path form; "" if it has none, as for a local path
+func RemoteHost(remoteURL string) string {
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+	// scp-like syntax only applies when there's no slash before the first colon
+	before, _, ok := strings.Cut(remoteURL, ":")
+	if !ok || strings.Contains(before, "/") {
+		return ""
+	}
+	if _, host, ok := strings.Cut(before, "@"); ok {
+		return host
+	}
+	return before
+}
+
+// requestArgs builds the CLI invocation that opens the pull/merge request
+func requestArgs(provider Provider, branch, title, body string) []string {
+	if provider == GitLab {
+		return []string{"glab", "mr", "create", "--source-branch", branch, "--title", title, "--description", body, "--yes"}
+	}
+	return []string{"gh", "pr", "create", "--head", branch, "--title", title, "--body", body}
+}
+
+// gitLabPushOptions ask GitLab to open the merge request as part of the push
+// This only needs push access, so it works with a CI job token; push options can't
+// contain newlines, so the description is a single line.
+func gitLabPushOptions(opts Options) []string {
+	description := "Opened automatically by packnplay."
+	if opts.Summary != "" {
+		description = "Session summary: " + opts.Summary + ". " + description
+	}
+	if opts.Verification != "" {
+		description = "Verification: " + opts.Verification + ". " + description
+	}
+	return []string{
+		"merge_request.create",
+		"merge_request.title=" + opts.Task,
+		"merge_request.description=" + strings.ReplaceAll(description, "\n", " "),
+		"merge_request.remove_source_branch",
+	}
+}
+
+// Open publishes the session's work at path since base, the commit it started from: its
+// commits plus anything left uncommitted, committed on top without touching the checked-out
+// branch, index or files. It pushes that to a new branch and opens a pull request,
+// returning its URL.
+func Open(path, base string, opts Options) (string, error) {
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+	getenv := os.Getenv
+	if opts.CI != nil {
+		getenv = opts.CI.Getenv
+	}
+	if base == "" {
+		base = "HEAD" // sessions started before the start commit was recorded
+	}
+
+	remoteURL, err := git.GetRemoteURL(path, opts.Remote)
+	if err != nil {
+		return "", err
+	}
+	provider, err := DetectProvider(remoteURL)
+	if err != nil {
+		return "", err
+	}
+
+	// What the agent left uncommitted goes in one more commit, made from a scratch index
+	tree, err := git.SnapshotTree(path)
+	if err != nil {
+		return "", err
+	}
+	uncommitted, err := git.DiffStat(path, "HEAD", tree)
+	if err != nil {
+		return "", err
+	}
+	commit := "HEAD"
+	if uncommitted != "" {
+		if commit, err = git.CommitTree(path, tree, "HEAD", CommitMessage(opts.Task, uncommitted)); err != nil {
+			return "", err
+		}
+	}
+	diffStat, err := git.DiffStat(path, base, commit)
+	if err != nil {
+		return "", err
+	}
+	if diffStat == "" {
+		return "", fmt.Errorf("no changes to publish in %s", path)
+	}
+	branch := BranchName(opts.Task)
+
+	// CI checkouts often have read-only or credential-less remotes; push with the job token instead
+	pushTarget, authorization := opts.Remote, ""
+	if opts.CI != nil {
+		if target, ok := opts.CI.PushTarget(); ok {
+			pushTarget, authorization = target.URL, target.Authorization
+		}
+	}
+
+	// In GitLab CI without an API token, the push itself opens the merge request
+	var pushOptions []string
+	useGitLabPush := provider == GitLab && opts.CI != nil && getenv("GITLAB_TOKEN") == ""
+	if useGitLabPush {
+		pushOptions = gitLabPushOptions(opts)
+	}
+
+	pushOutput, err := git.Push(path, pushTarget, commit, branch, authorization, pushOptions...)
+	if err != nil {
+		return "", err
+	}
+
+	body := Description(opts, diffStat)
+	switch {
+	case useGitLabPush:
+		if url := mergeRequestURL.FindString(pushOutput); url != "" {
+			return url, nil
+		}
+		return "", fmt.Errorf("pushed %s but GitLab didn't report a merge request:\n%s", branch, pushOutput)
+	case provider == Bitbucket:
+		repo := getenv("BITBUCKET_REPO_FULL_NAME")
+		if repo == "" {
+			repo = bitbucketRepo(remoteURL)
+		}
+		return createBitbucketPR(getenv("BITBUCKET_ACCESS_TOKEN"), repo, branch, opts.Task, body)
+	}
+
+	args := requestArgs(provider, branch, opts.Task, body)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = path
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s request with %s: %w\n%s", provider, args[0], err, output)
+	}
+
+	// Both CLIs print the new request's URL last
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
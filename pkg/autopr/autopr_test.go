@@ -0,0 +1,132 @@
+package autopr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBranchName(t *testing.T) {
+	tests := []struct {
+		task string
+		want string
+	}{
+		{"Fix login redirect loop", "packnplay/fix-login-redirect-loop"},
+		{"  Add `--json` output!! ", "packnplay/add-json-output"},
+		{"???", "packnplay/changes"},
+		{strings.Repeat("long task name ", 10), "packnplay/long-task-name-long-task-name-long-task-name"},
+	}
+
+	for _, tt := range tests {
+		if got := BranchName(tt.task); got != tt.want {
+			t.Errorf("BranchName(%q) = %q, want %q", tt.task, got, tt.want)
+		}
+	}
+}
+
+func TestDetectProvider(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    Provider
+		wantErr bool
+	}{
+		{"git@github.com:obra/packnplay.git", GitHub, false},
+		{"https://gitlab.example.com/team/app.git", GitLab, false},
+		{"https://bitbucket.org/team/app.git", Bitbucket, false},
+		{"git@example.com:team/app.git", "", true},
+		{"ssh://git@gitlab.com:2222/team/app.git", GitLab, false},
+		{"https://github.example.com/team/app.git", GitHub, false},
+		{"git@example.com:github/app.git", "", true},
+		{"https://mygithub.com/team/app.git", "", true},
+		{"https://bitbucket.org.evil.com/team/app.git", "", true},
+		{"/srv/git/github.git", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectProvider(tt.url)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("DetectProvider(%q) = %q, %v; want %q, error %v", tt.url, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDescription(t *testing.T) {
+	body := Description(Options{Task: "Fix bug", Summary: "Fixing the redirect in auth.go", Verification: "`make test` passed across 2 shard(s)", Review: "- review-bot: approve"}, " a.go | 2 +-")
+
+	for _, want := range []string{"## Task\n\nFix bug", "## Session Summary\n\nFixing the redirect", "## Verification\n\n`make test` passed", "## Review\n\n- review-bot: approve", "```\n a.go | 2 +-\n```"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Description() missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestRequestArgs(t *testing.T) {
+	gh := strings.Join(requestArgs(GitHub, "packnplay/x", "T", "B"), " ")
+	if gh != "gh pr create --head packnplay/x --title T --body B" {
+		t.Errorf("GitHub args = %s", gh)
+	}
+
+	glab := strings.Join(requestArgs(GitLab, "packnplay/x", "T", "B"), " ")
+	if glab != "glab mr create --source-branch packnplay/x --title T --description B --yes" {
+		t.Errorf("GitLab args = %s", glab)
+	}
+}
+
+func TestGitLabPushOptions(t *testing.T) {
+	options := gitLabPushOptions(Options{Task: "Fix bug", Verification: "`make test`\npassed"})
+
+	if options[0] != "merge_request.create" || options[1] != "merge_request.title=Fix bug" {
+		t.Errorf("gitLabPushOptions() = %v", options)
+	}
+	for _, option := range options {
+		if strings.Contains(option, "\n") {
+			t.Errorf("push option contains a newline: %q", option)
+		}
+	}
+}
+
+func TestBitbucketRepo(t *testing.T) {
+	for url, want := range map[string]string{
+		"git@bitbucket.org:team/app.git":          "team/app",
+		"https://user@bitbucket.org/team/app.git": "team/app",
+		"https://bitbucket.org/team/app":          "team/app",
+		"https://github.com/team/app.git":         "",
+	} {
+		if got := bitbucketRepo(url); got != want {
+			t.Errorf("bitbucketRepo(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestCreateBitbucketPR(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repositories/team/app/pullrequests" || r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["title"] != "Fix bug" {
+			t.Errorf("unexpected body %v (%v)", body, err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"links": {"html": {"href": "https://bitbucket.org/team/app/pull-requests/7"}}}`))
+	}))
+	defer server.Close()
+
+	original := bitbucketAPI
+	bitbucketAPI = server.URL
+	defer func() { bitbucketAPI = original }()
+
+	url, err := createBitbucketPR("tok", "team/app", "packnplay/fix-bug", "Fix bug", "body")
+	if err != nil {
+		t.Fatalf("createBitbucketPR() error = %v", err)
+	}
+	if url != "https://bitbucket.org/team/app/pull-requests/7" {
+		t.Errorf("createBitbucketPR() = %q", url)
+	}
+
+	if _, err := createBitbucketPR("", "team/app", "b", "t", "body"); err == nil {
+		t.Error("createBitbucketPR() without a token should fail")
+	}
+}
@@ -0,0 +1,75 @@
+package autopr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// bitbucketAPI is the Bitbucket Cloud REST API root
+var bitbucketAPI = "https://api.bitbucket.org/2.0"
+
+var bitbucketRemote = regexp.MustCompile(`bitbucket\.org[:/]([^/]+/[^/]+?)(?:\.git)?/?$`)
+
+// bitbucketRepo extracts "workspace/repo" from a Bitbucket remote URL
+func bitbucketRepo(remoteURL string) string {
+	if match := bitbucketRemote.FindStringSubmatch(strings.TrimSpace(remoteURL)); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// createBitbucketPR opens a pull request into the repository's main branch
+func createBitbucketPR(token, repo, branch, title, body string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("opening Bitbucket pull requests needs BITBUCKET_ACCESS_TOKEN (a repository access token)")
+	}
+	if repo == "" {
+		return "", fmt.Errorf("can't determine the Bitbucket repository; set BITBUCKET_REPO_FULL_NAME")
+	}
+
+	request := map[string]interface{}{
+		"title":               title,
+		"description":         body,
+		"source":              map[string]interface{}{"branch": map[string]string{"name": branch}},
+		"close_source_branch": true,
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode pull request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/repositories/%s/pullrequests", bitbucketAPI, repo), bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to open Bitbucket pull request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to open Bitbucket pull request: %s\n%s", resp.Status, respBody)
+	}
+
+	var created struct {
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", fmt.Errorf("failed to parse Bitbucket response: %w", err)
+	}
+	return created.Links.HTML.Href, nil
+}
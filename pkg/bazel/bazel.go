@@ -0,0 +1,140 @@
+package bazel
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// SystemRC is the rc file Bazel reads before the workspace's and the user's own
+const SystemRC = "/etc/bazel.bazelrc"
+
+// CredentialsPath is where the remote cache's Google credentials are mounted
+const CredentialsPath = "/run/packnplay-bazel/google-credentials.json"
+
+// RepositoryCache names the cache of downloaded external dependencies shared by every session
+const RepositoryCache = "bazel-repo"
+
+// workspaceFiles mark the root of a Bazel workspace
+var workspaceFiles = []string{"MODULE.bazel", "WORKSPACE.bazel", "WORKSPACE"}
+
+// headerNamePattern is an HTTP header name
+var headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// envRefPattern is a ${VAR} reference to the host environment
+var envRefPattern = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// IsWorkspace reports whether dir is the root of a Bazel workspace
+func IsWorkspace(dir string) bool {
+	for _, name := range workspaceFiles {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputBaseCache names the cache holding a worktree's output base, which keeps its
+// analysis cache and build outputs between sessions. Each worktree gets its own, since
+// the Bazel server locks it and every session's workspace is /workspace.
+func OutputBaseCache(containerName string) string {
+	return "bazel-" + strings.TrimPrefix(containerName, "packnplay-")
+}
+
+// IsConvenienceSymlink reports whether a workspace-relative path is one of the bazel-*
+// symlinks Bazel creates at the workspace root, which point into an output base
+func IsConvenienceSymlink(rel string, mode fs.FileMode) bool {
+	return mode&fs.ModeSymlink != 0 && !strings.Contains(rel, "/") && strings.HasPrefix(rel, "bazel-")
+}
+
+// RC renders the session's system rc file: the shared repository cache and the remote
+// cache, with headers resolved from getenv so secrets stay out of the config file
+func RC(settings config.BazelConfig, containerHome string, getenv func(string) string) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Written by packnplay for this session\n")
+	fmt.Fprintf(&b, "common --repository_cache=%s\n", path.Join(containerHome, ".cache", RepositoryCache))
+
+	if settings.RemoteCache == "" {
+		if len(settings.RemoteHeaders) > 0 || settings.GoogleCredentials != "" {
+			return "", fmt.Errorf("bazel.remote_headers and bazel.google_credentials need bazel.remote_cache")
+		}
+		return b.String(), nil
+	}
+	if strings.ContainsAny(settings.RemoteCache, " \n") {
+		return "", fmt.Errorf("invalid bazel.remote_cache '%s'", settings.RemoteCache)
+	}
+	fmt.Fprintf(&b, "build --remote_cache=%s\n", settings.RemoteCache)
+
+	names := make([]string, 0, len(settings.RemoteHeaders))
+	for name := range settings.RemoteHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := expand(settings.RemoteHeaders[name], getenv)
+		if !headerNamePattern.MatchString(name) || strings.ContainsAny(value, "\n\r") {
+			return "", fmt.Errorf("invalid bazel.remote_headers entry '%s'", name)
+		}
+		if value == "" {
+			return "", fmt.Errorf("bazel.remote_headers '%s' is empty; is its variable set?", name)
+		}
+		fmt.Fprintf(&b, "build --remote_header=%s=%s\n", name, quote(value))
+	}
+	if settings.GoogleCredentials != "" {
+		fmt.Fprintf(&b, "build --google_credentials=%s\n", CredentialsPath)
+	}
+	return b.String(), nil
+}
+
+// expand substitutes ${VAR} references with getenv, like env config values
+func expand(value string, getenv func(string) string) string {
+	return envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		return getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// quote makes value a single rc file word, which Bazel splits like a shell
+func quote(value string) string {
+	if !strings.ContainsAny(value, " \t'\"\\") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Dir returns the host directory holding a session's rc file
+func Dir(containerName string) string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "bazel", containerName)
+}
+
+// Prepare writes a session's rc file and returns its path, to mount at SystemRC
+func Prepare(containerName, rc string) (string, error) {
+	if err := os.MkdirAll(Dir(containerName), 0700); err != nil {
+		return "", fmt.Errorf("failed to create bazel dir: %w", err)
+	}
+	rcPath := filepath.Join(Dir(containerName), "bazel.bazelrc")
+	// Readable by the container user, whose uid needn't match ours; the directory keeps it private on the host
+	if err := os.WriteFile(rcPath, []byte(rc), 0644); err != nil {
+		return "", fmt.Errorf("failed to write bazelrc: %w", err)
+	}
+	return rcPath, nil
+}
+
+// Forget removes a session's rc file once its container is gone
+func Forget(containerName string) error {
+	if err := os.RemoveAll(Dir(containerName)); err != nil {
+		return fmt.Errorf("failed to remove session bazelrc: %w", err)
+	}
+	return nil
+}
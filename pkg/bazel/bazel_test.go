@@ -0,0 +1,89 @@
+package bazel
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestIsWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	if IsWorkspace(dir) {
+		t.Error("IsWorkspace(empty dir) = true")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "MODULE.bazel"), []byte(`module(name = "app")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsWorkspace(dir) {
+		t.Error("IsWorkspace(MODULE.bazel) = false")
+	}
+}
+
+func TestIsConvenienceSymlink(t *testing.T) {
+	tests := []struct {
+		rel  string
+		mode fs.FileMode
+		want bool
+	}{
+		{"bazel-bin", fs.ModeSymlink, true},
+		{"bazel-myrepo", fs.ModeSymlink, true},
+		{"bazel-tools", fs.ModeDir, false},
+		{"third_party/bazel-bin", fs.ModeSymlink, false},
+		{"bin", fs.ModeSymlink, false},
+	}
+	for _, tt := range tests {
+		if got := IsConvenienceSymlink(tt.rel, tt.mode); got != tt.want {
+			t.Errorf("IsConvenienceSymlink(%s, %v) = %v, want %v", tt.rel, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRC(t *testing.T) {
+	getenv := func(name string) string {
+		return map[string]string{"BUILDBUDDY_API_KEY": "s3cret"}[name]
+	}
+
+	rc, err := RC(config.BazelConfig{}, "/home/vscode", getenv)
+	if err != nil {
+		t.Fatalf("RC() error = %v", err)
+	}
+	if !strings.Contains(rc, "common --repository_cache=/home/vscode/.cache/bazel-repo\n") || strings.Contains(rc, "remote") {
+		t.Errorf("RC(no remote cache) =\n%s", rc)
+	}
+
+	settings := config.BazelConfig{
+		RemoteCache:       "grpcs://remote.buildbuddy.io",
+		RemoteHeaders:     map[string]string{"x-buildbuddy-api-key": "${BUILDBUDDY_API_KEY}", "x-team": "build infra"},
+		GoogleCredentials: "~/key.json",
+	}
+	rc, err = RC(settings, "/home/vscode", getenv)
+	if err != nil {
+		t.Fatalf("RC() error = %v", err)
+	}
+	for _, want := range []string{
+		"build --remote_cache=grpcs://remote.buildbuddy.io\n",
+		"build --remote_header=x-buildbuddy-api-key=s3cret\n",
+		"build --remote_header=x-team='build infra'\n",
+		"build --google_credentials=" + CredentialsPath + "\n",
+	} {
+		if !strings.Contains(rc, want) {
+			t.Errorf("RC() missing %q:\n%s", want, rc)
+		}
+	}
+
+	bad := []config.BazelConfig{
+		{RemoteHeaders: map[string]string{"x-key": "v"}},
+		{RemoteCache: "grpcs://cache", RemoteHeaders: map[string]string{"x-key": "${UNSET}"}},
+		{RemoteCache: "grpcs://cache", RemoteHeaders: map[string]string{"x key": "v"}},
+		{RemoteCache: "grpcs://cache", RemoteHeaders: map[string]string{"x-key": "v\nbuild --config=evil"}},
+	}
+	for _, settings := range bad {
+		if _, err := RC(settings, "/home/vscode", getenv); err == nil {
+			t.Errorf("RC(%+v) should fail", settings)
+		}
+	}
+}
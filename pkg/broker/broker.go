@@ -0,0 +1,247 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// Actions the broker knows how to perform on the host
+// Anything not in this list can never be triggered from a container, whatever the config says
+const (
+	ActionOpen      = "open"       // open an http(s) URL in the host browser
+	ActionCopy      = "copy"       // put text on the host clipboard
+	ActionNotify    = "notify"     // show a host desktop notification
+	ActionGitPush   = "git-push"   // push a session branch to a trusted host with host credentials
+	ActionSparseAdd = "sparse-add" // materialize more paths in a sparse checkout
+)
+
+// KnownActions returns every action the broker supports
+func KnownActions() []string {
+	return []string{ActionOpen, ActionCopy, ActionNotify, ActionGitPush, ActionSparseAdd}
+}
+
+// SocketName is the broker socket's file name inside its mounted directory
+const SocketName = "broker.sock"
+
+// maxRequestBytes bounds request bodies so a container can't exhaust host memory
+const maxRequestBytes = 1 << 20
+
+// Runner executes a host command, feeding stdin when non-empty
+type Runner func(name string, args []string, stdin string) error
+
+// Server handles broker requests for a single container
+type Server struct {
+	mu        sync.RWMutex
+	allowed   map[string]bool
+	gitHosts  []string // host[/path] patterns git-push may push to
+	repo      string   // host checkout git-push pushes from
+	workspace string   // sparse checkout sparse-add works in; empty if the session's isn't sparse
+	run       Runner
+	pushURL   func(repo, remote string) (string, error)
+	push      Pusher
+	addSparse SparseAdder
+}
+
+// NewServer creates a broker that only performs the allowed actions
+func NewServer(allowed []string, run Runner) (*Server, error) {
+	s := &Server{run: run}
+	if _, err := s.SetAllowed(allowed); err != nil {
+		return nil, err
+	}
+
+	if s.run == nil {
+		s.run = execRunner
+	}
+	s.pushURL = git.GetPushURL
+	s.push = execPush
+	s.addSparse = git.SparseAdd
+	return s, nil
+}
+
+// SetAllowed replaces the allowed actions, e.g. after the config changed, reporting whether
+// they differ from before. Nothing changes if any action is unknown.
+func (s *Server) SetAllowed(allowed []string) (bool, error) {
+	known := make(map[string]bool)
+	for _, action := range KnownActions() {
+		known[action] = true
+	}
+
+	next := make(map[string]bool)
+	for _, action := range allowed {
+		if !known[action] {
+			return false, fmt.Errorf("unknown broker action '%s' (available: %s)", action, strings.Join(KnownActions(), ", "))
+		}
+		next[action] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := len(next) != len(s.allowed)
+	for action := range next {
+		changed = changed || !s.allowed[action]
+	}
+	s.allowed = next
+	return changed, nil
+}
+
+// Allowed returns the currently allowed actions, sorted
+func (s *Server) Allowed() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var actions []string
+	for action := range s.allowed {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// notifyRequest is the body of a notify action
+type notifyRequest struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// ServeHTTP dispatches POST /<action> requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := strings.TrimPrefix(r.URL.Path, "/")
+	s.mu.RLock()
+	allowed := s.allowed[action]
+	s.mu.RUnlock()
+	if !allowed {
+		http.Error(w, fmt.Sprintf("action '%s' is not allowed", action), http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	if action == ActionGitPush {
+		log.Printf("broker: %s", action)
+		s.serveGitPush(w, body)
+		return
+	}
+	if action == ActionSparseAdd {
+		log.Printf("broker: %s %s", action, strings.Join(strings.Fields(string(body)), " "))
+		s.serveSparseAdd(w, body)
+		return
+	}
+
+	name, args, stdin, err := hostCommand(action, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("broker: %s", action)
+	if err := s.run(name, args, stdin); err != nil {
+		http.Error(w, fmt.Sprintf("%s failed: %v", action, err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hostCommand validates a request and maps it to the host command that performs it
+// Commands are run without a shell; request data only ever becomes a single argument or stdin.
+func hostCommand(action string, body []byte) (string, []string, string, error) {
+	isDarwin := runtime.GOOS == "darwin"
+
+	switch action {
+	case ActionOpen:
+		target := strings.TrimSpace(string(body))
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "", nil, "", fmt.Errorf("open only accepts http(s) URLs")
+		}
+		if isDarwin {
+			return "open", []string{parsed.String()}, "", nil
+		}
+		return "xdg-open", []string{parsed.String()}, "", nil
+
+	case ActionCopy:
+		if len(body) == 0 {
+			return "", nil, "", fmt.Errorf("copy requires text in the request body")
+		}
+		if isDarwin {
+			return "pbcopy", nil, string(body), nil
+		}
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			return "wl-copy", nil, string(body), nil
+		}
+		return "xclip", []string{"-selection", "clipboard"}, string(body), nil
+
+	case ActionNotify:
+		var req notifyRequest
+		if err := json.Unmarshal(body, &req); err != nil || req.Message == "" {
+			return "", nil, "", fmt.Errorf(`notify expects {"title": "...", "message": "..."}`)
+		}
+		if req.Title == "" {
+			req.Title = "packnplay"
+		}
+		if isDarwin {
+			script := fmt.Sprintf("display notification %s with title %s", appleScriptString(req.Message), appleScriptString(req.Title))
+			return "osascript", []string{"-e", script}, "", nil
+		}
+		return "notify-send", []string{req.Title, req.Message}, "", nil
+	}
+
+	return "", nil, "", fmt.Errorf("unknown action '%s'", action)
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// execRunner runs a host command for real
+func execRunner(name string, args []string, stdin string) error {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Listen removes any stale socket and listens on socketPath, usable by the owner and its
+// group. The container user usually has another uid, so it reaches the socket through the
+// owner's gid, which the session adds to its groups.
+func Listen(socketPath string) (net.Listener, error) {
+	_ = os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to secure %s: %w", socketPath, err)
+	}
+	return listener, nil
+}
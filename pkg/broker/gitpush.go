@@ -0,0 +1,153 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// Pusher pushes refspec from the host checkout at repo to the remote URL, returning git's output
+type Pusher func(repo, remoteURL, refspec string) (string, error)
+
+// ValidateGitHosts checks trusted host patterns: a host, optionally followed by a path prefix,
+// e.g. "github.com/my-org" or "gitlab.example.com"
+func ValidateGitHosts(patterns []string) error {
+	for _, pattern := range patterns {
+		host, _, _ := strings.Cut(pattern, "/")
+		if host == "" || strings.Contains(pattern, "://") || strings.ContainsAny(pattern, " \n") {
+			return fmt.Errorf("invalid git host '%s' (use a host, optionally with a path, e.g. github.com/my-org)", pattern)
+		}
+	}
+	return nil
+}
+
+// SetGitHosts replaces the hosts git-push may push to, reporting whether they changed
+func (s *Server) SetGitHosts(patterns []string) (bool, error) {
+	if err := ValidateGitHosts(patterns); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := strings.Join(patterns, "\n") != strings.Join(s.gitHosts, "\n")
+	s.gitHosts = append([]string(nil), patterns...)
+	return changed, nil
+}
+
+// SetRepo sets the host checkout git-push pushes from
+func (s *Server) SetRepo(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo = path
+}
+
+// trustedGitHost reports whether a pattern covers the host and repository path of a remote
+// "github.com/my-org" covers my-org/app.git but not my-organization/app.git.
+func trustedGitHost(patterns []string, host, path string) bool {
+	path = strings.Trim(path, "/")
+	for _, pattern := range patterns {
+		patternHost, prefix, _ := strings.Cut(pattern, "/")
+		if !strings.EqualFold(patternHost, host) {
+			continue
+		}
+		prefix = strings.Trim(strings.TrimSuffix(prefix, "*"), "/")
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pushRequest is the body of a git-push action
+type pushRequest struct {
+	Remote string `json:"remote"` // defaults to origin
+	Branch string `json:"branch"`
+}
+
+// validRefName rejects names git could read as an option, a refspec or a revision expression
+func validRefName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "-") && !strings.ContainsAny(name, ": \t\n~^?*[\\") &&
+		!strings.Contains(name, "..") && !strings.Contains(name, "@{")
+}
+
+// serveGitPush pushes a branch of the session's checkout from the host, where the host's own
+// credential helpers authenticate it, so no password or token ever reaches the container
+// The remote is resolved on the host and must be an HTTPS URL on a trusted host.
+func (s *Server) serveGitPush(w http.ResponseWriter, body []byte) {
+	var req pushRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, `git-push expects {"remote": "origin", "branch": "..."}`, http.StatusBadRequest)
+		return
+	}
+	if req.Remote == "" {
+		req.Remote = "origin"
+	}
+	if !validRefName(req.Remote) || !validRefName(req.Branch) {
+		http.Error(w, fmt.Sprintf("invalid remote or branch '%s %s'", req.Remote, req.Branch), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	repo, patterns := s.repo, s.gitHosts
+	s.mu.RUnlock()
+	if repo == "" {
+		http.Error(w, "this session has no host checkout to push from", http.StatusConflict)
+		return
+	}
+
+	remoteURL, err := s.pushURL(repo, req.Remote)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	parsed, err := url.Parse(remoteURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.User != nil {
+		http.Error(w, fmt.Sprintf("git-push only pushes to https remotes without embedded credentials, not %s", req.Remote), http.StatusBadRequest)
+		return
+	}
+	if !trustedGitHost(patterns, parsed.Hostname(), parsed.Path) {
+		http.Error(w, fmt.Sprintf("%s%s is not a trusted git host", parsed.Hostname(), parsed.Path), http.StatusForbidden)
+		return
+	}
+
+	refspec := fmt.Sprintf("refs/heads/%s:refs/heads/%s", req.Branch, req.Branch)
+	output, err := s.push(repo, remoteURL, refspec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, output)
+}
+
+// execPush pushes with host git and never prompts. The commit is resolved in the checkout, but
+// pushed from a scratch repository that shares its objects and not its config, which the
+// sandbox can write: a url.*.insteadOf or http.proxy there must not redirect the host's
+// credentialed push away from the remote serveGitPush checked.
+func execPush(repo, remoteURL, refspec string) (string, error) {
+	src, dst, _ := strings.Cut(refspec, ":")
+	resolve, err := git.Command(repo, "rev-parse", "--verify", "--end-of-options", src+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	output, err := resolve.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", src, err)
+	}
+	commit := strings.TrimSpace(string(output))
+
+	cmd, cleanup, err := git.IsolatedCommand(repo, "-c", "credential.interactive=false", "push", remoteURL, commit+":"+dst)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	pushOutput, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git push failed: %w\n%s", err, pushOutput)
+	}
+	return string(pushOutput), nil
+}
@@ -0,0 +1,91 @@
+package broker
+
+import (
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// initPushRepo creates a checkout with one commit on main and returns its path
+func initPushRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q", "-b", "main")
+	runGit(t, repo, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial")
+	return repo
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// The checkout's config is the sandbox's to write; it must not send the push anywhere else
+func TestExecPushIgnoresRepoURLRewrites(t *testing.T) {
+	repo := initPushRepo(t)
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	evil := filepath.Join(t.TempDir(), "evil.git")
+	runGit(t, repo, "init", "-q", "--bare", remote)
+	runGit(t, repo, "init", "-q", "--bare", evil)
+	runGit(t, repo, "config", "url."+evil+".insteadOf", remote)
+	runGit(t, repo, "config", "url."+evil+".pushInsteadOf", remote)
+
+	if output, err := execPush(repo, remote, "refs/heads/main:refs/heads/main"); err != nil {
+		t.Fatalf("execPush() error = %v\n%s", err, output)
+	}
+
+	want := runGit(t, repo, "rev-parse", "main")
+	if got := runGit(t, remote, "rev-parse", "main"); got != want {
+		t.Errorf("remote main = %s, want %s", got, want)
+	}
+	if refs := runGit(t, evil, "for-each-ref"); refs != "" {
+		t.Errorf("push was rewritten to %s: %s", evil, refs)
+	}
+}
+
+func TestExecPushIgnoresRepoProxy(t *testing.T) {
+	repo := initPushRepo(t)
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	var proxied atomic.Bool
+	go func() {
+		for {
+			conn, err := proxy.Accept()
+			if err != nil {
+				return
+			}
+			proxied.Store(true)
+			conn.Close()
+		}
+	}()
+
+	// Nothing listens on the remote's port, so the push fails fast when it goes direct
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := "http://" + closed.Addr().String() + "/my-org/app.git"
+	closed.Close()
+	runGit(t, repo, "config", "http.proxy", "http://"+proxy.Addr().String())
+	runGit(t, repo, "config", "http.sslVerify", "false")
+
+	if _, err := execPush(repo, remote, "refs/heads/main:refs/heads/main"); err == nil {
+		t.Fatal("execPush() to a closed port succeeded")
+	}
+	if proxied.Load() {
+		t.Error("push went through the checkout's http.proxy")
+	}
+}
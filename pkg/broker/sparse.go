@@ -0,0 +1,50 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// SparseAdder materializes more paths in the sparse checkout at workspace
+type SparseAdder func(workspace string, paths []string) error
+
+// SetWorkspace sets the host checkout sparse-add materializes paths in
+func (s *Server) SetWorkspace(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspace = path
+}
+
+// serveSparseAdd checks out more of a sparse monorepo, so an agent that finds it needs
+// another part of the tree can ask for it instead of working without it
+// The body lists repository-relative paths, separated by whitespace.
+func (s *Server) serveSparseAdd(w http.ResponseWriter, body []byte) {
+	s.mu.RLock()
+	workspace := s.workspace
+	s.mu.RUnlock()
+	if workspace == "" {
+		http.Error(w, "this session's workspace isn't a sparse checkout", http.StatusConflict)
+		return
+	}
+
+	paths := strings.Fields(string(body))
+	if len(paths) == 0 {
+		http.Error(w, "sparse-add requires paths in the request body", http.StatusBadRequest)
+		return
+	}
+	for _, p := range paths {
+		if err := git.ValidateSparsePath(p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.addSparse(workspace, paths); err != nil {
+		http.Error(w, fmt.Sprintf("sparse-add failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,340 @@
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/tarball"
+)
+
+// bundleVersion is bumped whenever the bundle layout changes incompatibly
+const bundleVersion = 1
+
+// Bundle entry names
+const (
+	manifestEntry = "manifest.json"
+	imagesEntry   = "images.tar"
+	policiesDir   = "policies"
+	blocklistsDir = "blocklists"
+)
+
+// Manifest describes what an offline bundle carries
+type Manifest struct {
+	Version      int       `json:"version"`
+	Images       []string  `json:"images"`
+	DefaultImage string    `json:"defaultImage,omitempty"`
+	Policies     []string  `json:"policies,omitempty"`   // entries under policies/, one per configured policy path
+	Blocklists   []string  `json:"blocklists,omitempty"` // network blocklist names
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Contents is what to put in a bundle
+type Contents struct {
+	Images        []string // image references, saved with their layers
+	DefaultImage  string   // the image sessions start from, recorded for the target's config
+	Policies      []string // Rego files or directories
+	BlocklistsDir string   // updated network blocklists, if any
+}
+
+// Create writes an offline bundle to outPath, compressed as its extension says (.tar.zst or .tar.gz)
+// Images are exported with docker save, so the target needs no registry access.
+func Create(dockerClient *docker.Client, contents Contents, outPath string) (*Manifest, error) {
+	manifest := &Manifest{
+		Version:      bundleVersion,
+		Images:       contents.Images,
+		DefaultImage: contents.DefaultImage,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	tempDir, err := os.MkdirTemp("", "packnplay-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	imagesPath := filepath.Join(tempDir, imagesEntry)
+	if len(contents.Images) > 0 {
+		args := append([]string{"save", "-o", imagesPath}, contents.Images...)
+		if output, err := dockerClient.Run(args...); err != nil {
+			return nil, fmt.Errorf("failed to save images: %w\n%s", err, output)
+		}
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", outPath, err)
+	}
+	defer out.Close()
+	compressed, err := tarball.NewCompressedWriter(out, outPath)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(compressed)
+
+	if len(contents.Images) > 0 {
+		if err := addFile(tw, imagesEntry, imagesPath); err != nil {
+			return nil, err
+		}
+	}
+	for i, policy := range contents.Policies {
+		name := strconv.Itoa(i) + "-" + filepath.Base(policy)
+		if err := addTree(tw, policiesDir+"/"+name, policy); err != nil {
+			return nil, err
+		}
+		manifest.Policies = append(manifest.Policies, name)
+	}
+	if contents.BlocklistsDir != "" {
+		entries, _ := os.ReadDir(contents.BlocklistsDir)
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+				continue
+			}
+			if err := addFile(tw, blocklistsDir+"/"+entry.Name(), filepath.Join(contents.BlocklistsDir, entry.Name())); err != nil {
+				return nil, err
+			}
+			manifest.Blocklists = append(manifest.Blocklists, strings.TrimSuffix(entry.Name(), ".txt"))
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := addBytes(tw, manifestEntry, data); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := compressed.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	return manifest, nil
+}
+
+// Install loads a bundle's images into the runtime, puts its policies under policyRoot and
+// its blocklists in blocklistRoot, and returns the manifest with the installed policy paths
+// Installed policies replace earlier copies of the same name, so reinstalling is safe.
+func Install(dockerClient *docker.Client, archivePath, policyRoot, blocklistRoot string) (*Manifest, []string, error) {
+	tempDir, err := os.MkdirTemp("", "packnplay-bundle-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := extract(archivePath, tempDir); err != nil {
+		return nil, nil, err
+	}
+	manifest, err := readManifest(tempDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(manifest.Images) > 0 {
+		if output, err := dockerClient.Run("load", "-i", filepath.Join(tempDir, imagesEntry)); err != nil {
+			return nil, nil, fmt.Errorf("failed to load images: %w\n%s", err, output)
+		}
+	}
+
+	var policies []string
+	for _, name := range manifest.Policies {
+		target := filepath.Join(policyRoot, name)
+		if err := os.RemoveAll(target); err != nil {
+			return nil, nil, fmt.Errorf("failed to replace %s: %w", target, err)
+		}
+		if err := copyTree(filepath.Join(tempDir, policiesDir, name), target); err != nil {
+			return nil, nil, fmt.Errorf("failed to install policy %s: %w", name, err)
+		}
+		policies = append(policies, target)
+	}
+
+	for _, name := range manifest.Blocklists {
+		if err := os.MkdirAll(blocklistRoot, 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create %s: %w", blocklistRoot, err)
+		}
+		data, err := os.ReadFile(filepath.Join(tempDir, blocklistsDir, name+".txt"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read blocklist %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(blocklistRoot, name+".txt"), data, 0644); err != nil {
+			return nil, nil, fmt.Errorf("failed to install blocklist %s: %w", name, err)
+		}
+	}
+	return manifest, policies, nil
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestEntry))
+	if err != nil {
+		return nil, fmt.Errorf("not a packnplay bundle (no %s): %w", manifestEntry, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestEntry, err)
+	}
+	if manifest.Version > bundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than this packnplay supports (%d); upgrade packnplay", manifest.Version, bundleVersion)
+	}
+	// Names become paths under the policy and blocklist dirs, so each must be one plain name
+	for _, name := range append(append([]string{}, manifest.Policies...), manifest.Blocklists...) {
+		if !validName(name) {
+			return nil, fmt.Errorf("invalid %s: %q is not a plain file name", manifestEntry, name)
+		}
+	}
+	return &manifest, nil
+}
+
+// validName reports whether name is a single, local path element
+func validName(name string) bool {
+	return filepath.IsLocal(name) && filepath.Base(name) == name
+}
+
+func addBytes(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// addFile adds a regular file; anything else (symlinks, sockets) is skipped
+func addFile(tw *tar.Writer, name, srcPath string) error {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", srcPath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to build header for %s: %w", srcPath, err)
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// addTree adds a file as name, or a directory's files below name
+func addTree(tw *tar.Writer, name, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return addFile(tw, name, path)
+		}
+		return addFile(tw, name+"/"+filepath.ToSlash(rel), path)
+	})
+}
+
+// extract unpacks a bundle's regular files into destDir, rejecting entries that escape it
+func extract(archivePath, destDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	r, err := tarball.NewDecompressedReader(f, archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		target := filepath.Join(destDir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("bundle entry %s escapes destination", header.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", target, err)
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+}
+
+// copyTree copies an extracted file, or a directory's files, to dest
+func copyTree(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,103 @@
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/tarball"
+)
+
+func TestCreateAndInstallPoliciesAndBlocklists(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "registry.rego"), []byte("package packnplay\n"), 0644)
+	os.MkdirAll(filepath.Join(src, "org", "rules"), 0755)
+	os.WriteFile(filepath.Join(src, "org", "rules", "mounts.rego"), []byte("package packnplay\n"), 0644)
+	blocklists := filepath.Join(src, "blocklists")
+	os.MkdirAll(blocklists, 0755)
+	os.WriteFile(filepath.Join(blocklists, "exfiltration.txt"), []byte("paste.example\n"), 0644)
+	os.WriteFile(filepath.Join(blocklists, "notes.md"), []byte("ignored"), 0644)
+
+	for _, ext := range []string{".tar.zst", ".tar.gz"} {
+		archive := filepath.Join(t.TempDir(), "bundle"+ext)
+		manifest, err := Create(nil, Contents{
+			DefaultImage:  "ghcr.io/obra/packnplay-default:latest",
+			Policies:      []string{filepath.Join(src, "registry.rego"), filepath.Join(src, "org")},
+			BlocklistsDir: blocklists,
+		}, archive)
+		if err != nil {
+			t.Fatalf("Create(%s) error = %v", ext, err)
+		}
+		if !reflect.DeepEqual(manifest.Policies, []string{"0-registry.rego", "1-org"}) || !reflect.DeepEqual(manifest.Blocklists, []string{"exfiltration"}) {
+			t.Errorf("manifest = %+v", manifest)
+		}
+
+		target := t.TempDir()
+		installed, policies, err := Install(nil, archive, filepath.Join(target, "policies"), filepath.Join(target, "blocklists"))
+		if err != nil {
+			t.Fatalf("Install(%s) error = %v", ext, err)
+		}
+		if installed.DefaultImage != "ghcr.io/obra/packnplay-default:latest" {
+			t.Errorf("installed manifest = %+v", installed)
+		}
+		wantPolicies := []string{filepath.Join(target, "policies", "0-registry.rego"), filepath.Join(target, "policies", "1-org")}
+		if !reflect.DeepEqual(policies, wantPolicies) {
+			t.Errorf("Install() policies = %v, want %v", policies, wantPolicies)
+		}
+		if _, err := os.Stat(filepath.Join(target, "policies", "1-org", "rules", "mounts.rego")); err != nil {
+			t.Errorf("policy directory not installed: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(target, "blocklists", "exfiltration.txt")); err != nil || string(data) != "paste.example\n" {
+			t.Errorf("blocklist = %q, %v", data, err)
+		}
+
+		// Reinstalling replaces the earlier copies
+		if _, _, err := Install(nil, archive, filepath.Join(target, "policies"), filepath.Join(target, "blocklists")); err != nil {
+			t.Errorf("reinstall error = %v", err)
+		}
+	}
+}
+
+func TestInstallRejectsOtherArchives(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "session.tar.gz")
+	if _, err := Create(nil, Contents{}, archive); err != nil {
+		t.Fatal(err)
+	}
+	// A bundle without a manifest isn't one
+	os.WriteFile(archive, []byte{}, 0644)
+	if _, _, err := Install(nil, archive, t.TempDir(), t.TempDir()); err == nil {
+		t.Error("Install() accepted an empty file")
+	}
+
+	if _, err := Create(nil, Contents{}, filepath.Join(t.TempDir(), "bundle.zip")); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Create() with a .zip name error = %v", err)
+	}
+}
+
+func TestInstallRejectsPathNames(t *testing.T) {
+	for _, name := range []string{"../../.bashrc", "/etc/cron.d/x", "org/rules", "..", ""} {
+		archive := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		f, err := os.Create(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed, _ := tarball.NewCompressedWriter(f, archive)
+		tw := tar.NewWriter(compressed)
+		manifest, _ := json.Marshal(Manifest{Version: bundleVersion, Blocklists: []string{name}})
+		if err := addBytes(tw, manifestEntry, manifest); err != nil {
+			t.Fatal(err)
+		}
+		tw.Close()
+		compressed.Close()
+		f.Close()
+
+		target := t.TempDir()
+		if _, _, err := Install(nil, archive, filepath.Join(target, "policies"), filepath.Join(target, "blocklists")); err == nil || !strings.Contains(err.Error(), "plain file name") {
+			t.Errorf("Install() with blocklist %q error = %v, want refusal", name, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package burner
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+// Providers that can mint per-session keys
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+)
+
+// Record is what's kept on the host to revoke a minted key later
+// It never holds the key itself, only the provider-side handle.
+type Record struct {
+	Provider    string    `json:"provider"`
+	ID          string    `json:"id"` // provider-side handle used to revoke, e.g. an OpenAI service account ID
+	ProjectID   string    `json:"project_id"`
+	AdminKeyEnv string    `json:"admin_key_env"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Credential is a freshly minted key and the env var it's exposed as in the container
+type Credential struct {
+	Record
+	EnvVar string
+	Key    string
+}
+
+// minter is a provider that can create and revoke scoped keys
+type minter interface {
+	mint(name string) (Record, string, error)
+	revoke(record Record) error
+}
+
+// envVars maps each provider to the variable its agents read the key from
+var envVars = map[string]string{
+	ProviderOpenAI: "OPENAI_API_KEY",
+}
+
+// defaultAdminKeyEnvs is where each provider's admin key is read from unless configured
+var defaultAdminKeyEnvs = map[string]string{
+	ProviderOpenAI: "OPENAI_ADMIN_KEY",
+}
+
+// Validate checks the burner_credentials config before anything is minted
+func Validate(cfgs map[string]config.BurnerCredential) error {
+	for name, cfg := range cfgs {
+		switch name {
+		case ProviderOpenAI:
+			if cfg.ProjectID == "" {
+				return fmt.Errorf("burner_credentials.%s requires project_id", name)
+			}
+		case ProviderAnthropic:
+			return fmt.Errorf("burner_credentials.%s is not supported: the Anthropic Admin API can list and disable keys but not create them", name)
+		default:
+			return fmt.Errorf("unknown burner_credentials provider '%s' (available: %s)", name, ProviderOpenAI)
+		}
+	}
+	return nil
+}
+
+// EnvVars returns the env vars the configured providers will supply, so host values aren't forwarded too
+func EnvVars(cfgs map[string]config.BurnerCredential) []string {
+	var names []string
+	for name := range cfgs {
+		if envVar, ok := envVars[name]; ok {
+			names = append(names, envVar)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Mint creates one key per configured provider, named after the session
+// If any provider fails, keys already minted are revoked before returning.
+func Mint(cfgs map[string]config.BurnerCredential, session string) ([]Credential, error) {
+	names := make([]string, 0, len(cfgs))
+	for name := range cfgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var creds []Credential
+	for _, name := range names {
+		cred, err := mintOne(name, cfgs[name], session)
+		if err != nil {
+			for _, minted := range creds {
+				_ = Revoke(minted.Record)
+			}
+			return nil, fmt.Errorf("failed to mint %s key: %w", name, err)
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+// mintOne creates a single provider's key
+func mintOne(name string, cfg config.BurnerCredential, session string) (Credential, error) {
+	adminKeyEnv := cfg.AdminKeyEnv
+	if adminKeyEnv == "" {
+		adminKeyEnv = defaultAdminKeyEnvs[name]
+	}
+
+	m, err := newMinter(Record{Provider: name, ProjectID: cfg.ProjectID, AdminKeyEnv: adminKeyEnv})
+	if err != nil {
+		return Credential{}, err
+	}
+	record, key, err := m.mint("packnplay-" + session)
+	if err != nil {
+		return Credential{}, err
+	}
+	record.AdminKeyEnv = adminKeyEnv
+	return Credential{Record: record, EnvVar: envVars[name], Key: key}, nil
+}
+
+// Revoke deletes a minted key at its provider
+func Revoke(record Record) error {
+	m, err := newMinter(record)
+	if err != nil {
+		return err
+	}
+	if err := m.revoke(record); err != nil {
+		return fmt.Errorf("failed to revoke %s key %s: %w", record.Provider, record.ID, err)
+	}
+	return nil
+}
+
+// newMinter builds a provider client, reading its admin key from the host environment
+// or, failing that, packnplay's secret store
+func newMinter(record Record) (minter, error) {
+	adminKey := secrets.Lookup(record.AdminKeyEnv)
+	if adminKey == "" {
+		return nil, fmt.Errorf("%s admin key not set (export %s, or store it with 'packnplay secret set %s')", record.Provider, record.AdminKeyEnv, record.AdminKeyEnv)
+	}
+
+	switch record.Provider {
+	case ProviderOpenAI:
+		return &openAI{baseURL: openAIBaseURL, adminKey: adminKey, projectID: record.ProjectID}, nil
+	}
+	return nil, fmt.Errorf("unknown burner provider '%s'", record.Provider)
+}
+
+// Env returns the docker -e values that expose the credentials
+func Env(creds []Credential) []string {
+	env := make([]string, 0, len(creds))
+	for _, cred := range creds {
+		env = append(env, fmt.Sprintf("%s=%s", cred.EnvVar, cred.Key))
+	}
+	return env
+}
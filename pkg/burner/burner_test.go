@@ -0,0 +1,159 @@
+package burner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// fakeOpenAI serves the service account endpoints for one project
+type fakeOpenAI struct {
+	mu       sync.Mutex
+	accounts map[string]bool
+	next     int
+}
+
+func (f *fakeOpenAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer admin-key" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/organization/projects/proj_123/service_accounts"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == prefix:
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.next++
+		id := "svc_acct_" + string(rune('0'+f.next))
+		f.accounts[id] = true
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      id,
+			"name":    body["name"],
+			"api_key": map[string]string{"value": "sk-burner-" + id},
+		})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, prefix+"/"):
+		id := strings.TrimPrefix(r.URL.Path, prefix+"/")
+		if !f.accounts[id] {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.accounts, id)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func setupFake(t *testing.T) *fakeOpenAI {
+	t.Helper()
+	fake := &fakeOpenAI{accounts: make(map[string]bool)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	original := openAIBaseURL
+	openAIBaseURL = server.URL
+	t.Cleanup(func() { openAIBaseURL = original })
+
+	t.Setenv("OPENAI_ADMIN_KEY", "admin-key")
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	return fake
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfgs    map[string]config.BurnerCredential
+		wantErr string
+	}{
+		{"empty", nil, ""},
+		{"openai", map[string]config.BurnerCredential{"openai": {ProjectID: "proj_123"}}, ""},
+		{"missing project", map[string]config.BurnerCredential{"openai": {}}, "requires project_id"},
+		{"anthropic", map[string]config.BurnerCredential{"anthropic": {ProjectID: "wrk_1"}}, "not supported"},
+		{"unknown", map[string]config.BurnerCredential{"gemini": {}}, "unknown burner_credentials provider"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfgs)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMintAndRevokeSession(t *testing.T) {
+	fake := setupFake(t)
+	cfgs := map[string]config.BurnerCredential{"openai": {ProjectID: "proj_123"}}
+
+	creds, err := Mint(cfgs, "packnplay-demo-main")
+	if err != nil {
+		t.Fatalf("Mint() error: %v", err)
+	}
+	if len(creds) != 1 || creds[0].EnvVar != "OPENAI_API_KEY" || creds[0].Key != "sk-burner-svc_acct_1" {
+		t.Fatalf("Mint() = %+v", creds)
+	}
+	if got := Env(creds); len(got) != 1 || got[0] != "OPENAI_API_KEY=sk-burner-svc_acct_1" {
+		t.Errorf("Env() = %v", got)
+	}
+
+	if err := Save("packnplay-demo-main", []Record{creds[0].Record}); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	sessions, err := Sessions()
+	if err != nil || len(sessions) != 1 || sessions[0] != "packnplay-demo-main" {
+		t.Fatalf("Sessions() = %v, %v", sessions, err)
+	}
+
+	if err := RevokeSession("packnplay-demo-main"); err != nil {
+		t.Fatalf("RevokeSession() error: %v", err)
+	}
+	if len(fake.accounts) != 0 {
+		t.Errorf("service accounts left after revoke: %v", fake.accounts)
+	}
+	if sessions, _ := Sessions(); len(sessions) != 0 {
+		t.Errorf("records left after revoke: %v", sessions)
+	}
+}
+
+func TestRevokeSessionKeepsFailures(t *testing.T) {
+	setupFake(t)
+
+	records := []Record{{Provider: ProviderOpenAI, ID: "svc_acct_gone", ProjectID: "proj_123", AdminKeyEnv: "OPENAI_ADMIN_KEY"}}
+	if err := Save("packnplay-demo-main", records); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	if err := RevokeSession("packnplay-demo-main"); err == nil {
+		t.Fatal("RevokeSession() should fail for an unknown service account")
+	}
+	remaining, err := Load("packnplay-demo-main")
+	if err != nil || len(remaining) != 1 {
+		t.Errorf("failed revocations should stay recorded, got %v, %v", remaining, err)
+	}
+}
+
+func TestMintRequiresAdminKey(t *testing.T) {
+	setupFake(t)
+	t.Setenv("OPENAI_ADMIN_KEY", "")
+
+	_, err := Mint(map[string]config.BurnerCredential{"openai": {ProjectID: "proj_123"}}, "demo")
+	if err == nil || !strings.Contains(err.Error(), "OPENAI_ADMIN_KEY") {
+		t.Fatalf("Mint() error = %v, want missing admin key", err)
+	}
+}
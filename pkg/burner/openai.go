@@ -0,0 +1,92 @@
+package burner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// openAIBaseURL is the OpenAI API root (overridden in tests)
+var openAIBaseURL = "https://api.openai.com/v1"
+
+// openAI mints keys as project service accounts: creating one returns a key scoped
+// to the project, and deleting the account revokes it.
+type openAI struct {
+	baseURL   string
+	adminKey  string
+	projectID string
+}
+
+// serviceAccount is the subset of the create response we use
+type serviceAccount struct {
+	ID     string `json:"id"`
+	APIKey struct {
+		Value string `json:"value"`
+	} `json:"api_key"`
+}
+
+func (o *openAI) mint(name string) (Record, string, error) {
+	body, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return Record{}, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	data, err := o.do(http.MethodPost, o.serviceAccountsURL(), body)
+	if err != nil {
+		return Record{}, "", err
+	}
+
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return Record{}, "", fmt.Errorf("failed to parse service account: %w", err)
+	}
+	if account.ID == "" || account.APIKey.Value == "" {
+		return Record{}, "", fmt.Errorf("service account response has no API key")
+	}
+
+	return Record{
+		Provider:  ProviderOpenAI,
+		ID:        account.ID,
+		ProjectID: o.projectID,
+		CreatedAt: time.Now().UTC(),
+	}, account.APIKey.Value, nil
+}
+
+func (o *openAI) revoke(record Record) error {
+	_, err := o.do(http.MethodDelete, o.serviceAccountsURL()+"/"+url.PathEscape(record.ID), nil)
+	return err
+}
+
+func (o *openAI) serviceAccountsURL() string {
+	return fmt.Sprintf("%s/organization/projects/%s/service_accounts", o.baseURL, url.PathEscape(o.projectID))
+}
+
+// do sends an authenticated admin request and returns the response body
+func (o *openAI) do(method, target string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+o.adminKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach OpenAI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("OpenAI returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
@@ -0,0 +1,107 @@
+package burner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// getStateDir returns the directory holding outstanding key records, one file per container
+func getStateDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "burner")
+}
+
+// getStatePath returns the record file for a container
+func getStatePath(containerName string) string {
+	return filepath.Join(getStateDir(), containerName+".json")
+}
+
+// Save records the keys minted for a container so they can be revoked after it stops
+func Save(containerName string, records []Record) error {
+	if err := os.MkdirAll(getStateDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create burner state dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal burner records: %w", err)
+	}
+	if err := os.WriteFile(getStatePath(containerName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write burner records: %w", err)
+	}
+	return nil
+}
+
+// Load returns the outstanding records for a container (nil if there are none)
+func Load(containerName string) ([]Record, error) {
+	data, err := os.ReadFile(getStatePath(containerName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read burner records: %w", err)
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse burner records: %w", err)
+	}
+	return records, nil
+}
+
+// RevokeSession revokes every outstanding key for a container
+// Keys that fail to revoke stay recorded, so a later stop or run retries them.
+func RevokeSession(containerName string) error {
+	records, err := Load(containerName)
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	var remaining []Record
+	var failures []string
+	for _, record := range records {
+		if err := Revoke(record); err != nil {
+			remaining = append(remaining, record)
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(remaining) == 0 {
+		if err := os.Remove(getStatePath(containerName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove burner records: %w", err)
+		}
+		return nil
+	}
+	if err := Save(containerName, remaining); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s", strings.Join(failures, "; "))
+}
+
+// Sessions lists containers that still have outstanding keys
+func Sessions() ([]string, error) {
+	entries, err := os.ReadDir(getStateDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read burner state dir: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
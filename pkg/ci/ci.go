@@ -0,0 +1,121 @@
+package ci
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Provider identifies a CI system
+type Provider string
+
+const (
+	GitHubActions      Provider = "github-actions"
+	GitLabCI           Provider = "gitlab-ci"
+	BitbucketPipelines Provider = "bitbucket-pipelines"
+)
+
+// Env is the CI system packnplay is running under
+type Env struct {
+	Provider Provider
+	getenv   func(string) string
+}
+
+// Detect identifies the CI system from its standard environment variables
+// Returns nil when not running in CI.
+func Detect(getenv func(string) string) *Env {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return &Env{Provider: GitHubActions, getenv: getenv}
+	case getenv("GITLAB_CI") == "true":
+		return &Env{Provider: GitLabCI, getenv: getenv}
+	case getenv("BITBUCKET_BUILD_NUMBER") != "":
+		return &Env{Provider: BitbucketPipelines, getenv: getenv}
+	}
+	return nil
+}
+
+// GroupStart opens a collapsible log section in the CI's native format
+// Bitbucket Pipelines has no log sections, so it gets a plain header.
+func (e *Env) GroupStart(w io.Writer, id, title string) {
+	switch e.Provider {
+	case GitHubActions:
+		fmt.Fprintf(w, "::group::%s\n", title)
+	case GitLabCI:
+		fmt.Fprintf(w, "\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s\n", time.Now().Unix(), sectionID(id), title)
+	default:
+		fmt.Fprintf(w, "=== %s ===\n", title)
+	}
+}
+
+// GroupEnd closes the section opened by GroupStart with the same id
+func (e *Env) GroupEnd(w io.Writer, id string) {
+	switch e.Provider {
+	case GitHubActions:
+		fmt.Fprintln(w, "::endgroup::")
+	case GitLabCI:
+		fmt.Fprintf(w, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", time.Now().Unix(), sectionID(id))
+	}
+}
+
+// sectionID makes id safe for GitLab section markers, which allow only [A-Za-z0-9_.-]
+func sectionID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// PushTarget is where to push with the job's credentials
+// The token is kept out of the URL, where git would show it in process listings and
+// error messages, and sent as an HTTP Authorization header instead.
+type PushTarget struct {
+	URL           string // HTTPS remote URL, without credentials
+	Authorization string // value of the Authorization header, e.g. "Basic ..."
+}
+
+// PushTarget returns the job's repository and credentials to push with
+// Returns false when the job has no usable token.
+func (e *Env) PushTarget() (PushTarget, bool) {
+	var user, token, url string
+	switch e.Provider {
+	case GitHubActions:
+		repo := e.getenv("GITHUB_REPOSITORY")
+		server := strings.TrimPrefix(e.getenv("GITHUB_SERVER_URL"), "https://")
+		if server == "" {
+			server = "github.com"
+		}
+		user, token, url = "x-access-token", e.getenv("GITHUB_TOKEN"), fmt.Sprintf("https://%s/%s.git", server, repo)
+		if repo == "" {
+			token = ""
+		}
+	case GitLabCI:
+		// Job tokens can push when the project allows it (CI/CD settings > Job token permissions)
+		host, project := e.getenv("CI_SERVER_HOST"), e.getenv("CI_PROJECT_PATH")
+		user, token, url = "gitlab-ci-token", e.getenv("CI_JOB_TOKEN"), fmt.Sprintf("https://%s/%s.git", host, project)
+		if host == "" || project == "" {
+			token = ""
+		}
+	case BitbucketPipelines:
+		// Pipelines has no job token with write access; use a repository access token
+		repo := e.getenv("BITBUCKET_REPO_FULL_NAME")
+		user, token, url = "x-token-auth", e.getenv("BITBUCKET_ACCESS_TOKEN"), fmt.Sprintf("https://bitbucket.org/%s.git", repo)
+		if repo == "" {
+			token = ""
+		}
+	}
+	if token == "" {
+		return PushTarget{}, false
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + token))
+	return PushTarget{URL: url, Authorization: "Basic " + credentials}, true
+}
+
+// Getenv reads a variable from the CI environment
+func (e *Env) Getenv(key string) string {
+	return e.getenv(key)
+}
@@ -0,0 +1,108 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func envFunc(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Provider
+	}{
+		{"github", map[string]string{"GITHUB_ACTIONS": "true"}, GitHubActions},
+		{"gitlab", map[string]string{"GITLAB_CI": "true"}, GitLabCI},
+		{"bitbucket", map[string]string{"BITBUCKET_BUILD_NUMBER": "42"}, BitbucketPipelines},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := Detect(envFunc(tt.env))
+			if env == nil || env.Provider != tt.want {
+				t.Errorf("Detect() = %v, want %s", env, tt.want)
+			}
+		})
+	}
+
+	if env := Detect(envFunc(nil)); env != nil {
+		t.Errorf("Detect() outside CI = %v, want nil", env)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	var out bytes.Buffer
+	github := Detect(envFunc(map[string]string{"GITHUB_ACTIONS": "true"}))
+	github.GroupStart(&out, "shard-1", "shard 1/2 passed")
+	github.GroupEnd(&out, "shard-1")
+	if out.String() != "::group::shard 1/2 passed\n::endgroup::\n" {
+		t.Errorf("GitHub groups = %q", out.String())
+	}
+
+	out.Reset()
+	gitlab := Detect(envFunc(map[string]string{"GITLAB_CI": "true"}))
+	gitlab.GroupStart(&out, "shard 1", "shard 1/2 passed")
+	gitlab.GroupEnd(&out, "shard 1")
+	if !strings.Contains(out.String(), ":shard_1[collapsed=true]\r\x1b[0Kshard 1/2 passed\n") || !strings.Contains(out.String(), "section_end:") {
+		t.Errorf("GitLab groups = %q", out.String())
+	}
+
+	out.Reset()
+	bitbucket := Detect(envFunc(map[string]string{"BITBUCKET_BUILD_NUMBER": "1"}))
+	bitbucket.GroupStart(&out, "shard-1", "shard 1/2 passed")
+	bitbucket.GroupEnd(&out, "shard-1")
+	if out.String() != "=== shard 1/2 passed ===\n" {
+		t.Errorf("Bitbucket groups = %q", out.String())
+	}
+}
+
+func TestPushTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		url  string
+		auth string // user:token
+	}{
+		{
+			"github",
+			map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_TOKEN": "ghs_x", "GITHUB_REPOSITORY": "obra/packnplay"},
+			"https://github.com/obra/packnplay.git",
+			"x-access-token:ghs_x",
+		},
+		{
+			"gitlab",
+			map[string]string{"GITLAB_CI": "true", "CI_JOB_TOKEN": "tok", "CI_SERVER_HOST": "gitlab.example.com", "CI_PROJECT_PATH": "team/app"},
+			"https://gitlab.example.com/team/app.git",
+			"gitlab-ci-token:tok",
+		},
+		{
+			"bitbucket",
+			map[string]string{"BITBUCKET_BUILD_NUMBER": "1", "BITBUCKET_ACCESS_TOKEN": "tok", "BITBUCKET_REPO_FULL_NAME": "team/app"},
+			"https://bitbucket.org/team/app.git",
+			"x-token-auth:tok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Detect(envFunc(tt.env)).PushTarget()
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte(tt.auth))
+			if !ok || got.URL != tt.url || got.Authorization != want {
+				t.Errorf("PushTarget() = %+v, %v; want %s with %s", got, ok, tt.url, tt.auth)
+			}
+		})
+	}
+
+	if _, ok := Detect(envFunc(map[string]string{"GITLAB_CI": "true"})).PushTarget(); ok {
+		t.Error("PushTarget() without a job token should report false")
+	}
+	if _, ok := Detect(envFunc(map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_TOKEN": "ghs_x"})).PushTarget(); ok {
+		t.Error("PushTarget() without a repository should report false")
+	}
+}
@@ -0,0 +1,171 @@
+package cmdhistory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/sessionenv"
+)
+
+// ContainerDir is where a session's history directory is mounted
+const ContainerDir = "/run/packnplay-history"
+
+// Files in the history directory
+const (
+	logName    = "commands.log"
+	hookName   = "bash_env.sh"
+	promptName = "prompt.sh"
+)
+
+// Sources of a command
+const (
+	SourceCommand = "command" // bash -c, which is how agents run their tool calls
+	SourceShell   = "shell"   // a line typed at an interactive shell
+)
+
+// Entry is one command run in a session
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Dir     string    `json:"dir"`
+	Command string    `json:"command"`
+}
+
+// Records are NUL-terminated fields, since NUL is the one byte no shell string contains
+const recordFields = 4
+
+// record prints a record from the shell; a single printf is a single append, so
+// commands run at the same time don't interleave
+const record = `printf '%s\0%s\0%s\0%s\0' "${EPOCHREALTIME:-$(date +%s)}" `
+
+// hookScript is BASH_ENV for the session: it applies 'packnplay env' changes, then records
+// the command a top-level bash -c was started with. Commands it starts inherit the marker,
+// so a tool call's own scripts aren't recorded again.
+const hookScript = "# packnplay session hook\n" +
+	sessionenv.Source + "\n" +
+	`if [ -n "${BASH_EXECUTION_STRING:-}" ] && [ -z "${PACKNPLAY_HISTORY_SEEN:-}" ]; then` + "\n" +
+	"\t" + record + SourceCommand + ` "$PWD" "$BASH_EXECUTION_STRING" >> ` + ContainerDir + "/" + logName + " 2>/dev/null\n" +
+	"\texport PACKNPLAY_HISTORY_SEEN=1\n" +
+	"fi\n"
+
+// promptScript runs before each interactive prompt and records the line just entered
+// The first prompt only notes where history starts, so a persisted HISTFILE isn't replayed.
+const promptScript = "# packnplay interactive history\n" +
+	"export PACKNPLAY_HISTORY_SEEN=1\n" +
+	`__packnplay_re='^ *([0-9]+)[* ] *(.*)$'` + "\n" +
+	`__packnplay_num= __packnplay_cmd=` + "\n" +
+	`if [[ $(HISTTIMEFORMAT= history 1) =~ $__packnplay_re ]]; then __packnplay_num=${BASH_REMATCH[1]} __packnplay_cmd=${BASH_REMATCH[2]}; fi` + "\n" +
+	`if [ "${__packnplay_seen-unset}" != unset ] && [ -n "$__packnplay_num" ] && [ "$__packnplay_num" != "$__packnplay_seen" ]; then` + "\n" +
+	"\t" + record + SourceShell + ` "$PWD" "$__packnplay_cmd" >> ` + ContainerDir + "/" + logName + " 2>/dev/null\n" +
+	"fi\n" +
+	"__packnplay_seen=$__packnplay_num\n"
+
+func getStateDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "commands")
+}
+
+// Dir returns the host directory mounted at ContainerDir
+// It outlives the session, so its history is there for a post-mortem.
+func Dir(containerName string) string {
+	return filepath.Join(getStateDir(), containerName)
+}
+
+// Prepare writes a session's hooks and returns docker args that mount them and point
+// bash at them. The container writes the log, so it's the session's own account of what ran.
+func Prepare(containerName string) ([]string, error) {
+	dir := Dir(containerName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history dir: %w", err)
+	}
+	for name, content := range map[string]string{hookName: hookScript, promptName: promptScript} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write history hook: %w", err)
+		}
+	}
+	// A restarted session keeps appending to the same log
+	log, err := os.OpenFile(filepath.Join(dir, logName), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create history log: %w", err)
+	}
+	log.Close()
+
+	return []string{
+		"-v", fmt.Sprintf("%s:%s", dir, ContainerDir),
+		"-e", "BASH_ENV=" + ContainerDir + "/" + hookName,
+		"-e", "PROMPT_COMMAND=. " + ContainerDir + "/" + promptName,
+	}, nil
+}
+
+// Load returns a session's commands in the order they started
+func Load(containerName string) ([]Entry, error) {
+	data, err := encryption.ReadStored(filepath.Join(Dir(containerName), logName))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no command history for %s", containerName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command history: %w", err)
+	}
+	return Parse(data), nil
+}
+
+// Seal encrypts a finished session's log with the storage key, when storage is encrypted
+// The container writes the log in the clear, since the key never goes into a sandbox.
+func Seal(containerName string) error {
+	if err := encryption.SealFile(filepath.Join(Dir(containerName), logName)); err != nil {
+		return fmt.Errorf("failed to encrypt command history: %w", err)
+	}
+	return nil
+}
+
+// Parse decodes a history log, dropping a record cut short by a crash
+func Parse(data []byte) []Entry {
+	fields := bytes.Split(data, []byte{0})
+	fields = fields[:len(fields)-1] // the last field ends in NUL, leaving nothing after it
+
+	var entries []Entry
+	for i := 0; i+recordFields <= len(fields); i += recordFields {
+		entries = append(entries, Entry{
+			Time:    parseTime(string(fields[i])),
+			Source:  string(fields[i+1]),
+			Dir:     string(fields[i+2]),
+			Command: string(fields[i+3]),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries
+}
+
+// parseTime reads $EPOCHREALTIME, whose decimal separator follows the locale, or $(date +%s)
+func parseTime(value string) time.Time {
+	seconds, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return time.Time{}
+	}
+	whole := int64(seconds)
+	return time.Unix(whole, int64((seconds-float64(whole))*1e9)).UTC()
+}
+
+// WriteJSON writes entries as JSON lines, for tools that process a session's history
+func WriteJSON(w io.Writer, entries []Entry) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to encode command history: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmdhistory
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte("1700000001.500000\x00command\x00/workspace\x00npm test\x00" +
+		"1700000000,250000\x00shell\x00/workspace/src\x00git status\nls\x00" +
+		"1700000002\x00command\x00/workspace\x00rm -rf") // cut short
+	entries := Parse(data)
+	if len(entries) != 2 {
+		t.Fatalf("Parse() = %d entries, want 2: %+v", len(entries), entries)
+	}
+	if entries[0].Command != "git status\nls" || entries[0].Source != SourceShell || entries[0].Dir != "/workspace/src" {
+		t.Errorf("first entry = %+v", entries[0])
+	}
+	if entries[1].Command != "npm test" || entries[1].Time.Unix() != 1700000001 {
+		t.Errorf("second entry = %+v", entries[1])
+	}
+	if got := entries[0].Time.Sub(time.Unix(1700000000, 0)); got < 249*time.Millisecond || got > 251*time.Millisecond {
+		t.Errorf("comma decimal parsed as %v past the second", got)
+	}
+	if Parse(nil) != nil {
+		t.Error("Parse(nil) should be empty")
+	}
+}
+
+func TestHookRecordsTopLevelCommands(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	dir := t.TempDir()
+	hook := filepath.Join(dir, hookName)
+	if err := os.WriteFile(hook, []byte(strings.ReplaceAll(hookScript, ContainerDir, dir)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bash, "-c", `echo outer; bash -c "echo nested"`)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "BASH_ENV="+hook)
+	cmd.Env = append(cmd.Env, "PACKNPLAY_HISTORY_SEEN=")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, output)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := Parse(data)
+	if len(entries) != 1 {
+		t.Fatalf("recorded %d commands, want only the top-level one: %+v", len(entries), entries)
+	}
+	if entries[0].Command != `echo outer; bash -c "echo nested"` || entries[0].Source != SourceCommand || entries[0].Dir != dir {
+		t.Errorf("entry = %+v", entries[0])
+	}
+	if time.Since(entries[0].Time) > time.Minute {
+		t.Errorf("entry time = %v", entries[0].Time)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	entries := []Entry{{Time: time.Unix(1700000000, 0).UTC(), Source: SourceCommand, Dir: "/workspace", Command: "go test ./..."}}
+	if err := WriteJSON(&out, entries); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"2023-11-14T22:13:20Z","source":"command","dir":"/workspace","command":"go test ./..."}` + "\n"
+	if out.String() != want {
+		t.Errorf("WriteJSON() = %s, want %s", out.String(), want)
+	}
+}
@@ -8,15 +8,264 @@ import (
 	"path/filepath"
 
 	"github.com/charmbracelet/huh"
+	"github.com/obra/packnplay/pkg/agents"
 )
 
 // Config represents packnplay's configuration
 type Config struct {
-	ContainerRuntime   string               `json:"container_runtime"` // docker, podman, or container
-	DefaultImage       string               `json:"default_image"`     // default container image to use
-	DefaultCredentials Credentials          `json:"default_credentials"`
-	DefaultEnvVars     []string             `json:"default_env_vars"` // API keys to always proxy
-	EnvConfigs         map[string]EnvConfig `json:"env_configs"`
+	ContainerRuntime   string                      `json:"container_runtime"` // docker, podman, or container
+	DefaultImage       string                      `json:"default_image"`     // default container image to use
+	LanguageImages     map[string]string           `json:"language_images"`   // detected project type (go, node, python, ...) -> base image used instead of default_image
+	DefaultCredentials Credentials                 `json:"default_credentials"`
+	DefaultEnvVars     []string                    `json:"default_env_vars"` // API keys to always proxy
+	EnvConfigs         map[string]EnvConfig        `json:"env_configs"`
+	NetworkPolicy      NetworkPolicy               `json:"network_policy"`
+	HostBroker         HostBroker                  `json:"host_broker"`
+	Home               HomeConfig                  `json:"home"`
+	AgentMounts        map[string]string           `json:"agent_mounts"`      // agent name -> "full" (default) or "minimal"
+	AgentTemplates     map[string]string           `json:"agent_templates"`   // agent name -> directory that seeds a scratch config dir on hosts without one, e.g. in CI
+	MountConsistency   map[string]string           `json:"mount_consistency"` // workspace/git/agents/home -> consistent, cached or delegated (macOS)
+	SELinuxLabels      map[string]string           `json:"selinux_labels"`    // workspace/git/agents/home/credentials/read_only -> z, Z or none
+	EncryptStorage     bool                        `json:"encrypt_storage"`   // encrypt exports and stored session data with the local storage key
+	Policies           []string                    `json:"policies"`          // Rego files or directories evaluated before every container start
+	Caches             map[string]CacheVolume      `json:"caches"`            // cache name -> shared volume mounted into every session
+	Logging            LoggingConfig               `json:"logging"`
+	BurnerCredentials  map[string]BurnerCredential `json:"burner_credentials"` // provider -> per-session key minting
+	RecordSessions     bool                        `json:"record_sessions"`    // record every session's terminal for 'packnplay replay'
+	PublishLAN         bool                        `json:"publish_lan"`        // publish every session's ports on the LAN under an mDNS name
+	MountHooks         []MountHook                 `json:"mount_hooks"`        // programs that sanitize agent config files before the container sees them
+	Shell              ShellConfig                 `json:"shell"`              // interactive shell baked into every sandbox image
+	Artifacts          ArtifactsConfig             `json:"artifacts"`          // session outputs uploaded to object storage when a container stops
+	Labels             map[string]string           `json:"labels"`             // extra labels on every container and volume, e.g. cost-center
+	DevCerts           DevCertsConfig              `json:"dev_certs"`          // locally trusted HTTPS certificates for dev servers
+	WorkspaceMode      string                      `json:"workspace_mode"`     // "bind" (default), "sync" (a copy written back at idle points), "split" (an overlay committed to a session branch) or "prompt" (writes outside the write policy wait for approval)
+	ImageTrust         ImageTrustConfig            `json:"image_trust"`        // signature checks on session images before they run
+	DisableTelemetry   bool                        `json:"disable_telemetry"`  // set every agent's documented telemetry opt-outs in sessions
+	Display            DisplayConfig               `json:"display"`            // a screen for agents that drive a headed browser
+	Hostname           string                      `json:"hostname"`           // sandbox hostname (default: the container ID)
+	ExtraHosts         map[string]string           `json:"extra_hosts"`        // /etc/hosts entries, name -> IP (or host-gateway), e.g. staging domains -> a tunnel
+	GitHubApp          GitHubAppConfig             `json:"github_app"`         // repo-scoped installation tokens instead of the host's GH_TOKEN
+	SecurityProfile    string                      `json:"security_profile"`   // "" (standard) or "paranoid", which turns on read_only_root
+	ReadOnlyRoot       bool                        `json:"read_only_root"`     // read-only root filesystem; only scratch space, home and writable_paths can be written
+	WritablePaths      []string                    `json:"writable_paths"`     // extra paths given an empty tmpfs on a read-only root
+	SessionStore       string                      `json:"session_store"`      // shared session registry: s3://bucket/key.json, postgres://... or sqlite:///path (default: a local file)
+	Tailnet            TailnetConfig               `json:"tailnet"`            // attach sessions to a tailnet or WireGuard network through a sidecar
+	GitIdentity        GitIdentityConfig           `json:"git_identity"`       // commit as the agent, with trailers naming the session
+	Ulimits            map[string]string           `json:"ulimits"`            // resource limits, name -> "soft[:hard]", e.g. nofile -> "65536"
+	Sysctls            map[string]string           `json:"sysctls"`            // namespaced kernel settings, e.g. net.ipv4.ip_local_port_range
+	Summaries          SummariesConfig             `json:"summaries"`          // one-line summaries of what recorded sessions are doing
+	DetachKeys         string                      `json:"detach_keys"`        // keys that detach from a session, leaving it running, e.g. ctrl-p,ctrl-q ("" hands the terminal straight to the runtime)
+	StatusBar          StatusBarConfig             `json:"status_bar"`         // a line of session status in attached sessions; needs detach_keys
+	Bazel              BazelConfig                 `json:"bazel"`              // caches and remote cache access for Bazel workspaces
+	Reviewers          []ReviewerConfig            `json:"reviewers"`          // agents or services that review a session's diff, run by 'packnplay review' and before --auto-pr
+	Integrity          IntegrityConfig             `json:"integrity"`          // canaries and host file checks that catch writes escaping the sandbox
+}
+
+// SecurityProfileParanoid hardens sessions beyond the defaults: a read-only root filesystem
+const SecurityProfileParanoid = "paranoid"
+
+// ReadOnlyRootEnabled reports whether sessions run on a read-only root filesystem
+func (c *Config) ReadOnlyRootEnabled() bool {
+	return c.ReadOnlyRoot || c.SecurityProfile == SecurityProfileParanoid
+}
+
+// IntegrityEnabled reports whether sessions are checked for writes outside their mounts
+func (c *Config) IntegrityEnabled() bool {
+	return c.Integrity.Enabled || c.SecurityProfile == SecurityProfileParanoid
+}
+
+// IntegrityConfig plants canaries next to a session's writable mounts and fingerprints
+// host files, then checks nothing changed once the session is torn down
+type IntegrityConfig struct {
+	Enabled bool     `json:"enabled"`
+	Watch   []string `json:"watch"` // more host files to check, e.g. ~/.aws/credentials
+}
+
+// ShellConfig sets up the shell users land in when debugging inside a sandbox
+type ShellConfig struct {
+	Name      string   `json:"name"`      // bash, zsh or fish ("" keeps the image's shell)
+	Prompt    string   `json:"prompt"`    // "starship", "minimal" or "" for the shell's default
+	Utilities []string `json:"utilities"` // extra apt packages, e.g. ripgrep, fzf
+}
+
+// Empty reports whether no shell setup is configured
+func (s ShellConfig) Empty() bool {
+	return s.Name == "" && s.Prompt == "" && len(s.Utilities) == 0
+}
+
+// BazelConfig tunes sessions in Bazel workspaces, which otherwise rebuild from scratch
+// every time: the remote cache, and what it needs from the host to authenticate
+type BazelConfig struct {
+	Disabled          bool              `json:"disabled"`           // treat Bazel workspaces like any other project
+	RemoteCache       string            `json:"remote_cache"`       // e.g. grpcs://remote.buildbuddy.io
+	RemoteHeaders     map[string]string `json:"remote_headers"`     // header -> value sent to the remote cache; ${VAR} reads the host environment
+	GoogleCredentials string            `json:"google_credentials"` // host service account key for GCS or Google remote caches
+	Env               []string          `json:"env"`                // host env vars passed through, e.g. for credential helpers
+}
+
+// SummariesConfig periodically summarizes recorded sessions' transcripts, for 'packnplay sessions'
+type SummariesConfig struct {
+	Enabled  bool     `json:"enabled"`  // turns on recording for interactive sessions too
+	Interval string   `json:"interval"` // how often new output is looked at (default 2m)
+	Command  []string `json:"command"`  // host command reading the prompt on stdin, e.g. ["ollama", "run", "llama3.2"]; default: the session's agent in headless mode
+}
+
+// StatusBarConfig draws a line of session status along the edge of attached sessions
+type StatusBarConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Position    string   `json:"position"`      // "bottom" (default) or "top"
+	Items       []string `json:"items"`         // session, elapsed, cost, network and detach, in the order given (default: all)
+	ToggleKeys  string   `json:"toggle_keys"`   // keys that hide and show it, e.g. ctrl-p,ctrl-b (the default)
+	CostPerHour float64  `json:"cost_per_hour"` // estimated dollars per session hour, for the cost item (0 leaves it out)
+}
+
+// ReviewerConfig is one reviewer of a session's diff: a host command given the diff on
+// stdin, an agent run headless in the session, or a service the diff is POSTed to
+type ReviewerConfig struct {
+	Name    string            `json:"name"`
+	Command []string          `json:"command"` // e.g. ["review-bot", "--strict"]; PACKNPLAY_TASK etc. are set
+	Agent   string            `json:"agent"`   // e.g. "codex", for a second opinion from another model
+	URL     string            `json:"url"`     // receives the session, task and diff as JSON
+	Headers map[string]string `json:"headers"` // sent with url; ${VAR} is expanded from the environment
+	Gate    bool              `json:"gate"`    // a rejection, or no answer, blocks --auto-pr
+}
+
+// DevCertsConfig gives sessions a certificate host browsers trust, for HTTPS dev servers
+// on forwarded ports. By default mkcert issues it; cert_file/key_file forward existing ones.
+type DevCertsConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Hosts    []string `json:"hosts"`     // names on the mkcert certificate (default localhost, 127.0.0.1, ::1)
+	CertFile string   `json:"cert_file"` // existing certificate to forward instead
+	KeyFile  string   `json:"key_file"`
+	CAFile   string   `json:"ca_file"` // CA to trust inside the container, if not publicly trusted
+}
+
+// ImageTrustConfig is the trust policy for images sessions run in
+type ImageTrustConfig struct {
+	Policy        string            `json:"policy"`         // "off" (default), "warn" or "enforce"
+	Keys          []string          `json:"keys"`           // cosign public keys: files, or KMS URIs like awskms://...
+	Keyless       []KeylessIdentity `json:"keyless"`        // Sigstore keyless signers
+	TrustLockfile bool              `json:"trust_lockfile"` // digests pinned in the committed .packnplay.lock need no signature
+}
+
+// DisplayConfig gives sessions a display, for browser automation in headed mode
+type DisplayConfig struct {
+	Mode       string `json:"mode"`       // "host" (forward X11/Wayland, Linux) or "vnc" (virtual display viewable with noVNC); "" for none
+	VNCImage   string `json:"vnc_image"`  // sidecar image running Xvfb and noVNC (default theasp/novnc:latest)
+	Resolution string `json:"resolution"` // virtual screen size, e.g. 1920x1080
+}
+
+// TailnetConfig gives each session its own node on a private network, so agents reach
+// internal services under that network's ACLs instead of with the host's identity
+type TailnetConfig struct {
+	Mode            string   `json:"mode"`             // "tailscale" or "wireguard"; "" for none
+	AuthKeyEnv      string   `json:"auth_key_env"`     // host variable holding a Tailscale auth key or OAuth client secret (default TS_AUTHKEY)
+	Tags            []string `json:"tags"`             // ACL tags the session's node advertises, e.g. tag:agent
+	WireGuardConfig string   `json:"wireguard_config"` // wg-quick config file for mode wireguard
+	Image           string   `json:"image"`            // sidecar image (default tailscale/tailscale:stable, or linuxserver/wireguard)
+}
+
+// GitIdentityConfig makes commits inside sandboxes auditable: they're authored by the
+// agent and carry a Packnplay-Session trailer
+type GitIdentityConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Name     string `json:"name"`      // author name; {agent} is replaced with the agent (default "{agent} via packnplay")
+	Email    string `json:"email"`     // author email (default noreply@packnplay.invalid)
+	CoAuthor bool   `json:"co_author"` // credit your host git identity with a Co-authored-by trailer
+}
+
+// GitHubAppConfig mints a GitHub App installation token per session, scoped to the
+// workspace's repository, in place of the host's personal GH_TOKEN/GITHUB_TOKEN
+type GitHubAppConfig struct {
+	AppID          int64             `json:"app_id"`
+	PrivateKey     string            `json:"private_key"`     // the app's PEM private key file
+	InstallationID int64             `json:"installation_id"` // default: looked up from the repository
+	Permissions    map[string]string `json:"permissions"`     // e.g. {"contents": "write"} (default contents and metadata read)
+	APIURL         string            `json:"api_url"`         // GitHub Enterprise API, e.g. https://ghe.example.com/api/v3
+}
+
+// KeylessIdentity is a Sigstore keyless signer, e.g. a CI workflow
+type KeylessIdentity struct {
+	Identity string `json:"identity"` // regexp for the certificate identity, e.g. https://github.com/obra/.*
+	Issuer   string `json:"issuer"`   // OIDC issuer, e.g. https://token.actions.githubusercontent.com
+}
+
+// ArtifactsConfig uploads a session's outputs when its container is stopped
+type ArtifactsConfig struct {
+	Destination string   `json:"destination"` // s3://bucket/prefix, gs://bucket/prefix or az://account/container/prefix
+	Paths       []string `json:"paths"`       // workspace-relative globs, or absolute container paths
+	Diff        bool     `json:"diff"`        // also upload the session's changes as session.diff
+}
+
+// Enabled reports whether artifacts should be uploaded
+func (a ArtifactsConfig) Enabled() bool {
+	return a.Destination != ""
+}
+
+// MountHook transforms a host config file before it's exposed to the container
+// The command gets the original on stdin and prints the sanitized version, which is
+// mounted in place of the original; the host file is never changed.
+type MountHook struct {
+	Path    string   `json:"path"`    // file under the host home, e.g. ".claude/settings.json"
+	Command []string `json:"command"` // e.g. ["jq", "del(.mcpServers)"]
+}
+
+// BurnerCredential mints a short-lived, project-scoped API key for each session
+// and revokes it when the container stops, so a leaked key is worthless afterwards.
+type BurnerCredential struct {
+	ProjectID   string `json:"project_id"`    // provider project the key is scoped to
+	AdminKeyEnv string `json:"admin_key_env"` // host env var holding the admin key used to mint (default OPENAI_ADMIN_KEY)
+}
+
+// LoggingConfig controls where background processes (credential watcher, host broker,
+// metrics server) and session containers send their logs
+type LoggingConfig struct {
+	Driver        string `json:"driver"`         // "" (runtime default), "file", "syslog" or "journald"
+	Path          string `json:"path"`           // log file for the file driver
+	MaxSizeMB     int    `json:"max_size_mb"`    // rotate the file after this size (default 10)
+	MaxFiles      int    `json:"max_files"`      // rotated files to keep (default 5)
+	SyslogAddress string `json:"syslog_address"` // remote syslog, e.g. udp://logs.example.com:514 (default: local)
+}
+
+// CacheVolume is a dependency cache shared across sessions, and optionally across a team
+// With no backend it's a local named volume; NFS, SMB or a volume plugin (e.g. an S3 driver)
+// let every developer's sandboxes mount the same warm cache.
+type CacheVolume struct {
+	Target     string            `json:"target"`      // container path; ~/ is the container user's home
+	NFS        string            `json:"nfs"`         // host:/export
+	SMB        string            `json:"smb"`         // //host/share[/path]
+	Options    string            `json:"options"`     // extra mount options, e.g. "nfsvers=4,soft" or "username=ci"
+	Driver     string            `json:"driver"`      // volume plugin for other backends
+	DriverOpts map[string]string `json:"driver_opts"` // options passed to the plugin
+}
+
+// HostBroker controls which host actions containers may trigger via the broker socket
+type HostBroker struct {
+	Enabled  bool     `json:"enabled"`
+	Allow    []string `json:"allow"`     // open, copy, notify, git-push, sparse-add
+	GitHosts []string `json:"git_hosts"` // host[/path] prefixes git-push may push to, e.g. github.com/my-org
+}
+
+// HomeConfig controls the container home directory
+// By default it's a tmpfs, so stray agent writes to $HOME vanish with the container.
+type HomeConfig struct {
+	Persistent     bool     `json:"persistent"`      // keep the image's writable home instead of a tmpfs
+	PersistHistory bool     `json:"persist_history"` // keep shell history across sessions
+	Persist        []string `json:"persist"`         // extra home subpaths kept across sessions, e.g. ".cache/pip"
+}
+
+// NetworkPolicy controls what the sandbox may reach on the network
+type NetworkPolicy struct {
+	Blocklists       []string          `json:"blocklists"`        // e.g., "exfiltration", "typosquat"
+	BlocklistSources map[string]string `json:"blocklist_sources"` // blocklist name -> URL for `packnplay policy update`
+	Window           NetworkWindow     `json:"window"`
+}
+
+// NetworkWindow leaves the network open for a while after start (e.g. to install
+// dependencies), then cuts it off except for HTTPS to a few domains
+type NetworkWindow struct {
+	OpenFor string   `json:"open_for"` // e.g. "5m"; empty disables the window
+	Allow   []string `json:"allow"`    // domains still reachable afterwards (default: model APIs)
 }
 
 // EnvConfig defines environment variables for different setups (API configs, etc.)
@@ -24,6 +273,39 @@ type EnvConfig struct {
 	Name        string            `json:"name"`
 	Description string            `json:"description"`
 	EnvVars     map[string]string `json:"env_vars"`
+	Ulimits     map[string]string `json:"ulimits"` // override the top-level ulimits for this profile
+	Sysctls     map[string]string `json:"sysctls"` // override the top-level sysctls for this profile
+	AzureOpenAI *AzureConfig      `json:"azure_openai"`
+}
+
+// AzureConfig points agents that support it at an Azure OpenAI resource
+// Values may use ${VAR} like env_vars. APIKey defaults to ${AZURE_OPENAI_API_KEY}.
+type AzureConfig struct {
+	Endpoint   string `json:"endpoint"`    // https://<resource>.openai.azure.com
+	Deployment string `json:"deployment"`  // model deployment name
+	APIVersion string `json:"api_version"` // default: agents.DefaultAzureAPIVersion
+	APIKey     string `json:"api_key"`
+}
+
+// Limits returns the ulimits and sysctls for a session started with an env config profile
+// ("" for none); the profile's settings win over the top-level ones
+func (c *Config) Limits(profile string) (map[string]string, map[string]string) {
+	envConfig := c.EnvConfigs[profile]
+	return mergeSettings(c.Ulimits, envConfig.Ulimits), mergeSettings(c.Sysctls, envConfig.Sysctls)
+}
+
+func mergeSettings(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
 }
 
 // Credentials specifies which credentials to mount
@@ -61,9 +343,9 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	cfg, err := decodeConfig(configPath, data)
+	if err != nil {
+		return nil, err
 	}
 
 	// If container_runtime is not set, prompt for it
@@ -76,7 +358,7 @@ func Load() (*Config, error) {
 		cfg.DefaultImage = "ghcr.io/obra/packnplay-default:latest"
 	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
 // LoadWithoutRuntimeCheck loads config without prompting for runtime
@@ -94,9 +376,9 @@ func LoadWithoutRuntimeCheck() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	cfg, err := decodeConfig(configPath, data)
+	if err != nil {
+		return nil, err
 	}
 
 	// Set default image if not configured (backward compatibility)
@@ -104,6 +386,21 @@ func LoadWithoutRuntimeCheck() (*Config, error) {
 		cfg.DefaultImage = "ghcr.io/obra/packnplay-default:latest"
 	}
 
+	return cfg, nil
+}
+
+// decodeConfig parses the config file, pointing at the line of anything it can't read
+// and warning about fields it doesn't know, which would otherwise be silently ignored
+func decodeConfig(configPath string, data []byte) (*Config, error) {
+	issues, _ := Validate(data, UserSchema(), true)
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		if len(issues) > 0 {
+			return nil, fmt.Errorf("failed to parse config: %w", issuesError(configPath, issues))
+		}
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	warnIssues(configPath, issues)
 	return &cfg, nil
 }
 
@@ -216,19 +513,8 @@ func interactiveSetup(configPath string) (*Config, error) {
 			GPG: gpgCreds,
 			NPM: npmCreds,
 		},
-		DefaultEnvVars: []string{
-			"ANTHROPIC_API_KEY",
-			"OPENAI_API_KEY",
-			"GEMINI_API_KEY",
-			"GOOGLE_API_KEY",
-			"GH_TOKEN",
-			"GITHUB_TOKEN",
-			"QWEN_API_KEY",
-			"CURSOR_API_KEY",
-			"AMP_API_KEY",
-			"DEEPSEEK_API_KEY",
-		},
-		EnvConfigs: make(map[string]EnvConfig),
+		DefaultEnvVars: agents.GetDefaultEnvVars(),
+		EnvConfigs:     make(map[string]EnvConfig),
 	}
 
 	if saveConfig {
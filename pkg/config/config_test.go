@@ -132,4 +132,27 @@ func TestDetectAvailableRuntimes(t *testing.T) {
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
-}
\ No newline at end of file
+}
+
+func TestLimits(t *testing.T) {
+	cfg := &Config{
+		Ulimits: map[string]string{"nofile": "65536", "nproc": "4096"},
+		Sysctls: map[string]string{"net.core.somaxconn": "1024"},
+		EnvConfigs: map[string]EnvConfig{
+			"ci": {Ulimits: map[string]string{"nofile": "1048576"}},
+		},
+	}
+
+	ulimits, sysctls := cfg.Limits("")
+	if ulimits["nofile"] != "65536" || sysctls["net.core.somaxconn"] != "1024" {
+		t.Errorf("Limits(\"\") = %v, %v", ulimits, sysctls)
+	}
+
+	ulimits, sysctls = cfg.Limits("ci")
+	if ulimits["nofile"] != "1048576" || ulimits["nproc"] != "4096" || sysctls["net.core.somaxconn"] != "1024" {
+		t.Errorf("Limits(\"ci\") = %v, %v", ulimits, sysctls)
+	}
+	if cfg.Ulimits["nofile"] != "65536" {
+		t.Errorf("profile override changed the top-level ulimits: %v", cfg.Ulimits)
+	}
+}
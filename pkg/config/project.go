@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the per-project config file, committed at the project root
+const ProjectConfigFile = ".packnplay.yaml"
+
+// ProjectConfig is configuration shared by everyone working on a project
+type ProjectConfig struct {
+	AgentVersions map[string]string `yaml:"agent_versions"` // agent name -> npm version or range, e.g. claude: "1.x"
+	Packages      Packages          `yaml:"packages"`
+	ReadOnly      []ReadOnlyMount   `yaml:"read_only_mounts"`
+	Agents        AgentRules        `yaml:"agents"`
+	Nix           string            `yaml:"nix"`    // "off" runs commands as-is despite a flake.nix or devenv.nix
+	Labels        map[string]string `yaml:"labels"` // cost-allocation labels for this project's sessions, e.g. team; override the config's
+	Writes        WritePolicy       `yaml:"writes"` // which workspace files agents may change
+}
+
+// WritePolicy limits which workspace files agents may change, by glob relative to the
+// project root: * matches within a path segment, ** across segments, and a pattern
+// without a / matches the file name at any depth
+type WritePolicy struct {
+	Allow []string `yaml:"allow"` // only matching files may change (empty allows any), e.g. src/**
+	Deny  []string `yaml:"deny"`  // matching files may never change, e.g. .github/**
+}
+
+// Empty reports whether the policy allows every change
+func (w WritePolicy) Empty() bool {
+	return len(w.Allow) == 0 && len(w.Deny) == 0
+}
+
+// AgentRules restricts which agents may run in a project, for model-governance rules
+type AgentRules struct {
+	Allow      []string            `yaml:"allow"`       // only these agents may run (empty allows any)
+	Deny       []string            `yaml:"deny"`        // these agents may never run
+	RequireEnv map[string][]string `yaml:"require_env"` // agent -> KEY=value (or KEY, any value) it must run with
+}
+
+// Names returns every agent the rules mention
+func (r AgentRules) Names() []string {
+	names := append(append([]string(nil), r.Allow...), r.Deny...)
+	for name := range r.RequireEnv {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Check returns an error if agent may not run here with the session environment getenv sees
+func (r AgentRules) Check(agent string, getenv func(string) string) error {
+	if slices.Contains(r.Deny, agent) {
+		return fmt.Errorf("%s doesn't allow %s in this project", ProjectConfigFile, agent)
+	}
+	if len(r.Allow) > 0 && !slices.Contains(r.Allow, agent) {
+		return fmt.Errorf("%s only allows %s in this project, not %s", ProjectConfigFile, strings.Join(r.Allow, ", "), agent)
+	}
+	for _, required := range r.RequireEnv[agent] {
+		key, want, hasValue := strings.Cut(required, "=")
+		got := getenv(key)
+		if got == "" || (hasValue && got != want) {
+			return fmt.Errorf("%s requires %s to run with %s in this project; set it with --env or an env config (--config)", ProjectConfigFile, agent, required)
+		}
+	}
+	return nil
+}
+
+// ReadOnlyMount exposes a host path outside the project to the sandbox, read-only
+type ReadOnlyMount struct {
+	Path   string `yaml:"path"`   // relative to the project root, ~/, or absolute
+	Target string `yaml:"target"` // container path (default: where Path sits relative to /workspace)
+}
+
+// Packages are extra packages baked into the sandbox image, per package manager
+type Packages struct {
+	Apt  []string `yaml:"apt"`  // e.g. jq, protobuf-compiler
+	Brew []string `yaml:"brew"` // needs Homebrew in the image
+	NPM  []string `yaml:"npm"`  // installed globally, e.g. typescript@5
+	Pip  []string `yaml:"pip"`  // e.g. black==24.4.2
+
+	Browsers bool `yaml:"browsers"` // Chromium's system libraries and fonts for Playwright, with a shared browser cache
+}
+
+// Empty reports whether no packages are declared
+func (p Packages) Empty() bool {
+	return len(p.Apt) == 0 && len(p.Brew) == 0 && len(p.NPM) == 0 && len(p.Pip) == 0 && !p.Browsers
+}
+
+// LoadProjectConfig loads .packnplay.yaml from projectPath
+// Returns an empty config if the project has none
+func LoadProjectConfig(projectPath string) (*ProjectConfig, error) {
+	configPath := filepath.Join(projectPath, ProjectConfigFile)
+
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return &ProjectConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ProjectConfigFile, err)
+	}
+
+	issues, _ := Validate(data, ProjectSchema(), false)
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		if len(issues) > 0 {
+			return nil, fmt.Errorf("failed to parse %s: %w", ProjectConfigFile, issuesError(configPath, issues))
+		}
+		return nil, fmt.Errorf("failed to parse %s: %w", ProjectConfigFile, err)
+	}
+	warnIssues(configPath, issues)
+
+	return &cfg, nil
+}
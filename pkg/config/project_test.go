@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProjectConfig(t *testing.T) {
+	projectDir := t.TempDir()
+
+	// Missing file yields an empty config
+	cfg, err := LoadProjectConfig(projectDir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v", err)
+	}
+	if len(cfg.AgentVersions) != 0 {
+		t.Errorf("AgentVersions = %v, want empty", cfg.AgentVersions)
+	}
+
+	content := `agent_versions:
+  claude: "1.x"
+  codex: 0.46.0
+`
+	if err := os.WriteFile(filepath.Join(projectDir, ProjectConfigFile), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write project config: %v", err)
+	}
+
+	cfg, err = LoadProjectConfig(projectDir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v", err)
+	}
+	if cfg.AgentVersions["claude"] != "1.x" || cfg.AgentVersions["codex"] != "0.46.0" {
+		t.Errorf("AgentVersions = %v, want claude=1.x codex=0.46.0", cfg.AgentVersions)
+	}
+}
+
+func TestLoadProjectConfig_Invalid(t *testing.T) {
+	projectDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectDir, ProjectConfigFile), []byte("agent_versions: [unclosed"), 0644); err != nil {
+		t.Fatalf("Failed to write project config: %v", err)
+	}
+
+	if _, err := LoadProjectConfig(projectDir); err == nil {
+		t.Error("LoadProjectConfig() with invalid YAML should fail")
+	}
+}
+
+func TestAgentRulesCheck(t *testing.T) {
+	rules := AgentRules{
+		Allow:      []string{"claude", "gemini"},
+		Deny:       []string{"gemini"},
+		RequireEnv: map[string][]string{"claude": {"CLAUDE_CODE_USE_BEDROCK=1", "AWS_REGION"}},
+	}
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	if err := rules.Check("codex", getenv); err == nil || !strings.Contains(err.Error(), "only allows claude, gemini") {
+		t.Errorf("Check(codex) = %v, want not allowed", err)
+	}
+	if err := rules.Check("gemini", getenv); err == nil || !strings.Contains(err.Error(), "doesn't allow gemini") {
+		t.Errorf("Check(gemini) = %v, want denied", err)
+	}
+	if err := rules.Check("claude", getenv); err == nil || !strings.Contains(err.Error(), "CLAUDE_CODE_USE_BEDROCK=1") {
+		t.Errorf("Check(claude) without Bedrock = %v, want required env error", err)
+	}
+
+	env["CLAUDE_CODE_USE_BEDROCK"] = "0"
+	env["AWS_REGION"] = "us-east-1"
+	if err := rules.Check("claude", getenv); err == nil {
+		t.Error("Check(claude) with CLAUDE_CODE_USE_BEDROCK=0 should fail")
+	}
+	env["CLAUDE_CODE_USE_BEDROCK"] = "1"
+	if err := rules.Check("claude", getenv); err != nil {
+		t.Errorf("Check(claude) on Bedrock = %v, want allowed", err)
+	}
+
+	if err := (AgentRules{}).Check("codex", getenv); err != nil {
+		t.Errorf("empty rules Check() = %v, want any agent allowed", err)
+	}
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the subset of JSON Schema the config files are described and checked with
+// It's generated from the config structs, so it can't drift from what's actually read.
+type Schema struct {
+	Version              string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"` // "" accepts anything
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"` // false, or the *Schema of every value
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+// UserSchema describes ~/.config/packnplay/config.json
+func UserSchema() *Schema {
+	schema := schemaFor(reflect.TypeOf(Config{}), "json")
+	schema.Version, schema.Title = "https://json-schema.org/draft/2020-12/schema", "packnplay config"
+	return schema
+}
+
+// ProjectSchema describes a project's .packnplay.yaml
+func ProjectSchema() *Schema {
+	schema := schemaFor(reflect.TypeOf(ProjectConfig{}), "yaml")
+	schema.Version, schema.Title = "https://json-schema.org/draft/2020-12/schema", ProjectConfigFile
+	return schema
+}
+
+// schemaFor maps a Go type to the JSON it's decoded from, using the tag's field names
+func schemaFor(t reflect.Type, tag string) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), tag)
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem(), tag)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), tag)}
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+				if tag == "yaml" {
+					name = strings.ToLower(name)
+				}
+			}
+			schema.Properties[name] = schemaFor(field.Type, tag)
+		}
+		return schema
+	}
+	return &Schema{}
+}
+
+// Issue is one place a config file doesn't match its schema
+type Issue struct {
+	Line    int
+	Column  int
+	Field   string // dotted path to the value, e.g. caches.gomod.target
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Field, i.Message)
+}
+
+// Validate checks a config file's contents against schema, returning every problem found
+// JSON is read as YAML for positions; strictJSON also checks the JSON syntax and holds
+// scalars to JSON's types, as encoding/json does when the file is loaded.
+func Validate(data []byte, schema *Schema, strictJSON bool) ([]Issue, error) {
+	if strictJSON {
+		var syntax any
+		if err := json.Unmarshal(data, &syntax); err != nil {
+			if syntaxErr, ok := err.(*json.SyntaxError); ok {
+				line, column := position(data, syntaxErr.Offset)
+				return []Issue{{Line: line, Column: column, Message: syntaxErr.Error()}}, nil
+			}
+			return nil, err
+		}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	v := &validator{strictJSON: strictJSON}
+	v.check(doc.Content[0], schema, "")
+	return v.issues, nil
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := strings.Count(string(before), "\n") + 1
+	column := int(offset) - strings.LastIndex(string(before), "\n")
+	return line, column
+}
+
+type validator struct {
+	strictJSON bool
+	issues     []Issue
+}
+
+func (v *validator) fail(node *yaml.Node, field, format string, args ...any) {
+	v.issues = append(v.issues, Issue{Line: node.Line, Column: node.Column, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) check(node *yaml.Node, schema *Schema, field string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if schema.Type == "" || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			v.fail(node, field, "expected an object, got %s", describe(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			path := key.Value
+			if field != "" {
+				path = field + "." + key.Value
+			}
+			if property, ok := schema.Properties[key.Value]; ok {
+				v.check(value, property, path)
+			} else if additional, ok := schema.AdditionalProperties.(*Schema); ok {
+				v.check(value, additional, path)
+			} else {
+				message := fmt.Sprintf("unknown field '%s'", key.Value)
+				if suggestion := closest(key.Value, schema.Properties); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+				}
+				v.fail(key, field, "%s", message)
+			}
+		}
+
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			v.fail(node, field, "expected a list, got %s", describe(node))
+			return
+		}
+		for i, item := range node.Content {
+			v.check(item, schema.Items, fmt.Sprintf("%s[%d]", field, i))
+		}
+
+	default:
+		if node.Kind != yaml.ScalarNode || !v.scalarMatches(node, schema.Type) {
+			v.fail(node, field, "expected %s, got %s", article(schema.Type), describe(node))
+		}
+	}
+}
+
+// scalarMatches reports whether a scalar decodes into the type; YAML is lenient where
+// yaml.v3 is, reading any scalar as a string and yes/no, on/off as booleans
+func (v *validator) scalarMatches(node *yaml.Node, typ string) bool {
+	switch typ {
+	case "string":
+		return node.Tag == "!!str" || (!v.strictJSON && node.Tag != "!!binary")
+	case "boolean":
+		if node.Tag == "!!bool" {
+			return true
+		}
+		switch strings.ToLower(node.Value) {
+		case "yes", "no", "on", "off", "y", "n":
+			return !v.strictJSON
+		}
+		return false
+	case "integer":
+		return node.Tag == "!!int"
+	case "number":
+		return node.Tag == "!!int" || node.Tag == "!!float"
+	}
+	return true
+}
+
+// describe names what a node holds, for error messages
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "an object"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	switch node.Tag {
+	case "!!bool":
+		return "boolean " + node.Value
+	case "!!int", "!!float":
+		return "number " + node.Value
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an integer"
+	}
+	return "a " + typ
+}
+
+// closest returns the known field name nearest to a misspelled one, if any is near enough
+func closest(name string, properties map[string]*Schema) string {
+	best, bestDistance := "", len(name)/3+2
+	candidates := make([]string, 0, len(properties))
+	for candidate := range properties {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(name), candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// warned remembers the issues already reported, since config files are loaded many times
+var warned sync.Map
+
+// warnIssues reports a loaded file's schema problems on stderr, once per process
+// Unknown fields don't stop a session; a typo just means a setting isn't applied.
+func warnIssues(path string, issues []Issue) {
+	for _, issue := range issues {
+		message := fmt.Sprintf("Warning: %s:%s (run 'packnplay config validate')", path, issue)
+		if _, seen := warned.LoadOrStore(message, true); !seen {
+			fmt.Fprintln(os.Stderr, message)
+		}
+	}
+}
+
+// issuesError turns a file's schema problems into one error, one per line
+func issuesError(path string, issues []Issue) error {
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = fmt.Sprintf("%s:%s", path, issue)
+	}
+	return fmt.Errorf("invalid config:\n  %s", strings.Join(lines, "\n  "))
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateProjectConfig(t *testing.T) {
+	data := []byte(`packages:
+  apt: [jq]
+  browsers: yes
+mouts: []
+agents:
+  allow: claude
+read_only_mounts:
+  - path: ../protos
+    targte: /protos
+`)
+	issues, err := Validate(data, ProjectSchema(), false)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	want := []string{
+		"4:1: unknown field 'mouts'",
+		`6:10: agents.allow: expected a list, got "claude"`,
+		"9:5: read_only_mounts[0]: unknown field 'targte' (did you mean 'target'?)",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Validate() = %v, want %d issues", issues, len(want))
+	}
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d = %s, want %s", i, issue, want[i])
+		}
+	}
+}
+
+func TestValidateUserConfig(t *testing.T) {
+	data := []byte(`{
+  "container_runtime": "docker",
+  "default_credentials": {"git": "yes", "sshh": true},
+  "caches": {"gomod": {"target": 5}},
+  "env_configs": {"work": {"env_vars": {"A": "b"}}}
+}`)
+	issues, err := Validate(data, UserSchema(), true)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	want := []string{
+		`3:34: default_credentials.git: expected a boolean, got "yes"`,
+		"3:41: default_credentials: unknown field 'sshh' (did you mean 'ssh'?)",
+		"4:34: caches.gomod.target: expected a string, got number 5",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Validate() = %v, want %d issues", issues, len(want))
+	}
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d = %s, want %s", i, issue, want[i])
+		}
+	}
+
+	issues, err = Validate([]byte("{\n  \"hostname\": \"x\",\n}"), UserSchema(), true)
+	if err != nil || len(issues) != 1 || issues[0].Line != 3 {
+		t.Errorf("Validate(trailing comma) = %v, %v; want a syntax error on line 3", issues, err)
+	}
+}
+
+func TestLoadProjectConfigPointsAtBadValues(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte("packages:\n  apt: jq\n"), 0644)
+
+	_, err := LoadProjectConfig(dir)
+	if err == nil || !strings.Contains(err.Error(), ":2:8: packages.apt: expected a list") {
+		t.Errorf("LoadProjectConfig() error = %v, want the line and field of packages.apt", err)
+	}
+}
+
+func TestSchemaCoversEveryField(t *testing.T) {
+	project := ProjectSchema()
+	for _, name := range []string{"packages", "read_only_mounts", "agents", "nix"} {
+		if project.Properties[name] == nil {
+			t.Errorf("ProjectSchema() is missing %s", name)
+		}
+	}
+	user := UserSchema()
+	if user.Properties["caches"].AdditionalProperties.(*Schema).Properties["target"].Type != "string" {
+		t.Error("UserSchema() caches values should be objects with a string target")
+	}
+}
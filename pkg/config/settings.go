@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resolve splits a dotted key like shell.name into the path it names in schema,
+// returning the schema of the value there
+// Map keys may contain dots (sysctls.net.ipv4.ip_local_port_range), so once the key
+// reaches a map of plain values the rest of it is a single map key.
+func Resolve(schema *Schema, key string) ([]string, *Schema, error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("empty key")
+	}
+	parts := strings.Split(key, ".")
+	var path []string
+	for i := 0; i < len(parts); i++ {
+		if schema.Type != "object" {
+			return nil, nil, fmt.Errorf("'%s' is %s and has no field '%s'", strings.Join(path, "."), article(schema.Type), parts[i])
+		}
+		if property, ok := schema.Properties[parts[i]]; ok {
+			path, schema = append(path, parts[i]), property
+			continue
+		}
+		additional, ok := schema.AdditionalProperties.(*Schema)
+		if !ok {
+			message := fmt.Sprintf("unknown key '%s'", strings.Join(append(path, parts[i]), "."))
+			if suggestion := closest(parts[i], schema.Properties); suggestion != "" {
+				message += fmt.Sprintf(" (did you mean '%s'?)", strings.Join(append(path, suggestion), "."))
+			}
+			return nil, nil, fmt.Errorf("%s", message)
+		}
+		if additional.Type != "object" {
+			return append(path, strings.Join(parts[i:], ".")), additional, nil
+		}
+		path, schema = append(path, parts[i]), additional
+	}
+	return path, schema, nil
+}
+
+// ParseValue reads a command-line value as the type schema expects
+// Lists may be given comma-separated or as JSON; objects only as JSON.
+func ParseValue(schema *Schema, raw string) (any, error) {
+	switch schema.Type {
+	case "string", "":
+		return raw, nil
+	case "boolean":
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false, got %q", raw)
+		}
+		return value, nil
+	case "integer":
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer, got %q", raw)
+		}
+		return value, nil
+	case "number":
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number, got %q", raw)
+		}
+		return value, nil
+	case "array":
+		if strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			return parseJSONValue(schema, raw)
+		}
+		items := []any{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, err := ParseValue(schema.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case "object":
+		return parseJSONValue(schema, raw)
+	}
+	return nil, fmt.Errorf("unsupported type %s", schema.Type)
+}
+
+// parseJSONValue reads a JSON list or object and checks it against schema
+func parseJSONValue(schema *Schema, raw string) (any, error) {
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, fmt.Errorf("expected JSON %s: %w", strings.TrimPrefix(article(schema.Type), "a "), err)
+	}
+	issues, err := Validate([]byte(raw), schema, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(issues) > 0 {
+		return nil, fmt.Errorf("%s", issues[0].Message)
+	}
+	return value, nil
+}
+
+// SetJSON sets the value at path in a JSON config file's contents, keeping every other
+// field, including ones packnplay doesn't know
+func SetJSON(data []byte, path []string, value any) ([]byte, error) {
+	doc := map[string]any{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+	}
+
+	parent := doc
+	for i, name := range path[:len(path)-1] {
+		child, ok := parent[name].(map[string]any)
+		if !ok {
+			if parent[name] != nil {
+				return nil, fmt.Errorf("'%s' is not an object", strings.Join(path[:i+1], "."))
+			}
+			child = map[string]any{}
+			parent[name] = child
+		}
+		parent = child
+	}
+	parent[path[len(path)-1]] = value
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// SetYAML sets the value at path in a YAML config file's contents, keeping its comments
+// and the order of its fields
+func SetYAML(data []byte, path []string, value any) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	node := doc.Content[0]
+	for i, name := range path {
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("'%s' is not an object", strings.Join(path[:i], "."))
+		}
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == name {
+				child = node.Content[j+1]
+				break
+			}
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, child)
+		}
+		node = child
+	}
+
+	var replacement yaml.Node
+	if err := replacement.Encode(value); err != nil {
+		return nil, err
+	}
+	replacement.HeadComment = node.HeadComment
+	if replacement.Kind == yaml.ScalarNode {
+		replacement.LineComment = node.LineComment
+	}
+	*node = replacement
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Generic converts a loaded config into plain maps and lists by way of its encoding,
+// so values can be looked up by the names the file uses
+func Generic(cfg any, tag string) (map[string]any, error) {
+	doc := map[string]any{}
+	switch tag {
+	case "json":
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown encoding %s", tag)
+	}
+	return doc, nil
+}
+
+// ValueAt returns the value at path in a generic config, and whether it's set
+func ValueAt(doc map[string]any, path []string) (any, bool) {
+	var value any = doc
+	for _, name := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
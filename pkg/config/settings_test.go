@@ -0,0 +1,128 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		key      string
+		wantPath []string
+		wantType string
+	}{
+		{"record_sessions", []string{"record_sessions"}, "boolean"},
+		{"shell.utilities", []string{"shell", "utilities"}, "array"},
+		{"caches.gomod.target", []string{"caches", "gomod", "target"}, "string"},
+		{"sysctls.net.ipv4.ip_local_port_range", []string{"sysctls", "net.ipv4.ip_local_port_range"}, "string"},
+	}
+	for _, tt := range tests {
+		path, schema, err := Resolve(UserSchema(), tt.key)
+		if err != nil {
+			t.Errorf("Resolve(%s) error = %v", tt.key, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, tt.wantPath) || schema.Type != tt.wantType {
+			t.Errorf("Resolve(%s) = %v %s, want %v %s", tt.key, path, schema.Type, tt.wantPath, tt.wantType)
+		}
+	}
+
+	if _, _, err := Resolve(UserSchema(), "shel.name"); err == nil || !strings.Contains(err.Error(), "did you mean 'shell'") {
+		t.Errorf("Resolve(shel.name) error = %v, want a suggestion", err)
+	}
+	if _, _, err := Resolve(UserSchema(), "record_sessions.on"); err == nil {
+		t.Error("Resolve() should reject fields of a scalar")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	list := &Schema{Type: "array", Items: &Schema{Type: "string"}}
+	tests := []struct {
+		schema *Schema
+		raw    string
+		want   any
+	}{
+		{&Schema{Type: "boolean"}, "true", true},
+		{&Schema{Type: "integer"}, "42", int64(42)},
+		{list, "jq, protobuf-compiler", []any{"jq", "protobuf-compiler"}},
+		{list, `["a,b"]`, []any{"a,b"}},
+		{list, "", []any{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseValue(tt.schema, tt.raw)
+		if err != nil {
+			t.Errorf("ParseValue(%q) error = %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseValue(%q) = %#v, want %#v", tt.raw, got, tt.want)
+		}
+	}
+
+	if _, err := ParseValue(&Schema{Type: "boolean"}, "maybe"); err == nil {
+		t.Error("ParseValue() should reject a non-boolean")
+	}
+	if _, err := ParseValue(UserSchema().Properties["caches"], `{"gomod": {"targte": "/go"}}`); err == nil {
+		t.Error("ParseValue() should check objects against the schema")
+	}
+}
+
+func TestSetJSONKeepsOtherFields(t *testing.T) {
+	data := []byte(`{"container_runtime": "docker", "custom": 1, "shell": {"name": "zsh"}}`)
+	out, err := SetJSON(data, []string{"shell", "prompt"}, "starship")
+	if err != nil {
+		t.Fatalf("SetJSON() error = %v", err)
+	}
+	for _, want := range []string{`"container_runtime": "docker"`, `"custom": 1`, `"name": "zsh"`, `"prompt": "starship"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("SetJSON() = %s, missing %s", out, want)
+		}
+	}
+
+	if _, err := SetJSON(data, []string{"container_runtime", "name"}, "x"); err == nil {
+		t.Error("SetJSON() should refuse to replace a value with an object")
+	}
+}
+
+func TestSetYAMLKeepsComments(t *testing.T) {
+	data := []byte(`# shared with CI
+packages:
+  apt: [git]
+nix: off # flake is for releases
+`)
+	out, err := SetYAML(data, []string{"packages", "apt"}, []any{"jq"})
+	if err != nil {
+		t.Fatalf("SetYAML() error = %v", err)
+	}
+	out, err = SetYAML(out, []string{"agents", "deny"}, []any{"codex"})
+	if err != nil {
+		t.Fatalf("SetYAML() error = %v", err)
+	}
+
+	want := `# shared with CI
+packages:
+  apt:
+    - jq
+nix: off # flake is for releases
+agents:
+  deny:
+    - codex
+`
+	if string(out) != want {
+		t.Errorf("SetYAML() =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestValueAt(t *testing.T) {
+	doc, err := Generic(&Config{Shell: ShellConfig{Name: "fish"}}, "json")
+	if err != nil {
+		t.Fatalf("Generic() error = %v", err)
+	}
+	if value, ok := ValueAt(doc, []string{"shell", "name"}); !ok || value != "fish" {
+		t.Errorf("ValueAt(shell.name) = %v, %v", value, ok)
+	}
+	if _, ok := ValueAt(doc, []string{"shell", "name", "x"}); ok {
+		t.Error("ValueAt() found a field below a string")
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	configPath := GetConfigPath()
+
+	changes := make(chan *Config, 4)
+	errs := make(chan error, 4)
+	stop, err := Watch(func(cfg *Config, err error) {
+		if err != nil {
+			errs <- err
+			return
+		}
+		changes <- cfg
+	})
+	if err != nil {
+		t.Fatalf("Watch() error: %v", err)
+	}
+	defer stop()
+
+	// Replace the file the way Save and most editors do
+	write := func(content string) {
+		t.Helper()
+		tmp := filepath.Join(filepath.Dir(configPath), "config.json.tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, configPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"host_broker": {"enabled": true, "allow": ["notify"]}}`)
+	select {
+	case cfg := <-changes:
+		if !cfg.HostBroker.Enabled || len(cfg.HostBroker.Allow) != 1 {
+			t.Errorf("reloaded config = %+v, want the broker enabled for notify", cfg.HostBroker)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected reload error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the config changed")
+	}
+
+	write(`{"host_broker": `)
+	select {
+	case <-errs:
+	case cfg := <-changes:
+		t.Fatalf("broken config reloaded as %+v, want an error", cfg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the config broke")
+	}
+
+	// Other files in the directory don't trigger reloads
+	if err := os.WriteFile(filepath.Join(filepath.Dir(configPath), "other.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Error("unrelated file triggered a reload")
+	case <-errs:
+		t.Error("unrelated file triggered a reload")
+	case <-time.After(2 * reloadDelay):
+	}
+}
@@ -0,0 +1,121 @@
+package container
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Fleet metadata labels, so platform tooling can attribute and clean up sessions with
+// plain `docker ps --filter label=...` / `docker volume ls --filter label=...` queries
+const (
+	SessionLabel = "packnplay-session" // unique per container start
+	OwnerLabel   = "packnplay-owner"   // host user who started it
+	ProfileLabel = "packnplay-profile" // env config profile (--config)
+	VersionLabel = "packnplay-version" // packnplay release that created it
+	CreatedLabel = "packnplay-created" // RFC 3339
+	CacheLabel   = "packnplay-cache"   // shared cache a volume backs
+)
+
+// Metadata describes who started a session, and how
+type Metadata struct {
+	SessionID string
+	Owner     string
+	Profile   string
+	Version   string
+	Created   time.Time
+	Project   string
+	Worktree  string
+	Agent     string
+	Extra     map[string]string // user-defined labels from the config, e.g. cost-center; values may use {placeholders}
+}
+
+// labelPlaceholder matches the {name} placeholders user-defined label values can use
+var labelPlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// placeholders returns what each label placeholder stands for in a session
+func (m Metadata) placeholders() map[string]string {
+	return map[string]string{
+		"project":  m.Project,
+		"worktree": m.Worktree,
+		"agent":    m.Agent,
+		"session":  m.SessionID,
+		"owner":    m.Owner,
+		"profile":  m.Profile,
+	}
+}
+
+// ExpandLabel fills in a label value's placeholders, e.g. "{project}-{agent}"
+func ExpandLabel(value string, meta Metadata) string {
+	values := meta.placeholders()
+	return labelPlaceholder.ReplaceAllStringFunc(value, func(match string) string {
+		if v, ok := values[match[1:len(match)-1]]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+// NewSessionID returns a random identifier for a session
+func NewSessionID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MetadataLabels returns the fleet labels for a session container; empty values are omitted
+// User-defined labels whose placeholders all expand to nothing are left off too.
+func MetadataLabels(meta Metadata) map[string]string {
+	labels := make(map[string]string, len(meta.Extra)+5)
+	for k, v := range meta.Extra {
+		if v = ExpandLabel(v, meta); v != "" {
+			labels[k] = v
+		}
+	}
+	for k, v := range map[string]string{
+		SessionLabel: meta.SessionID,
+		OwnerLabel:   meta.Owner,
+		ProfileLabel: meta.Profile,
+		VersionLabel: meta.Version,
+	} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	if !meta.Created.IsZero() {
+		labels[CreatedLabel] = meta.Created.UTC().Format(time.RFC3339)
+	}
+	return labels
+}
+
+// VolumeLabels returns the labels for a shared volume packnplay creates
+// Volumes outlive sessions, so only the creator, not the session, is recorded, and
+// placeholders other than {owner} expand to nothing.
+func VolumeLabels(cacheName string, meta Metadata) map[string]string {
+	labels := MetadataLabels(Metadata{Owner: meta.Owner, Version: meta.Version, Created: meta.Created, Extra: meta.Extra})
+	labels["managed-by"] = "packnplay"
+	labels[CacheLabel] = cacheName
+	return labels
+}
+
+// ValidateExtraLabels rejects user-defined labels that would shadow packnplay's own
+func ValidateExtraLabels(labels map[string]string) error {
+	for key := range labels {
+		if key == "" || strings.ContainsAny(key, "= \t\n") {
+			return fmt.Errorf("invalid label key '%s'", key)
+		}
+		if key == "managed-by" || strings.HasPrefix(key, "packnplay-") || strings.HasPrefix(key, "packnplay.") {
+			return fmt.Errorf("label '%s' is reserved for packnplay", key)
+		}
+		known := Metadata{}.placeholders()
+		for _, match := range labelPlaceholder.FindAllStringSubmatch(labels[key], -1) {
+			if _, ok := known[match[1]]; !ok {
+				return fmt.Errorf("unknown placeholder '%s' in label '%s' (use {project}, {worktree}, {agent}, {session}, {owner} or {profile})", match[0], key)
+			}
+		}
+	}
+	return nil
+}
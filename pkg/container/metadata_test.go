@@ -0,0 +1,90 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadataLabels(t *testing.T) {
+	meta := Metadata{
+		SessionID: "0123456789abcdef",
+		Owner:     "jesse",
+		Version:   "1.4.0",
+		Created:   time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC),
+		Extra:     map[string]string{"cost-center": "ml-platform"},
+	}
+
+	want := map[string]string{
+		SessionLabel:  "0123456789abcdef",
+		OwnerLabel:    "jesse",
+		VersionLabel:  "1.4.0",
+		CreatedLabel:  "2026-10-15T09:30:00Z",
+		"cost-center": "ml-platform",
+	}
+	if got := MetadataLabels(meta); !reflect.DeepEqual(got, want) {
+		t.Errorf("MetadataLabels() = %v, want %v", got, want)
+	}
+
+	volume := VolumeLabels("npm", meta)
+	if _, ok := volume[SessionLabel]; ok {
+		t.Error("VolumeLabels() should not tie shared volumes to one session")
+	}
+	if volume["managed-by"] != "packnplay" || volume[CacheLabel] != "npm" || volume[OwnerLabel] != "jesse" || volume["cost-center"] != "ml-platform" {
+		t.Errorf("VolumeLabels() = %v", volume)
+	}
+}
+
+func TestMetadataLabelsExpandPlaceholders(t *testing.T) {
+	meta := Metadata{
+		SessionID: "0123456789abcdef",
+		Owner:     "jesse",
+		Project:   "api",
+		Agent:     "claude",
+		Extra: map[string]string{
+			"app":   "{project}",
+			"usage": "{project}/{agent}/{session}",
+			"env":   "{profile}",
+		},
+	}
+
+	labels := MetadataLabels(meta)
+	if labels["app"] != "api" || labels["usage"] != "api/claude/0123456789abcdef" {
+		t.Errorf("MetadataLabels() = %v", labels)
+	}
+	if _, ok := labels["env"]; ok {
+		t.Error("a label that expands to nothing should be left off")
+	}
+
+	volume := VolumeLabels("npm", meta)
+	if _, ok := volume["app"]; ok {
+		t.Errorf("VolumeLabels() = %v, shared volumes shouldn't carry one project's labels", volume)
+	}
+}
+
+func TestNewSessionID(t *testing.T) {
+	a, b := NewSessionID(), NewSessionID()
+	if len(a) != 16 || a == b {
+		t.Errorf("NewSessionID() = %q, %q; want distinct 16-character IDs", a, b)
+	}
+}
+
+func TestValidateExtraLabels(t *testing.T) {
+	tests := []struct {
+		labels  map[string]string
+		wantErr bool
+	}{
+		{labels: map[string]string{"cost-center": "ml", "com.example.team": "infra"}},
+		{labels: map[string]string{"managed-by": "me"}, wantErr: true},
+		{labels: map[string]string{OwnerLabel: "someone-else"}, wantErr: true},
+		{labels: map[string]string{"packnplay.agent.claude": "1.0"}, wantErr: true},
+		{labels: map[string]string{"a=b": "c"}, wantErr: true},
+		{labels: map[string]string{"usage": "{project}-{owner}"}},
+		{labels: map[string]string{"usage": "{projct}"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := ValidateExtraLabels(tt.labels); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateExtraLabels(%v) error = %v, wantErr %v", tt.labels, err, tt.wantErr)
+		}
+	}
+}
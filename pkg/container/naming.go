@@ -22,6 +22,13 @@ func sanitizeName(name string) string {
 	return name
 }
 
+// AgentLabel records which agent a container was started for, for usage metrics
+const AgentLabel = "packnplay-agent"
+
+// StartCommitLabel records the workspace's HEAD when the container was created, so the
+// session's changes can be diffed against it later
+const StartCommitLabel = "packnplay-start-commit"
+
 // GenerateLabels creates Docker labels for packnplay-managed containers
 func GenerateLabels(projectName, worktreeName string) map[string]string {
 	return map[string]string{
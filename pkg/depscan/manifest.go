@@ -0,0 +1,221 @@
+package depscan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// OSV ecosystem names
+const (
+	EcosystemNPM  = "npm"
+	EcosystemGo   = "Go"
+	EcosystemPyPI = "PyPI"
+)
+
+// manifestEcosystems maps the manifests that are scanned to their ecosystem
+var manifestEcosystems = map[string]string{
+	"package.json":     EcosystemNPM,
+	"go.mod":           EcosystemGo,
+	"requirements.txt": EcosystemPyPI,
+}
+
+// Package is one dependency declared in a manifest
+type Package struct {
+	Ecosystem string
+	Name      string
+	Version   string // exact or lower-bound version; empty when unpinned
+	Manifest  string // path relative to the workspace
+}
+
+func (p Package) String() string {
+	if p.Version == "" {
+		return fmt.Sprintf("%s (%s)", p.Name, p.Ecosystem)
+	}
+	return fmt.Sprintf("%s@%s (%s)", p.Name, p.Version, p.Ecosystem)
+}
+
+// ParseManifest extracts the dependencies declared in a manifest's contents
+func ParseManifest(name string, data []byte) ([]Package, error) {
+	var packages []Package
+	var err error
+	switch filepath.Base(name) {
+	case "package.json":
+		packages, err = parsePackageJSON(data)
+	case "go.mod":
+		packages = parseGoMod(data)
+	case "requirements.txt":
+		packages = parseRequirements(data)
+	default:
+		return nil, fmt.Errorf("unsupported manifest %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	for i := range packages {
+		packages[i].Ecosystem = manifestEcosystems[filepath.Base(name)]
+		packages[i].Manifest = name
+	}
+	return packages, nil
+}
+
+func parsePackageJSON(data []byte) ([]Package, error) {
+	var manifest map[string]json.RawMessage
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	var packages []Package
+	for _, section := range []string{"dependencies", "devDependencies", "optionalDependencies", "peerDependencies"} {
+		var deps map[string]string
+		if raw, ok := manifest[section]; ok {
+			if err := json.Unmarshal(raw, &deps); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", section, err)
+			}
+		}
+		for name, spec := range deps {
+			packages = append(packages, Package{Name: name, Version: npmVersion(spec)})
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages, nil
+}
+
+var npmSemver = regexp.MustCompile(`^[\^~>=v ]*(\d+\.\d+\.\d+[0-9A-Za-z.+-]*)$`)
+
+// npmVersion returns the lowest version a simple range (1.2.3, ^1.2.3, ~1.2.3, >=1.2.3) allows
+// Tags, URLs and compound ranges have no single version and are scanned unpinned.
+func npmVersion(spec string) string {
+	if match := npmSemver.FindStringSubmatch(strings.TrimSpace(spec)); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func parseGoMod(data []byte) []Package {
+	var packages []Package
+	inRequire := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inRequire:
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) == 2 {
+			packages = append(packages, Package{Name: fields[0], Version: strings.TrimPrefix(fields[1], "v")})
+		}
+	}
+	return packages
+}
+
+var requirementLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(?:\[[^\]]*\])?\s*(?:(==|>=|~=|===)\s*([^\s,;]+))?`)
+
+func parseRequirements(data []byte) []Package {
+	var packages []Package
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		// Options (-r, -e, --index-url) and direct URLs aren't registry packages
+		if line == "" || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+			continue
+		}
+		if match := requirementLine.FindStringSubmatch(line); match != nil {
+			packages = append(packages, Package{Name: match[1], Version: match[3]})
+		}
+	}
+	return packages
+}
+
+// Added returns the packages in after that aren't in before with the same version
+func Added(before, after []Package) []Package {
+	seen := make(map[string]bool, len(before))
+	for _, p := range before {
+		seen[p.Ecosystem+"\x00"+p.Name+"\x00"+p.Version] = true
+	}
+	var added []Package
+	for _, p := range after {
+		if !seen[p.Ecosystem+"\x00"+p.Name+"\x00"+p.Version] {
+			added = append(added, p)
+		}
+	}
+	return added
+}
+
+// AddedSince lists the dependencies the workspace declares now that it didn't at the base
+// commit, across every tracked or new manifest outside vendored directories
+func AddedSince(workspace, base string) ([]Package, error) {
+	if base == "" {
+		base = "HEAD"
+	}
+	list, err := git.Command(workspace, "ls-files", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	output, err := list.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace files: %w", err)
+	}
+
+	var added []Package
+	for _, path := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if !IsManifest(path) {
+			continue
+		}
+		current, err := os.ReadFile(filepath.Join(workspace, path))
+		if err != nil {
+			continue // deleted in the working tree
+		}
+		after, err := ParseManifest(path, current)
+		if err != nil {
+			return nil, err
+		}
+
+		var before []Package
+		// A manifest that didn't exist at base contributes nothing before
+		if previous, err := git.ShowFile(workspace, base, path); err == nil && previous != nil {
+			if before, err = ParseManifest(path, previous); err != nil {
+				before = nil // an unparseable old manifest means everything counts as new
+			}
+		}
+		added = append(added, Added(before, after)...)
+	}
+	return added, nil
+}
+
+// IsManifest reports whether a workspace-relative path is a manifest the scan covers
+func IsManifest(path string) bool {
+	_, ok := manifestEcosystems[filepath.Base(path)]
+	return ok && !isVendored(path)
+}
+
+// isVendored reports whether a path is inside third-party code rather than the project's own manifests
+func isVendored(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == "node_modules" || part == "vendor" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package depscan
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Package
+	}{
+		{
+			name: "package.json",
+			data: `{"name": "app", "dependencies": {"left-pad": "^1.3.0", "lodash": "latest"}, "devDependencies": {"@types/node": "~20.1.2"}}`,
+			want: []Package{
+				{Ecosystem: "npm", Name: "@types/node", Version: "20.1.2", Manifest: "package.json"},
+				{Ecosystem: "npm", Name: "left-pad", Version: "1.3.0", Manifest: "package.json"},
+				{Ecosystem: "npm", Name: "lodash", Manifest: "package.json"},
+			},
+		},
+		{
+			name: "svc/go.mod",
+			data: "module example.com/svc\n\ngo 1.23\n\nrequire github.com/spf13/cobra v1.8.0\n\nrequire (\n\tgolang.org/x/sys v0.20.0 // indirect\n)\n",
+			want: []Package{
+				{Ecosystem: "Go", Name: "github.com/spf13/cobra", Version: "1.8.0", Manifest: "svc/go.mod"},
+				{Ecosystem: "Go", Name: "golang.org/x/sys", Version: "0.20.0", Manifest: "svc/go.mod"},
+			},
+		},
+		{
+			name: "requirements.txt",
+			data: "# deps\n-r base.txt\nrequests==2.31.0\nuvicorn[standard]>=0.29\nflask\ngit+https://example.com/pkg.git\n",
+			want: []Package{
+				{Ecosystem: "PyPI", Name: "requests", Version: "2.31.0", Manifest: "requirements.txt"},
+				{Ecosystem: "PyPI", Name: "uvicorn", Version: "0.29", Manifest: "requirements.txt"},
+				{Ecosystem: "PyPI", Name: "flask", Manifest: "requirements.txt"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseManifest(tt.name, []byte(tt.data))
+		if err != nil {
+			t.Errorf("ParseManifest(%s) error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseManifest(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseManifest("package.json", []byte("{")); err == nil {
+		t.Error("expected an error for invalid package.json")
+	}
+}
+
+func TestAdded(t *testing.T) {
+	before := []Package{{Ecosystem: "npm", Name: "a", Version: "1.0.0"}, {Ecosystem: "npm", Name: "b", Version: "1.0.0"}}
+	after := []Package{{Ecosystem: "npm", Name: "a", Version: "1.0.0"}, {Ecosystem: "npm", Name: "b", Version: "2.0.0"}, {Ecosystem: "npm", Name: "c", Version: "1.0.0"}}
+
+	got := Added(before, after)
+	want := []Package{{Ecosystem: "npm", Name: "b", Version: "2.0.0"}, {Ecosystem: "npm", Name: "c", Version: "1.0.0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Added() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAddedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workspace := t.TempDir()
+	git := func(args ...string) {
+		output, err := exec.Command("git", append([]string{"-C", workspace, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	write := func(path, data string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(workspace, path)), 0755)
+		os.WriteFile(filepath.Join(workspace, path), []byte(data), 0644)
+	}
+
+	git("init", "-q")
+	write("package.json", `{"dependencies": {"express": "4.19.2"}}`)
+	git("add", "-A")
+	git("commit", "-qm", "start")
+
+	write("package.json", `{"dependencies": {"express": "4.19.2", "left-padd": "1.0.0"}}`)
+	write("tools/requirements.txt", "requests==2.31.0\n")
+	write("vendor/x/go.mod", "module x\n\nrequire example.com/y v1.0.0\n")
+
+	got, err := AddedSince(workspace, "")
+	if err != nil {
+		t.Fatalf("AddedSince() error = %v", err)
+	}
+	want := []Package{
+		{Ecosystem: "PyPI", Name: "requests", Version: "2.31.0", Manifest: "tools/requirements.txt"},
+		{Ecosystem: "npm", Name: "left-padd", Version: "1.0.0", Manifest: "package.json"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddedSince() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,263 @@
+package depscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Service roots; tests point these at local servers
+var (
+	osvAPI      = "https://api.osv.dev/v1"
+	npmRegistry = "https://registry.npmjs.org"
+	goProxy     = "https://proxy.golang.org"
+	pypiAPI     = "https://pypi.org/pypi"
+)
+
+// SeverityCritical is the OSV severity that blocks publishing
+const SeverityCritical = "CRITICAL"
+
+// Vuln is a known vulnerability affecting a package version
+type Vuln struct {
+	ID       string
+	Severity string // CRITICAL, HIGH, MODERATE, LOW, or "" when the advisory doesn't rate it
+	Summary  string
+}
+
+// Finding is a newly added package that needs attention
+type Finding struct {
+	Package Package
+	Unknown bool // the package isn't published in its registry (typo, hallucination or squatting target)
+	Vulns   []Vuln
+}
+
+// Blocking reports whether the finding should stop the changes from being published
+func (f Finding) Blocking() bool {
+	if f.Unknown {
+		return true
+	}
+	for _, v := range f.Vulns {
+		if v.Severity == SeverityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// Report is the result of scanning newly added packages
+type Report struct {
+	Scanned  []Package
+	Findings []Finding
+}
+
+// Blocking returns the findings that should stop the changes from being published
+func (r Report) Blocking() []Finding {
+	var blocking []Finding
+	for _, f := range r.Findings {
+		if f.Blocking() {
+			blocking = append(blocking, f)
+		}
+	}
+	return blocking
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Scan checks each package exists in its registry and queries OSV for vulnerabilities in
+// its version. Unpinned packages are only checked for existence. Lookup failures are
+// returned as errors, so callers can fail closed.
+func Scan(packages []Package) (Report, error) {
+	report := Report{Scanned: packages}
+	if len(packages) == 0 {
+		return report, nil
+	}
+
+	vulnIDs, err := queryOSV(packages)
+	if err != nil {
+		return report, err
+	}
+
+	details := map[string]Vuln{}
+	for i, p := range packages {
+		known, err := published(p)
+		if err != nil {
+			return report, err
+		}
+
+		finding := Finding{Package: p, Unknown: !known}
+		for _, id := range vulnIDs[i] {
+			if _, ok := details[id]; !ok {
+				if details[id], err = fetchVuln(id); err != nil {
+					return report, err
+				}
+			}
+			finding.Vulns = append(finding.Vulns, details[id])
+		}
+		if finding.Unknown || len(finding.Vulns) > 0 {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	return report, nil
+}
+
+// queryOSV returns the vulnerability IDs affecting each pinned package, by index
+func queryOSV(packages []Package) ([][]string, error) {
+	type query struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+
+	var queries []query
+	var indexes []int
+	for i, p := range packages {
+		if p.Version == "" {
+			continue
+		}
+		var q query
+		q.Package.Name, q.Package.Ecosystem, q.Version = p.Name, p.Ecosystem, p.Version
+		queries = append(queries, q)
+		indexes = append(indexes, i)
+	}
+
+	ids := make([][]string, len(packages))
+	if len(queries) == 0 {
+		return ids, nil
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"queries": queries})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OSV query: %w", err)
+	}
+	var response struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+		} `json:"results"`
+	}
+	if err := doJSON(http.MethodPost, osvAPI+"/querybatch", payload, &response); err != nil {
+		return nil, fmt.Errorf("failed to query OSV: %w", err)
+	}
+	if len(response.Results) != len(queries) {
+		return nil, fmt.Errorf("failed to query OSV: got %d results for %d packages", len(response.Results), len(queries))
+	}
+	for i, result := range response.Results {
+		for _, v := range result.Vulns {
+			ids[indexes[i]] = append(ids[indexes[i]], v.ID)
+		}
+	}
+	return ids, nil
+}
+
+// fetchVuln loads an advisory's summary and severity
+func fetchVuln(id string) (Vuln, error) {
+	var advisory struct {
+		ID               string `json:"id"`
+		Summary          string `json:"summary"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	}
+	if err := doJSON(http.MethodGet, osvAPI+"/vulns/"+url.PathEscape(id), nil, &advisory); err != nil {
+		return Vuln{}, fmt.Errorf("failed to fetch %s: %w", id, err)
+	}
+	return Vuln{ID: id, Severity: strings.ToUpper(advisory.DatabaseSpecific.Severity), Summary: advisory.Summary}, nil
+}
+
+// published reports whether a package exists in its ecosystem's public registry
+// Go modules matched by GOPRIVATE are never on the public proxy, so they count as known.
+func published(p Package) (bool, error) {
+	var target string
+	switch p.Ecosystem {
+	case EcosystemNPM:
+		target = npmRegistry + "/" + strings.Replace(p.Name, "/", "%2f", 1)
+	case EcosystemGo:
+		if matchesGoPrivate(p.Name, os.Getenv("GOPRIVATE")) {
+			return true, nil
+		}
+		target = goProxy + "/" + escapeModulePath(p.Name) + "/@v/list"
+	case EcosystemPyPI:
+		target = pypiAPI + "/" + url.PathEscape(p.Name) + "/json"
+	default:
+		return false, fmt.Errorf("unsupported ecosystem %s", p.Ecosystem)
+	}
+
+	resp, err := httpClient.Get(target)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %s: %w", p, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to look up %s: registry returned %s", p, resp.Status)
+}
+
+// escapeModulePath applies the module proxy's case encoding (Uppercase -> !lowercase)
+func escapeModulePath(module string) string {
+	var b strings.Builder
+	for _, r := range module {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// matchesGoPrivate reports whether a module path matches a GOPRIVATE-style glob list
+func matchesGoPrivate(module, patterns string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		n := strings.Count(pattern, "/") + 1
+		elems := strings.Split(module, "/")
+		if len(elems) < n {
+			continue
+		}
+		if ok, _ := path.Match(pattern, strings.Join(elems[:n], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func doJSON(method, target string, payload []byte, out interface{}) error {
+	req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,104 @@
+package depscan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/osv/querybatch":
+			var body struct {
+				Queries []struct {
+					Package struct{ Name string } `json:"package"`
+				} `json:"queries"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			var results []map[string]interface{}
+			for _, q := range body.Queries {
+				var vulns []map[string]string
+				switch q.Package.Name {
+				case "lodash":
+					vulns = append(vulns, map[string]string{"id": "GHSA-crit"})
+				case "minimist":
+					vulns = append(vulns, map[string]string{"id": "GHSA-low"})
+				}
+				results = append(results, map[string]interface{}{"vulns": vulns})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+		case "/osv/vulns/GHSA-crit":
+			w.Write([]byte(`{"id": "GHSA-crit", "summary": "Prototype pollution", "database_specific": {"severity": "CRITICAL"}}`))
+		case "/osv/vulns/GHSA-low":
+			w.Write([]byte(`{"id": "GHSA-low", "summary": "Minor", "database_specific": {"severity": "LOW"}}`))
+		case "/npm/lodash", "/npm/minimist", "/npm/@scope%2fpkg", "/go/github.com/!burnt!sushi/toml/@v/list":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oldOSV, oldNPM, oldGo := osvAPI, npmRegistry, goProxy
+	osvAPI, npmRegistry, goProxy = server.URL+"/osv", server.URL+"/npm", server.URL+"/go"
+	defer func() { osvAPI, npmRegistry, goProxy = oldOSV, oldNPM, oldGo }()
+	t.Setenv("GOPRIVATE", "corp.example.com")
+
+	report, err := Scan([]Package{
+		{Ecosystem: EcosystemNPM, Name: "lodash", Version: "4.17.4"},
+		{Ecosystem: EcosystemNPM, Name: "minimist", Version: "1.2.0"},
+		{Ecosystem: EcosystemNPM, Name: "@scope/pkg"},
+		{Ecosystem: EcosystemNPM, Name: "lodahs", Version: "1.0.0"},
+		{Ecosystem: EcosystemGo, Name: "github.com/BurntSushi/toml", Version: "1.3.2"},
+		{Ecosystem: EcosystemGo, Name: "corp.example.com/internal/lib", Version: "0.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(report.Findings) != 3 {
+		t.Fatalf("Scan() findings = %+v, want lodash, minimist and lodahs", report.Findings)
+	}
+	blocking := report.Blocking()
+	if len(blocking) != 2 || blocking[0].Package.Name != "lodash" || blocking[1].Package.Name != "lodahs" || !blocking[1].Unknown {
+		t.Errorf("Blocking() = %+v, want critical lodash and unknown lodahs", blocking)
+	}
+	if v := report.Findings[0].Vulns; len(v) != 1 || v[0].Severity != SeverityCritical || v[0].Summary != "Prototype pollution" {
+		t.Errorf("lodash vulns = %+v", v)
+	}
+}
+
+func TestScanFailsClosed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	oldOSV := osvAPI
+	osvAPI = server.URL
+	defer func() { osvAPI = oldOSV }()
+
+	if _, err := Scan([]Package{{Ecosystem: EcosystemNPM, Name: "lodash", Version: "4.17.21"}}); err == nil {
+		t.Error("expected an error when OSV is unavailable")
+	}
+}
+
+func TestMatchesGoPrivate(t *testing.T) {
+	tests := []struct {
+		module, patterns string
+		want             bool
+	}{
+		{"corp.example.com/team/lib", "corp.example.com", true},
+		{"github.com/acme/private", "github.com/acme/*", true},
+		{"github.com/other/lib", "github.com/acme/*,*.corp.internal", false},
+		{"git.corp.internal/x", "github.com/acme/*,*.corp.internal", true},
+		{"github.com/acme", "github.com/acme/*", false},
+	}
+	for _, tt := range tests {
+		if got := matchesGoPrivate(tt.module, tt.patterns); got != tt.want {
+			t.Errorf("matchesGoPrivate(%q, %q) = %v, want %v", tt.module, tt.patterns, got, tt.want)
+		}
+	}
+}
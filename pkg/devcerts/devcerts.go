@@ -0,0 +1,206 @@
+package devcerts
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// ContainerDir is where the certificate, key and CA are mounted in the sandbox
+const ContainerDir = "/etc/packnplay/tls"
+
+// Files inside ContainerDir (and the host staging directory)
+const (
+	CertFile = "cert.pem"
+	KeyFile  = "key.pem"
+	CAFile   = "ca.pem" // public CA certificate only; mkcert's CA key never leaves the host
+)
+
+// DefaultHosts are the names a dev server is reached by through forwarded ports
+var DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// Runner executes a host program, returning its combined output
+type Runner func(name string, args ...string) (string, error)
+
+// HostRunner runs programs on the host
+func HostRunner(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	return string(output), err
+}
+
+// certsDir returns where a certificate set is kept, keyed by what it was made from
+func certsDir(key string) string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "certs", key)
+}
+
+// Prepare returns a host directory holding cert.pem, key.pem and ca.pem for the sandbox
+// Configured cert_file/key_file/ca_file are forwarded as-is; otherwise mkcert issues a
+// certificate for the hosts from its locally trusted CA, reused until the CA changes.
+func Prepare(settings config.DevCertsConfig, run Runner) (string, error) {
+	if settings.CertFile != "" || settings.KeyFile != "" {
+		return forward(settings)
+	}
+
+	output, err := run("mkcert", "-CAROOT")
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("dev_certs needs mkcert on the host (https://github.com/FiloSottile/mkcert), or cert_file and key_file")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find mkcert's CA: %w\n%s", err, output)
+	}
+	caPath := filepath.Join(strings.TrimSpace(output), "rootCA.pem")
+	ca, err := os.ReadFile(caPath)
+	if err != nil {
+		return "", fmt.Errorf("mkcert has no CA yet; run 'mkcert -install' so host browsers trust it: %w", err)
+	}
+
+	hosts := settings.Hosts
+	if len(hosts) == 0 {
+		hosts = DefaultHosts
+	}
+	dir := certsDir(setKey(ca, hosts))
+	certPath, keyPath := filepath.Join(dir, CertFile), filepath.Join(dir, KeyFile)
+	if validFor(certPath, renewBefore) && fileExists(keyPath) && fileExists(filepath.Join(dir, CAFile)) {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create certs dir: %w", err)
+	}
+	args := append([]string{"-cert-file", certPath, "-key-file", keyPath}, hosts...)
+	if output, err := run("mkcert", args...); err != nil {
+		return "", fmt.Errorf("failed to issue dev certificate: %w\n%s", err, output)
+	}
+	if err := os.WriteFile(filepath.Join(dir, CAFile), ca, 0644); err != nil {
+		return "", fmt.Errorf("failed to stage CA certificate: %w", err)
+	}
+	return dir, nil
+}
+
+// forward stages user-provided certificate files for mounting
+func forward(settings config.DevCertsConfig) (string, error) {
+	if settings.CertFile == "" || settings.KeyFile == "" {
+		return "", fmt.Errorf("dev_certs needs both cert_file and key_file")
+	}
+
+	files := map[string]string{CertFile: settings.CertFile, KeyFile: settings.KeyFile, CAFile: settings.CAFile}
+	contents := map[string][]byte{}
+	var all []byte
+	for _, name := range []string{CertFile, KeyFile, CAFile} {
+		if files[name] == "" {
+			continue
+		}
+		data, err := os.ReadFile(expandHome(files[name]))
+		if err != nil {
+			return "", fmt.Errorf("failed to read dev_certs %s: %w", name, err)
+		}
+		contents[name] = data
+		all = append(all, data...)
+	}
+
+	dir := certsDir(setKey(all, nil))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create certs dir: %w", err)
+	}
+	for name, data := range contents {
+		mode := os.FileMode(0644)
+		if name == KeyFile {
+			mode = 0600
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, mode); err != nil {
+			return "", fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// renewBefore is how close to expiry an issued certificate is replaced
+const renewBefore = 30 * 24 * time.Hour
+
+// validFor reports whether the PEM certificate at path is still valid for at least d
+func validFor(path string, d time.Duration) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	return err == nil && time.Now().Add(d).Before(cert.NotAfter)
+}
+
+// setKey names a certificate set after its CA (or files) and hosts
+func setKey(material []byte, hosts []string) string {
+	sorted := append([]string(nil), hosts...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	h.Write(material)
+	fmt.Fprintf(h, "\nhosts=%s", strings.Join(sorted, ","))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Env points dev servers and Node at the mounted files
+// NODE_EXTRA_CA_CERTS adds to Node's bundled CAs; other tools use the system store TrustScript updates.
+func Env(dir string) []string {
+	env := []string{
+		"PACKNPLAY_TLS_CERT=" + ContainerDir + "/" + CertFile,
+		"PACKNPLAY_TLS_KEY=" + ContainerDir + "/" + KeyFile,
+	}
+	if fileExists(filepath.Join(dir, CAFile)) {
+		env = append(env, "NODE_EXTRA_CA_CERTS="+ContainerDir+"/"+CAFile)
+	}
+	return env
+}
+
+// TrustScript adds the mounted CA to the container's system trust store, for Debian/Alpine
+// (update-ca-certificates) and Fedora/RHEL (update-ca-trust) images
+func TrustScript() string {
+	ca := ContainerDir + "/" + CAFile
+	return fmt.Sprintf(`set -e
+[ -f %[1]s ] || exit 0
+if command -v update-ca-certificates >/dev/null 2>&1; then
+  mkdir -p /usr/local/share/ca-certificates
+  cp %[1]s /usr/local/share/ca-certificates/packnplay-dev-ca.crt
+  update-ca-certificates >/dev/null
+elif command -v update-ca-trust >/dev/null 2>&1; then
+  cp %[1]s /etc/pki/ca-trust/source/anchors/packnplay-dev-ca.pem
+  update-ca-trust extract
+else
+  echo "no CA trust tool (update-ca-certificates or update-ca-trust) in the image" >&2
+  exit 1
+fi
+`, ca)
+}
+
+// expandHome resolves a leading ~/ to the host home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[2:])
+		}
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
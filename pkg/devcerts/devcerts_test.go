@@ -0,0 +1,126 @@
+package devcerts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// writeCert writes a self-signed certificate expiring at notAfter
+func writeCert(t *testing.T, path string, notAfter time.Time) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// fakeMkcert stands in for mkcert with a CA root at caroot
+func fakeMkcert(t *testing.T, caroot string, notAfter time.Time, calls *[]string) Runner {
+	return func(name string, args ...string) (string, error) {
+		*calls = append(*calls, strings.Join(args, " "))
+		if args[0] == "-CAROOT" {
+			return caroot + "\n", nil
+		}
+		writeCert(t, args[1], notAfter)
+		os.WriteFile(args[3], []byte("KEY"), 0600)
+		return "", nil
+	}
+}
+
+func TestPrepareWithMkcert(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	caroot := t.TempDir()
+	os.WriteFile(filepath.Join(caroot, "rootCA.pem"), []byte("CA"), 0644)
+	os.WriteFile(filepath.Join(caroot, "rootCA-key.pem"), []byte("CA KEY"), 0600)
+
+	var calls []string
+	run := fakeMkcert(t, caroot, time.Now().Add(365*24*time.Hour), &calls)
+	dir, err := Prepare(config.DevCertsConfig{Enabled: true}, run)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if len(calls) != 2 || !strings.HasSuffix(calls[1], "localhost 127.0.0.1 ::1") {
+		t.Errorf("mkcert calls = %v", calls)
+	}
+	if ca, _ := os.ReadFile(filepath.Join(dir, CAFile)); string(ca) != "CA" {
+		t.Errorf("ca.pem = %q, want the CA certificate", ca)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("staged %d files, want only cert, key and CA certificate", len(entries))
+	}
+
+	// Reused while valid
+	calls = nil
+	if again, err := Prepare(config.DevCertsConfig{Enabled: true}, run); err != nil || again != dir || len(calls) != 1 {
+		t.Errorf("second Prepare() = %s, %v with calls %v; want reuse of %s", again, err, calls, dir)
+	}
+
+	// Reissued close to expiry, and for other hosts
+	writeCert(t, filepath.Join(dir, CertFile), time.Now().Add(24*time.Hour))
+	calls = nil
+	if _, err := Prepare(config.DevCertsConfig{Enabled: true}, run); err != nil || len(calls) != 2 {
+		t.Errorf("expiring certificate not reissued: %v, calls %v", err, calls)
+	}
+	other, err := Prepare(config.DevCertsConfig{Enabled: true, Hosts: []string{"app.test"}}, run)
+	if err != nil || other == dir {
+		t.Errorf("Prepare() with other hosts = %s, %v; want a separate set", other, err)
+	}
+}
+
+func TestPrepareErrors(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	missing := func(name string, args ...string) (string, error) {
+		return "", fmt.Errorf("exec: %q: %w", name, exec.ErrNotFound)
+	}
+	if _, err := Prepare(config.DevCertsConfig{Enabled: true}, missing); err == nil || !strings.Contains(err.Error(), "needs mkcert") {
+		t.Errorf("Prepare() without mkcert error = %v", err)
+	}
+
+	var calls []string
+	if _, err := Prepare(config.DevCertsConfig{Enabled: true}, fakeMkcert(t, t.TempDir(), time.Now(), &calls)); err == nil || !strings.Contains(err.Error(), "mkcert -install") {
+		t.Errorf("Prepare() without a CA error = %v", err)
+	}
+
+	if _, err := Prepare(config.DevCertsConfig{Enabled: true, CertFile: "cert.pem"}, missing); err == nil {
+		t.Error("Prepare() with cert_file but no key_file should fail")
+	}
+}
+
+func TestPrepareForwardsFiles(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "dev.crt"), []byte("CERT"), 0644)
+	os.WriteFile(filepath.Join(src, "dev.key"), []byte("KEY"), 0600)
+
+	dir, err := Prepare(config.DevCertsConfig{Enabled: true, CertFile: filepath.Join(src, "dev.crt"), KeyFile: filepath.Join(src, "dev.key")}, nil)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if key, _ := os.ReadFile(filepath.Join(dir, KeyFile)); string(key) != "KEY" {
+		t.Errorf("key.pem = %q", key)
+	}
+
+	// Without a CA there's nothing for Node to add
+	env := strings.Join(Env(dir), " ")
+	if !strings.Contains(env, "PACKNPLAY_TLS_CERT=/etc/packnplay/tls/cert.pem") || strings.Contains(env, "NODE_EXTRA_CA_CERTS") {
+		t.Errorf("Env() = %s", env)
+	}
+}
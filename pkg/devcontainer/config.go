@@ -2,17 +2,21 @@ package devcontainer
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/obra/packnplay/pkg/userdetect"
 )
 
 // Config represents a parsed devcontainer.json
 type Config struct {
-	Image       string `json:"image"`
-	DockerFile  string `json:"dockerFile"`
-	RemoteUser  string `json:"remoteUser"`
+	Image             string           `json:"image"`
+	DockerFile        string           `json:"dockerFile"`
+	RemoteUser        string           `json:"remoteUser"`
+	PostCreateCommand LifecycleCommand `json:"postCreateCommand"`
 }
 
 // LoadConfig loads and parses .devcontainer/devcontainer.json if it exists
@@ -67,3 +71,62 @@ func GetDefaultConfig(defaultImage string) *Config {
 		RemoteUser: remoteUser,
 	}
 }
+
+// LifecycleCommand is a devcontainer lifecycle command as argv
+// devcontainer.json allows a shell string, an argv array, or an object of named commands;
+// strings and objects are run through /bin/sh.
+type LifecycleCommand []string
+
+// UnmarshalJSON accepts all three devcontainer.json command forms
+func (c *LifecycleCommand) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		if str != "" {
+			*c = LifecycleCommand{"/bin/sh", "-c", str}
+		}
+		return nil
+	}
+
+	var argv []string
+	if err := json.Unmarshal(data, &argv); err == nil {
+		*c = argv
+		return nil
+	}
+
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(data, &named); err != nil {
+		return fmt.Errorf("lifecycle command must be a string, array or object")
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Named commands run in parallel in the spec; sequentially is close enough for setup
+	var scripts []string
+	for _, name := range names {
+		var sub LifecycleCommand
+		if err := sub.UnmarshalJSON(named[name]); err != nil {
+			return fmt.Errorf("lifecycle command '%s': %w", name, err)
+		}
+		if len(sub) == 3 && sub[0] == "/bin/sh" && sub[1] == "-c" {
+			scripts = append(scripts, "("+sub[2]+")")
+		} else if len(sub) > 0 {
+			scripts = append(scripts, shellJoin(sub))
+		}
+	}
+	if len(scripts) > 0 {
+		*c = LifecycleCommand{"/bin/sh", "-c", strings.Join(scripts, " && ")}
+	}
+	return nil
+}
+
+// shellJoin quotes argv for /bin/sh
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
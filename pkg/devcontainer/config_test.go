@@ -1,8 +1,10 @@
 package devcontainer
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -72,3 +74,33 @@ func TestGetDefaultConfig(t *testing.T) {
 		t.Errorf("GetDefaultConfig(%v) RemoteUser should not be empty", ubuntuImage)
 	}
 }
+
+func TestLifecycleCommandForms(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want LifecycleCommand
+	}{
+		{"string", `"npm ci"`, LifecycleCommand{"/bin/sh", "-c", "npm ci"}},
+		{"array", `["go", "mod", "download"]`, LifecycleCommand{"go", "mod", "download"}},
+		{"object", `{"web": "npm ci", "api": ["go", "mod", "download"]}`, LifecycleCommand{"/bin/sh", "-c", "'go' 'mod' 'download' && (npm ci)"}},
+		{"empty string", `""`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got LifecycleCommand
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var bad LifecycleCommand
+	if err := json.Unmarshal([]byte(`42`), &bad); err == nil {
+		t.Error("Unmarshal(number) should fail")
+	}
+}
@@ -0,0 +1,234 @@
+package diskusage
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is a sort of space packnplay consumes
+type Kind string
+
+const (
+	KindImage      Kind = "image"      // images packnplay built: devcontainer, agent, package, setup and shell layers
+	KindCache      Kind = "cache"      // shared cache volumes
+	KindOverlay    Kind = "overlay"    // split-mode overlays, the agent's writes between snapshots
+	KindHome       Kind = "home"       // persisted session home directories
+	KindWorktree   Kind = "worktree"   // worktrees packnplay created
+	KindTranscript Kind = "transcript" // session recordings
+)
+
+// Kinds lists every kind, in report order
+var Kinds = []Kind{KindImage, KindCache, KindOverlay, KindHome, KindWorktree, KindTranscript}
+
+// How long leftovers are kept before they're recommended for cleanup
+const (
+	HomeMaxAge       = 14 * 24 * time.Hour // a home whose container is gone
+	TranscriptMaxAge = 30 * 24 * time.Hour
+)
+
+// Item is one thing taking up space
+type Item struct {
+	Kind     Kind
+	Project  string // "" for what projects share, like caches
+	Name     string // image reference, volume, session container or file name
+	Path     string // host path, for what's on disk
+	Bytes    int64  // -1 when the runtime didn't report a size
+	Modified time.Time
+	Reclaim  string // why it can go; "" to keep it
+}
+
+// imageKinds are the suffixes of the image repositories packnplay builds per project
+var imageKinds = []string{"-devcontainer", "-agents", "-packages", "-setup", "-shell"}
+
+// ImageProject returns the project an image repository was built for
+func ImageProject(repository string) (string, bool) {
+	name, ok := strings.CutPrefix(repository, "packnplay-")
+	if !ok {
+		return "", false
+	}
+	for _, suffix := range imageKinds {
+		if project, ok := strings.CutSuffix(name, suffix); ok && project != "" {
+			return project, true
+		}
+	}
+	return "", false
+}
+
+// ProjectOf returns the project a session container belongs to, from the known project
+// names; names may contain dashes, so the longest match wins
+func ProjectOf(container string, projects []string) string {
+	best := ""
+	for _, project := range projects {
+		if strings.HasPrefix(container, "packnplay-"+project+"-") && len(project) > len(best) {
+			best = project
+		}
+	}
+	return best
+}
+
+// Advise marks what can be reclaimed. live holds the session containers that still exist;
+// nil means the runtime couldn't be asked, so nothing tied to a container is marked.
+// Images must be in the runtime's order, newest first within a repository.
+func Advise(items []Item, live map[string]bool, now time.Time) {
+	newest := make(map[string]bool)
+	for i := range items {
+		item := &items[i]
+		switch item.Kind {
+		case KindImage:
+			repository, _, _ := strings.Cut(item.Name, ":")
+			if newest[repository] {
+				item.Reclaim = "superseded by a newer build"
+			}
+			newest[repository] = true
+		case KindOverlay:
+			if live != nil && !live[item.Name] {
+				item.Reclaim = "session container is gone; its snapshots are on the session branch"
+			}
+		case KindHome:
+			if live != nil && !live[item.Name] && now.Sub(item.Modified) > HomeMaxAge {
+				item.Reclaim = fmt.Sprintf("session container is gone and it's unused for %d days", int(now.Sub(item.Modified).Hours()/24))
+			}
+		case KindTranscript:
+			if now.Sub(item.Modified) > TranscriptMaxAge {
+				item.Reclaim = fmt.Sprintf("older than %d days", int(TranscriptMaxAge.Hours()/24))
+			}
+		}
+	}
+}
+
+// ProjectUsage totals a project's space
+type ProjectUsage struct {
+	Project     string
+	Bytes       map[Kind]int64
+	Total       int64
+	Reclaimable int64
+}
+
+// Summarize totals items by project, largest first; unknown sizes count as zero
+func Summarize(items []Item) []ProjectUsage {
+	byProject := make(map[string]*ProjectUsage)
+	for _, item := range items {
+		usage, ok := byProject[item.Project]
+		if !ok {
+			usage = &ProjectUsage{Project: item.Project, Bytes: make(map[Kind]int64)}
+			byProject[item.Project] = usage
+		}
+		size := max(item.Bytes, 0)
+		usage.Bytes[item.Kind] += size
+		usage.Total += size
+		if item.Reclaim != "" {
+			usage.Reclaimable += size
+		}
+	}
+
+	usages := make([]ProjectUsage, 0, len(byProject))
+	for _, usage := range byProject {
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Total != usages[j].Total {
+			return usages[i].Total > usages[j].Total
+		}
+		return usages[i].Project < usages[j].Project
+	})
+	return usages
+}
+
+// DirSize returns the bytes of the files under root and when any was last modified
+// Unreadable parts, like files the container user owns, are skipped.
+func DirSize(root string) (int64, time.Time) {
+	var total int64
+	var modified time.Time
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+		return nil
+	})
+	return total, modified
+}
+
+// VolumeSizes reads volume sizes from `docker system df -v` (or podman's)
+func VolumeSizes(output string) map[string]int64 {
+	sizes := make(map[string]int64)
+	inVolumes := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "VOLUME NAME") {
+			inVolumes = true
+			continue
+		}
+		if !inVolumes {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			inVolumes = false
+			continue
+		}
+		// podman and older docker print "1.2 GB", newer docker "1.2GB"
+		sizeText := fields[len(fields)-1]
+		if _, isUnit := sizeUnits[strings.ToUpper(sizeText)]; isUnit && len(fields) > 2 {
+			sizeText = fields[len(fields)-2] + fields[len(fields)-1]
+		}
+		if size, ok := ParseSize(sizeText); ok {
+			sizes[fields[0]] = size
+		}
+	}
+	return sizes
+}
+
+// sizeUnits are the runtimes' human size units, which are decimal
+var sizeUnits = map[string]float64{
+	"B": 1, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+}
+
+// ParseSize parses a runtime's human size, like "1.21GB" or "512 kB"
+func ParseSize(s string) (int64, bool) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i <= 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := sizeUnits[s[i:]]
+	if err != nil || !ok {
+		return 0, false
+	}
+	return int64(value * unit), true
+}
+
+// FormatBytes formats a size the way the runtimes do, e.g. "1.2 GB"
+func FormatBytes(n int64) string {
+	if n < 0 {
+		return "?"
+	}
+	value := float64(n)
+	for _, unit := range []string{"B", "kB", "MB", "GB"} {
+		if value < 1000 {
+			if unit == "B" {
+				return fmt.Sprintf("%d B", n)
+			}
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+		value /= 1000
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
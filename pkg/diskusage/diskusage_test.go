@@ -0,0 +1,161 @@
+package diskusage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageProject(t *testing.T) {
+	tests := []struct {
+		repository string
+		want       string
+		ok         bool
+	}{
+		{"packnplay-app-setup", "app", true},
+		{"packnplay-my-app-devcontainer", "my-app", true},
+		{"packnplay-app-agents", "app", true},
+		{"packnplay-default", "", false},
+		{"ghcr.io/obra/packnplay-default", "", false},
+		{"packnplay--shell", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ImageProject(tt.repository)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ImageProject(%q) = %q, %v, want %q, %v", tt.repository, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestProjectOf(t *testing.T) {
+	projects := []string{"app", "app-api", "web"}
+	for container, want := range map[string]string{
+		"packnplay-app-main":         "app",
+		"packnplay-app-api-main":     "app-api",
+		"packnplay-web-feature-auth": "web",
+		"packnplay-other-main":       "",
+	} {
+		if got := ProjectOf(container, projects); got != want {
+			t.Errorf("ProjectOf(%q) = %q, want %q", container, got, want)
+		}
+	}
+}
+
+func TestAdvise(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	items := []Item{
+		{Kind: KindImage, Name: "packnplay-app-setup:new"},
+		{Kind: KindImage, Name: "packnplay-app-setup:old"},
+		{Kind: KindImage, Name: "packnplay-app-shell:only"},
+		{Kind: KindOverlay, Name: "packnplay-app-main"},
+		{Kind: KindOverlay, Name: "packnplay-app-gone"},
+		{Kind: KindHome, Name: "packnplay-app-gone", Modified: now.Add(-30 * 24 * time.Hour)},
+		{Kind: KindHome, Name: "packnplay-app-recent", Modified: now.Add(-time.Hour)},
+		{Kind: KindTranscript, Name: "old.cast", Modified: now.Add(-40 * 24 * time.Hour)},
+		{Kind: KindTranscript, Name: "new.cast", Modified: now.Add(-24 * time.Hour)},
+		{Kind: KindCache, Name: "packnplay-cache-npm"},
+		{Kind: KindWorktree, Name: "main"},
+	}
+	Advise(items, map[string]bool{"packnplay-app-main": true}, now)
+
+	reclaimable := map[string]bool{}
+	for _, item := range items {
+		if item.Reclaim != "" {
+			reclaimable[string(item.Kind)+" "+item.Name] = true
+		}
+	}
+	want := map[string]bool{
+		"image packnplay-app-setup:old": true,
+		"overlay packnplay-app-gone":    true,
+		"home packnplay-app-gone":       true,
+		"transcript old.cast":           true,
+	}
+	if len(reclaimable) != len(want) {
+		t.Errorf("reclaimable = %v, want %v", reclaimable, want)
+	}
+	for key := range want {
+		if !reclaimable[key] {
+			t.Errorf("%s should be reclaimable", key)
+		}
+	}
+
+	// Without the runtime, nothing tied to a container is marked
+	items = []Item{{Kind: KindOverlay, Name: "packnplay-app-gone"}}
+	Advise(items, nil, now)
+	if items[0].Reclaim != "" {
+		t.Errorf("overlay marked without knowing the live containers: %s", items[0].Reclaim)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	usages := Summarize([]Item{
+		{Kind: KindImage, Project: "app", Bytes: 100, Reclaim: "stale"},
+		{Kind: KindImage, Project: "app", Bytes: 50},
+		{Kind: KindHome, Project: "app", Bytes: 10},
+		{Kind: KindCache, Bytes: 500},
+		{Kind: KindImage, Project: "web", Bytes: -1},
+	})
+	if len(usages) != 3 || usages[0].Project != "" || usages[1].Project != "app" {
+		t.Fatalf("unexpected order: %+v", usages)
+	}
+	app := usages[1]
+	if app.Total != 160 || app.Reclaimable != 100 || app.Bytes[KindImage] != 150 {
+		t.Errorf("app usage = %+v", app)
+	}
+	if usages[2].Total != 0 {
+		t.Errorf("unknown sizes should count as zero: %+v", usages[2])
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, size := range map[string]int{"one": 10, "a/two": 20, "a/b/three": 30} {
+		if err := os.WriteFile(filepath.Join(dir, path), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size, modified := DirSize(dir); size != 60 || modified.IsZero() {
+		t.Errorf("DirSize = %d, %v, want 60", size, modified)
+	}
+}
+
+func TestVolumeSizes(t *testing.T) {
+	docker := `Images space usage:
+
+REPOSITORY   TAG   IMAGE ID   CREATED   SIZE   SHARED SIZE   UNIQUE SIZE   CONTAINERS
+alpine       3     abc        2 weeks   7.8MB  0B            7.8MB         0
+
+Local Volumes space usage:
+
+VOLUME NAME            LINKS     SIZE
+packnplay-cache-npm    2         1.21GB
+packnplay-cache-go     0         512kB
+
+Build cache usage: 0B
+`
+	sizes := VolumeSizes(docker)
+	if sizes["packnplay-cache-npm"] != 1210000000 || sizes["packnplay-cache-go"] != 512000 || len(sizes) != 2 {
+		t.Errorf("docker sizes = %v", sizes)
+	}
+
+	podman := `Local Volumes space usage:
+
+VOLUME NAME           LINKS       SIZE
+packnplay-cache-npm   1           1.21 GB
+`
+	if sizes := VolumeSizes(podman); sizes["packnplay-cache-npm"] != 1210000000 {
+		t.Errorf("podman sizes = %v", sizes)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{-1: "?", 0: "0 B", 999: "999 B", 1500: "1.5 kB", 1210000000: "1.2 GB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package diskusage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+	"github.com/obra/packnplay/pkg/summary"
+)
+
+// Scan finds the space packnplay uses, on disk and in the container runtime, and marks
+// what can be reclaimed. Runtime failures are warned about, leaving the disk report.
+func Scan(dockerClient *docker.Client) []Item {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	dataDir := filepath.Join(xdgDataHome, "packnplay")
+
+	live, projects := sessionContainers(dockerClient)
+	var items []Item
+
+	// Images first: they name the projects that have built anything
+	images, err := imageItems(dockerClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	for _, image := range images {
+		projects = append(projects, image.Project)
+	}
+	items = append(items, images...)
+
+	caches, err := cacheItems(dockerClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	items = append(items, caches...)
+
+	worktreeRoot := filepath.Join(dataDir, "worktrees")
+	projectDirs, _ := os.ReadDir(worktreeRoot)
+	for _, projectDir := range projectDirs {
+		if !projectDir.IsDir() {
+			continue
+		}
+		projects = append(projects, projectDir.Name())
+		worktrees, _ := os.ReadDir(filepath.Join(worktreeRoot, projectDir.Name()))
+		for _, worktree := range worktrees {
+			if worktree.IsDir() {
+				items = append(items, dirItem(KindWorktree, projectDir.Name(), worktree.Name(), filepath.Join(worktreeRoot, projectDir.Name(), worktree.Name())))
+			}
+		}
+	}
+	if entries, err := session.LoadStore(); err == nil {
+		for _, entry := range entries {
+			projects = append(projects, entry.Project)
+		}
+	}
+
+	// Per-session directories, named after the session container
+	for _, kind := range []Kind{KindOverlay, KindHome} {
+		dir := filepath.Join(dataDir, "homes")
+		if kind == KindOverlay {
+			dir = filepath.Join(dataDir, "split")
+		}
+		sessions, _ := os.ReadDir(dir)
+		for _, s := range sessions {
+			if s.IsDir() {
+				items = append(items, dirItem(kind, ProjectOf(s.Name(), projects), s.Name(), filepath.Join(dir, s.Name())))
+			}
+		}
+	}
+
+	recordings, err := recording.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	for _, rec := range recordings {
+		info, err := os.Stat(rec.Path)
+		if err != nil {
+			continue
+		}
+		items = append(items, Item{Kind: KindTranscript, Project: ProjectOf(rec.Session, projects), Name: filepath.Base(rec.Path), Path: rec.Path, Bytes: info.Size(), Modified: info.ModTime()})
+	}
+
+	Advise(items, live, time.Now())
+	return items
+}
+
+func dirItem(kind Kind, project, name, path string) Item {
+	size, modified := DirSize(path)
+	return Item{Kind: kind, Project: project, Name: name, Path: path, Bytes: size, Modified: modified}
+}
+
+// sessionContainers returns the session containers that exist, running or not, and their
+// projects; the containers are nil if the runtime can't be asked
+func sessionContainers(dockerClient *docker.Client) (map[string]bool, []string) {
+	output, err := dockerClient.Run("ps", "-a", "--filter", "label=managed-by=packnplay", "--format", "{{.Names}}\t{{.Label \"packnplay-project\"}}")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to list containers, so nothing tied to a session is recommended for cleanup: %v\n", err)
+		return nil, nil
+	}
+	live := make(map[string]bool)
+	var projects []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		name, project, _ := strings.Cut(line, "\t")
+		if name == "" {
+			continue
+		}
+		live[name] = true
+		if project != "" {
+			projects = append(projects, project)
+		}
+	}
+	return live, projects
+}
+
+// imageItems lists packnplay's images, newest first within a repository
+func imageItems(dockerClient *docker.Client) ([]Item, error) {
+	output, err := dockerClient.Run("images", "--filter", "reference=packnplay-*", "--format", "{{.Repository}}:{{.Tag}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	var items []Item
+	var refs []string
+	for _, ref := range strings.Fields(output) {
+		repository, tag, _ := strings.Cut(ref, ":")
+		project, ok := ImageProject(repository)
+		if !ok || tag == "<none>" {
+			continue
+		}
+		items = append(items, Item{Kind: KindImage, Project: project, Name: ref, Bytes: -1})
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	sizes, err := dockerClient.Run(append([]string{"image", "inspect", "--format", "{{.Size}}"}, refs...)...)
+	if err != nil {
+		return items, fmt.Errorf("failed to read image sizes: %w", err)
+	}
+	for i, line := range strings.Fields(sizes) {
+		if i < len(items) {
+			fmt.Sscan(line, &items[i].Bytes)
+		}
+	}
+	return items, nil
+}
+
+// cacheItems lists the shared cache volumes
+func cacheItems(dockerClient *docker.Client) ([]Item, error) {
+	output, err := dockerClient.Run("volume", "ls", "--filter", "label="+container.CacheLabel, "--format", "{{.Name}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache volumes: %w", err)
+	}
+	names := strings.Fields(output)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	// Volume sizes come only from the runtime's full disk report
+	df, err := dockerClient.Run("system", "df", "-v")
+	sizes := VolumeSizes(df)
+	var items []Item
+	for _, name := range names {
+		size, ok := sizes[name]
+		if !ok {
+			size = -1
+		}
+		items = append(items, Item{Kind: KindCache, Name: name, Bytes: size})
+	}
+	if err != nil {
+		return items, fmt.Errorf("failed to read volume sizes: %w", err)
+	}
+	return items, nil
+}
+
+// Remove deletes a reclaimable item
+// Overlays are removed with a throwaway container of image, since the agent's files are
+// owned by the container user.
+func Remove(dockerClient *docker.Client, item Item, image string) error {
+	switch item.Kind {
+	case KindImage:
+		if output, err := dockerClient.Run("rmi", item.Name); err != nil {
+			return fmt.Errorf("failed to remove image %s: %w\n%s", item.Name, err, output)
+		}
+		return nil
+	case KindOverlay:
+		_, _ = dockerClient.Run("volume", "rm", "-f", sessionbranch.VolumeName(item.Name))
+		if err := sessionbranch.Cleanup(dockerClient, item.Name, image); err != nil {
+			return err
+		}
+		return sessionbranch.Forget(item.Name)
+	case KindHome, KindTranscript:
+		if err := os.RemoveAll(item.Path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", item.Path, err)
+		}
+		if item.Kind == KindTranscript {
+			_ = os.Remove(summary.Path(item.Path))
+		}
+		return nil
+	}
+	return fmt.Errorf("%s %s isn't removed by packnplay", item.Kind, item.Name)
+}
@@ -0,0 +1,150 @@
+package display
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Display modes
+const (
+	ModeHost = "host" // forward the host's X11 or Wayland display (Linux)
+	ModeVNC  = "vnc"  // run a virtual display in a sidecar, viewable with noVNC in a browser
+)
+
+// DefaultVNCImage runs Xvfb listening on TCP :0 plus noVNC on port 8080
+const DefaultVNCImage = "theasp/novnc:latest"
+
+// NoVNCPort is the sidecar's web viewer port
+const NoVNCPort = "8080"
+
+// Container paths for forwarded display sockets
+const (
+	containerXauthority = "/tmp/.packnplay-Xauthority"
+	containerRuntimeDir = "/tmp/packnplay-runtime"
+)
+
+// Validate rejects unknown display modes
+func Validate(mode string) error {
+	switch mode {
+	case "", ModeHost, ModeVNC:
+		return nil
+	}
+	return fmt.Errorf("unknown display mode '%s' (use host or vnc)", mode)
+}
+
+// HostArgs returns docker args forwarding the host's display into the container
+// X11 needs its socket directory and a cookie the container can use; Wayland needs its socket.
+// xauthPath is where a copy of the X cookie rewritten for the container is written.
+func HostArgs(getenv func(string) string, xauthPath string) ([]string, error) {
+	var args []string
+
+	if display := getenv("DISPLAY"); display != "" {
+		if !strings.HasPrefix(display, ":") {
+			return nil, fmt.Errorf("can't forward DISPLAY=%s; only local displays (like :0) are supported, use display mode vnc", display)
+		}
+		args = append(args, "-v", "/tmp/.X11-unix:/tmp/.X11-unix:ro", "-e", "DISPLAY="+display)
+
+		source := getenv("XAUTHORITY")
+		if source == "" {
+			source = filepath.Join(getenv("HOME"), ".Xauthority")
+		}
+		if _, err := os.Stat(source); err == nil {
+			if err := WildcardXauthority(source, xauthPath); err != nil {
+				return nil, err
+			}
+			args = append(args, "-v", xauthPath+":"+containerXauthority+":ro", "-e", "XAUTHORITY="+containerXauthority)
+		}
+	}
+
+	if wayland := getenv("WAYLAND_DISPLAY"); wayland != "" && getenv("XDG_RUNTIME_DIR") != "" {
+		socket := wayland
+		if !filepath.IsAbs(socket) {
+			socket = filepath.Join(getenv("XDG_RUNTIME_DIR"), wayland)
+		}
+		args = append(args,
+			"-v", socket+":"+containerRuntimeDir+"/wayland-0",
+			"-e", "WAYLAND_DISPLAY=wayland-0",
+			"-e", "XDG_RUNTIME_DIR="+containerRuntimeDir)
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no host display to forward (DISPLAY and WAYLAND_DISPLAY are unset); use display mode vnc")
+	}
+	return args, nil
+}
+
+// familyWild matches any host, so the cookie works under the container's hostname
+const familyWild = 0xffff
+
+// WildcardXauthority copies an Xauthority file with every entry's address family set to
+// FamilyWild (what `xauth nlist | sed 's/^..../ffff/'` does), since X matches local
+// cookies by hostname and the container has a different one
+func WildcardXauthority(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read X authority: %w", err)
+	}
+
+	var out bytes.Buffer
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return fmt.Errorf("failed to parse X authority %s: %w", src, err)
+		}
+		binary.Write(&out, binary.BigEndian, uint16(familyWild))
+		// address, display number, auth name, auth data
+		for i := 0; i < 4; i++ {
+			var length uint16
+			if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+				return fmt.Errorf("failed to parse X authority %s: %w", src, err)
+			}
+			field := make([]byte, length)
+			if _, err := io.ReadFull(r, field); err != nil {
+				return fmt.Errorf("failed to parse X authority %s: %w", src, err)
+			}
+			binary.Write(&out, binary.BigEndian, length)
+			out.Write(field)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return fmt.Errorf("failed to create display dir: %w", err)
+	}
+	if err := os.WriteFile(dst, out.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write X authority: %w", err)
+	}
+	return nil
+}
+
+// SidecarName returns the name of a session's virtual display container
+func SidecarName(container string) string {
+	return container + "-display"
+}
+
+// SessionArgs returns the session container's args for a VNC sidecar
+// The sidecar joins the session's network namespace, so the session publishes its viewer port.
+func SessionArgs() []string {
+	return []string{"-e", "DISPLAY=127.0.0.1:0", "-p", "127.0.0.1::" + NoVNCPort}
+}
+
+// SidecarArgs returns the docker run args for a session's virtual display
+func SidecarArgs(container, image, resolution string) []string {
+	if image == "" {
+		image = DefaultVNCImage
+	}
+	args := []string{"run", "-d", "--rm",
+		"--name", SidecarName(container),
+		"--network", "container:" + container,
+		"--label", "managed-by=packnplay",
+	}
+	if width, height, ok := strings.Cut(resolution, "x"); ok {
+		args = append(args, "-e", "DISPLAY_WIDTH="+width, "-e", "DISPLAY_HEIGHT="+height)
+	}
+	return append(args, image)
+}
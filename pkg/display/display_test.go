@@ -0,0 +1,87 @@
+package display
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// xauthEntry encodes one Xauthority record
+func xauthEntry(family uint16, fields ...string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, family)
+	for _, field := range fields {
+		binary.Write(&buf, binary.BigEndian, uint16(len(field)))
+		buf.WriteString(field)
+	}
+	return buf.Bytes()
+}
+
+func TestWildcardXauthority(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, ".Xauthority")
+	cookie := "0123456789abcdef"
+	data := append(xauthEntry(256, "laptop", "0", "MIT-MAGIC-COOKIE-1", cookie), xauthEntry(0, "\x7f\x00\x00\x01", "1", "MIT-MAGIC-COOKIE-1", cookie)...)
+	os.WriteFile(src, data, 0600)
+
+	dst := filepath.Join(dir, "display", "session.Xauthority")
+	if err := WildcardXauthority(src, dst); err != nil {
+		t.Fatalf("WildcardXauthority() error = %v", err)
+	}
+	got, _ := os.ReadFile(dst)
+	want := append(xauthEntry(0xffff, "laptop", "0", "MIT-MAGIC-COOKIE-1", cookie), xauthEntry(0xffff, "\x7f\x00\x00\x01", "1", "MIT-MAGIC-COOKIE-1", cookie)...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("WildcardXauthority() wrote %q, want %q", got, want)
+	}
+	if info, _ := os.Stat(dst); info.Mode().Perm() != 0600 {
+		t.Errorf("cookie file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	os.WriteFile(src, data[:len(data)-3], 0600)
+	if err := WildcardXauthority(src, dst); err == nil {
+		t.Error("expected an error for a truncated file")
+	}
+}
+
+func TestHostArgs(t *testing.T) {
+	home := t.TempDir()
+	os.WriteFile(filepath.Join(home, ".Xauthority"), xauthEntry(256, "laptop", "0", "MIT-MAGIC-COOKIE-1", "cookie"), 0600)
+	xauthPath := filepath.Join(t.TempDir(), "session.Xauthority")
+
+	env := map[string]string{
+		"HOME":            home,
+		"DISPLAY":         ":0",
+		"WAYLAND_DISPLAY": "wayland-1",
+		"XDG_RUNTIME_DIR": "/run/user/1000",
+	}
+	args, err := HostArgs(func(key string) string { return env[key] }, xauthPath)
+	if err != nil {
+		t.Fatalf("HostArgs() error = %v", err)
+	}
+	want := "-v /tmp/.X11-unix:/tmp/.X11-unix:ro -e DISPLAY=:0 " +
+		"-v " + xauthPath + ":/tmp/.packnplay-Xauthority:ro -e XAUTHORITY=/tmp/.packnplay-Xauthority " +
+		"-v /run/user/1000/wayland-1:/tmp/packnplay-runtime/wayland-0 -e WAYLAND_DISPLAY=wayland-0 -e XDG_RUNTIME_DIR=/tmp/packnplay-runtime"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("HostArgs() = %s\nwant %s", got, want)
+	}
+
+	env = map[string]string{"DISPLAY": "remote:10.0"}
+	if _, err := HostArgs(func(key string) string { return env[key] }, xauthPath); err == nil {
+		t.Error("expected an error for a forwarded SSH display")
+	}
+	if _, err := HostArgs(func(string) string { return "" }, xauthPath); err == nil {
+		t.Error("expected an error with no display to forward")
+	}
+}
+
+func TestSidecarArgs(t *testing.T) {
+	got := strings.Join(SidecarArgs("packnplay-app-main", "", "1280x800"), " ")
+	want := "run -d --rm --name packnplay-app-main-display --network container:packnplay-app-main --label managed-by=packnplay " +
+		"-e DISPLAY_WIDTH=1280 -e DISPLAY_HEIGHT=800 theasp/novnc:latest"
+	if got != want {
+		t.Errorf("SidecarArgs() = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,329 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/obra/packnplay/pkg/redact"
+)
+
+// RecordEnv names a file to record every runtime command to, as a fixture for a Replayer
+const RecordEnv = "PACKNPLAY_RECORD_RUNTIME"
+
+// Executor runs the commands a session needs: the runtime's CLI, and the host programs
+// (tmux, bwrap) packnplay hands a session's terminal to
+type Executor interface {
+	// Exec runs a command and returns its combined output
+	Exec(name string, args []string) (string, error)
+	// Command returns a command for the caller to connect and start, for commands whose
+	// input and output are streamed rather than collected
+	Command(ctx context.Context, name string, args []string) *exec.Cmd
+	// Handoff replaces packnplay with a command, with env as its environment; it only
+	// returns if the command can't be started
+	Handoff(name string, args []string, env []string) error
+}
+
+// DefaultExecutor returns the executor that runs commands for real, recording them to the
+// fixture named by RecordEnv if it's set. runtime names the runtime a recording is for.
+func DefaultExecutor(runtime string) Executor {
+	if path := os.Getenv(RecordEnv); path != "" {
+		return NewRecorder(path, runtime, cliExecutor{})
+	}
+	return cliExecutor{}
+}
+
+// cliExecutor runs commands on the host
+type cliExecutor struct{}
+
+func (cliExecutor) Exec(name string, args []string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	return string(output), err
+}
+
+func (cliExecutor) Command(ctx context.Context, name string, args []string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func (cliExecutor) Handoff(name string, args []string, env []string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", name, err)
+	}
+	return syscall.Exec(path, append([]string{filepath.Base(name)}, args...), env)
+}
+
+// How a recorded command was run
+const (
+	KindStream  = "stream"  // with Command; its output went to the caller, so none is recorded
+	KindHandoff = "handoff" // with Handoff
+)
+
+// Call is one recorded command
+type Call struct {
+	Kind    string   `json:"kind,omitempty"`    // how it was run; empty for Exec
+	Program string   `json:"program,omitempty"` // the program, when it isn't the runtime
+	Args    []string `json:"args"`
+	Output  string   `json:"output,omitempty"`
+	Exit    int      `json:"exit,omitempty"`  // exit code when the command failed
+	Error   string   `json:"error,omitempty"` // why the command couldn't run at all
+}
+
+// Cassette is a fixture: the runtime commands an operation ran, in order
+type Cassette struct {
+	Runtime string `json:"runtime"`
+	Calls   []Call `json:"calls"`
+}
+
+// LoadCassette reads a fixture
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the fixture, indented so changes to it review well
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ExitError is a replayed command's failure
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Recorder runs commands with another executor and appends each to a fixture file
+// Args and output are redacted as in verbose output, and the home directory is written as
+// ${HOME}, so fixtures can be committed. The file is rewritten after every command, so a
+// recording survives packnplay exec'ing into a session, and every client in the process
+// adds to the same recording.
+type Recorder struct {
+	path    string
+	runtime string
+	next    Executor
+	mu      sync.Mutex
+}
+
+// NewRecorder records the commands next runs to path, appending if it already exists
+func NewRecorder(path, runtime string, next Executor) *Recorder {
+	return &Recorder{path: path, runtime: runtime, next: next}
+}
+
+func (r *Recorder) Exec(name string, args []string) (string, error) {
+	output, err := r.next.Exec(name, args)
+	r.record("", name, args, output, err)
+	return output, err
+}
+
+// Command records the command before it runs; what it streams isn't recorded
+func (r *Recorder) Command(ctx context.Context, name string, args []string) *exec.Cmd {
+	r.record(KindStream, name, args, "", nil)
+	return r.next.Command(ctx, name, args)
+}
+
+// Handoff records the command before packnplay is replaced by it
+func (r *Recorder) Handoff(name string, args []string, env []string) error {
+	r.record(KindHandoff, name, args, "", nil)
+	return r.next.Handoff(name, args, env)
+}
+
+// record appends a command to the fixture
+func (r *Recorder) record(kind, name string, args []string, output string, err error) {
+	redactor := redact.New(nil)
+	call := Call{Kind: kind, Args: redactor.Args(args), Output: redactor.Redact(output)}
+	if program := filepath.Base(name); program != r.runtime {
+		call.Program = program
+	}
+	if home, homeErr := os.UserHomeDir(); homeErr == nil && home != "/" {
+		for i, arg := range call.Args {
+			call.Args[i] = strings.ReplaceAll(arg, home, "${HOME}")
+		}
+		call.Output = strings.ReplaceAll(call.Output, home, "${HOME}")
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		call.Exit = exitErr.ExitCode()
+	default:
+		call.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cassette, loadErr := LoadCassette(r.path)
+	if errors.Is(loadErr, os.ErrNotExist) {
+		cassette, loadErr = &Cassette{Runtime: r.runtime}, nil
+	}
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not recording %s %s: %v\n", name, strings.Join(call.Args, " "), loadErr)
+		return
+	}
+	cassette.Calls = append(cassette.Calls, call)
+	if saveErr := cassette.Save(r.path); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not recording %s %s: %v\n", name, strings.Join(call.Args, " "), saveErr)
+	}
+}
+
+// Replayer answers commands from a fixture instead of running them, so runtime logic can be
+// tested without a container runtime. Commands must arrive in the recorded order; Verify
+// reports any that didn't, and any recorded commands that never ran.
+type Replayer struct {
+	cassette   *Cassette
+	vars       map[string]string
+	next       int
+	mismatches []string
+	mu         sync.Mutex
+}
+
+// NewReplayer loads a fixture recorded with RecordEnv, or written by hand
+func NewReplayer(path string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fixture: %w", err)
+	}
+	return &Replayer{cassette: cassette, vars: map[string]string{}}, nil
+}
+
+// Set replaces ${name} in the fixture's args and output with value, e.g. HOME with a
+// test's temporary home directory
+func (r *Replayer) Set(name, value string) {
+	r.vars[name] = value
+}
+
+// Client returns a client for the fixture's runtime that replays its commands
+func (r *Replayer) Client() *Client {
+	return NewClientWithExecutor(r.cassette.Runtime, r, false)
+}
+
+func (r *Replayer) Exec(name string, args []string) (string, error) {
+	call, err := r.replay("", name, args)
+	if err != nil {
+		return "", err
+	}
+	return call.Output, call.err()
+}
+
+// Command returns a stand-in that prints the recorded output and exits with the recorded
+// status; it doesn't read its input
+func (r *Replayer) Command(ctx context.Context, name string, args []string) *exec.Cmd {
+	call, err := r.replay(KindStream, name, args)
+	if err != nil {
+		return exec.CommandContext(ctx, "sh", "-c", `printf '%s\n' "$1" >&2; exit 1`, "sh", err.Error())
+	}
+	return exec.CommandContext(ctx, "sh", "-c", `printf '%s' "$1"; exit "$2"`, "sh", call.Output, fmt.Sprint(call.Exit))
+}
+
+// Handoff returns instead of replacing the process: nil for a command that ran, so tests
+// can check what a session would have been handed to
+func (r *Replayer) Handoff(name string, args []string, env []string) error {
+	call, err := r.replay(KindHandoff, name, args)
+	if err != nil {
+		return err
+	}
+	return call.err()
+}
+
+// replay checks a command against the next recorded one and returns it, expanded
+func (r *Replayer) replay(kind, name string, args []string) (Call, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	got := strings.Join(redact.New(nil).Args(args), " ")
+	if r.next >= len(r.cassette.Calls) {
+		return Call{}, r.mismatch("unexpected command %s %s", name, got)
+	}
+	call := r.cassette.Calls[r.next]
+	program := call.Program
+	if program == "" {
+		program = r.cassette.Runtime
+	}
+	want := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		want[i] = r.expand(arg)
+	}
+	if kind != call.Kind || filepath.Base(name) != program || got != strings.Join(want, " ") {
+		return Call{}, r.mismatch("command %d is %s %s%s, recorded %s %s%s", r.next+1,
+			name, got, kindNote(kind), program, strings.Join(want, " "), kindNote(call.Kind))
+	}
+	r.next++
+	call.Output = r.expand(call.Output)
+	return call, nil
+}
+
+// kindNote describes how a command was run, for mismatch messages
+func kindNote(kind string) string {
+	if kind == "" {
+		return ""
+	}
+	return " (" + kind + ")"
+}
+
+// err returns the recorded outcome of a call as an error
+func (c Call) err() error {
+	switch {
+	case c.Error != "":
+		return errors.New(c.Error)
+	case c.Exit != 0:
+		return &ExitError{Code: c.Exit}
+	}
+	return nil
+}
+
+// mismatch notes a command that doesn't follow the fixture, returning it as its error
+func (r *Replayer) mismatch(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	r.mismatches = append(r.mismatches, msg)
+	return fmt.Errorf("replay: %s", msg)
+}
+
+func (r *Replayer) expand(s string) string {
+	for name, value := range r.vars {
+		s = strings.ReplaceAll(s, "${"+name+"}", value)
+	}
+	return s
+}
+
+// Verify reports whether every command followed the fixture and every recorded one ran
+// Callers often ignore a command's error, so a mismatch may only show up here.
+func (r *Replayer) Verify() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.mismatches) > 0 {
+		return fmt.Errorf("replay: %s", strings.Join(r.mismatches, "; "))
+	}
+	if remaining := len(r.cassette.Calls) - r.next; remaining > 0 {
+		return fmt.Errorf("replay: %d recorded command(s) never ran, starting with %s %s",
+			remaining, r.cassette.Runtime, strings.Join(r.cassette.Calls[r.next].Args, " "))
+	}
+	return nil
+}
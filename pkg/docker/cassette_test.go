@@ -0,0 +1,167 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scripted answers commands from a map of joined args to output
+type scripted map[string]string
+
+func (s scripted) Command(ctx context.Context, name string, args []string) *exec.Cmd {
+	return exec.CommandContext(ctx, "true")
+}
+
+func (s scripted) Handoff(name string, args []string, env []string) error {
+	return nil
+}
+
+func (s scripted) Exec(runtime string, args []string) (string, error) {
+	output, ok := s[strings.Join(args, " ")]
+	if !ok {
+		return "Error: No such object\n", errors.New("exit status 1")
+	}
+	return output, nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	runtime := scripted{
+		"inspect --format {{.State.Status}} app":                                               "running\n",
+		"run -e ANTHROPIC_API_KEY=sk-secret-value -v " + home + "/.claude:/home/u/.claude app": "abc123\n",
+	}
+	recorded := NewClientWithExecutor("docker", NewRecorder(path, "docker", runtime), false)
+	recorded.Run("inspect", "--format", "{{.State.Status}}", "app")
+	recorded.Run("run", "-e", "ANTHROPIC_API_KEY=sk-secret-value", "-v", home+"/.claude:/home/u/.claude", "app")
+	// A second client in the same process adds to the recording
+	NewClientWithExecutor("docker", NewRecorder(path, "docker", runtime), false).Run("inspect", "gone")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-secret-value") || strings.Contains(string(data), home) {
+		t.Errorf("fixture keeps secrets or the home directory:\n%s", data)
+	}
+
+	// Replayed in another home, the same commands get the recorded answers
+	otherHome := t.TempDir()
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer.Set("HOME", otherHome)
+	client := replayer.Client()
+	if client.Command() != "docker" {
+		t.Errorf("Command() = %s", client.Command())
+	}
+	if output, err := client.Run("inspect", "--format", "{{.State.Status}}", "app"); err != nil || output != "running\n" {
+		t.Errorf("Run(inspect) = %q, %v", output, err)
+	}
+	if output, err := client.Run("run", "-e", "ANTHROPIC_API_KEY=other-secret", "-v", otherHome+"/.claude:/home/u/.claude", "app"); err != nil || output != "abc123\n" {
+		t.Errorf("Run(run) = %q, %v", output, err)
+	}
+	if _, err := client.Run("inspect", "gone"); err == nil {
+		t.Error("replayed failure succeeded")
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	cassette := &Cassette{Runtime: "podman", Calls: []Call{
+		{Args: []string{"stop", "app"}},
+		{Args: []string{"rm", "-f", "app"}, Output: "in use\n", Exit: 2},
+	}}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := replayer.Client()
+
+	if _, err := client.Run("stop", "app"); err != nil {
+		t.Fatalf("Run(stop) error = %v", err)
+	}
+	if err := replayer.Verify(); err == nil || !strings.Contains(err.Error(), "never ran") {
+		t.Errorf("Verify() = %v; want the unreplayed rm reported", err)
+	}
+
+	_, err = client.Run("rm", "-f", "app")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+		t.Errorf("Run(rm) error = %v; want exit status 2", err)
+	}
+	if _, err := client.Run("rm", "-f", "app"); err == nil {
+		t.Error("Run() past the end of the fixture succeeded")
+	}
+	if err := replayer.Verify(); err == nil || !strings.Contains(err.Error(), "unexpected command podman rm -f app") {
+		t.Errorf("Verify() = %v", err)
+	}
+}
+
+func TestRecordAndReplayStreamsAndHandoffs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	recorder := NewRecorder(path, "docker", scripted{})
+	recorded := NewClientWithExecutor("docker", recorder, false)
+	_ = recorded.Stream("exec", "-i", "app", "tar", "-xf", "-").Run()
+	_ = recorder.Handoff("tmux", []string{"attach-session", "-t", "=app"}, nil)
+	_ = recorded.Handoff("exec", "-it", "app", "bash")
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Call{
+		{Kind: KindStream, Args: []string{"exec", "-i", "app", "tar", "-xf", "-"}},
+		{Kind: KindHandoff, Program: "tmux", Args: []string{"attach-session", "-t", "=app"}},
+		{Kind: KindHandoff, Args: []string{"exec", "-it", "app", "bash"}},
+	}
+	if len(cassette.Calls) != len(want) {
+		t.Fatalf("recorded %+v, want %+v", cassette.Calls, want)
+	}
+	for i := range want {
+		got := cassette.Calls[i]
+		if got.Kind != want[i].Kind || got.Program != want[i].Program || strings.Join(got.Args, " ") != strings.Join(want[i].Args, " ") {
+			t.Errorf("call %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	// A replayed stream prints the fixture's output; a replayed handoff returns
+	cassette.Calls[0].Output = "extracted\n"
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := replayer.Client()
+	if output, err := client.Stream("exec", "-i", "app", "tar", "-xf", "-").Output(); err != nil || string(output) != "extracted\n" {
+		t.Errorf("replayed stream = %q, %v", output, err)
+	}
+	if err := client.Executor().Handoff("tmux", []string{"attach-session", "-t", "=app"}, nil); err != nil {
+		t.Errorf("replayed tmux handoff error = %v", err)
+	}
+	// Recorded as a handoff, so running it any other way is off script
+	if _, err := client.Run("exec", "-it", "app", "bash"); err == nil {
+		t.Error("Run() of a recorded handoff succeeded")
+	}
+	if err := replayer.Verify(); err == nil || !strings.Contains(err.Error(), "(handoff)") {
+		t.Errorf("Verify() = %v; want the mismatched kind reported", err)
+	}
+}
@@ -1,15 +1,19 @@
 package docker
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+
+	"github.com/obra/packnplay/pkg/redact"
 )
 
 // Client handles Docker CLI interactions
 type Client struct {
 	cmd     string
 	verbose bool
+	run     Executor // runs the commands; nil runs the real CLI
 }
 
 // NewClient creates a new Docker client
@@ -34,9 +38,16 @@ func NewClientWithRuntime(preferredRuntime string, verbose bool) (*Client, error
 		return nil, err
 	}
 	client.cmd = cmd
+	client.run = DefaultExecutor(cmd)
 	return client, nil
 }
 
+// NewClientWithExecutor creates a client whose commands are run by executor rather than
+// the runtime's CLI, e.g. a Replayer in tests. The runtime isn't looked up in PATH.
+func NewClientWithExecutor(runtime string, executor Executor, verbose bool) *Client {
+	return &Client{cmd: runtime, verbose: verbose, run: executor}
+}
+
 // UseSpecificRuntime uses a specific container runtime
 func (c *Client) UseSpecificRuntime(runtime string) (string, error) {
 	if _, err := exec.LookPath(runtime); err != nil {
@@ -74,19 +85,51 @@ func (c *Client) Run(args ...string) (string, error) {
 		args = c.translateToAppleContainer(args)
 	}
 
-	cmd := exec.Command(c.cmd, args...)
-
+	// Verbose output is often pasted into bug reports; never echo env values or tokens
+	redactor := redact.New(nil)
 	if c.verbose {
-		fmt.Fprintf(os.Stderr, "+ %s %v\n", c.cmd, args)
+		fmt.Fprintf(os.Stderr, "+ %s %v\n", c.cmd, redactor.Args(args))
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := c.Executor().Exec(c.cmd, args)
 
 	if c.verbose && len(output) > 0 {
-		fmt.Fprintf(os.Stderr, "%s\n", output)
+		fmt.Fprintf(os.Stderr, "%s\n", redactor.Redact(output))
 	}
 
-	return string(output), err
+	return output, err
+}
+
+// Executor returns what runs the client's commands, for host programs that belong to a
+// session, such as the tmux a paired session is handed to
+func (c *Client) Executor() Executor {
+	if c.run == nil {
+		return cliExecutor{}
+	}
+	return c.run
+}
+
+// Stream returns a runtime command whose input and output the caller connects, e.g. a tar
+// piped into a container
+func (c *Client) Stream(args ...string) *exec.Cmd {
+	return c.StreamContext(context.Background(), args...)
+}
+
+// StreamContext is Stream for a command that's killed once ctx is done
+func (c *Client) StreamContext(ctx context.Context, args ...string) *exec.Cmd {
+	if c.verbose {
+		fmt.Fprintf(os.Stderr, "+ %s %v\n", c.cmd, redact.New(nil).Args(args))
+	}
+	return c.Executor().Command(ctx, c.cmd, args)
+}
+
+// Handoff replaces packnplay with a runtime command, e.g. the docker exec of a session; it
+// only returns if the command can't be started
+func (c *Client) Handoff(args ...string) error {
+	if c.verbose {
+		fmt.Fprintf(os.Stderr, "+ %s %v\n", c.cmd, redact.New(nil).Args(args))
+	}
+	return c.Executor().Handoff(c.cmd, args, os.Environ())
 }
 
 // translateToAppleContainer translates Docker CLI args to Apple Container CLI
@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Docker Desktop for Mac file sharing implementations
+const (
+	FileSharingVirtioFS = "virtiofs"
+	FileSharingGRPCFUSE = "grpcfuse"
+	FileSharingOSXFS    = "osxfs"
+)
+
+// desktopSettingsFiles are Docker Desktop's settings files, newest format first
+var desktopSettingsFiles = []string{
+	"Library/Group Containers/group.com.docker/settings-store.json",
+	"Library/Group Containers/group.com.docker/settings.json",
+}
+
+// DetectFileSharing reports which bind mount implementation Docker Desktop for Mac uses
+// Returns "" when Docker Desktop's settings can't be found (e.g. Colima, OrbStack, Linux).
+func DetectFileSharing(homeDir string) string {
+	for _, rel := range desktopSettingsFiles {
+		data, err := os.ReadFile(filepath.Join(homeDir, rel))
+		if err != nil {
+			continue
+		}
+		if sharing := parseFileSharing(data); sharing != "" {
+			return sharing
+		}
+	}
+	return ""
+}
+
+// parseFileSharing reads the file sharing choice from Docker Desktop settings JSON
+// Key casing differs between settings.json and settings-store.json.
+func parseFileSharing(data []byte) string {
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return ""
+	}
+
+	enabled := func(keys ...string) (bool, bool) {
+		for _, key := range keys {
+			if value, ok := settings[key].(bool); ok {
+				return value, true
+			}
+		}
+		return false, false
+	}
+
+	if virtiofs, ok := enabled("useVirtualizationFrameworkVirtioFS", "UseVirtualizationFrameworkVirtioFS"); ok && virtiofs {
+		return FileSharingVirtioFS
+	}
+	if grpcfuse, ok := enabled("useGrpcfuse", "UseGrpcfuse"); ok {
+		if grpcfuse {
+			return FileSharingGRPCFUSE
+		}
+		return FileSharingOSXFS
+	}
+	if _, ok := enabled("useVirtualizationFrameworkVirtioFS", "UseVirtualizationFrameworkVirtioFS"); ok {
+		// VirtioFS explicitly off and no gRPC-FUSE key: gRPC-FUSE is the default
+		return FileSharingGRPCFUSE
+	}
+	return ""
+}
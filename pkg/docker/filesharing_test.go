@@ -0,0 +1,49 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileSharing(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		want     string
+	}{
+		{"virtiofs", `{"useVirtualizationFrameworkVirtioFS": true, "useGrpcfuse": true}`, FileSharingVirtioFS},
+		{"settings-store casing", `{"UseVirtualizationFrameworkVirtioFS": true}`, FileSharingVirtioFS},
+		{"grpcfuse", `{"useVirtualizationFrameworkVirtioFS": false, "useGrpcfuse": true}`, FileSharingGRPCFUSE},
+		{"osxfs", `{"useGrpcfuse": false}`, FileSharingOSXFS},
+		{"virtiofs off defaults to grpcfuse", `{"UseVirtualizationFrameworkVirtioFS": false}`, FileSharingGRPCFUSE},
+		{"unknown", `{"other": 1}`, ""},
+		{"invalid", `not json`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseFileSharing([]byte(tt.settings)); got != tt.want {
+				t.Errorf("parseFileSharing() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFileSharing(t *testing.T) {
+	homeDir := t.TempDir()
+	if got := DetectFileSharing(homeDir); got != "" {
+		t.Errorf("DetectFileSharing() without Docker Desktop = %q, want empty", got)
+	}
+
+	settingsPath := filepath.Join(homeDir, desktopSettingsFiles[1])
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(settingsPath, []byte(`{"useGrpcfuse": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := DetectFileSharing(homeDir); got != FileSharingGRPCFUSE {
+		t.Errorf("DetectFileSharing() = %q, want %q", got, FileSharingGRPCFUSE)
+	}
+}
@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// Backoff controls how RunWithRetry retries transient failures
+type Backoff struct {
+	Attempts    int           // total attempts, including the first
+	Initial     time.Duration // delay before the first retry
+	Max         time.Duration // delay cap; delays double up to this
+	BeforeRetry func()        // optional cleanup before each retry, e.g. removing a half-created container
+}
+
+// DefaultBackoff rides out registry hiccups and VPN reconnects (about half a minute in total)
+var DefaultBackoff = Backoff{Attempts: 4, Initial: 2 * time.Second, Max: 15 * time.Second}
+
+// sleep is replaced in tests
+var sleep = time.Sleep
+
+// transientMarkers are output fragments of failures worth retrying: network and DNS
+// errors, registry rate limits and overload, and a daemon that is restarting
+var transientMarkers = []string{
+	"tls handshake timeout",
+	"i/o timeout",
+	"connection reset by peer",
+	"connection refused",
+	"broken pipe",
+	"unexpected eof",
+	"no such host",
+	"temporary failure in name resolution",
+	"server misbehaving",
+	"network is unreachable",
+	"context deadline exceeded",
+	"request canceled while waiting for connection",
+	"toomanyrequests",
+	"too many requests",
+	"429 too many requests",
+	"500 internal server error",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+	"cannot connect to the docker daemon",
+	"failed to set up container networking",
+	"error during connect",
+}
+
+// fatalMarkers are failures that retrying can never fix, even if they also mention the network
+var fatalMarkers = []string{
+	"manifest unknown",
+	"not found: manifest",
+	"repository does not exist",
+	"pull access denied",
+	"unauthorized",
+	"authentication required",
+	"invalid reference format",
+	"no space left on device",
+}
+
+// IsTransient reports whether a failed command's output indicates a retryable failure
+func IsTransient(output string) bool {
+	lower := strings.ToLower(output)
+	for _, marker := range fatalMarkers {
+		if strings.Contains(lower, marker) {
+			return false
+		}
+	}
+	for _, marker := range transientMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunWithRetry runs a command, retrying with exponential backoff while it fails transiently
+// Use it for operations that touch the network (pulls, builds, volume and network creation)
+// and are safe to repeat. Fatal failures return immediately.
+func (c *Client) RunWithRetry(backoff Backoff, args ...string) (string, error) {
+	delay := backoff.Initial
+	for attempt := 1; ; attempt++ {
+		output, err := c.Run(args...)
+		if err == nil || attempt >= backoff.Attempts || !IsTransient(output) {
+			return output, err
+		}
+
+		// Jitter keeps parallel sessions from retrying a struggling registry in lockstep
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/4+1))
+		fmt.Fprintf(os.Stderr, "Transient failure running %s %s (attempt %d/%d), retrying in %s: %s\n",
+			c.cmd, args[0], attempt, backoff.Attempts, wait.Round(100*time.Millisecond), lastLine(output))
+		sleep(wait)
+		if backoff.BeforeRetry != nil {
+			backoff.BeforeRetry()
+		}
+
+		delay *= 2
+		if delay > backoff.Max {
+			delay = backoff.Max
+		}
+	}
+}
+
+// lastLine returns the last non-empty line of output, which usually holds the error
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"Error response from daemon: Get \"https://ghcr.io/v2/\": net/http: TLS handshake timeout", true},
+		{"dial tcp: lookup registry-1.docker.io: Temporary failure in name resolution", true},
+		{"toomanyrequests: You have reached your pull rate limit", true},
+		{"received unexpected HTTP status: 503 Service Unavailable", true},
+		{"read tcp 10.0.0.2:51234->1.2.3.4:443: read: connection reset by peer", true},
+		{"Error response from daemon: manifest unknown", false},
+		{"pull access denied for private/image, repository does not exist or may require 'docker login'", false},
+		{"docker: invalid reference format.", false},
+		{"Error response from daemon: Conflict. The container name is already in use", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.output); got != tt.want {
+			t.Errorf("IsTransient(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+// fakeRuntime writes a script that fails transiently until its counter reaches succeedOn
+func fakeRuntime(t *testing.T, output string, succeedOn int) (*Client, string) {
+	t.Helper()
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "count")
+	script := `#!/bin/sh
+n=$(cat "` + counter + `" 2>/dev/null || echo 0)
+n=$((n+1))
+echo $n > "` + counter + `"
+if [ $n -ge ` + strconv.Itoa(succeedOn) + ` ]; then echo ok; exit 0; fi
+echo "` + output + `"
+exit 1
+`
+	path := filepath.Join(dir, "fake-docker")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Client{cmd: path}, counter
+}
+
+func attempts(t *testing.T, counter string) string {
+	data, _ := os.ReadFile(counter)
+	return strings.TrimSpace(string(data))
+}
+
+func TestRunWithRetry(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	backoff := Backoff{Attempts: 4, Initial: time.Second, Max: 3 * time.Second}
+
+	client, counter := fakeRuntime(t, "net/http: TLS handshake timeout", 3)
+	output, err := client.RunWithRetry(backoff, "pull", "image")
+	if err != nil || strings.TrimSpace(output) != "ok" {
+		t.Fatalf("RunWithRetry() = %q, %v; want success on third attempt", output, err)
+	}
+	if got := attempts(t, counter); got != "3" {
+		t.Errorf("attempts = %s, want 3", got)
+	}
+	if len(slept) != 2 || slept[0] < time.Second || slept[1] < 2*time.Second {
+		t.Errorf("backoff delays = %v, want ~1s then ~2s", slept)
+	}
+
+	// Fatal errors are not retried
+	client, counter = fakeRuntime(t, "manifest unknown", 9)
+	if _, err := client.RunWithRetry(backoff, "pull", "image"); err == nil {
+		t.Error("RunWithRetry() should fail")
+	}
+	if got := attempts(t, counter); got != "1" {
+		t.Errorf("fatal failure attempts = %s, want 1", got)
+	}
+
+	// Transient errors give up after Attempts
+	client, counter = fakeRuntime(t, "i/o timeout", 9)
+	if _, err := client.RunWithRetry(backoff, "pull", "image"); err == nil {
+		t.Error("RunWithRetry() should fail after exhausting attempts")
+	}
+	if got := attempts(t, counter); got != "4" {
+		t.Errorf("exhausted attempts = %s, want 4", got)
+	}
+}
+
+func TestRunWithRetryBeforeRetry(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	cleanups := 0
+	backoff := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond, BeforeRetry: func() { cleanups++ }}
+
+	client, _ := fakeRuntime(t, "failed to set up container networking: i/o timeout", 3)
+	if _, err := client.RunWithRetry(backoff, "run", "-d", "image"); err != nil {
+		t.Fatalf("RunWithRetry() error = %v", err)
+	}
+	if cleanups != 2 {
+		t.Errorf("BeforeRetry ran %d times, want 2", cleanups)
+	}
+}
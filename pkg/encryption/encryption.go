@@ -0,0 +1,198 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+// Extension marks files encrypted with the storage key
+const Extension = ".age"
+
+// keySecret names the storage key in the OS keychain
+const keySecret = "storage-key"
+
+// legacyKeychainService names the macOS keychain item older versions kept the storage key in
+const legacyKeychainService = "packnplay-storage-key"
+
+// ageHeader is the first line of every age-encrypted file
+const ageHeader = "age-encryption.org/v1"
+
+// GetKeyPath returns the storage key file used where no OS keychain is available
+func GetKeyPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, _ := os.UserHomeDir()
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "packnplay", "storage.key")
+}
+
+// LoadIdentity returns the local storage key, generating it on first use when create is set
+// It lives in the OS keychain where packnplay keeps its secrets (see pkg/secrets), and
+// otherwise in a 0600 file under the config dir.
+func LoadIdentity(create bool) (*age.X25519Identity, error) {
+	if secrets.Store() == secrets.StoreFile {
+		return loadFileIdentity(GetKeyPath(), create)
+	}
+	return loadKeychainIdentity(create)
+}
+
+// loadFileIdentity reads an age identity from path, generating one if allowed
+func loadFileIdentity(path string, create bool) (*age.X25519Identity, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parseIdentity(string(data))
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read storage key: %w", err)
+	}
+	if !create {
+		return nil, fmt.Errorf("no storage key at %s", path)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate storage key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key dir: %w", err)
+	}
+
+	// O_EXCL so two concurrent first runs can't each write a different key
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		return loadFileIdentity(path, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage key: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "# packnplay storage key, public key: %s\n%s\n", identity.Recipient(), identity); err != nil {
+		return nil, fmt.Errorf("failed to write storage key: %w", err)
+	}
+	return identity, nil
+}
+
+// loadKeychainIdentity reads the key from the OS keychain, generating one if allowed
+func loadKeychainIdentity(create bool) (*age.X25519Identity, error) {
+	stored, err := secrets.Get(keySecret)
+	if err != nil {
+		return nil, err
+	}
+	if stored != "" {
+		return parseIdentity(stored)
+	}
+	// A key made by an older version stays in use, so what it encrypted still decrypts
+	if identity, ok := loadLegacyIdentity(); ok {
+		return identity, nil
+	}
+	if !create {
+		return nil, fmt.Errorf("no storage key in the %s store", secrets.Store())
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate storage key: %w", err)
+	}
+	if err := secrets.Set(keySecret, identity.String()); err != nil {
+		return nil, fmt.Errorf("failed to store storage key: %w", err)
+	}
+	return identity, nil
+}
+
+// loadLegacyIdentity reads a storage key from where older versions kept it: a keychain item of
+// its own on macOS, the key file elsewhere
+func loadLegacyIdentity() (*age.X25519Identity, bool) {
+	if runtime.GOOS == "darwin" {
+		output, err := exec.Command("security", "find-generic-password", "-s", legacyKeychainService, "-w").Output()
+		if err != nil {
+			return nil, false
+		}
+		identity, err := parseIdentity(string(output))
+		return identity, err == nil
+	}
+	identity, err := loadFileIdentity(GetKeyPath(), false)
+	return identity, err == nil
+}
+
+// parseIdentity parses a single X25519 identity, ignoring comment lines
+func parseIdentity(data string) (*age.X25519Identity, error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := age.ParseX25519Identity(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse storage key: %w", err)
+		}
+		return identity, nil
+	}
+	return nil, fmt.Errorf("storage key is empty")
+}
+
+// ParseRecipients parses age public keys (age1...) given on the command line
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		recipient, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient '%s': %w", key, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// LoadIdentityFile reads identities from an age key file, e.g. one copied from another machine
+func LoadIdentityFile(path string) ([]age.Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+	return identities, nil
+}
+
+// IsEncrypted reports whether r starts with an age header, without consuming it
+func IsEncrypted(r *bufio.Reader) bool {
+	header, _ := r.Peek(len(ageHeader))
+	return string(header) == ageHeader
+}
+
+// NewWriter encrypts everything written to w for the given recipients
+// Close must be called to flush the final chunk.
+func NewWriter(w io.Writer, recipients []age.Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients to encrypt for")
+	}
+	encrypted, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start encryption: %w", err)
+	}
+	return encrypted, nil
+}
+
+// NewReader decrypts r with the first identity that matches
+func NewReader(r io.Reader, identities []age.Identity) (io.Reader, error) {
+	decrypted, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (is this your storage key?): %w", err)
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,86 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestLoadFileIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "packnplay", "storage.key")
+
+	if _, err := loadFileIdentity(path, false); err == nil {
+		t.Error("loadFileIdentity(create=false) should fail when no key exists")
+	}
+
+	created, err := loadFileIdentity(path, true)
+	if err != nil {
+		t.Fatalf("loadFileIdentity(create=true) error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := loadFileIdentity(path, true)
+	if err != nil {
+		t.Fatalf("loadFileIdentity() reload error = %v", err)
+	}
+	if loaded.String() != created.String() {
+		t.Error("reloading should return the same key, not generate a new one")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []age.Recipient{identity.Recipient()})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	w.Write([]byte("proprietary code"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(&buf)
+	if !IsEncrypted(br) {
+		t.Fatal("IsEncrypted() = false for age output")
+	}
+
+	r, err := NewReader(br, []age.Identity{identity})
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	plain, _ := io.ReadAll(r)
+	if string(plain) != "proprietary code" {
+		t.Errorf("decrypted = %q", plain)
+	}
+
+	if IsEncrypted(bufio.NewReader(bytes.NewReader([]byte("plain text")))) {
+		t.Error("IsEncrypted() = true for plain text")
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	identity, _ := age.GenerateX25519Identity()
+	if _, err := ParseRecipients([]string{identity.Recipient().String()}); err != nil {
+		t.Errorf("ParseRecipients(valid) error = %v", err)
+	}
+	if _, err := ParseRecipients([]string{"not-a-key"}); err == nil {
+		t.Error("ParseRecipients(invalid) should fail")
+	}
+}
@@ -0,0 +1,185 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"filippo.io/age"
+)
+
+// sealedPrefix starts a stored line encrypted with the storage key
+const sealedPrefix = "age:"
+
+var (
+	storageMu        sync.Mutex
+	storageEncrypted bool
+	storageIdentity  *age.X25519Identity
+)
+
+// ConfigureStorage sets whether packnplay encrypts the data it stores with the storage key,
+// from encrypt_storage. It applies to every store for the rest of the process.
+func ConfigureStorage(encrypt bool) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	storageEncrypted = encrypt
+}
+
+// StorageEncrypted reports whether stored data is encrypted
+func StorageEncrypted() bool {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	return storageEncrypted
+}
+
+// storageKey returns the storage key, loading it once per process since the keychain may prompt
+func storageKey(create bool) (*age.X25519Identity, error) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	if storageIdentity != nil {
+		return storageIdentity, nil
+	}
+	identity, err := LoadIdentity(create)
+	if err != nil {
+		return nil, err
+	}
+	storageIdentity = identity
+	return identity, nil
+}
+
+// SealLine returns a line of a stored log as it goes on disk: encrypted with the storage key
+// onto a single line when storage is encrypted, unchanged otherwise. Each line is sealed on
+// its own, so logs can still be appended to by several processes and read while they grow.
+func SealLine(line []byte) ([]byte, error) {
+	if !StorageEncrypted() {
+		return line, nil
+	}
+	identity, err := storageKey(true)
+	if err != nil {
+		return nil, err
+	}
+	var sealed bytes.Buffer
+	w, err := NewWriter(&sealed, []age.Recipient{identity.Recipient()})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(line); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return []byte(sealedPrefix + base64.StdEncoding.EncodeToString(sealed.Bytes())), nil
+}
+
+// OpenLine returns a stored line in the clear, decrypting it if it was sealed, so logs
+// written before encrypt_storage was turned on (or off) still read
+func OpenLine(line []byte) ([]byte, error) {
+	encoded, ok := bytes.CutPrefix(line, []byte(sealedPrefix))
+	if !ok {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed line: %w", err)
+	}
+	identity, err := storageKey(false)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(bytes.NewReader(sealed), []age.Identity{identity})
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// SealFile moves the file at path into a copy encrypted for the storage key at
+// path+Extension, appending to what was sealed there before. It's for data written where the
+// key isn't available, such as inside a container, and does nothing unless storage is encrypted.
+func SealFile(path string) error {
+	if !StorageEncrypted() {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	sealed, err := readSealed(path + Extension)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	identity, err := storageKey(true)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + Extension + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	w, err := NewWriter(file, []age.Recipient{identity.Recipient()})
+	if err == nil {
+		_, err = w.Write(append(sealed, data...))
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+Extension)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// ReadStored reads a file kept with SealFile: its sealed copy, followed by whatever has been
+// written to path since
+func ReadStored(path string) ([]byte, error) {
+	sealed, sealedErr := readSealed(path + Extension)
+	if sealedErr != nil && !os.IsNotExist(sealedErr) {
+		return nil, sealedErr
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && sealedErr == nil {
+		return sealed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(sealed, data...), nil
+}
+
+// readSealed decrypts a file encrypted for the storage key
+func readSealed(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	identity, err := storageKey(false)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(file, []age.Identity{identity})
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return data, nil
+}
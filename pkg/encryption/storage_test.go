@@ -0,0 +1,80 @@
+package encryption
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+// useStorage turns storage encryption on with a fresh key file for the test
+func useStorage(t *testing.T) {
+	t.Helper()
+	t.Setenv(secrets.StoreEnv, secrets.StoreFile)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	storageIdentity = nil
+	ConfigureStorage(true)
+	t.Cleanup(func() {
+		ConfigureStorage(false)
+		storageIdentity = nil
+	})
+}
+
+func TestSealLine(t *testing.T) {
+	line := []byte(`{"event":"integrity-violation"}`)
+
+	plain, err := SealLine(line)
+	if err != nil || !bytes.Equal(plain, line) {
+		t.Fatalf("SealLine() without encryption = %q, %v; want the line unchanged", plain, err)
+	}
+
+	useStorage(t)
+	sealed, err := SealLine(line)
+	if err != nil {
+		t.Fatalf("SealLine() error = %v", err)
+	}
+	if bytes.Contains(sealed, []byte("integrity")) || bytes.ContainsAny(sealed, "\n") {
+		t.Errorf("SealLine() = %q, want one encrypted line", sealed)
+	}
+
+	for _, stored := range [][]byte{sealed, line} {
+		opened, err := OpenLine(stored)
+		if err != nil {
+			t.Fatalf("OpenLine() error = %v", err)
+		}
+		if !bytes.Equal(opened, line) {
+			t.Errorf("OpenLine() = %q, want %q", opened, line)
+		}
+	}
+}
+
+func TestSealFileAppends(t *testing.T) {
+	useStorage(t)
+	path := filepath.Join(t.TempDir(), "commands.log")
+
+	for _, content := range []string{"first\x00", "second\x00"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := SealFile(path); err != nil {
+			t.Fatalf("SealFile() error = %v", err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("SealFile() left the plain file behind: %v", err)
+	}
+
+	// A restarted session writes to the plain file again
+	if err := os.WriteFile(path, []byte("third\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ReadStored(path)
+	if err != nil {
+		t.Fatalf("ReadStored() error = %v", err)
+	}
+	if string(data) != "first\x00second\x00third\x00" {
+		t.Errorf("ReadStored() = %q", data)
+	}
+}
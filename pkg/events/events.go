@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event types
+const (
+	TypeFile      = "file"      // a workspace file was created, modified or removed
+	TypeExec      = "exec"      // a command was started in the container with exec
+	TypeLifecycle = "lifecycle" // the container started, stopped, paused or resumed
+	TypeState     = "state"     // the session moved to another lifecycle state
+)
+
+// Event is one thing that happened in a session
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`             // container name
+	Path     string    `json:"path,omitempty"`      // file events: path in the container
+	HostPath string    `json:"host_path,omitempty"` // file events: path on the host
+	Op       string    `json:"op,omitempty"`        // file events: created, modified or removed
+	Command  string    `json:"command,omitempty"`   // exec events, when the runtime reports it
+	Action   string    `json:"action,omitempty"`    // lifecycle events: start, die, pause, unpause, ...
+	State    string    `json:"state,omitempty"`     // state events: the new state
+}
+
+// WriteSSE writes an event as a server-sent event, named after its type
+func WriteSSE(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// ParseRuntimeEvent turns a `docker events --format '{{json .}}'` line (or podman's) into
+// an event; ok is false for events dashboards don't need
+func ParseRuntimeEvent(line string) (Event, bool) {
+	var raw struct {
+		Action   string `json:"Action"` // docker
+		Status   string `json:"Status"` // podman
+		Name     string `json:"Name"`   // podman
+		TimeNano int64  `json:"timeNano"`
+		Actor    struct {
+			Attributes map[string]string `json:"Attributes"`
+		} `json:"Actor"`
+	}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return Event{}, false
+	}
+	action := raw.Action
+	if action == "" {
+		action = raw.Status
+	}
+	name := raw.Actor.Attributes["name"]
+	if name == "" {
+		name = raw.Name
+	}
+	when := time.Now().UTC()
+	if raw.TimeNano != 0 {
+		when = time.Unix(0, raw.TimeNano).UTC()
+	}
+
+	// docker reports "exec_start: <command>"; podman just "exec"
+	verb, command, _ := strings.Cut(action, ": ")
+	switch verb {
+	case "exec_start", "exec":
+		return Event{Type: TypeExec, Time: when, Session: name, Command: strings.TrimSpace(command)}, true
+	case "start", "restart", "die", "stop", "pause", "unpause", "kill", "oom", "destroy":
+		return Event{Type: TypeLifecycle, Time: when, Session: name, Action: verb}, true
+	}
+	return Event{}, false
+}
+
+// skippedDirs are never watched: git's own churn, and dependency trees too big to watch
+var skippedDirs = map[string]bool{".git": true, "node_modules": true}
+
+// fileDebounce folds the writes of one save into a single event
+const fileDebounce = 200 * time.Millisecond
+
+// WatchWorkspace reports file changes under hostRoot, the host directory mounted at
+// containerRoot, until ctx is done. New directories are watched as they appear.
+func WatchWorkspace(ctx context.Context, session, hostRoot, containerRoot string, emit func(Event)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	addTree := func(root string) {
+		_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if p != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			_ = watcher.Add(p)
+			return nil
+		})
+	}
+	addTree(hostRoot)
+
+	// Changes waiting out the debounce, by host path
+	type pendingChange struct {
+		timer *time.Timer
+		op    string
+	}
+	var mu sync.Mutex
+	pending := make(map[string]*pendingChange)
+	for {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			for _, change := range pending {
+				change.timer.Stop()
+			}
+			mu.Unlock()
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("workspace watch failed: %w", err)
+		case change, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			op := fileOp(change.Op)
+			if op == "" {
+				continue
+			}
+			rel, err := filepath.Rel(hostRoot, change.Name)
+			if err != nil || rel == "." || skipped(rel) {
+				continue
+			}
+			if op == "created" {
+				if info, err := os.Stat(change.Name); err == nil && info.IsDir() {
+					addTree(change.Name)
+				}
+			}
+
+			event := Event{
+				Type:     TypeFile,
+				Session:  session,
+				Path:     path.Join(containerRoot, filepath.ToSlash(rel)),
+				HostPath: change.Name,
+				Op:       op,
+			}
+			mu.Lock()
+			if earlier, ok := pending[change.Name]; ok {
+				earlier.timer.Stop()
+				// A file created and then written is still news of a creation
+				if op == "modified" {
+					event.Op = earlier.op
+				}
+			}
+			name := change.Name
+			current := &pendingChange{op: event.Op}
+			current.timer = time.AfterFunc(fileDebounce, func() {
+				mu.Lock()
+				// A later change may have replaced this one after the timer fired
+				if pending[name] != current {
+					mu.Unlock()
+					return
+				}
+				delete(pending, name)
+				mu.Unlock()
+				event.Time = time.Now().UTC()
+				emit(event)
+			})
+			pending[name] = current
+			mu.Unlock()
+		}
+	}
+}
+
+// fileOp names a filesystem change; attribute changes aren't reported
+func fileOp(op fsnotify.Op) string {
+	switch {
+	case op&fsnotify.Create != 0:
+		return "created"
+	case op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		return "removed"
+	case op&fsnotify.Write != 0:
+		return "modified"
+	}
+	return ""
+}
+
+// skipped reports whether a workspace-relative path is inside a directory that isn't watched
+func skipped(rel string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if skippedDirs[part] {
+			return true
+		}
+	}
+	return false
+}
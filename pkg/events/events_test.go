@@ -0,0 +1,124 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRuntimeEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		ok   bool
+		want Event
+	}{
+		{
+			name: "docker exec",
+			line: `{"Type":"container","Action":"exec_start: bash -c make test","Actor":{"Attributes":{"name":"packnplay-app-main"}},"timeNano":1700000000000000000}`,
+			ok:   true,
+			want: Event{Type: TypeExec, Session: "packnplay-app-main", Command: "bash -c make test"},
+		},
+		{
+			name: "docker die",
+			line: `{"Type":"container","Action":"die","Actor":{"Attributes":{"name":"packnplay-app-main"}}}`,
+			ok:   true,
+			want: Event{Type: TypeLifecycle, Session: "packnplay-app-main", Action: "die"},
+		},
+		{
+			name: "podman exec",
+			line: `{"Name":"packnplay-app-main","Status":"exec","Type":"container"}`,
+			ok:   true,
+			want: Event{Type: TypeExec, Session: "packnplay-app-main"},
+		},
+		{
+			name: "exec_create isn't reported",
+			line: `{"Action":"exec_create: bash","Actor":{"Attributes":{"name":"x"}}}`,
+		},
+		{
+			name: "not json",
+			line: "exec_start",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRuntimeEvent(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			got.Time = time.Time{}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	var buf bytes.Buffer
+	event := Event{Type: TypeState, Session: "packnplay-app-main", State: "idle"}
+	if err := WriteSSE(&buf, event); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "event: state\ndata: {") || !strings.HasSuffix(got, "}\n\n") {
+		t.Errorf("not a server-sent event: %q", got)
+	}
+	if !strings.Contains(got, `"state":"idle"`) {
+		t.Errorf("event data missing state: %q", got)
+	}
+}
+
+func TestWatchWorkspace(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan Event, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchWorkspace(ctx, "packnplay-app-main", root, "/workspace", func(e Event) { received <- e })
+	}()
+	// Give the watcher time to register the tree
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(root, ".git", "index"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(root, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-received:
+		if e.Type != TypeFile || e.Path != "/workspace/main.go" || e.Op != "created" || e.Session != "packnplay-app-main" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for a new file")
+	}
+	select {
+	case e := <-received:
+		t.Errorf("create and write should be one event, also got %+v", e)
+	case <-time.After(3 * fileDebounce):
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchWorkspace: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package fsguard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// decideRequest is the body of a POST /decide
+type decideRequest struct {
+	Pattern  string   `json:"pattern"`
+	Decision Decision `json:"decision"`
+}
+
+// ServeHTTP serves the control API: GET /pending lists waiting writes and POST /decide
+// answers them
+func (g *Guard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/pending":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(g.Pending())
+	case r.Method == http.MethodPost && r.URL.Path == "/decide":
+		var req decideRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, `decide expects {"pattern": "...", "decision": "allow"}`, http.StatusBadRequest)
+			return
+		}
+		if err := g.Decide(req.Pattern, req.Decision); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Listen creates a session's control socket, readable only by the host user
+func Listen(container string) (net.Listener, error) {
+	path := SocketPath(container)
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to secure %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// client talks to a session's control socket
+func client(container string) *http.Client {
+	path := SocketPath(container)
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+// Pending returns the writes in a session waiting for an answer
+func Pending(container string) ([]Request, error) {
+	resp, err := client(container).Get("http://fsguard/pending")
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the write guard of %s: %w", container, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("write guard of %s: %s", container, bytes.TrimSpace(body))
+	}
+	var requests []Request
+	if err := json.NewDecoder(resp.Body).Decode(&requests); err != nil {
+		return nil, fmt.Errorf("failed to parse pending writes of %s: %w", container, err)
+	}
+	return requests, nil
+}
+
+// Decide answers a session's writes to a path, or to a directory with a trailing /
+func Decide(container, pattern string, decision Decision) error {
+	body, err := json.Marshal(decideRequest{Pattern: pattern, Decision: decision})
+	if err != nil {
+		return err
+	}
+	resp, err := client(container).Post("http://fsguard/decide", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to reach the write guard of %s: %w", container, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("write guard of %s: %s", container, bytes.TrimSpace(body))
+	}
+	return nil
+}
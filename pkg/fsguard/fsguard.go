@@ -0,0 +1,245 @@
+package fsguard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/writepolicy"
+)
+
+// PromptTimeout is how long a write waits for the host user before it's denied
+const PromptTimeout = 10 * time.Minute
+
+// Dir returns the host directory holding a prompt-mode session's mount point and control socket
+func Dir(container string) string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "fsguard", container)
+}
+
+// MountPoint returns where the guarded view of a session's workspace is mounted on the host
+func MountPoint(container string) string {
+	return filepath.Join(Dir(container), "mnt")
+}
+
+// SocketPath returns the control socket 'packnplay approve' talks to
+func SocketPath(container string) string {
+	return filepath.Join(Dir(container), "control.sock")
+}
+
+// Active reports whether a session's workspace is guarded by write prompts
+func Active(container string) bool {
+	_, err := os.Stat(Dir(container))
+	return err == nil
+}
+
+// Forget unmounts a session's guarded workspace, if it's still mounted, and drops its state
+// Only known entries are removed: while mounted, the mount point is the user's workspace.
+func Forget(container string) error {
+	if !Active(container) {
+		return nil
+	}
+	mountPoint := MountPoint(container)
+	_ = Unmount(mountPoint)
+	_ = os.Remove(SocketPath(container))
+	if err := os.Remove(mountPoint); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove write guard mount point %s: %w", mountPoint, err)
+	}
+	if err := os.Remove(Dir(container)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove write guard state: %w", err)
+	}
+	return nil
+}
+
+// Decision is the host user's answer to a write outside the allowlist
+type Decision string
+
+const (
+	Allow Decision = "allow"
+	Deny  Decision = "deny"
+)
+
+// Request is a write waiting for the host user
+type Request struct {
+	ID    uint64    `json:"id"`
+	Op    string    `json:"op"`   // write, create, mkdir, delete, rename, link, symlink or change
+	Path  string    `json:"path"` // relative to the workspace
+	PID   uint32    `json:"pid"`  // the writing process, as the host sees it
+	Since time.Time `json:"since"`
+}
+
+// pending is a Request and the channel its decision arrives on
+type pending struct {
+	Request
+	decided chan Decision
+}
+
+// Guard decides which writes to the workspace go ahead. The write policy's allow list passes
+// silently and its deny list, like the project config, is refused outright; anything else
+// waits for the host user. Answers apply to a path or a directory and hold for the session.
+type Guard struct {
+	policy  config.WritePolicy
+	timeout time.Duration
+	notify  func(Request) // called when a write starts waiting and none were before
+
+	mu       sync.Mutex
+	patterns map[string]Decision // path, or directory with a trailing /, -> answer
+	pending  map[uint64]*pending
+	nextID   uint64
+}
+
+// NewGuard returns a Guard for a write policy; notify, if set, is told when writes start waiting
+func NewGuard(policy config.WritePolicy, notify func(Request)) *Guard {
+	return &Guard{
+		policy:   policy,
+		timeout:  PromptTimeout,
+		notify:   notify,
+		patterns: make(map[string]Decision),
+		pending:  make(map[uint64]*pending),
+	}
+}
+
+// Check reports whether op may change path, asking the host user when neither the policy nor
+// an earlier answer decides. It returns nil, EACCES, or EINTR when cancel closes first.
+func (g *Guard) Check(op, path string, pid uint32, cancel <-chan struct{}) error {
+	if path == "" {
+		path = "."
+	}
+	if path == config.ProjectConfigFile || matchesAny(g.policy.Deny, path) {
+		return syscall.EACCES
+	}
+	if matchesAny(g.policy.Allow, path) {
+		return nil
+	}
+
+	g.mu.Lock()
+	if decision, ok := g.decided(path); ok {
+		g.mu.Unlock()
+		return errorFor(decision)
+	}
+	g.nextID++
+	p := &pending{
+		Request: Request{ID: g.nextID, Op: op, Path: path, PID: pid, Since: time.Now().UTC()},
+		decided: make(chan Decision, 1),
+	}
+	first := len(g.pending) == 0
+	g.pending[p.ID] = p
+	g.mu.Unlock()
+
+	if first && g.notify != nil {
+		g.notify(p.Request)
+	}
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case decision := <-p.decided:
+		return errorFor(decision)
+	case <-timer.C:
+		g.drop(p.ID)
+		return syscall.EACCES
+	case <-cancel:
+		g.drop(p.ID)
+		return syscall.EINTR
+	}
+}
+
+// decided returns the answer that covers path, the most specific one first; callers hold g.mu
+func (g *Guard) decided(path string) (Decision, bool) {
+	if decision, ok := g.patterns[path]; ok {
+		return decision, true
+	}
+	for dir := filepath.Dir(path); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if decision, ok := g.patterns[dir+"/"]; ok {
+			return decision, true
+		}
+	}
+	return "", false
+}
+
+// drop forgets a request nobody is waiting on anymore
+func (g *Guard) drop(id uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pending, id)
+}
+
+// Pending returns the writes waiting for the host user, oldest first
+func (g *Guard) Pending() []Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	requests := make([]Request, 0, len(g.pending))
+	for _, p := range g.pending {
+		requests = append(requests, p.Request)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	return requests
+}
+
+// Decide answers for a path, or for a directory and everything in it when pattern ends in /,
+// for the rest of the session. Every waiting write it covers goes ahead or fails at once.
+func (g *Guard) Decide(pattern string, decision Decision) error {
+	if decision != Allow && decision != Deny {
+		return fmt.Errorf("unknown decision '%s' (use allow or deny)", decision)
+	}
+	if err := ValidatePattern(pattern); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.patterns[pattern] = decision
+	for id, p := range g.pending {
+		if covers(pattern, p.Path) {
+			p.decided <- decision
+			delete(g.pending, id)
+		}
+	}
+	return nil
+}
+
+// ValidatePattern checks an answer's scope: a workspace-relative path, or a directory with a
+// trailing /
+func ValidatePattern(pattern string) error {
+	trimmed := strings.TrimSuffix(pattern, "/")
+	if trimmed == "" || strings.HasPrefix(pattern, "/") || filepath.Clean(trimmed) != trimmed || trimmed == ".." || strings.HasPrefix(trimmed, "../") {
+		return fmt.Errorf("invalid path '%s' (use a path relative to the workspace, with a trailing / for a directory)", pattern)
+	}
+	return nil
+}
+
+// covers reports whether an answer's scope includes path
+func covers(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return path == pattern
+}
+
+// matchesAny reports whether a workspace-relative path matches one of the write patterns
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if writepolicy.Match(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorFor turns an answer into the result of the write
+func errorFor(decision Decision) error {
+	if decision == Allow {
+		return nil
+	}
+	return syscall.EACCES
+}
@@ -0,0 +1,110 @@
+package fsguard
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// waitPending waits until n writes are waiting on the guard
+func waitPending(t *testing.T, g *Guard, n int) []Request {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if pending := g.Pending(); len(pending) == n {
+			return pending
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d pending writes, have %v", n, g.Pending())
+	return nil
+}
+
+func TestGuardPolicy(t *testing.T) {
+	g := NewGuard(config.WritePolicy{Allow: []string{"src/**"}, Deny: []string{"deploy/**"}}, nil)
+	if err := g.Check("write", "src/main.go", 1, nil); err != nil {
+		t.Errorf("allowed write = %v, want nil", err)
+	}
+	if err := g.Check("write", "deploy/prod.yaml", 1, nil); err != syscall.EACCES {
+		t.Errorf("denied write = %v, want EACCES", err)
+	}
+	if err := g.Check("write", config.ProjectConfigFile, 1, nil); err != syscall.EACCES {
+		t.Errorf("project config write = %v, want EACCES", err)
+	}
+}
+
+func TestGuardPrompts(t *testing.T) {
+	notified := make(chan Request, 4)
+	g := NewGuard(config.WritePolicy{}, func(r Request) { notified <- r })
+
+	result := make(chan error, 2)
+	go func() { result <- g.Check("create", "docs/a.md", 7, nil) }()
+	pending := waitPending(t, g, 1)
+	go func() { result <- g.Check("write", "docs/b.md", 7, nil) }()
+	pending = waitPending(t, g, 2)
+	if pending[0].Path != "docs/a.md" || pending[0].Op != "create" || pending[0].PID != 7 {
+		t.Errorf("pending[0] = %+v", pending[0])
+	}
+	if err := g.Decide("docs/", Allow); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-result; err != nil {
+			t.Errorf("write allowed by directory = %v", err)
+		}
+	}
+	if len(notified) != 1 {
+		t.Errorf("notified %d times, want once for the first waiting write", len(notified))
+	}
+	if err := g.Check("write", "docs/deep/c.md", 7, nil); err != nil {
+		t.Errorf("write under an allowed directory = %v, want it remembered", err)
+	}
+
+	go func() { result <- g.Check("delete", "README.md", 7, nil) }()
+	waitPending(t, g, 1)
+	if err := g.Decide("README.md", Deny); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != syscall.EACCES {
+		t.Errorf("denied write = %v, want EACCES", err)
+	}
+}
+
+func TestGuardTimeoutAndCancel(t *testing.T) {
+	g := NewGuard(config.WritePolicy{}, nil)
+	g.timeout = 20 * time.Millisecond
+	if err := g.Check("write", "a", 1, nil); err != syscall.EACCES {
+		t.Errorf("unanswered write = %v, want EACCES", err)
+	}
+
+	g.timeout = time.Minute
+	cancel := make(chan struct{})
+	result := make(chan error, 1)
+	go func() { result <- g.Check("write", "a", 1, cancel) }()
+	waitPending(t, g, 1)
+	close(cancel)
+	if err := <-result; err != syscall.EINTR {
+		t.Errorf("interrupted write = %v, want EINTR", err)
+	}
+	if pending := g.Pending(); len(pending) != 0 {
+		t.Errorf("interrupted write still pending: %v", pending)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, pattern := range []string{"a.txt", "docs/", "src/pkg/x.go"} {
+		if err := ValidatePattern(pattern); err != nil {
+			t.Errorf("ValidatePattern(%q) = %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"", "/", "/etc/passwd", "../x", "..", "a/../b", "./a"} {
+		if err := ValidatePattern(pattern); err == nil {
+			t.Errorf("ValidatePattern(%q) accepted", pattern)
+		}
+	}
+	if err := NewGuard(config.WritePolicy{}, nil).Decide("a", "maybe"); err == nil {
+		t.Error("Decide accepted an unknown decision")
+	}
+}
@@ -0,0 +1,457 @@
+package fsguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Kernel FUSE protocol, as in include/uapi/linux/fuse.h. Only what a passthrough
+// filesystem needs is spoken; anything else is answered with ENOSYS.
+const (
+	fuseMajor = 7
+	fuseMinor = 31
+
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opSetattr     = 4
+	opReadlink    = 5
+	opSymlink     = 6
+	opMknod       = 8
+	opMkdir       = 9
+	opUnlink      = 10
+	opRmdir       = 11
+	opRename      = 12
+	opLink        = 13
+	opOpen        = 14
+	opRead        = 15
+	opWrite       = 16
+	opStatfs      = 17
+	opRelease     = 18
+	opFsync       = 20
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opFsyncdir    = 30
+	opCreate      = 35
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+	opFallocate   = 43
+	opRename2     = 45
+
+	// init flags packnplay accepts from the kernel
+	initAsyncRead      = 1 << 0
+	initAtomicOTrunc   = 1 << 3
+	initBigWrites      = 1 << 5
+	initAutoInvalData  = 1 << 12
+	initParallelDirops = 1 << 18
+	initMaxPages       = 1 << 22
+
+	// setattr valid bits
+	setMode     = 1 << 0
+	setUID      = 1 << 1
+	setGID      = 1 << 2
+	setSize     = 1 << 3
+	setAtime    = 1 << 4
+	setMtime    = 1 << 5
+	setFh       = 1 << 6
+	setAtimeNow = 1 << 7
+	setMtimeNow = 1 << 8
+
+	maxWrite   = 128 << 10
+	bufferSize = maxWrite + 4096
+	rootNode   = 1
+)
+
+type inHeader struct {
+	Len     uint32
+	Opcode  uint32
+	Unique  uint64
+	NodeID  uint64
+	UID     uint32
+	GID     uint32
+	PID     uint32
+	Padding uint32
+}
+
+type outHeader struct {
+	Len    uint32
+	Error  int32
+	Unique uint64
+}
+
+type initIn struct {
+	Major        uint32
+	Minor        uint32
+	MaxReadahead uint32
+	Flags        uint32
+}
+
+type initOut struct {
+	Major               uint32
+	Minor               uint32
+	MaxReadahead        uint32
+	Flags               uint32
+	MaxBackground       uint16
+	CongestionThreshold uint16
+	MaxWrite            uint32
+	TimeGran            uint32
+	MaxPages            uint16
+	MapAlignment        uint16
+	Flags2              uint32
+	Unused              [7]uint32
+}
+
+type attr struct {
+	Ino       uint64
+	Size      uint64
+	Blocks    uint64
+	Atime     uint64
+	Mtime     uint64
+	Ctime     uint64
+	Atimensec uint32
+	Mtimensec uint32
+	Ctimensec uint32
+	Mode      uint32
+	Nlink     uint32
+	UID       uint32
+	GID       uint32
+	Rdev      uint32
+	Blksize   uint32
+	Flags     uint32
+}
+
+type entryOut struct {
+	NodeID         uint64
+	Generation     uint64
+	EntryValid     uint64
+	AttrValid      uint64
+	EntryValidNsec uint32
+	AttrValidNsec  uint32
+	Attr           attr
+}
+
+type attrOut struct {
+	AttrValid     uint64
+	AttrValidNsec uint32
+	Dummy         uint32
+	Attr          attr
+}
+
+type setattrIn struct {
+	Valid     uint32
+	Padding   uint32
+	Fh        uint64
+	Size      uint64
+	LockOwner uint64
+	Atime     uint64
+	Mtime     uint64
+	Ctime     uint64
+	Atimensec uint32
+	Mtimensec uint32
+	Ctimensec uint32
+	Mode      uint32
+	Unused4   uint32
+	UID       uint32
+	GID       uint32
+	Unused5   uint32
+}
+
+type mkdirIn struct {
+	Mode  uint32
+	Umask uint32
+}
+
+type mknodIn struct {
+	Mode    uint32
+	Rdev    uint32
+	Umask   uint32
+	Padding uint32
+}
+
+type renameIn struct {
+	Newdir uint64
+}
+
+type rename2In struct {
+	Newdir  uint64
+	Flags   uint32
+	Padding uint32
+}
+
+type linkIn struct {
+	Oldnodeid uint64
+}
+
+type openIn struct {
+	Flags     uint32
+	OpenFlags uint32
+}
+
+type createIn struct {
+	Flags     uint32
+	Mode      uint32
+	Umask     uint32
+	OpenFlags uint32
+}
+
+type openOut struct {
+	Fh        uint64
+	OpenFlags uint32
+	Padding   uint32
+}
+
+type readIn struct {
+	Fh        uint64
+	Offset    uint64
+	Size      uint32
+	ReadFlags uint32
+	LockOwner uint64
+	Flags     uint32
+	Padding   uint32
+}
+
+type writeIn struct {
+	Fh         uint64
+	Offset     uint64
+	Size       uint32
+	WriteFlags uint32
+	LockOwner  uint64
+	Flags      uint32
+	Padding    uint32
+}
+
+type writeOut struct {
+	Size    uint32
+	Padding uint32
+}
+
+type releaseIn struct {
+	Fh           uint64
+	Flags        uint32
+	ReleaseFlags uint32
+	LockOwner    uint64
+}
+
+type fsyncIn struct {
+	Fh         uint64
+	FsyncFlags uint32
+	Padding    uint32
+}
+
+type fallocateIn struct {
+	Fh      uint64
+	Offset  uint64
+	Length  uint64
+	Mode    uint32
+	Padding uint32
+}
+
+type interruptIn struct {
+	Unique uint64
+}
+
+type forgetIn struct {
+	Nlookup uint64
+}
+
+type batchForgetIn struct {
+	Count uint32
+	Dummy uint32
+}
+
+type forgetOne struct {
+	NodeID  uint64
+	Nlookup uint64
+}
+
+type kstatfs struct {
+	Blocks  uint64
+	Bfree   uint64
+	Bavail  uint64
+	Files   uint64
+	Ffree   uint64
+	Bsize   uint32
+	Namelen uint32
+	Frsize  uint32
+	Padding uint32
+	Spare   [6]uint32
+}
+
+// decode reads a fixed-size request struct off the front of data, returning the rest
+func decode(data []byte, v any) ([]byte, error) {
+	size := binary.Size(v)
+	if len(data) < size {
+		return nil, unix.EINVAL
+	}
+	if err := binary.Read(bytes.NewReader(data[:size]), binary.NativeEndian, v); err != nil {
+		return nil, unix.EINVAL
+	}
+	return data[size:], nil
+}
+
+// encode lays out reply structs back to back
+func encode(values ...any) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		if b, ok := v.([]byte); ok {
+			buf.Write(b)
+			continue
+		}
+		_ = binary.Write(&buf, binary.NativeEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// cString splits a NUL-terminated name off the front of data
+func cString(data []byte) (string, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, unix.EINVAL
+	}
+	return string(data[:end]), data[end+1:], nil
+}
+
+// Available reports whether this host can mount a guarded workspace, and if not, why
+func Available() error {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		return fmt.Errorf("write prompts need FUSE, and /dev/fuse is missing (load the fuse module)")
+	}
+	if os.Geteuid() != 0 {
+		_, err := fusermountPath()
+		return err
+	}
+	return nil
+}
+
+// mount mounts a FUSE filesystem at mountPoint and returns the /dev/fuse descriptor serving
+// it. As root it mounts directly; anyone else goes through fusermount, which needs
+// user_allow_other in /etc/fuse.conf. allow_other lets the container runtime, which runs as
+// another user, reach the mount; default_permissions has the kernel check file modes as it
+// would on a bind mount.
+func mount(mountPoint string) (int, error) {
+	if os.Geteuid() == 0 {
+		fd, err := unix.Open("/dev/fuse", unix.O_RDWR|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return -1, fmt.Errorf("failed to open /dev/fuse: %w", err)
+		}
+		options := fmt.Sprintf("fd=%d,rootmode=40000,user_id=0,group_id=0,allow_other,default_permissions", fd)
+		if err := unix.Mount("packnplay", mountPoint, "fuse.packnplay", unix.MS_NOSUID|unix.MS_NODEV, options); err != nil {
+			unix.Close(fd)
+			return -1, fmt.Errorf("failed to mount %s: %w", mountPoint, err)
+		}
+		return fd, nil
+	}
+	return fusermount(mountPoint)
+}
+
+// fusermount has the setuid fusermount helper mount mountPoint and pass back the descriptor
+func fusermount(mountPoint string) (int, error) {
+	helper, err := fusermountPath()
+	if err != nil {
+		return -1, err
+	}
+	pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create socket pair: %w", err)
+	}
+	ours, theirs := os.NewFile(uintptr(pair[0]), "fusermount-ours"), os.NewFile(uintptr(pair[1]), "fusermount-theirs")
+	defer ours.Close()
+
+	cmd := exec.Command(helper, "-o", "allow_other,default_permissions,nosuid,nodev,fsname=packnplay,subtype=packnplay", "--", mountPoint)
+	cmd.ExtraFiles = []*os.File{theirs} // fd 3
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	output, err := cmd.CombinedOutput()
+	theirs.Close()
+	if err != nil {
+		return -1, fmt.Errorf("%s failed to mount %s: %w\n%s", helper, mountPoint, err, output)
+	}
+
+	buf := make([]byte, 4)
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := unix.Recvmsg(int(ours.Fd()), buf, oob, 0)
+	if err != nil {
+		return -1, fmt.Errorf("failed to receive the FUSE descriptor: %w", err)
+	}
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) == 0 {
+		return -1, fmt.Errorf("fusermount passed no FUSE descriptor")
+	}
+	fds, err := unix.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) == 0 {
+		return -1, fmt.Errorf("fusermount passed no FUSE descriptor")
+	}
+	unix.CloseOnExec(fds[0])
+	return fds[0], nil
+}
+
+// fusermountPath finds fusermount3 or, on older systems, fusermount
+func fusermountPath() (string, error) {
+	for _, name := range []string{"fusermount3", "fusermount"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("write prompts need fusermount3 (the fuse3 package) to mount the workspace")
+}
+
+// Unmount detaches a guarded workspace; a busy mount goes once its last user leaves
+func Unmount(mountPoint string) error {
+	if os.Geteuid() == 0 {
+		if err := unix.Unmount(mountPoint, unix.MNT_DETACH); err != nil && err != unix.EINVAL && err != unix.ENOENT {
+			return fmt.Errorf("failed to unmount %s: %w", mountPoint, err)
+		}
+		return nil
+	}
+	helper, err := fusermountPath()
+	if err != nil {
+		return err
+	}
+	if !mounted(mountPoint) {
+		return nil
+	}
+	if output, err := exec.Command(helper, "-u", "-z", mountPoint).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unmount %s: %w\n%s", mountPoint, err, output)
+	}
+	return nil
+}
+
+// mounted reports whether something is mounted at path, going by /proc/self/mountinfo
+func mounted(path string) bool {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) > 4 && unescapeMountPath(string(fields[4])) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMountPath undoes mountinfo's octal escapes for spaces, tabs and the like
+func unescapeMountPath(path string) string {
+	var out []byte
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if n, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				out = append(out, byte(n))
+				i += 3
+				continue
+			}
+		}
+		out = append(out, path[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,183 @@
+package fsguard
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"golang.org/x/sys/unix"
+)
+
+// mountGuarded serves a guarded view of a temp workspace, skipping where FUSE can't mount
+func mountGuarded(t *testing.T, policy config.WritePolicy) (source, mnt string, g *Guard) {
+	t.Helper()
+	source = t.TempDir()
+	mnt = filepath.Join(t.TempDir(), "mnt")
+	g = NewGuard(policy, nil)
+	server, err := Mount(source, mnt, g)
+	if err != nil {
+		t.Skipf("FUSE unavailable: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve() }()
+	t.Cleanup(func() {
+		if err := Unmount(mnt); err != nil {
+			t.Errorf("Unmount() = %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Serve() = %v", err)
+		}
+	})
+	return source, mnt, g
+}
+
+// inMount runs a shell script in the mount from another process, as the container would; the
+// serving process can't use its own mount
+func inMount(mnt, script string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	cmd.Dir = mnt
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+func TestMountPassesAllowedWrites(t *testing.T) {
+	source, mnt, _ := mountGuarded(t, config.WritePolicy{Allow: []string{"src/**"}})
+	if err := os.MkdirAll(filepath.Join(source, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if output, err := inMount(mnt, "cat README.md"); err != nil || output != "hello" {
+		t.Fatalf("read through mount = %q, %v", output, err)
+	}
+	if output, err := inMount(mnt, "printf 'package main\\n' > src/main.go && mv src/main.go src/app.go && ls src"); err != nil || output != "app.go" {
+		t.Fatalf("allowed write and rename = %q, %v", output, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(source, "src", "app.go")); string(data) != "package main\n" {
+		t.Errorf("workspace has %q after an allowed write", data)
+	}
+
+	script := `set -e
+mkdir -p src/a/b
+dd if=/dev/zero of=src/a/b/big bs=64k count=20 2>/dev/null
+truncate -s 1000 src/a/b/big
+touch -d 2020-01-01 src/a/b/big
+chmod 600 src/a/b/big
+ln src/a/b/big src/hard
+ln -s a/b/big src/soft
+cat src/soft > /dev/null
+mv src/a src/c
+rm -r src/c
+ls src`
+	if output, err := inMount(mnt, script); err != nil || output != "app.go\nhard\nsoft" {
+		t.Fatalf("file operations through mount = %q, %v", output, err)
+	}
+	info, err := os.Stat(filepath.Join(source, "src", "hard"))
+	if err != nil || info.Size() != 1000 || info.Mode().Perm() != 0600 || info.ModTime().Year() != 2020 {
+		t.Errorf("hard link = %v, %v, want the truncated, chmodded, touched file", info, err)
+	}
+}
+
+func TestMountPromptsForOtherWrites(t *testing.T) {
+	source, mnt, g := mountGuarded(t, config.WritePolicy{Deny: []string{"deploy/**"}})
+	if err := os.MkdirAll(filepath.Join(source, "deploy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"README.md", "NOTES.md"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte("hello\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := inMount(mnt, "echo changed > README.md")
+		done <- result{output, err}
+	}()
+	pending := waitPending(t, g, 1)
+	if pending[0].Op != "write" || pending[0].Path != "README.md" || pending[0].PID == 0 {
+		t.Errorf("pending write = %+v", pending[0])
+	}
+	if err := g.Decide("README.md", Allow); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-done; r.err != nil {
+		t.Errorf("approved write = %q, %v", r.output, r.err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(source, "README.md")); string(data) != "changed\n" {
+		t.Errorf("workspace has %q after an approved write", data)
+	}
+
+	go func() {
+		output, err := inMount(mnt, "rm NOTES.md")
+		done <- result{output, err}
+	}()
+	if pending := waitPending(t, g, 1); pending[0].Op != "delete" {
+		t.Errorf("pending delete = %+v", pending[0])
+	}
+	if err := g.Decide("NOTES.md", Deny); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-done; r.err == nil || !strings.Contains(r.output, "Permission denied") {
+		t.Errorf("refused delete = %q, %v, want permission denied", r.output, r.err)
+	}
+	if _, err := os.Stat(filepath.Join(source, "NOTES.md")); err != nil {
+		t.Errorf("refused delete removed the file: %v", err)
+	}
+	if output, err := inMount(mnt, "rm README.md"); err != nil {
+		t.Errorf("delete of an approved path = %q, %v", output, err)
+	}
+
+	if output, err := inMount(mnt, "echo x > deploy/prod.yaml"); err == nil || !strings.Contains(output, "Permission denied") {
+		t.Errorf("write denied by policy = %q, %v, want permission denied", output, err)
+	}
+	if pending := g.Pending(); len(pending) != 0 {
+		t.Errorf("policy decisions prompted: %v", pending)
+	}
+}
+
+func TestServerStaysInWorkspace(t *testing.T) {
+	source, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(source, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(source, "link")); err != nil {
+		t.Fatal(err)
+	}
+	root, err := unix.Open(source, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(root)
+	s := &Server{root: root}
+
+	// the container resolves symlinks itself; the server never follows one on the host
+	for _, path := range []string{"escape/secret", "link", "../" + filepath.Base(outside) + "/secret"} {
+		if fd, err := s.openat(path, unix.O_WRONLY, 0); err == nil {
+			unix.Close(fd)
+			t.Errorf("openat(%q) left the workspace", path)
+		}
+	}
+	if _, err := s.lstat("escape/secret"); err == nil {
+		t.Error("lstat followed a symlinked directory")
+	}
+	if a, err := s.lstat("link"); err != nil || a.Mode&unix.S_IFMT != unix.S_IFLNK {
+		t.Errorf("lstat(link) = %o, %v, want the symlink itself", a.Mode, err)
+	}
+}
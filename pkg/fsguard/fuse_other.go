@@ -0,0 +1,31 @@
+//go:build !linux
+
+package fsguard
+
+import "errors"
+
+// errUnsupported is returned wherever write prompts need the Linux kernel's FUSE
+var errUnsupported = errors.New("write prompts need FUSE on a Linux host")
+
+// Server is only available on Linux
+type Server struct{}
+
+// Mount is only available on Linux
+func Mount(source, mountPoint string, guard *Guard) (*Server, error) {
+	return nil, errUnsupported
+}
+
+// Serve is only available on Linux
+func (s *Server) Serve() error {
+	return errUnsupported
+}
+
+// Unmount has nothing to do where nothing can be mounted
+func Unmount(mountPoint string) error {
+	return nil
+}
+
+// Available reports why write prompts can't work here
+func Available() error {
+	return errUnsupported
+}
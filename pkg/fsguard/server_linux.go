@@ -0,0 +1,1061 @@
+package fsguard
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// resolveBeneath keeps every host lookup inside the workspace: paths come from the kernel one
+// name at a time, so a symlink anywhere along one is never followed here. The container
+// resolves symlinks itself, through the mount.
+const resolveBeneath = unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS | unix.RESOLVE_NO_MAGICLINKS
+
+// Server passes a workspace through to a FUSE mount, asking its Guard before each change
+type Server struct {
+	fd    int // /dev/fuse
+	root  int // O_PATH descriptor of the workspace
+	guard *Guard
+	chown bool // hand new files to the writing user, as a bind mount would
+
+	mu         sync.Mutex
+	nodes      map[uint64]*node
+	byPath     map[string]uint64
+	nextNode   uint64
+	handles    map[uint64]*handle
+	nextHandle uint64
+	interrupts map[uint64]chan struct{}
+}
+
+// node is a path the kernel holds a reference to; path is "" once it's been deleted
+type node struct {
+	path    string
+	lookups uint64
+}
+
+// handle is an open file or directory
+type handle struct {
+	fd      int
+	mu      sync.Mutex
+	entries []dirEntry // directory listing, read when a readdir starts at offset 0
+}
+
+type dirEntry struct {
+	ino  uint64
+	typ  uint8
+	name string
+}
+
+// Mount mounts a guarded view of source at mountPoint; call Serve to answer it
+func Mount(source, mountPoint string, guard *Guard) (*Server, error) {
+	root, err := unix.Open(source, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace %s: %w", source, err)
+	}
+	if err := os.MkdirAll(mountPoint, 0700); err != nil {
+		unix.Close(root)
+		return nil, fmt.Errorf("failed to create mount point %s: %w", mountPoint, err)
+	}
+	fd, err := mount(mountPoint)
+	if err != nil {
+		unix.Close(root)
+		return nil, err
+	}
+	s := &Server{
+		fd:         fd,
+		root:       root,
+		guard:      guard,
+		chown:      os.Geteuid() == 0,
+		nodes:      map[uint64]*node{rootNode: {path: ".", lookups: 1}},
+		byPath:     map[string]uint64{".": rootNode},
+		nextNode:   rootNode,
+		handles:    make(map[uint64]*handle),
+		interrupts: make(map[uint64]chan struct{}),
+	}
+	return s, nil
+}
+
+// Serve answers the kernel until the filesystem is unmounted. The serving process must not
+// use the mount itself: Go's poller would wait on the kernel while the kernel waits on Serve.
+func (s *Server) Serve() error {
+	defer unix.Close(s.root)
+	defer unix.Close(s.fd)
+
+	buffers := sync.Pool{New: func() any { return make([]byte, bufferSize) }}
+	var inflight sync.WaitGroup
+	defer func() {
+		// nobody is left to answer: writes still waiting for the host user give up
+		s.mu.Lock()
+		for unique, cancel := range s.interrupts {
+			close(cancel)
+			delete(s.interrupts, unique)
+		}
+		s.mu.Unlock()
+		inflight.Wait()
+	}()
+	for {
+		buf := buffers.Get().([]byte)
+		n, err := unix.Read(s.fd, buf)
+		switch {
+		case err == unix.EINTR || err == unix.EAGAIN || err == unix.ENOENT:
+			// ENOENT: the request was interrupted before it was read
+			buffers.Put(buf)
+			continue
+		case err == unix.ENODEV:
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to read from FUSE: %w", err)
+		}
+
+		var in inHeader
+		payload, err := decode(buf[:n], &in)
+		if err != nil {
+			buffers.Put(buf)
+			continue
+		}
+		switch in.Opcode {
+		case opDestroy:
+			s.reply(in.Unique, nil)
+			return nil
+		case opInterrupt:
+			s.interrupt(payload)
+			buffers.Put(buf)
+			continue
+		}
+
+		cancel := make(chan struct{})
+		s.mu.Lock()
+		s.interrupts[in.Unique] = cancel
+		s.mu.Unlock()
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			defer buffers.Put(buf)
+			s.dispatch(in, payload, cancel)
+			s.mu.Lock()
+			delete(s.interrupts, in.Unique)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// interrupt cancels a request that's waiting for the host user
+func (s *Server) interrupt(payload []byte) {
+	var in interruptIn
+	if _, err := decode(payload, &in); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.interrupts[in.Unique]; ok {
+		close(cancel)
+		delete(s.interrupts, in.Unique)
+	}
+}
+
+// dispatch answers one request; forgets get no reply
+func (s *Server) dispatch(in inHeader, payload []byte, cancel <-chan struct{}) {
+	var out []byte
+	var err error
+	switch in.Opcode {
+	case opInit:
+		out, err = s.init(payload)
+	case opForget:
+		var f forgetIn
+		if _, err := decode(payload, &f); err == nil {
+			s.forget(in.NodeID, f.Nlookup)
+		}
+		return
+	case opBatchForget:
+		s.batchForget(payload)
+		return
+	case opLookup:
+		out, err = s.lookup(in, payload)
+	case opGetattr:
+		out, err = s.getattr(in)
+	case opSetattr:
+		out, err = s.setattr(in, payload, cancel)
+	case opReadlink:
+		out, err = s.readlink(in)
+	case opSymlink:
+		out, err = s.symlink(in, payload, cancel)
+	case opMknod:
+		out, err = s.mknod(in, payload, cancel)
+	case opMkdir:
+		out, err = s.mkdir(in, payload, cancel)
+	case opUnlink, opRmdir:
+		err = s.remove(in, payload, cancel)
+	case opRename, opRename2:
+		err = s.rename(in, payload, cancel)
+	case opLink:
+		out, err = s.link(in, payload, cancel)
+	case opOpen, opOpendir:
+		out, err = s.open(in, payload, cancel)
+	case opCreate:
+		out, err = s.create(in, payload, cancel)
+	case opRead:
+		out, err = s.read(payload)
+	case opWrite:
+		out, err = s.write(payload)
+	case opReaddir:
+		out, err = s.readdir(payload)
+	case opRelease, opReleasedir:
+		s.release(payload)
+	case opFsync, opFsyncdir:
+		err = s.fsync(payload)
+	case opFallocate:
+		err = s.fallocate(payload)
+	case opStatfs:
+		out, err = s.statfs()
+	case opFlush:
+	default:
+		err = unix.ENOSYS
+	}
+	if err != nil {
+		s.replyError(in.Unique, err)
+		return
+	}
+	s.reply(in.Unique, out)
+}
+
+func (s *Server) reply(unique uint64, payload []byte) {
+	header := outHeader{Len: uint32(16 + len(payload)), Unique: unique}
+	_, _ = unix.Write(s.fd, encode(header, payload))
+}
+
+func (s *Server) replyError(unique uint64, err error) {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		errno = unix.EIO
+	}
+	header := outHeader{Len: 16, Error: -int32(errno), Unique: unique}
+	_, _ = unix.Write(s.fd, encode(header))
+}
+
+func (s *Server) init(payload []byte) ([]byte, error) {
+	var in initIn
+	if _, err := decode(payload, &in); err != nil {
+		return nil, err
+	}
+	if in.Major < fuseMajor {
+		return nil, unix.EPROTO
+	}
+	out := initOut{Major: fuseMajor, Minor: fuseMinor}
+	if in.Major > fuseMajor {
+		// the kernel resends INIT at our version
+		return encode(out), nil
+	}
+	if in.Minor < fuseMinor {
+		out.Minor = in.Minor
+	}
+	out.MaxReadahead = in.MaxReadahead
+	out.Flags = in.Flags & (initAsyncRead | initAtomicOTrunc | initBigWrites | initAutoInvalData | initParallelDirops | initMaxPages)
+	out.MaxBackground = 64
+	out.CongestionThreshold = 48
+	out.MaxWrite = maxWrite
+	out.TimeGran = 1
+	out.MaxPages = maxWrite / 4096
+	return encode(out), nil
+}
+
+// path returns the workspace-relative path of a node
+func (s *Server) path(nodeID uint64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[nodeID]
+	if !ok || n.path == "" {
+		return "", unix.ENOENT
+	}
+	return n.path, nil
+}
+
+// child returns the workspace-relative path of name in a directory node
+func (s *Server) child(nodeID uint64, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", unix.EINVAL
+	}
+	dir, err := s.path(nodeID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// remember takes a kernel reference on a path and returns its node
+func (s *Server) remember(path string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.byPath[path]; ok {
+		s.nodes[id].lookups++
+		return id
+	}
+	s.nextNode++
+	s.nodes[s.nextNode] = &node{path: path, lookups: 1}
+	s.byPath[path] = s.nextNode
+	return s.nextNode
+}
+
+func (s *Server) forget(nodeID, count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[nodeID]
+	if !ok || nodeID == rootNode {
+		return
+	}
+	if n.lookups > count {
+		n.lookups -= count
+		return
+	}
+	delete(s.nodes, nodeID)
+	if n.path != "" && s.byPath[n.path] == nodeID {
+		delete(s.byPath, n.path)
+	}
+}
+
+func (s *Server) batchForget(payload []byte) {
+	var in batchForgetIn
+	rest, err := decode(payload, &in)
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < in.Count; i++ {
+		var one forgetOne
+		if rest, err = decode(rest, &one); err != nil {
+			return
+		}
+		s.forget(one.NodeID, one.Nlookup)
+	}
+}
+
+// deleted drops the node for a path that no longer exists
+func (s *Server) deleted(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.byPath[path]; ok {
+		s.nodes[id].path = ""
+		delete(s.byPath, path)
+	}
+}
+
+// moved renames the nodes under from to to, dropping whatever was at to
+func (s *Server) moved(from, to string, exchange bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rename := func(path, from, to string) (string, bool) {
+		if path == from {
+			return to, true
+		}
+		if strings.HasPrefix(path, from+"/") {
+			return to + path[len(from):], true
+		}
+		return "", false
+	}
+	for id, n := range s.nodes {
+		if n.path == "" || id == rootNode {
+			continue
+		}
+		if newPath, ok := rename(n.path, from, to); ok {
+			n.path = newPath
+		} else if newPath, ok := rename(n.path, to, from); ok {
+			if exchange {
+				n.path = newPath
+			} else {
+				n.path = ""
+			}
+		}
+	}
+	s.byPath = map[string]uint64{}
+	for id, n := range s.nodes {
+		if n.path != "" {
+			s.byPath[n.path] = id
+		}
+	}
+}
+
+// check asks the guard whether op may change path
+func (s *Server) check(in inHeader, op, path string, cancel <-chan struct{}) error {
+	return s.guard.Check(op, path, in.PID, cancel)
+}
+
+// at returns a directory descriptor and name for a workspace-relative path; call done after
+func (s *Server) at(path string) (dirfd int, name string, done func(), err error) {
+	if path == "." {
+		return s.root, ".", func() {}, nil
+	}
+	dirfd, err = s.openat(filepath.Dir(path), unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return -1, "", nil, err
+	}
+	return dirfd, filepath.Base(path), func() { unix.Close(dirfd) }, nil
+}
+
+// openat opens a workspace-relative path without leaving the workspace or following symlinks
+func (s *Server) openat(path string, flags int, mode uint32) (int, error) {
+	how := unix.OpenHow{Flags: uint64(flags | unix.O_CLOEXEC | unix.O_NOFOLLOW), Mode: uint64(mode), Resolve: resolveBeneath}
+	for {
+		fd, err := unix.Openat2(s.root, path, &how)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			// a concurrent rename raced the lookup
+			continue
+		}
+		return fd, err
+	}
+}
+
+func (s *Server) lstat(path string) (attr, error) {
+	dirfd, name, done, err := s.at(path)
+	if err != nil {
+		return attr{}, err
+	}
+	defer done()
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return attr{}, err
+	}
+	return toAttr(&st), nil
+}
+
+func toAttr(st *unix.Stat_t) attr {
+	return attr{
+		Ino:       st.Ino,
+		Size:      uint64(st.Size),
+		Blocks:    uint64(st.Blocks),
+		Atime:     uint64(st.Atim.Sec),
+		Mtime:     uint64(st.Mtim.Sec),
+		Ctime:     uint64(st.Ctim.Sec),
+		Atimensec: uint32(st.Atim.Nsec),
+		Mtimensec: uint32(st.Mtim.Nsec),
+		Ctimensec: uint32(st.Ctim.Nsec),
+		Mode:      st.Mode,
+		Nlink:     uint32(st.Nlink),
+		UID:       st.Uid,
+		GID:       st.Gid,
+		Rdev:      uint32(st.Rdev),
+		Blksize:   uint32(st.Blksize),
+	}
+}
+
+// entry looks up a path and takes a kernel reference on it
+func (s *Server) entry(path string) (entryOut, error) {
+	a, err := s.lstat(path)
+	if err != nil {
+		return entryOut{}, err
+	}
+	return entryOut{NodeID: s.remember(path), EntryValid: 1, AttrValid: 1, Attr: a}, nil
+}
+
+// own hands a new file to the user who created it
+func (s *Server) own(in inHeader, path string) {
+	if !s.chown {
+		return
+	}
+	dirfd, name, done, err := s.at(path)
+	if err != nil {
+		return
+	}
+	defer done()
+	_ = unix.Fchownat(dirfd, name, int(in.UID), int(in.GID), unix.AT_SYMLINK_NOFOLLOW)
+}
+
+func (s *Server) lookup(in inHeader, payload []byte) ([]byte, error) {
+	name, _, err := cString(payload)
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.child(in.NodeID, name)
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.entry(path)
+	if err != nil {
+		return nil, err
+	}
+	return encode(e), nil
+}
+
+func (s *Server) getattr(in inHeader) ([]byte, error) {
+	path, err := s.path(in.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	a, err := s.lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	return encode(attrOut{AttrValid: 1, Attr: a}), nil
+}
+
+func (s *Server) setattr(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	var set setattrIn
+	if _, err := decode(payload, &set); err != nil {
+		return nil, err
+	}
+	path, err := s.path(in.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(in, "change", path, cancel); err != nil {
+		return nil, err
+	}
+
+	if set.Valid&setSize != 0 {
+		if h := s.handle(set.Fh); set.Valid&setFh != 0 && h != nil {
+			err = unix.Ftruncate(h.fd, int64(set.Size))
+		} else if fd, openErr := s.openat(path, unix.O_WRONLY, 0); openErr != nil {
+			err = openErr
+		} else {
+			err = unix.Ftruncate(fd, int64(set.Size))
+			unix.Close(fd)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if set.Valid&setMode != 0 {
+		// chmod through an O_PATH descriptor, which can't be a symlink that leads elsewhere
+		fd, err := s.openat(path, unix.O_PATH, 0)
+		if err != nil {
+			return nil, err
+		}
+		err = unix.Chmod(fmt.Sprintf("/proc/self/fd/%d", fd), set.Mode&07777)
+		unix.Close(fd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dirfd, name, done, err := s.at(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if set.Valid&(setUID|setGID) != 0 {
+		uid, gid := -1, -1
+		if set.Valid&setUID != 0 {
+			uid = int(set.UID)
+		}
+		if set.Valid&setGID != 0 {
+			gid = int(set.GID)
+		}
+		if err := unix.Fchownat(dirfd, name, uid, gid, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return nil, err
+		}
+	}
+	if set.Valid&(setAtime|setMtime|setAtimeNow|setMtimeNow) != 0 {
+		times := []unix.Timespec{{Nsec: unix.UTIME_OMIT}, {Nsec: unix.UTIME_OMIT}}
+		if set.Valid&setAtimeNow != 0 {
+			times[0].Nsec = unix.UTIME_NOW
+		} else if set.Valid&setAtime != 0 {
+			times[0] = unix.Timespec{Sec: int64(set.Atime), Nsec: int64(set.Atimensec)}
+		}
+		if set.Valid&setMtimeNow != 0 {
+			times[1].Nsec = unix.UTIME_NOW
+		} else if set.Valid&setMtime != 0 {
+			times[1] = unix.Timespec{Sec: int64(set.Mtime), Nsec: int64(set.Mtimensec)}
+		}
+		if err := unix.UtimesNanoAt(dirfd, name, times, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return nil, err
+		}
+	}
+
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return nil, err
+	}
+	return encode(attrOut{AttrValid: 1, Attr: toAttr(&st)}), nil
+}
+
+func (s *Server) readlink(in inHeader) ([]byte, error) {
+	path, err := s.path(in.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	dirfd, name, done, err := s.at(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlinkat(dirfd, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (s *Server) symlink(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	name, rest, err := cString(payload)
+	if err != nil {
+		return nil, err
+	}
+	target, _, err := cString(rest)
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.child(in.NodeID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(in, "symlink", path, cancel); err != nil {
+		return nil, err
+	}
+	dirfd, _, done, err := s.at(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if err := unix.Symlinkat(target, dirfd, name); err != nil {
+		return nil, err
+	}
+	s.own(in, path)
+	e, err := s.entry(path)
+	if err != nil {
+		return nil, err
+	}
+	return encode(e), nil
+}
+
+func (s *Server) mknod(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	var mk mknodIn
+	rest, err := decode(payload, &mk)
+	if err != nil {
+		return nil, err
+	}
+	name, _, err := cString(rest)
+	if err != nil {
+		return nil, err
+	}
+	// device nodes have no business in a workspace
+	if kind := mk.Mode & unix.S_IFMT; kind != unix.S_IFREG && kind != unix.S_IFIFO && kind != unix.S_IFSOCK {
+		return nil, unix.EPERM
+	}
+	path, err := s.child(in.NodeID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(in, "create", path, cancel); err != nil {
+		return nil, err
+	}
+	dirfd, _, done, err := s.at(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if err := unix.Mknodat(dirfd, name, mk.Mode, 0); err != nil {
+		return nil, err
+	}
+	s.own(in, path)
+	e, err := s.entry(path)
+	if err != nil {
+		return nil, err
+	}
+	return encode(e), nil
+}
+
+func (s *Server) mkdir(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	var mk mkdirIn
+	rest, err := decode(payload, &mk)
+	if err != nil {
+		return nil, err
+	}
+	name, _, err := cString(rest)
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.child(in.NodeID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(in, "mkdir", path, cancel); err != nil {
+		return nil, err
+	}
+	dirfd, _, done, err := s.at(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if err := unix.Mkdirat(dirfd, name, mk.Mode&07777); err != nil {
+		return nil, err
+	}
+	s.own(in, path)
+	e, err := s.entry(path)
+	if err != nil {
+		return nil, err
+	}
+	return encode(e), nil
+}
+
+func (s *Server) remove(in inHeader, payload []byte, cancel <-chan struct{}) error {
+	name, _, err := cString(payload)
+	if err != nil {
+		return err
+	}
+	path, err := s.child(in.NodeID, name)
+	if err != nil {
+		return err
+	}
+	if err := s.check(in, "delete", path, cancel); err != nil {
+		return err
+	}
+	dirfd, _, done, err := s.at(path)
+	if err != nil {
+		return err
+	}
+	defer done()
+	flags := 0
+	if in.Opcode == opRmdir {
+		flags = unix.AT_REMOVEDIR
+	}
+	if err := unix.Unlinkat(dirfd, name, flags); err != nil {
+		return err
+	}
+	s.deleted(path)
+	return nil
+}
+
+func (s *Server) rename(in inHeader, payload []byte, cancel <-chan struct{}) error {
+	var newDir uint64
+	var flags uint32
+	var rest []byte
+	var err error
+	if in.Opcode == opRename2 {
+		var r rename2In
+		rest, err = decode(payload, &r)
+		newDir, flags = r.Newdir, r.Flags
+	} else {
+		var r renameIn
+		rest, err = decode(payload, &r)
+		newDir = r.Newdir
+	}
+	if err != nil {
+		return err
+	}
+	oldName, rest, err := cString(rest)
+	if err != nil {
+		return err
+	}
+	newName, _, err := cString(rest)
+	if err != nil {
+		return err
+	}
+	from, err := s.child(in.NodeID, oldName)
+	if err != nil {
+		return err
+	}
+	to, err := s.child(newDir, newName)
+	if err != nil {
+		return err
+	}
+	if err := s.check(in, "rename", from, cancel); err != nil {
+		return err
+	}
+	if err := s.check(in, "rename", to, cancel); err != nil {
+		return err
+	}
+
+	fromDir, _, doneFrom, err := s.at(from)
+	if err != nil {
+		return err
+	}
+	defer doneFrom()
+	toDir, _, doneTo, err := s.at(to)
+	if err != nil {
+		return err
+	}
+	defer doneTo()
+	if err := unix.Renameat2(fromDir, oldName, toDir, newName, uint(flags)); err != nil {
+		return err
+	}
+	s.moved(from, to, flags&unix.RENAME_EXCHANGE != 0)
+	return nil
+}
+
+func (s *Server) link(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	var l linkIn
+	rest, err := decode(payload, &l)
+	if err != nil {
+		return nil, err
+	}
+	name, _, err := cString(rest)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.path(l.Oldnodeid)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.child(in.NodeID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(in, "link", to, cancel); err != nil {
+		return nil, err
+	}
+	fromDir, fromName, doneFrom, err := s.at(from)
+	if err != nil {
+		return nil, err
+	}
+	defer doneFrom()
+	toDir, _, doneTo, err := s.at(to)
+	if err != nil {
+		return nil, err
+	}
+	defer doneTo()
+	if err := unix.Linkat(fromDir, fromName, toDir, name, 0); err != nil {
+		return nil, err
+	}
+	e, err := s.entry(to)
+	if err != nil {
+		return nil, err
+	}
+	return encode(e), nil
+}
+
+// writes reports whether open flags can change a file
+func writes(flags uint32) bool {
+	return flags&unix.O_ACCMODE != unix.O_RDONLY || flags&unix.O_TRUNC != 0
+}
+
+func (s *Server) open(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	var o openIn
+	if _, err := decode(payload, &o); err != nil {
+		return nil, err
+	}
+	path, err := s.path(in.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	flags := int(o.Flags) &^ (unix.O_CREAT | unix.O_EXCL | unix.O_NOCTTY)
+	if in.Opcode == opOpendir {
+		flags = unix.O_RDONLY | unix.O_DIRECTORY
+	} else if writes(o.Flags) {
+		if err := s.check(in, "write", path, cancel); err != nil {
+			return nil, err
+		}
+	}
+	fd, err := s.openat(path, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	return encode(openOut{Fh: s.addHandle(fd)}), nil
+}
+
+func (s *Server) create(in inHeader, payload []byte, cancel <-chan struct{}) ([]byte, error) {
+	var c createIn
+	rest, err := decode(payload, &c)
+	if err != nil {
+		return nil, err
+	}
+	name, _, err := cString(rest)
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.child(in.NodeID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(in, "create", path, cancel); err != nil {
+		return nil, err
+	}
+	fd, err := s.openat(path, int(c.Flags)|unix.O_CREAT, c.Mode&07777)
+	if err != nil {
+		return nil, err
+	}
+	if s.chown {
+		_ = unix.Fchown(fd, int(in.UID), int(in.GID))
+	}
+	e, err := s.entry(path)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return encode(e, openOut{Fh: s.addHandle(fd)}), nil
+}
+
+func (s *Server) addHandle(fd int) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextHandle++
+	s.handles[s.nextHandle] = &handle{fd: fd}
+	return s.nextHandle
+}
+
+func (s *Server) handle(fh uint64) *handle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handles[fh]
+}
+
+func (s *Server) release(payload []byte) {
+	var r releaseIn
+	if _, err := decode(payload, &r); err != nil {
+		return
+	}
+	s.mu.Lock()
+	h, ok := s.handles[r.Fh]
+	delete(s.handles, r.Fh)
+	s.mu.Unlock()
+	if ok {
+		unix.Close(h.fd)
+	}
+}
+
+func (s *Server) read(payload []byte) ([]byte, error) {
+	var r readIn
+	if _, err := decode(payload, &r); err != nil {
+		return nil, err
+	}
+	h := s.handle(r.Fh)
+	if h == nil {
+		return nil, unix.EBADF
+	}
+	buf := make([]byte, r.Size)
+	n, err := unix.Pread(h.fd, buf, int64(r.Offset))
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// write needs no check: the file was opened for writing, which was checked
+func (s *Server) write(payload []byte) ([]byte, error) {
+	var w writeIn
+	data, err := decode(payload, &w)
+	if err != nil {
+		return nil, err
+	}
+	h := s.handle(w.Fh)
+	if h == nil {
+		return nil, unix.EBADF
+	}
+	if int(w.Size) < len(data) {
+		data = data[:w.Size]
+	}
+	n, err := unix.Pwrite(h.fd, data, int64(w.Offset))
+	if err != nil {
+		return nil, err
+	}
+	return encode(writeOut{Size: uint32(n)}), nil
+}
+
+func (s *Server) readdir(payload []byte) ([]byte, error) {
+	var r readIn
+	if _, err := decode(payload, &r); err != nil {
+		return nil, err
+	}
+	h := s.handle(r.Fh)
+	if h == nil {
+		return nil, unix.EBADF
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r.Offset == 0 || h.entries == nil {
+		entries, err := listDir(h.fd)
+		if err != nil {
+			return nil, err
+		}
+		h.entries = entries
+	}
+
+	var out []byte
+	for i := int(r.Offset); i < len(h.entries); i++ {
+		e := h.entries[i]
+		size := (24 + len(e.name) + 7) &^ 7
+		if len(out)+size > int(r.Size) {
+			break
+		}
+		record := make([]byte, size)
+		binary.NativeEndian.PutUint64(record[0:], e.ino)
+		binary.NativeEndian.PutUint64(record[8:], uint64(i+1))
+		binary.NativeEndian.PutUint32(record[16:], uint32(len(e.name)))
+		binary.NativeEndian.PutUint32(record[20:], uint32(e.typ))
+		copy(record[24:], e.name)
+		out = append(out, record...)
+	}
+	return out, nil
+}
+
+// listDir reads a whole directory from its start
+func listDir(fd int) ([]dirEntry, error) {
+	if _, err := unix.Seek(fd, 0, 0); err != nil {
+		return nil, err
+	}
+	entries := []dirEntry{}
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := unix.Getdents(fd, buf)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return entries, nil
+		}
+		// struct linux_dirent64: ino, off, reclen, type, name
+		for b := buf[:n]; len(b) >= 19; {
+			reclen := int(binary.NativeEndian.Uint16(b[16:]))
+			if reclen < 19 || reclen > len(b) {
+				break
+			}
+			name := b[19:reclen]
+			if end := strings.IndexByte(string(name), 0); end >= 0 {
+				name = name[:end]
+			}
+			entries = append(entries, dirEntry{
+				ino:  binary.NativeEndian.Uint64(b[0:]),
+				typ:  b[18],
+				name: string(name),
+			})
+			b = b[reclen:]
+		}
+	}
+}
+
+func (s *Server) fsync(payload []byte) error {
+	var f fsyncIn
+	if _, err := decode(payload, &f); err != nil {
+		return err
+	}
+	h := s.handle(f.Fh)
+	if h == nil {
+		return unix.EBADF
+	}
+	if f.FsyncFlags&1 != 0 {
+		return unix.Fdatasync(h.fd)
+	}
+	return unix.Fsync(h.fd)
+}
+
+func (s *Server) fallocate(payload []byte) error {
+	var f fallocateIn
+	if _, err := decode(payload, &f); err != nil {
+		return err
+	}
+	h := s.handle(f.Fh)
+	if h == nil {
+		return unix.EBADF
+	}
+	return unix.Fallocate(h.fd, f.Mode, int64(f.Offset), int64(f.Length))
+}
+
+func (s *Server) statfs() ([]byte, error) {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(s.root, &st); err != nil {
+		return nil, err
+	}
+	return encode(kstatfs{
+		Blocks:  st.Blocks,
+		Bfree:   st.Bfree,
+		Bavail:  st.Bavail,
+		Files:   st.Files,
+		Ffree:   st.Ffree,
+		Bsize:   uint32(st.Bsize),
+		Namelen: uint32(st.Namelen),
+		Frsize:  uint32(st.Frsize),
+	}), nil
+}
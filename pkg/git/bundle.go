@@ -0,0 +1,84 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GetHeadCommit returns the commit SHA checked out at path
+func GetHeadCommit(path string) (string, error) {
+	output, err := gitOutput(path, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD in %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CreateBundle writes a git bundle containing branch and its history to bundlePath
+func CreateBundle(path, branch, bundlePath string) error {
+	output, err := gitCombinedOutput(path, "bundle", "create", bundlePath, branch)
+	if err != nil {
+		return fmt.Errorf("failed to bundle branch %s: %w\n%s", branch, err, output)
+	}
+	return nil
+}
+
+// FetchBundle fetches branch from a bundle into a new local branch of the same name
+// Fails if the branch already exists so imports never clobber local work
+func FetchBundle(repoPath, bundlePath, branch string) error {
+	checkCmd := exec.Command("git", "-C", repoPath, "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", branch))
+	if checkCmd.Run() == nil {
+		return fmt.Errorf("branch '%s' already exists in %s", branch, repoPath)
+	}
+
+	refspec := fmt.Sprintf("%s:%s", branch, branch)
+	output, err := gitCombinedOutput(repoPath, "fetch", bundlePath, refspec)
+	if err != nil {
+		return fmt.Errorf("failed to fetch branch %s from bundle: %w\n%s", branch, err, output)
+	}
+	return nil
+}
+
+// AddWorktree checks out an existing branch into a new worktree at path
+func AddWorktree(repoPath, path, branch string) error {
+	output, err := gitCombinedOutput(repoPath, "worktree", "add", path, branch)
+	if err != nil {
+		return fmt.Errorf("failed to add worktree for %s: %w\n%s", branch, err, output)
+	}
+	return nil
+}
+
+// DiffAgainstHead returns a binary-safe patch of all staged and unstaged changes
+func DiffAgainstHead(path string) ([]byte, error) {
+	output, err := gitOutput(path, "diff", "--binary", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff working tree: %w", err)
+	}
+	return output, nil
+}
+
+// ApplyPatch applies a patch produced by DiffAgainstHead to the working tree at path
+func ApplyPatch(path, patchPath string) error {
+	output, err := gitCombinedOutput(path, "apply", "--binary", patchPath)
+	if err != nil {
+		return fmt.Errorf("failed to apply patch: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// ListUntrackedFiles returns untracked, non-ignored files relative to path
+func ListUntrackedFiles(path string) ([]string, error) {
+	output, err := gitOutput(path, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
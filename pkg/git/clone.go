@@ -0,0 +1,168 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ParseRepoSpec splits "<url>[@branch]" into the repository URL and branch ("" for the default)
+// Only an @ inside the URL's path starts the branch, so git@host:org/repo.git has none.
+func ParseRepoSpec(spec string) (string, string, error) {
+	pathStart := strings.Index(spec, ":")
+	if scheme := strings.Index(spec, "://"); scheme >= 0 {
+		pathStart = len(spec)
+		if slash := strings.Index(spec[scheme+3:], "/"); slash >= 0 {
+			pathStart = scheme + 3 + slash
+		}
+	}
+
+	url, branch := spec, ""
+	if at := strings.LastIndex(spec, "@"); at > pathStart {
+		url, branch = spec[:at], spec[at+1:]
+		if branch == "" {
+			return "", "", fmt.Errorf("missing branch after @ in '%s'", spec)
+		}
+	}
+	if url == "" || repoKey(url) == "" {
+		return "", "", fmt.Errorf("invalid repository '%s' (use e.g. git@github.com:org/repo.git@branch)", spec)
+	}
+	return url, branch, nil
+}
+
+// repoKey names a repository on disk as <host>/<path>, e.g. github.com/org/repo
+func repoKey(url string) string {
+	host, path := "local", url
+	if scheme := strings.Index(url, "://"); scheme >= 0 {
+		rest := url[scheme+3:]
+		host, path, _ = strings.Cut(rest, "/")
+		if strings.HasPrefix(url, "file://") {
+			host = "local"
+		}
+	} else if before, after, ok := strings.Cut(url, ":"); ok && !strings.Contains(before, "/") {
+		host, path = before, after
+	}
+	// Drop the user and port, e.g. git@ and :2222
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	host, _, _ = strings.Cut(host, ":")
+
+	var parts []string
+	for _, part := range strings.Split(strings.TrimSuffix(strings.Trim(path, "/"), ".git"), "/") {
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	if host == "" || len(parts) == 0 {
+		return ""
+	}
+	return filepath.Join(append([]string{host}, parts...)...)
+}
+
+// GetClonesDir returns where managed clones of remote repositories live
+// Uses XDG-compliant location: ~/.local/share/packnplay/clones/<host>/<path>
+func GetClonesDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "clones")
+}
+
+// getCloneCacheDir returns where bare reference copies of cloned repositories are kept
+func getCloneCacheDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "clone-cache")
+}
+
+// EnsureClone makes sure a managed clone of url exists with branch fetched, and returns its path
+// The first clone is partial (no blobs until checked out) and, with depth > 0, shallow. Its
+// objects come from a bare reference cache where possible, so recloning after the clone is
+// deleted only fetches what changed. Later calls fetch branch but never move a local branch
+// that already exists, so an agent's unpushed work survives.
+func EnsureClone(url, branch string, depth int, verbose bool) (string, error) {
+	key := repoKey(url)
+	dir := filepath.Join(GetClonesDir(), key)
+	cache := filepath.Join(getCloneCacheDir(), key+".git")
+
+	var depthArgs []string
+	if depth > 0 {
+		depthArgs = []string{"--depth", fmt.Sprint(depth)}
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// The cache only speeds things up; a clone without it still works
+		if err := updateCloneCache(url, cache, verbose); err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "Warning: not using a reference cache: %v\n", err)
+		}
+
+		args := append([]string{"clone", "--filter=blob:none", "--reference-if-able", cache, "--dissociate"}, depthArgs...)
+		if branch != "" {
+			args = append(args, "--branch", branch)
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create clones dir: %w", err)
+		}
+		if err := runGit(verbose, append(args, url, dir)...); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to clone %s: %w", url, err)
+		}
+		return dir, nil
+	}
+
+	if branch == "" {
+		return dir, nil
+	}
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch)
+	if err := runGit(verbose, append(append([]string{"-C", dir, "fetch"}, depthArgs...), "origin", refspec)...); err != nil {
+		return "", fmt.Errorf("failed to fetch %s from %s: %w", branch, url, err)
+	}
+	if exec.Command("git", "-C", dir, "show-ref", "--verify", "--quiet", "refs/heads/"+branch).Run() != nil {
+		// A shallow clone only follows its first branch; follow this one too, so it can be tracked
+		if err := runGit(verbose, "-C", dir, "remote", "set-branches", "--add", "origin", branch); err != nil {
+			return "", fmt.Errorf("failed to follow %s: %w", branch, err)
+		}
+		if err := runGit(verbose, "-C", dir, "branch", "--track", branch, "origin/"+branch); err != nil {
+			return "", fmt.Errorf("failed to create branch %s: %w", branch, err)
+		}
+	}
+	return dir, nil
+}
+
+// updateCloneCache creates or refreshes the bare, blobless reference copy of url
+func updateCloneCache(url, cache string, verbose bool) error {
+	if _, err := os.Stat(cache); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(cache), 0755); err != nil {
+			return fmt.Errorf("failed to create clone cache dir: %w", err)
+		}
+		if err := runGit(verbose, "clone", "--bare", "--filter=blob:none", url, cache); err != nil {
+			os.RemoveAll(cache)
+			return err
+		}
+		return nil
+	}
+	return runGit(verbose, "-C", cache, "fetch", "--prune", "--filter=blob:none", "origin", "+refs/heads/*:refs/heads/*")
+}
+
+// runGit runs git, showing the command and its progress when verbose
+func runGit(verbose bool, args ...string) error {
+	cmd := exec.Command("git", args...)
+	if verbose {
+		fmt.Fprintf(os.Stderr, "+ git %s\n", strings.Join(args, " "))
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w\n%s", err, output)
+	}
+	return nil
+}
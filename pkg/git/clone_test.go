@@ -0,0 +1,101 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRepoSpec(t *testing.T) {
+	tests := []struct {
+		spec, url, branch string
+	}{
+		{"git@github.com:org/repo.git", "git@github.com:org/repo.git", ""},
+		{"git@github.com:org/repo.git@main", "git@github.com:org/repo.git", "main"},
+		{"https://github.com/org/repo", "https://github.com/org/repo", ""},
+		{"https://github.com/org/repo.git@feature/login", "https://github.com/org/repo.git", "feature/login"},
+		{"https://user@git.example.com:8443/team/repo.git@v2", "https://user@git.example.com:8443/team/repo.git", "v2"},
+		{"ssh://git@github.com/org/repo.git", "ssh://git@github.com/org/repo.git", ""},
+		{"/srv/git/repo.git@main", "/srv/git/repo.git", "main"},
+	}
+	for _, tt := range tests {
+		url, branch, err := ParseRepoSpec(tt.spec)
+		if err != nil || url != tt.url || branch != tt.branch {
+			t.Errorf("ParseRepoSpec(%q) = %q, %q, %v; want %q, %q", tt.spec, url, branch, err, tt.url, tt.branch)
+		}
+	}
+
+	for _, spec := range []string{"", "git@github.com:org/repo.git@", "https://github.com"} {
+		if _, _, err := ParseRepoSpec(spec); err == nil {
+			t.Errorf("ParseRepoSpec(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRepoKey(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:org/repo.git":                 "github.com/org/repo",
+		"https://github.com/org/repo":                 "github.com/org/repo",
+		"ssh://git@git.example.com:2222/team/app.git": "git.example.com/team/app",
+		"file:///srv/git/../repo.git":                 "local/srv/git/repo",
+	}
+	for url, want := range tests {
+		if got := repoKey(url); got != filepath.FromSlash(want) {
+			t.Errorf("repoKey(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestEnsureClone(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// A remote with a main and a feature branch
+	remote := filepath.Join(t.TempDir(), "app")
+	gitIn := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.email=t@example.com", "-c", "user.name=t"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	if err := exec.Command("git", "init", "-q", "-b", "main", remote).Run(); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(remote, "config", "uploadpack.allowfilter", "true")
+	os.WriteFile(filepath.Join(remote, "README"), []byte("main\n"), 0644)
+	gitIn(remote, "add", ".")
+	gitIn(remote, "commit", "-qm", "main")
+	gitIn(remote, "checkout", "-qb", "feature")
+	os.WriteFile(filepath.Join(remote, "feature.txt"), []byte("feature\n"), 0644)
+	gitIn(remote, "add", ".")
+	gitIn(remote, "commit", "-qm", "feature")
+	gitIn(remote, "checkout", "-q", "main")
+
+	url := "file://" + remote
+	dir, err := EnsureClone(url, "", 1, false)
+	if err != nil {
+		t.Fatalf("EnsureClone() error: %v", err)
+	}
+	if dir != filepath.Join(GetClonesDir(), repoKey(url)) {
+		t.Errorf("clone at %s", dir)
+	}
+	if branch, _ := GetCurrentBranch(dir); branch != "main" {
+		t.Errorf("clone checked out %q, want the default branch", branch)
+	}
+	if _, err := os.Stat(filepath.Join(getCloneCacheDir(), repoKey(url)+".git")); err != nil {
+		t.Errorf("no reference cache: %v", err)
+	}
+
+	// Another branch of the existing clone is fetched into a local branch for a worktree
+	if _, err := EnsureClone(url, "feature", 1, false); err != nil {
+		t.Fatalf("EnsureClone(feature) error: %v", err)
+	}
+	if err := exec.Command("git", "-C", dir, "show-ref", "--verify", "--quiet", "refs/heads/feature").Run(); err != nil {
+		t.Error("feature wasn't made a local branch")
+	}
+
+	if _, err := EnsureClone(url, "no-such-branch", 1, false); err == nil {
+		t.Error("EnsureClone() of a missing branch should fail")
+	}
+}
@@ -0,0 +1,197 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// alwaysOverride neutralizes settings that run commands however the repository is set up
+var alwaysOverride = []string{
+	"core.fsmonitor=",
+	"core.hooksPath=/dev/null",
+	"protocol.ext.allow=never",
+}
+
+// commandKeys are settings whose values git runs as commands, and the value that makes them
+// harmless; "" leaves the setting off. Subsection keys (filter.<driver>.clean) match on
+// section and key.
+var commandKeys = map[string]string{
+	"core.fsmonitor":             "",
+	"core.hookspath":             "/dev/null",
+	"core.sshcommand":            "ssh",
+	"core.gitproxy":              "",
+	"core.askpass":               "",
+	"core.pager":                 "cat",
+	"core.editor":                ":",
+	"core.alternaterefscommand":  "",
+	"sequence.editor":            ":",
+	"diff.external":              "",
+	"credential.helper":          "",
+	"gpg.program":                "gpg",
+	"filter.*.clean":             "",
+	"filter.*.smudge":            "",
+	"filter.*.process":           "",
+	"filter.*.required":          "false",
+	"diff.*.textconv":            "",
+	"diff.*.command":             "",
+	"merge.*.driver":             "",
+	"credential.*.helper":        "",
+	"gpg.*.program":              "",
+	"remote.*.uploadpack":        "git-upload-pack",
+	"remote.*.receivepack":       "git-receive-pack",
+	"submodule.*.update":         "checkout",
+	"uploadpack.packobjectshook": "",
+}
+
+// listKeys accumulate values across config files instead of the last one winning; an empty
+// value clears what came before
+var listKeys = map[string]bool{"credential.helper": true, "credential.*.helper": true}
+
+// Command returns a host git command for the repository at dir, hardened for repositories a
+// sandbox can write to. The container mounts .git read-write, so its config is the agent's
+// to edit: core.fsmonitor, filters, hooks and the like would run the agent's commands on
+// the host. Those settings are overridden wherever the repository's own config sets them,
+// keeping the user's global values, and the system config isn't read.
+func Command(dir string, args ...string) (*exec.Cmd, error) {
+	env := append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1")
+	list := exec.Command("git", "-C", dir, "config", "--list", "--show-scope", "-z")
+	list.Env = env
+	output, err := list.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git config of %s: %w", dir, err)
+	}
+	overrides, err := configOverrides(string(output))
+	if err != nil {
+		return nil, fmt.Errorf("refusing to run git in %s: %w", dir, err)
+	}
+
+	argv := []string{"-C", dir}
+	for _, override := range overrides {
+		argv = append(argv, "-c", override)
+	}
+	cmd := exec.Command("git", append(argv, args...)...)
+	cmd.Env = env
+	return cmd, nil
+}
+
+// IsolatedCommand returns a host git command that sees the objects of the repository at
+// dir but none of its config, with a cleanup func that removes its scratch repository.
+// Overrides can't undo everything the repository's config does to a remote: url.*.insteadOf
+// rewrites the URL, and http.proxy, http.sslVerify or http.extraHeader redirect or weaken the
+// connection. Commands that use the host's credentials against a remote run here instead,
+// with only the user's global config.
+func IsolatedCommand(dir string, args ...string) (*exec.Cmd, func(), error) {
+	output, err := gitOutput(dir, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find the git dir of %s: %w", dir, err)
+	}
+	objects := filepath.Join(strings.TrimSpace(string(output)), "objects")
+
+	scratch, err := os.MkdirTemp("", "packnplay-git-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scratch repository: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(scratch) }
+
+	env := append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1")
+	init := exec.Command("git", "init", "-q", "--bare", "--template=", scratch)
+	init.Env = env
+	if output, err := init.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create scratch repository: %w\n%s", err, output)
+	}
+	infoDir := filepath.Join(scratch, "objects", "info")
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create scratch repository: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(infoDir, "alternates"), []byte(objects+"\n"), 0644); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create scratch repository: %w", err)
+	}
+
+	cmd := exec.Command("git", append([]string{"--git-dir", scratch}, args...)...)
+	cmd.Env = env
+	return cmd, cleanup, nil
+}
+
+// configOverrides returns the -c settings that undo the repository's command settings,
+// given `git config --list --show-scope -z` output
+func configOverrides(list string) ([]string, error) {
+	global := map[string][]string{}
+	var repoKeys []string
+	seen := map[string]bool{}
+	fields := strings.Split(list, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		scope := fields[i]
+		key, value, _ := strings.Cut(fields[i+1], "\n")
+		_, isCommand := commandPattern(key)
+		switch {
+		case scope == "global" && isCommand:
+			global[key] = append(global[key], value)
+		case scope == "local" || scope == "worktree":
+			if strings.EqualFold(key, "core.worktree") {
+				return nil, fmt.Errorf("its config moves the working tree to %s", value)
+			}
+			if isCommand && !seen[key] {
+				seen[key] = true
+				repoKeys = append(repoKeys, key)
+			}
+		}
+	}
+
+	overrides := append([]string{}, alwaysOverride...)
+	for _, key := range repoKeys {
+		pattern, _ := commandPattern(key)
+		values := global[key]
+		switch {
+		case listKeys[pattern]:
+			overrides = append(overrides, key+"=")
+			for _, value := range values {
+				overrides = append(overrides, key+"="+value)
+			}
+		case len(values) > 0:
+			overrides = append(overrides, key+"="+values[len(values)-1])
+		default:
+			overrides = append(overrides, key+"="+commandKeys[pattern])
+		}
+	}
+	return overrides, nil
+}
+
+// commandPattern returns the commandKeys entry a config key matches; section and key
+// names are case-insensitive, subsection names aren't
+func commandPattern(key string) (string, bool) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first < 0 {
+		return "", false
+	}
+	pattern := strings.ToLower(key)
+	if first != last {
+		pattern = strings.ToLower(key[:first]) + ".*" + strings.ToLower(key[last:])
+	}
+	_, ok := commandKeys[pattern]
+	return pattern, ok
+}
+
+// gitOutput runs a hardened git command in dir and returns its stdout
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd, err := Command(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.Output()
+}
+
+// gitCombinedOutput runs a hardened git command in dir and returns its stdout and stderr
+func gitCombinedOutput(dir string, args ...string) ([]byte, error) {
+	cmd, err := Command(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.CombinedOutput()
+}
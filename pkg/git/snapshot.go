@@ -0,0 +1,113 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotTree records the working tree at path, untracked files included, as a tree
+// object and returns its hash. A scratch index is used, so nothing the user staged changes.
+func SnapshotTree(path string) (string, error) {
+	indexDir, err := os.MkdirTemp("", "packnplay-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create scratch index: %w", err)
+	}
+	defer os.RemoveAll(indexDir)
+	index := "GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")
+	git := func(args ...string) (*exec.Cmd, error) {
+		cmd, err := Command(path, args...)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, index)
+		return cmd, nil
+	}
+
+	// Starting from HEAD's tree keeps the add to what changed; a repo with no commits starts empty
+	readTree, err := git("read-tree", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	_ = readTree.Run()
+
+	add, err := git("add", "-A")
+	if err != nil {
+		return "", err
+	}
+	if output, err := add.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to snapshot %s: %w\n%s", path, err, output)
+	}
+	writeTree, err := git("write-tree")
+	if err != nil {
+		return "", err
+	}
+	output, err := writeTree.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// DiffTrees returns the patch between two trees from SnapshotTree
+func DiffTrees(path, from, to string) ([]byte, error) {
+	output, err := gitOutput(path, "diff", "--binary", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff snapshots: %w", err)
+	}
+	return output, nil
+}
+
+// DiffSince returns a text patch of everything changed in the working tree at path since
+// base, untracked files included, for reading rather than applying. base "" means HEAD.
+func DiffSince(path, base string) ([]byte, error) {
+	if base == "" {
+		base = "HEAD"
+	}
+	tree, err := SnapshotTree(path)
+	if err != nil {
+		return nil, err
+	}
+	output, err := gitOutput(path, "diff", base, tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff since %s: %w", base, err)
+	}
+	return output, nil
+}
+
+// ChangedSince lists the files changed in the working tree at path since base, untracked
+// files included; a rename counts as a deletion and an addition. base "" means HEAD.
+func ChangedSince(path, base string) ([]string, error) {
+	if base == "" {
+		base = "HEAD"
+	}
+	tree, err := SnapshotTree(path)
+	if err != nil {
+		return nil, err
+	}
+	output, err := gitOutput(path, "diff", "--name-only", "--no-renames", "-z", base, tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes since %s: %w", base, err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// ShowFile returns a file's contents at rev, or nil if it didn't exist there
+func ShowFile(path, rev, file string) ([]byte, error) {
+	if _, err := gitOutput(path, "cat-file", "-e", rev+":"+file); err != nil {
+		return nil, nil
+	}
+	output, err := gitOutput(path, "show", rev+":"+file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", file, rev, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,69 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644)
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "main.go"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "init"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	before, err := SnapshotTree(repo)
+	if err != nil {
+		t.Fatalf("SnapshotTree() error = %v", err)
+	}
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "new.go"), []byte("package main\n"), 0644)
+	after, err := SnapshotTree(repo)
+	if err != nil {
+		t.Fatalf("SnapshotTree() error = %v", err)
+	}
+
+	diff, err := DiffTrees(repo, before, after)
+	if err != nil {
+		t.Fatalf("DiffTrees() error = %v", err)
+	}
+	for _, want := range []string{"+func main() {}", "b/new.go"} {
+		if !strings.Contains(string(diff), want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+
+	since, err := DiffSince(repo, "")
+	if err != nil {
+		t.Fatalf("DiffSince() error = %v", err)
+	}
+	if !strings.Contains(string(since), "b/new.go") {
+		t.Errorf("DiffSince() missing the untracked file:\n%s", since)
+	}
+	if changed, err := ChangedSince(repo, ""); err != nil || strings.Join(changed, ",") != "main.go,new.go" {
+		t.Errorf("ChangedSince() = %v, %v; want main.go and new.go", changed, err)
+	}
+	if data, err := ShowFile(repo, "HEAD", "main.go"); err != nil || string(data) != "package main\n" {
+		t.Errorf("ShowFile() = %q, %v; want the committed contents", data, err)
+	}
+	if data, err := ShowFile(repo, "HEAD", "new.go"); err != nil || data != nil {
+		t.Errorf("ShowFile() of an uncommitted file = %q, %v; want nil", data, err)
+	}
+
+	// The snapshot must not stage anything in the real index
+	if output, _ := exec.Command("git", "-C", repo, "diff", "--cached", "--name-only").Output(); len(output) != 0 {
+		t.Errorf("snapshot staged %s", output)
+	}
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Sparse describes a checkout that only has part of its repository: a sparse checkout,
+// whose patterns choose the files in the working tree, and/or a partial clone, whose
+// missing objects are fetched from the remote when something needs them
+type Sparse struct {
+	Patterns []string // the sparse-checkout patterns; directories in cone mode
+	Cone     bool
+	Partial  bool
+}
+
+// SparseInfo reports how much of its repository the checkout at path has; nil means all of it
+func SparseInfo(path string) (*Sparse, error) {
+	info := &Sparse{
+		Partial: gitConfig(path, "extensions.partialClone") != "" || gitConfig(path, "remote.origin.promisor") == "true",
+	}
+	if gitConfig(path, "core.sparseCheckout") == "true" {
+		list, err := Command(path, "sparse-checkout", "list")
+		if err != nil {
+			return nil, err
+		}
+		output, err := list.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sparse-checkout patterns: %w", err)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				info.Patterns = append(info.Patterns, line)
+			}
+		}
+		// sparse-checkout init and set record the mode; checkouts set up by hand are non-cone
+		info.Cone = gitConfig(path, "core.sparseCheckoutCone") == "true"
+	}
+	if len(info.Patterns) == 0 && !info.Partial {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// gitConfig returns a config value of the repository at path, or "" if it isn't set
+func gitConfig(path, key string) string {
+	output, err := exec.Command("git", "-C", path, "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ValidateSparsePath checks a path asked to be materialized stays inside the repository
+func ValidateSparsePath(p string) error {
+	clean := path.Clean(p)
+	if p == "" || path.IsAbs(p) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(p, "-") {
+		return fmt.Errorf("invalid path '%s' (use a path relative to the repository root)", p)
+	}
+	return nil
+}
+
+// SparseAdd materializes more of the repository in the sparse checkout at path
+// In a partial clone the files' contents are fetched from the remote.
+func SparseAdd(path string, paths []string) error {
+	for _, p := range paths {
+		if err := ValidateSparsePath(p); err != nil {
+			return err
+		}
+	}
+	// The agent asks for this while it can write the repository's config, which the checkout
+	// would otherwise take filters and fsmonitor commands from
+	cmd, err := Command(path, append([]string{"sparse-checkout", "add", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add %s to the sparse checkout: %w\n%s", strings.Join(paths, ", "), err, output)
+	}
+	return nil
+}
+
+// createSparseWorktree creates a worktree with the same sparse patterns as the repo at
+// repoPath, so a monorepo isn't checked out (or in a partial clone, fetched) in full
+func createSparseWorktree(repoPath, path string, addArgs []string, sparse *Sparse, verbose bool) error {
+	run := func(dir string, args ...string) error {
+		cmd, err := Command(dir, args...)
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "+ git -C %s %s\n", dir, strings.Join(args, " "))
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+		}
+		return cmd.Run()
+	}
+
+	if err := run(repoPath, append([]string{"worktree", "add", "--no-checkout"}, addArgs...)...); err != nil {
+		return err
+	}
+	mode := "--no-cone"
+	if sparse.Cone {
+		mode = "--cone"
+	}
+	if err := run(path, append([]string{"sparse-checkout", "set", mode, "--"}, sparse.Patterns...)...); err != nil {
+		return err
+	}
+	// The worktree starts with nothing checked out; this fills in just the sparse paths
+	return run(path, "reset", "-q", "--hard")
+}
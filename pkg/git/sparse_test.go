@@ -0,0 +1,64 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSparseWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := filepath.Join(t.TempDir(), "monorepo")
+	for _, file := range []string{"services/api/main.go", "services/billing/main.go", "web/index.html"} {
+		os.MkdirAll(filepath.Join(repo, filepath.Dir(file)), 0755)
+		os.WriteFile(filepath.Join(repo, file), []byte("x\n"), 0644)
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "."},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "init"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	if info, err := SparseInfo(repo); err != nil || info != nil {
+		t.Fatalf("SparseInfo() of a full checkout = %+v, %v; want nil", info, err)
+	}
+	if output, err := exec.Command("git", "-C", repo, "sparse-checkout", "set", "--cone", "services/api").CombinedOutput(); err != nil {
+		t.Skipf("git without sparse-checkout set: %v\n%s", err, output)
+	}
+	info, err := SparseInfo(repo)
+	if err != nil || info == nil || !info.Cone || !reflect.DeepEqual(info.Patterns, []string{"services/api"}) {
+		t.Fatalf("SparseInfo() = %+v, %v", info, err)
+	}
+
+	// A new worktree only gets the sparse paths
+	worktree := filepath.Join(t.TempDir(), "feature")
+	if err := CreateWorktree(repo, worktree, "feature", false); err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(worktree, "services", "api", "main.go")); err != nil {
+		t.Errorf("sparse path missing from the worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(worktree, "web")); !os.IsNotExist(err) {
+		t.Errorf("worktree has web/, which isn't in the sparse checkout: %v", err)
+	}
+
+	if err := SparseAdd(worktree, []string{"services/billing"}); err != nil {
+		t.Fatalf("SparseAdd() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(worktree, "services", "billing", "main.go")); err != nil {
+		t.Errorf("SparseAdd() didn't materialize services/billing: %v", err)
+	}
+	for _, bad := range []string{"../other", "/etc", "--cone", "."} {
+		if err := SparseAdd(worktree, []string{bad}); err == nil {
+			t.Errorf("SparseAdd(%q) should fail", bad)
+		}
+	}
+}
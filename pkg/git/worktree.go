@@ -52,6 +52,15 @@ func IsGitRepo(path string) bool {
 	return cmd.Run() == nil
 }
 
+// FindRoot returns the top-level directory of the working tree containing path
+func FindRoot(path string) (string, error) {
+	output, err := exec.Command("git", "-C", path, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find git root of %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 // GetCurrentBranch returns the current branch name
 func GetCurrentBranch(path string) (string, error) {
 	cmd := exec.Command("git", "-C", path, "branch", "--show-current")
@@ -62,9 +71,9 @@ func GetCurrentBranch(path string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// WorktreeExists checks if a worktree with the given name exists
-func WorktreeExists(worktreeName string) (bool, error) {
-	cmd := exec.Command("git", "worktree", "list", "--porcelain")
+// WorktreeExists checks if the repo at repoPath has a worktree with the given name
+func WorktreeExists(repoPath, worktreeName string) (bool, error) {
+	cmd := exec.Command("git", "-C", repoPath, "worktree", "list", "--porcelain")
 	output, err := cmd.Output()
 	if err != nil {
 		return false, err
@@ -83,9 +92,9 @@ func WorktreeExists(worktreeName string) (bool, error) {
 	return false, nil
 }
 
-// GetWorktreePath gets the actual path of an existing worktree
-func GetWorktreePath(worktreeName string) (string, error) {
-	cmd := exec.Command("git", "worktree", "list", "--porcelain")
+// GetWorktreePath gets the actual path of an existing worktree of the repo at repoPath
+func GetWorktreePath(repoPath, worktreeName string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "worktree", "list", "--porcelain")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", err
@@ -112,26 +121,39 @@ func GetWorktreePath(worktreeName string) (string, error) {
 	return "", fmt.Errorf("worktree '%s' not found", worktreeName)
 }
 
-// CreateWorktree creates a new worktree
-func CreateWorktree(path, branchName string, verbose bool) error {
+// CreateWorktree creates a new worktree of the repo at repoPath
+func CreateWorktree(repoPath, path, branchName string, verbose bool) error {
 	// Check if branch already exists
-	checkCmd := exec.Command("git", "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", branchName))
+	checkCmd := exec.Command("git", "-C", repoPath, "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", branchName))
 	branchExists := checkCmd.Run() == nil
 
+	if sparse, err := SparseInfo(repoPath); err == nil && sparse != nil && len(sparse.Patterns) > 0 {
+		addArgs := []string{path, "-b", branchName}
+		if branchExists {
+			addArgs = []string{path, branchName}
+		}
+		return createSparseWorktree(repoPath, path, addArgs, sparse, verbose)
+	}
+
+	// Checking out runs filters from the repository's config, which earlier sessions could write
 	var cmd *exec.Cmd
+	var err error
 	if branchExists {
 		// Branch exists, check it out in the worktree
-		cmd = exec.Command("git", "worktree", "add", path, branchName)
+		cmd, err = Command(repoPath, "worktree", "add", path, branchName)
 		if verbose {
 			fmt.Fprintf(os.Stderr, "+ git worktree add %s %s\n", path, branchName)
 		}
 	} else {
 		// Branch doesn't exist, create it
-		cmd = exec.Command("git", "worktree", "add", path, "-b", branchName)
+		cmd, err = Command(repoPath, "worktree", "add", path, "-b", branchName)
 		if verbose {
 			fmt.Fprintf(os.Stderr, "+ git worktree add %s -b %s\n", path, branchName)
 		}
 	}
+	if err != nil {
+		return err
+	}
 
 	if verbose {
 		cmd.Stdout = os.Stderr
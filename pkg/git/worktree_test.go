@@ -1,6 +1,9 @@
 package git
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
@@ -53,3 +56,41 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestWorktreesOutsideWorkingDirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	subdir := filepath.Join(repo, "pkg", "api")
+	os.MkdirAll(subdir, 0755)
+
+	root, err := FindRoot(subdir)
+	if err != nil {
+		t.Fatalf("FindRoot() error = %v", err)
+	}
+	if realRepo, _ := filepath.EvalSymlinks(repo); root != realRepo {
+		t.Errorf("FindRoot() = %s, want %s", root, realRepo)
+	}
+
+	// The test process runs elsewhere, so these must not depend on the working directory
+	worktree := filepath.Join(t.TempDir(), "feature")
+	if err := CreateWorktree(repo, worktree, "feature", false); err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	if exists, err := WorktreeExists(repo, "feature"); err != nil || !exists {
+		t.Errorf("WorktreeExists() = %v, %v; want true", exists, err)
+	}
+	path, err := GetWorktreePath(repo, "feature")
+	if realWorktree, _ := filepath.EvalSymlinks(worktree); err != nil || path != realWorktree {
+		t.Errorf("GetWorktreePath() = %s, %v; want %s", path, err, realWorktree)
+	}
+}
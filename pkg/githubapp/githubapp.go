@@ -0,0 +1,248 @@
+package githubapp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// DefaultAPIURL is github.com's REST API
+const DefaultAPIURL = "https://api.github.com"
+
+// DefaultPermissions are what a session gets unless configured: read the repository, nothing else
+var DefaultPermissions = map[string]string{"contents": "read", "metadata": "read"}
+
+// TokenVars are the variables agents and gh read a GitHub token from
+var TokenVars = []string{"GH_TOKEN", "GITHUB_TOKEN"}
+
+// permissionLevels are the access levels GitHub accepts for app permissions
+var permissionLevels = map[string]bool{"read": true, "write": true, "admin": true}
+
+// Enabled reports whether sessions get app tokens
+func Enabled(settings config.GitHubAppConfig) bool {
+	return settings.AppID != 0
+}
+
+// Validate checks the github_app config before anything is minted
+func Validate(settings config.GitHubAppConfig) error {
+	if !Enabled(settings) {
+		return nil
+	}
+	if settings.PrivateKey == "" {
+		return fmt.Errorf("github_app requires private_key")
+	}
+	for name, level := range settings.Permissions {
+		if !permissionLevels[level] {
+			return fmt.Errorf("github_app permission %s: unknown level '%s' (use read, write or admin)", name, level)
+		}
+	}
+	if settings.APIURL != "" {
+		if u, err := url.Parse(settings.APIURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("github_app api_url must be an https URL, got '%s'", settings.APIURL)
+		}
+	}
+	return nil
+}
+
+// APIURL returns the API the app lives on
+func APIURL(settings config.GitHubAppConfig) string {
+	if settings.APIURL == "" {
+		return DefaultAPIURL
+	}
+	return strings.TrimSuffix(settings.APIURL, "/")
+}
+
+// Permissions returns the permissions session tokens are scoped to
+func Permissions(settings config.GitHubAppConfig) map[string]string {
+	if len(settings.Permissions) == 0 {
+		return DefaultPermissions
+	}
+	return settings.Permissions
+}
+
+// Host returns the git host repositories are cloned from, e.g. github.com for the public API
+func Host(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "api.")
+}
+
+// remotePattern matches scp-style (git@host:owner/repo) and URL-style remotes
+var remotePattern = regexp.MustCompile(`^(?:(?:https?|ssh|git)://)?(?:[^@/]+@)?([^/:]+)(?::\d+)?[:/]([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// ParseRemote returns the repository a remote URL points at on host
+func ParseRemote(remoteURL, host string) (owner, repo string, ok bool) {
+	m := remotePattern.FindStringSubmatch(strings.TrimSpace(remoteURL))
+	if m == nil || !strings.EqualFold(m[1], host) {
+		return "", "", false
+	}
+	return m[2], m[3], true
+}
+
+// Token is an installation token and when it stops working
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Client acts as the app: it signs its own JWTs and exchanges them for installation tokens
+type Client struct {
+	apiURL string
+	appID  int64
+	key    *rsa.PrivateKey
+	now    func() time.Time
+}
+
+// NewClient loads the app's private key
+func NewClient(apiURL string, appID int64, keyPath string) (*Client, error) {
+	if rest, ok := strings.CutPrefix(keyPath, "~/"); ok {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			keyPath = filepath.Join(homeDir, rest)
+		}
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key %s: %w", keyPath, err)
+	}
+	return &Client{apiURL: apiURL, appID: appID, key: key, now: time.Now}, nil
+}
+
+// parsePrivateKey reads the PKCS#1 keys GitHub issues, or PKCS#8 conversions of them
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns a short-lived token authenticating as the app itself
+// iat is backdated a minute to allow for clock drift, as GitHub recommends.
+func (c *Client) jwt() (string, error) {
+	now := c.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(c.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(nil, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Installation looks up the app's installation on a repository
+func (c *Client) Installation(owner, repo string) (int64, error) {
+	jwt, err := c.jwt()
+	if err != nil {
+		return 0, err
+	}
+	data, err := do(http.MethodGet, fmt.Sprintf("%s/repos/%s/%s/installation", c.apiURL, url.PathEscape(owner), url.PathEscape(repo)), "Bearer "+jwt, nil)
+	if err != nil {
+		return 0, fmt.Errorf("GitHub App isn't installed on %s/%s: %w", owner, repo, err)
+	}
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &installation); err != nil {
+		return 0, fmt.Errorf("failed to parse installation: %w", err)
+	}
+	return installation.ID, nil
+}
+
+// Mint creates an installation token that only reaches repo, with only permissions
+func (c *Client) Mint(installationID int64, repo string, permissions map[string]string) (Token, error) {
+	jwt, err := c.jwt()
+	if err != nil {
+		return Token{}, err
+	}
+	body, err := json.Marshal(map[string]any{"repositories": []string{repo}, "permissions": permissions})
+	if err != nil {
+		return Token{}, err
+	}
+	data, err := do(http.MethodPost, fmt.Sprintf("%s/app/installations/%d/access_tokens", c.apiURL, installationID), "Bearer "+jwt, body)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to mint GitHub App token: %w", err)
+	}
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil || token.Token == "" {
+		return Token{}, fmt.Errorf("failed to parse GitHub App token response")
+	}
+	return token, nil
+}
+
+// Revoke invalidates an installation token before it expires
+func Revoke(apiURL, token string) error {
+	if _, err := do(http.MethodDelete, apiURL+"/installation/token", "token "+token, nil); err != nil {
+		return fmt.Errorf("failed to revoke GitHub App token: %w", err)
+	}
+	return nil
+}
+
+// do sends an authenticated API request and returns the response body
+func do(method, target, authorization string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach GitHub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("GitHub returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
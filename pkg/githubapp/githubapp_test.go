@@ -0,0 +1,228 @@
+package githubapp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.GitHubAppConfig
+		wantErr  bool
+	}{
+		{"disabled", config.GitHubAppConfig{}, false},
+		{"defaults", config.GitHubAppConfig{AppID: 42, PrivateKey: "~/app.pem"}, false},
+		{"write access", config.GitHubAppConfig{AppID: 42, PrivateKey: "app.pem", Permissions: map[string]string{"contents": "write"}}, false},
+		{"no key", config.GitHubAppConfig{AppID: 42}, true},
+		{"bad level", config.GitHubAppConfig{AppID: 42, PrivateKey: "app.pem", Permissions: map[string]string{"contents": "all"}}, true},
+		{"plain http api", config.GitHubAppConfig{AppID: 42, PrivateKey: "app.pem", APIURL: "http://ghe.example.com/api/v3"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.settings); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote, host string
+		owner, repo  string
+		ok           bool
+	}{
+		{"git@github.com:obra/packnplay.git", "github.com", "obra", "packnplay", true},
+		{"https://github.com/obra/packnplay", "github.com", "obra", "packnplay", true},
+		{"https://user@github.com/obra/packnplay.git/", "github.com", "obra", "packnplay", true},
+		{"ssh://git@ghe.example.com:2222/team/app.git", "ghe.example.com", "team", "app", true},
+		{"git@gitlab.com:obra/packnplay.git", "github.com", "", "", false},
+		{"https://github.com/obra/packnplay/tree/main", "github.com", "", "", false},
+		{"", "github.com", "", "", false},
+	}
+	for _, tt := range tests {
+		owner, repo, ok := ParseRemote(tt.remote, tt.host)
+		if owner != tt.owner || repo != tt.repo || ok != tt.ok {
+			t.Errorf("ParseRemote(%q) = %q, %q, %v; want %q, %q, %v", tt.remote, owner, repo, ok, tt.owner, tt.repo, tt.ok)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	if got := Host(DefaultAPIURL); got != "github.com" {
+		t.Errorf("Host(public) = %s", got)
+	}
+	if got := Host("https://ghe.example.com/api/v3"); got != "ghe.example.com" {
+		t.Errorf("Host(enterprise) = %s", got)
+	}
+}
+
+// writeKey writes a fresh app key and returns its path
+func writeKey(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, pemData, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+// verifyJWT checks an app JWT's signature and returns its claims
+func verifyJWT(t *testing.T, token string, key *rsa.PrivateKey) map[string]any {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts", len(parts))
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("JWT signature doesn't verify: %v", err)
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]any
+	json.Unmarshal(payload, &claims)
+	return claims
+}
+
+// fakeGitHub serves the installation lookup, token and revocation endpoints
+func fakeGitHub(t *testing.T, key *rsa.PrivateKey, requests *[]string) *httptest.Server {
+	t.Helper()
+	minted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*requests = append(*requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+		auth := r.Header.Get("Authorization")
+
+		switch {
+		case r.Method == http.MethodDelete && r.URL.Path == "/installation/token":
+			if !strings.HasPrefix(auth, "token ghs_") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/repos/obra/packnplay/installation":
+			if claims := verifyJWT(t, strings.TrimPrefix(auth, "Bearer "), key); claims["iss"] != "42" {
+				t.Errorf("JWT iss = %v, want the app ID", claims["iss"])
+			}
+			w.Write([]byte(`{"id": 7}`))
+		case r.URL.Path == "/app/installations/7/access_tokens":
+			verifyJWT(t, strings.TrimPrefix(auth, "Bearer "), key)
+			minted++
+			json.NewEncoder(w).Encode(Token{Token: "ghs_token" + string(rune('0'+minted)), ExpiresAt: time.Now().Add(time.Hour).UTC()})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMintRefreshRevoke(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv(secrets.StoreEnv, secrets.StoreFile)
+	keyPath, key := writeKey(t)
+	var requests []string
+	server := fakeGitHub(t, key, &requests)
+
+	client, err := NewClient(server.URL, 42, keyPath)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	installationID, err := client.Installation("obra", "packnplay")
+	if err != nil || installationID != 7 {
+		t.Fatalf("Installation() = %d, %v", installationID, err)
+	}
+	token, err := client.Mint(installationID, "packnplay", DefaultPermissions)
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+	if want := `POST /app/installations/7/access_tokens {"permissions":{"contents":"read","metadata":"read"},"repositories":["packnplay"]}`; requests[1] != want {
+		t.Errorf("mint request = %s\nwant %s", requests[1], want)
+	}
+
+	// A fake runtime that keeps what's published to the container
+	published := filepath.Join(t.TempDir(), "published")
+	runtime := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(runtime, []byte("#!/bin/sh\ncat > "+published+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &State{APIURL: server.URL, AppID: 42, PrivateKey: keyPath, InstallationID: 7, Owner: "obra", Repo: "packnplay", Permissions: DefaultPermissions, Token: token}
+	if err := Save("packnplay-app-main", state); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if data, _ := os.ReadFile(getStatePath("packnplay-app-main")); strings.Contains(string(data), "ghs_token1") {
+		t.Error("state file holds the token; it belongs in the secret store")
+	}
+	if _, err := os.Stat(legacyTokenDir("packnplay-app-main")); !os.IsNotExist(err) {
+		t.Error("Save() wrote the token to the host; it's only published into the container")
+	}
+	if loaded, err := Load("packnplay-app-main"); err != nil || loaded.Token.Token != "ghs_token1" {
+		t.Errorf("Load() token = %v, %v; want ghs_token1 from the secret store", loaded, err)
+	}
+	if state.NeedsRefresh(time.Now()) || !state.NeedsRefresh(time.Now().Add(55*time.Minute)) {
+		t.Error("NeedsRefresh() should be false for a new token and true near expiry")
+	}
+
+	dockerClient := docker.NewClientWithExecutor(runtime, docker.DefaultExecutor(runtime), false)
+	if err := Refresh(dockerClient, "packnplay-app-main"); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if data, _ := os.ReadFile(published); string(data) != "ghs_token2" {
+		t.Errorf("published token after refresh = %q", data)
+	}
+
+	if err := RevokeSession("packnplay-app-main"); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if last := requests[len(requests)-1]; last != "DELETE /installation/token" {
+		t.Errorf("last request = %s, want a revocation", last)
+	}
+	if state, _ := Load("packnplay-app-main"); state != nil {
+		t.Error("state kept after revocation")
+	}
+}
+
+func TestMintNotInstalled(t *testing.T) {
+	keyPath, key := writeKey(t)
+	var requests []string
+	server := fakeGitHub(t, key, &requests)
+
+	client, err := NewClient(server.URL, 42, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Installation("obra", "elsewhere"); err == nil || !strings.Contains(err.Error(), "isn't installed on obra/elsewhere") {
+		t.Errorf("Installation() = %v, want a not-installed error", err)
+	}
+	if !reflect.DeepEqual(requests, []string{"GET /repos/obra/elsewhere/installation"}) {
+		t.Errorf("requests = %v", requests)
+	}
+}
@@ -0,0 +1,162 @@
+package githubapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+// ContainerTokenDir is the tmpfs in the container holding a session's token
+// The token file is replaced as it's refreshed, so git's credential helper always reads a live one.
+const ContainerTokenDir = "/run/packnplay-github"
+
+// publishScript replaces the token file with stdin, renaming it into place so the
+// credential helper never reads a half-written token
+var publishScript = fmt.Sprintf("umask 022 && cat > %[1]s/token.tmp && mv -f %[1]s/token.tmp %[1]s/token", ContainerTokenDir)
+
+// refreshBefore is how long before expiry a session's token is replaced (they last an hour)
+const refreshBefore = 10 * time.Minute
+
+// State is what's kept on the host to refresh and revoke a session's token
+type State struct {
+	APIURL         string            `json:"api_url"`
+	AppID          int64             `json:"app_id"`
+	PrivateKey     string            `json:"private_key"`
+	InstallationID int64             `json:"installation_id"`
+	Owner          string            `json:"owner"`
+	Repo           string            `json:"repo"`
+	Permissions    map[string]string `json:"permissions"`
+	Token
+}
+
+func getStateDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "github")
+}
+
+func getStatePath(containerName string) string {
+	return filepath.Join(getStateDir(), containerName+".json")
+}
+
+// tokenSecret names the secret holding packnplay's copy of a session's token
+func tokenSecret(containerName string) string {
+	return "github-token." + containerName
+}
+
+// legacyTokenDir is the host directory older versions published tokens to in the clear
+func legacyTokenDir(containerName string) string {
+	return filepath.Join(getStateDir(), containerName)
+}
+
+// Save records a session's token
+func Save(containerName string, state *State) error {
+	if err := os.MkdirAll(getStateDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create GitHub token dir: %w", err)
+	}
+	// The token goes in the OS keychain; the state file only says how to refresh and revoke it
+	if err := secrets.Set(tokenSecret(containerName), state.Token.Token); err != nil {
+		return err
+	}
+	stored := *state
+	stored.Token.Token = ""
+	data, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal GitHub token state: %w", err)
+	}
+	if err := os.WriteFile(getStatePath(containerName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write GitHub token state: %w", err)
+	}
+	return nil
+}
+
+// Publish writes a session's token to the tmpfs at ContainerTokenDir, passing it on stdin, so
+// it's never on the host's disk or a command line. It runs as root, since the tmpfs is root's;
+// the file is readable by the container user, whose uid needn't match ours.
+func Publish(dockerClient *docker.Client, containerName, token string) error {
+	cmd := dockerClient.Stream("exec", "-i", "-u", "0", containerName, "sh", "-c", publishScript)
+	cmd.Stdin = strings.NewReader(token)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to publish GitHub token to %s: %w: %s", containerName, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Load returns a session's token state (nil if it has none)
+func Load(containerName string) (*State, error) {
+	data, err := os.ReadFile(getStatePath(containerName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub token state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub token state: %w", err)
+	}
+	// State written by older versions still has the token in it
+	if state.Token.Token == "" {
+		if state.Token.Token, err = secrets.Get(tokenSecret(containerName)); err != nil {
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+// NeedsRefresh reports whether a token is close enough to expiry to replace
+func (s *State) NeedsRefresh(now time.Time) bool {
+	return now.Add(refreshBefore).After(s.ExpiresAt)
+}
+
+// Refresh replaces a session's token with a fresh one of the same scope, publishing it to the
+// running container. The old token is left to expire, since git may be mid-operation with it.
+func Refresh(dockerClient *docker.Client, containerName string) error {
+	state, err := Load(containerName)
+	if err != nil || state == nil {
+		return err
+	}
+	client, err := NewClient(state.APIURL, state.AppID, state.PrivateKey)
+	if err != nil {
+		return err
+	}
+	token, err := client.Mint(state.InstallationID, state.Repo, state.Permissions)
+	if err != nil {
+		return err
+	}
+	state.Token = token
+	if err := Save(containerName, state); err != nil {
+		return err
+	}
+	return Publish(dockerClient, containerName, token.Token)
+}
+
+// RevokeSession revokes a session's token and removes its state
+// A token that fails to revoke stays recorded, so a later stop retries it.
+func RevokeSession(containerName string) error {
+	state, err := Load(containerName)
+	if err != nil || state == nil {
+		return err
+	}
+	if time.Now().Before(state.ExpiresAt) {
+		if err := Revoke(state.APIURL, state.Token.Token); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(legacyTokenDir(containerName)); err != nil {
+		return fmt.Errorf("failed to remove GitHub token dir: %w", err)
+	}
+	if err := os.Remove(getStatePath(containerName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove GitHub token state: %w", err)
+	}
+	return secrets.Delete(tokenSecret(containerName))
+}
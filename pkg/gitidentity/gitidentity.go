@@ -0,0 +1,145 @@
+package gitidentity
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// ContainerDir is where a session's git hooks are mounted
+const ContainerDir = "/run/packnplay-git"
+
+// SessionTrailer names the session a commit was made in
+const SessionTrailer = "Packnplay-Session"
+
+// Defaults for the session's git author
+const (
+	DefaultName  = "{agent} via packnplay"
+	DefaultEmail = "noreply@packnplay.invalid"
+)
+
+// hookNames are the client-side hooks wrapped, so a repository's own hooks still run
+var hookNames = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch",
+	"pre-commit", "pre-merge-commit", "prepare-commit-msg", "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push", "post-rewrite", "pre-auto-gc",
+}
+
+// Author returns the name and email agent commits are made under
+func Author(settings config.GitIdentityConfig, agent string) (string, string) {
+	if agent == "" {
+		agent = "agent"
+	}
+	name := settings.Name
+	if name == "" {
+		name = DefaultName
+	}
+	email := settings.Email
+	if email == "" {
+		email = DefaultEmail
+	}
+	return strings.ReplaceAll(name, "{agent}", agent), email
+}
+
+// Trailers returns the trailers added to every commit in a session
+// coAuthor is the person behind the session, e.g. "Ada Lovelace <ada@example.com>"; "" for none.
+func Trailers(sessionID, coAuthor string) []string {
+	trailers := []string{SessionTrailer + ": " + sessionID}
+	if coAuthor != "" {
+		trailers = append(trailers, "Co-authored-by: "+coAuthor)
+	}
+	return trailers
+}
+
+// HostIdentity returns the host user's git identity as "Name <email>", or "" if it isn't set
+func HostIdentity() string {
+	name, _ := exec.Command("git", "config", "--get", "user.name").Output()
+	email, _ := exec.Command("git", "config", "--get", "user.email").Output()
+	if strings.TrimSpace(string(name)) == "" || strings.TrimSpace(string(email)) == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s <%s>", strings.TrimSpace(string(name)), strings.TrimSpace(string(email)))
+}
+
+// EnvArgs returns docker args that make git in the session commit as name and email
+func EnvArgs(name, email string) []string {
+	var args []string
+	for _, env := range []string{
+		"GIT_AUTHOR_NAME=" + name,
+		"GIT_AUTHOR_EMAIL=" + email,
+		"GIT_COMMITTER_NAME=" + name,
+		"GIT_COMMITTER_EMAIL=" + email,
+	} {
+		args = append(args, "-e", env)
+	}
+	return args
+}
+
+// GitConfig returns the git config entries ("key=value") that run the session's hooks
+// They're meant for git's environment config, so no config file in the workspace or home
+// is touched.
+func GitConfig() []string {
+	return []string{"core.hooksPath=" + ContainerDir + "/hooks"}
+}
+
+// HookScript returns the wrapper installed as a hook: it runs the repository's own hook of
+// that name, from its core.hooksPath or .git/hooks, and commit-msg then adds the trailers
+func HookScript(name string, trailers []string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# packnplay session hook; runs the repository's own " + name + " hook first\n")
+	b.WriteString(`hooks=$(env -u GIT_CONFIG_COUNT git config --get core.hooksPath)` + "\n")
+	b.WriteString(`[ -n "$hooks" ] || hooks="$(git rev-parse --git-common-dir)/hooks"` + "\n")
+	if name != "commit-msg" {
+		fmt.Fprintf(&b, "[ -x \"$hooks/%s\" ] || exit 0\nexec \"$hooks/%s\" \"$@\"\n", name, name)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "if [ -x \"$hooks/%s\" ]; then \"$hooks/%s\" \"$@\" || exit $?; fi\n", name, name)
+	b.WriteString("exec git interpret-trailers --in-place --if-exists addIfDifferent")
+	for _, trailer := range trailers {
+		b.WriteString(" --trailer " + shellQuote(trailer))
+	}
+	b.WriteString(` "$1"` + "\n")
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Dir returns the host directory mounted at ContainerDir
+func Dir(containerName string) string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "git-identity", containerName)
+}
+
+// Prepare writes a session's hooks and returns the directory to mount
+func Prepare(containerName string, trailers []string) (string, error) {
+	hooksDir := filepath.Join(Dir(containerName), "hooks")
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create git hooks dir: %w", err)
+	}
+	for _, name := range hookNames {
+		// Readable and executable by the container user, whose uid needn't match ours
+		if err := os.WriteFile(filepath.Join(hooksDir, name), []byte(HookScript(name, trailers)), 0755); err != nil {
+			return "", fmt.Errorf("failed to write git hook: %w", err)
+		}
+	}
+	return Dir(containerName), nil
+}
+
+// Forget removes a session's hooks once its container is gone
+func Forget(containerName string) error {
+	if err := os.RemoveAll(Dir(containerName)); err != nil {
+		return fmt.Errorf("failed to remove session git hooks: %w", err)
+	}
+	return nil
+}
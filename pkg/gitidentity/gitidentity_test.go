@@ -0,0 +1,74 @@
+package gitidentity
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestAuthor(t *testing.T) {
+	name, email := Author(config.GitIdentityConfig{Enabled: true}, "claude")
+	if name != "claude via packnplay" || email != DefaultEmail {
+		t.Errorf("Author() = %s <%s>", name, email)
+	}
+	name, email = Author(config.GitIdentityConfig{Name: "{agent}-bot", Email: "bots@example.com"}, "")
+	if name != "agent-bot" || email != "bots@example.com" {
+		t.Errorf("Author() = %s <%s>", name, email)
+	}
+}
+
+func TestHooksAddTrailers(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	repo := t.TempDir()
+
+	dir, err := Prepare("packnplay-app-main", Trailers("0123456789abcdef", "Ada Lovelace <ada@example.com>"))
+	if err != nil {
+		t.Fatalf("Prepare() error: %v", err)
+	}
+	env := append(os.Environ(),
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_AUTHOR_NAME=claude via packnplay", "GIT_AUTHOR_EMAIL="+DefaultEmail,
+		"GIT_COMMITTER_NAME=claude via packnplay", "GIT_COMMITTER_EMAIL="+DefaultEmail,
+		// Another feature's entry comes first, as when a GitHub App token is in use
+		"GIT_CONFIG_COUNT=2", "GIT_CONFIG_KEY_0=credential.https://github.com.helper", "GIT_CONFIG_VALUE_0=",
+		"GIT_CONFIG_KEY_1=core.hooksPath", "GIT_CONFIG_VALUE_1="+filepath.Join(dir, "hooks"))
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	git("init", "-q", "-b", "main")
+
+	// The repository's own hook still runs, before the trailers are added
+	os.WriteFile(filepath.Join(repo, ".git", "hooks", "commit-msg"), []byte("#!/bin/sh\necho 'Reviewed-by: lint' >> \"$1\"\n"), 0755)
+	git("commit", "-q", "--allow-empty", "-m", "Add feature")
+	git("commit", "-q", "--amend", "--allow-empty", "--no-edit")
+
+	message := git("log", "-1", "--format=%an <%ae>%n%B")
+	for _, want := range []string{"claude via packnplay <" + DefaultEmail + ">", "Packnplay-Session: 0123456789abcdef", "Co-authored-by: Ada Lovelace <ada@example.com>", "Reviewed-by: lint"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("commit missing %q:\n%s", want, message)
+		}
+	}
+	if n := strings.Count(message, SessionTrailer); n != 1 {
+		t.Errorf("amending added the session trailer again (%d times):\n%s", n, message)
+	}
+
+	if err := Forget("packnplay-app-main"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Forget() left %s", dir)
+	}
+}
@@ -0,0 +1,125 @@
+package hostopen
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mount is a container mount as reported by docker inspect
+type Mount struct {
+	Type        string `json:"Type"`
+	Name        string `json:"Name"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+}
+
+// locationPattern matches the :line or :line:column suffix compilers and agents print
+var locationPattern = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?:?$`)
+
+// SplitLocation separates a trailing :line[:column] from a path, returning 0 if there is none
+func SplitLocation(arg string) (string, int) {
+	match := locationPattern.FindStringSubmatch(arg)
+	if match == nil {
+		return arg, 0
+	}
+	line, err := strconv.Atoi(match[2])
+	if err != nil {
+		return arg, 0
+	}
+	return match[1], line
+}
+
+// HostPath maps a path inside the container to the host path behind it
+// Relative paths are taken from workdir. The deepest mount containing the path wins,
+// as it does in the container.
+func HostPath(mounts []Mount, containerPath, workdir string) (string, error) {
+	if !path.IsAbs(containerPath) {
+		if workdir == "" {
+			workdir = "/"
+		}
+		containerPath = path.Join(workdir, containerPath)
+	}
+	containerPath = path.Clean(containerPath)
+
+	var best *Mount
+	var rest string
+	for i := range mounts {
+		dest := path.Clean(mounts[i].Destination)
+		relative, ok := within(containerPath, dest)
+		if !ok {
+			continue
+		}
+		if best == nil || len(dest) > len(path.Clean(best.Destination)) {
+			best, rest = &mounts[i], relative
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("%s is not on a host mount; it only exists inside the container", containerPath)
+	}
+	if best.Type != "bind" {
+		return "", fmt.Errorf("%s is in %s volume %s, not on the host", containerPath, best.Type, best.Name)
+	}
+	return filepath.Join(best.Source, filepath.FromSlash(rest)), nil
+}
+
+// within returns p relative to dir if p is dir or below it
+func within(p, dir string) (string, bool) {
+	if p == dir {
+		return "", true
+	}
+	if dir == "/" {
+		return strings.TrimPrefix(p, "/"), true
+	}
+	if strings.HasPrefix(p, dir+"/") {
+		return p[len(dir)+1:], true
+	}
+	return "", false
+}
+
+// CheckOpenable refuses paths that opening could run rather than show
+// Directories are never opened. Without an editor the desktop's default handler picks the
+// application, and for an executable (or an app bundle) that means launching it, so
+// executables are only opened in an editor.
+func CheckOpenable(info fs.FileInfo, editor string) error {
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory; give a file to open", info.Name())
+	}
+	if strings.TrimSpace(editor) == "" && info.Mode()&0111 != 0 {
+		return fmt.Errorf("%s is executable; set $VISUAL or $EDITOR to open it in an editor", info.Name())
+	}
+	return nil
+}
+
+// EditorCommand builds the command that opens a file at a line in editor
+// editor is $VISUAL or $EDITOR and may carry arguments; without one the file goes to the
+// desktop's default handler (the default text editor on macOS). Editors whose line syntax
+// isn't known get the file alone. Check the file with CheckOpenable first.
+func EditorCommand(editor, file string, line int, goos string) []string {
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		if goos == "darwin" {
+			return []string{"open", "-t", file}
+		}
+		return []string{"xdg-open", file}
+	}
+	if line <= 0 {
+		return append(fields, file)
+	}
+
+	switch strings.TrimSuffix(filepath.Base(fields[0]), ".exe") {
+	case "code", "code-insiders", "codium", "cursor", "windsurf":
+		return append(fields, "-g", fmt.Sprintf("%s:%d", file, line))
+	case "subl", "zed", "hx", "helix":
+		return append(fields, fmt.Sprintf("%s:%d", file, line))
+	case "vi", "vim", "nvim", "gvim", "mvim", "emacs", "emacsclient", "nano", "micro", "kak":
+		return append(fields, fmt.Sprintf("+%d", line), file)
+	case "idea", "goland", "pycharm", "webstorm":
+		return append(fields, "--line", strconv.Itoa(line), file)
+	}
+	return append(fields, file)
+}
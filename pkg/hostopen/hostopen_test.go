@@ -0,0 +1,117 @@
+package hostopen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitLocation(t *testing.T) {
+	tests := []struct {
+		arg  string
+		path string
+		line int
+	}{
+		{"src/main.go", "src/main.go", 0},
+		{"src/main.go:42", "src/main.go", 42},
+		{"src/main.go:42:7", "src/main.go", 42},
+		{"src/main.go:42:", "src/main.go", 42},
+		{"/workspace/notes:draft.md", "/workspace/notes:draft.md", 0},
+	}
+	for _, tt := range tests {
+		path, line := SplitLocation(tt.arg)
+		if path != tt.path || line != tt.line {
+			t.Errorf("SplitLocation(%q) = %q, %d; want %q, %d", tt.arg, path, line, tt.path, tt.line)
+		}
+	}
+}
+
+func TestHostPath(t *testing.T) {
+	mounts := []Mount{
+		{Type: "bind", Source: "/Users/me/src/app", Destination: "/workspace"},
+		{Type: "bind", Source: "/Users/me/src/app/.git", Destination: "/workspace/.git"},
+		{Type: "bind", Source: "/Users/me/.claude", Destination: "/home/vscode/.claude"},
+		{Type: "volume", Name: "packnplay-cache-npm", Source: "/var/lib/docker/volumes/packnplay-cache-npm/_data", Destination: "/home/vscode/.npm"},
+	}
+	tests := []struct {
+		path    string
+		workdir string
+		want    string
+		wantErr string
+	}{
+		{"/workspace/src/main.go", "/workspace", "/Users/me/src/app/src/main.go", ""},
+		{"src/main.go", "/workspace", "/Users/me/src/app/src/main.go", ""},
+		{"../workspace/./README.md", "/workspace", "/Users/me/src/app/README.md", ""},
+		{"/workspace", "/", "/Users/me/src/app", ""},
+		{"/workspace/.git/config", "/", "/Users/me/src/app/.git/config", ""},
+		{"/home/vscode/.claude/settings.json", "/", "/Users/me/.claude/settings.json", ""},
+		{"/workspacefoo/x", "/", "", "not on a host mount"},
+		{"/home/vscode/.npm/_cacache/index", "/", "", "volume packnplay-cache-npm"},
+		{"/tmp/out.log", "/workspace", "", "not on a host mount"},
+	}
+	for _, tt := range tests {
+		got, err := HostPath(mounts, tt.path, tt.workdir)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("HostPath(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("HostPath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	tests := []struct {
+		editor string
+		line   int
+		goos   string
+		want   []string
+	}{
+		{"", 10, "darwin", []string{"open", "-t", "/a/b.go"}},
+		{"", 10, "linux", []string{"xdg-open", "/a/b.go"}},
+		{"code --wait", 10, "linux", []string{"code", "--wait", "-g", "/a/b.go:10"}},
+		{"/usr/local/bin/nvim", 10, "linux", []string{"/usr/local/bin/nvim", "+10", "/a/b.go"}},
+		{"subl -n", 10, "darwin", []string{"subl", "-n", "/a/b.go:10"}},
+		{"vim", 0, "linux", []string{"vim", "/a/b.go"}},
+		{"ed", 10, "linux", []string{"ed", "/a/b.go"}},
+	}
+	for _, tt := range tests {
+		if got := EditorCommand(tt.editor, "/a/b.go", tt.line, tt.goos); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("EditorCommand(%q, %d) = %v, want %v", tt.editor, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOpenable(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	script := filepath.Join(dir, "run.sh")
+	os.WriteFile(file, []byte("package main\n"), 0644)
+	os.WriteFile(script, []byte("#!/bin/sh\n"), 0755)
+
+	tests := []struct {
+		path   string
+		editor string
+		ok     bool
+	}{
+		{file, "", true},
+		{script, "vim", true},
+		{script, "", false},
+		{dir, "vim", false},
+		{dir, "", false},
+	}
+	for _, tt := range tests {
+		info, err := os.Stat(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckOpenable(info, tt.editor); (err == nil) != tt.ok {
+			t.Errorf("CheckOpenable(%s, %q) = %v, want ok %v", filepath.Base(tt.path), tt.editor, err, tt.ok)
+		}
+	}
+}
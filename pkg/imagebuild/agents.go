@@ -0,0 +1,278 @@
+package imagebuild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/lockfile"
+)
+
+// Labels recorded on agent layer images; containers inherit them, so they double as session metadata
+const (
+	BaseImageLabel   = "packnplay.base-image"
+	BaseIDLabel      = "packnplay.base-id" // ID of the exact base image the layer was built on
+	AgentLabelPrefix = "packnplay.agent."
+	// exact npm version of each agent package in the layer, checked against .packnplay.lock
+	PackageLabelPrefix = "packnplay.agent-package."
+)
+
+// AgentImageName returns the tag of a project's agent layer image
+func AgentImageName(projectName string) string {
+	return fmt.Sprintf("packnplay-%s-agents:latest", projectName)
+}
+
+// AgentInstall is one agent CLI to install into the layer
+type AgentInstall struct {
+	Name    string
+	Spec    agents.InstallSpec
+	Version string // npm version or range; empty means latest
+}
+
+// ParsePin parses an agent pin like "claude@1.x"
+func ParsePin(spec string) (string, string, error) {
+	name, version, ok := strings.Cut(spec, "@")
+	if !ok || name == "" || version == "" {
+		return "", "", fmt.Errorf("invalid agent pin '%s' (expected name@version, e.g. claude@1.x)", spec)
+	}
+	return name, version, nil
+}
+
+// ResolveInstalls decides how to install each agent, applying version pins
+// Agents pinned to a version must be npm-installed, since install scripts always fetch the latest release.
+func ResolveInstalls(names []string, pins map[string]string) ([]AgentInstall, error) {
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for name := range pins {
+		wanted[name] = true
+	}
+
+	var installs []AgentInstall
+	for _, agent := range agents.GetSupportedAgents() {
+		if !wanted[agent.Name()] {
+			continue
+		}
+		delete(wanted, agent.Name())
+
+		installable, ok := agent.(agents.Installable)
+		if !ok {
+			if pins[agent.Name()] != "" {
+				return nil, fmt.Errorf("agent '%s' has no known installer, so it can't be pinned", agent.Name())
+			}
+			continue
+		}
+
+		spec := installable.Install()
+		version := pins[agent.Name()]
+		if version != "" && spec.NPMPackage == "" {
+			return nil, fmt.Errorf("agent '%s' is installed by script and can't be pinned to %s", agent.Name(), version)
+		}
+		installs = append(installs, AgentInstall{Name: agent.Name(), Spec: spec, Version: version})
+	}
+
+	if len(wanted) > 0 {
+		unknown := make([]string, 0, len(wanted))
+		for name := range wanted {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown agent(s): %s", strings.Join(unknown, ", "))
+	}
+	return installs, nil
+}
+
+// GenerateAgentDockerfile layers the agent CLIs on top of baseImage
+func GenerateAgentDockerfile(baseImage, user string, installs []AgentInstall) string {
+	var packages, scripts []string
+	for _, install := range installs {
+		if install.Spec.NPMPackage != "" {
+			version := install.Version
+			if version == "" {
+				version = "latest"
+			}
+			packages = append(packages, install.Spec.NPMPackage+"@"+version)
+		} else if install.Spec.Script != "" {
+			scripts = append(scripts, install.Spec.Script)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	if len(packages) > 0 {
+		b.WriteString("USER root\n")
+		fmt.Fprintf(&b, "RUN npm install -g %s\n", strings.Join(packages, " "))
+	}
+	if user != "" {
+		fmt.Fprintf(&b, "USER %s\n", user)
+	}
+	for _, script := range scripts {
+		fmt.Fprintf(&b, "RUN %s\n", script)
+	}
+	return b.String()
+}
+
+// labelDockerfile records the base image, its ID, detected agent versions and installed
+// package versions as image labels
+func labelDockerfile(image, baseImage, baseID string, versions, packages map[string]string) string {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", image)
+	fmt.Fprintf(&b, "LABEL %s=%s", strconv.Quote(BaseImageLabel), strconv.Quote(baseImage))
+	fmt.Fprintf(&b, " \\\n  %s=%s", strconv.Quote(BaseIDLabel), strconv.Quote(baseID))
+	for _, name := range names {
+		fmt.Fprintf(&b, " \\\n  %s=%s", strconv.Quote(AgentLabelPrefix+name), strconv.Quote(versions[name]))
+	}
+	names = names[:0]
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, " \\\n  %s=%s", strconv.Quote(PackageLabelPrefix+name), strconv.Quote(packages[name]))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// BuildAgentImage builds the agent layer and tags it, returning the installed agent versions
+// and the exact npm version of each agent package. Nothing is cached, so unpinned agents always get their latest release. A base from a
+// registry is re-pulled first; one built locally, like a devcontainer image, has nothing to pull.
+func BuildAgentImage(dockerClient *docker.Client, baseImage, user, tag string, installs []AgentInstall) (map[string]string, map[string]string, error) {
+	buildDir, err := os.MkdirTemp("", "packnplay-agents-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	buildArgs := []string{"--no-cache"}
+	if !isLocalImage(dockerClient, baseImage) {
+		buildArgs = append(buildArgs, "--pull")
+	}
+	stageTag := strings.TrimSuffix(tag, ":latest") + ":build"
+	if err := build(dockerClient, buildDir, GenerateAgentDockerfile(baseImage, user, installs), stageTag, buildArgs...); err != nil {
+		return nil, nil, err
+	}
+	defer dockerClient.Run("rmi", stageTag)
+
+	versions, err := lockfile.DetectAgentVersions(dockerClient, stageTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	packages, err := lockfile.ResolveAgentPackages(dockerClient, stageTag, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	baseID, err := imageID(dockerClient, baseImage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := build(dockerClient, buildDir, labelDockerfile(stageTag, baseImage, baseID, versions, packages), tag); err != nil {
+		return nil, nil, err
+	}
+	return versions, packages, nil
+}
+
+// isLocalImage reports whether an image exists only on this machine, with no registry digest
+func isLocalImage(dockerClient *docker.Client, image string) bool {
+	output, err := dockerClient.Run("image", "inspect", "--format", "{{len .RepoDigests}}", image)
+	return err == nil && strings.TrimSpace(output) == "0"
+}
+
+// imageID returns the ID of an image present locally
+func imageID(dockerClient *docker.Client, image string) (string, error) {
+	output, err := dockerClient.Run("image", "inspect", "--format", "{{.Id}}", image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// build runs docker build with the given Dockerfile content
+func build(dockerClient *docker.Client, dir, dockerfile, tag string, extraArgs ...string) error {
+	dockerfilePath := filepath.Join(dir, "Dockerfile")
+	if err := os.WriteFile(dockerfilePath, []byte(dockerfile), 0644); err != nil {
+		return fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+
+	args := append([]string{"build", "-f", dockerfilePath, "-t", tag}, extraArgs...)
+	args = append(args, dir)
+	output, err := dockerClient.RunWithRetry(docker.DefaultBackoff, args...)
+	if err != nil {
+		return fmt.Errorf("failed to build %s: %w\nDocker output:\n%s", tag, err, output)
+	}
+	return nil
+}
+
+// FindAgentImage returns the project's agent layer image if one was built on top of baseImage
+// The layer must sit on the image baseImage is now, not only share its name: a tag that moved,
+// or a pinned digest, must match the ID recorded at build time. stale reports a layer built on
+// a different base, which is ignored until it's rebuilt.
+func FindAgentImage(dockerClient *docker.Client, projectName, baseImage string) (tag string, stale bool) {
+	tag = AgentImageName(projectName)
+	format := fmt.Sprintf("{{index .Config.Labels %q}}|{{index .Config.Labels %q}}", BaseImageLabel, BaseIDLabel)
+	output, err := dockerClient.Run("image", "inspect", "--format", format, tag)
+	if err != nil {
+		return "", false
+	}
+	builtOn, builtID, _ := strings.Cut(strings.TrimSpace(output), "|")
+	if builtOn != baseImage {
+		return "", true
+	}
+	if id, err := imageID(dockerClient, baseImage); err != nil || id != builtID {
+		return "", true
+	}
+	return tag, false
+}
+
+// InstalledVersions reads the agent versions recorded on an image or container
+func InstalledVersions(dockerClient *docker.Client, ref string) (map[string]string, error) {
+	output, err := dockerClient.Run("inspect", "--format", "{{range $k, $v := .Config.Labels}}{{println $k $v}}{{end}}", ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", ref, err)
+	}
+	return parseAgentLabels(output, AgentLabelPrefix), nil
+}
+
+// InstalledPackages reads the exact agent package versions recorded on an image
+func InstalledPackages(dockerClient *docker.Client, ref string) (map[string]string, error) {
+	output, err := dockerClient.Run("inspect", "--format", "{{range $k, $v := .Config.Labels}}{{println $k $v}}{{end}}", ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", ref, err)
+	}
+	return parseAgentLabels(output, PackageLabelPrefix), nil
+}
+
+// HasPackages reports whether installed includes every locked package at its exact version
+func HasPackages(installed, locked map[string]string) bool {
+	for name, version := range locked {
+		if installed[name] != version {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAgentLabels extracts per-agent values from "key value" label lines
+func parseAgentLabels(output, prefix string) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok && strings.HasPrefix(key, prefix) {
+			versions[strings.TrimPrefix(key, prefix)] = value
+		}
+	}
+	return versions
+}
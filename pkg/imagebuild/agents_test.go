@@ -0,0 +1,142 @@
+package imagebuild
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+func TestParsePin(t *testing.T) {
+	name, version, err := ParsePin("claude@1.x")
+	if err != nil || name != "claude" || version != "1.x" {
+		t.Errorf("ParsePin(claude@1.x) = %q, %q, %v", name, version, err)
+	}
+
+	for _, bad := range []string{"claude", "@1.x", "claude@"} {
+		if _, _, err := ParsePin(bad); err == nil {
+			t.Errorf("ParsePin(%q) should fail", bad)
+		}
+	}
+}
+
+func TestResolveInstalls(t *testing.T) {
+	installs, err := ResolveInstalls([]string{"codex", "cursor"}, map[string]string{"claude": "1.x"})
+	if err != nil {
+		t.Fatalf("ResolveInstalls() error = %v", err)
+	}
+
+	got := make(map[string]AgentInstall)
+	for _, install := range installs {
+		got[install.Name] = install
+	}
+	if len(got) != 3 {
+		t.Fatalf("ResolveInstalls() = %v, want claude, codex, cursor", installs)
+	}
+	if got["claude"].Version != "1.x" || got["claude"].Spec.NPMPackage != "@anthropic-ai/claude-code" {
+		t.Errorf("claude install = %+v", got["claude"])
+	}
+	if got["cursor"].Spec.Script == "" {
+		t.Errorf("cursor should install by script, got %+v", got["cursor"])
+	}
+}
+
+func TestResolveInstalls_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		pins  map[string]string
+	}{
+		{"unknown agent", []string{"nope"}, nil},
+		{"pinned script install", nil, map[string]string{"cursor": "1.0.0"}},
+		{"pinned without installer", nil, map[string]string{"deepseek": "1.0.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ResolveInstalls(tt.names, tt.pins); err == nil {
+				t.Error("ResolveInstalls() should fail")
+			}
+		})
+	}
+}
+
+func TestGenerateAgentDockerfile(t *testing.T) {
+	installs, err := ResolveInstalls([]string{"codex", "cursor"}, map[string]string{"claude": "1.x"})
+	if err != nil {
+		t.Fatalf("ResolveInstalls() error = %v", err)
+	}
+
+	dockerfile := GenerateAgentDockerfile("ghcr.io/obra/packnplay-default:latest", "vscode", installs)
+
+	for _, want := range []string{
+		"FROM ghcr.io/obra/packnplay-default:latest\n",
+		"USER root\nRUN npm install -g @anthropic-ai/claude-code@1.x @openai/codex@latest\n",
+		"USER vscode\nRUN curl -fsSL https://cursor.com/install | bash\n",
+	} {
+		if !strings.Contains(dockerfile, want) {
+			t.Errorf("Dockerfile missing %q:\n%s", want, dockerfile)
+		}
+	}
+}
+
+func TestLabelDockerfileRoundTrip(t *testing.T) {
+	dockerfile := labelDockerfile("stage", "base:latest", "sha256:abc", map[string]string{"claude": `2.0.1 (Claude Code)`}, map[string]string{"claude": "2.0.1"})
+
+	if !strings.Contains(dockerfile, `"packnplay.base-image"="base:latest"`) || !strings.Contains(dockerfile, `"packnplay.base-id"="sha256:abc"`) {
+		t.Errorf("Dockerfile missing base labels:\n%s", dockerfile)
+	}
+	if !strings.Contains(dockerfile, `"packnplay.agent.claude"="2.0.1 (Claude Code)"`) {
+		t.Errorf("Dockerfile missing agent label:\n%s", dockerfile)
+	}
+
+	if !strings.Contains(dockerfile, `"packnplay.agent-package.claude"="2.0.1"`) {
+		t.Errorf("Dockerfile missing package label:\n%s", dockerfile)
+	}
+
+	labels := "packnplay.base-image base:latest\npacknplay.agent.claude 2.0.1 (Claude Code)\npacknplay.agent-package.claude 2.0.1\nmaintainer someone\n"
+	versions := parseAgentLabels(labels, AgentLabelPrefix)
+	if len(versions) != 1 || versions["claude"] != "2.0.1 (Claude Code)" {
+		t.Errorf("parseAgentLabels() = %v", versions)
+	}
+	packages := parseAgentLabels(labels, PackageLabelPrefix)
+	if len(packages) != 1 || packages["claude"] != "2.0.1" {
+		t.Errorf("parseAgentLabels() packages = %v", packages)
+	}
+}
+
+func TestHasPackages(t *testing.T) {
+	installed := map[string]string{"claude": "2.0.1", "codex": "0.5.0"}
+	if !HasPackages(installed, map[string]string{"claude": "2.0.1"}) {
+		t.Error("HasPackages() = false for a locked subset")
+	}
+	if HasPackages(installed, map[string]string{"claude": "2.0.2"}) {
+		t.Error("HasPackages() = true for a different version")
+	}
+	if HasPackages(installed, map[string]string{"gemini": "1.0.0"}) {
+		t.Error("HasPackages() = true for a missing package")
+	}
+}
+
+func TestFindAgentImage(t *testing.T) {
+	replayer, err := docker.NewReplayer(filepath.Join("testdata", "runtime", "agent-image.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := replayer.Client()
+
+	if tag, stale := FindAgentImage(client, "app", "node:20"); tag != "packnplay-app-agents:latest" || stale {
+		t.Errorf("FindAgentImage() on an unchanged base = %q, %v", tag, stale)
+	}
+	// The tag was pulled again since the layer was built
+	if tag, stale := FindAgentImage(client, "app", "node:20"); tag != "" || !stale {
+		t.Errorf("FindAgentImage() on a moved base = %q, %v; want it stale", tag, stale)
+	}
+	if tag, stale := FindAgentImage(client, "app", "node:22"); tag != "" || !stale {
+		t.Errorf("FindAgentImage() on another base = %q, %v; want it stale", tag, stale)
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Error(err)
+	}
+}
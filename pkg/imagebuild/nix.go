@@ -0,0 +1,90 @@
+package imagebuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/nixenv"
+)
+
+// NixKeyLabel records the cache key a Nix layer was built for
+const NixKeyLabel = "packnplay.nix-key"
+
+// nixConf lets the single-user install use flakes without a build sandbox, which
+// needs privileges containers don't have
+const nixConf = `experimental-features = nix-command flakes\nsandbox = false\n`
+
+// NixImageRepository returns the repository holding a project's Nix layers
+func NixImageRepository(projectName string) string {
+	return fmt.Sprintf("packnplay-%s-nix", projectName)
+}
+
+// NixKey hashes the base image, user and kind of dev environment
+// It also names the store volume, which the first session fills from this layer's /nix.
+func NixKey(baseImageID, user, kind string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "base=%s\nuser=%s\nkind=%s\n", baseImageID, user, kind)
+	return hex.EncodeToString(h.Sum(nil))[:setupKeyLength]
+}
+
+// GenerateNixDockerfile installs single-user Nix on top of baseImage unless it already has
+// it, plus devenv for devenv projects. The store is owned by user, and the binaries are
+// linked into /usr/local/bin through the user's profile so they survive profile upgrades.
+func GenerateNixDockerfile(baseImage, user, key, kind string) string {
+	owner := user
+	if owner == "" {
+		owner = "root"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	b.WriteString("USER root\n")
+	b.WriteString("RUN command -v nix >/dev/null || { apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends curl xz-utils ca-certificates && rm -rf /var/lib/apt/lists/*; }\n")
+	fmt.Fprintf(&b, "RUN mkdir -p -m 0755 %s /etc/nix && chown %s %s && printf '%s' >> /etc/nix/nix.conf\n", nixenv.StorePath, quoteAll([]string{owner}), nixenv.StorePath, nixConf)
+	fmt.Fprintf(&b, "USER %s\n", owner)
+	b.WriteString("RUN command -v nix >/dev/null || curl -fsSL https://nixos.org/nix/install | sh -s -- --no-daemon\n")
+	if kind == nixenv.Devenv {
+		b.WriteString("RUN . ~/.nix-profile/etc/profile.d/nix.sh 2>/dev/null; command -v devenv >/dev/null || nix profile install --accept-flake-config nixpkgs#devenv\n")
+	}
+	b.WriteString("USER root\n")
+	fmt.Fprintf(&b, `RUN home="$(getent passwd %s | cut -d: -f6)" && for bin in "$home"/.nix-profile/bin/*; do [ -e "$bin" ] && ln -sf "$bin" /usr/local/bin/; done; true`+"\n", quoteAll([]string{owner}))
+	if user != "" {
+		fmt.Fprintf(&b, "USER %s\n", user)
+	}
+	fmt.Fprintf(&b, "LABEL %s=%s\n", strconv.Quote(NixKeyLabel), strconv.Quote(key))
+	return b.String()
+}
+
+// FindNixImage returns the cached Nix layer for key, or "" if it hasn't been built
+func FindNixImage(dockerClient *docker.Client, projectName, key string) string {
+	tag := NixImageRepository(projectName) + ":" + key
+	if _, err := dockerClient.Run("image", "inspect", tag); err != nil {
+		return ""
+	}
+	return tag
+}
+
+// BuildNixImage builds the Nix layer for key on top of baseImage
+func BuildNixImage(dockerClient *docker.Client, baseImage, user, projectName, key, kind string) (string, error) {
+	buildDir, err := os.MkdirTemp("", "packnplay-nix-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	tag := NixImageRepository(projectName) + ":" + key
+	if err := build(dockerClient, buildDir, GenerateNixDockerfile(baseImage, user, key, kind), tag); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// PruneNixImages removes a project's Nix layers other than keep, returning how many were removed
+func PruneNixImages(dockerClient *docker.Client, projectName, keep string) int {
+	return pruneRepository(dockerClient, NixImageRepository(projectName), keep)
+}
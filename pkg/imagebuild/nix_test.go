@@ -0,0 +1,54 @@
+package imagebuild
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/nixenv"
+)
+
+func TestNixKey(t *testing.T) {
+	key := NixKey("sha256:base", "vscode", nixenv.Flake)
+	if len(key) != setupKeyLength {
+		t.Fatalf("key length = %d, want %d", len(key), setupKeyLength)
+	}
+	if NixKey("sha256:base", "vscode", nixenv.Flake) != key {
+		t.Error("NixKey() should be deterministic")
+	}
+	for _, other := range []string{
+		NixKey("sha256:other", "vscode", nixenv.Flake),
+		NixKey("sha256:base", "node", nixenv.Flake),
+		NixKey("sha256:base", "vscode", nixenv.Devenv),
+	} {
+		if other == key {
+			t.Error("NixKey() should change with the base image, user and kind")
+		}
+	}
+}
+
+func TestGenerateNixDockerfile(t *testing.T) {
+	flake := GenerateNixDockerfile("base:latest", "vscode", "abc123", nixenv.Flake)
+	for _, want := range []string{
+		"FROM base:latest\n",
+		"chown 'vscode' /nix",
+		"experimental-features = nix-command flakes",
+		"command -v nix >/dev/null || curl -fsSL https://nixos.org/nix/install | sh -s -- --no-daemon",
+		`getent passwd 'vscode'`,
+		`LABEL "packnplay.nix-key"="abc123"`,
+	} {
+		if !strings.Contains(flake, want) {
+			t.Errorf("flake Dockerfile missing %q:\n%s", want, flake)
+		}
+	}
+	if strings.Contains(flake, "devenv") {
+		t.Errorf("flake Dockerfile installs devenv:\n%s", flake)
+	}
+	if !strings.HasSuffix(strings.TrimSuffix(flake, "\n"), `"abc123"`) || !strings.Contains(flake, "USER vscode\nLABEL") {
+		t.Errorf("flake Dockerfile should end as the user:\n%s", flake)
+	}
+
+	devenv := GenerateNixDockerfile("base:latest", "vscode", "abc123", nixenv.Devenv)
+	if !strings.Contains(devenv, "nix profile install --accept-flake-config nixpkgs#devenv") {
+		t.Errorf("devenv Dockerfile doesn't install devenv:\n%s", devenv)
+	}
+}
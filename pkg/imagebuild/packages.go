@@ -0,0 +1,136 @@
+package imagebuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// PackagesKeyLabel records the cache key a packages layer was built for
+const PackagesKeyLabel = "packnplay.packages-key"
+
+// PlaywrightBrowsersPath is where Playwright keeps downloaded browsers in images with the
+// browser bundle; it's outside the home directory so a shared cache volume can be mounted there
+const PlaywrightBrowsersPath = "/opt/ms-playwright"
+
+// browserFonts cover scripts and emoji, so screenshots don't render as boxes
+var browserFonts = []string{"fonts-liberation", "fonts-noto-color-emoji", "fonts-noto-cjk"}
+
+// packageNamePattern accepts package names with version specifiers (jq, typescript@5,
+// black==24.4.2, @scope/pkg, libfoo1:amd64) and nothing a shell would interpret
+var packageNamePattern = regexp.MustCompile(`^[A-Za-z0-9@][A-Za-z0-9@._+/:=<>~^*-]*$`)
+
+// PackagesImageRepository returns the repository holding a project's packages layers
+func PackagesImageRepository(projectName string) string {
+	return fmt.Sprintf("packnplay-%s-packages", projectName)
+}
+
+// ValidatePackages rejects package names that could be read as options or shell syntax
+func ValidatePackages(pkgs config.Packages) error {
+	for manager, names := range map[string][]string{"apt": pkgs.Apt, "brew": pkgs.Brew, "npm": pkgs.NPM, "pip": pkgs.Pip} {
+		for _, name := range names {
+			if !packageNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid %s package '%s' in %s", manager, name, config.ProjectConfigFile)
+			}
+		}
+	}
+	return nil
+}
+
+// PackagesKey hashes the base image, user and declared packages
+func PackagesKey(baseImageID, user string, pkgs config.Packages) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "base=%s\nuser=%s\n", baseImageID, user)
+	for _, list := range []struct {
+		manager string
+		names   []string
+	}{{"apt", pkgs.Apt}, {"brew", pkgs.Brew}, {"npm", pkgs.NPM}, {"pip", pkgs.Pip}} {
+		for _, name := range list.names {
+			fmt.Fprintf(h, "%s=%q\n", list.manager, name)
+		}
+	}
+	if pkgs.Browsers {
+		h.Write([]byte("browsers\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:setupKeyLength]
+}
+
+// GeneratePackagesDockerfile installs the packages on top of baseImage, one layer per manager
+// System, npm and pip packages install as root; Homebrew refuses root, so brew runs as user.
+func GeneratePackagesDockerfile(baseImage, user, key string, pkgs config.Packages) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	b.WriteString("USER root\n")
+	if len(pkgs.Apt) > 0 {
+		fmt.Fprintf(&b, "RUN apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*\n", quoteAll(pkgs.Apt))
+	}
+	if pkgs.Browsers {
+		// Playwright knows which libraries its Chromium build needs; the browser itself is
+		// downloaded on first use into PlaywrightBrowsersPath, where the cache volume keeps it
+		b.WriteString("RUN command -v npx >/dev/null || { echo 'packages.browsers needs Node.js in the image' >&2; exit 1; }\n")
+		fmt.Fprintf(&b, "RUN apt-get update && npx -y playwright install-deps chromium && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*\n", quoteAll(browserFonts))
+		fmt.Fprintf(&b, "RUN mkdir -p %s && chmod 1777 %s\n", PlaywrightBrowsersPath, PlaywrightBrowsersPath)
+		fmt.Fprintf(&b, "ENV PLAYWRIGHT_BROWSERS_PATH=%s\n", PlaywrightBrowsersPath)
+		b.WriteString(writableLabel("packages", []string{PlaywrightBrowsersPath}))
+	}
+	if len(pkgs.NPM) > 0 {
+		fmt.Fprintf(&b, "RUN npm install -g %s\n", quoteAll(pkgs.NPM))
+	}
+	if len(pkgs.Pip) > 0 {
+		// Debian-based images mark the system Python as externally managed
+		fmt.Fprintf(&b, "RUN PIP_BREAK_SYSTEM_PACKAGES=1 python3 -m pip install --no-cache-dir %s\n", quoteAll(pkgs.Pip))
+	}
+	if user != "" {
+		fmt.Fprintf(&b, "USER %s\n", user)
+	}
+	if len(pkgs.Brew) > 0 {
+		fmt.Fprintf(&b, "RUN brew install %s\n", quoteAll(pkgs.Brew))
+	}
+	fmt.Fprintf(&b, "LABEL %s=%s\n", strconv.Quote(PackagesKeyLabel), strconv.Quote(key))
+	return b.String()
+}
+
+// quoteAll single-quotes each name for the shell and joins them
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + strings.ReplaceAll(name, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// FindPackagesImage returns the cached packages layer for key, or "" if it hasn't been built
+func FindPackagesImage(dockerClient *docker.Client, projectName, key string) string {
+	tag := PackagesImageRepository(projectName) + ":" + key
+	if _, err := dockerClient.Run("image", "inspect", tag); err != nil {
+		return ""
+	}
+	return tag
+}
+
+// BuildPackagesImage builds the packages layer for key on top of baseImage
+func BuildPackagesImage(dockerClient *docker.Client, baseImage, user, projectName, key string, pkgs config.Packages) (string, error) {
+	buildDir, err := os.MkdirTemp("", "packnplay-packages-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	tag := PackagesImageRepository(projectName) + ":" + key
+	if err := build(dockerClient, buildDir, GeneratePackagesDockerfile(baseImage, user, key, pkgs), tag); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// PrunePackagesImages removes a project's packages layers other than keep, returning how many were removed
+func PrunePackagesImages(dockerClient *docker.Client, projectName, keep string) int {
+	return pruneRepository(dockerClient, PackagesImageRepository(projectName), keep)
+}
@@ -0,0 +1,105 @@
+package imagebuild
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestValidatePackages(t *testing.T) {
+	valid := config.Packages{
+		Apt:  []string{"jq", "protobuf-compiler", "libssl3:amd64", "g++"},
+		NPM:  []string{"typescript@5", "@scope/tool@^1.2"},
+		Pip:  []string{"black==24.4.2", "ruff>=0.4", "requests~=2.31"},
+		Brew: []string{"hashicorp/tap/terraform"},
+	}
+	if err := ValidatePackages(valid); err != nil {
+		t.Errorf("ValidatePackages() unexpected error: %v", err)
+	}
+
+	for _, bad := range []string{"--allow-unauthenticated", "jq; curl evil", "$(id)", "a b", ""} {
+		if err := ValidatePackages(config.Packages{Apt: []string{bad}}); err == nil {
+			t.Errorf("ValidatePackages(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPackagesKey(t *testing.T) {
+	pkgs := config.Packages{Apt: []string{"jq"}, NPM: []string{"typescript"}}
+	key := PackagesKey("sha256:base", "vscode", pkgs)
+	if len(key) != setupKeyLength {
+		t.Fatalf("key length = %d, want %d", len(key), setupKeyLength)
+	}
+	if PackagesKey("sha256:base", "vscode", pkgs) != key {
+		t.Error("PackagesKey() should be deterministic")
+	}
+
+	changed := []struct {
+		name string
+		key  string
+	}{
+		{"base image", PackagesKey("sha256:other", "vscode", pkgs)},
+		{"user", PackagesKey("sha256:base", "node", pkgs)},
+		{"packages", PackagesKey("sha256:base", "vscode", config.Packages{Apt: []string{"jq", "protoc"}, NPM: []string{"typescript"}})},
+		{"manager", PackagesKey("sha256:base", "vscode", config.Packages{Pip: []string{"jq"}, NPM: []string{"typescript"}})},
+		{"browsers", PackagesKey("sha256:base", "vscode", config.Packages{Apt: []string{"jq"}, NPM: []string{"typescript"}, Browsers: true})},
+	}
+	for _, c := range changed {
+		if c.key == key {
+			t.Errorf("changing the %s should change the key", c.name)
+		}
+	}
+}
+
+func TestGeneratePackagesDockerfile(t *testing.T) {
+	pkgs := config.Packages{
+		Apt:  []string{"jq"},
+		NPM:  []string{"typescript@5"},
+		Pip:  []string{"ruff>=0.4"},
+		Brew: []string{"gh"},
+	}
+	got := GeneratePackagesDockerfile("ghcr.io/obra/packnplay-default:latest", "vscode", "abc123", pkgs)
+
+	want := []string{
+		"FROM ghcr.io/obra/packnplay-default:latest",
+		"USER root",
+		"apt-get install -y --no-install-recommends 'jq'",
+		"RUN npm install -g 'typescript@5'",
+		"pip install --no-cache-dir 'ruff>=0.4'",
+		"USER vscode",
+		"RUN brew install 'gh'",
+		`LABEL "packnplay.packages-key"="abc123"`,
+	}
+	last := -1
+	for _, line := range want {
+		idx := strings.Index(got, line)
+		if idx == -1 {
+			t.Fatalf("Dockerfile missing %q:\n%s", line, got)
+		}
+		if idx < last {
+			t.Errorf("%q is out of order:\n%s", line, got)
+		}
+		last = idx
+	}
+
+	if strings.Contains(GeneratePackagesDockerfile("img", "vscode", "k", config.Packages{NPM: []string{"x"}}), "apt-get") {
+		t.Error("managers without packages should get no RUN line")
+	}
+}
+
+func TestGeneratePackagesDockerfileBrowsers(t *testing.T) {
+	got := GeneratePackagesDockerfile("img", "vscode", "k", config.Packages{Browsers: true})
+	for _, want := range []string{
+		"npx -y playwright install-deps chromium",
+		"'fonts-noto-color-emoji'",
+		"ENV PLAYWRIGHT_BROWSERS_PATH=/opt/ms-playwright\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Dockerfile missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "playwright install chromium") {
+		t.Errorf("browsers belong in the cache volume, not the image:\n%s", got)
+	}
+}
@@ -0,0 +1,52 @@
+package imagebuild
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// WritableLabelPrefix marks paths an image needs writable when sessions run on a read-only
+// root filesystem, e.g. packnplay.writable.packages=/opt/ms-playwright. Each layer sets its
+// own key, since a LABEL replaces the value a base image set under the same key.
+const WritableLabelPrefix = "packnplay.writable."
+
+// DefaultWritablePaths are the scratch locations every session gets writable
+var DefaultWritablePaths = []string{"/tmp", "/var/tmp", "/run"}
+
+// writableLabel returns the Dockerfile line declaring a layer's writable paths
+func writableLabel(layer string, paths []string) string {
+	return fmt.Sprintf("LABEL %s=%s\n", strconv.Quote(WritableLabelPrefix+layer), strconv.Quote(strings.Join(paths, ",")))
+}
+
+// WritablePaths reads the writable paths every layer of an image declared
+func WritablePaths(dockerClient *docker.Client, image string) ([]string, error) {
+	output, err := dockerClient.Run("image", "inspect", "--format", "{{range $k, $v := .Config.Labels}}{{println $k $v}}{{end}}", image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", image, err)
+	}
+	return parseWritableLabels(output), nil
+}
+
+// parseWritableLabels collects the paths from "key value" label lines, sorted and deduplicated
+func parseWritableLabels(output string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || !strings.HasPrefix(key, WritableLabelPrefix) {
+			continue
+		}
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" && !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,27 @@
+package imagebuild
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestParseWritableLabels(t *testing.T) {
+	output := "packnplay.base-image ubuntu\npacknplay.writable.packages /opt/ms-playwright\npacknplay.writable.app /var/lib/app, /opt/ms-playwright\n\n"
+	want := []string{"/opt/ms-playwright", "/var/lib/app"}
+	if got := parseWritableLabels(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWritableLabels() = %v, want %v", got, want)
+	}
+}
+
+func TestPackagesDeclareBrowserPathWritable(t *testing.T) {
+	dockerfile := GeneratePackagesDockerfile("base", "vscode", "k", config.Packages{Browsers: true})
+	if !strings.Contains(dockerfile, `LABEL "packnplay.writable.packages"="/opt/ms-playwright"`) {
+		t.Errorf("browsers layer doesn't declare its download dir writable:\n%s", dockerfile)
+	}
+	if dockerfile := GeneratePackagesDockerfile("base", "vscode", "k", config.Packages{Apt: []string{"jq"}}); strings.Contains(dockerfile, WritableLabelPrefix) {
+		t.Errorf("apt-only layer declares writable paths:\n%s", dockerfile)
+	}
+}
@@ -0,0 +1,178 @@
+package imagebuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// SetupKeyLabel records the cache key a setup layer was built for
+const SetupKeyLabel = "packnplay.setup-key"
+
+// dependencyLockfiles are the files whose contents decide whether setup must re-run
+var dependencyLockfiles = map[string]bool{
+	"package-lock.json": true, "npm-shrinkwrap.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "bun.lockb": true,
+	"go.sum": true, "Cargo.lock": true, "Gemfile.lock": true, "composer.lock": true,
+	"poetry.lock": true, "uv.lock": true, "Pipfile.lock": true, "requirements.txt": true,
+	"mix.lock": true, "pubspec.lock": true, "Package.resolved": true,
+	"flake.lock": true, "devenv.lock": true,
+}
+
+// lockfileSkipDirs are never searched for lockfiles (vendored or generated trees)
+var lockfileSkipDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "target": true, "dist": true, "build": true, ".venv": true,
+}
+
+// lockfileMaxDepth bounds the search so huge repos don't stall startup
+const lockfileMaxDepth = 3
+
+// setupKeyLength is how much of the key goes into the image tag
+const setupKeyLength = 16
+
+// SetupImageRepository returns the repository holding a project's setup layers
+func SetupImageRepository(projectName string) string {
+	return fmt.Sprintf("packnplay-%s-setup", projectName)
+}
+
+// SetupKey hashes everything that affects the result of running setup: the base image,
+// the command, the user it runs as, and the contents of every dependency lockfile
+func SetupKey(baseImageID, user string, command []string, projectPath string) (string, error) {
+	lockfiles, err := findLockfiles(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "base=%s\nuser=%s\n", baseImageID, user)
+	for _, arg := range command {
+		fmt.Fprintf(h, "arg=%q\n", arg)
+	}
+	for _, rel := range lockfiles {
+		data, err := os.ReadFile(filepath.Join(projectPath, rel))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "lock=%s %s\n", filepath.ToSlash(rel), hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:setupKeyLength], nil
+}
+
+// findLockfiles returns dependency lockfiles under projectPath, relative and sorted
+func findLockfiles(projectPath string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(projectPath, path)
+		if d.IsDir() {
+			if rel != "." && (lockfileSkipDirs[d.Name()] || strings.Count(rel, string(filepath.Separator)) >= lockfileMaxDepth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dependencyLockfiles[d.Name()] {
+			found = append(found, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for lockfiles: %w", err)
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// FindSetupImage returns the cached setup layer for key, or "" if it hasn't been built
+func FindSetupImage(dockerClient *docker.Client, projectName, key string) string {
+	tag := SetupImageRepository(projectName) + ":" + key
+	if _, err := dockerClient.Run("image", "inspect", tag); err != nil {
+		return ""
+	}
+	return tag
+}
+
+// BuildSetupImage runs command in a container of baseImage and commits the result as the
+// setup layer for key. The workspace is mounted so the command can read project files, but
+// since bind mounts aren't committed only changes outside /workspace are cached.
+// runArgs mount anything else the command should use, such as the shared Nix store.
+func BuildSetupImage(dockerClient *docker.Client, baseImage, user, projectName, key, projectPath string, command []string, runArgs ...string) (string, error) {
+	tag := SetupImageRepository(projectName) + ":" + key
+	name := fmt.Sprintf("packnplay-%s-setup-%s", projectName, key)
+	_, _ = dockerClient.Run("rm", "-f", name)
+
+	startArgs := append([]string{"run", "-d", "--name", name, "-v", fmt.Sprintf("%s:/workspace", projectPath)}, runArgs...)
+	output, err := dockerClient.Run(append(startArgs, baseImage, "sleep", "infinity")...)
+	if err != nil {
+		return "", fmt.Errorf("failed to start setup container: %w\nDocker output:\n%s", err, output)
+	}
+	defer dockerClient.Run("rm", "-f", name)
+
+	execArgs := append([]string{"exec", "-u", user, "-w", "/workspace", name}, command...)
+	if output, err := dockerClient.Run(execArgs...); err != nil {
+		return "", fmt.Errorf("postCreateCommand failed: %w\nOutput:\n%s", err, output)
+	}
+
+	if output, err := dockerClient.Run("commit", "--change", fmt.Sprintf("LABEL %s=%s", SetupKeyLabel, key), name, tag); err != nil {
+		return "", fmt.Errorf("failed to commit setup layer: %w\nDocker output:\n%s", err, output)
+	}
+	return tag, nil
+}
+
+// PruneSetupImages removes a project's setup layers other than keep, returning how many were removed
+func PruneSetupImages(dockerClient *docker.Client, projectName, keep string) int {
+	return pruneRepository(dockerClient, SetupImageRepository(projectName), keep)
+}
+
+// pruneRepository removes every tag of repo except keep, returning how many were removed
+func pruneRepository(dockerClient *docker.Client, repo, keep string) int {
+	output, err := dockerClient.Run("images", repo, "--format", "{{.Tag}}")
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	for _, tag := range strings.Fields(output) {
+		if tag == keep || tag == "<none>" {
+			continue
+		}
+		if _, err := dockerClient.Run("rmi", repo+":"+tag); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// ProjectImages lists every layer image built locally for a project, so they can be
+// carried to a machine that can't build them
+func ProjectImages(dockerClient *docker.Client, projectName string) []string {
+	var images []string
+	if _, err := dockerClient.Run("image", "inspect", AgentImageName(projectName)); err == nil {
+		images = append(images, AgentImageName(projectName))
+	}
+	for _, repo := range []string{
+		PackagesImageRepository(projectName),
+		ShellImageRepository(projectName),
+		NixImageRepository(projectName),
+		SetupImageRepository(projectName),
+	} {
+		output, err := dockerClient.Run("images", repo, "--format", "{{.Tag}}")
+		if err != nil {
+			continue
+		}
+		for _, tag := range strings.Fields(output) {
+			if tag != "<none>" {
+				images = append(images, repo+":"+tag)
+			}
+		}
+	}
+	return images
+}
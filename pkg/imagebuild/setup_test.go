@@ -0,0 +1,79 @@
+package imagebuild
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindLockfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.sum"), "a")
+	writeFile(t, filepath.Join(dir, "web", "package-lock.json"), "b")
+	writeFile(t, filepath.Join(dir, "node_modules", "dep", "package-lock.json"), "vendored")
+	writeFile(t, filepath.Join(dir, "a", "b", "c", "d", "go.sum"), "too deep")
+	writeFile(t, filepath.Join(dir, "README.md"), "not a lockfile")
+
+	got, err := findLockfiles(dir)
+	if err != nil {
+		t.Fatalf("findLockfiles() error = %v", err)
+	}
+	want := []string{"go.sum", filepath.Join("web", "package-lock.json")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findLockfiles() = %v, want %v", got, want)
+	}
+}
+
+func TestSetupKey(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "package-lock.json"), `{"lockfileVersion": 3}`)
+	command := []string{"/bin/sh", "-c", "npm ci"}
+
+	key, err := SetupKey("sha256:base", "vscode", command, dir)
+	if err != nil {
+		t.Fatalf("SetupKey() error = %v", err)
+	}
+	if len(key) != setupKeyLength {
+		t.Errorf("SetupKey() = %q, want %d chars", key, setupKeyLength)
+	}
+
+	// Unrelated files don't change the key
+	writeFile(t, filepath.Join(dir, "main.js"), "console.log(1)")
+	if again, _ := SetupKey("sha256:base", "vscode", command, dir); again != key {
+		t.Error("SetupKey() changed after editing a non-lockfile")
+	}
+
+	changes := map[string]func() (string, error){
+		"base image": func() (string, error) {
+			return SetupKey("sha256:other", "vscode", command, dir)
+		},
+		"command": func() (string, error) {
+			return SetupKey("sha256:base", "vscode", []string{"/bin/sh", "-c", "npm install"}, dir)
+		},
+	}
+	for name, change := range changes {
+		changed, err := change()
+		if err != nil {
+			t.Fatalf("%s: SetupKey() error = %v", name, err)
+		}
+		if changed == key {
+			t.Errorf("SetupKey() unchanged after changing the %s", name)
+		}
+	}
+
+	writeFile(t, filepath.Join(dir, "package-lock.json"), `{"lockfileVersion": 3, "packages": {}}`)
+	if changed, _ := SetupKey("sha256:base", "vscode", command, dir); changed == key {
+		t.Error("SetupKey() unchanged after changing the lockfile")
+	}
+}
@@ -0,0 +1,179 @@
+package imagebuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// ShellKeyLabel records the cache key a shell layer was built for
+const ShellKeyLabel = "packnplay.shell-key"
+
+// shellPromptFile is where the prompt snippet is copied to inside the image
+const shellPromptFile = "/etc/packnplay/prompt"
+
+// supportedShells maps each shell to the system-wide rc file its interactive sessions read
+var supportedShells = map[string]string{
+	"bash": "/etc/bash.bashrc",
+	"zsh":  "/etc/zsh/zshrc",
+	"fish": "/etc/fish/conf.d/packnplay.fish",
+}
+
+// supportedPrompts are the prompt setups ShellPromptScript knows how to write
+var supportedPrompts = map[string]bool{"starship": true, "minimal": true}
+
+// ShellImageRepository returns the repository holding a project's shell layers
+func ShellImageRepository(projectName string) string {
+	return fmt.Sprintf("packnplay-%s-shell", projectName)
+}
+
+// ShellPath returns where the configured shell lives in the image, or "" to keep the image's
+func ShellPath(shell config.ShellConfig) string {
+	if shell.Name == "" {
+		return ""
+	}
+	return "/usr/bin/" + shell.Name
+}
+
+// ValidateShell rejects unknown shells and prompts, and utility names a shell would interpret
+func ValidateShell(shell config.ShellConfig) error {
+	if _, ok := supportedShells[shell.Name]; shell.Name != "" && !ok {
+		return fmt.Errorf("unsupported shell '%s' (use bash, zsh or fish)", shell.Name)
+	}
+	if shell.Prompt != "" && !supportedPrompts[shell.Prompt] {
+		return fmt.Errorf("unsupported shell prompt '%s' (use starship or minimal)", shell.Prompt)
+	}
+	for _, name := range shell.Utilities {
+		if !packageNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid shell utility '%s'", name)
+		}
+	}
+	return nil
+}
+
+// ShellKey hashes the base image, user and shell setup
+func ShellKey(baseImageID, user string, shell config.ShellConfig) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "base=%s\nuser=%s\nshell=%q\nprompt=%q\n", baseImageID, user, shell.Name, shell.Prompt)
+	for _, name := range shell.Utilities {
+		fmt.Fprintf(h, "utility=%q\n", name)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:setupKeyLength]
+}
+
+// promptShell is the shell the prompt is set up for; without a chosen shell that's bash,
+// which is what `packnplay attach` falls back to
+func promptShell(shell config.ShellConfig) string {
+	if shell.Name == "" {
+		return "bash"
+	}
+	return shell.Name
+}
+
+// ShellPromptScript returns the rc snippet that sets up the prompt, or "" for the shell's default
+func ShellPromptScript(shell config.ShellConfig) string {
+	name := promptShell(shell)
+	switch shell.Prompt {
+	case "starship":
+		if name == "fish" {
+			return "starship init fish | source\n"
+		}
+		return fmt.Sprintf("eval \"$(starship init %s)\"\n", name)
+	case "minimal":
+		// Just the directory, so deep worktree paths don't push commands off the screen
+		switch name {
+		case "zsh":
+			return "PROMPT='%~ %# '\n"
+		case "fish":
+			return "function fish_prompt\n    echo -n (prompt_pwd)' > '\nend\n"
+		default:
+			return "PS1='\\w \\$ '\n"
+		}
+	}
+	return ""
+}
+
+// GenerateShellDockerfile installs the shell and utilities on top of baseImage with apt,
+// makes the shell the user's login shell, and hooks the prompt into its system-wide rc file
+// (the home directory is usually a fresh tmpfs, so per-user dotfiles wouldn't survive).
+func GenerateShellDockerfile(baseImage, user, key string, shell config.ShellConfig) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	b.WriteString("USER root\n")
+
+	pkgs := append([]string{}, shell.Utilities...)
+	if shell.Name != "" && shell.Name != "bash" {
+		pkgs = append(pkgs, shell.Name)
+	}
+	if shell.Prompt == "starship" {
+		pkgs = append(pkgs, "curl", "ca-certificates")
+	}
+	if len(pkgs) > 0 {
+		fmt.Fprintf(&b, "RUN apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*\n", quoteAll(pkgs))
+	}
+
+	if path := ShellPath(shell); path != "" {
+		// Older images don't have a merged /usr, so link the shell where SHELL points
+		fmt.Fprintf(&b, "RUN [ -x %[1]s ] || ln -s \"$(command -v %[2]s)\" %[1]s\n", path, shell.Name)
+		if user != "" {
+			fmt.Fprintf(&b, "RUN usermod -s %s %s\n", path, quoteAll([]string{user}))
+		}
+	}
+
+	if shell.Prompt == "starship" {
+		b.WriteString("RUN curl -fsSL https://starship.rs/install.sh | sh -s -- -y -b /usr/local/bin\n")
+	}
+	if ShellPromptScript(shell) != "" {
+		rc := supportedShells[promptShell(shell)]
+		fmt.Fprintf(&b, "COPY prompt %s\n", shellPromptFile)
+		fmt.Fprintf(&b, "RUN mkdir -p %s && echo 'source %s' >> %s\n", filepath.Dir(rc), shellPromptFile, rc)
+	}
+
+	if user != "" {
+		fmt.Fprintf(&b, "USER %s\n", user)
+	}
+	fmt.Fprintf(&b, "LABEL %s=%s\n", strconv.Quote(ShellKeyLabel), strconv.Quote(key))
+	return b.String()
+}
+
+// FindShellImage returns the cached shell layer for key, or "" if it hasn't been built
+func FindShellImage(dockerClient *docker.Client, projectName, key string) string {
+	tag := ShellImageRepository(projectName) + ":" + key
+	if _, err := dockerClient.Run("image", "inspect", tag); err != nil {
+		return ""
+	}
+	return tag
+}
+
+// BuildShellImage builds the shell layer for key on top of baseImage
+func BuildShellImage(dockerClient *docker.Client, baseImage, user, projectName, key string, shell config.ShellConfig) (string, error) {
+	buildDir, err := os.MkdirTemp("", "packnplay-shell-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	if script := ShellPromptScript(shell); script != "" {
+		if err := os.WriteFile(filepath.Join(buildDir, "prompt"), []byte(script), 0644); err != nil {
+			return "", fmt.Errorf("failed to write prompt script: %w", err)
+		}
+	}
+
+	tag := ShellImageRepository(projectName) + ":" + key
+	if err := build(dockerClient, buildDir, GenerateShellDockerfile(baseImage, user, key, shell), tag); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// PruneShellImages removes a project's shell layers other than keep, returning how many were removed
+func PruneShellImages(dockerClient *docker.Client, projectName, keep string) int {
+	return pruneRepository(dockerClient, ShellImageRepository(projectName), keep)
+}
@@ -0,0 +1,97 @@
+package imagebuild
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestValidateShell(t *testing.T) {
+	valid := []config.ShellConfig{
+		{},
+		{Name: "zsh", Prompt: "starship", Utilities: []string{"ripgrep", "fzf"}},
+		{Name: "fish", Prompt: "minimal"},
+		{Prompt: "minimal"},
+	}
+	for _, shell := range valid {
+		if err := ValidateShell(shell); err != nil {
+			t.Errorf("ValidateShell(%+v) unexpected error: %v", shell, err)
+		}
+	}
+
+	invalid := []config.ShellConfig{
+		{Name: "csh"},
+		{Name: "/bin/zsh"},
+		{Prompt: "powerlevel10k"},
+		{Utilities: []string{"fzf; curl evil"}},
+	}
+	for _, shell := range invalid {
+		if err := ValidateShell(shell); err == nil {
+			t.Errorf("ValidateShell(%+v) should fail", shell)
+		}
+	}
+}
+
+func TestShellKey(t *testing.T) {
+	shell := config.ShellConfig{Name: "zsh", Utilities: []string{"fzf"}}
+	key := ShellKey("sha256:base", "vscode", shell)
+	if ShellKey("sha256:base", "vscode", shell) != key {
+		t.Error("ShellKey() should be deterministic")
+	}
+
+	for name, other := range map[string]string{
+		"base image": ShellKey("sha256:other", "vscode", shell),
+		"user":       ShellKey("sha256:base", "node", shell),
+		"shell":      ShellKey("sha256:base", "vscode", config.ShellConfig{Name: "fish", Utilities: []string{"fzf"}}),
+		"prompt":     ShellKey("sha256:base", "vscode", config.ShellConfig{Name: "zsh", Prompt: "minimal", Utilities: []string{"fzf"}}),
+		"utilities":  ShellKey("sha256:base", "vscode", config.ShellConfig{Name: "zsh"}),
+	} {
+		if other == key {
+			t.Errorf("changing the %s should change the key", name)
+		}
+	}
+}
+
+func TestGenerateShellDockerfile(t *testing.T) {
+	shell := config.ShellConfig{Name: "zsh", Prompt: "starship", Utilities: []string{"ripgrep"}}
+	dockerfile := GenerateShellDockerfile("base:latest", "vscode", "abc123", shell)
+
+	for _, want := range []string{
+		"FROM base:latest\n",
+		"install -y --no-install-recommends 'ripgrep' 'zsh' 'curl' 'ca-certificates'",
+		"RUN usermod -s /usr/bin/zsh 'vscode'\n",
+		"starship.rs/install.sh",
+		"COPY prompt /etc/packnplay/prompt\n",
+		"echo 'source /etc/packnplay/prompt' >> /etc/zsh/zshrc",
+		"USER vscode\n",
+		`LABEL "packnplay.shell-key"="abc123"`,
+	} {
+		if !strings.Contains(dockerfile, want) {
+			t.Errorf("Dockerfile missing %q:\n%s", want, dockerfile)
+		}
+	}
+
+	// Bash is already there and has no prompt to set up, so only the login shell changes
+	bash := GenerateShellDockerfile("base:latest", "vscode", "abc123", config.ShellConfig{Name: "bash"})
+	if strings.Contains(bash, "apt-get") || strings.Contains(bash, "COPY prompt") {
+		t.Errorf("bash without utilities or prompt shouldn't install anything:\n%s", bash)
+	}
+}
+
+func TestShellPromptScript(t *testing.T) {
+	tests := []struct {
+		shell config.ShellConfig
+		want  string
+	}{
+		{config.ShellConfig{}, ""},
+		{config.ShellConfig{Prompt: "starship"}, `eval "$(starship init bash)"` + "\n"},
+		{config.ShellConfig{Name: "fish", Prompt: "starship"}, "starship init fish | source\n"},
+		{config.ShellConfig{Name: "zsh", Prompt: "minimal"}, "PROMPT='%~ %# '\n"},
+	}
+	for _, tt := range tests {
+		if got := ShellPromptScript(tt.shell); got != tt.want {
+			t.Errorf("ShellPromptScript(%+v) = %q, want %q", tt.shell, got, tt.want)
+		}
+	}
+}
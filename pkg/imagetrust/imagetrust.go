@@ -0,0 +1,139 @@
+package imagetrust
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// Trust policies
+const (
+	PolicyOff     = "off"
+	PolicyWarn    = "warn"    // report unverified images but run them
+	PolicyEnforce = "enforce" // refuse to run unverified images
+)
+
+// Runner executes a host program, returning its combined output
+type Runner func(name string, args ...string) (string, error)
+
+// HostRunner runs programs on the host
+func HostRunner(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	return string(output), err
+}
+
+// Enabled reports whether images are checked at all
+func Enabled(settings config.ImageTrustConfig) bool {
+	return settings.Policy == PolicyWarn || settings.Policy == PolicyEnforce
+}
+
+// Validate rejects trust policies that could never verify anything
+func Validate(settings config.ImageTrustConfig) error {
+	switch settings.Policy {
+	case "", PolicyOff:
+		return nil
+	case PolicyWarn, PolicyEnforce:
+	default:
+		return fmt.Errorf("invalid image_trust policy '%s' (use off, warn or enforce)", settings.Policy)
+	}
+	if len(settings.Keys) == 0 && len(settings.Keyless) == 0 && !settings.TrustLockfile {
+		return fmt.Errorf("image_trust needs keys, keyless signers or trust_lockfile")
+	}
+	for _, signer := range settings.Keyless {
+		if signer.Identity == "" || signer.Issuer == "" {
+			return fmt.Errorf("image_trust keyless signers need both identity and issuer")
+		}
+	}
+	return nil
+}
+
+// Verify checks an image's signature against the trust policy
+// ref should be a digest reference, so what was verified is exactly what runs.
+// pinned means ref came from the project's lockfile as committed at HEAD.
+func Verify(settings config.ImageTrustConfig, ref string, pinned bool, run Runner) error {
+	if pinned && settings.TrustLockfile {
+		return nil
+	}
+
+	var attempts [][]string
+	for _, key := range settings.Keys {
+		attempts = append(attempts, []string{"verify", "--key", expandHome(key), ref})
+	}
+	for _, signer := range settings.Keyless {
+		attempts = append(attempts, []string{"verify",
+			"--certificate-identity-regexp", signer.Identity,
+			"--certificate-oidc-issuer", signer.Issuer, ref})
+	}
+	if len(attempts) == 0 {
+		return fmt.Errorf("%s isn't pinned in the lockfile, and image_trust has no keys to verify it with", ref)
+	}
+
+	var lastOutput string
+	for _, args := range attempts {
+		output, err := run("cosign", args...)
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("image_trust needs cosign on the host (https://docs.sigstore.dev/cosign/system_config/installation/)")
+		}
+		if err == nil {
+			return nil
+		}
+		lastOutput = output
+	}
+	return fmt.Errorf("no trusted signature on %s\n%s", ref, strings.TrimSpace(lastOutput))
+}
+
+// BaseImages returns the images a Dockerfile builds FROM
+// Earlier build stages and scratch aren't images to verify.
+func BaseImages(dockerfile string) ([]string, error) {
+	file, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dockerfile, err)
+	}
+	defer file.Close()
+
+	stages := map[string]bool{}
+	var images []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:] // --platform=...
+		}
+		if len(args) == 0 {
+			continue
+		}
+		image := args[0]
+		earlierStage := stages[strings.ToLower(image)]
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = true
+		}
+		if image == "scratch" || earlierStage {
+			continue
+		}
+		images = append(images, image)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dockerfile, err)
+	}
+	return images, nil
+}
+
+// expandHome resolves a leading ~/ to the host home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[2:])
+		}
+	}
+	return path
+}
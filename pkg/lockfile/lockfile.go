@@ -0,0 +1,323 @@
+package lockfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// FileName is the lockfile name, committed at the project root
+const FileName = ".packnplay.lock"
+
+// lockVersion is bumped whenever the lockfile format changes incompatibly
+const lockVersion = 1
+
+// Lockfile pins everything that makes up a project's sandbox
+type Lockfile struct {
+	Version     int               `json:"version"`
+	Image       string            `json:"image"`       // image reference as configured, e.g. ghcr.io/obra/packnplay-default:latest
+	ImageDigest string            `json:"imageDigest"` // immutable reference, e.g. ghcr.io/obra/packnplay-default@sha256:...
+	Agents      map[string]string `json:"agents"`      // agent name -> CLI version reported inside the image
+	// agent name -> exact npm version, installed into the agent layer by 'packnplay run'
+	AgentPackages map[string]string `json:"agentPackages,omitempty"`
+	GeneratedAt   time.Time         `json:"generatedAt"`
+}
+
+// Path returns the lockfile path for a project
+func Path(projectPath string) string {
+	return filepath.Join(projectPath, FileName)
+}
+
+// Load reads the project's lockfile, returning nil if it doesn't exist
+func Load(projectPath string) (*Lockfile, error) {
+	data, err := os.ReadFile(Path(projectPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	return parse(data)
+}
+
+// LoadCommitted reads the lockfile as committed at HEAD, returning nil if HEAD has none
+// Unlike the working tree's copy, a sandbox can't change it without a commit.
+func LoadCommitted(projectPath string) (*Lockfile, error) {
+	data, err := git.ShowFile(projectPath, "HEAD", FileName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (*Lockfile, error) {
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	if lock.Version > lockVersion {
+		return nil, fmt.Errorf("%s version %d is newer than supported version %d; upgrade packnplay", FileName, lock.Version, lockVersion)
+	}
+	return &lock, nil
+}
+
+// Save writes the lockfile to the project root
+func Save(projectPath string, lock *Lockfile) error {
+	lock.Version = lockVersion
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+
+	if err := os.WriteFile(Path(projectPath), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+	return nil
+}
+
+// PinnedImage returns the image reference to run for the configured image
+// Returns an error if the lockfile was generated for a different image, so a
+// config change is never silently overridden by a stale pin.
+func (l *Lockfile) PinnedImage(configuredImage string) (string, error) {
+	if l.Image != configuredImage {
+		return "", fmt.Errorf("%s pins %s but project uses %s; run 'packnplay update'", FileName, l.Image, configuredImage)
+	}
+	if l.ImageDigest == "" {
+		return "", fmt.Errorf("%s has no image digest; run 'packnplay update'", FileName)
+	}
+	return l.ImageDigest, nil
+}
+
+// Generate pulls the latest image, resolves its digest, and records agent CLI versions and
+// the exact npm version of each agent to install: the one the image ships, or the newest
+// release matching the project's pin
+func Generate(dockerClient *docker.Client, image string, pins map[string]string) (*Lockfile, error) {
+	output, err := dockerClient.RunWithRetry(docker.DefaultBackoff, "pull", image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull image %s: %w\nDocker output:\n%s", image, err, output)
+	}
+
+	digest, err := ResolveDigest(dockerClient, image)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := DetectAgentVersions(dockerClient, digest)
+	if err != nil {
+		return nil, err
+	}
+	packages, err := ResolveAgentPackages(dockerClient, digest, pins)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lockfile{
+		Image:         image,
+		ImageDigest:   digest,
+		Agents:        versions,
+		AgentPackages: packages,
+		GeneratedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// exactVersion matches a single npm release, as opposed to a range
+var exactVersion = regexp.MustCompile(`^\d+\.\d+\.\d+(?:[-+][0-9A-Za-z.+-]+)?$`)
+
+// ResolveAgentPackages returns the exact npm version of each npm-installed agent the image
+// ships, and of each pinned agent: the newest release matching its pin, looked up with npm
+// inside the image. Agents installed by script have no version to pin and are left out.
+func ResolveAgentPackages(dockerClient *docker.Client, image string, pins map[string]string) (map[string]string, error) {
+	args := []string{"run", "--rm", "--entrypoint", "sh"}
+	var script strings.Builder
+	for _, agent := range agents.GetSupportedAgents() {
+		installable, ok := agent.(agents.Installable)
+		if !ok || installable.Install().NPMPackage == "" {
+			continue
+		}
+		pkg := installable.Install().NPMPackage
+		if pin := pins[agent.Name()]; pin != "" {
+			// Passed in the environment, so the pin needs no shell quoting
+			variable := "PACKNPLAY_PIN_" + strings.ToUpper(agent.Name())
+			args = append(args, "-e", variable+"="+pkg+"@"+pin)
+			fmt.Fprintf(&script, "echo \"%s=view:$(npm view \"$%s\" version --json 2>/dev/null | tr -d '\\n ')\"\n", agent.Name(), variable)
+		} else {
+			fmt.Fprintf(&script, "echo \"%s=ls:$(npm ls -g --depth=0 --parseable --long %s 2>/dev/null | head -n1)\"\n", agent.Name(), pkg)
+		}
+	}
+	args = append(args, image, "-c", script.String())
+
+	output, err := dockerClient.Run(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve agent versions in %s: %w\nDocker output:\n%s", image, err, output)
+	}
+	return parsePackageVersions(output, pins)
+}
+
+// parsePackageVersions reads ResolveAgentPackages' output: "name=view:<npm view --json>" for
+// pinned agents and "name=ls:<path>:<package>@<version>" for installed ones
+func parsePackageVersions(output string, pins map[string]string) (map[string]string, error) {
+	packages := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		name, result, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		var version string
+		switch {
+		case strings.HasPrefix(result, "view:"):
+			var versions []string
+			data := strings.TrimPrefix(result, "view:")
+			if json.Unmarshal([]byte(data), &versions) != nil {
+				var single string
+				if json.Unmarshal([]byte(data), &single) == nil {
+					versions = []string{single}
+				}
+			}
+			if len(versions) == 0 {
+				return nil, fmt.Errorf("no release of %s matches %s", name, pins[name])
+			}
+			version = versions[len(versions)-1] // npm lists matches oldest first
+		case strings.HasPrefix(result, "ls:"):
+			installed := strings.TrimPrefix(result, "ls:")
+			if installed == "" {
+				continue // not in the image
+			}
+			version = installed[strings.LastIndex(installed, "@")+1:]
+		}
+		if !exactVersion.MatchString(version) {
+			return nil, fmt.Errorf("unexpected version '%s' for %s", version, name)
+		}
+		packages[name] = version
+	}
+	return packages, nil
+}
+
+// ResolveDigest returns the registry digest reference (repo@sha256:...) of a local image
+func ResolveDigest(dockerClient *docker.Client, image string) (string, error) {
+	output, err := dockerClient.Run("image", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	digest := selectDigest(image, output)
+	if digest == "" {
+		return "", fmt.Errorf("image %s has no registry digest (locally built images can't be pinned)", image)
+	}
+	return digest, nil
+}
+
+// selectDigest picks the repo digest matching the image's repository
+func selectDigest(image, inspectOutput string) string {
+	repo := imageRepository(image)
+
+	var first string
+	for _, line := range strings.Split(strings.TrimSpace(inspectOutput), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if first == "" {
+			first = line
+		}
+		if strings.HasPrefix(line, repo+"@") {
+			return line
+		}
+	}
+	return first
+}
+
+// imageRepository strips the tag or digest from an image reference
+func imageRepository(image string) string {
+	if idx := strings.Index(image, "@"); idx != -1 {
+		return image[:idx]
+	}
+	// A colon after the last slash is a tag; one before it is a registry port
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		return image[:idx]
+	}
+	return image
+}
+
+// DetectAgentVersions runs each agent CLI's --version inside the image
+func DetectAgentVersions(dockerClient *docker.Client, image string) (map[string]string, error) {
+	var script strings.Builder
+	for _, agent := range agents.GetSupportedAgents() {
+		fmt.Fprintf(&script, "if command -v %[1]s >/dev/null 2>&1; then echo \"%[2]s=$(%[1]s --version 2>/dev/null | head -n1)\"; fi\n", agent.Command(), agent.Name())
+	}
+
+	output, err := dockerClient.Run("run", "--rm", "--entrypoint", "sh", image, "-c", script.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect agent versions in %s: %w\nDocker output:\n%s", image, err, output)
+	}
+	return parseVersions(output), nil
+}
+
+// parseVersions parses name=version lines, ignoring agents that printed nothing
+func parseVersions(output string) map[string]string {
+	versions := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, version, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if ok && name != "" && strings.TrimSpace(version) != "" {
+			versions[name] = strings.TrimSpace(version)
+		}
+	}
+	return versions
+}
+
+// Diff describes what changed between two lockfiles, for `packnplay update` output
+func Diff(old, new *Lockfile) []string {
+	var changes []string
+	if old == nil {
+		return []string{fmt.Sprintf("created %s", FileName)}
+	}
+
+	if old.ImageDigest != new.ImageDigest {
+		changes = append(changes, fmt.Sprintf("image: %s -> %s", old.ImageDigest, new.ImageDigest))
+	}
+
+	changes = append(changes, diffVersions(old.Agents, new.Agents, "")...)
+	changes = append(changes, diffVersions(old.AgentPackages, new.AgentPackages, " package")...)
+	return changes
+}
+
+// diffVersions describes the per-agent differences between two version maps
+func diffVersions(old, new map[string]string, suffix string) []string {
+	var changes []string
+	names := make(map[string]bool)
+	for name := range old {
+		names[name] = true
+	}
+	for name := range new {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		before, after := old[name], new[name]
+		switch {
+		case before == after:
+		case before == "":
+			changes = append(changes, fmt.Sprintf("%s%s: added %s", name, suffix, after))
+		case after == "":
+			changes = append(changes, fmt.Sprintf("%s%s: removed (was %s)", name, suffix, before))
+		default:
+			changes = append(changes, fmt.Sprintf("%s%s: %s -> %s", name, suffix, before, after))
+		}
+	}
+	return changes
+}
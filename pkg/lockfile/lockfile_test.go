@@ -0,0 +1,123 @@
+package lockfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	projectDir := t.TempDir()
+
+	lock, err := Load(projectDir)
+	if err != nil || lock != nil {
+		t.Fatalf("Load() on project without lockfile = %v, %v, want nil, nil", lock, err)
+	}
+
+	want := &Lockfile{
+		Image:       "ghcr.io/obra/packnplay-default:latest",
+		ImageDigest: "ghcr.io/obra/packnplay-default@sha256:abc123",
+		Agents:      map[string]string{"claude": "2.0.1 (Claude Code)"},
+	}
+	if err := Save(projectDir, want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	got, err := Load(projectDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.ImageDigest != want.ImageDigest || got.Agents["claude"] != "2.0.1 (Claude Code)" {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPinnedImage(t *testing.T) {
+	lock := &Lockfile{
+		Image:       "node:22",
+		ImageDigest: "node@sha256:abc123",
+	}
+
+	pinned, err := lock.PinnedImage("node:22")
+	if err != nil || pinned != "node@sha256:abc123" {
+		t.Errorf("PinnedImage() = %v, %v, want node@sha256:abc123", pinned, err)
+	}
+
+	if _, err := lock.PinnedImage("node:20"); err == nil {
+		t.Error("PinnedImage() with a different configured image should fail")
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/obra/packnplay-default:latest": "ghcr.io/obra/packnplay-default",
+		"node":                                  "node",
+		"localhost:5000/team/image:v1":          "localhost:5000/team/image",
+		"localhost:5000/team/image":             "localhost:5000/team/image",
+		"node@sha256:abc":                       "node",
+	}
+	for image, want := range tests {
+		if got := imageRepository(image); got != want {
+			t.Errorf("imageRepository(%s) = %s, want %s", image, got, want)
+		}
+	}
+}
+
+func TestSelectDigest(t *testing.T) {
+	output := "mirror.example.com/node@sha256:111\nnode@sha256:222\n"
+	if got := selectDigest("node:22", output); got != "node@sha256:222" {
+		t.Errorf("selectDigest() = %s, want node@sha256:222", got)
+	}
+	if got := selectDigest("node:22", ""); got != "" {
+		t.Errorf("selectDigest() with no digests = %s, want empty", got)
+	}
+}
+
+func TestParseVersions(t *testing.T) {
+	output := "claude=2.0.1 (Claude Code)\ncodex=codex-cli 0.46.0\ngemini=\nnoise\n"
+	want := map[string]string{
+		"claude": "2.0.1 (Claude Code)",
+		"codex":  "codex-cli 0.46.0",
+	}
+	if got := parseVersions(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVersions() = %v, want %v", got, want)
+	}
+}
+
+func TestParsePackageVersions(t *testing.T) {
+	output := "claude=view:[\"1.0.1\",\"1.0.2\"]\ncodex=view:\"0.46.0\"\ngemini=ls:/usr/local/lib/node_modules/@google/gemini-cli:@google/gemini-cli@0.9.0\namp=ls:\n"
+	want := map[string]string{"claude": "1.0.2", "codex": "0.46.0", "gemini": "0.9.0"}
+	got, err := parsePackageVersions(output, map[string]string{"claude": "1.x", "codex": "0.46.0"})
+	if err != nil {
+		t.Fatalf("parsePackageVersions() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePackageVersions() = %v, want %v", got, want)
+	}
+
+	if _, err := parsePackageVersions("claude=view:\n", map[string]string{"claude": "9.x"}); err == nil {
+		t.Error("parsePackageVersions() accepted a pin with no matching release")
+	}
+	if _, err := parsePackageVersions("claude=ls:/x:@anthropic-ai/claude-code@latest\n", nil); err == nil {
+		t.Error("parsePackageVersions() accepted an inexact version")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Lockfile{ImageDigest: "img@sha256:1", Agents: map[string]string{"claude": "1.0", "amp": "0.1"}}
+	new := &Lockfile{
+		ImageDigest:   "img@sha256:2",
+		Agents:        map[string]string{"claude": "1.1", "codex": "0.46"},
+		AgentPackages: map[string]string{"claude": "1.1.0"},
+	}
+
+	want := []string{
+		"image: img@sha256:1 -> img@sha256:2",
+		"amp: removed (was 0.1)",
+		"claude: 1.0 -> 1.1",
+		"codex: added 0.46",
+		"claude package: added 1.1.0",
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// journalSocket is systemd-journald's native protocol socket
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journal writes each Write as one journald entry
+type Journal struct {
+	conn *net.UnixConn
+	tag  string
+}
+
+// OpenJournal connects to the local journald socket
+func OpenJournal(tag string) (*Journal, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald (is systemd running?): %w", err)
+	}
+	return &Journal{conn: conn, tag: tag}, nil
+}
+
+// Write sends p as the MESSAGE of a new entry
+func (j *Journal) Write(p []byte) (int, error) {
+	if _, err := j.conn.Write(journalEntry(j.tag, strings.TrimRight(string(p), "\n"))); err != nil {
+		return 0, fmt.Errorf("failed to write to journald: %w", err)
+	}
+	return len(p), nil
+}
+
+// Close closes the socket
+func (j *Journal) Close() error {
+	return j.conn.Close()
+}
+
+// journalEntry encodes a message in journald's native format
+// Fields are KEY=value lines; values containing newlines use the length-prefixed binary form.
+func journalEntry(tag, message string) []byte {
+	var b bytes.Buffer
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", tag)
+	writeJournalField(&b, "PRIORITY", "6")
+	writeJournalField(&b, "MESSAGE", message)
+	return b.Bytes()
+}
+
+func writeJournalField(b *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%s=%s\n", key, value)
+		return
+	}
+	b.WriteString(key)
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// Supported drivers
+const (
+	DriverFile     = "file"
+	DriverSyslog   = "syslog"
+	DriverJournald = "journald"
+)
+
+// Rotation defaults for the file driver
+const (
+	defaultMaxSizeMB = 10
+	defaultMaxFiles  = 5
+)
+
+// Validate checks a logging config before anything is started with it
+func Validate(cfg config.LoggingConfig) error {
+	switch cfg.Driver {
+	case "", DriverFile, DriverJournald:
+	case DriverSyslog:
+		if cfg.SyslogAddress != "" {
+			if _, _, err := parseSyslogAddress(cfg.SyslogAddress); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown logging driver '%s' (use file, syslog or journald)", cfg.Driver)
+	}
+	if cfg.MaxSizeMB < 0 || cfg.MaxFiles < 0 {
+		return fmt.Errorf("logging max_size_mb and max_files must not be negative")
+	}
+	return nil
+}
+
+// GetLogPath returns the file driver's log file
+func GetLogPath(cfg config.LoggingConfig) string {
+	if cfg.Path != "" {
+		return cfg.Path
+	}
+
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "logs", "packnplay.log")
+}
+
+// Open returns a writer for the configured driver, or nil when no driver is configured
+// tag identifies the process, e.g. "packnplay-broker".
+func Open(cfg config.LoggingConfig, tag string) (io.WriteCloser, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case DriverFile:
+		w, err := OpenRotatingFile(GetLogPath(cfg), maxBytes(cfg), maxFiles(cfg))
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	case DriverSyslog:
+		if cfg.SyslogAddress == "" {
+			w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+			}
+			return w, nil
+		}
+		network, address, err := parseSyslogAddress(cfg.SyslogAddress)
+		if err != nil {
+			return nil, err
+		}
+		w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog at %s: %w", cfg.SyslogAddress, err)
+		}
+		return w, nil
+	case DriverJournald:
+		w, err := OpenJournal(tag)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	return nil, fmt.Errorf("unknown logging driver '%s'", cfg.Driver)
+}
+
+// Setup points the standard logger at the configured driver for a background process
+// The returned function flushes and closes it; with no driver configured it's a no-op.
+func Setup(cfg config.LoggingConfig, tag string) (func(), error) {
+	w, err := Open(cfg, tag)
+	if err != nil || w == nil {
+		if os.Getenv("JOURNAL_STREAM") != "" {
+			log.SetFlags(0) // stderr goes to the journal, which timestamps entries itself
+		}
+		return func() {}, err
+	}
+
+	log.SetOutput(w)
+	if cfg.Driver != DriverFile {
+		log.SetFlags(0) // syslog and journald timestamp entries themselves
+	}
+	return func() {
+		log.SetOutput(os.Stderr)
+		w.Close()
+	}, nil
+}
+
+// ContainerArgs returns docker run log driver args so session containers follow the same config
+func ContainerArgs(cfg config.LoggingConfig, containerName string) []string {
+	switch cfg.Driver {
+	case DriverFile:
+		// Container output stays with the runtime, but bounded the same way
+		return []string{
+			"--log-driver", "json-file",
+			"--log-opt", fmt.Sprintf("max-size=%dm", maxBytes(cfg)/(1024*1024)),
+			"--log-opt", "max-file=" + strconv.Itoa(maxFiles(cfg)),
+		}
+	case DriverSyslog:
+		args := []string{"--log-driver", "syslog", "--log-opt", "tag=" + containerName}
+		if cfg.SyslogAddress != "" {
+			args = append(args, "--log-opt", "syslog-address="+cfg.SyslogAddress)
+		}
+		return args
+	case DriverJournald:
+		return []string{"--log-driver", "journald", "--log-opt", "tag=" + containerName}
+	}
+	return nil
+}
+
+func maxBytes(cfg config.LoggingConfig) int64 {
+	if cfg.MaxSizeMB > 0 {
+		return int64(cfg.MaxSizeMB) * 1024 * 1024
+	}
+	return defaultMaxSizeMB * 1024 * 1024
+}
+
+func maxFiles(cfg config.LoggingConfig) int {
+	if cfg.MaxFiles > 0 {
+		return cfg.MaxFiles
+	}
+	return defaultMaxFiles
+}
+
+// parseSyslogAddress splits udp://host:514 into a network and address for syslog.Dial
+func parseSyslogAddress(address string) (string, string, error) {
+	parsed, err := url.Parse(address)
+	if err != nil || parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid syslog_address '%s' (expected udp://host:port or tcp://host:port)", address)
+	}
+	switch parsed.Scheme {
+	case "udp", "tcp":
+		return parsed.Scheme, parsed.Host, nil
+	}
+	return "", "", fmt.Errorf("invalid syslog_address '%s': scheme must be udp or tcp", address)
+}
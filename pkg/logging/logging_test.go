@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "packnplay.log")
+	r, err := OpenRotatingFile(path, 20, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer r.Close()
+
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read := func(p string) string {
+		data, _ := os.ReadFile(p)
+		return string(data)
+	}
+	if got := read(path); got != "fourth line\n" {
+		t.Errorf("current = %q", got)
+	}
+	if got := read(path + ".1"); got != "third line\n" {
+		t.Errorf(".1 = %q", got)
+	}
+	if got := read(path + ".2"); got != "second line\n" {
+		t.Errorf(".2 = %q", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("rotation kept more than max_files")
+	}
+}
+
+func TestContainerArgs(t *testing.T) {
+	tests := []struct {
+		cfg  config.LoggingConfig
+		want []string
+	}{
+		{config.LoggingConfig{}, nil},
+		{config.LoggingConfig{Driver: "file", MaxSizeMB: 20, MaxFiles: 3},
+			[]string{"--log-driver", "json-file", "--log-opt", "max-size=20m", "--log-opt", "max-file=3"}},
+		{config.LoggingConfig{Driver: "file"},
+			[]string{"--log-driver", "json-file", "--log-opt", "max-size=10m", "--log-opt", "max-file=5"}},
+		{config.LoggingConfig{Driver: "syslog", SyslogAddress: "udp://logs:514"},
+			[]string{"--log-driver", "syslog", "--log-opt", "tag=packnplay-app-main", "--log-opt", "syslog-address=udp://logs:514"}},
+		{config.LoggingConfig{Driver: "journald"},
+			[]string{"--log-driver", "journald", "--log-opt", "tag=packnplay-app-main"}},
+	}
+
+	for _, tt := range tests {
+		if got := ContainerArgs(tt.cfg, "packnplay-app-main"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ContainerArgs(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []config.LoggingConfig{
+		{},
+		{Driver: "file", Path: "/var/log/packnplay.log"},
+		{Driver: "syslog"},
+		{Driver: "syslog", SyslogAddress: "tcp://logs.example.com:601"},
+		{Driver: "journald"},
+	}
+	for _, cfg := range valid {
+		if err := Validate(cfg); err != nil {
+			t.Errorf("Validate(%+v) error = %v", cfg, err)
+		}
+	}
+
+	invalid := []config.LoggingConfig{
+		{Driver: "fluentd"},
+		{Driver: "syslog", SyslogAddress: "logs.example.com:514"},
+		{Driver: "file", MaxFiles: -1},
+	}
+	for _, cfg := range invalid {
+		if err := Validate(cfg); err == nil {
+			t.Errorf("Validate(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestJournalEntry(t *testing.T) {
+	got := journalEntry("packnplay-broker", "broker: open")
+	want := "SYSLOG_IDENTIFIER=packnplay-broker\nPRIORITY=6\nMESSAGE=broker: open\n"
+	if string(got) != want {
+		t.Errorf("journalEntry() = %q, want %q", got, want)
+	}
+
+	// Multi-line messages use the binary length-prefixed form
+	multi := journalEntry("t", "a\nb")
+	var expected bytes.Buffer
+	expected.WriteString("SYSLOG_IDENTIFIER=t\nPRIORITY=6\nMESSAGE\n")
+	_ = binary.Write(&expected, binary.LittleEndian, uint64(3))
+	expected.WriteString("a\nb\n")
+	if !bytes.Equal(multi, expected.Bytes()) {
+		t.Errorf("journalEntry(multi-line) = %q", multi)
+	}
+	if !strings.HasPrefix(string(multi), "SYSLOG_IDENTIFIER=t\n") {
+		t.Error("identifier should stay in text form")
+	}
+}
@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mapping pairs a host directory with where the container sees it
+type Mapping struct {
+	Host      string
+	Container string
+}
+
+// Rewriter translates file URIs in LSP messages between host and container paths,
+// so a server in the sandbox and an editor on the host agree on which file is which
+type Rewriter struct {
+	toContainer [][2]string // host URI -> container URI, deepest first
+	toHost      [][2]string
+}
+
+// NewRewriter returns a rewriter for the given mappings; the deepest directory wins
+func NewRewriter(mappings []Mapping) *Rewriter {
+	r := &Rewriter{}
+	for _, m := range mappings {
+		host, container := fileURI(m.Host), fileURI(m.Container)
+		r.toContainer = append(r.toContainer, [2]string{host, container})
+		r.toHost = append(r.toHost, [2]string{container, host})
+	}
+	for _, pairs := range [][][2]string{r.toContainer, r.toHost} {
+		sort.SliceStable(pairs, func(i, j int) bool { return len(pairs[i][0]) > len(pairs[j][0]) })
+	}
+	return r
+}
+
+// ToContainer rewrites a message from the editor for the server
+func (r *Rewriter) ToContainer(body []byte) []byte {
+	return rewrite(body, r.toContainer)
+}
+
+// ToHost rewrites a message from the server for the editor
+func (r *Rewriter) ToHost(body []byte) []byte {
+	return rewrite(body, r.toHost)
+}
+
+// rewrite replaces the directory URIs in body in one pass, so a replacement is never
+// rewritten again by another mapping
+func rewrite(body []byte, pairs [][2]string) []byte {
+	s := string(body)
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "file://")
+		if i < 0 {
+			b.WriteString(s)
+			return []byte(b.String())
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		matched := false
+		for _, pair := range pairs {
+			if atPrefix(s, pair[0]) {
+				b.WriteString(pair[1])
+				s = s[len(pair[0]):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteString("file://")
+			s = s[len("file://"):]
+		}
+	}
+}
+
+// atPrefix reports whether s starts with the directory URI dir as a whole path: followed
+// by a slash or the end of the URI, so /src/app doesn't also match /src/app2
+func atPrefix(s, dir string) bool {
+	if !strings.HasPrefix(s, dir) {
+		return false
+	}
+	rest := s[len(dir):]
+	return rest == "" || rest[0] == '/' || rest[0] == '"' || rest[0] == '\\'
+}
+
+// fileURI is the file:// URI of a directory, percent-encoded as editors send it
+func fileURI(dir string) string {
+	return (&url.URL{Scheme: "file", Path: strings.TrimSuffix(dir, "/")}).String()
+}
+
+// ReadMessage reads one Content-Length framed message, returning its body
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read message header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message has no Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	return body, nil
+}
+
+// WriteMessage writes a body with the Content-Length header LSP frames messages with
+func WriteMessage(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// Forward copies messages from src to dst, rewriting each body, until src ends
+// Bodies change length when paths do, so each message is reframed.
+func Forward(dst io.Writer, src io.Reader, rewrite func([]byte) []byte) error {
+	r := bufio.NewReader(src)
+	for {
+		body, err := ReadMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := WriteMessage(dst, rewrite(body)); err != nil {
+			return fmt.Errorf("failed to forward message: %w", err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRewriter(t *testing.T) {
+	r := NewRewriter([]Mapping{
+		{Host: "/home/me/src/app", Container: "/workspace"},
+		{Host: "/home/me/src/app/vendor/lib", Container: "/deps/lib"},
+	})
+
+	tests := []struct {
+		name        string
+		host        string
+		container   string
+		toContainer bool
+	}{
+		{"file", `{"uri":"file:///home/me/src/app/main.go"}`, `{"uri":"file:///workspace/main.go"}`, true},
+		{"root", `{"rootUri":"file:///home/me/src/app"}`, `{"rootUri":"file:///workspace"}`, true},
+		{"deeper mapping wins", `{"uri":"file:///home/me/src/app/vendor/lib/x.go"}`, `{"uri":"file:///deps/lib/x.go"}`, true},
+		{"sibling untouched", `{"uri":"file:///home/me/src/app2/main.go"}`, `{"uri":"file:///home/me/src/app2/main.go"}`, true},
+		{"diagnostics back", `{"uri":"file:///home/me/src/app/main.go","message":"unused"}`, `{"uri":"file:///workspace/main.go","message":"unused"}`, false},
+	}
+	for _, tt := range tests {
+		if tt.toContainer {
+			if got := string(r.ToContainer([]byte(tt.host))); got != tt.container {
+				t.Errorf("%s: ToContainer() = %s, want %s", tt.name, got, tt.container)
+			}
+		}
+		if got := string(r.ToHost([]byte(tt.container))); got != tt.host {
+			t.Errorf("%s: ToHost() = %s, want %s", tt.name, got, tt.host)
+		}
+	}
+}
+
+func TestRewriterEscapedPaths(t *testing.T) {
+	r := NewRewriter([]Mapping{{Host: "/Users/me/My Project", Container: "/workspace"}})
+	got := string(r.ToContainer([]byte(`{"uri":"file:///Users/me/My%20Project/a.go"}`)))
+	if got != `{"uri":"file:///workspace/a.go"}` {
+		t.Errorf("ToContainer() = %s", got)
+	}
+}
+
+func TestForward(t *testing.T) {
+	var in bytes.Buffer
+	WriteMessage(&in, []byte(`{"uri":"file:///host/a.go"}`))
+	in.WriteString("Content-Length: 2\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{}")
+
+	var out bytes.Buffer
+	r := NewRewriter([]Mapping{{Host: "/host", Container: "/workspace"}})
+	if err := Forward(&out, &in, r.ToContainer); err != nil {
+		t.Fatalf("Forward() error: %v", err)
+	}
+	want := "Content-Length: 32\r\n\r\n{\"uri\":\"file:///workspace/a.go\"}Content-Length: 2\r\n\r\n{}"
+	if out.String() != want {
+		t.Errorf("Forward() wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	for _, input := range []string{"Content-Type: x\r\n\r\n{}", "Content-Length: 10\r\n\r\n{}", "Content-Length: -1\r\n\r\n"} {
+		if err := Forward(&bytes.Buffer{}, strings.NewReader(input), func(b []byte) []byte { return b }); err == nil {
+			t.Errorf("Forward(%q) should fail", input)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package mdns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// The mDNS group and port (RFC 6762)
+var groupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// DNS constants used by the responder
+const (
+	typeA       = 1
+	typeANY     = 255
+	classIN     = 1
+	cacheFlush  = 0x8000 // the record replaces any others cached for the name
+	unicastBit  = 0x8000 // in a question: the asker wants a unicast reply
+	flagsAnswer = 0x8400 // response, authoritative
+	recordTTL   = 120
+)
+
+// Name returns the .local host name for a session, e.g. myproj-claude.local
+func Name(project, agent string) string {
+	label := project
+	if agent != "" {
+		label += "-" + agent
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(label) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	clean := strings.Trim(b.String(), "-")
+	if len(clean) > 63 {
+		clean = strings.TrimRight(clean[:63], "-")
+	}
+	if clean == "" {
+		clean = "packnplay"
+	}
+	return clean + ".local"
+}
+
+// virtualPrefixes are interfaces other LAN devices can't reach: container bridges and VPNs
+var virtualPrefixes = []string{"docker", "br-", "veth", "cni", "podman", "virbr", "vmnet", "bridge", "utun", "tun", "tap", "tailscale", "wg", "zt"}
+
+// LANAddresses returns the host's IPv4 addresses on physical networks
+func LANAddresses() ([]net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+	var ips []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 || isVirtual(iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				if ip4 := ipnet.IP.To4(); ip4 != nil && !ip4.IsLinkLocalUnicast() {
+					ips = append(ips, ip4)
+				}
+			}
+		}
+	}
+	return ips, nil
+}
+
+func isVirtual(name string) bool {
+	for _, prefix := range virtualPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Responder answers mDNS queries for one host name
+type Responder struct {
+	Name string   // e.g. myproj-claude.local
+	IPs  []net.IP // IPv4 addresses the name resolves to
+}
+
+// Serve announces the name and answers queries for it until ctx is done, then tells the
+// network the name is gone so phones and laptops drop it from their caches
+func (r *Responder) Serve(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return fmt.Errorf("failed to join mDNS group: %w", err)
+	}
+	defer conn.Close()
+
+	send, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return fmt.Errorf("failed to open mDNS socket: %w", err)
+	}
+	defer send.Close()
+
+	announce := r.response(0, nil, recordTTL)
+	// Announced twice, a second apart, as RFC 6762 asks
+	for i := 0; i < 2; i++ {
+		if _, err := send.WriteToUDP(announce, groupAddr); err != nil {
+			return fmt.Errorf("failed to announce %s: %w", r.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		_, _ = send.WriteToUDP(r.response(0, nil, 0), groupAddr)
+		conn.Close()
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read mDNS query: %w", err)
+		}
+		id, question, unicast, ok := r.match(buf[:n])
+		if !ok {
+			continue
+		}
+		switch {
+		case from.Port != groupAddr.Port:
+			// A one-shot query from a plain resolver wants a DNS-style reply, ID and question included
+			_, _ = send.WriteToUDP(r.response(id, question, recordTTL), from)
+		case unicast:
+			_, _ = send.WriteToUDP(r.response(0, nil, recordTTL), from)
+		default:
+			_, _ = send.WriteToUDP(r.response(0, nil, recordTTL), groupAddr)
+		}
+	}
+}
+
+// match reports whether msg is a query asking for the responder's address, returning the
+// query's ID and question (for legacy unicast replies) and whether it asked for unicast
+func (r *Responder) match(msg []byte) (uint16, []byte, bool, bool) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[2:])&0x8000 != 0 {
+		return 0, nil, false, false // too short, or a response
+	}
+	id := binary.BigEndian.Uint16(msg)
+	count := int(binary.BigEndian.Uint16(msg[4:]))
+	offset := 12
+	for i := 0; i < count; i++ {
+		start := offset
+		name, next, err := readName(msg, offset)
+		if err != nil || next+4 > len(msg) {
+			return 0, nil, false, false
+		}
+		qtype := binary.BigEndian.Uint16(msg[next:])
+		qclass := binary.BigEndian.Uint16(msg[next+2:])
+		offset = next + 4
+		if strings.EqualFold(name, r.Name) && (qtype == typeA || qtype == typeANY) && qclass&^unicastBit == classIN {
+			return id, msg[start:offset], qclass&unicastBit != 0, true
+		}
+	}
+	return 0, nil, false, false
+}
+
+// response builds an answer with an A record for each address
+// A question is echoed for legacy unicast replies; ttl 0 says goodbye.
+func (r *Responder) response(id uint16, question []byte, ttl uint32) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[2:], flagsAnswer)
+	if question != nil {
+		binary.BigEndian.PutUint16(msg[4:], 1)
+		// Copy the question with its name uncompressed, since pointers would refer to the query
+		name, _, _ := readName(question, 0)
+		msg = append(msg, encodeName(name)...)
+		msg = append(msg, question[len(question)-4:len(question)-2]...)
+		msg = binary.BigEndian.AppendUint16(msg, classIN)
+	}
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(r.IPs)))
+
+	class := uint16(classIN | cacheFlush)
+	if question != nil {
+		class = classIN // legacy resolvers don't know the cache-flush bit
+	}
+	name := encodeName(r.Name)
+	for _, ip := range r.IPs {
+		msg = append(msg, name...)
+		msg = binary.BigEndian.AppendUint16(msg, typeA)
+		msg = binary.BigEndian.AppendUint16(msg, class)
+		msg = binary.BigEndian.AppendUint32(msg, ttl)
+		msg = binary.BigEndian.AppendUint16(msg, 4)
+		msg = append(msg, ip.To4()...)
+	}
+	return msg
+}
+
+// encodeName writes a dotted name as DNS labels
+func encodeName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// readName decodes the name at offset, following compression pointers, and returns it
+// with the offset just past it
+func readName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; jumps < 16; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("name runs past the message")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, fmt.Errorf("truncated name pointer")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+			jumps++
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, fmt.Errorf("label runs past the message")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, fmt.Errorf("too many name pointers")
+}
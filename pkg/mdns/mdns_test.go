@@ -0,0 +1,111 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		project, agent, want string
+	}{
+		{"myproj", "claude", "myproj-claude.local"},
+		{"My_Project.v2", "codex", "my-project-v2-codex.local"},
+		{"api", "", "api.local"},
+		{"__", "", "packnplay.local"},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.project, tt.agent); got != tt.want {
+			t.Errorf("Name(%q, %q) = %s, want %s", tt.project, tt.agent, got, tt.want)
+		}
+	}
+}
+
+// query builds an mDNS query for name, with a second question pointing back at the first
+func query(id uint16, name string, qtype, qclass uint16) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[4:], 2)
+	msg = append(msg, encodeName("other.local")...)
+	msg = binary.BigEndian.AppendUint16(msg, typeA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	msg = append(msg, encodeName(name)...)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	return binary.BigEndian.AppendUint16(msg, qclass)
+}
+
+func TestMatch(t *testing.T) {
+	r := &Responder{Name: "myproj-claude.local", IPs: []net.IP{net.IPv4(192, 168, 1, 20)}}
+
+	if id, question, unicast, ok := r.match(query(7, "MyProj-Claude.local", typeA, classIN|unicastBit)); !ok || id != 7 || !unicast || len(question) == 0 {
+		t.Errorf("match() = %d, %v, %v, %v; want a unicast match", id, question, unicast, ok)
+	}
+	if _, _, _, ok := r.match(query(0, "myproj-claude.local", 28, classIN)); ok {
+		t.Error("match() answered an AAAA query")
+	}
+	if _, _, _, ok := r.match(query(0, "someone-else.local", typeA, classIN)); ok {
+		t.Error("match() answered for another name")
+	}
+	response := r.response(0, nil, recordTTL)
+	if _, _, _, ok := r.match(response); ok {
+		t.Error("match() answered a response")
+	}
+
+	// A compressed name pointing at the question before it
+	msg := query(0, "x.local", typeA, classIN)
+	msg = msg[:12]
+	binary.BigEndian.PutUint16(msg[4:], 2)
+	msg = append(msg, encodeName("myproj-claude.local")...)
+	msg = binary.BigEndian.AppendUint16(msg, 12)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	msg = append(msg, 0xC0, 12)
+	msg = binary.BigEndian.AppendUint16(msg, typeA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	if _, _, _, ok := r.match(msg); !ok {
+		t.Error("match() missed a compressed name")
+	}
+}
+
+func TestResponse(t *testing.T) {
+	r := &Responder{Name: "myproj-claude.local", IPs: []net.IP{net.IPv4(192, 168, 1, 20), net.IPv4(10, 0, 0, 5)}}
+	msg := r.response(0, nil, recordTTL)
+
+	if flags := binary.BigEndian.Uint16(msg[2:]); flags != flagsAnswer {
+		t.Errorf("flags = %#x", flags)
+	}
+	if answers := binary.BigEndian.Uint16(msg[6:]); answers != 2 {
+		t.Fatalf("answers = %d, want 2", answers)
+	}
+	name, offset, err := readName(msg, 12)
+	if err != nil || name != "myproj-claude.local" {
+		t.Fatalf("readName() = %s, %v", name, err)
+	}
+	if class := binary.BigEndian.Uint16(msg[offset+2:]); class != classIN|cacheFlush {
+		t.Errorf("class = %#x", class)
+	}
+	if ttl := binary.BigEndian.Uint32(msg[offset+4:]); ttl != recordTTL {
+		t.Errorf("ttl = %d", ttl)
+	}
+	if ip := net.IP(msg[offset+10 : offset+14]); !ip.Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Errorf("first address = %s", ip)
+	}
+
+	// Legacy unicast replies echo the query's ID and question
+	q := query(42, "myproj-claude.local", typeA, classIN)
+	id, question, _, _ := r.match(q)
+	reply := r.response(id, question, recordTTL)
+	if binary.BigEndian.Uint16(reply) != 42 || binary.BigEndian.Uint16(reply[4:]) != 1 {
+		t.Errorf("legacy reply header = %v", reply[:12])
+	}
+	if name, _, err := readName(reply, 12); err != nil || name != "myproj-claude.local" {
+		t.Errorf("legacy reply question = %s, %v", name, err)
+	}
+}
+
+func TestReadNameLoop(t *testing.T) {
+	msg := append(make([]byte, 12), 0xC0, 12) // points at itself
+	if _, _, err := readName(msg, 12); err == nil {
+		t.Error("readName() followed a pointer loop")
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+)
+
+// Event types recorded by packnplay commands for the metrics endpoint
+const (
+	EventSessionStart   = "session_start"
+	EventSessionFailure = "session_failure"
+	EventImagePull      = "image_pull"
+)
+
+// Event is one line of the metrics event log
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Agent   string    `json:"agent,omitempty"`
+	Image   string    `json:"image,omitempty"`
+	Seconds float64   `json:"seconds,omitempty"`
+}
+
+// GetEventsPath returns the append-only event log shared by all packnplay processes
+func GetEventsPath() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "metrics", "events.jsonl")
+}
+
+// Record appends an event to the event log
+// Metrics are best-effort: failures are returned for logging but should never fail a run.
+func Record(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	path := GetEventsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics dir: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if data, err = encryption.SealLine(data); err != nil {
+		return fmt.Errorf("failed to encrypt event: %w", err)
+	}
+
+	// O_APPEND writes of a single short line are atomic, so concurrent runs don't interleave
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// ReadEvents loads every event from the log, skipping malformed lines
+func ReadEvents(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, err := encryption.OpenLine(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read event log: %w", err)
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err == nil {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	return events, nil
+}
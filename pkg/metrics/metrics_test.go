@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSessions(t *testing.T) {
+	output := "packnplay-app-main|running|claude|2026-10-15 10:00:00 +0000 UTC|Up 2 hours\n" +
+		"packnplay-app-fix|exited||2026-10-15 09:00:00 +0000 UTC|Exited (137) 5 minutes ago\n" +
+		"garbage line\n"
+
+	sessions := ParseSessions(output)
+	if len(sessions) != 2 {
+		t.Fatalf("ParseSessions() returned %d sessions, want 2", len(sessions))
+	}
+	if !sessions[0].Running || sessions[0].Agent != "claude" || sessions[0].Created.Hour() != 10 {
+		t.Errorf("sessions[0] = %+v", sessions[0])
+	}
+	if sessions[1].Running || sessions[1].ExitCode != 137 {
+		t.Errorf("sessions[1] = %+v", sessions[1])
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		{Name: "packnplay-app-main", Agent: "claude", Running: true, Created: now.Add(-time.Hour)},
+		{Name: "packnplay-app-old", Running: false, ExitCode: 1},
+	}
+	events := []Event{
+		{Type: EventSessionStart, Agent: "claude"},
+		{Type: EventSessionStart, Agent: "claude"},
+		{Type: EventSessionFailure, Agent: "codex"},
+		{Type: EventImagePull, Image: "ubuntu:24.04", Seconds: 1.5},
+		{Type: EventImagePull, Image: "ubuntu:24.04", Seconds: 2.5},
+	}
+
+	var out strings.Builder
+	WritePrometheus(&out, sessions, events, now)
+	text := out.String()
+
+	for _, want := range []string{
+		`packnplay_sessions_running{agent="claude"} 1`,
+		`packnplay_sessions_exited{exit_code="1"} 1`,
+		`packnplay_session_age_seconds{container="packnplay-app-main",agent="claude"} 3600`,
+		`packnplay_sessions_started_total{agent="claude"} 2`,
+		`packnplay_session_failures_total{agent="codex"} 1`,
+		`packnplay_image_pull_seconds_sum{image="ubuntu:24.04"} 4`,
+		`packnplay_image_pull_seconds_count{image="ubuntu:24.04"} 2`,
+		"# TYPE packnplay_sessions_started_total counter",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestRecordAndReadEvents(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := Record(Event{Type: EventSessionStart, Agent: "claude"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := Record(Event{Type: EventImagePull, Image: "img", Seconds: 3}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	// Malformed lines from a torn write are skipped
+	file, err := os.OpenFile(GetEventsPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{not json\n")
+	file.Close()
+
+	events, err := ReadEvents(GetEventsPath())
+	if err != nil {
+		t.Fatalf("ReadEvents() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Agent != "claude" || events[1].Seconds != 3 || events[0].Time.IsZero() {
+		t.Errorf("ReadEvents() = %+v", events)
+	}
+
+	missing, err := ReadEvents(filepath.Join(t.TempDir(), "none.jsonl"))
+	if err != nil || missing != nil {
+		t.Errorf("ReadEvents(missing) = %v, %v", missing, err)
+	}
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session is a packnplay container as seen by the runtime
+type Session struct {
+	Name     string
+	Agent    string
+	Running  bool
+	ExitCode int // only meaningful when not running
+	Created  time.Time
+}
+
+// exitCodePattern extracts the code from docker ps status, e.g. "Exited (137) 2 hours ago"
+var exitCodePattern = regexp.MustCompile(`^Exited \((\d+)\)`)
+
+// psCreatedLayout is docker ps's CreatedAt format
+const psCreatedLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// ParseSessions parses `docker ps -a` lines formatted as name|state|agent|createdAt|status
+func ParseSessions(output string) []Session {
+	var sessions []Session
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) != 5 {
+			continue
+		}
+
+		session := Session{Name: fields[0], Running: fields[1] == "running", Agent: fields[2]}
+		if created, err := time.Parse(psCreatedLayout, fields[3]); err == nil {
+			session.Created = created
+		}
+		if match := exitCodePattern.FindStringSubmatch(fields[4]); match != nil {
+			session.ExitCode, _ = strconv.Atoi(match[1])
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// WritePrometheus writes metrics in the Prometheus text exposition format
+func WritePrometheus(w io.Writer, sessions []Session, events []Event, now time.Time) {
+	// Live state from the runtime
+	running := make(map[string]int)
+	exited := make(map[string]int)
+	for _, session := range sessions {
+		if session.Running {
+			running[agentLabel(session.Agent)]++
+		} else {
+			exited[strconv.Itoa(session.ExitCode)]++
+		}
+	}
+
+	writeHeader(w, "packnplay_sessions_running", "gauge", "Running sandbox containers by agent.")
+	writeSamples(w, "packnplay_sessions_running", "agent", running)
+
+	writeHeader(w, "packnplay_sessions_exited", "gauge", "Stopped sandbox containers by exit code.")
+	writeSamples(w, "packnplay_sessions_exited", "exit_code", exited)
+
+	writeHeader(w, "packnplay_session_age_seconds", "gauge", "Age of each running sandbox, for spotting stuck sessions.")
+	for _, session := range sessions {
+		if session.Running && !session.Created.IsZero() {
+			fmt.Fprintf(w, "packnplay_session_age_seconds{container=%q,agent=%q} %g\n", session.Name, agentLabel(session.Agent), now.Sub(session.Created).Seconds())
+		}
+	}
+
+	// History from the event log
+	started := make(map[string]int)
+	failures := make(map[string]int)
+	pullCount := make(map[string]int)
+	pullSeconds := make(map[string]float64)
+	for _, event := range events {
+		switch event.Type {
+		case EventSessionStart:
+			started[agentLabel(event.Agent)]++
+		case EventSessionFailure:
+			failures[agentLabel(event.Agent)]++
+		case EventImagePull:
+			pullCount[event.Image]++
+			pullSeconds[event.Image] += event.Seconds
+		}
+	}
+
+	writeHeader(w, "packnplay_sessions_started_total", "counter", "Sessions started by agent.")
+	writeSamples(w, "packnplay_sessions_started_total", "agent", started)
+
+	writeHeader(w, "packnplay_session_failures_total", "counter", "Sessions that failed to start by agent.")
+	writeSamples(w, "packnplay_session_failures_total", "agent", failures)
+
+	writeHeader(w, "packnplay_image_pull_seconds", "summary", "Time spent pulling and building images.")
+	for _, image := range sortedKeys(pullCount) {
+		fmt.Fprintf(w, "packnplay_image_pull_seconds_sum{image=%q} %g\n", image, pullSeconds[image])
+		fmt.Fprintf(w, "packnplay_image_pull_seconds_count{image=%q} %d\n", image, pullCount[image])
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSamples(w io.Writer, name, label string, values map[string]int) {
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, key, values[key])
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// agentLabel names sessions that weren't started with a known agent
+func agentLabel(agent string) string {
+	if agent == "" {
+		return "unknown"
+	}
+	return agent
+}
@@ -0,0 +1,198 @@
+package netpolicy
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed lists/*.txt
+var builtinLists embed.FS
+
+// Blocklist is a named set of domains the sandbox must not resolve
+type Blocklist struct {
+	Name    string
+	Domains []string
+}
+
+// BuiltinBlocklists returns the names of the blocklists shipped with packnplay
+func BuiltinBlocklists() []string {
+	entries, err := builtinLists.ReadDir("lists")
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".txt"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetBlocklistsDir returns the directory holding updated blocklists
+// Uses XDG-compliant location: ~/.local/share/packnplay/blocklists
+func GetBlocklistsDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "blocklists")
+}
+
+// LoadBlocklist loads a blocklist by name
+// An updated copy from `packnplay policy update` takes precedence over the builtin list
+func LoadBlocklist(name string) (*Blocklist, error) {
+	updatedPath := filepath.Join(GetBlocklistsDir(), name+".txt")
+	if f, err := os.Open(updatedPath); err == nil {
+		defer f.Close()
+		return &Blocklist{Name: name, Domains: ParseDomains(f)}, nil
+	}
+
+	f, err := builtinLists.Open("lists/" + name + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("unknown blocklist '%s' (available: %s)", name, strings.Join(BuiltinBlocklists(), ", "))
+	}
+	defer f.Close()
+
+	return &Blocklist{Name: name, Domains: ParseDomains(f)}, nil
+}
+
+// ParseDomains reads one domain per line, accepting plain lists and hosts-file format
+// Blank lines and # comments are ignored
+func ParseDomains(r io.Reader) []string {
+	var domains []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Hosts-file format: "0.0.0.0 example.com"
+		domain := fields[0]
+		if len(fields) > 1 {
+			domain = fields[1]
+		}
+
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if !isValidDomain(domain) || seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+
+	return domains
+}
+
+// isValidDomain does a light sanity check so garbage lines don't become --add-host args
+func isValidDomain(domain string) bool {
+	if domain == "" || domain == "localhost" || !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, r := range domain {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		if !isAlnum && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveBlockedDomains loads the named blocklists and merges their domains
+func ResolveBlockedDomains(names []string) ([]string, error) {
+	var domains []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		list, err := LoadBlocklist(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, domain := range list.Domains {
+			if !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	return domains, nil
+}
+
+// Sinkhole addresses for blocked domains, one per address family
+const (
+	sinkholeIPv4 = "0.0.0.0"
+	sinkholeIPv6 = "::"
+)
+
+// HostArgs converts blocked domains to docker --add-host args that sinkhole them
+// Each domain gets both an A and an AAAA entry: with only an IPv4 entry, an IPv6-only
+// lookup falls through to DNS and resolves the real AAAA record (or a DNS64-synthesized
+// one) on dual-stack and NAT64 networks. Only the exact names are blocked: /etc/hosts has
+// no wildcards, so x.webhook.site still resolves through DNS unless it's listed too.
+func HostArgs(domains []string) []string {
+	args := make([]string, 0, len(domains)*4)
+	for _, domain := range domains {
+		args = append(args,
+			"--add-host", fmt.Sprintf("%s:%s", domain, sinkholeIPv4),
+			"--add-host", fmt.Sprintf("%s:%s", domain, sinkholeIPv6),
+		)
+	}
+	return args
+}
+
+// UpdateBlocklist downloads a blocklist from url and stores it for future runs
+func UpdateBlocklist(name, url string) (int, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download blocklist '%s': %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to download blocklist '%s': %s", name, resp.Status)
+	}
+
+	domains := ParseDomains(resp.Body)
+	if len(domains) == 0 {
+		return 0, fmt.Errorf("blocklist '%s' from %s contained no domains", name, url)
+	}
+
+	dir := GetBlocklistsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create blocklists dir: %w", err)
+	}
+
+	content := fmt.Sprintf("# Downloaded from %s on %s\n%s\n", url, time.Now().Format(time.RFC3339), strings.Join(domains, "\n"))
+
+	// Write atomically so a running session never sees a partial list
+	listPath := filepath.Join(dir, name+".txt")
+	tempFile := listPath + ".tmp"
+	if err := os.WriteFile(tempFile, []byte(content), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	if err := os.Rename(tempFile, listPath); err != nil {
+		os.Remove(tempFile)
+		return 0, fmt.Errorf("failed to write blocklist: %w", err)
+	}
+
+	return len(domains), nil
+}
@@ -0,0 +1,96 @@
+package netpolicy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDomains(t *testing.T) {
+	input := `# comment line
+webhook.site
+0.0.0.0 evil.example.com  # hosts-file format
+:: evil6.example.com
+Upper.Example.COM.
+
+localhost
+not a domain
+webhook.site
+`
+	got := ParseDomains(strings.NewReader(input))
+	want := []string{"webhook.site", "evil.example.com", "evil6.example.com", "upper.example.com"}
+
+	if len(got) != len(want) {
+		t.Fatalf("ParseDomains() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseDomains()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuiltinBlocklists(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Setenv("XDG_DATA_HOME", tempDir)
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	for _, name := range []string{"exfiltration", "typosquat"} {
+		list, err := LoadBlocklist(name)
+		if err != nil {
+			t.Fatalf("LoadBlocklist(%s) error = %v", name, err)
+		}
+		if len(list.Domains) == 0 {
+			t.Errorf("LoadBlocklist(%s) returned no domains", name)
+		}
+	}
+
+	if _, err := LoadBlocklist("does-not-exist"); err == nil {
+		t.Error("LoadBlocklist() with unknown name should fail")
+	}
+}
+
+func TestUpdatedBlocklistOverridesBuiltin(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Setenv("XDG_DATA_HOME", tempDir)
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "0.0.0.0 fresh.example.com")
+	}))
+	defer server.Close()
+
+	count, err := UpdateBlocklist("exfiltration", server.URL)
+	if err != nil {
+		t.Fatalf("UpdateBlocklist() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("UpdateBlocklist() count = %d, want 1", count)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "packnplay", "blocklists", "exfiltration.txt")); err != nil {
+		t.Fatalf("Updated blocklist not written: %v", err)
+	}
+
+	domains, err := ResolveBlockedDomains([]string{"exfiltration"})
+	if err != nil {
+		t.Fatalf("ResolveBlockedDomains() error = %v", err)
+	}
+	if len(domains) != 1 || domains[0] != "fresh.example.com" {
+		t.Errorf("ResolveBlockedDomains() = %v, want [fresh.example.com]", domains)
+	}
+}
+
+func TestHostArgs(t *testing.T) {
+	args := HostArgs([]string{"webhook.site"})
+	want := []string{"--add-host", "webhook.site:0.0.0.0", "--add-host", "webhook.site:::"}
+
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("HostArgs() = %v, want %v", args, want)
+	}
+}
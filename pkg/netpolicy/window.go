@@ -0,0 +1,133 @@
+package netpolicy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WindowLabel records on the container when its network window closes (RFC 3339)
+const WindowLabel = "packnplay.network-window-until"
+
+// hostsMarker tags the /etc/hosts entries the cutoff pins, so re-applying replaces them
+const hostsMarker = "# packnplay-window"
+
+// windowChain is the iptables chain holding the cutoff rules
+const windowChain = "PACKNPLAY-WINDOW"
+
+// DefaultWindowAllow are the model APIs still reachable once a network window closes
+var DefaultWindowAllow = []string{
+	"api.anthropic.com",
+	"api.openai.com",
+	"generativelanguage.googleapis.com",
+	"api.x.ai",
+	"api.deepseek.com",
+	"openrouter.ai",
+}
+
+// Window opens the network for a while after start, then cuts it off except for Allow
+type Window struct {
+	OpenFor time.Duration
+	Allow   []string // domains reachable over HTTPS after the window closes
+}
+
+// ParseWindow validates a window config; an empty openFor means no window (nil)
+func ParseWindow(openFor string, allow []string) (*Window, error) {
+	if openFor == "" {
+		if len(allow) > 0 {
+			return nil, fmt.Errorf("network_policy.window.allow needs open_for")
+		}
+		return nil, nil
+	}
+
+	duration, err := time.ParseDuration(openFor)
+	if err != nil || duration < 0 {
+		return nil, fmt.Errorf("invalid network_policy.window.open_for '%s' (use e.g. 5m)", openFor)
+	}
+
+	if len(allow) == 0 {
+		allow = DefaultWindowAllow
+	}
+	for _, domain := range allow {
+		if !isValidDomain(domain) {
+			return nil, fmt.Errorf("invalid domain '%s' in network_policy.window.allow", domain)
+		}
+	}
+	return &Window{OpenFor: duration, Allow: allow}, nil
+}
+
+// ResolveAllowed looks up the IPv4 and IPv6 addresses of each allowed domain on the host
+// Addresses are resolved when the window closes, so they match what the API serves then.
+func ResolveAllowed(domains []string, lookup func(host string) ([]net.IP, error)) (map[string][]net.IP, error) {
+	resolved := make(map[string][]net.IP, len(domains))
+	for _, domain := range domains {
+		ips, err := lookup(domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", domain, err)
+		}
+		resolved[domain] = ips
+	}
+	return resolved, nil
+}
+
+// CutoffScript returns a root shell script that limits the container to HTTPS on the allowed
+// addresses. It pins the allowed domains in /etc/hosts and rejects DNS, so names can't be
+// resolved (or data smuggled out through lookups) any other way. Connections already open
+// to anywhere else stop too, so nothing started in the window outlives it. Running it again replaces
+// the previous rules. It fails, rather than leaving a gap, when IPv6 is routable but
+// ip6tables isn't usable.
+func CutoffScript(resolved map[string][]net.IP) string {
+	domains := make([]string, 0, len(resolved))
+	for domain := range resolved {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "grep -v '%s$' /etc/hosts > /tmp/packnplay-hosts || true\n", hostsMarker)
+	b.WriteString("cat /tmp/packnplay-hosts > /etc/hosts && rm -f /tmp/packnplay-hosts\n")
+
+	var v4, v6 []string
+	for _, domain := range domains {
+		pinned := map[bool]bool{}
+		for _, ip := range resolved[domain] {
+			isV4 := ip.To4() != nil
+			if isV4 {
+				v4 = append(v4, ip.String())
+			} else {
+				v6 = append(v6, ip.String())
+			}
+			// /etc/hosts returns the first entry per family, so pin one of each
+			if !pinned[isV4] {
+				pinned[isV4] = true
+				fmt.Fprintf(&b, "echo '%s %s %s' >> /etc/hosts\n", ip.String(), domain, hostsMarker)
+			}
+		}
+	}
+
+	writeRules := func(tool, dns string, addrs []string) {
+		fmt.Fprintf(&b, "%[1]s -N %[2]s 2>/dev/null || %[1]s -F %[2]s\n", tool, windowChain)
+		fmt.Fprintf(&b, "%[1]s -C OUTPUT -j %[2]s 2>/dev/null || %[1]s -I OUTPUT -j %[2]s\n", tool, windowChain)
+		if dns != "" {
+			// Docker's embedded resolver listens on loopback and forwards anywhere
+			fmt.Fprintf(&b, "%s -A %s -d %s -j REJECT\n", tool, windowChain, dns)
+		}
+		fmt.Fprintf(&b, "%s -A %s -o lo -j ACCEPT\n", tool, windowChain)
+		// Replies on connections the host opened, e.g. published ports. Only the reply direction:
+		// connections the container opened while the window was open are cut off with the rest.
+		fmt.Fprintf(&b, "%s -A %s -m conntrack --ctstate ESTABLISHED,RELATED --ctdir REPLY -j ACCEPT\n", tool, windowChain)
+		for _, addr := range addrs {
+			fmt.Fprintf(&b, "%s -A %s -d %s -p tcp --dport 443 -j ACCEPT\n", tool, windowChain, addr)
+		}
+		fmt.Fprintf(&b, "%s -A %s -j REJECT\n", tool, windowChain)
+	}
+
+	writeRules("iptables", "127.0.0.11", v4)
+	b.WriteString("if awk '$4 == \"00\"' /proc/net/if_inet6 2>/dev/null | grep -q .; then\n")
+	writeRules("ip6tables", "", v6)
+	b.WriteString("fi\n")
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package netpolicy
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	window, err := ParseWindow("", nil)
+	if err != nil || window != nil {
+		t.Fatalf("ParseWindow(\"\") = %v, %v; want no window", window, err)
+	}
+
+	window, err = ParseWindow("5m", nil)
+	if err != nil {
+		t.Fatalf("ParseWindow(5m) error: %v", err)
+	}
+	if window.OpenFor != 5*time.Minute || len(window.Allow) != len(DefaultWindowAllow) {
+		t.Errorf("ParseWindow(5m) = %+v, want 5m with the default model APIs", window)
+	}
+
+	window, err = ParseWindow("90s", []string{"api.anthropic.com"})
+	if err != nil || len(window.Allow) != 1 {
+		t.Errorf("ParseWindow(90s, custom) = %+v, %v", window, err)
+	}
+
+	for _, bad := range []struct {
+		openFor string
+		allow   []string
+	}{
+		{"five minutes", nil},
+		{"-1m", nil},
+		{"", []string{"api.openai.com"}},
+		{"5m", []string{"api.openai.com; rm -rf /"}},
+	} {
+		if _, err := ParseWindow(bad.openFor, bad.allow); err == nil {
+			t.Errorf("ParseWindow(%q, %v) should fail", bad.openFor, bad.allow)
+		}
+	}
+}
+
+func TestResolveAllowed(t *testing.T) {
+	lookup := func(host string) ([]net.IP, error) {
+		if host == "api.anthropic.com" {
+			return []net.IP{net.ParseIP("160.79.104.10")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	resolved, err := ResolveAllowed([]string{"api.anthropic.com"}, lookup)
+	if err != nil || len(resolved["api.anthropic.com"]) != 1 {
+		t.Errorf("ResolveAllowed() = %v, %v", resolved, err)
+	}
+	if _, err := ResolveAllowed([]string{"api.anthropic.com", "nope.example"}, lookup); err == nil {
+		t.Error("ResolveAllowed() should fail when a domain doesn't resolve")
+	}
+}
+
+func TestCutoffScript(t *testing.T) {
+	script := CutoffScript(map[string][]net.IP{
+		"api.anthropic.com": {net.ParseIP("160.79.104.10"), net.ParseIP("160.79.104.11"), net.ParseIP("2607:6bc0::10")},
+	})
+
+	for _, want := range []string{
+		"set -e\n",
+		"echo '160.79.104.10 api.anthropic.com # packnplay-window' >> /etc/hosts",
+		"echo '2607:6bc0::10 api.anthropic.com # packnplay-window' >> /etc/hosts",
+		"iptables -A PACKNPLAY-WINDOW -d 127.0.0.11 -j REJECT",
+		"iptables -A PACKNPLAY-WINDOW -d 160.79.104.11 -p tcp --dport 443 -j ACCEPT",
+		"ip6tables -A PACKNPLAY-WINDOW -d 2607:6bc0::10 -p tcp --dport 443 -j ACCEPT",
+		"iptables -A PACKNPLAY-WINDOW -j REJECT",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+
+	// Only one address per family is pinned; the rest are reachable but not in /etc/hosts
+	if strings.Contains(script, "160.79.104.11 api.anthropic.com") {
+		t.Errorf("script pinned a second IPv4 address:\n%s", script)
+	}
+	// Connections opened during the window don't survive it; only replies to the host's do
+	if !strings.Contains(script, "--ctstate ESTABLISHED,RELATED --ctdir REPLY -j ACCEPT") || strings.Contains(script, "RELATED -j ACCEPT") {
+		t.Errorf("script accepts established connections in both directions:\n%s", script)
+	}
+	// IPv4 addresses never end up in ip6tables rules
+	if strings.Contains(script, "ip6tables -A PACKNPLAY-WINDOW -d 160.") {
+		t.Errorf("IPv4 address in ip6tables rules:\n%s", script)
+	}
+}
@@ -0,0 +1,43 @@
+package nixenv
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Kinds of Nix-defined dev environment a project can have
+const (
+	Flake  = "flake"  // flake.nix with a devShell, entered with nix develop
+	Devenv = "devenv" // devenv.nix, entered with devenv shell
+)
+
+// StorePath holds the Nix store and its database; sessions share it through a volume
+const StorePath = "/nix"
+
+// Off is the project config value that runs commands as-is despite a flake.nix or devenv.nix
+const Off = "off"
+
+// Detect returns the kind of dev environment projectPath defines, or "" for none
+// devenv projects often have a flake.nix as well; devenv.nix means devenv owns the shell.
+func Detect(projectPath string) string {
+	for _, candidate := range []struct{ file, kind string }{{"devenv.nix", Devenv}, {"flake.nix", Flake}} {
+		if info, err := os.Stat(filepath.Join(projectPath, candidate.file)); err == nil && !info.IsDir() {
+			return candidate.kind
+		}
+	}
+	return ""
+}
+
+// Wrap runs command inside the dev environment of the given kind
+func Wrap(kind string, command []string) []string {
+	if len(command) == 0 {
+		return command
+	}
+	switch kind {
+	case Flake:
+		return append([]string{"nix", "develop", "--command"}, command...)
+	case Devenv:
+		return append([]string{"devenv", "shell", "--"}, command...)
+	}
+	return command
+}
@@ -0,0 +1,50 @@
+package nixenv
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	if got := Detect(dir); got != "" {
+		t.Errorf("Detect(empty) = %q, want none", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "flake.nix"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Detect(dir); got != Flake {
+		t.Errorf("Detect(flake.nix) = %q, want %q", got, Flake)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "devenv.nix"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Detect(dir); got != Devenv {
+		t.Errorf("Detect(flake.nix + devenv.nix) = %q, want %q", got, Devenv)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	command := []string{"claude", "--resume"}
+
+	tests := []struct {
+		kind string
+		want []string
+	}{
+		{Flake, []string{"nix", "develop", "--command", "claude", "--resume"}},
+		{Devenv, []string{"devenv", "shell", "--", "claude", "--resume"}},
+		{"", command},
+	}
+	for _, tt := range tests {
+		if got := Wrap(tt.kind, command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Wrap(%q) = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+	if got := Wrap(Flake, nil); len(got) != 0 {
+		t.Errorf("Wrap(no command) = %v, want none", got)
+	}
+}
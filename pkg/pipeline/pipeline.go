@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"gopkg.in/yaml.v3"
+)
+
+// Pipeline is a sequence of headless agent runs, each handed the output of earlier ones
+type Pipeline struct {
+	Name     string `yaml:"name"`
+	Worktree string `yaml:"worktree"` // shared by every step; defaults to pipeline-<name>
+	Steps    []Step `yaml:"steps"`
+}
+
+// Step is one agent run in a fresh sandbox
+type Step struct {
+	Name   string   `yaml:"name"`
+	Agent  string   `yaml:"agent"`
+	Prompt string   `yaml:"prompt"`
+	From   []string `yaml:"from"` // steps whose summary and diff this one sees; defaults to the one before
+}
+
+// Result is what a finished step hands on: what the agent printed and what it changed
+type Result struct {
+	Step    string
+	Agent   string
+	Summary string
+	Diff    []byte
+}
+
+// Load reads and validates a pipeline file
+func Load(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline: %w", err)
+	}
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Validate checks every step names a headless agent and only hands off from earlier steps
+func (p *Pipeline) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("pipeline needs a name")
+	}
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline %s has no steps", p.Name)
+	}
+	seen := make(map[string]bool)
+	for i, step := range p.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("step %d needs a name", i+1)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("step name %s is used twice", step.Name)
+		}
+		agent := agents.Lookup(step.Agent)
+		if agent == nil {
+			return fmt.Errorf("step %s: unknown agent '%s'", step.Name, step.Agent)
+		}
+		if _, ok := agent.(agents.HeadlessRunner); !ok {
+			return fmt.Errorf("step %s: %s has no headless mode packnplay knows how to run", step.Name, agent.Name())
+		}
+		if strings.TrimSpace(step.Prompt) == "" {
+			return fmt.Errorf("step %s needs a prompt", step.Name)
+		}
+		for _, from := range step.From {
+			if !seen[from] {
+				return fmt.Errorf("step %s hands off from %s, which isn't an earlier step", step.Name, from)
+			}
+		}
+		seen[step.Name] = true
+	}
+	return nil
+}
+
+// WorktreeName is the worktree every step works in
+func (p *Pipeline) WorktreeName() string {
+	if p.Worktree != "" {
+		return p.Worktree
+	}
+	return "pipeline-" + p.Name
+}
+
+// Sources returns the steps whose results step i is handed
+func (p *Pipeline) Sources(i int) []string {
+	if len(p.Steps[i].From) > 0 {
+		return p.Steps[i].From
+	}
+	if i == 0 {
+		return nil
+	}
+	return []string{p.Steps[i-1].Name}
+}
+
+// Prompt builds step i's prompt: its own instructions, then each handed-off summary and diff
+func (p *Pipeline) Prompt(i int, results map[string]Result) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(p.Steps[i].Prompt))
+	b.WriteString("\n")
+	for _, name := range p.Sources(i) {
+		result := results[name]
+		fmt.Fprintf(&b, "\n## Handoff from %s (%s)\n\n### Summary\n\n", result.Step, result.Agent)
+		if summary := strings.TrimSpace(result.Summary); summary != "" {
+			b.WriteString(summary)
+		} else {
+			b.WriteString("(no output)")
+		}
+		b.WriteString("\n\n### Diff\n\n")
+		if len(result.Diff) == 0 {
+			b.WriteString("(no changes)\n")
+			continue
+		}
+		b.WriteString("```diff\n")
+		b.Write(result.Diff)
+		if !strings.HasSuffix(string(result.Diff), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("```\n")
+	}
+	return b.String()
+}
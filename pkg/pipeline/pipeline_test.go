@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "review.yaml")
+	content := `name: implement-review
+steps:
+  - name: implement
+    agent: codex
+    prompt: Add a --json flag to the list command.
+  - name: review
+    agent: claude
+    prompt: Review the change below and fix anything wrong.
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(p.Steps) != 2 || p.Steps[1].Agent != "claude" {
+		t.Errorf("steps = %+v", p.Steps)
+	}
+	if p.WorktreeName() != "pipeline-implement-review" {
+		t.Errorf("WorktreeName() = %s", p.WorktreeName())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	step := func(name, agent string, from ...string) Step {
+		return Step{Name: name, Agent: agent, Prompt: "do it", From: from}
+	}
+	tests := []struct {
+		name    string
+		steps   []Step
+		wantErr string
+	}{
+		{"valid", []Step{step("a", "codex"), step("b", "claude", "a")}, ""},
+		{"no steps", nil, "no steps"},
+		{"unknown agent", []Step{step("a", "nope")}, "unknown agent"},
+		{"duplicate", []Step{step("a", "claude"), step("a", "codex")}, "used twice"},
+		{"forward handoff", []Step{step("a", "claude", "b"), step("b", "codex")}, "isn't an earlier step"},
+		{"self handoff", []Step{step("a", "claude", "a")}, "isn't an earlier step"},
+		{"no prompt", []Step{{Name: "a", Agent: "claude"}}, "needs a prompt"},
+	}
+	for _, tt := range tests {
+		err := (&Pipeline{Name: "p", Steps: tt.steps}).Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Validate() error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Validate() = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	p := &Pipeline{Name: "p", Steps: []Step{
+		{Name: "implement", Agent: "codex", Prompt: "Implement it."},
+		{Name: "test", Agent: "gemini", Prompt: "Write tests."},
+		{Name: "review", Agent: "claude", Prompt: "Review.", From: []string{"implement", "test"}},
+	}}
+	results := map[string]Result{
+		"implement": {Step: "implement", Agent: "codex", Summary: "Added the flag.\n", Diff: []byte("--- a/x\n+++ b/x\n")},
+		"test":      {Step: "test", Agent: "gemini"},
+	}
+
+	if got := p.Prompt(0, results); got != "Implement it.\n" {
+		t.Errorf("first step prompt = %q", got)
+	}
+	if got := p.Prompt(1, results); !strings.Contains(got, "## Handoff from implement (codex)") || strings.Contains(got, "## Handoff from test") {
+		t.Errorf("second step should only see the step before it:\n%s", got)
+	}
+
+	got := p.Prompt(2, results)
+	for _, want := range []string{"Review.\n", "## Handoff from implement (codex)", "Added the flag.", "```diff\n--- a/x\n+++ b/x\n```", "## Handoff from test (gemini)", "(no output)", "(no changes)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("review prompt missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Query is the Rego rule policies define; each message in the deny set is one violation
+const Query = "data.packnplay.deny"
+
+// Mount is a bind mount in the container spec
+type Mount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+// Spec is the resolved container spec policies are evaluated against
+// Environment values are never included, only variable names.
+type Spec struct {
+	Image        string            `json:"image"`
+	BaseImage    string            `json:"baseImage"` // configured image that local layers were built on
+	Registry     string            `json:"registry"`  // registry the base image comes from
+	Name         string            `json:"name"`
+	User         string            `json:"user,omitempty"`
+	Workdir      string            `json:"workdir"`
+	Network      string            `json:"network"`
+	Privileged   bool              `json:"privileged"`
+	ReadOnlyRoot bool              `json:"readOnlyRoot"`
+	Hostname     string            `json:"hostname,omitempty"`
+	CapAdd       []string          `json:"capAdd"`
+	Devices      []string          `json:"devices"`
+	SecurityOpts []string          `json:"securityOpts"`
+	Ulimits      []string          `json:"ulimits"`
+	Sysctls      []string          `json:"sysctls"`
+	Mounts       []Mount           `json:"mounts"`
+	Tmpfs        []string          `json:"tmpfs"`
+	Env          []string          `json:"env"`
+	Ports        []string          `json:"ports"`
+	ExtraHosts   []string          `json:"extraHosts"`
+	Labels       map[string]string `json:"labels"`
+	Command      []string          `json:"command"`
+	Runtime      string            `json:"runtime"`
+}
+
+// valueFlags are docker run flags that take a value
+var valueFlags = map[string]bool{
+	"--label": true, "-l": true, "--name": true, "-v": true, "--volume": true, "--mount": true,
+	"-e": true, "--env": true, "-p": true, "--publish": true, "--tmpfs": true,
+	"--add-host": true, "--network": true, "--net": true, "-w": true, "--workdir": true,
+	"-u": true, "--user": true, "--entrypoint": true, "-h": true, "--hostname": true,
+	"--log-driver": true, "--log-opt": true, "--ulimit": true, "--sysctl": true,
+	"--cap-add": true, "--cap-drop": true, "--device": true, "--security-opt": true,
+	"--runtime": true, "--shm-size": true, "-m": true, "--memory": true, "--cpus": true,
+	"--pids-limit": true, "--platform": true, "--group-add": true,
+}
+
+// boolFlags are docker run flags without a value
+var boolFlags = map[string]bool{
+	"-d": true, "--detach": true, "-i": true, "--interactive": true, "-t": true, "--tty": true,
+	"-it": true, "-ti": true, "-dit": true, "--rm": true, "--init": true,
+	"--privileged": true, "--read-only": true,
+}
+
+// ParseRunArgs builds a Spec from `docker run` arguments as packnplay generates them
+// Unknown flags are an error rather than skipped: a flag whose value was taken for the image
+// would hide every mount after it from the checks that read the Spec.
+func ParseRunArgs(args []string) (Spec, error) {
+	spec := Spec{Network: "bridge", Labels: make(map[string]string)}
+
+	i := 0
+	if len(args) > 0 && args[0] == "run" {
+		i = 1
+	}
+	for ; i < len(args); i++ {
+		flag := args[i]
+		if !strings.HasPrefix(flag, "-") {
+			spec.SetBaseImage(flag)
+			spec.Image = flag
+			spec.Command = append([]string{}, args[i+1:]...)
+			break
+		}
+
+		var value string
+		name, inline, hasInline := strings.Cut(flag, "=")
+		switch {
+		case boolFlags[flag]:
+			switch flag {
+			case "--privileged":
+				spec.Privileged = true
+			case "--read-only":
+				spec.ReadOnlyRoot = true
+			}
+			continue
+		case hasInline && strings.HasPrefix(name, "--") && valueFlags[name]:
+			flag, value = name, inline
+		case valueFlags[flag] && i+1 < len(args):
+			i++
+			value = args[i]
+		case valueFlags[flag]:
+			return spec, fmt.Errorf("docker run flag %s has no value", flag)
+		default:
+			return spec, fmt.Errorf("unknown docker run flag %s", flag)
+		}
+
+		switch flag {
+		case "--label", "-l":
+			key, val, _ := strings.Cut(value, "=")
+			spec.Labels[key] = val
+		case "--name":
+			spec.Name = value
+		case "-v", "--volume":
+			spec.Mounts = append(spec.Mounts, parseVolume(value))
+		case "--mount":
+			mount, tmpfs, err := parseMount(value)
+			if err != nil {
+				return spec, err
+			}
+			if tmpfs {
+				spec.Tmpfs = append(spec.Tmpfs, mount.Target)
+			} else {
+				spec.Mounts = append(spec.Mounts, mount)
+			}
+		case "-e", "--env":
+			name, _, _ := strings.Cut(value, "=")
+			spec.Env = append(spec.Env, name)
+		case "-p", "--publish":
+			spec.Ports = append(spec.Ports, value)
+		case "--tmpfs":
+			spec.Tmpfs = append(spec.Tmpfs, value)
+		case "--add-host":
+			spec.ExtraHosts = append(spec.ExtraHosts, value)
+		case "--network", "--net":
+			spec.Network = value
+		case "-w", "--workdir":
+			spec.Workdir = value
+		case "-u", "--user":
+			spec.User = value
+		case "-h", "--hostname":
+			spec.Hostname = value
+		case "--ulimit":
+			spec.Ulimits = append(spec.Ulimits, value)
+		case "--sysctl":
+			spec.Sysctls = append(spec.Sysctls, value)
+		case "--cap-add":
+			spec.CapAdd = append(spec.CapAdd, value)
+		case "--device":
+			spec.Devices = append(spec.Devices, value)
+		case "--security-opt":
+			spec.SecurityOpts = append(spec.SecurityOpts, value)
+		}
+	}
+	if spec.Image == "" {
+		return spec, fmt.Errorf("docker run args name no image")
+	}
+
+	sort.Strings(spec.Env)
+	return spec, nil
+}
+
+// SetBaseImage records the configured image the running image was built from
+// packnplay layers (agents, setup) are local tags, so the registry is taken from the base.
+func (s *Spec) SetBaseImage(image string) {
+	s.BaseImage = image
+	s.Registry = registryOf(image)
+}
+
+// parseVolume parses a -v source:target[:options] value
+func parseVolume(value string) Mount {
+	parts := strings.Split(value, ":")
+	mount := Mount{Source: parts[0]}
+	if len(parts) > 1 {
+		mount.Target = parts[1]
+	}
+	if len(parts) > 2 {
+		for _, option := range strings.Split(parts[2], ",") {
+			if option == "ro" {
+				mount.ReadOnly = true
+			}
+		}
+	}
+	return mount
+}
+
+// parseMount parses a --mount value, reporting whether it is a tmpfs
+func parseMount(value string) (Mount, bool, error) {
+	var mount Mount
+	mountType := "volume"
+	for _, field := range strings.Split(value, ",") {
+		key, val, hasValue := strings.Cut(field, "=")
+		switch key {
+		case "type":
+			mountType = val
+		case "source", "src":
+			mount.Source = val
+		case "target", "destination", "dst":
+			mount.Target = val
+		case "readonly", "ro":
+			mount.ReadOnly = !hasValue || val == "true" || val == "1"
+		}
+	}
+	switch mountType {
+	case "bind", "volume":
+		return mount, false, nil
+	case "tmpfs":
+		return mount, true, nil
+	}
+	return mount, false, fmt.Errorf("unsupported mount type %s in --mount %s", mountType, value)
+}
+
+// registryOf returns the registry host an image is pulled from
+func registryOf(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
+
+// Evaluate runs the policies in paths (Rego files or directories) against spec with the opa CLI
+// Returns the violation messages, sorted; an empty result means the spec is allowed.
+func Evaluate(paths []string, spec Spec) ([]string, error) {
+	opaPath, err := exec.LookPath("opa")
+	if err != nil {
+		return nil, fmt.Errorf("policies are configured but the opa CLI was not found in PATH (https://www.openpolicyagent.org/docs/latest/#running-opa)")
+	}
+
+	input, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal container spec: %w", err)
+	}
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, path := range paths {
+		args = append(args, "--data", path)
+	}
+	args = append(args, Query)
+
+	cmd := exec.Command(opaPath, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("opa eval failed: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseViolations(output)
+}
+
+// evalResult is the subset of `opa eval --format json` output we read
+type evalResult struct {
+	Result []struct {
+		Expressions []struct {
+			Value interface{} `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// parseViolations extracts deny messages from opa eval output
+// An undefined deny rule (no policy defines it) yields no violations.
+func parseViolations(output []byte) ([]string, error) {
+	var result evalResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse opa output: %w", err)
+	}
+
+	var violations []string
+	for _, r := range result.Result {
+		for _, expr := range r.Expressions {
+			values, ok := expr.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s must be a set of messages, got %T", Query, expr.Value)
+			}
+			for _, value := range values {
+				if msg, ok := value.(string); ok {
+					violations = append(violations, msg)
+				} else {
+					encoded, _ := json.Marshal(value)
+					violations = append(violations, string(encoded))
+				}
+			}
+		}
+	}
+	sort.Strings(violations)
+	return violations, nil
+}
+
+// ViolationError reports the rules a container spec broke
+type ViolationError struct {
+	Violations []string
+}
+
+func (e *ViolationError) Error() string {
+	var b strings.Builder
+	b.WriteString("container spec violates policy:")
+	for _, violation := range e.Violations {
+		b.WriteString("\n  - ")
+		b.WriteString(violation)
+	}
+	return b.String()
+}
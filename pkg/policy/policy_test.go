@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	args := []string{
+		"run", "-d", "-it",
+		"--label", "managed-by=packnplay",
+		"--name", "packnplay-app-main",
+		"-v", "/home/me/app:/workspace",
+		"-v", "/home/me/.ssh:/home/vscode/.ssh:ro",
+		"--tmpfs", "/home/vscode:rw,exec",
+		"-w", "/workspace",
+		"-e", "ANTHROPIC_API_KEY=sk-secret",
+		"-e", "IS_SANDBOX=1",
+		"-p", "8080:80",
+		"--add-host", "evil.example:0.0.0.0",
+		"ghcr.io/obra/packnplay-default:latest",
+		"sleep", "infinity",
+	}
+
+	spec, err := ParseRunArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.Image != "ghcr.io/obra/packnplay-default:latest" || spec.Registry != "ghcr.io" {
+		t.Errorf("image = %q registry = %q", spec.Image, spec.Registry)
+	}
+	if spec.Name != "packnplay-app-main" || spec.Workdir != "/workspace" || spec.Network != "bridge" {
+		t.Errorf("spec = %+v", spec)
+	}
+	wantMounts := []Mount{
+		{Source: "/home/me/app", Target: "/workspace"},
+		{Source: "/home/me/.ssh", Target: "/home/vscode/.ssh", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(spec.Mounts, wantMounts) {
+		t.Errorf("mounts = %+v, want %+v", spec.Mounts, wantMounts)
+	}
+	if !reflect.DeepEqual(spec.Env, []string{"ANTHROPIC_API_KEY", "IS_SANDBOX"}) {
+		t.Errorf("env = %v, want names only", spec.Env)
+	}
+	if spec.Labels["managed-by"] != "packnplay" {
+		t.Errorf("labels = %v", spec.Labels)
+	}
+	if !reflect.DeepEqual(spec.Command, []string{"sleep", "infinity"}) {
+		t.Errorf("command = %v", spec.Command)
+	}
+}
+
+func TestParseRunArgsOptions(t *testing.T) {
+	args := []string{
+		"run", "-d",
+		"--log-driver", "json-file", "--log-opt", "max-size=10m",
+		"--hostname", "app", "--ulimit", "nofile=65536", "--sysctl", "net.core.somaxconn=1024",
+		"--log-opt=max-file=3",
+		"-v", "/home/me/app:/workspace",
+		"--mount", "type=bind,source=/home/me/.aws,target=/home/vscode/.aws,readonly",
+		"--mount", "type=tmpfs,destination=/scratch",
+		"ghcr.io/obra/packnplay-default:latest", "sleep", "infinity",
+	}
+	spec, err := ParseRunArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Image != "ghcr.io/obra/packnplay-default:latest" || spec.Hostname != "app" {
+		t.Errorf("spec = %+v", spec)
+	}
+	wantMounts := []Mount{
+		{Source: "/home/me/app", Target: "/workspace"},
+		{Source: "/home/me/.aws", Target: "/home/vscode/.aws", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(spec.Mounts, wantMounts) || !reflect.DeepEqual(spec.Tmpfs, []string{"/scratch"}) {
+		t.Errorf("mounts = %+v, tmpfs = %v", spec.Mounts, spec.Tmpfs)
+	}
+	if !reflect.DeepEqual(spec.Ulimits, []string{"nofile=65536"}) || !reflect.DeepEqual(spec.Sysctls, []string{"net.core.somaxconn=1024"}) {
+		t.Errorf("ulimits = %v, sysctls = %v", spec.Ulimits, spec.Sysctls)
+	}
+
+	// Flags the parser doesn't know fail closed instead of swallowing the mounts after them
+	for _, bad := range [][]string{
+		{"run", "--log-level", "debug", "-v", "/:/host", "image"},
+		{"run", "-v"},
+		{"run", "-d"},
+	} {
+		if _, err := ParseRunArgs(bad); err == nil {
+			t.Errorf("ParseRunArgs(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRegistryOf(t *testing.T) {
+	tests := map[string]string{
+		"ubuntu:24.04":                     "docker.io",
+		"library/ubuntu":                   "docker.io",
+		"ghcr.io/obra/packnplay-default":   "ghcr.io",
+		"localhost:5000/img":               "localhost:5000",
+		"registry.corp.example/team/image": "registry.corp.example",
+	}
+	for image, want := range tests {
+		if got := registryOf(image); got != want {
+			t.Errorf("registryOf(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestParseViolations(t *testing.T) {
+	output := `{"result":[{"expressions":[{"value":["ssh keys may not be mounted","image must come from ghcr.io"],"text":"data.packnplay.deny"}]}]}`
+	violations, err := parseViolations([]byte(output))
+	if err != nil {
+		t.Fatalf("parseViolations() error = %v", err)
+	}
+	want := []string{"image must come from ghcr.io", "ssh keys may not be mounted"}
+	if !reflect.DeepEqual(violations, want) {
+		t.Errorf("parseViolations() = %v, want %v", violations, want)
+	}
+
+	// No policy defines deny: result is empty
+	if violations, err := parseViolations([]byte(`{}`)); err != nil || len(violations) != 0 {
+		t.Errorf("parseViolations(undefined) = %v, %v", violations, err)
+	}
+
+	if _, err := parseViolations([]byte(`{"result":[{"expressions":[{"value":true}]}]}`)); err == nil {
+		t.Error("parseViolations() should reject a non-set deny rule")
+	}
+}
+
+func TestViolationError(t *testing.T) {
+	err := &ViolationError{Violations: []string{"no ssh", "no host network"}}
+	if !strings.Contains(err.Error(), "\n  - no ssh\n  - no host network") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	if _, err := exec.LookPath("opa"); err != nil {
+		t.Skip("opa CLI not installed")
+	}
+
+	dir := t.TempDir()
+	rego := `package packnplay
+
+import rego.v1
+
+deny contains msg if {
+	input.mounts[_].target == "/home/vscode/.ssh"
+	msg := "ssh keys may not be mounted"
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "mounts.rego"), []byte(rego), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := Spec{Mounts: []Mount{{Source: "/home/me/.ssh", Target: "/home/vscode/.ssh"}}}
+	violations, err := Evaluate([]string{dir}, spec)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(violations) != 1 || violations[0] != "ssh keys may not be mounted" {
+		t.Errorf("Evaluate() = %v", violations)
+	}
+}
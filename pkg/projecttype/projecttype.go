@@ -0,0 +1,83 @@
+package projecttype
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// markers are the root files that identify each project type, in the order types are
+// tried, so a Go service with a package.json for its frontend tooling is a Go project
+var markers = []struct {
+	Type  string
+	Files []string
+}{
+	{"go", []string{"go.mod"}},
+	{"rust", []string{"Cargo.toml"}},
+	{"java", []string{"pom.xml", "build.gradle", "build.gradle.kts"}},
+	{"python", []string{"pyproject.toml", "requirements.txt", "setup.py", "Pipfile"}},
+	{"ruby", []string{"Gemfile"}},
+	{"php", []string{"composer.json"}},
+	{"elixir", []string{"mix.exs"}},
+	{"node", []string{"package.json"}},
+}
+
+// Types returns the project types packnplay detects
+func Types() []string {
+	types := make([]string, 0, len(markers))
+	for _, marker := range markers {
+		types = append(types, marker.Type)
+	}
+	return types
+}
+
+// Detect returns the types of the project at path, most specific first
+func Detect(projectPath string) []string {
+	var types []string
+	for _, marker := range markers {
+		for _, file := range marker.Files {
+			if _, err := os.Stat(filepath.Join(projectPath, file)); err == nil {
+				types = append(types, marker.Type)
+				break
+			}
+		}
+	}
+	return types
+}
+
+// Validate checks that images only maps project types packnplay detects
+func Validate(images map[string]string) error {
+	known := make(map[string]bool)
+	for _, t := range Types() {
+		known[t] = true
+	}
+	var unknown []string
+	for t, image := range images {
+		if !known[t] {
+			unknown = append(unknown, t)
+		} else if image == "" {
+			return fmt.Errorf("language_images.%s is empty", t)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown project type(s) in language_images: %s (use %s)", strings.Join(unknown, ", "), strings.Join(Types(), ", "))
+	}
+	return nil
+}
+
+// Image returns the base image for the project at path: the image configured for its
+// first detected type that has one, or fallback. The type is "" when fallback is used.
+func Image(projectPath string, images map[string]string, fallback string) (string, string) {
+	if len(images) == 0 {
+		return fallback, ""
+	}
+	for _, t := range Detect(projectPath) {
+		if image := images[t]; image != "" {
+			return image, t
+		}
+	}
+	return fallback, ""
+}
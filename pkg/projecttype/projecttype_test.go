@@ -0,0 +1,65 @@
+package projecttype
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	if got := Detect(dir); got != nil {
+		t.Errorf("Detect(empty) = %v", got)
+	}
+	for _, file := range []string{"package.json", "go.mod", "requirements.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := Detect(dir), []string{"go", "python", "node"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect() = %v, want %v", got, want)
+	}
+}
+
+func TestImage(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"package.json", "go.mod"} {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fallback := "ghcr.io/obra/packnplay-default:latest"
+
+	tests := []struct {
+		name      string
+		images    map[string]string
+		wantImage string
+		wantType  string
+	}{
+		{"no mapping", nil, fallback, ""},
+		{"most specific type wins", map[string]string{"go": "golang:1.23-bookworm", "node": "node:22"}, "golang:1.23-bookworm", "go"},
+		{"later type when earlier is unmapped", map[string]string{"node": "node:22"}, "node:22", "node"},
+		{"undetected type", map[string]string{"rust": "rust:1"}, fallback, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, typ := Image(dir, tt.images, fallback)
+			if image != tt.wantImage || typ != tt.wantType {
+				t.Errorf("Image() = %s, %s; want %s, %s", image, typ, tt.wantImage, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(map[string]string{"go": "golang:1.23", "node": "node:22"}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := Validate(map[string]string{"golang": "golang:1.23"}); err == nil {
+		t.Error("Validate() accepted an unknown type")
+	}
+	if err := Validate(map[string]string{"go": ""}); err == nil {
+		t.Error("Validate() accepted an empty image")
+	}
+}
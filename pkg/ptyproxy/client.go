@@ -0,0 +1,158 @@
+package ptyproxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// Result is how an attached session ended
+type Result struct {
+	Detached bool // the user detached; the program is still running
+	ExitCode int  // the program's exit code, when it exited
+}
+
+// Attach connects this process's terminal to a held session until its program exits or the
+// user types the detach keys. Meanwhile the terminal is in raw mode, so Ctrl-C and the like
+// reach the program instead of stopping packnplay, and nothing is echoed twice. SIGHUP and
+// SIGTERM, e.g. from closing the terminal window, detach too. status, if set, is drawn
+// along the terminal's edge.
+func Attach(conn net.Conn, keys []byte, status *StatusBar) (Result, error) {
+	fd := os.Stdin.Fd()
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to put terminal in raw mode: %w", err)
+		}
+		defer func() { _ = term.Restore(fd, state) }()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(stop)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	sizes := make(chan [2]int, 1)
+	go func() {
+		for {
+			if w, h, err := term.GetSize(os.Stdout.Fd()); err == nil {
+				sizes <- [2]int{w, h}
+			}
+			if _, ok := <-winch; !ok {
+				return
+			}
+		}
+	}()
+
+	return proxy(conn, os.Stdin, os.Stdout, keys, status, sizes, stop)
+}
+
+// proxy passes input, resizes and output between the terminal and the session
+func proxy(conn net.Conn, stdin io.Reader, stdout io.Writer, keys []byte, status *StatusBar, sizes <-chan [2]int, stop <-chan os.Signal) (Result, error) {
+	defer conn.Close()
+	frames := &frameWriter{w: conn}
+	bar := newBar(status, stdout)
+
+	detach := make(chan struct{})
+	go func() {
+		input := NewInput(keys)
+		if status != nil && len(status.ToggleKeys) > 0 {
+			input.WithToggle(status.ToggleKeys, func() { _ = frames.resize(bar.toggle()) })
+		}
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				out, detached := input.Feed(buf[:n])
+				if len(out) > 0 && frames.write(frameData, out) != nil {
+					return
+				}
+				if detached {
+					close(detach)
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var ticks <-chan time.Time
+	if status != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case size := <-sizes:
+				bar.resize(size[0], size[1])
+				if frames.resize(bar.programSize(size[0], size[1])) != nil {
+					return
+				}
+			case <-ticks:
+				bar.tick()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Output is read here, so everything shown before a detach is also tracked in modes
+	var modes Modes
+	type ending struct {
+		code int
+		err  error
+	}
+	ended := make(chan ending, 1)
+	reading := make(chan struct{})
+	go func() {
+		defer close(reading)
+		for {
+			kind, payload, err := readFrame(conn)
+			if err != nil {
+				ended <- ending{err: fmt.Errorf("lost the session's terminal (attached elsewhere, or its holder stopped)")}
+				return
+			}
+			switch kind {
+			case frameData:
+				_, _ = modes.Write(payload)
+				bar.write(payload)
+			case frameExit:
+				code, _ := parseExit(payload)
+				ended <- ending{code: code}
+				return
+			}
+		}
+	}()
+
+	var result Result
+	var err error
+	select {
+	case <-detach:
+		result.Detached = true
+	case <-stop:
+		result.Detached = true
+	case end := <-ended:
+		result.ExitCode, err = end.code, end.err
+	}
+
+	// Hand the terminal back the way the user's shell expects it
+	conn.Close()
+	<-reading
+	bar.close()
+	_, _ = stdout.Write(modes.Reset())
+	return result, err
+}
@@ -0,0 +1,83 @@
+package ptyproxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Frame kinds on the connection between a held session and its client
+const (
+	frameData   byte = 'd' // terminal bytes: keyboard input to the server, output to the client
+	frameResize byte = 'r' // client to server: width and height as two uint16s
+	frameExit   byte = 'x' // server to client: the program's exit code as an int32
+)
+
+// maxFrame bounds a frame's payload, so a bad peer can't make the other side allocate without limit
+const maxFrame = 1 << 20
+
+// frameWriter writes whole frames, one at a time
+type frameWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (f *frameWriter) write(kind byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.w.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *frameWriter) resize(width, height int) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload, uint16(width))
+	binary.BigEndian.PutUint16(payload[2:], uint16(height))
+	return f.write(frameResize, payload)
+}
+
+func (f *frameWriter) exit(code int) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(int32(code)))
+	return f.write(frameExit, payload)
+}
+
+// readFrame reads the next frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrame {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// parseResize reads a resize frame's payload
+func parseResize(payload []byte) (int, int, bool) {
+	if len(payload) != 4 {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:])), true
+}
+
+// parseExit reads an exit frame's payload
+func parseExit(payload []byte) (int, bool) {
+	if len(payload) != 4 {
+		return 0, false
+	}
+	return int(int32(binary.BigEndian.Uint32(payload))), true
+}
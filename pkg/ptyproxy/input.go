@@ -0,0 +1,91 @@
+package ptyproxy
+
+import "bytes"
+
+// Bracketed paste markers the terminal wraps pastes in, once a program asks for them
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// Input watches what the user types for the detach keys, passing everything else on
+// A pasted text is passed on whole even if it contains the detach keys, and a detach key
+// that turns out not to start the sequence is passed on with the key that followed it.
+type Input struct {
+	keys     []byte
+	toggle   []byte // keys that show and hide the status bar, if any
+	onToggle func()
+	held     []byte // keys typed so far that may still complete a sequence
+	pasting  bool
+	marker   int // bytes of the next paste marker seen so far
+}
+
+// NewInput returns an Input that detaches on keys
+func NewInput(keys []byte) *Input {
+	return &Input{keys: keys}
+}
+
+// WithToggle also calls onToggle whenever keys are typed; they may share a prefix with
+// the detach keys, e.g. ctrl-p,ctrl-s next to ctrl-p,ctrl-q
+func (in *Input) WithToggle(keys []byte, onToggle func()) *Input {
+	in.toggle, in.onToggle = keys, onToggle
+	return in
+}
+
+// Feed returns what to pass on from p, and whether the detach keys were typed
+// Anything typed after the detach keys is dropped.
+func (in *Input) Feed(p []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		if in.pasting {
+			out = append(out, b)
+			in.trackMarker(b, pasteEnd)
+			continue
+		}
+
+		in.held = append(in.held, b)
+		for len(in.held) > 0 {
+			switch {
+			case len(in.keys) > 0 && bytes.Equal(in.held, in.keys):
+				in.held = nil
+				return out, true
+			case len(in.toggle) > 0 && bytes.Equal(in.held, in.toggle):
+				in.held = nil
+				in.onToggle()
+			case bytes.HasPrefix(in.keys, in.held) || bytes.HasPrefix(in.toggle, in.held):
+				// Wait for the next key to tell
+			default:
+				// Not a sequence after all: pass on its first key and look at the rest again
+				out = append(out, in.held[0])
+				in.trackMarker(in.held[0], pasteStart)
+				in.held = in.held[1:]
+				if in.pasting {
+					for _, rest := range in.held {
+						out = append(out, rest)
+						in.trackMarker(rest, pasteEnd)
+					}
+					in.held = nil
+				}
+				continue
+			}
+			break
+		}
+	}
+	return out, false
+}
+
+// trackMarker follows b through marker, flipping in and out of a paste when it completes
+func (in *Input) trackMarker(b byte, marker []byte) {
+	switch {
+	case b == marker[in.marker]:
+		in.marker++
+	case b == marker[0]:
+		in.marker = 1
+	default:
+		in.marker = 0
+	}
+	if in.marker == len(marker) {
+		in.marker = 0
+		in.pasting = !in.pasting
+	}
+}
@@ -0,0 +1,37 @@
+package ptyproxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDetachKeys are the keys that detach from a held session unless configured otherwise
+const DefaultDetachKeys = "ctrl-p,ctrl-q"
+
+// DefaultToggleKeys show and hide the status bar unless configured otherwise
+const DefaultToggleKeys = "ctrl-p,ctrl-b"
+
+// ParseKeys turns a detach key spec in Docker's format, e.g. "ctrl-p,ctrl-q" or "ctrl-a,d",
+// into the bytes the terminal sends for it
+func ParseKeys(spec string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		switch {
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		case len(key) == 6 && strings.HasPrefix(strings.ToLower(key), "ctrl-"):
+			c := key[5]
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			if c < '@' || c > '_' {
+				return nil, fmt.Errorf("invalid detach key '%s' (ctrl- takes a-z, @, [, \\, ], ^ or _)", key)
+			}
+			keys = append(keys, c&0x1f)
+		default:
+			return nil, fmt.Errorf("invalid detach key '%s' in '%s' (use e.g. ctrl-p,ctrl-q)", key, spec)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,87 @@
+package ptyproxy
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// trackedModes are the DEC private modes a program can leave the terminal in, with whether
+// each is on by default: cursor keys, cursor visibility, mouse reporting, focus events,
+// the alternate screen and bracketed paste
+var trackedModes = map[int]bool{
+	1: false, 25: true, 1000: false, 1002: false, 1003: false, 1004: false,
+	1006: false, 1049: false, 2004: false,
+}
+
+var (
+	privateMode = regexp.MustCompile(`\x1b\[\?([0-9;]+)([hl])`)
+	// unfinishedMode matches the start of a mode sequence cut off at the end of a read
+	unfinishedMode = regexp.MustCompile(`^\x1b(\[(\?[0-9;]*)?)?$`)
+)
+
+// Modes follows the terminal modes a program turns on and off in its output
+// A detaching client turns them off again, so the user's shell doesn't get mouse reports or
+// bracketed pastes, and a client attaching later turns them back on for the program.
+type Modes struct {
+	set     map[int]bool
+	partial []byte
+}
+
+// Write scans program output for mode changes
+func (m *Modes) Write(p []byte) (int, error) {
+	data := append(m.partial, p...)
+	m.partial = nil
+	for _, match := range privateMode.FindAllSubmatch(data, -1) {
+		on := string(match[2]) == "h"
+		for _, field := range bytes.Split(match[1], []byte(";")) {
+			mode, err := strconv.Atoi(string(field))
+			if _, tracked := trackedModes[mode]; err != nil || !tracked {
+				continue
+			}
+			if m.set == nil {
+				m.set = make(map[int]bool)
+			}
+			m.set[mode] = on
+		}
+	}
+	if i := bytes.LastIndexByte(data, 0x1b); i >= 0 && unfinishedMode.Match(data[i:]) {
+		m.partial = append([]byte(nil), data[i:]...)
+	}
+	return len(p), nil
+}
+
+// Reset returns the sequences that put the terminal back in its default modes
+func (m *Modes) Reset() []byte {
+	return m.sequences(func(mode int, on bool) (bool, bool) { return trackedModes[mode], on != trackedModes[mode] })
+}
+
+// Restore returns the sequences that put a terminal in the program's current modes
+func (m *Modes) Restore() []byte {
+	return m.sequences(func(mode int, on bool) (bool, bool) { return on, on != trackedModes[mode] })
+}
+
+// sequences emits, in mode order, the setting pick chooses for each mode it wants changed
+func (m *Modes) sequences(pick func(mode int, on bool) (setting bool, emit bool)) []byte {
+	modes := make([]int, 0, len(m.set))
+	for mode := range m.set {
+		modes = append(modes, mode)
+	}
+	sort.Ints(modes)
+
+	var out bytes.Buffer
+	for _, mode := range modes {
+		setting, emit := pick(mode, m.set[mode])
+		if !emit {
+			continue
+		}
+		final := 'l'
+		if setting {
+			final = 'h'
+		}
+		fmt.Fprintf(&out, "\x1b[?%d%c", mode, final)
+	}
+	return out.Bytes()
+}
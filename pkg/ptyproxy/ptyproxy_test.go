@@ -0,0 +1,254 @@
+package ptyproxy
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []byte
+	}{
+		{"ctrl-p,ctrl-q", []byte{0x10, 0x11}},
+		{"ctrl-a,d", []byte{0x01, 'd'}},
+		{"CTRL-[", []byte{0x1b}},
+		{"ctrl-@", []byte{0x00}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeys(tt.spec)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("ParseKeys(%q) = %v, %v; want %v", tt.spec, got, err, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "ctrl-1", "alt-x", "ctrl-p,,ctrl-q", "ctrl-pq"} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) should fail", spec)
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	keys := []byte{0x10, 0x11} // ctrl-p, ctrl-q
+
+	in := NewInput(keys)
+	out, detach := in.Feed([]byte("ls\x10"))
+	if string(out) != "ls" || detach {
+		t.Errorf("Feed() held key: out %q, detach %v", out, detach)
+	}
+	out, detach = in.Feed([]byte("\x11typed after"))
+	if len(out) != 0 || !detach {
+		t.Errorf("Feed() completing the keys: out %q, detach %v", out, detach)
+	}
+
+	// ctrl-p on its own is emacs-style "previous line", and has to reach the agent
+	in = NewInput(keys)
+	if out, detach := in.Feed([]byte("\x10\x10a")); string(out) != "\x10\x10a" || detach {
+		t.Errorf("Feed() of a broken sequence: out %q, detach %v", out, detach)
+	}
+
+	// A paste containing the keys is passed on whole, split across reads or not
+	in = NewInput(keys)
+	paste := "\x1b[200~binary \x10\x11 data\x1b[201~"
+	got, detached := []byte{}, false
+	for _, chunk := range []string{paste[:4], paste[4:15], paste[15:]} {
+		out, detach := in.Feed([]byte(chunk))
+		got, detached = append(got, out...), detached || detach
+	}
+	if string(got) != paste || detached {
+		t.Errorf("Feed() of a paste: out %q, detach %v", got, detached)
+	}
+	if _, detach := in.Feed(keys); !detach {
+		t.Error("Feed() after a paste ended should detach on the keys again")
+	}
+}
+
+func TestInputToggle(t *testing.T) {
+	toggles := 0
+	in := NewInput([]byte{0x10, 0x11}).WithToggle([]byte{0x10, 0x13}, func() { toggles++ })
+
+	out, detach := in.Feed([]byte("a\x10\x13b\x10x"))
+	if string(out) != "ab\x10x" || detach || toggles != 1 {
+		t.Errorf("Feed() = %q, %v with %d toggles; want the toggle keys swallowed", out, detach, toggles)
+	}
+	if _, detach := in.Feed([]byte{0x10, 0x11}); !detach {
+		t.Error("Feed() should still detach when the toggle keys share a prefix")
+	}
+}
+
+func TestUnfinished(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"plain", ""},
+		{"\x1b[0mdone", ""},
+		{"text\x1b[38;5", "\x1b[38;5"},
+		{"\x1b]0;title", "\x1b]0;title"},
+		{"\x1b]0;title\x07", ""},
+		{"\x1b]0;title\x1b\\", ""},
+		{"\x1b(", "\x1b("},
+		{"\x1b[1m caf\xc3", "\xc3"},
+		{"\x1b", "\x1b"},
+	}
+	for _, tt := range tests {
+		if got := string(unfinished([]byte(tt.data))); got != tt.want {
+			t.Errorf("unfinished(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestStatusBar(t *testing.T) {
+	var screen bytes.Buffer
+	b := newBar(&StatusBar{Text: func(width int) string { return "session " + strings.Repeat("x", 20) }}, &screen)
+
+	if w, h := b.programSize(10, 5); w != 10 || h != 4 {
+		t.Errorf("programSize() = %d, %d; want a row less", w, h)
+	}
+	b.resize(10, 5)
+	if got := screen.String(); !strings.Contains(got, "\x1b[1;4r") || !strings.Contains(got, "\x1b[5;1H\x1b[0;7msession xx\x1b[0m") {
+		t.Errorf("resize() drew %q", got)
+	}
+
+	// No redraw in the middle of a sequence, then one once it's done
+	screen.Reset()
+	b.write([]byte("hi\x1b[3"))
+	if screen.String() != "hi\x1b[3" {
+		t.Errorf("write() redrew mid-sequence: %q", screen.String())
+	}
+	b.write([]byte("1m"))
+	if !strings.Contains(screen.String(), "\x1b[0;7m") {
+		t.Errorf("write() didn't redraw after the sequence ended: %q", screen.String())
+	}
+
+	// Hiding gives the program the whole screen back
+	screen.Reset()
+	if w, h := b.toggle(); w != 10 || h != 5 {
+		t.Errorf("toggle() = %d, %d; want the full size", w, h)
+	}
+	if !strings.Contains(screen.String(), "\x1b[r") {
+		t.Errorf("toggle() didn't reset the scroll region: %q", screen.String())
+	}
+	screen.Reset()
+	b.write([]byte("out"))
+	b.tick()
+	if screen.String() != "out" {
+		t.Errorf("a hidden bar was drawn: %q", screen.String())
+	}
+}
+
+func TestModes(t *testing.T) {
+	var m Modes
+	m.Write([]byte("\x1b[?1049h\x1b[?25l\x1b[?20"))
+	m.Write([]byte("04h\x1b[?1000;1006h\x1b[?1000l\x1b[?7777h"))
+
+	if got, want := string(m.Reset()), "\x1b[?25h\x1b[?1006l\x1b[?1049l\x1b[?2004l"; got != want {
+		t.Errorf("Reset() = %q, want %q", got, want)
+	}
+	if got, want := string(m.Restore()), "\x1b[?25l\x1b[?1006h\x1b[?1049h\x1b[?2004h"; got != want {
+		t.Errorf("Restore() = %q, want %q", got, want)
+	}
+
+	var fresh Modes
+	if len(fresh.Reset()) != 0 || len(fresh.Restore()) != 0 {
+		t.Error("a program that changed no modes needs nothing reset or restored")
+	}
+}
+
+// startServer holds a shell script's terminal on a socket in a temp dir
+func startServer(t *testing.T, script string) (string, <-chan int) {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "t.sock")
+	listener, err := Listen(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := Start(exec.Command("/bin/sh", "-c", script), 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make(chan int, 1)
+	go func() {
+		code, err := server.Serve(listener)
+		if err != nil {
+			t.Errorf("Serve() error: %v", err)
+		}
+		codes <- code
+	}()
+	return socketPath, codes
+}
+
+func TestDetachAndReattach(t *testing.T) {
+	socketPath, codes := startServer(t, `printf '\033[?2004h'; read line; echo "got $line"; exit 3`)
+
+	// First client detaches without the program noticing
+	conn, err := Dial(socketPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var screen bytes.Buffer
+	result, err := proxy(conn, strings.NewReader("\x10\x11"), &screen, []byte{0x10, 0x11}, nil, nil, nil)
+	if err != nil || !result.Detached {
+		t.Fatalf("proxy() = %+v, %v; want detached", result, err)
+	}
+
+	// The second gets the program's modes back, and its exit code
+	conn, err = Dial(socketPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin, typing := io.Pipe()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		typing.Write([]byte("hello\n"))
+	}()
+	screen.Reset()
+	result, err = proxy(conn, stdin, &screen, []byte{0x10, 0x11}, nil, nil, nil)
+	if err != nil || result.Detached || result.ExitCode != 3 {
+		t.Fatalf("proxy() = %+v, %v; want exit code 3", result, err)
+	}
+	output := screen.String()
+	if !strings.HasPrefix(output, "\x1b[?2004h") || !strings.Contains(output, "got hello") || !strings.HasSuffix(output, "\x1b[?2004l") {
+		t.Errorf("second client saw %q", output)
+	}
+
+	select {
+	case code := <-codes:
+		if code != 3 {
+			t.Errorf("Serve() = %d, want 3", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() didn't return after the program exited")
+	}
+}
+
+func TestLargePaste(t *testing.T) {
+	// Raw mode, as an agent's TUI sets it, and a byte count of everything read
+	socketPath, _ := startServer(t, `stty raw -echo; head -c 200000 | wc -c; exit 0`)
+	conn, err := Dial(socketPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paste := "\x1b[200~" + strings.Repeat("x\x10\x11", (200000-12)/3) + "\x1b[201~"
+	paste += strings.Repeat("y", 200000-len(paste))
+	stdin, typing := io.Pipe()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		typing.Write([]byte(paste))
+	}()
+	var screen bytes.Buffer
+	result, err := proxy(conn, stdin, &screen, []byte{0x10, 0x11}, nil, nil, nil)
+	if err != nil || result.Detached {
+		t.Fatalf("proxy() = %+v, %v", result, err)
+	}
+	if !strings.Contains(screen.String(), "200000") {
+		t.Errorf("program didn't read the whole paste: %q", screen.String())
+	}
+}
@@ -0,0 +1,158 @@
+package ptyproxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/creack/pty"
+)
+
+// Server holds a program's pseudo-terminal, so clients can detach from it and attach again
+// while the program keeps running. One client is attached at a time; a new one takes over.
+type Server struct {
+	Output   io.Writer               // also gets all output, e.g. a recording (optional)
+	OnResize func(width, height int) // called when a client resizes the terminal (optional)
+
+	cmd    *exec.Cmd
+	ptmx   *os.File
+	mu     sync.Mutex
+	modes  Modes
+	client *frameWriter
+	conn   net.Conn
+	redraw bool // the attached client hasn't sized the terminal yet
+}
+
+// Start runs cmd under a pseudo-terminal of the given size
+func Start(cmd *exec.Cmd, width, height int) (*Server, error) {
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s under a pty: %w", cmd.Path, err)
+	}
+	return &Server{cmd: cmd, ptmx: ptmx}, nil
+}
+
+// Serve attaches clients from listener until the program exits, and returns its exit code
+// The listener is closed on return.
+func (s *Server) Serve(listener net.Listener) (int, error) {
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.attach(conn)
+		}
+	}()
+
+	// Reading the pty fails with EIO once the program exits; that's the normal end of output
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.ptmx.Read(buf)
+		if n > 0 {
+			s.output(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+	listener.Close()
+	s.ptmx.Close()
+
+	code := 0
+	if err := s.cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return 0, fmt.Errorf("failed to wait for %s: %w", s.cmd.Path, err)
+		}
+		code = exitErr.ExitCode()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		_ = s.client.exit(code)
+		s.conn.Close()
+	}
+	return code, nil
+}
+
+// output passes program output on to the modes, the optional Output and the client
+// Without a client it's dropped once tracked, so the program never blocks on a full pty.
+func (s *Server) output(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = s.modes.Write(data)
+	if s.Output != nil {
+		_, _ = s.Output.Write(data)
+	}
+	if s.client != nil && s.client.write(frameData, data) != nil {
+		s.detach()
+	}
+}
+
+// attach makes conn the client, putting its terminal in the program's modes, and feeds
+// it input and resizes until it goes away
+func (s *Server) attach(conn net.Conn) {
+	client := &frameWriter{w: conn}
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.detach()
+	}
+	if restore := s.modes.Restore(); len(restore) > 0 {
+		_ = client.write(frameData, restore)
+	}
+	s.client, s.conn, s.redraw = client, conn, true
+	s.mu.Unlock()
+
+	for {
+		kind, payload, err := readFrame(conn)
+		if err != nil {
+			break
+		}
+		if kind == frameData {
+			if _, err := s.ptmx.Write(payload); err != nil {
+				break
+			}
+		} else if width, height, ok := parseResize(payload); kind == frameResize && ok {
+			s.resize(conn, width, height)
+		}
+	}
+
+	s.mu.Lock()
+	if s.conn == conn {
+		s.detach()
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// resize sizes the pty for the client's terminal
+// A newly attached client's first resize also makes the program redraw, by briefly changing
+// the size: the kernel only signals the program when the size actually changes.
+func (s *Server) resize(conn net.Conn, width, height int) {
+	s.mu.Lock()
+	redraw := s.redraw && s.conn == conn
+	s.redraw = false
+	s.mu.Unlock()
+
+	if redraw && height > 1 {
+		_ = pty.Setsize(s.ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height - 1)})
+	}
+	_ = pty.Setsize(s.ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+	if s.OnResize != nil {
+		s.OnResize(width, height)
+	}
+}
+
+// detach drops the current client; s.mu must be held
+func (s *Server) detach() {
+	s.conn.Close()
+	s.client, s.conn = nil, nil
+}
@@ -0,0 +1,196 @@
+package ptyproxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+// StatusBar is a line of session status an attached client draws below (or above) the
+// program. The program is told the terminal is a line shorter, and a scroll region keeps
+// its output off the bar.
+type StatusBar struct {
+	Text       func(width int) string // the bar's contents, redrawn every second and after output
+	Top        bool                   // draw above the program; programs with their own scroll regions may draw over it
+	ToggleKeys []byte                 // keys that show and hide the bar (optional)
+	Hidden     bool                   // start hidden, until the toggle keys are typed
+}
+
+// maxTail bounds the unfinished sequence held back from the end of output, e.g. a long
+// window title cut off mid-read
+const maxTail = 4096
+
+// regionReset matches output that puts the scroll region back to the whole screen
+var regionReset = regexp.MustCompile(`\x1b\[;?r|\x1bc`)
+
+// bar draws a StatusBar between the program's writes, never in the middle of an escape
+// sequence or character
+type bar struct {
+	*StatusBar
+	mu      sync.Mutex
+	out     io.Writer
+	width   int
+	height  int
+	visible bool
+	tail    []byte // unfinished sequence at the end of the output so far
+}
+
+func newBar(status *StatusBar, out io.Writer) *bar {
+	b := &bar{StatusBar: status, out: out}
+	if status != nil {
+		b.visible = !status.Hidden
+	}
+	return b
+}
+
+// programSize is the terminal size the program is told about
+func (b *bar) programSize(width, height int) (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.shown(height) {
+		return width, height - 1
+	}
+	return width, height
+}
+
+// shown reports whether the bar is drawn on a terminal of height rows; b.mu must be held
+func (b *bar) shown(height int) bool {
+	return b.StatusBar != nil && b.visible && height > 2
+}
+
+// resize makes room for the bar on a terminal of the new size
+func (b *bar) resize(width, height int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.width, b.height = width, height
+	if b.shown(height) {
+		_, _ = b.out.Write(append(b.region(), b.draw()...))
+	}
+}
+
+// write passes program output on, then redraws the bar over anything that hit it
+func (b *bar) write(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, _ = b.out.Write(p)
+	if !b.shown(b.height) {
+		return
+	}
+	b.tail = append([]byte(nil), unfinished(append(b.tail, p...))...)
+	if len(b.tail) > maxTail {
+		b.tail = nil
+	}
+	if len(b.tail) > 0 {
+		return
+	}
+	var redraw []byte
+	if regionReset.Match(p) {
+		redraw = b.region()
+	}
+	_, _ = b.out.Write(append(redraw, b.draw()...))
+}
+
+// tick redraws the bar, e.g. so its elapsed time moves
+func (b *bar) tick() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.shown(b.height) && len(b.tail) == 0 {
+		_, _ = b.out.Write(b.draw())
+	}
+}
+
+// toggle shows or hides the bar, returning the size to tell the program
+func (b *bar) toggle() (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.shown(b.height) {
+		_, _ = b.out.Write(b.clear())
+		b.visible = false
+		return b.width, b.height
+	}
+	b.visible = true
+	if !b.shown(b.height) {
+		return b.width, b.height
+	}
+	_, _ = b.out.Write(append(b.region(), b.draw()...))
+	return b.width, b.height - 1
+}
+
+// close takes the bar off the terminal, for the user's shell
+func (b *bar) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.shown(b.height) {
+		_, _ = b.out.Write(b.clear())
+	}
+}
+
+// region keeps the program's output off the bar's row
+// Setting a scroll region moves the cursor; programs redraw once told their new size.
+func (b *bar) region() []byte {
+	if b.Top {
+		// Origin mode makes the program's row 1 the terminal's row 2
+		return []byte(fmt.Sprintf("\x1b[2;%dr\x1b[?6h", b.height))
+	}
+	// A cursor left on the last row would be outside the region, where nothing scrolls;
+	// a newline and back up moves it and what's above it up a row, and leaves it otherwise
+	return []byte(fmt.Sprintf("\n\x1b[A\x1b7\x1b[1;%dr\x1b8", b.height-1))
+}
+
+// draw writes the bar in reverse video, leaving the cursor and attributes as they were
+func (b *bar) draw() []byte {
+	text := []rune(b.Text(b.width))
+	if len(text) > b.width {
+		text = text[:b.width]
+	}
+	line := string(text) + string(bytes.Repeat([]byte(" "), b.width-len(text)))
+	if b.Top {
+		return []byte(fmt.Sprintf("\x1b7\x1b[?6l\x1b[1;1H\x1b[0;7m%s\x1b[0m\x1b8", line))
+	}
+	return []byte(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0;7m%s\x1b[0m\x1b8", b.height, line))
+}
+
+// clear gives the whole screen back to the program and blanks the bar's row
+func (b *bar) clear() []byte {
+	if b.Top {
+		return []byte("\x1b[?6l\x1b[r\x1b7\x1b[1;1H\x1b[2K\x1b8")
+	}
+	return []byte(fmt.Sprintf("\x1b7\x1b[r\x1b[%d;1H\x1b[2K\x1b8", b.height))
+}
+
+// unfinished returns the end of data that's an escape sequence or character still being
+// written, which a redraw mustn't be put in the middle of
+func unfinished(data []byte) []byte {
+	if i := bytes.LastIndexByte(data, 0x1b); i >= 0 && unfinishedEscape(data[i:]) {
+		return data[i:]
+	}
+	// A multi-byte character split across reads
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[i:]
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// unfinishedEscape reports whether seq, starting at an ESC, is cut off
+func unfinishedEscape(seq []byte) bool {
+	switch {
+	case len(seq) == 1:
+		return true
+	case seq[1] == '[':
+		// Parameters and intermediates until a final byte in @ to ~
+		return bytes.IndexFunc(seq[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e }) < 0
+	case seq[1] == ']' || seq[1] == 'P' || seq[1] == '_' || seq[1] == '^':
+		// Strings end with BEL or ESC \, and the latter would be the last ESC
+		return bytes.IndexByte(seq, 0x07) < 0
+	}
+	// Character set designations and the like take one more byte
+	return len(seq) == 2 && bytes.IndexByte([]byte("()*+#%"), seq[1]) >= 0
+}
@@ -0,0 +1,217 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/redact"
+)
+
+// Extension is the asciicast file extension
+const Extension = ".cast"
+
+// Event codes in the asciicast v2 format
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is one timed chunk of terminal activity
+type Event struct {
+	Time float64 // seconds since the recording started
+	Code string  // EventOutput, EventInput or EventResize
+	Data string
+}
+
+// MarshalJSON encodes the event as asciicast's [time, code, data] array
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.Time, e.Code, e.Data})
+}
+
+// UnmarshalJSON decodes an asciicast [time, code, data] array
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("event has %d fields, want 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return fmt.Errorf("invalid event time: %w", err)
+	}
+	if err := json.Unmarshal(fields[1], &e.Code); err != nil {
+		return fmt.Errorf("invalid event code: %w", err)
+	}
+	return json.Unmarshal(fields[2], &e.Data)
+}
+
+// Writer appends timed events to an asciicast v2 stream
+// It's safe for concurrent use, since output and resizes arrive from different goroutines.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	now     func() time.Time
+	pending []byte // trailing bytes of a UTF-8 sequence split across reads
+	redact  *redact.Writer
+}
+
+// NewWriter writes the header and returns a writer timing events from now
+// With encrypt_storage each line is encrypted with the storage key on its own, so a
+// recording can still be read, e.g. summarized, while it's written.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.Version = 2
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recording header: %w", err)
+	}
+	if data, err = encryption.SealLine(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt recording header: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+	return &Writer{w: w, start: time.Now(), now: time.Now}, nil
+}
+
+// WriteEvent records one event at the current time
+func (w *Writer) WriteEvent(code, data string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	elapsed := float64(w.now().Sub(w.start).Microseconds()) / 1e6
+	line, err := json.Marshal(Event{Time: elapsed, Code: code, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording event: %w", err)
+	}
+	if line, err = encryption.SealLine(line); err != nil {
+		return fmt.Errorf("failed to encrypt recording event: %w", err)
+	}
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+// Redact masks secrets in the output recorded from now on
+// Output is then recorded a line at a time, so a secret split across reads is still
+// caught; Flush records the last partial line.
+func (w *Writer) Redact(r *redact.Redactor) {
+	w.redact = redact.NewWriter(r, writerFunc(w.writeOutput))
+}
+
+// Flush records output held back for redaction
+func (w *Writer) Flush() error {
+	if w.redact == nil {
+		return nil
+	}
+	return w.redact.Close()
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// Write records terminal output, so a Writer can sit behind an io.MultiWriter
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.redact != nil {
+		return w.redact.Write(p)
+	}
+	return w.writeOutput(p)
+}
+
+// writeOutput records output as an event
+// A multi-byte character split across two reads is held back until it's complete,
+// since event data must be valid UTF-8.
+func (w *Writer) writeOutput(p []byte) (int, error) {
+	w.mu.Lock()
+	data := append(w.pending, p...)
+	data, w.pending = splitIncomplete(data)
+	w.mu.Unlock()
+
+	if len(data) == 0 {
+		return len(p), nil
+	}
+	if err := w.WriteEvent(EventOutput, string(data)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// splitIncomplete separates a trailing incomplete UTF-8 sequence from b
+func splitIncomplete(b []byte) ([]byte, []byte) {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i], append([]byte(nil), b[i:]...)
+			}
+			break
+		}
+	}
+	return b, nil
+}
+
+// Resize records a terminal size change
+func (w *Writer) Resize(width, height int) error {
+	return w.WriteEvent(EventResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+// Read parses an asciicast v2 stream
+func Read(r io.Reader) (Header, []Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var header Header
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return header, nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		return header, nil, fmt.Errorf("recording is empty")
+	}
+	data, err := encryption.OpenLine(scanner.Bytes())
+	if err != nil {
+		return header, nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return header, nil, fmt.Errorf("failed to parse recording header: %w", err)
+	}
+	if header.Version != 2 {
+		return header, nil, fmt.Errorf("unsupported asciicast version %d (only v2 is supported)", header.Version)
+	}
+
+	var events []Event
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		data, err := encryption.OpenLine(scanner.Bytes())
+		if err != nil {
+			return header, nil, fmt.Errorf("failed to read recording line %d: %w", line, err)
+		}
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return header, nil, fmt.Errorf("failed to parse recording line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return header, nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return header, events, nil
+}
@@ -0,0 +1,108 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/creack/pty"
+	"github.com/obra/packnplay/pkg/redact"
+)
+
+// Default size when stdin isn't a terminal
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// Create starts a new recording at path of a terminal of the given size, masking secrets
+// redactor finds in the output. The caller flushes the writer and closes the file once the
+// session ends.
+func Create(path, title string, width, height int, redactor *redact.Redactor) (*os.File, *Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	rec, err := NewWriter(file, Header{
+		Width:     width,
+		Height:    height,
+		Timestamp: time.Now().Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	rec.Redact(redactor)
+	return file, rec, nil
+}
+
+// Run runs cmd under a pseudo-terminal, mirroring it to the real terminal while
+// recording its output, redacted, to path. It returns the command's exit code.
+func Run(path, title string, cmd *exec.Cmd, redactor *redact.Redactor) (int, error) {
+	fd := os.Stdin.Fd()
+	isTerminal := term.IsTerminal(fd)
+	width, height := defaultWidth, defaultHeight
+	if isTerminal {
+		if w, h, err := term.GetSize(fd); err == nil {
+			width, height = w, h
+		}
+	}
+
+	file, rec, err := Create(path, title, width, height, redactor)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start %s under a pty: %w", cmd.Path, err)
+	}
+	defer ptmx.Close()
+
+	// Follow the real terminal's size, and record each change so replays can too
+	resizes := make(chan os.Signal, 1)
+	signal.Notify(resizes, syscall.SIGWINCH)
+	defer func() {
+		signal.Stop(resizes)
+		close(resizes)
+	}()
+	go func() {
+		for range resizes {
+			if w, h, err := term.GetSize(fd); err == nil {
+				_ = pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(w), Rows: uint16(h)})
+				_ = rec.Resize(w, h)
+			}
+		}
+	}()
+
+	if isTerminal {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return 0, fmt.Errorf("failed to put terminal in raw mode: %w", err)
+		}
+		defer func() { _ = term.Restore(fd, state) }()
+	}
+
+	go func() { _, _ = io.Copy(ptmx, os.Stdin) }()
+	// Reading the pty fails with EIO once the command exits; that's the normal end of output
+	_, _ = io.Copy(io.MultiWriter(os.Stdout, rec), ptmx)
+	_ = rec.Flush()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, fmt.Errorf("failed to wait for %s: %w", cmd.Path, err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,266 @@
+package recording
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/redact"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 100, Height: 30, Title: "demo"})
+	if err != nil {
+		t.Fatalf("NewWriter() error: %v", err)
+	}
+
+	clock := w.start
+	w.now = func() time.Time { return clock }
+
+	clock = clock.Add(500 * time.Millisecond)
+	if _, err := w.Write([]byte("hello\r\n")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	clock = clock.Add(time.Second)
+	if err := w.Resize(120, 40); err != nil {
+		t.Fatalf("Resize() error: %v", err)
+	}
+
+	header, events, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Title != "demo" {
+		t.Errorf("header = %+v", header)
+	}
+
+	want := []Event{
+		{Time: 0.5, Code: EventOutput, Data: "hello\r\n"},
+		{Time: 1.5, Code: EventResize, Data: "120x40"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
+
+func TestWriterHoldsSplitRunes(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatalf("NewWriter() error: %v", err)
+	}
+
+	check := []byte("a✓b")
+	_, _ = w.Write(check[:2]) // "a" plus the first byte of ✓
+	_, _ = w.Write(check[2:])
+
+	_, events, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	var got strings.Builder
+	for _, event := range events {
+		got.WriteString(event.Data)
+	}
+	if got.String() != "a✓b" {
+		t.Errorf("recorded output = %q, want %q", got.String(), "a✓b")
+	}
+}
+
+func TestWriterRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatalf("NewWriter() error: %v", err)
+	}
+	w.Redact(redact.New([]string{"hunter2-secret-value"}))
+
+	// The secret arrives split across reads, and the last line has no newline
+	_, _ = w.Write([]byte("key=hunter2-sec"))
+	_, _ = w.Write([]byte("ret-value\r\nprompt> hunter2-secret-value"))
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	_, events, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	var got strings.Builder
+	for _, event := range events {
+		got.WriteString(event.Data)
+	}
+	if strings.Contains(got.String(), "hunter2") {
+		t.Errorf("recorded output = %q, want the secret masked", got.String())
+	}
+	if !strings.Contains(got.String(), "prompt> ") {
+		t.Errorf("recorded output = %q, want the partial last line", got.String())
+	}
+}
+
+func TestEncryptedRecording(t *testing.T) {
+	t.Setenv(secrets.StoreEnv, secrets.StoreFile)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	encryption.ConfigureStorage(true)
+	defer encryption.ConfigureStorage(false)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 80, Height: 24, Title: "secret-project"})
+	if err != nil {
+		t.Fatalf("NewWriter() error: %v", err)
+	}
+	_, _ = w.Write([]byte("proprietary output\r\n"))
+	if strings.Contains(buf.String(), "proprietary") || strings.Contains(buf.String(), "secret-project") {
+		t.Fatalf("recording = %q, want it encrypted", buf.String())
+	}
+
+	// Exported recordings are plain asciicast, and encrypted again on import
+	path := filepath.Join(t.TempDir(), "session"+Extension)
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if !strings.Contains(string(plain), "proprietary output") {
+		t.Errorf("ReadFile() = %q, want the recording decrypted", plain)
+	}
+	if err := WriteFile(path, plain); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	header, events, err := Read(file)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if header.Title != "secret-project" || len(events) != 1 || events[0].Data != "proprietary output\r\n" {
+		t.Errorf("Read() = %+v, %+v", header, events)
+	}
+}
+
+func TestReadRejectsOtherVersions(t *testing.T) {
+	_, _, err := Read(strings.NewReader(`{"version": 1, "width": 80, "height": 24}` + "\n"))
+	if err == nil || !strings.Contains(err.Error(), "version 1") {
+		t.Errorf("Read() error = %v, want unsupported version", err)
+	}
+}
+
+func TestPlay(t *testing.T) {
+	var slept []time.Duration
+	original := sleep
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	t.Cleanup(func() { sleep = original })
+
+	events := []Event{
+		{Time: 1, Code: EventOutput, Data: "a"},
+		{Time: 1.5, Code: EventResize, Data: "100x30"},
+		{Time: 11, Code: EventOutput, Data: "b"},
+	}
+
+	var out bytes.Buffer
+	if err := Play(&out, events, PlayOptions{Speed: 2, MaxIdle: 4 * time.Second}); err != nil {
+		t.Fatalf("Play() error: %v", err)
+	}
+	if out.String() != "ab" {
+		t.Errorf("output = %q, want %q", out.String(), "ab")
+	}
+
+	want := []time.Duration{500 * time.Millisecond, 2 * time.Second}
+	if len(slept) != len(want) || slept[0] != want[0] || slept[1] != want[1] {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+
+	if err := Play(&out, events, PlayOptions{Speed: 0}); err == nil {
+		t.Error("Play() should reject a zero speed")
+	}
+}
+
+func TestListAndFind(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	older := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	newer := older.Add(time.Hour)
+	var paths []string
+	for _, started := range []time.Time{newer, older} {
+		path, err := NewPath("packnplay-myapp-main", started)
+		if err != nil {
+			t.Fatalf("NewPath() error: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	// Not a recording
+	_ = os.WriteFile(filepath.Join(GetRecordingsDir(), "notes.txt"), nil, 0600)
+
+	recordings, err := List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(recordings) != 2 || !recordings[0].Started.Equal(older) || recordings[1].Session != "packnplay-myapp-main" {
+		t.Fatalf("List() = %+v", recordings)
+	}
+
+	got, err := Find("packnplay-myapp-main")
+	if err != nil || got != paths[0] {
+		t.Errorf("Find() = %q, %v; want latest %q", got, err, paths[0])
+	}
+	if got, err := Find(paths[1]); err != nil || got != paths[1] {
+		t.Errorf("Find(path) = %q, %v", got, err)
+	}
+	if _, err := Find("packnplay-other-main"); err == nil {
+		t.Error("Find() should fail for a session without recordings")
+	}
+}
+
+func TestRunRecordsOutput(t *testing.T) {
+	if _, err := os.Stat("/dev/ptmx"); err != nil {
+		t.Skip("no pseudo-terminal support")
+	}
+
+	path := filepath.Join(t.TempDir(), "session"+Extension)
+	code, err := Run(path, "test", exec.Command("sh", "-c", "printf recorded; exit 3"), redact.New(nil))
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	_, events, err := Read(file)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	var out strings.Builder
+	for _, event := range events {
+		out.WriteString(event.Data)
+	}
+	if !strings.Contains(out.String(), "recorded") {
+		t.Errorf("recording = %q, want it to contain the command output", out.String())
+	}
+}
@@ -0,0 +1,45 @@
+package recording
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// sleep is swapped out in tests
+var sleep = time.Sleep
+
+// PlayOptions controls playback timing
+type PlayOptions struct {
+	Speed   float64       // playback speed multiplier (1 = real time)
+	MaxIdle time.Duration // pauses longer than this are shortened to it (0 keeps them)
+}
+
+// Play writes recorded output to w with its original timing, scaled by the options
+// Input and resize events are skipped: the replaying terminal keeps its own size.
+func Play(w io.Writer, events []Event, opts PlayOptions) error {
+	if opts.Speed <= 0 {
+		return fmt.Errorf("playback speed must be positive")
+	}
+
+	var last float64
+	for _, event := range events {
+		if event.Code != EventOutput {
+			continue
+		}
+
+		delay := time.Duration((event.Time - last) * float64(time.Second))
+		last = event.Time
+		if opts.MaxIdle > 0 && delay > opts.MaxIdle {
+			delay = opts.MaxIdle
+		}
+		if delay > 0 {
+			sleep(time.Duration(float64(delay) / opts.Speed))
+		}
+
+		if _, err := io.WriteString(w, event.Data); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return nil
+}
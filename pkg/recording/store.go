@@ -0,0 +1,136 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+)
+
+// timestampFormat keeps recording file names sortable by start time
+const timestampFormat = "20060102-150405"
+
+// GetRecordingsDir returns where session recordings are stored
+func GetRecordingsDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "recordings")
+}
+
+// NewPath returns a fresh recording path for a session, creating the directory
+func NewPath(session string, now time.Time) (string, error) {
+	dir := GetRecordingsDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create recordings dir: %w", err)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", session, now.Format(timestampFormat), Extension)), nil
+}
+
+// Recording is a stored session recording
+type Recording struct {
+	Session string
+	Started time.Time
+	Path    string
+}
+
+// List returns every stored recording, oldest first
+func List() ([]Recording, error) {
+	dir := GetRecordingsDir()
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recordings dir: %w", err)
+	}
+
+	var recordings []Recording
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), Extension)
+		if !ok || entry.IsDir() || len(name) <= len(timestampFormat)+1 {
+			continue
+		}
+		split := len(name) - len(timestampFormat)
+		started, err := time.ParseInLocation(timestampFormat, name[split:], time.Local)
+		if err != nil || name[split-1] != '-' {
+			continue
+		}
+		recordings = append(recordings, Recording{
+			Session: name[:split-1],
+			Started: started,
+			Path:    filepath.Join(dir, entry.Name()),
+		})
+	}
+
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].Started.Before(recordings[j].Started) })
+	return recordings, nil
+}
+
+// Find resolves a session name, or a path to a .cast file, to a recording
+// A session with several recordings resolves to its latest.
+func Find(session string) (string, error) {
+	if strings.HasSuffix(session, Extension) {
+		if _, err := os.Stat(session); err == nil {
+			return session, nil
+		}
+	}
+
+	recordings, err := List()
+	if err != nil {
+		return "", err
+	}
+	for i := len(recordings) - 1; i >= 0; i-- {
+		if recordings[i].Session == session {
+			return recordings[i].Path, nil
+		}
+	}
+	return "", fmt.Errorf("no recording found for session %s", session)
+}
+
+// ReadFile returns a stored recording as plain asciicast, decrypting it if it's encrypted
+// with the storage key, e.g. to export it for another machine
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return mapLines(data, encryption.OpenLine)
+}
+
+// WriteFile stores a plain asciicast recording at path, encrypting it as a recording made
+// here would be
+func WriteFile(path string, data []byte) error {
+	stored, err := mapLines(data, encryption.SealLine)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, stored, 0600)
+}
+
+// mapLines applies fn to each line of data
+func mapLines(data []byte, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		content, newline := bytes.CutSuffix(line, []byte("\n"))
+		mapped, err := fn(content)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, mapped...)
+		if newline {
+			out = append(out, '\n')
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,151 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Mask replaces every redacted secret
+const Mask = "[REDACTED]"
+
+// minSecretLength keeps short env values ("1", "true", "us-east-1") from masking ordinary text
+const minSecretLength = 8
+
+// patterns match well-known credential formats regardless of where they came from
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                                             // AWS access key ID
+	regexp.MustCompile(`(?i)(aws_secret_access_key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`),         // AWS secret key assignment
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),           // JWT
+	regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`),                                    // Anthropic / OpenAI API key
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`),             // GitHub token
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),                                           // Slack token
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),                                                   // Google API key
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`), // PEM private key
+}
+
+// Redactor masks secrets in text
+type Redactor struct {
+	values []string // literal secrets, longest first so overlapping values mask fully
+}
+
+// New creates a redactor for the built-in formats plus the given literal secret values
+func New(values []string) *Redactor {
+	r := &Redactor{}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if len(value) < minSecretLength || seen[value] {
+			continue
+		}
+		seen[value] = true
+		r.values = append(r.values, value)
+	}
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+	return r
+}
+
+// FromEnv creates a redactor for the values of the named environment variables
+// Pass the variables forwarded into the sandbox, so anything echoing them is caught.
+func FromEnv(names []string, getenv func(string) string) *Redactor {
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		if value := getenv(name); value != "" {
+			values = append(values, value)
+		}
+	}
+	return New(values)
+}
+
+// Redact returns s with every known secret masked
+func (r *Redactor) Redact(s string) string {
+	for _, value := range r.values {
+		s = strings.ReplaceAll(s, value, Mask)
+	}
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			// Keep an assignment prefix ("aws_secret_access_key = ") so the context stays readable
+			if sub := pattern.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + Mask
+			}
+			return Mask
+		})
+	}
+	return s
+}
+
+// Args redacts a command line, masking every value passed with -e/--env
+// Env values are masked whatever their format, since any of them may be a secret.
+func (r *Redactor) Args(args []string) []string {
+	redacted := make([]string, len(args))
+	maskNext := false
+	for i, arg := range args {
+		switch {
+		case maskNext:
+			redacted[i] = maskEnvValue(arg)
+			maskNext = false
+		case arg == "-e" || arg == "--env":
+			redacted[i] = arg
+			maskNext = true
+		case strings.HasPrefix(arg, "--env="):
+			redacted[i] = "--env=" + maskEnvValue(strings.TrimPrefix(arg, "--env="))
+		default:
+			redacted[i] = r.Redact(arg)
+		}
+	}
+	return redacted
+}
+
+// maskEnvValue masks the value of a KEY=value pair, leaving pass-through KEY alone
+func maskEnvValue(env string) string {
+	key, _, ok := strings.Cut(env, "=")
+	if !ok {
+		return env
+	}
+	return key + "=" + Mask
+}
+
+// Writer redacts everything written to it before passing it on
+// Output is redacted a line at a time so secrets split across writes are still caught;
+// Close flushes any trailing partial line.
+type Writer struct {
+	redactor *Redactor
+	dst      io.Writer
+	pending  []byte
+}
+
+// NewWriter wraps dst with redaction
+func NewWriter(r *Redactor, dst io.Writer) *Writer {
+	return &Writer{redactor: r, dst: dst}
+}
+
+// Write buffers p and writes out every completed line, redacted
+func (w *Writer) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+
+	end := bytes.LastIndexByte(w.pending, '\n')
+	// PEM blocks span lines; hold them until they close
+	if begin := bytes.LastIndex(w.pending, []byte("-----BEGIN ")); begin != -1 && !bytes.Contains(w.pending[begin:], []byte("-----END ")) {
+		end = bytes.LastIndexByte(w.pending[:begin], '\n')
+	}
+	if end == -1 {
+		return len(p), nil
+	}
+
+	if _, err := io.WriteString(w.dst, w.redactor.Redact(string(w.pending[:end+1]))); err != nil {
+		return 0, err
+	}
+	w.pending = append(w.pending[:0], w.pending[end+1:]...)
+	return len(p), nil
+}
+
+// Close flushes any buffered partial line
+func (w *Writer) Close() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.dst, w.redactor.Redact(string(w.pending)))
+	w.pending = nil
+	return err
+}
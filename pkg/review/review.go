@@ -0,0 +1,254 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// Timeout bounds one reviewer, so a stuck model or service doesn't hold up publishing
+const Timeout = 10 * time.Minute
+
+// maxDiffBytes keeps the diff within what a reviewing model can read in one prompt
+const maxDiffBytes = 200 * 1024
+
+// Decisions a reviewer can reach
+const (
+	Approve = "approve"
+	Reject  = "reject"
+	Comment = "comment" // neither approved nor rejected, e.g. a reviewer that only leaves notes
+	Failed  = "failed"  // the reviewer couldn't be reached or errored; the error is the summary
+)
+
+// Request is what a reviewer is sent; services get it as JSON
+type Request struct {
+	Session  string `json:"session"`
+	Project  string `json:"project"`
+	Worktree string `json:"worktree"`
+	Task     string `json:"task"`
+	Diff     string `json:"diff"`
+}
+
+// Verdict is a reviewer's answer, as attached to the session
+type Verdict struct {
+	Reviewer string    `json:"reviewer"`
+	Decision string    `json:"decision"`
+	Summary  string    `json:"summary,omitempty"`
+	Gate     bool      `json:"gate,omitempty"` // the reviewer can block publishing
+	At       time.Time `json:"at"`
+}
+
+// Blocks reports whether the verdict stops --auto-pr
+func (v Verdict) Blocks() bool {
+	return v.Gate && v.Decision != Approve && v.Decision != Comment
+}
+
+// Name returns how a reviewer is referred to: its name, or else what it runs
+func Name(reviewer config.ReviewerConfig) string {
+	switch {
+	case reviewer.Name != "":
+		return reviewer.Name
+	case reviewer.Agent != "":
+		return reviewer.Agent
+	case len(reviewer.Command) > 0:
+		return reviewer.Command[0]
+	}
+	if u, err := url.Parse(reviewer.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return reviewer.URL
+}
+
+// Validate checks each reviewer says exactly one way to reach it
+func Validate(reviewers []config.ReviewerConfig) error {
+	for i, reviewer := range reviewers {
+		ways := 0
+		for _, set := range []bool{len(reviewer.Command) > 0, reviewer.Agent != "", reviewer.URL != ""} {
+			if set {
+				ways++
+			}
+		}
+		if ways != 1 {
+			return fmt.Errorf("reviewer %d needs exactly one of command, agent or url", i+1)
+		}
+		if reviewer.URL != "" {
+			if u, err := url.Parse(reviewer.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("reviewer %s: url must be http or https", Name(reviewer))
+			}
+		}
+	}
+	return nil
+}
+
+// Prompt asks a reviewing agent for a verdict on the request's diff
+func Prompt(req Request) string {
+	diff := req.Diff
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes] + "\n[diff truncated]\n"
+	}
+	var b strings.Builder
+	b.WriteString("Review this change for correctness, security and maintainability. ")
+	b.WriteString("Answer APPROVE or REJECT on the first line, then summarize your reasons and any problems in a few lines.\n\n")
+	if req.Task != "" {
+		fmt.Fprintf(&b, "The change was made for this task: %s\n\n", req.Task)
+	}
+	fmt.Fprintf(&b, "```diff\n%s```\n", diff)
+	return b.String()
+}
+
+// ParseVerdict reads a reviewer's answer: JSON like {"decision": "reject", "summary": "..."}
+// ("verdict" works too), or text whose first line starts with APPROVE or REJECT.
+// Anything else is kept as a comment.
+func ParseVerdict(output []byte) (decision, summary string) {
+	var answer struct {
+		Decision string `json:"decision"`
+		Verdict  string `json:"verdict"`
+		Summary  string `json:"summary"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(output), &answer); err == nil {
+		if answer.Decision == "" {
+			answer.Decision = answer.Verdict
+		}
+		if decision := normalize(answer.Decision); decision != "" {
+			return decision, strings.TrimSpace(answer.Summary)
+		}
+	}
+
+	text := strings.TrimSpace(string(output))
+	first, rest, _ := strings.Cut(text, "\n")
+	if fields := strings.Fields(first); len(fields) > 0 {
+		// Models like to dress the verdict up, e.g. "**APPROVE**:" or "# REJECT"
+		if decision := normalize(strings.Trim(fields[0], "*:.#")); decision != "" {
+			summary := strings.Trim(strings.TrimPrefix(strings.TrimSpace(first), fields[0]), " *:.-")
+			if rest = strings.TrimSpace(rest); rest != "" {
+				summary = strings.TrimSpace(summary + "\n" + rest)
+			}
+			return decision, summary
+		}
+	}
+	return Comment, text
+}
+
+func normalize(decision string) string {
+	switch strings.ToLower(strings.TrimSpace(decision)) {
+	case "approve", "approved", "lgtm", "pass":
+		return Approve
+	case "reject", "rejected", "request_changes", "fail":
+		return Reject
+	case "comment":
+		return Comment
+	}
+	return ""
+}
+
+// Run sends the request to a reviewer and returns its verdict. agentCommand builds the
+// command that runs an agent headless in the session, for agent reviewers.
+func Run(ctx context.Context, reviewer config.ReviewerConfig, req Request, agentCommand func(agent string) ([]string, error)) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var output []byte
+	var err error
+	switch {
+	case reviewer.URL != "":
+		output, err = post(ctx, reviewer, req)
+	case reviewer.Agent != "":
+		var command []string
+		if command, err = agentCommand(reviewer.Agent); err == nil {
+			output, err = runCommand(ctx, command, nil, Prompt(req))
+		}
+	default:
+		env := []string{
+			"PACKNPLAY_SESSION=" + req.Session,
+			"PACKNPLAY_PROJECT=" + req.Project,
+			"PACKNPLAY_WORKTREE=" + req.Worktree,
+			"PACKNPLAY_TASK=" + req.Task,
+		}
+		output, err = runCommand(ctx, reviewer.Command, env, req.Diff)
+	}
+	if err != nil {
+		return Verdict{}, fmt.Errorf("reviewer %s failed: %w", Name(reviewer), err)
+	}
+
+	decision, summary := ParseVerdict(output)
+	return Verdict{Reviewer: Name(reviewer), Decision: decision, Summary: summary, Gate: reviewer.Gate, At: time.Now().UTC()}, nil
+}
+
+// RunAll sends the request to every reviewer at once, returning their verdicts in order.
+// A reviewer that fails gets a Failed verdict, which blocks if it gates.
+func RunAll(ctx context.Context, reviewers []config.ReviewerConfig, req Request, agentCommand func(agent string) ([]string, error)) []Verdict {
+	verdicts := make([]Verdict, len(reviewers))
+	var wg sync.WaitGroup
+	for i, reviewer := range reviewers {
+		wg.Add(1)
+		go func(i int, reviewer config.ReviewerConfig) {
+			defer wg.Done()
+			verdict, err := Run(ctx, reviewer, req, agentCommand)
+			if err != nil {
+				verdict = Verdict{Reviewer: Name(reviewer), Decision: Failed, Summary: err.Error(), Gate: reviewer.Gate, At: time.Now().UTC()}
+			}
+			verdicts[i] = verdict
+		}(i, reviewer)
+	}
+	wg.Wait()
+	return verdicts
+}
+
+// runCommand runs a reviewer on the host with input on stdin, returning what it printed
+func runCommand(ctx context.Context, command, env []string, input string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// A command may reject by exiting non-zero, as long as it says so
+		if decision, _ := ParseVerdict(stdout.Bytes()); decision == Reject {
+			return stdout.Bytes(), nil
+		}
+		return nil, fmt.Errorf("%w\n%s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// post sends the request to a review service as JSON
+func post(ctx context.Context, reviewer config.ReviewerConfig, req Request) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, reviewer.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for name, value := range reviewer.Headers {
+		httpReq.Header.Set(name, os.ExpandEnv(value))
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s returned %s: %s", reviewer.URL, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,113 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestParseVerdict(t *testing.T) {
+	tests := []struct {
+		output       string
+		wantDecision string
+		wantSummary  string
+	}{
+		{`{"decision": "reject", "summary": "drops error"}`, Reject, "drops error"},
+		{`{"verdict": "APPROVED"}`, Approve, ""},
+		{"APPROVE\nLooks fine.", Approve, "Looks fine."},
+		{"**REJECT**: leaks the token\nSee config.go", Reject, "leaks the token\nSee config.go"},
+		{"Some notes about naming.", Comment, "Some notes about naming."},
+		{`{"decision": "maybe"}`, Comment, `{"decision": "maybe"}`},
+	}
+	for _, tt := range tests {
+		decision, summary := ParseVerdict([]byte(tt.output))
+		if decision != tt.wantDecision || summary != tt.wantSummary {
+			t.Errorf("ParseVerdict(%q) = %q, %q, want %q, %q", tt.output, decision, summary, tt.wantDecision, tt.wantSummary)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		verdict Verdict
+		want    bool
+	}{
+		{Verdict{Decision: Reject, Gate: true}, true},
+		{Verdict{Decision: Failed, Gate: true}, true},
+		{Verdict{Decision: Approve, Gate: true}, false},
+		{Verdict{Decision: Comment, Gate: true}, false},
+		{Verdict{Decision: Reject}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.verdict.Blocks(); got != tt.want {
+			t.Errorf("%+v.Blocks() = %v, want %v", tt.verdict, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]config.ReviewerConfig{{Agent: "codex"}, {URL: "https://review.internal/hook"}}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := Validate([]config.ReviewerConfig{{Agent: "codex", Command: []string{"bot"}}}); err == nil {
+		t.Error("Validate() should reject a reviewer with two ways to reach it")
+	}
+	if err := Validate([]config.ReviewerConfig{{URL: "ftp://review.internal"}}); err == nil {
+		t.Error("Validate() should reject non-HTTP URLs")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	req := Request{Task: "fix login", Diff: "+added line\n"}
+	reviewer := config.ReviewerConfig{Name: "bot", Gate: true,
+		Command: []string{"sh", "-c", `grep -q added && echo "REJECT $PACKNPLAY_TASK"; exit 1`}}
+	verdict, err := Run(context.Background(), reviewer, req, nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if verdict.Reviewer != "bot" || verdict.Decision != Reject || verdict.Summary != "fix login" || !verdict.Blocks() {
+		t.Errorf("Run() = %+v", verdict)
+	}
+
+	failing := config.ReviewerConfig{Command: []string{"sh", "-c", "exit 3"}, Gate: true}
+	verdicts := RunAll(context.Background(), []config.ReviewerConfig{failing}, req, nil)
+	if verdicts[0].Decision != Failed || !verdicts[0].Blocks() {
+		t.Errorf("RunAll() = %+v, want a blocking failure", verdicts[0])
+	}
+}
+
+func TestRunURL(t *testing.T) {
+	t.Setenv("REVIEW_TOKEN", "s3cret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Session != "packnplay-app-main" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"decision": "approve", "summary": "ok"}`))
+	}))
+	defer server.Close()
+
+	reviewer := config.ReviewerConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer ${REVIEW_TOKEN}"}}
+	verdict, err := Run(context.Background(), reviewer, Request{Session: "packnplay-app-main"}, nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if verdict.Decision != Approve || verdict.Summary != "ok" {
+		t.Errorf("Run() = %+v", verdict)
+	}
+
+	reviewer.Headers = nil
+	if _, err := Run(context.Background(), reviewer, Request{Session: "packnplay-app-main"}, nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Run() error = %v, want the service's status", err)
+	}
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/session"
+)
+
+// OrphanAction is what to do with a session container packnplay had lost track of
+type OrphanAction int
+
+const (
+	OrphanKeep   OrphanAction = iota // adopt it and leave it as it is
+	OrphanAttach                     // adopt it and run this session's command in it
+	OrphanRemove                     // stop and remove it
+)
+
+// OrphanHandler decides what happens to an adopted orphan
+type OrphanHandler func(orphan session.Entry) (OrphanAction, error)
+
+// SessionStopper saves a session's work and removes it, as 'packnplay stop' does
+type SessionStopper func(dockerClient *docker.Client, containerName string) error
+
+// managedContainersFormat lists the fields parseManagedContainers expects
+const managedContainersFormat = `{{.Names}}|{{.State}}|{{.Label "packnplay-project"}}|{{.Label "packnplay-worktree"}}|{{.Label "` + container.AgentLabel + `"}}|{{.CreatedAt}}`
+
+// listManagedContainers returns every packnplay session container, running or not
+func listManagedContainers(dockerClient *docker.Client) ([]session.Entry, error) {
+	output, err := dockerClient.Run("ps", "-a", "--filter", "label=managed-by=packnplay", "--format", managedContainersFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session containers: %w", err)
+	}
+	return parseManagedContainers(output), nil
+}
+
+// parseManagedContainers parses `ps --format managedContainersFormat` output
+func parseManagedContainers(output string) []session.Entry {
+	var entries []session.Entry
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "|", 6)
+		if len(fields) != 6 || fields[0] == "" {
+			continue
+		}
+
+		entry := session.Entry{
+			Container: fields[0],
+			Running:   fields[1] == "running",
+			Project:   fields[2],
+			Worktree:  fields[3],
+			Agent:     fields[4],
+		}
+		// docker appends the zone name, e.g. "2026-10-15 09:30:00 +0000 UTC"
+		if created, err := time.Parse("2006-01-02 15:04:05 -0700 MST", fields[5]); err == nil {
+			entry.StartedAt = created.UTC()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// adoptOrphans brings session containers the store doesn't know about (left behind by a
+// crash or an older packnplay) back under management, and asks what to do with each.
+// Removing one goes through stop, so its work is saved first; without a stopper it's kept.
+// It returns the container to attach to, if the user chose one.
+func adoptOrphans(dockerClient *docker.Client, handle OrphanHandler, stop SessionStopper, current string) (string, error) {
+	// Apple Container's ps has no label filter or Go templates
+	if dockerClient.Command() == "container" {
+		return "", nil
+	}
+
+	found, err := listManagedContainers(dockerClient)
+	if err != nil {
+		return "", err
+	}
+	entries, err := session.LoadStore()
+	if err != nil {
+		return "", err
+	}
+	if recoverSessions(dockerClient, entries, found) {
+		if found, err = listManagedContainers(dockerClient); err != nil {
+			return "", err
+		}
+		if entries, err = session.LoadStore(); err != nil {
+			return "", err
+		}
+	}
+
+	orphans, gone := session.Reconcile(entries, found)
+	if len(orphans) == 0 && len(gone) == 0 {
+		return "", nil
+	}
+	// Re-check against the store as it is now: another packnplay may be starting one of these
+	err = session.Update(func(entries map[string]session.Entry) error {
+		for _, name := range gone {
+			if entry, ok := entries[name]; ok && session.Recover(entry, false, entry.OwnerAlive()) == session.RecoverForget {
+				delete(entries, name)
+			}
+		}
+		for _, orphan := range orphans {
+			if _, ok := entries[orphan.Container]; !ok {
+				orphan.Adopted = true
+				orphan.State = session.StateRunning
+				entries[orphan.Container] = orphan
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var attach string
+	for _, orphan := range orphans {
+		// The session being started decides for itself whether to reconnect
+		if orphan.Container == current {
+			continue
+		}
+
+		state := "stopped"
+		if orphan.Running {
+			state = "running"
+		}
+		fmt.Fprintf(os.Stderr, "Adopted untracked session %s (%s)\n", orphan.Container, state)
+		if handle == nil {
+			continue
+		}
+
+		action, err := handle(orphan)
+		if err != nil {
+			return "", err
+		}
+		switch action {
+		case OrphanAttach:
+			if attach == "" && orphan.Running {
+				attach = orphan.Container
+			}
+		case OrphanRemove:
+			if stop == nil {
+				fmt.Fprintf(os.Stderr, "Warning: kept %s; remove it with 'packnplay stop'\n", orphan.Container)
+				continue
+			}
+			// A session whose work can't be saved stays adopted, so the rest still get asked about
+			if err := stop(dockerClient, orphan.Container); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+	return attach, nil
+}
+
+// recoverSessions settles sessions whose packnplay process died mid-step (a crash, a
+// reboot, a killed terminal): half-provisioned containers and interrupted teardowns are
+// removed, and interrupted verifications go back to running. Reports whether it changed anything.
+func recoverSessions(dockerClient *docker.Client, entries map[string]session.Entry, found []session.Entry) bool {
+	exists := make(map[string]bool, len(found))
+	for _, entry := range found {
+		exists[entry.Container] = true
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		entry := entries[name]
+		switch session.Recover(entry, exists[name], entry.OwnerAlive()) {
+		case session.RecoverRemove:
+			step := string(entry.State)
+			if entry.Removing {
+				step = "being removed"
+			}
+			fmt.Fprintf(os.Stderr, "Cleaning up session %s, interrupted while %s\n", name, step)
+			if err := Teardown(dockerClient, name); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to clean up %s: %v\n", name, err)
+			}
+			changed = true
+		case session.RecoverResume:
+			fmt.Fprintf(os.Stderr, "Verification of %s was interrupted; it's back to running\n", name)
+			if err := session.Transition(name, session.StateRunning); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseManagedContainers(t *testing.T) {
+	output := `packnplay-app-main|running|app|main|claude|2026-10-15 09:30:00 +0000 UTC
+packnplay-app-feature|exited|app|feature/auth||2026-10-14 18:00:00 +0000 UTC
+
+garbage line
+`
+	entries := parseManagedContainers(output)
+	if len(entries) != 2 {
+		t.Fatalf("parseManagedContainers() = %+v, want 2 entries", entries)
+	}
+
+	main := entries[0]
+	if main.Container != "packnplay-app-main" || !main.Running || main.Project != "app" || main.Worktree != "main" || main.Agent != "claude" {
+		t.Errorf("entry 0 = %+v", main)
+	}
+	if want := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC); !main.StartedAt.Equal(want) {
+		t.Errorf("StartedAt = %v, want %v", main.StartedAt, want)
+	}
+
+	if feature := entries[1]; feature.Running || feature.Worktree != "feature/auth" || feature.Agent != "" {
+		t.Errorf("entry 1 = %+v", feature)
+	}
+}
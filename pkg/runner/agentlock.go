@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/imagebuild"
+	"github.com/obra/packnplay/pkg/lockfile"
+)
+
+// ensureLockedAgents returns the project's agent layer on baseImage with exactly the agent
+// versions .packnplay.lock pins, building it when the existing layer is missing, sits on a
+// different base, or holds other versions (e.g. after a teammate ran 'packnplay update').
+func ensureLockedAgents(dockerClient *docker.Client, baseImage, user, projectName string, locked map[string]string, verbose bool) (string, error) {
+	if tag, _ := imagebuild.FindAgentImage(dockerClient, projectName, baseImage); tag != "" {
+		if installed, err := imagebuild.InstalledPackages(dockerClient, tag); err == nil && imagebuild.HasPackages(installed, locked) {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Using agent layer %s\n", tag)
+			}
+			return tag, nil
+		}
+	}
+
+	installs, err := imagebuild.ResolveInstalls(nil, locked)
+	if err != nil {
+		return "", fmt.Errorf("invalid agent versions in %s: %w", lockfile.FileName, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Installing agent versions pinned by %s...\n", lockfile.FileName)
+	started := time.Now()
+	tag := imagebuild.AgentImageName(projectName)
+	if _, packages, err := imagebuild.BuildAgentImage(dockerClient, baseImage, user, tag, installs); err != nil {
+		return "", err
+	} else if !imagebuild.HasPackages(packages, locked) {
+		return "", fmt.Errorf("agent layer %s doesn't match %s; run 'packnplay update'", tag, lockfile.FileName)
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Built agent layer %s in %s\n", tag, time.Since(started).Round(time.Second))
+	}
+	return tag, nil
+}
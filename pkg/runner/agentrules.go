@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// checkAgentRules enforces the project's agent allow/deny list before anything is started
+// Only the agent packnplay launches is checked; plain commands like bash aren't agents.
+func checkAgentRules(projectPath string, runConfig *RunConfig) error {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return err
+	}
+	rules := projectConfig.Agents
+
+	// A misspelled deny entry would silently allow the agent it meant to block
+	for _, name := range rules.Names() {
+		if agents.Lookup(name) == nil {
+			return fmt.Errorf("unknown agent '%s' in %s agents", name, config.ProjectConfigFile)
+		}
+	}
+	if runConfig.Agent == "" {
+		return nil
+	}
+	return rules.Check(runConfig.Agent, sessionEnv(runConfig.DefaultEnvVars, runConfig.Env))
+}
+
+// sessionEnv resolves the variables a session is started with, as the runners pass them on
+func sessionEnv(defaults, env []string) func(string) string {
+	values := map[string]string{}
+	for _, key := range defaults {
+		values[key] = os.Getenv(key)
+	}
+	for _, entry := range env {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			values[key] = value
+		} else {
+			values[entry] = os.Getenv(entry)
+		}
+	}
+	return func(key string) string { return values[key] }
+}
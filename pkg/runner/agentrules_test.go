@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestCheckAgentRules(t *testing.T) {
+	project := t.TempDir()
+	rules := `agents:
+  allow: [claude]
+  require_env:
+    claude: [CLAUDE_CODE_USE_BEDROCK=1]
+`
+	if err := os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLAUDE_CODE_USE_BEDROCK", "1")
+
+	tests := []struct {
+		name    string
+		run     RunConfig
+		wantErr string
+	}{
+		{"other agent", RunConfig{Agent: "codex"}, "only allows claude"},
+		{"claude without bedrock", RunConfig{Agent: "claude"}, "CLAUDE_CODE_USE_BEDROCK=1"},
+		{"claude with --env", RunConfig{Agent: "claude", Env: []string{"CLAUDE_CODE_USE_BEDROCK=1"}}, ""},
+		{"claude passing host env", RunConfig{Agent: "claude", Env: []string{"CLAUDE_CODE_USE_BEDROCK"}}, ""},
+		{"claude via default_env_vars", RunConfig{Agent: "claude", DefaultEnvVars: []string{"CLAUDE_CODE_USE_BEDROCK"}}, ""},
+		{"--env overrides defaults", RunConfig{Agent: "claude", DefaultEnvVars: []string{"CLAUDE_CODE_USE_BEDROCK"}, Env: []string{"CLAUDE_CODE_USE_BEDROCK=0"}}, "CLAUDE_CODE_USE_BEDROCK=1"},
+		{"plain command", RunConfig{Command: []string{"bash"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAgentRules(project, &tt.run)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("checkAgentRules() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkAgentRules() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAgentRulesUnknownAgent(t *testing.T) {
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte("agents:\n  deny: [cdoex]\n"), 0644)
+
+	if err := checkAgentRules(project, &RunConfig{Agent: "codex"}); err == nil || !strings.Contains(err.Error(), "cdoex") {
+		t.Errorf("checkAgentRules() error = %v, want the misspelled agent reported", err)
+	}
+}
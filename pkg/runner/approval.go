@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/policy"
+)
+
+// MountApprover asks the user whether a project may use mounts it hasn't used before
+type MountApprover func(project string, mounts []string) (bool, error)
+
+// approvedMounts is the persisted approval state for one project
+type approvedMounts struct {
+	Project    string    `json:"project"`
+	Mounts     []string  `json:"mounts"`
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// getApprovalPath returns where a project's approved mount set is stored
+func getApprovalPath(projectDir string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+
+	sum := sha256.Sum256([]byte(projectDir))
+	name := fmt.Sprintf("%s-%s", filepath.Base(projectDir), hex.EncodeToString(sum[:])[:8])
+	return filepath.Join(xdgDataHome, "packnplay", "projects", name, "approved-mounts.json"), nil
+}
+
+// describeMounts renders the mounts in docker run args as stable, human-readable lines
+// Session-specific paths are replaced with placeholders so approval carries across worktrees.
+func describeMounts(args []string, replacements map[string]string) ([]string, error) {
+	spec, err := policy.ParseRunArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Longest paths first, so a worktree under the home dir isn't rewritten as ~/...
+	prefixes := make([]string, 0, len(replacements))
+	for prefix := range replacements {
+		if prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	seen := make(map[string]bool)
+	var lines []string
+	for _, mount := range spec.Mounts {
+		source := mount.Source
+		if !strings.HasPrefix(source, "/") {
+			source = "volume " + source
+		}
+		for _, prefix := range prefixes {
+			source = strings.ReplaceAll(source, prefix, replacements[prefix])
+		}
+
+		line := fmt.Sprintf("%s -> %s", source, mount.Target)
+		if mount.ReadOnly {
+			line += " (read-only)"
+		}
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// checkMountApproval requires the user to approve any mount this project hasn't used before
+func checkMountApproval(projectDir string, mounts []string, approve MountApprover) error {
+	path, err := getApprovalPath(projectDir)
+	if err != nil {
+		return err
+	}
+
+	var state approvedMounts
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read mount approvals: %w", err)
+	}
+
+	approved := make(map[string]bool)
+	for _, mount := range state.Mounts {
+		approved[mount] = true
+	}
+	var pending []string
+	for _, mount := range mounts {
+		if !approved[mount] {
+			pending = append(pending, mount)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ok, err := approve(projectDir, pending)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("mounts not approved for %s", projectDir)
+	}
+
+	state.Project = projectDir
+	state.Mounts = append(state.Mounts, pending...)
+	sort.Strings(state.Mounts)
+	state.ApprovedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal mount approvals: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create project state dir: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save mount approvals: %w", err)
+	}
+	return nil
+}
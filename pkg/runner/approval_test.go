@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/logging"
+)
+
+func TestDescribeMounts(t *testing.T) {
+	args := []string{
+		"run", "-d",
+		"-v", "/home/me/.local/share/packnplay/worktrees/app/feature:/workspace:cached",
+		"-v", "/home/me/app/.git:/home/me/app/.git",
+		"-v", "/home/me/.ssh:/home/vscode/.ssh:ro",
+		"-v", "/home/me/.local/share/packnplay/homes/packnplay-app-feature/.cache:/home/vscode/.cache",
+		"-v", "packnplay-cache-npm:/home/vscode/.npm",
+		"image", "sleep", "infinity",
+	}
+	replacements := map[string]string{
+		"/home/me/.local/share/packnplay/worktrees/app/feature": "<workspace>",
+		"/home/me/app":          "<project>",
+		"packnplay-app-feature": "<session>",
+		"/home/me":              "~",
+	}
+
+	got, err := describeMounts(args, replacements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"<workspace> -> /workspace",
+		"<project>/.git -> /home/me/app/.git",
+		"~/.ssh -> /home/vscode/.ssh (read-only)",
+		"~/.local/share/packnplay/homes/<session>/.cache -> /home/vscode/.cache",
+		"volume packnplay-cache-npm -> /home/vscode/.npm",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("describeMounts() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+// The options packnplay puts ahead of the mounts must not hide them from approval
+func TestDescribeMountsAfterOptions(t *testing.T) {
+	args := []string{"run", "-d", "-it", "--name", "packnplay-app-main"}
+	args = append(args, logging.ContainerArgs(config.LoggingConfig{Driver: logging.DriverFile}, "packnplay-app-main")...)
+	hostArgs, err := hostsArgs("app", map[string]string{"db.local": "10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limitArgs, err := limitsArgs(map[string]string{"nofile": "65536"}, map[string]string{"net.core.somaxconn": "1024"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args = append(append(args, hostArgs...), limitArgs...)
+	args = append(args, "-v", "/home/me/.ssh:/home/vscode/.ssh:ro", "image", "sleep", "infinity")
+
+	got, err := describeMounts(args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/home/me/.ssh -> /home/vscode/.ssh (read-only)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("describeMounts() = %q, want %q", got, want)
+	}
+
+	if _, err := describeMounts([]string{"run", "--some-new-flag", "value", "-v", "/:/host", "image"}, nil); err == nil {
+		t.Error("describeMounts() skipped an unknown flag")
+	}
+}
+
+func TestCheckMountApproval(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	var asked [][]string
+	approver := func(answer bool) MountApprover {
+		return func(project string, mounts []string) (bool, error) {
+			asked = append(asked, mounts)
+			return answer, nil
+		}
+	}
+
+	mounts := []string{"<workspace> -> /workspace", "~/.ssh -> /home/vscode/.ssh (read-only)"}
+
+	if err := checkMountApproval("/home/me/app", mounts, approver(false)); err == nil {
+		t.Fatal("checkMountApproval() should fail when the user declines")
+	}
+	if err := checkMountApproval("/home/me/app", mounts, approver(true)); err != nil {
+		t.Fatalf("checkMountApproval() error = %v", err)
+	}
+
+	// Approved set isn't asked again
+	asked = nil
+	if err := checkMountApproval("/home/me/app", mounts, approver(false)); err != nil || len(asked) != 0 {
+		t.Errorf("checkMountApproval() re-asked approved mounts: %v, %v", asked, err)
+	}
+
+	// Only new mounts are shown
+	withNew := append(mounts, "~/.config/gh -> /home/vscode/.config/gh")
+	if err := checkMountApproval("/home/me/app", withNew, approver(true)); err != nil {
+		t.Fatal(err)
+	}
+	if len(asked) != 1 || !reflect.DeepEqual(asked[0], []string{"~/.config/gh -> /home/vscode/.config/gh"}) {
+		t.Errorf("checkMountApproval() asked %v, want only the new mount", asked)
+	}
+
+	// Other projects need their own approval
+	asked = nil
+	_ = checkMountApproval("/home/me/other", mounts, approver(true))
+	if len(asked) != 1 {
+		t.Error("checkMountApproval() should ask again for a different project")
+	}
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/obra/packnplay/pkg/bazel"
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// withBazelCaches adds the worktree's output base and the repository cache every session
+// shares. As with the browser cache, a "bazel-repo" cache in the user's config keeps its
+// backend but is mounted where the session's bazelrc points.
+func withBazelCaches(caches map[string]config.CacheVolume, containerName string) map[string]config.CacheVolume {
+	merged := make(map[string]config.CacheVolume, len(caches)+2)
+	for name, cache := range caches {
+		merged[name] = cache
+	}
+	merged[bazel.OutputBaseCache(containerName)] = config.CacheVolume{Target: "~/.cache/bazel"}
+	cache := merged[bazel.RepositoryCache]
+	cache.Target = "~/.cache/" + bazel.RepositoryCache
+	merged[bazel.RepositoryCache] = cache
+	return merged
+}
+
+// bazelArgs writes the session's system bazelrc and returns the docker args that mount it,
+// along with the credentials and host env vars the remote cache needs
+func bazelArgs(settings config.BazelConfig, containerName, containerHome, homeDir string) ([]string, error) {
+	rc, err := bazel.RC(settings, containerHome, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	rcPath, err := bazel.Prepare(containerName, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"-v", fmt.Sprintf("%s:%s:ro", rcPath, bazel.SystemRC)}
+	if settings.GoogleCredentials != "" {
+		credentials := hookHostPath(settings.GoogleCredentials, homeDir)
+		if !fileExists(credentials) {
+			return nil, fmt.Errorf("bazel.google_credentials %s not found", credentials)
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", credentials, bazel.CredentialsPath))
+	}
+	for _, name := range settings.Env {
+		// By name only, so the value never appears in the docker command line
+		if _, ok := os.LookupEnv(name); ok {
+			args = append(args, "-e", name)
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/broker"
+)
+
+// brokerContainerDir is where the broker socket directory is mounted inside the container
+const brokerContainerDir = "/tmp/packnplay-broker"
+
+// gitHostPush asks the broker to push a branch from the host: git host-push [remote] [branch]
+// The branch defaults to the current one and the remote to origin.
+const gitHostPush = `#!/bin/sh
+case $# in
+0) remote=origin; branch=$(git symbolic-ref --short HEAD) || exit 1 ;;
+1) remote=origin; branch=$1 ;;
+*) remote=$1; branch=$2 ;;
+esac
+printf '{"remote":"%s","branch":"%s"}' "$remote" "$branch" |
+	exec curl -sS --fail-with-body --unix-socket "$PACKNPLAY_BROKER_SOCKET" --data-binary @- http://broker/git-push
+`
+
+// getBrokerDir returns the host directory holding a container's broker socket
+func getBrokerDir(containerName string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+
+	return filepath.Join(xdgDataHome, "packnplay", "broker", containerName), nil
+}
+
+// startHostBroker launches a detached host broker for the container and returns
+// the docker args that mount its socket and advertise it to tools inside
+func startHostBroker(containerName, runtime string, allowed, gitHosts []string, repo, sparseWorkspace string, verbose bool) ([]string, error) {
+	brokerDir, err := getBrokerDir(containerName)
+	if err != nil {
+		return nil, err
+	}
+	// Other host users are kept out by the private parent; the container user gets in
+	// through the group, as it's mounted without the parent
+	if err := os.MkdirAll(brokerDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create broker dir: %w", err)
+	}
+	if err := os.Chmod(filepath.Dir(brokerDir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to secure broker dir: %w", err)
+	}
+	if err := os.Chmod(brokerDir, 0710); err != nil {
+		return nil, fmt.Errorf("failed to secure broker dir: %w", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	socketPath := filepath.Join(brokerDir, broker.SocketName)
+	cmd := exec.Command(executable, "host-broker",
+		"--socket", socketPath,
+		"--container", containerName,
+		"--runtime", runtime,
+		"--allow", strings.Join(allowed, ","),
+		"--git-hosts", strings.Join(gitHosts, ","),
+		"--repo", repo,
+		"--workspace", sparseWorkspace,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start host broker: %w", err)
+	}
+
+	// Let it create the socket before the container starts
+	time.Sleep(100 * time.Millisecond)
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Host broker serving %s on %s\n", strings.Join(allowed, ", "), socketPath)
+	}
+
+	var configPath string
+	if slices.Contains(allowed, broker.ActionGitPush) {
+		if configPath, err = writeGitPushConfig(brokerDir); err != nil {
+			return nil, err
+		}
+	}
+	return brokerRunArgs(brokerDir, runtime, os.Getgid(), configPath), nil
+}
+
+// brokerRunArgs returns the docker args that mount the broker socket into the container
+// gitPushConfig is the container path of the git-push config, if pushing is allowed.
+func brokerRunArgs(brokerDir, runtime string, gid int, gitPushConfig string) []string {
+	args := []string{
+		"-v", fmt.Sprintf("%s:%s", brokerDir, brokerContainerDir),
+		"-e", fmt.Sprintf("PACKNPLAY_BROKER_SOCKET=%s/%s", brokerContainerDir, broker.SocketName),
+	}
+	args = append(args, brokerGroupArgs(runtime, gid)...)
+	if gitPushConfig != "" {
+		// A system config, so the user's own gitconfig (mounted or not) still applies on top
+		args = append(args, "-e", "GIT_CONFIG_SYSTEM="+gitPushConfig)
+	}
+	return args
+}
+
+// brokerGroupArgs adds the host user's gid to the container's groups, so its user can use the
+// broker socket whatever its uid. Apple Container has no --group-add.
+func brokerGroupArgs(runtime string, gid int) []string {
+	if runtime == "container" {
+		return nil
+	}
+	return []string{"--group-add", strconv.Itoa(gid)}
+}
+
+// writeGitPushConfig writes the push helper and a git config that makes it `git host-push`
+// into the broker dir, returning the config's path inside the container
+func writeGitPushConfig(brokerDir string) (string, error) {
+	helperPath := brokerContainerDir + "/git-host-push"
+	if err := os.WriteFile(filepath.Join(brokerDir, "git-host-push"), []byte(gitHostPush), 0755); err != nil {
+		return "", fmt.Errorf("failed to write git push helper: %w", err)
+	}
+
+	gitConfig := fmt.Sprintf("[alias]\n\thost-push = !%s\n", helperPath)
+	if err := os.WriteFile(filepath.Join(brokerDir, "gitconfig"), []byte(gitConfig), 0644); err != nil {
+		return "", fmt.Errorf("failed to write git push config: %w", err)
+	}
+	return brokerContainerDir + "/gitconfig", nil
+}
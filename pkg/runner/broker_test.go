@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBrokerGroupArgs(t *testing.T) {
+	if got := strings.Join(brokerGroupArgs("docker", 1001), " "); got != "--group-add 1001" {
+		t.Errorf("brokerGroupArgs(docker) = %q, want the host gid added", got)
+	}
+	if got := brokerGroupArgs("container", 1001); got != nil {
+		t.Errorf("brokerGroupArgs(container) = %v, want none", got)
+	}
+}
+
+// Every flag the broker adds must be one mount approval can parse, or launch fails
+func TestBrokerRunArgsDescribeMounts(t *testing.T) {
+	args := []string{"run", "-d", "--name", "packnplay-app-main"}
+	args = append(args, brokerRunArgs("/home/me/.local/share/packnplay/broker/packnplay-app-main", "docker", 1001, brokerContainerDir+"/gitconfig")...)
+	args = append(args, "-v", "/home/me/app:/workspace", "image", "sleep", "infinity")
+
+	got, err := describeMounts(args, map[string]string{"/home/me": "~"})
+	if err != nil {
+		t.Fatalf("describeMounts() error = %v", err)
+	}
+	want := []string{
+		"~/.local/share/packnplay/broker/packnplay-app-main -> " + brokerContainerDir,
+		"~/app -> /workspace",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("describeMounts() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/imagebuild"
+)
+
+// browserCacheName is the cache holding Playwright's browser downloads
+const browserCacheName = "playwright"
+
+// withBrowserCache adds the project's browser cache when it asks for the browser bundle, so
+// Chromium is downloaded once per project rather than in every session. A "playwright" cache
+// in the user's config keeps its backend (e.g. team storage) but is mounted where Playwright
+// looks; declaring it is what opts in to sharing browsers across projects.
+func withBrowserCache(caches map[string]config.CacheVolume, projectPath, workDir string) (map[string]config.CacheVolume, error) {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if !projectConfig.Packages.Browsers {
+		return caches, nil
+	}
+
+	merged := make(map[string]config.CacheVolume, len(caches)+1)
+	for name, cache := range caches {
+		merged[name] = cache
+	}
+	name := browserCacheName
+	if _, shared := caches[browserCacheName]; !shared {
+		name = projectCacheName(browserCacheName, workDir)
+	}
+	cache := merged[name]
+	cache.Target = imagebuild.PlaywrightBrowsersPath
+	merged[name] = cache
+	return merged, nil
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/obra/packnplay/pkg/burner"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// mintBurnerCredentials creates the session's provider keys and records them for revocation
+// Keys left behind by sessions whose revoker never ran (host reboot, crash) are revoked first.
+func mintBurnerCredentials(dockerClient *docker.Client, cfgs map[string]config.BurnerCredential, containerName string, verbose bool) ([]string, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	revokeStaleBurnerCredentials(dockerClient)
+
+	creds, err := burner.Mint(cfgs, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]burner.Record, 0, len(creds))
+	for _, cred := range creds {
+		records = append(records, cred.Record)
+	}
+	if err := burner.Save(containerName, records); err != nil {
+		// An unrecorded key could never be revoked, so don't hand it out
+		for _, record := range records {
+			_ = burner.Revoke(record)
+		}
+		return nil, err
+	}
+
+	if verbose {
+		for _, cred := range creds {
+			fmt.Fprintf(os.Stderr, "Minted %s key %s for this session\n", cred.Provider, cred.ID)
+		}
+	}
+	return burner.Env(creds), nil
+}
+
+// revokeStaleBurnerCredentials revokes recorded keys whose containers are no longer running
+func revokeStaleBurnerCredentials(dockerClient *docker.Client) {
+	sessions, err := burner.Sessions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	for _, name := range sessions {
+		if running, err := containerIsRunning(dockerClient, name); err != nil || running {
+			continue
+		}
+		if err := burner.RevokeSession(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to revoke keys for %s: %v\n", name, err)
+		}
+	}
+}
+
+// startBurnerRevoker launches a detached process that revokes the session's keys once the container stops
+func startBurnerRevoker(containerName, runtime string, verbose bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(executable, "burner-revoker",
+		"--container", containerName,
+		"--runtime", runtime,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start burner revoker: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Burner keys for %s will be revoked when it stops\n", containerName)
+	}
+	return nil
+}
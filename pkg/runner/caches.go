@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// cacheNamePattern keeps cache names usable as docker volume names
+var cacheNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// cacheVolumeName returns the docker volume backing a named cache
+func cacheVolumeName(name string) string {
+	return "packnplay-cache-" + name
+}
+
+// projectCacheName keys a built-in cache to one project, so a session of another project
+// can't plant binaries or store paths that this project's sessions then run
+func projectCacheName(name, projectPath string) string {
+	sum := sha256.Sum256([]byte(projectPath))
+	base := invalidCacheChars.ReplaceAllString(filepath.Base(projectPath), "-")
+	return fmt.Sprintf("%s-%s-%s", name, base, hex.EncodeToString(sum[:4]))
+}
+
+// invalidCacheChars matches characters a project directory may have but a volume name can't
+var invalidCacheChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// cacheTarget resolves a cache's container path, expanding ~/ to the container home
+func cacheTarget(target, containerHome string) (string, error) {
+	if strings.HasPrefix(target, "~/") {
+		target = path.Join(containerHome, target[2:])
+	}
+	if !path.IsAbs(target) {
+		return "", fmt.Errorf("cache target '%s' must be absolute or start with ~/", target)
+	}
+	return path.Clean(target), nil
+}
+
+// cacheVolumeCreateArgs returns the `docker volume create` args for a cache's backend
+func cacheVolumeCreateArgs(name string, cache config.CacheVolume, labels map[string]string) ([]string, error) {
+	args := append([]string{"volume", "create"}, container.LabelsToArgs(labels)...)
+
+	backends := 0
+	for _, set := range []bool{cache.NFS != "", cache.SMB != "", cache.Driver != ""} {
+		if set {
+			backends++
+		}
+	}
+	if backends > 1 {
+		return nil, fmt.Errorf("cache '%s' must use only one of nfs, smb or driver", name)
+	}
+
+	switch {
+	case cache.NFS != "":
+		host, export, ok := strings.Cut(cache.NFS, ":")
+		if !ok || host == "" || !strings.HasPrefix(export, "/") {
+			return nil, fmt.Errorf("cache '%s': nfs must be host:/export, got '%s'", name, cache.NFS)
+		}
+		args = append(args, "--driver", "local",
+			"--opt", "type=nfs",
+			"--opt", "o="+joinOptions("addr="+host, cache.Options),
+			"--opt", "device=:"+export)
+
+	case cache.SMB != "":
+		if !strings.HasPrefix(cache.SMB, "//") {
+			return nil, fmt.Errorf("cache '%s': smb must be //host/share, got '%s'", name, cache.SMB)
+		}
+		host, _, _ := strings.Cut(strings.TrimPrefix(cache.SMB, "//"), "/")
+		args = append(args, "--driver", "local",
+			"--opt", "type=cifs",
+			"--opt", "o="+joinOptions("addr="+host, cache.Options),
+			"--opt", "device="+cache.SMB)
+
+	case cache.Driver != "":
+		args = append(args, "--driver", cache.Driver)
+		keys := make([]string, 0, len(cache.DriverOpts))
+		for key := range cache.DriverOpts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args = append(args, "--opt", fmt.Sprintf("%s=%s", key, cache.DriverOpts[key]))
+		}
+	}
+
+	return append(args, cacheVolumeName(name)), nil
+}
+
+// joinOptions appends user mount options to the required ones
+func joinOptions(required, extra string) string {
+	if extra == "" {
+		return required
+	}
+	return required + "," + extra
+}
+
+// cacheMountArgs ensures every configured cache volume exists and returns its -v args
+// and the container paths they're mounted at.
+// Volumes are created once and reused; docker ignores options on an existing volume, so
+// changing a cache's backend requires `docker volume rm packnplay-cache-<name>`.
+func cacheMountArgs(dockerClient *docker.Client, caches map[string]config.CacheVolume, containerHome string, meta container.Metadata) ([]string, []string, error) {
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args, targets []string
+	for _, name := range names {
+		cache := caches[name]
+		if !cacheNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("invalid cache name '%s' (use letters, digits, '.', '_' or '-')", name)
+		}
+		target, err := cacheTarget(cache.Target, containerHome)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		volume := cacheVolumeName(name)
+		if _, err := dockerClient.Run("volume", "inspect", volume); err != nil {
+			createArgs, err := cacheVolumeCreateArgs(name, cache, container.VolumeLabels(name, meta))
+			if err != nil {
+				return nil, nil, err
+			}
+			if output, err := dockerClient.RunWithRetry(docker.DefaultBackoff, createArgs...); err != nil {
+				return nil, nil, fmt.Errorf("failed to create cache volume %s: %w\nDocker output:\n%s", volume, err, output)
+			}
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s", volume, target))
+		targets = append(targets, target)
+	}
+	return args, targets, nil
+}
@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestCacheTarget(t *testing.T) {
+	if got, err := cacheTarget("~/go/pkg/mod", "/home/vscode"); err != nil || got != "/home/vscode/go/pkg/mod" {
+		t.Errorf("cacheTarget(~/...) = %q, %v", got, err)
+	}
+	if got, err := cacheTarget("/var/cache/apt/", "/home/vscode"); err != nil || got != "/var/cache/apt" {
+		t.Errorf("cacheTarget(abs) = %q, %v", got, err)
+	}
+	if _, err := cacheTarget("node_modules", "/home/vscode"); err == nil {
+		t.Error("cacheTarget(relative) should fail")
+	}
+}
+
+func TestCacheVolumeCreateArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cache   config.CacheVolume
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "local volume",
+			cache: config.CacheVolume{Target: "~/.npm"},
+			want:  []string{"volume", "create", "packnplay-cache-npm"},
+		},
+		{
+			name:  "nfs",
+			cache: config.CacheVolume{NFS: "nfs.corp:/exports/npm", Options: "nfsvers=4,soft"},
+			want: []string{"volume", "create", "--driver", "local",
+				"--opt", "type=nfs", "--opt", "o=addr=nfs.corp,nfsvers=4,soft", "--opt", "device=:/exports/npm",
+				"packnplay-cache-npm"},
+		},
+		{
+			name:  "smb",
+			cache: config.CacheVolume{SMB: "//fs.corp/caches/npm", Options: "username=ci"},
+			want: []string{"volume", "create", "--driver", "local",
+				"--opt", "type=cifs", "--opt", "o=addr=fs.corp,username=ci", "--opt", "device=//fs.corp/caches/npm",
+				"packnplay-cache-npm"},
+		},
+		{
+			name:  "plugin",
+			cache: config.CacheVolume{Driver: "s3fs", DriverOpts: map[string]string{"bucket": "team-cache", "region": "us-east-1"}},
+			want: []string{"volume", "create", "--driver", "s3fs",
+				"--opt", "bucket=team-cache", "--opt", "region=us-east-1",
+				"packnplay-cache-npm"},
+		},
+		{
+			name:    "nfs without export path",
+			cache:   config.CacheVolume{NFS: "nfs.corp"},
+			wantErr: true,
+		},
+		{
+			name:    "two backends",
+			cache:   config.CacheVolume{NFS: "nfs.corp:/x", Driver: "s3fs"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cacheVolumeCreateArgs("npm", tt.cache, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cacheVolumeCreateArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cacheVolumeCreateArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithBrowserCache(t *testing.T) {
+	caches := map[string]config.CacheVolume{
+		"gomod":      {Target: "~/go/pkg/mod"},
+		"playwright": {Target: "~/.cache/ms-playwright", NFS: "nas:/playwright"},
+	}
+
+	project := t.TempDir()
+	if got, err := withBrowserCache(caches, project, project); err != nil || !reflect.DeepEqual(got, caches) {
+		t.Errorf("withBrowserCache() without browsers = %v, %v; want caches unchanged", got, err)
+	}
+
+	os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte("packages:\n  browsers: true\n"), 0644)
+	got, err := withBrowserCache(caches, project, project)
+	if err != nil {
+		t.Fatalf("withBrowserCache() error = %v", err)
+	}
+	want := config.CacheVolume{Target: "/opt/ms-playwright", NFS: "nas:/playwright"}
+	if !reflect.DeepEqual(got["playwright"], want) || got["gomod"].Target != "~/go/pkg/mod" {
+		t.Errorf("withBrowserCache() = %v, want the playwright cache's backend kept at /opt/ms-playwright", got)
+	}
+	if caches["playwright"].Target != "~/.cache/ms-playwright" {
+		t.Error("withBrowserCache() modified the user's caches")
+	}
+
+	got, _ = withBrowserCache(nil, project, "/src/app")
+	name := projectCacheName("playwright", "/src/app")
+	if len(got) != 1 || got[name].Target != "/opt/ms-playwright" {
+		t.Errorf("withBrowserCache(nil) = %v, want a local playwright cache named %s", got, name)
+	}
+}
+
+func TestProjectCacheName(t *testing.T) {
+	name := projectCacheName("playwright", "/src/my app")
+	if !strings.HasPrefix(name, "playwright-my-app-") || !cacheNamePattern.MatchString(name) {
+		t.Errorf("projectCacheName() = %q, want a valid volume name for the project", name)
+	}
+	if other := projectCacheName("playwright", "/work/my app"); other == name {
+		t.Errorf("projectCacheName() = %q for two projects with the same directory name", name)
+	}
+}
+
+func TestWithBazelCaches(t *testing.T) {
+	caches := map[string]config.CacheVolume{
+		"bazel-repo": {Target: "/somewhere", NFS: "nas:/bazel"},
+	}
+
+	got := withBazelCaches(caches, "packnplay-monorepo-main")
+	want := map[string]config.CacheVolume{
+		"bazel-monorepo-main": {Target: "~/.cache/bazel"},
+		"bazel-repo":          {Target: "~/.cache/bazel-repo", NFS: "nas:/bazel"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("withBazelCaches() = %v, want %v", got, want)
+	}
+	if caches["bazel-repo"].Target != "/somewhere" {
+		t.Error("withBazelCaches() modified the user's caches")
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// Mount kinds that can be given a consistency mode in config
+const (
+	MountKindWorkspace = "workspace" // the worktree at /workspace
+	MountKindGit       = "git"       // the main repo's .git directory
+	MountKindAgents    = "agents"    // agent config directories and files
+	MountKindHome      = "home"      // persisted home subpaths
+)
+
+// validConsistency are Docker Desktop's bind mount consistency modes
+var validConsistency = map[string]bool{"consistent": true, "cached": true, "delegated": true}
+
+// ValidateMountConsistency checks a mount_consistency config map
+func ValidateMountConsistency(modes map[string]string) error {
+	kinds := map[string]bool{MountKindWorkspace: true, MountKindGit: true, MountKindAgents: true, MountKindHome: true}
+	for kind, mode := range modes {
+		if !kinds[kind] {
+			return fmt.Errorf("unknown mount_consistency mount '%s' (use workspace, git, agents or home)", kind)
+		}
+		if !validConsistency[mode] {
+			return fmt.Errorf("invalid mount_consistency mode '%s' for %s (use consistent, cached or delegated)", mode, kind)
+		}
+	}
+	return nil
+}
+
+// resolveConsistency decides the consistency mode for each mount kind
+// Modes only mean something to Docker Desktop for Mac, so elsewhere nothing is applied.
+// On the older gRPC-FUSE and osxfs backends, where agent-driven npm installs in the
+// workspace crawl, the workspace and git mounts default to cached.
+func resolveConsistency(configured map[string]string, isDockerDesktopMac bool, fileSharing string) map[string]string {
+	if !isDockerDesktopMac {
+		return nil
+	}
+
+	modes := make(map[string]string)
+	if fileSharing == docker.FileSharingGRPCFUSE || fileSharing == docker.FileSharingOSXFS {
+		modes[MountKindWorkspace] = "cached"
+		modes[MountKindGit] = "cached"
+	}
+	for kind, mode := range configured {
+		modes[kind] = mode
+	}
+	return modes
+}
+
+// withMountOption adds an option such as a consistency mode to a -v value, merging with existing ones like ro
+func withMountOption(volume, option string) string {
+	if option == "" {
+		return volume
+	}
+	if strings.Count(volume, ":") >= 2 {
+		return volume + "," + option
+	}
+	return volume + ":" + option
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+func TestWithMountOption(t *testing.T) {
+	tests := []struct {
+		volume string
+		mode   string
+		want   string
+	}{
+		{"/src:/workspace", "cached", "/src:/workspace:cached"},
+		{"/h/.aws/config:/home/u/.aws/config:ro", "delegated", "/h/.aws/config:/home/u/.aws/config:ro,delegated"},
+		{"/src:/workspace", "", "/src:/workspace"},
+	}
+
+	for _, tt := range tests {
+		if got := withMountOption(tt.volume, tt.mode); got != tt.want {
+			t.Errorf("withMountOption(%q, %q) = %q, want %q", tt.volume, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestResolveConsistency(t *testing.T) {
+	configured := map[string]string{MountKindAgents: "delegated", MountKindWorkspace: "consistent"}
+
+	if modes := resolveConsistency(configured, false, ""); modes != nil {
+		t.Errorf("resolveConsistency() off Docker Desktop = %v, want nil", modes)
+	}
+
+	modes := resolveConsistency(nil, true, docker.FileSharingGRPCFUSE)
+	if modes[MountKindWorkspace] != "cached" || modes[MountKindGit] != "cached" {
+		t.Errorf("resolveConsistency() on gRPC-FUSE = %v, want cached workspace and git", modes)
+	}
+
+	if modes := resolveConsistency(nil, true, docker.FileSharingVirtioFS); len(modes) != 0 {
+		t.Errorf("resolveConsistency() on VirtioFS = %v, want no defaults", modes)
+	}
+
+	modes = resolveConsistency(configured, true, docker.FileSharingGRPCFUSE)
+	if modes[MountKindWorkspace] != "consistent" || modes[MountKindAgents] != "delegated" {
+		t.Errorf("resolveConsistency() = %v, config should override defaults", modes)
+	}
+}
+
+func TestValidateMountConsistency(t *testing.T) {
+	if err := ValidateMountConsistency(map[string]string{MountKindWorkspace: "cached", MountKindHome: "delegated"}); err != nil {
+		t.Errorf("ValidateMountConsistency() error = %v", err)
+	}
+	if err := ValidateMountConsistency(map[string]string{"everything": "cached"}); err == nil {
+		t.Error("ValidateMountConsistency() accepted unknown mount kind")
+	}
+	if err := ValidateMountConsistency(map[string]string{MountKindWorkspace: "fast"}); err == nil {
+		t.Error("ValidateMountConsistency() accepted unknown mode")
+	}
+}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/devcerts"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// devCertArgs mounts the session's dev certificate read-only and points tools at it
+func devCertArgs(settings config.DevCertsConfig, verbose bool) ([]string, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+	dir, err := devcerts.Prepare(settings, devcerts.HostRunner)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Mounting dev certificate from %s at %s\n", dir, devcerts.ContainerDir)
+	}
+
+	args := []string{"-v", fmt.Sprintf("%s:%s:ro", dir, devcerts.ContainerDir)}
+	for _, env := range devcerts.Env(dir) {
+		args = append(args, "-e", env)
+	}
+	return args, nil
+}
+
+// trustDevCA adds the dev CA to the container's trust store, so tools inside can call
+// the session's own HTTPS servers. Failing only costs that, so it's a warning.
+func trustDevCA(dockerClient *docker.Client, containerID string) {
+	if output, err := dockerClient.Run("exec", "-u", "root", containerID, "sh", "-c", devcerts.TrustScript()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to trust the dev CA inside the container: %v\n%s", err, output)
+	}
+}
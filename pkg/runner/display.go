@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/display"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// displayArgs gives the session a display: the host's forwarded sockets, or a virtual one
+// that the sidecar started by startDisplaySidecar serves inside the session's network
+func displayArgs(settings config.DisplayConfig, containerName string, verbose bool) ([]string, error) {
+	switch settings.Mode {
+	case display.ModeHost:
+		if goruntime.GOOS != "linux" {
+			return nil, fmt.Errorf("display mode host needs a Linux host with X11 or Wayland; use display mode vnc")
+		}
+		xdgDataHome := os.Getenv("XDG_DATA_HOME")
+		if xdgDataHome == "" {
+			homeDir, _ := os.UserHomeDir()
+			xdgDataHome = filepath.Join(homeDir, ".local", "share")
+		}
+		xauthPath := filepath.Join(xdgDataHome, "packnplay", "display", containerName+".Xauthority")
+		args, err := display.HostArgs(os.Getenv, xauthPath)
+		if err != nil {
+			return nil, err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Forwarding the host display\n")
+		}
+		return args, nil
+	case display.ModeVNC:
+		return display.SessionArgs(), nil
+	}
+	return nil, nil
+}
+
+// startDisplaySidecar runs the virtual display next to the session and prints where to watch it
+func startDisplaySidecar(dockerClient *docker.Client, settings config.DisplayConfig, containerName string) error {
+	if settings.Mode != display.ModeVNC {
+		return nil
+	}
+	_, _ = dockerClient.Run("rm", "-f", display.SidecarName(containerName))
+	if output, err := dockerClient.Run(display.SidecarArgs(containerName, settings.VNCImage, settings.Resolution)...); err != nil {
+		return fmt.Errorf("failed to start virtual display: %w\n%s", err, output)
+	}
+	if output, err := dockerClient.Run("port", containerName, display.NoVNCPort); err == nil {
+		address := strings.TrimSpace(strings.Split(output, "\n")[0])
+		fmt.Fprintf(os.Stderr, "Watch the session's display at http://%s/vnc.html\n", address)
+	}
+	return nil
+}
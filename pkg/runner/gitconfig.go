@@ -0,0 +1,23 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// gitConfigArgs returns docker args that set git config entries ("key=value") through git's
+// environment config. Git reads a single GIT_CONFIG_COUNT list, so every feature that needs
+// session git config adds its entries here rather than setting the variables itself.
+func gitConfigArgs(entries []string) []string {
+	if len(entries) == 0 {
+		return nil
+	}
+	args := []string{"-e", fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(entries))}
+	for i, entry := range entries {
+		key, value, _ := strings.Cut(entry, "=")
+		args = append(args,
+			"-e", fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, key),
+			"-e", fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, value))
+	}
+	return args
+}
@@ -0,0 +1,24 @@
+package runner
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/gitidentity"
+)
+
+func TestGitConfigArgsCombinesEntries(t *testing.T) {
+	entries := append(gitidentity.GitConfig(), "credential.https://github.com.helper=", "credential.https://github.com.helper=!f() { echo a=b; }; f")
+	want := []string{
+		"-e", "GIT_CONFIG_COUNT=3",
+		"-e", "GIT_CONFIG_KEY_0=core.hooksPath", "-e", "GIT_CONFIG_VALUE_0=" + gitidentity.ContainerDir + "/hooks",
+		"-e", "GIT_CONFIG_KEY_1=credential.https://github.com.helper", "-e", "GIT_CONFIG_VALUE_1=",
+		"-e", "GIT_CONFIG_KEY_2=credential.https://github.com.helper", "-e", "GIT_CONFIG_VALUE_2=!f() { echo a=b; }; f",
+	}
+	if got := gitConfigArgs(entries); !slices.Equal(got, want) {
+		t.Errorf("gitConfigArgs() = %q, want %q", got, want)
+	}
+	if got := gitConfigArgs(nil); got != nil {
+		t.Errorf("gitConfigArgs(nil) = %q, want none", got)
+	}
+}
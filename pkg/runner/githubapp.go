@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/githubapp"
+)
+
+// githubCredentialHelper answers git's credential requests with the session's current token
+// It reads the published file rather than $GH_TOKEN, which can't be updated once the container runs.
+var githubCredentialHelper = fmt.Sprintf(`!f() { test "$1" = get && echo username=x-access-token && echo "password=$(cat %s)"; }; f`,
+	filepath.Join(githubapp.ContainerTokenDir, "token"))
+
+// mintGitHubAppToken creates a token for the workspace's repository and returns the args exposing
+// it, and the git config entries that make git use it
+// A workspace that isn't on the app's GitHub gets no token; the host's is never forwarded instead.
+func mintGitHubAppToken(settings config.GitHubAppConfig, containerName, mountPath string, verbose bool) ([]string, []string, error) {
+	if !githubapp.Enabled(settings) {
+		return nil, nil, nil
+	}
+
+	apiURL := githubapp.APIURL(settings)
+	host := githubapp.Host(apiURL)
+	remote, _ := git.GetRemoteURL(mountPath, "origin")
+	owner, repo, ok := githubapp.ParseRemote(remote, host)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Warning: %s has no %s origin remote, so the session gets no GitHub token\n", mountPath, host)
+		return nil, nil, nil
+	}
+
+	client, err := githubapp.NewClient(apiURL, settings.AppID, settings.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	installationID := settings.InstallationID
+	if installationID == 0 {
+		if installationID, err = client.Installation(owner, repo); err != nil {
+			return nil, nil, err
+		}
+	}
+	permissions := githubapp.Permissions(settings)
+	token, err := client.Mint(installationID, repo, permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state := &githubapp.State{
+		APIURL:         apiURL,
+		AppID:          settings.AppID,
+		PrivateKey:     settings.PrivateKey,
+		InstallationID: installationID,
+		Owner:          owner,
+		Repo:           repo,
+		Permissions:    permissions,
+		Token:          token,
+	}
+	if err := githubapp.Save(containerName, state); err != nil {
+		// An unrecorded token could never be revoked, so don't hand it out
+		_ = githubapp.Revoke(apiURL, token.Token)
+		return nil, nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Minted a GitHub App token for %s/%s with %v\n", owner, repo, permissions)
+	}
+
+	// The token file is published once the container runs, see publishGitHubToken
+	args := []string{"--tmpfs", githubapp.ContainerTokenDir + ":mode=0755"}
+	for _, envVar := range githubapp.TokenVars {
+		args = append(args, "-e", envVar+"="+token.Token)
+	}
+	if host != "github.com" {
+		args = append(args, "-e", "GH_HOST="+host, "-e", "GH_ENTERPRISE_TOKEN="+token.Token)
+	}
+	// An empty helper first clears any from a mounted ~/.gitconfig, so git can't fall back to host credentials
+	helperKey := fmt.Sprintf("credential.https://%s.helper", host)
+	gitConfig := []string{helperKey + "=", helperKey + "=" + githubCredentialHelper}
+	return args, gitConfig, nil
+}
+
+// publishGitHubToken writes the session's token into its running container, for the credential helper
+func publishGitHubToken(dockerClient *docker.Client, containerName string) error {
+	state, err := githubapp.Load(containerName)
+	if err != nil || state == nil {
+		return err
+	}
+	return githubapp.Publish(dockerClient, containerName, state.Token.Token)
+}
+
+// startGitHubTokenRefresher launches a detached process that keeps the session's token fresh
+// and revokes it once the container stops
+func startGitHubTokenRefresher(containerName, runtime string, verbose bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(executable, "github-token-refresher",
+		"--container", containerName,
+		"--runtime", runtime,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start GitHub token refresher: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "The GitHub token for %s will be refreshed until it stops\n", containerName)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestMintGitHubAppTokenSkipsOtherHosts(t *testing.T) {
+	repo := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", "git@gitlab.com:obra/packnplay.git"}} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	// The key is never read: a workspace the app can't reach gets no token, and no error
+	settings := config.GitHubAppConfig{AppID: 42, PrivateKey: "/nonexistent/app.pem"}
+	args, gitConfig, err := mintGitHubAppToken(settings, "packnplay-app-main", repo, false)
+	if err != nil || args != nil || gitConfig != nil {
+		t.Errorf("mintGitHubAppToken() = %v, %v; want no token", args, err)
+	}
+
+	if args, _, err := mintGitHubAppToken(config.GitHubAppConfig{}, "packnplay-app-main", repo, false); err != nil || args != nil {
+		t.Errorf("mintGitHubAppToken() disabled = %v, %v", args, err)
+	}
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/obra/packnplay/pkg/ptyproxy"
+	"github.com/obra/packnplay/pkg/recording"
+)
+
+// holdSession runs the session's docker exec in a detached terminal holder and attaches to
+// it, so the detach keys leave the agent running for a later 'packnplay run --reconnect'
+// The holder records the session too, when it's recorded.
+func holdSession(config *RunConfig, cmdPath string, execArgs []string, containerName string) error {
+	width, height := 80, 24
+	if w, h, err := term.GetSize(os.Stdout.Fd()); err == nil {
+		width, height = w, h
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	socketPath := ptyproxy.SocketPath(containerName)
+	args := []string{"session-terminal",
+		"--socket", socketPath,
+		"--title", containerName,
+		"--width", fmt.Sprint(width),
+		"--height", fmt.Sprint(height),
+	}
+	if config.Record {
+		path, err := recording.NewPath(containerName, time.Now())
+		if err != nil {
+			return err
+		}
+		if config.Verbose {
+			fmt.Fprintf(os.Stderr, "Recording session to %s\n", path)
+		}
+		args = append(args, "--record", path)
+		// Only the names: the holder inherits the environment to read the values from
+		for _, name := range recordingRedactEnv(config) {
+			args = append(args, "--redact-env", name)
+		}
+	}
+	args = append(append(args, "--", cmdPath), execArgs...)
+
+	cmd := exec.Command(executable, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start session terminal: %w", err)
+	}
+	// Reaped by init once packnplay exits; don't leave it a zombie until then
+	go func() { _ = cmd.Wait() }()
+
+	return attachTerminal(config, socketPath, containerName, 5*time.Second)
+}
+
+// attachTerminal connects to a session's held terminal, then exits with its command's status
+// unless the user detached
+func attachTerminal(config *RunConfig, socketPath, containerName string, wait time.Duration) error {
+	keys, err := ptyproxy.ParseKeys(config.DetachKeys)
+	if err != nil {
+		return err
+	}
+	conn, err := ptyproxy.Dial(socketPath, wait)
+	if err != nil {
+		return fmt.Errorf("failed to attach to session terminal: %w", err)
+	}
+
+	status, err := statusBar(config, containerName)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	result, err := ptyproxy.Attach(conn, keys, status)
+	if err != nil {
+		return err
+	}
+	if result.Detached {
+		fmt.Fprintf(os.Stderr, "\nDetached from %s; it keeps running. Reattach with 'packnplay run --reconnect' in the same project.\n", containerName)
+		return nil
+	}
+	os.Exit(result.ExitCode)
+	return nil
+}
+
+// heldTerminal returns the socket of the session's held terminal, if one is still running
+func heldTerminal(containerName string) (string, bool) {
+	socketPath := ptyproxy.SocketPath(containerName)
+	conn, err := ptyproxy.Dial(socketPath, 0)
+	if err != nil {
+		return "", false
+	}
+	conn.Close()
+	return socketPath, true
+}
@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// historyDir holds persisted shell history, relative to the container home
+const historyDir = ".packnplay-history"
+
+// getHomePersistDir returns the host directory backing a session's persisted home subpaths
+func getHomePersistDir(containerName string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+
+	return filepath.Join(xdgDataHome, "packnplay", "homes", containerName), nil
+}
+
+// homeMountArgs makes the container home a tmpfs and bind-mounts each declared
+// subpath from the session's persist directory, returning the docker args
+func homeMountArgs(containerHome, persistDir string, cfg config.HomeConfig) ([]string, error) {
+	args := []string{"--tmpfs", containerHome + ":rw,exec,mode=0755"}
+
+	subpaths := append([]string{}, cfg.Persist...)
+	if cfg.PersistHistory {
+		subpaths = append(subpaths, historyDir)
+	}
+
+	for _, subpath := range subpaths {
+		clean := path.Clean(subpath)
+		if path.IsAbs(clean) || clean == "." || strings.HasPrefix(clean, "..") {
+			return nil, fmt.Errorf("home.persist entry '%s' must be a path inside the home directory", subpath)
+		}
+
+		hostPath := filepath.Join(persistDir, filepath.FromSlash(clean))
+		if err := os.MkdirAll(hostPath, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", hostPath, err)
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s", hostPath, path.Join(containerHome, clean)))
+	}
+
+	if cfg.PersistHistory {
+		// bash and zsh both honor HISTFILE; a file inside a mounted dir survives atomic rewrites
+		args = append(args, "-e", fmt.Sprintf("HISTFILE=%s", path.Join(containerHome, historyDir, "history")))
+	}
+	return args, nil
+}
+
+// mountedUnder returns the "./"-relative destinations of -v mounts inside dir
+// Seeding the home must skip these, or image files would be written through to the host.
+func mountedUnder(args []string, dir string) []string {
+	var paths []string
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] != "-v" {
+			continue
+		}
+		parts := strings.Split(args[i+1], ":")
+		if len(parts) < 2 {
+			continue
+		}
+		if rel := strings.TrimPrefix(parts[1], dir+"/"); rel != parts[1] && rel != "" {
+			paths = append(paths, "./"+rel)
+		}
+	}
+	return paths
+}
+
+// seedHome copies the image's home directory into the container's tmpfs home
+// A tmpfs hides whatever the image put there (shell rc files, user-installed tools),
+// so stream it across from a throwaway container of the same image.
+func seedHome(dockerClient *docker.Client, image, containerID, containerHome, user string, exclude []string) error {
+	createArgs := []string{"run", "--rm", "--entrypoint", "tar", image, "-C", containerHome}
+	for _, path := range exclude {
+		createArgs = append(createArgs, "--exclude="+path)
+	}
+	createArgs = append(createArgs, "-cf", "-", ".")
+
+	src := dockerClient.Stream(createArgs...)
+	dst := dockerClient.Stream("exec", "-i", "-u", "root", containerID, "tar", "-C", containerHome, "-xpf", "-")
+
+	pipe, err := src.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to stream home directory: %w", err)
+	}
+	dst.Stdin = pipe
+
+	if err := src.Start(); err != nil {
+		return fmt.Errorf("failed to read image home directory: %w", err)
+	}
+	dstOutput, dstErr := dst.CombinedOutput()
+	srcErr := src.Wait()
+	if srcErr != nil {
+		return fmt.Errorf("failed to read image home directory: %w", srcErr)
+	}
+	if dstErr != nil {
+		return fmt.Errorf("failed to populate home directory: %w\n%s", dstErr, dstOutput)
+	}
+
+	output, err := dockerClient.Run("exec", "-u", "root", containerID, "chown", fmt.Sprintf("%s:%s", user, user), containerHome)
+	if err != nil {
+		return fmt.Errorf("failed to set home directory owner: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// streamFileToContainer writes a host file into the container as user
+// docker cp can't write into tmpfs mounts, so the tmpfs home needs this instead.
+func streamFileToContainer(dockerClient *docker.Client, containerID, srcPath, dstPath, user string) error {
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer file.Close()
+
+	cmd := dockerClient.Stream("exec", "-i", "-u", user, containerID,
+		"sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", dstPath)
+	cmd.Stdin = file
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w\n%s", srcPath, dstPath, err, output)
+	}
+	return nil
+}
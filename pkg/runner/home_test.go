@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestHomeMountArgs(t *testing.T) {
+	persistDir := t.TempDir()
+	cfg := config.HomeConfig{PersistHistory: true, Persist: []string{".cache/pip"}}
+
+	args, err := homeMountArgs("/home/vscode", persistDir, cfg)
+	if err != nil {
+		t.Fatalf("homeMountArgs() error = %v", err)
+	}
+
+	want := []string{
+		"--tmpfs", "/home/vscode:rw,exec,mode=0755",
+		"-v", filepath.Join(persistDir, ".cache", "pip") + ":/home/vscode/.cache/pip",
+		"-v", filepath.Join(persistDir, historyDir) + ":/home/vscode/" + historyDir,
+		"-e", "HISTFILE=/home/vscode/" + historyDir + "/history",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("homeMountArgs() = %v, want %v", args, want)
+	}
+
+	if info, err := os.Stat(filepath.Join(persistDir, ".cache", "pip")); err != nil || !info.IsDir() {
+		t.Errorf("persist directory not created: %v", err)
+	}
+}
+
+func TestHomeMountArgs_RejectsEscapes(t *testing.T) {
+	for _, subpath := range []string{"/etc", "../outside", "."} {
+		if _, err := homeMountArgs("/home/vscode", t.TempDir(), config.HomeConfig{Persist: []string{subpath}}); err == nil {
+			t.Errorf("homeMountArgs() accepted persist entry %q", subpath)
+		}
+	}
+}
+
+func TestMountedUnder(t *testing.T) {
+	args := []string{
+		"-v", "/host/.claude:/home/vscode/.claude",
+		"-v", "/host/.gitconfig:/home/vscode/.gitconfig:ro",
+		"-v", "/src/app:/workspace",
+		"-e", "HOME=/home/vscode",
+	}
+
+	got := mountedUnder(args, "/home/vscode")
+	want := []string{"./.claude", "./.gitconfig"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mountedUnder() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// getSanitizedDir returns where a container's hook-transformed config copies live
+func getSanitizedDir(containerName string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+
+	return filepath.Join(xdgDataHome, "packnplay", "sanitized", containerName), nil
+}
+
+// hookHostPath resolves a hook's path; relative paths and ~/ are under the host home
+func hookHostPath(path, homeDir string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(homeDir, rest)
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(homeDir, path)
+}
+
+// mountedAt returns where a host file appears in the container, given the docker run args
+// The most specific -v mount covering the file wins, as it does in the runtime.
+func mountedAt(args []string, hostPath string) (string, bool) {
+	var best, target string
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] != "-v" {
+			continue
+		}
+		parts := strings.Split(args[i+1], ":")
+		if len(parts) < 2 || !filepath.IsAbs(parts[0]) {
+			continue // named volumes never hold host files
+		}
+		src, dst := parts[0], parts[1]
+
+		switch {
+		case hostPath == src:
+			if len(src) > len(best) {
+				best, target = src, dst
+			}
+		case strings.HasPrefix(hostPath, src+"/"):
+			if len(src) > len(best) {
+				best, target = src, filepath.Join(dst, strings.TrimPrefix(hostPath, src+"/"))
+			}
+		}
+	}
+	return target, best != ""
+}
+
+// runMountHook feeds a file through a hook program and returns what it printed
+func runMountHook(hook config.MountHook, hostPath string) ([]byte, error) {
+	input, err := os.ReadFile(hostPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", hostPath, err)
+	}
+
+	cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "PACKNPLAY_HOOK_PATH="+hostPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("mount hook for %s failed: %w\n%s", hook.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// applyMountHooks runs each hook over its host file and returns docker args that mount the
+// transformed copy over the original, plus a host path -> copy map for files packnplay copies
+// into the container itself. A failing hook stops the session rather than exposing the original.
+func applyMountHooks(hooks []config.MountHook, args []string, homeDir, containerName string, copied []string, verbose bool) ([]string, map[string]string, error) {
+	if len(hooks) == 0 {
+		return nil, nil, nil
+	}
+
+	sanitizedDir, err := getSanitizedDir(containerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Start clean so copies from a previous session with different hooks don't linger
+	if err := os.RemoveAll(sanitizedDir); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear sanitized dir: %w", err)
+	}
+
+	var mountArgs []string
+	replaced := make(map[string]string)
+	for i, hook := range hooks {
+		if hook.Path == "" || len(hook.Command) == 0 {
+			return nil, nil, fmt.Errorf("mount_hooks[%d] needs both path and command", i)
+		}
+
+		hostPath := hookHostPath(hook.Path, homeDir)
+		if info, err := os.Stat(hostPath); err != nil || info.IsDir() {
+			continue // nothing to expose
+		}
+
+		containerPath, mounted := mountedAt(args, hostPath)
+		if !mounted {
+			// Dotfiles are often symlinks, and some are mounted by their resolved path
+			if resolved, err := filepath.EvalSymlinks(hostPath); err == nil {
+				containerPath, mounted = mountedAt(args, resolved)
+			}
+		}
+		isCopied := false
+		for _, path := range copied {
+			isCopied = isCopied || path == hostPath
+		}
+		if !mounted && !isCopied {
+			continue
+		}
+
+		output, err := runMountHook(hook, hostPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		copyPath := filepath.Join(sanitizedDir, fmt.Sprintf("%d-%s", i, filepath.Base(hostPath)))
+		if err := os.MkdirAll(sanitizedDir, 0700); err != nil {
+			return nil, nil, fmt.Errorf("failed to create sanitized dir: %w", err)
+		}
+		if err := os.WriteFile(copyPath, output, 0600); err != nil {
+			return nil, nil, fmt.Errorf("failed to write sanitized %s: %w", hook.Path, err)
+		}
+
+		if mounted {
+			mountArgs = append(mountArgs, "-v", fmt.Sprintf("%s:%s", copyPath, containerPath))
+		}
+		if isCopied {
+			replaced[hostPath] = copyPath
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Exposing %s through mount hook %s\n", hook.Path, strings.Join(hook.Command, " "))
+		}
+	}
+	return mountArgs, replaced, nil
+}
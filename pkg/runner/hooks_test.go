@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestMountedAt(t *testing.T) {
+	args := []string{
+		"-v", "/home/me/.claude:/home/vscode/.claude",
+		"-v", "/tmp/creds.json:/home/vscode/.claude/.credentials.json",
+		"-v", "/home/me/.claude/projects:/home/vscode/.claude/projects:ro",
+		"-v", "packnplay-cache-npm:/home/vscode/.npm",
+		"-e", "/home/me/.claude:/nowhere",
+	}
+
+	tests := []struct {
+		hostPath string
+		want     string
+		ok       bool
+	}{
+		{"/home/me/.claude/settings.json", "/home/vscode/.claude/settings.json", true},
+		{"/home/me/.claude/projects/a.jsonl", "/home/vscode/.claude/projects/a.jsonl", true},
+		{"/tmp/creds.json", "/home/vscode/.claude/.credentials.json", true},
+		{"/home/me/.claudette/x", "", false},
+		{"/home/me/.gitconfig", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := mountedAt(args, tt.hostPath)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("mountedAt(%q) = %q, %v; want %q, %v", tt.hostPath, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestApplyMountHooks(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".claude"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	settings := filepath.Join(home, ".claude", "settings.json")
+	if err := os.WriteFile(settings, []byte(`{"token":"secret"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	claudeJSON := filepath.Join(home, ".claude.json")
+	if err := os.WriteFile(claudeJSON, []byte("mcp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-v", filepath.Join(home, ".claude") + ":/home/vscode/.claude"}
+	hooks := []config.MountHook{
+		{Path: ".claude/settings.json", Command: []string{"sed", "s/secret/REMOVED/"}},
+		{Path: "~/.claude.json", Command: []string{"tr", "a-z", "A-Z"}},
+		{Path: ".codex/config.toml", Command: []string{"false"}}, // missing file, never run
+	}
+
+	mountArgs, replaced, err := applyMountHooks(hooks, args, home, "packnplay-app-main", []string{claudeJSON}, false)
+	if err != nil {
+		t.Fatalf("applyMountHooks() error: %v", err)
+	}
+
+	if len(mountArgs) != 2 || mountArgs[0] != "-v" || !strings.HasSuffix(mountArgs[1], ":/home/vscode/.claude/settings.json") {
+		t.Fatalf("mount args = %v", mountArgs)
+	}
+	copyPath := strings.Split(mountArgs[1], ":")[0]
+	if data, _ := os.ReadFile(copyPath); string(data) != `{"token":"REMOVED"}` {
+		t.Errorf("sanitized settings = %q", data)
+	}
+	if data, _ := os.ReadFile(settings); string(data) != `{"token":"secret"}` {
+		t.Errorf("host file was modified: %q", data)
+	}
+
+	if data, _ := os.ReadFile(replaced[claudeJSON]); string(data) != "MCP" {
+		t.Errorf("sanitized .claude.json = %q", data)
+	}
+}
+
+func TestApplyMountHooksFailsClosed(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	home := t.TempDir()
+	path := filepath.Join(home, ".gitconfig")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-v", path + ":/home/vscode/.gitconfig:ro"}
+	hooks := []config.MountHook{{Path: ".gitconfig", Command: []string{"sh", "-c", "echo broken >&2; exit 1"}}}
+	_, _, err := applyMountHooks(hooks, args, home, "packnplay-app-main", nil, false)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("applyMountHooks() error = %v, want hook failure with its stderr", err)
+	}
+}
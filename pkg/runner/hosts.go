@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+)
+
+// hostnamePattern is a DNS name: dot-separated labels of letters, digits and inner hyphens
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+
+// hostsArgs sets the sandbox hostname and adds /etc/hosts entries, sorted so the args are stable
+// An entry's address may be "host-gateway", which the runtime resolves to the host.
+func hostsArgs(hostname string, extraHosts map[string]string) ([]string, error) {
+	var args []string
+	if hostname != "" {
+		if !hostnamePattern.MatchString(hostname) || len(hostname) > 253 {
+			return nil, fmt.Errorf("invalid hostname '%s'", hostname)
+		}
+		args = append(args, "--hostname", hostname)
+	}
+
+	names := make([]string, 0, len(extraHosts))
+	for name := range extraHosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		address := extraHosts[name]
+		if !hostnamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid extra_hosts name '%s'", name)
+		}
+		if address != "host-gateway" && net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("extra_hosts entry for %s: '%s' is not an IP address or host-gateway", name, address)
+		}
+		args = append(args, "--add-host", name+":"+address)
+	}
+	return args, nil
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostsArgs(t *testing.T) {
+	args, err := hostsArgs("sandbox.local", map[string]string{
+		"api.staging.example.com": "10.8.0.1",
+		"db.staging.example.com":  "fd00::5",
+		"host.internal":           "host-gateway",
+	})
+	if err != nil {
+		t.Fatalf("hostsArgs() error = %v", err)
+	}
+	want := []string{
+		"--hostname", "sandbox.local",
+		"--add-host", "api.staging.example.com:10.8.0.1",
+		"--add-host", "db.staging.example.com:fd00::5",
+		"--add-host", "host.internal:host-gateway",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("hostsArgs() = %v, want %v", args, want)
+	}
+
+	if args, err := hostsArgs("", nil); err != nil || len(args) != 0 {
+		t.Errorf("hostsArgs() with nothing configured = %v, %v", args, err)
+	}
+
+	for name, tt := range map[string]struct {
+		hostname string
+		hosts    map[string]string
+	}{
+		"hostname with space": {hostname: "my box"},
+		"hostname as option":  {hostname: "-h"},
+		"bad address":         {hosts: map[string]string{"api.example.com": "staging"}},
+		"bad name":            {hosts: map[string]string{"api.example.com:80": "10.0.0.1"}},
+	} {
+		if _, err := hostsArgs(tt.hostname, tt.hosts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
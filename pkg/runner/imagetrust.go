@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/devcontainer"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/imagetrust"
+	"github.com/obra/packnplay/pkg/lockfile"
+)
+
+// committedPin reports whether the lockfile committed at HEAD pins digest for the configured
+// image. The sandbox can edit the working tree's lockfile, so only a committed pin was
+// reviewed, and only it vouches for the image under trust_lockfile.
+func committedPin(projectPath, configuredImage, digest string) bool {
+	lock, err := lockfile.LoadCommitted(projectPath)
+	if err != nil || lock == nil {
+		return false
+	}
+	committed, err := lock.PinnedImage(configuredImage)
+	return err == nil && committed == digest
+}
+
+// verifyImage checks the session image against the image_trust policy before anything runs in it
+// A Dockerfile's base images are checked instead of the local build. Returns the digest
+// reference to run, so a tag moved after verification can't swap the image underneath.
+func verifyImage(dockerClient *docker.Client, settings config.ImageTrustConfig, devConfig *devcontainer.Config, projectPath string, committed, verbose bool) (string, error) {
+	if !imagetrust.Enabled(settings) {
+		return devConfig.Image, nil
+	}
+	check := func(ref string, err error) error {
+		if err == nil {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Verified image %s\n", ref)
+			}
+			return nil
+		}
+		if settings.Policy == imagetrust.PolicyEnforce {
+			return fmt.Errorf("refusing to run untrusted image: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: untrusted image: %v\n", err)
+		return nil
+	}
+
+	if devConfig.DockerFile != "" {
+		bases, err := imagetrust.BaseImages(filepath.Join(projectPath, ".devcontainer", devConfig.DockerFile))
+		if err != nil {
+			return "", check(devConfig.DockerFile, err)
+		}
+		for _, base := range bases {
+			if err := check(base, imagetrust.Verify(settings, base, false, imagetrust.HostRunner)); err != nil {
+				return "", err
+			}
+		}
+		return devConfig.Image, nil
+	}
+
+	ref := devConfig.Image
+	if !strings.Contains(ref, "@sha256:") {
+		digest, err := lockfile.ResolveDigest(dockerClient, ref)
+		if err != nil {
+			return devConfig.Image, check(ref, err)
+		}
+		ref = digest
+	}
+	if err := check(ref, imagetrust.Verify(settings, ref, committed, imagetrust.HostRunner)); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
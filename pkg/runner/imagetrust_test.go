@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/lockfile"
+)
+
+func TestCommittedPin(t *testing.T) {
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	const image = "ghcr.io/obra/packnplay-default:latest"
+	reviewed := "ghcr.io/obra/packnplay-default@sha256:" + "1111111111111111111111111111111111111111111111111111111111111111"
+	edited := "ghcr.io/obra/packnplay-default@sha256:" + "2222222222222222222222222222222222222222222222222222222222222222"
+
+	git("init", "-q")
+	if committedPin(repo, image, reviewed) {
+		t.Error("committedPin() with no commits = true")
+	}
+
+	if err := lockfile.Save(repo, &lockfile.Lockfile{Image: image, ImageDigest: reviewed}); err != nil {
+		t.Fatal(err)
+	}
+	if committedPin(repo, image, reviewed) {
+		t.Error("committedPin() with an uncommitted lockfile = true")
+	}
+	git("add", lockfile.FileName)
+	git("commit", "-q", "-m", "pin image")
+	if !committedPin(repo, image, reviewed) {
+		t.Error("committedPin() with the committed digest = false")
+	}
+
+	// The sandbox repinning the working tree's lockfile doesn't make its digest trusted
+	if err := lockfile.Save(repo, &lockfile.Lockfile{Image: image, ImageDigest: edited}); err != nil {
+		t.Fatal(err)
+	}
+	if committedPin(repo, image, edited) {
+		t.Error("committedPin() with an edited, uncommitted digest = true")
+	}
+}
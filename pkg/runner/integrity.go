@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/integrity"
+	"github.com/obra/packnplay/pkg/policy"
+)
+
+// plantCanaries sets up the checks run once the session is torn down: canaries beside its
+// writable mounts, and fingerprints of its read-only mounts and of sensitive host files
+func plantCanaries(config *RunConfig, args []string, homeDir, containerName string) error {
+	// packnplay rewrites its own session files (env, tokens) while the session runs
+	dataDir := filepath.Join(homeDir, ".local", "share", "packnplay")
+	if xdgDataHome := os.Getenv("XDG_DATA_HOME"); xdgDataHome != "" {
+		dataDir = filepath.Join(xdgDataHome, "packnplay")
+	}
+
+	spec, err := policy.ParseRunArgs(args)
+	if err != nil {
+		return fmt.Errorf("failed to set up integrity checks: %w", err)
+	}
+	var writable, readOnly []string
+	for _, mount := range spec.Mounts {
+		if !filepath.IsAbs(mount.Source) {
+			continue // named volumes live in the runtime, not next to anything on the host
+		}
+		switch {
+		case !mount.ReadOnly:
+			writable = append(writable, mount.Source)
+		case mount.Source != dataDir && !strings.HasPrefix(mount.Source, dataDir+string(filepath.Separator)):
+			readOnly = append(readOnly, mount.Source)
+		}
+	}
+
+	var watch []string
+	for _, path := range append(append([]string(nil), integrity.DefaultWatch...), config.IntegrityWatch...) {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			path = rest
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(homeDir, path)
+		}
+		watch = append(watch, path)
+	}
+
+	manifest, err := integrity.Plant(containerName, writable, readOnly, watch)
+	if err != nil {
+		return fmt.Errorf("failed to set up integrity checks: %w", err)
+	}
+	if config.Verbose {
+		fmt.Fprintf(os.Stderr, "Integrity checks: %d paths will be verified when the session ends\n", len(manifest.Checks))
+	}
+	return nil
+}
+
+// reportIntegrity verifies a torn-down session's integrity checks, alerting about and
+// auditing anything that changed, then removes its canaries
+func reportIntegrity(containerName string) {
+	violations, err := integrity.Verify(containerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to verify sandbox integrity: %v\n", err)
+		return
+	}
+	if len(violations) > 0 {
+		breach := violations[0].Breach() // breaches sort first
+		if breach {
+			fmt.Fprintf(os.Stderr, "\n!!! SANDBOX BOUNDARY VIOLATED: %s wrote outside its mounts !!!\n", containerName)
+		} else {
+			fmt.Fprintf(os.Stderr, "\n!!! Host files changed during %s; if you didn't change them, something escaped the sandbox !!!\n", containerName)
+		}
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "  %s\n", violation)
+		}
+		if err := integrity.Audit(integrity.AuditEntry{Session: containerName, Event: "integrity-violation", Violations: violations}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Recorded in %s\n\n", integrity.GetAuditLogPath())
+		}
+	}
+	if err := integrity.Forget(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
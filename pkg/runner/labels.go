@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+)
+
+// sessionLabels merges the project's cost-allocation labels over the config's, so
+// one team's sessions can be told apart from another's in the same cost report
+func sessionLabels(projectPath string, labels map[string]string) (map[string]string, error) {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := container.ValidateExtraLabels(projectConfig.Labels); err != nil {
+		return nil, fmt.Errorf("invalid labels in %s: %w", config.ProjectConfigFile, err)
+	}
+
+	merged := make(map[string]string, len(labels)+len(projectConfig.Labels))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range projectConfig.Labels {
+		merged[k] = v
+	}
+	return merged, nil
+}
@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestSessionLabels(t *testing.T) {
+	project := t.TempDir()
+	labels, err := sessionLabels(project, map[string]string{"cost-center": "ml", "team": "unassigned"})
+	if err != nil || len(labels) != 2 {
+		t.Fatalf("sessionLabels() without a project config = %v, %v", labels, err)
+	}
+
+	os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte("labels:\n  team: payments\n  app: \"{project}\"\n"), 0644)
+	labels, err = sessionLabels(project, map[string]string{"cost-center": "ml", "team": "unassigned"})
+	if err != nil {
+		t.Fatalf("sessionLabels() error = %v", err)
+	}
+	want := map[string]string{"cost-center": "ml", "team": "payments", "app": "{project}"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("sessionLabels() = %v, want %v", labels, want)
+	}
+
+	os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte("labels:\n  packnplay-owner: someone\n"), 0644)
+	if _, err := sessionLabels(project, nil); err == nil {
+		t.Error("sessionLabels() should reject reserved labels in the project config")
+	}
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/obra/packnplay/pkg/mdns"
+)
+
+// lanHostPorts returns the host side of each published port, and the mappings bound to a
+// loopback address, which other devices on the LAN can't reach
+func lanHostPorts(ports []string) ([]string, []string) {
+	var hostPorts, loopback []string
+	for _, port := range ports {
+		mapping, _, _ := strings.Cut(port, "/")
+		parts := strings.Split(mapping, ":")
+		if len(parts) < 2 {
+			continue // a bare container port gets a random host port
+		}
+		if len(parts) == 3 {
+			if ip := net.ParseIP(strings.Trim(parts[0], "[]")); ip != nil && ip.IsLoopback() {
+				loopback = append(loopback, port)
+				continue
+			}
+		}
+		hostPorts = append(hostPorts, parts[len(parts)-2])
+	}
+	return hostPorts, loopback
+}
+
+// startMDNSPublisher publishes the session's .local name on the LAN for as long as it runs,
+// and tells the user where its published ports can be reached
+func startMDNSPublisher(containerName, runtime, projectName, agentName string, ports []string, verbose bool) error {
+	name := mdns.Name(projectName, agentName)
+	hostPorts, loopback := lanHostPorts(ports)
+	for _, port := range loopback {
+		fmt.Fprintf(os.Stderr, "Warning: -p %s is bound to loopback, so it isn't reachable from the LAN\n", port)
+	}
+	if len(hostPorts) == 0 {
+		fmt.Fprintf(os.Stderr, "Warning: --lan publishes %s, but no ports are published; add them with -p\n", name)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	cmd := exec.Command(executable, "mdns-publisher",
+		"--container", containerName,
+		"--runtime", runtime,
+		"--name", name,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start mDNS publisher: %w", err)
+	}
+
+	for _, port := range hostPorts {
+		fmt.Fprintf(os.Stderr, "On the LAN: http://%s:%s\n", name, port)
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Publishing %s until %s stops\n", name, containerName)
+	}
+	return nil
+}
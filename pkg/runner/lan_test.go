@@ -0,0 +1,16 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLANHostPorts(t *testing.T) {
+	hostPorts, loopback := lanHostPorts([]string{"3000:3000", "0.0.0.0:8080:80/tcp", "127.0.0.1:5432:5432", "9000"})
+	if want := []string{"3000", "8080"}; !reflect.DeepEqual(hostPorts, want) {
+		t.Errorf("hostPorts = %v, want %v", hostPorts, want)
+	}
+	if want := []string{"127.0.0.1:5432:5432"}; !reflect.DeepEqual(loopback, want) {
+		t.Errorf("loopback = %v, want %v", loopback, want)
+	}
+}
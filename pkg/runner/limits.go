@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ulimitNames are the resource limits docker and podman accept with --ulimit
+var ulimitNames = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true, "locks": true, "memlock": true,
+	"msgqueue": true, "nice": true, "nofile": true, "nproc": true, "rss": true, "rtprio": true,
+	"rttime": true, "sigpending": true, "stack": true,
+}
+
+// safeSysctls are kernel settings namespaced to the container, so setting them can't
+// affect the host or other containers (Kubernetes' safe set, plus the listen backlog)
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.core.somaxconn":                  true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_local_reserved_ports":    true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.tcp_fin_timeout":            true,
+	"net.ipv4.tcp_keepalive_intvl":        true,
+	"net.ipv4.tcp_keepalive_probes":       true,
+	"net.ipv4.tcp_keepalive_time":         true,
+	"net.ipv4.tcp_syncookies":             true,
+}
+
+// limitsArgs returns the --ulimit and --sysctl args, sorted so the args are stable
+// A ulimit is "soft[:hard]"; a lone value sets both, and -1 is unlimited.
+func limitsArgs(ulimits, sysctls map[string]string) ([]string, error) {
+	var args []string
+	for _, name := range sortedKeys(ulimits) {
+		if !ulimitNames[name] {
+			return nil, fmt.Errorf("unknown ulimit '%s'", name)
+		}
+		value, err := parseUlimit(ulimits[name])
+		if err != nil {
+			return nil, fmt.Errorf("ulimit %s: %w", name, err)
+		}
+		args = append(args, "--ulimit", name+"="+value)
+	}
+	for _, key := range sortedKeys(sysctls) {
+		if !safeSysctls[key] {
+			return nil, fmt.Errorf("sysctl '%s' isn't namespaced to the container, so it can't be set per session", key)
+		}
+		value := sysctls[key]
+		if value == "" || strings.ContainsAny(value, "=\n") {
+			return nil, fmt.Errorf("invalid value '%s' for sysctl %s", value, key)
+		}
+		args = append(args, "--sysctl", key+"="+value)
+	}
+	return args, nil
+}
+
+// parseUlimit checks a "soft[:hard]" limit, returning it as the runtimes want it
+func parseUlimit(value string) (string, error) {
+	softText, hardText, hasHard := strings.Cut(value, ":")
+	if !hasHard {
+		hardText = softText
+	}
+	soft, err := strconv.ParseInt(softText, 10, 64)
+	if err != nil || soft < -1 {
+		return "", fmt.Errorf("'%s' is not a number or -1", softText)
+	}
+	hard, err := strconv.ParseInt(hardText, 10, 64)
+	if err != nil || hard < -1 {
+		return "", fmt.Errorf("'%s' is not a number or -1", hardText)
+	}
+	if hard != -1 && (soft == -1 || soft > hard) {
+		return "", fmt.Errorf("soft limit %s is above the hard limit %s", softText, hardText)
+	}
+	return fmt.Sprintf("%d:%d", soft, hard), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
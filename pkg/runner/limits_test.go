@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimitsArgs(t *testing.T) {
+	args, err := limitsArgs(
+		map[string]string{"nofile": "65536", "nproc": "4096:8192", "core": "-1"},
+		map[string]string{"net.ipv4.ip_local_port_range": "1024 65000", "net.core.somaxconn": "1024"},
+	)
+	if err != nil {
+		t.Fatalf("limitsArgs() error = %v", err)
+	}
+	want := []string{
+		"--ulimit", "core=-1:-1",
+		"--ulimit", "nofile=65536:65536",
+		"--ulimit", "nproc=4096:8192",
+		"--sysctl", "net.core.somaxconn=1024",
+		"--sysctl", "net.ipv4.ip_local_port_range=1024 65000",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("limitsArgs() = %v, want %v", args, want)
+	}
+
+	if args, err := limitsArgs(nil, nil); err != nil || len(args) != 0 {
+		t.Errorf("limitsArgs() with nothing configured = %v, %v", args, err)
+	}
+
+	for name, tt := range map[string]struct {
+		ulimits map[string]string
+		sysctls map[string]string
+	}{
+		"unknown ulimit":        {ulimits: map[string]string{"files": "1024"}},
+		"not a number":          {ulimits: map[string]string{"nofile": "lots"}},
+		"soft above hard":       {ulimits: map[string]string{"nofile": "4096:1024"}},
+		"unlimited soft":        {ulimits: map[string]string{"nofile": "-1:1024"}},
+		"host-wide sysctl":      {sysctls: map[string]string{"vm.max_map_count": "262144"}},
+		"empty sysctl value":    {sysctls: map[string]string{"net.core.somaxconn": ""}},
+		"sysctl value with '='": {sysctls: map[string]string{"net.core.somaxconn": "1=2"}},
+	} {
+		if _, err := limitsArgs(tt.ulimits, tt.sysctls); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/githubapp"
+)
+
+// alwaysHiddenPaths are home-relative credential stores no flag exposes in local mode
+var alwaysHiddenPaths = []string{
+	".aws", ".azure", ".config/gcloud", ".docker", ".kube", ".netrc", ".password-store",
+}
+
+// bwrapSystemPaths are the host directories bubblewrap exposes read-only, so host tools
+// and their libraries, certificates and resolver config work. Everything else, home
+// included, is only there if the policy binds it.
+var bwrapSystemPaths = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt", "/nix", "/run/systemd/resolve",
+}
+
+// localPolicy is the container mount/env model translated to host paths
+type localPolicy struct {
+	workDir  string
+	home     string
+	writable []string // host paths the command may modify
+	readOnly []string // host paths the command may read, e.g. agent files
+	tools    []string // install prefixes of PATH entries outside the system paths, read-only
+	hidden   []string // host paths replaced with empty ones
+	env      []string // the complete environment, KEY=value
+}
+
+// buildLocalPolicy mirrors Run's mounts and env for a sandbox on the host filesystem
+// Only the workspace and agent config dirs are writable. Credential stores not enabled
+// by flags are hidden, even inside directories an agent mount exposes.
+func buildLocalPolicy(config *RunConfig, ws *workspace, homeDir string, getenv func(string) string) localPolicy {
+	policy := localPolicy{workDir: ws.mountPath, home: homeDir}
+	policy.writable = append(policy.writable, ws.mountPath)
+	if ws.mainRepoGitDir != "" {
+		policy.writable = append(policy.writable, ws.mainRepoGitDir)
+	}
+
+	home := func(rel string) string { return filepath.Join(homeDir, rel) }
+
+	for _, rel := range alwaysHiddenPaths {
+		policy.hidden = append(policy.hidden, home(rel))
+	}
+	credentialPaths := []struct {
+		enabled bool
+		rel     string
+	}{
+		{config.Credentials.Git, ".gitconfig"},
+		{config.Credentials.SSH, ".ssh"},
+		{config.Credentials.GH, ".config/gh"},
+		{config.Credentials.GPG, ".gnupg"},
+		{config.Credentials.NPM, ".npmrc"},
+	}
+	for _, cred := range credentialPaths {
+		if cred.enabled {
+			policy.readOnly = append(policy.readOnly, home(cred.rel))
+		} else {
+			policy.hidden = append(policy.hidden, home(cred.rel))
+		}
+	}
+
+	// Agent config is used in place; the container-side paths don't apply on the host
+	// Claude also keeps state in ~/.claude.json, which the container gets via the credential overlay
+	policy.writable = append(policy.writable, home(".claude.json"))
+	var agentEnvKeys []string
+	launched := launchedAgents(sessionAgent(config.Command), "")
+	if config.Agent != "" {
+		launched = launchedAgents(config.Agent, "")
+	}
+	for _, agent := range agents.GetSupportedAgents() {
+		mounts := agents.MountsFor(agent, config.AgentMounts[agent.Name()], homeDir, "root")
+		if !launched[agent.Name()] {
+			mounts = agents.OwnMounts(agent, mounts, homeDir)
+		}
+		for _, mount := range mounts {
+			if mount.ReadOnly {
+				policy.readOnly = append(policy.readOnly, mount.HostPath)
+			} else {
+				policy.writable = append(policy.writable, mount.HostPath)
+			}
+		}
+		if provider, ok := agent.(agents.EnvProvider); ok && launched[agent.Name()] {
+			for _, env := range provider.GetEnv(homeDir, "root") {
+				key, _, _ := strings.Cut(env, "=")
+				agentEnvKeys = append(agentEnvKeys, key)
+			}
+		}
+	}
+
+	policy.tools = toolPrefixes(getenv("PATH"), homeDir)
+
+	// Environment: nothing from the host except what Run would pass, plus PATH since
+	// there's no image PATH to fall back on
+	policy.env = append(policy.env, terminalEnv(os.Environ())...)
+	policy.env = append(policy.env,
+		"HOME="+homeDir,
+		"PATH="+getenv("PATH"),
+		"USER="+getenv("USER"),
+		"IS_SANDBOX=1",
+	)
+	passThrough := append(append([]string{}, config.DefaultEnvVars...), agentEnvKeys...)
+	for _, key := range passThrough {
+		if value := getenv(key); value != "" {
+			policy.env = append(policy.env, key+"="+value)
+		}
+	}
+	for _, env := range config.Env {
+		if strings.Contains(env, "=") {
+			policy.env = append(policy.env, env)
+		} else if value := getenv(env); value != "" {
+			policy.env = append(policy.env, env+"="+value)
+		}
+	}
+	if config.DisableTelemetry {
+		policy.env = append(policy.env, agents.TelemetryOptOutEnv()...)
+	}
+
+	return policy
+}
+
+// toolPrefixes returns what to expose for PATH entries bubblewrap's system paths don't cover,
+// such as ~/.local/bin or ~/.nvm/versions/node/v20/bin. A bin directory's install prefix
+// goes with it, since tools there link into their lib and share directories; a bin
+// directory straight under home is exposed on its own.
+func toolPrefixes(path, homeDir string) []string {
+	var prefixes []string
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(path) {
+		if !filepath.IsAbs(dir) || underAny(dir, bwrapSystemPaths) {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if filepath.Base(dir) == "bin" && filepath.Dir(dir) != homeDir && filepath.Dir(dir) != "/" {
+			dir = filepath.Dir(dir)
+		}
+		if dir == homeDir || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		prefixes = append(prefixes, dir)
+	}
+	return prefixes
+}
+
+// underAny reports whether path is one of dirs or inside one
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// localMount is one path the sandbox exposes, hides or makes writable
+type localMount struct {
+	path string
+	kind string // "ro", "rw" or "hidden"
+}
+
+// localMounts orders the policy's paths so deeper ones are applied last and win: an agent
+// file inside a hidden directory stays reachable, and a credential store inside a directory
+// an agent mount exposes stays hidden
+func localMounts(policy localPolicy) []localMount {
+	var mounts []localMount
+	for _, path := range policy.tools {
+		mounts = append(mounts, localMount{path, "ro"})
+	}
+	for _, path := range policy.hidden {
+		mounts = append(mounts, localMount{path, "hidden"})
+	}
+	for _, path := range policy.readOnly {
+		mounts = append(mounts, localMount{path, "ro"})
+	}
+	for _, path := range policy.writable {
+		mounts = append(mounts, localMount{path, "rw"})
+	}
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return strings.Count(filepath.Clean(mounts[i].path), "/") < strings.Count(filepath.Clean(mounts[j].path), "/")
+	})
+	return mounts
+}
+
+// bwrapArgs builds a bubblewrap invocation (Linux user namespaces) for the policy
+// The sandbox starts empty: the system paths are bound read-only and home is a fresh
+// tmpfs, then the policy's paths are bound on top.
+func bwrapArgs(policy localPolicy, command []string, exists func(string) (bool, bool)) []string {
+	var args []string
+	for _, path := range bwrapSystemPaths {
+		if ok, _ := exists(path); ok {
+			args = append(args, "--ro-bind", path, path)
+		}
+	}
+	args = append(args,
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	)
+	if policy.home != "" {
+		args = append(args, "--tmpfs", policy.home)
+	}
+	args = append(args,
+		"--unshare-all", "--share-net",
+		"--die-with-parent",
+	)
+	for _, mount := range localMounts(policy) {
+		ok, isDir := exists(mount.path)
+		if !ok {
+			continue
+		}
+		switch {
+		case mount.kind == "rw":
+			args = append(args, "--bind", mount.path, mount.path)
+		case mount.kind == "ro":
+			args = append(args, "--ro-bind", mount.path, mount.path)
+		case isDir:
+			args = append(args, "--tmpfs", mount.path)
+		default:
+			args = append(args, "--ro-bind", "/dev/null", mount.path)
+		}
+	}
+
+	args = append(args, "--clearenv")
+	for _, env := range policy.env {
+		key, value, _ := strings.Cut(env, "=")
+		args = append(args, "--setenv", key, value)
+	}
+	args = append(args, "--chdir", policy.workDir, "--")
+	return append(args, command...)
+}
+
+// seatbeltProfile builds a macOS sandbox-exec profile for the policy
+// SBPL applies the last matching rule, so rules for deeper paths follow the ones they
+// carve out of.
+func seatbeltProfile(policy localPolicy) string {
+	var b strings.Builder
+	b.WriteString("(version 1)\n(allow default)\n")
+	b.WriteString("(deny file-write*)\n")
+	b.WriteString("(allow file-write* (subpath \"/private/tmp\") (subpath \"/private/var/folders\") (literal \"/dev/null\") (regex #\"^/dev/tty\"))\n")
+	for _, mount := range localMounts(policy) {
+		switch mount.kind {
+		case "hidden":
+			fmt.Fprintf(&b, "(deny file-read* file-write* (subpath %q))\n", mount.path)
+		case "ro":
+			fmt.Fprintf(&b, "(allow file-read* (subpath %q))\n", mount.path)
+		case "rw":
+			fmt.Fprintf(&b, "(allow file-read* file-write* (subpath %q))\n", mount.path)
+		}
+	}
+	return b.String()
+}
+
+// RunLocal runs the command in an OS-level sandbox instead of a container
+// It's a degraded mode for machines where no container runtime is permitted: the
+// host's tools are used as-is and the network is shared with the host.
+func RunLocal(config *RunConfig) error {
+	if len(config.BlockedDomains) > 0 {
+		return fmt.Errorf("network policy blocklists can't be enforced without a container; remove them from your config or use a container runtime")
+	}
+	if config.NetworkWindow != nil {
+		return fmt.Errorf("network windows are enforced inside the container's network namespace, so they need a container; use a container runtime")
+	}
+	if len(config.ExtraHosts) > 0 {
+		// Silently resolving staging names to their public addresses could point tests at the wrong service
+		return fmt.Errorf("extra_hosts entries need the container's own /etc/hosts; use a container runtime")
+	}
+	if config.Hostname != "" && config.Verbose {
+		fmt.Fprintf(os.Stderr, "Note: hostname has no effect without a container\n")
+	}
+	if len(config.Policies) > 0 {
+		return fmt.Errorf("container policies can't be evaluated without a container; use a container runtime")
+	}
+	if len(config.Burner) > 0 {
+		return fmt.Errorf("burner credentials are revoked when the container stops, so they need a container; use a container runtime")
+	}
+	if githubapp.Enabled(config.GitHubApp) {
+		return fmt.Errorf("GitHub App tokens are revoked when the container stops, so they need a container; use a container runtime")
+	}
+	if len(config.MountHooks) > 0 {
+		return fmt.Errorf("mount hooks can't sanitize files agents read straight from the host; use a container runtime")
+	}
+	if config.WorkspaceMode == WorkspaceSync || config.WorkspaceMode == WorkspaceSplit {
+		return fmt.Errorf("workspace_mode %s keeps the agent's edits in a container volume; use a container runtime", config.WorkspaceMode)
+	}
+	if config.WorkspaceMode == WorkspacePrompt {
+		return fmt.Errorf("workspace_mode prompt guards the workspace mount; use a container runtime")
+	}
+	if config.Display.Mode != "" {
+		return fmt.Errorf("display mode %s needs a container; use a container runtime", config.Display.Mode)
+	}
+	if config.DevCerts.Enabled && config.Verbose {
+		fmt.Fprintf(os.Stderr, "Note: dev_certs has no effect without a container; use mkcert's certificates directly\n")
+	}
+	if len(config.Ulimits) > 0 || len(config.Sysctls) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: ulimits and sysctls are only applied in containers; this session gets the host's\n")
+	}
+	if config.Record {
+		fmt.Fprintf(os.Stderr, "Warning: session recording is only supported in containers; this session won't be recorded\n")
+	}
+	if len(config.PublishPorts) > 0 && config.Verbose {
+		fmt.Fprintf(os.Stderr, "Note: --publish has no effect without a container; the host network is shared\n")
+	}
+
+	ws, err := resolveWorkspace(config)
+	if err != nil {
+		return err
+	}
+	if err := checkAgentRules(ws.mountPath, config); err != nil {
+		return err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	policy := buildLocalPolicy(config, ws, homeDir, os.Getenv)
+
+	var argv []string
+	switch runtime.GOOS {
+	case "linux":
+		argv = append([]string{"bwrap"}, bwrapArgs(policy, config.Command, pathExists)...)
+	case "darwin":
+		argv = append([]string{"sandbox-exec", "-p", seatbeltProfile(policy)}, config.Command...)
+	default:
+		return fmt.Errorf("--no-container is not supported on %s", runtime.GOOS)
+	}
+
+	if _, err := exec.LookPath(argv[0]); err != nil {
+		if argv[0] == "bwrap" {
+			return fmt.Errorf("--no-container needs bubblewrap (bwrap); install it with your package manager")
+		}
+		return fmt.Errorf("failed to find %s: %w", argv[0], err)
+	}
+	executor := docker.DefaultExecutor("")
+
+	if config.Verbose {
+		fmt.Fprintf(os.Stderr, "Sandboxing %s with %s in %s\n", strings.Join(config.Command, " "), argv[0], policy.workDir)
+	}
+
+	if runtime.GOOS == "darwin" {
+		// sandbox-exec has no --chdir or --clearenv
+		if err := os.Chdir(policy.workDir); err != nil {
+			return fmt.Errorf("failed to enter %s: %w", policy.workDir, err)
+		}
+		return executor.Handoff(argv[0], argv[1:], policy.env)
+	}
+	return executor.Handoff(argv[0], argv[1:], os.Environ())
+}
+
+// pathExists reports whether path exists and whether it's a directory
+func pathExists(path string) (bool, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, false
+	}
+	return true, info.IsDir()
+}
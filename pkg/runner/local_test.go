@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func containsString(list []string, want string) bool {
+	for _, item := range list {
+		if item == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBuildLocalPolicy(t *testing.T) {
+	// Agent env providers read the real environment
+	t.Setenv("AWS_PROFILE", "work")
+
+	env := map[string]string{
+		"PATH":              "/usr/bin",
+		"USER":              "dev",
+		"ANTHROPIC_API_KEY": "sk-test",
+		"AWS_PROFILE":       "work",
+		"SECRET_TOKEN":      "leak",
+		"EXTRA":             "passed",
+	}
+	cfg := &RunConfig{
+		Credentials:    config.Credentials{SSH: true},
+		DefaultEnvVars: []string{"ANTHROPIC_API_KEY"},
+		Env:            []string{"EXTRA", "MODE=fast"},
+	}
+	ws := &workspace{mountPath: "/src/app-wt", mainRepoGitDir: "/src/app/.git"}
+
+	policy := buildLocalPolicy(cfg, ws, "/home/dev", func(key string) string { return env[key] })
+
+	if policy.workDir != "/src/app-wt" {
+		t.Errorf("workDir = %s, want /src/app-wt", policy.workDir)
+	}
+	for _, want := range []string{"/src/app-wt", "/src/app/.git", "/home/dev/.claude", "/home/dev/.codex"} {
+		if !containsString(policy.writable, want) {
+			t.Errorf("writable missing %s: %v", want, policy.writable)
+		}
+	}
+	if !containsString(policy.readOnly, "/home/dev/.roo") {
+		t.Errorf("readOnly missing agent read-only mount: %v", policy.readOnly)
+	}
+	// Shared cloud credentials only go to the agent that uses them
+	if containsString(policy.readOnly, "/home/dev/.aws/config") {
+		t.Errorf("readOnly has Amazon Q's AWS config without running it: %v", policy.readOnly)
+	}
+	if !containsString(policy.hidden, "/home/dev/.gnupg") || !containsString(policy.hidden, "/home/dev/.aws") {
+		t.Errorf("hidden missing disabled credentials: %v", policy.hidden)
+	}
+	if containsString(policy.hidden, "/home/dev/.ssh") || !containsString(policy.readOnly, "/home/dev/.ssh") {
+		t.Errorf("SSH credentials are enabled but not exposed read-only: hidden %v, readOnly %v", policy.hidden, policy.readOnly)
+	}
+
+	for _, want := range []string{"HOME=/home/dev", "PATH=/usr/bin", "IS_SANDBOX=1", "ANTHROPIC_API_KEY=sk-test", "EXTRA=passed", "MODE=fast"} {
+		if !containsString(policy.env, want) {
+			t.Errorf("env missing %s: %v", want, policy.env)
+		}
+	}
+	for _, env := range policy.env {
+		if strings.HasPrefix(env, "SECRET_TOKEN=") || strings.HasPrefix(env, "AWS_PROFILE=") {
+			t.Errorf("env leaked host variable: %s", env)
+		}
+	}
+
+	cfg.Agent = "amazonq"
+	policy = buildLocalPolicy(cfg, ws, "/home/dev", func(key string) string { return env[key] })
+	if !containsString(policy.readOnly, "/home/dev/.aws/config") || !containsString(policy.env, "AWS_PROFILE=work") {
+		t.Errorf("amazonq session is missing its AWS config: readOnly %v, env %v", policy.readOnly, policy.env)
+	}
+}
+
+func TestBuildLocalPolicyDisablesTelemetry(t *testing.T) {
+	cfg := &RunConfig{Env: []string{"DISABLE_TELEMETRY=0"}, DisableTelemetry: true}
+	policy := buildLocalPolicy(cfg, &workspace{mountPath: "/src/app"}, "/home/dev", func(string) string { return "" })
+
+	// Later entries win, so the opt-out must follow the user's --env
+	last := map[string]string{}
+	for _, env := range policy.env {
+		key, value, _ := strings.Cut(env, "=")
+		last[key] = value
+	}
+	if last["DISABLE_TELEMETRY"] != "1" || last["DO_NOT_TRACK"] != "1" {
+		t.Errorf("env = %v, want telemetry opt-outs overriding --env", policy.env)
+	}
+}
+
+func TestBwrapArgs(t *testing.T) {
+	policy := localPolicy{
+		workDir:  "/src/app",
+		home:     "/home/dev",
+		writable: []string{"/src/app", "/missing", "/home/dev/.config"},
+		readOnly: []string{"/home/dev/.aws/config"},
+		tools:    []string{"/home/dev/.local"},
+		hidden:   []string{"/home/dev/.aws", "/home/dev/.npmrc", "/home/dev/.config/gcloud"},
+		env:      []string{"HOME=/home/dev"},
+	}
+	exists := func(path string) (bool, bool) {
+		switch path {
+		case "/missing", "/nix", "/lib32":
+			return false, false
+		case "/home/dev/.npmrc", "/home/dev/.aws/config":
+			return true, false
+		}
+		return true, true
+	}
+
+	args := strings.Join(bwrapArgs(policy, []string{"claude", "--resume"}, exists), " ")
+
+	for _, want := range []string{
+		"--ro-bind /usr /usr",
+		"--ro-bind /etc /etc",
+		"--tmpfs /tmp --tmpfs /home/dev --unshare-all",
+		"--tmpfs /home/dev/.aws --ro-bind /dev/null /home/dev/.npmrc",
+		"--ro-bind /home/dev/.aws/config /home/dev/.aws/config",
+		"--ro-bind /home/dev/.local /home/dev/.local",
+		"--bind /src/app /src/app",
+		"--clearenv --setenv HOME /home/dev --chdir /src/app -- claude --resume",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("bwrap args missing %q:\n%s", want, args)
+		}
+	}
+	if strings.Contains(args, "--ro-bind / /") {
+		t.Errorf("bwrap args expose the whole host filesystem:\n%s", args)
+	}
+	for _, absent := range []string{"/missing", "/nix"} {
+		if strings.Contains(args, absent) {
+			t.Errorf("bwrap args bind %s, which doesn't exist:\n%s", absent, args)
+		}
+	}
+	// Deeper paths are applied last, so an agent mount can't uncover a hidden store
+	if hide, expose := strings.Index(args, "--tmpfs /home/dev/.config/gcloud"), strings.Index(args, "--bind /home/dev/.config /home/dev/.config"); hide < expose {
+		t.Errorf("bwrap args bind ~/.config over the hidden gcloud directory:\n%s", args)
+	}
+	if hide, expose := strings.Index(args, "--tmpfs /home/dev/.aws"), strings.Index(args, "--ro-bind /home/dev/.aws/config"); expose < hide {
+		t.Errorf("bwrap args hide the agent's file after exposing it:\n%s", args)
+	}
+}
+
+func TestToolPrefixes(t *testing.T) {
+	path := "/home/dev/.local/bin:/usr/local/bin:/home/dev/.nvm/versions/node/v20/bin:/home/dev/bin:relative:/home/dev/go/bin:/home/dev/.local/bin"
+	want := []string{"/home/dev/.local", "/home/dev/.nvm/versions/node/v20", "/home/dev/bin", "/home/dev/go"}
+	if got := toolPrefixes(path, "/home/dev"); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("toolPrefixes() = %v, want %v", got, want)
+	}
+}
+
+func TestSeatbeltProfile(t *testing.T) {
+	policy := localPolicy{
+		writable: []string{"/Users/dev/src/app", "/Users/dev/.config"},
+		readOnly: []string{"/Users/dev/.aws/config"},
+		hidden:   []string{"/Users/dev/.aws", "/Users/dev/.config/gh"},
+	}
+
+	profile := seatbeltProfile(policy)
+
+	deny := strings.Index(profile, `(deny file-read* file-write* (subpath "/Users/dev/.aws"))`)
+	allow := strings.Index(profile, `(allow file-read* (subpath "/Users/dev/.aws/config"))`)
+	if deny == -1 || allow == -1 || allow < deny {
+		t.Errorf("profile must deny hidden paths before re-allowing agent files:\n%s", profile)
+	}
+	exposed := strings.Index(profile, `(allow file-read* file-write* (subpath "/Users/dev/.config"))`)
+	hidden := strings.Index(profile, `(deny file-read* file-write* (subpath "/Users/dev/.config/gh"))`)
+	if exposed == -1 || hidden == -1 || hidden < exposed {
+		t.Errorf("profile must deny hidden paths after allowing the directories they're in:\n%s", profile)
+	}
+	if !strings.Contains(profile, `(allow file-read* file-write* (subpath "/Users/dev/src/app"))`) {
+		t.Errorf("profile missing writable workspace:\n%s", profile)
+	}
+}
+
+func TestBuildLocalPolicyScopesGoogleCredentials(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/home/dev/keys/sa.json")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "acme")
+	ws := &workspace{mountPath: "/src/app"}
+	getenv := os.Getenv
+
+	policy := buildLocalPolicy(&RunConfig{Command: []string{"claude"}}, ws, "/home/dev", getenv)
+	for _, path := range append(policy.readOnly, policy.writable...) {
+		if strings.Contains(path, "gcloud") || path == "/home/dev/keys/sa.json" {
+			t.Errorf("claude session gets Google credentials %s", path)
+		}
+	}
+	for _, env := range policy.env {
+		if strings.HasPrefix(env, "GOOGLE_") {
+			t.Errorf("claude session gets %s", env)
+		}
+	}
+
+	policy = buildLocalPolicy(&RunConfig{Command: []string{"gemini"}}, ws, "/home/dev", getenv)
+	if !containsString(policy.readOnly, "/home/dev/keys/sa.json") || !containsString(policy.env, "GOOGLE_CLOUD_PROJECT=acme") {
+		t.Errorf("gemini session is missing its Google credentials: readOnly %v, env %v", policy.readOnly, policy.env)
+	}
+}
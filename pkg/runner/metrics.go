@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/metrics"
+)
+
+// sessionAgent names the agent a command starts, or the command itself for non-agent sessions
+func sessionAgent(command []string) string {
+	if len(command) == 0 {
+		return ""
+	}
+	binary := filepath.Base(command[0])
+	if agent := agents.Lookup(binary); agent != nil {
+		return agent.Name()
+	}
+	return binary
+}
+
+// recordEvent logs a metrics event without ever failing the run
+func recordEvent(event metrics.Event) {
+	if err := metrics.Record(event); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record metrics: %v\n", err)
+	}
+}
@@ -0,0 +1,21 @@
+package runner
+
+import "testing"
+
+func TestSessionAgent(t *testing.T) {
+	tests := []struct {
+		command []string
+		want    string
+	}{
+		{[]string{"claude", "--resume"}, "claude"},
+		{[]string{"/usr/local/bin/cursor-agent"}, "cursor"},
+		{[]string{"bash"}, "bash"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := sessionAgent(tt.command); got != tt.want {
+			t.Errorf("sessionAgent(%v) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
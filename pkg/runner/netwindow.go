@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/netpolicy"
+)
+
+// windowAllowLabel records the domains a container keeps once its network window closes
+const windowAllowLabel = "packnplay.network-window-allow"
+
+// windowLabels returns the labels that let later runs enforce a window started now
+func windowLabels(window *netpolicy.Window, now time.Time) map[string]string {
+	return map[string]string{
+		netpolicy.WindowLabel: now.Add(window.OpenFor).UTC().Format(time.RFC3339),
+		windowAllowLabel:      strings.Join(window.Allow, ","),
+	}
+}
+
+// CutOffNetwork closes a container's network window: only HTTPS to the allowed domains
+// keeps working. The rules are applied from a privileged exec, so the session itself never
+// holds NET_ADMIN and can't undo them. If they can't be applied (no iptables in the image),
+// the container is disconnected from every network instead. It returns what was done, and
+// an error only if the network may still be open.
+func CutOffNetwork(dockerClient *docker.Client, containerName string, allow []string) (string, error) {
+	resolved, err := netpolicy.ResolveAllowed(allow, net.LookupIP)
+	if err == nil {
+		output, execErr := dockerClient.Run("exec", "--privileged", "-u", "root", containerName, "sh", "-c", netpolicy.CutoffScript(resolved))
+		if execErr == nil {
+			return "network limited to " + strings.Join(allow, ", "), nil
+		}
+		err = fmt.Errorf("%w\n%s", execErr, strings.TrimSpace(output))
+	}
+
+	if disconnectErr := disconnectAllNetworks(dockerClient, containerName); disconnectErr != nil {
+		return "", fmt.Errorf("failed to cut off network for %s: %v; %w", containerName, err, disconnectErr)
+	}
+	return fmt.Sprintf("couldn't apply network rules (%v); disconnected from all networks instead", err), nil
+}
+
+// disconnectAllNetworks detaches a container from each network it's attached to
+func disconnectAllNetworks(dockerClient *docker.Client, containerName string) error {
+	output, err := dockerClient.Run("inspect", "--format", "{{range $name, $_ := .NetworkSettings.Networks}}{{$name}} {{end}}", containerName)
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, network := range strings.Fields(output) {
+		if output, err := dockerClient.Run("network", "disconnect", "-f", network, containerName); err != nil {
+			return fmt.Errorf("failed to disconnect from %s: %w\n%s", network, err, output)
+		}
+	}
+	return nil
+}
+
+// enforceExpiredWindow cuts off a container whose window closed while nothing was watching
+// it, e.g. because the window process was killed. Containers without a window are left alone.
+func enforceExpiredWindow(dockerClient *docker.Client, containerName string) error {
+	output, err := dockerClient.Run("inspect", "--format",
+		fmt.Sprintf(`{{index .Config.Labels %q}}|{{index .Config.Labels %q}}`, netpolicy.WindowLabel, windowAllowLabel), containerName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", containerName, err)
+	}
+	until, allow, _ := strings.Cut(strings.TrimSpace(output), "|")
+	deadline, err := time.Parse(time.RFC3339, until)
+	if err != nil || time.Now().Before(deadline) {
+		return nil
+	}
+
+	outcome, err := CutOffNetwork(dockerClient, containerName, strings.Split(allow, ","))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Network window for %s has closed: %s\n", containerName, outcome)
+	return nil
+}
+
+// startNetworkWindow launches a detached process that cuts off the container's network
+// when its window closes
+func startNetworkWindow(containerName, runtime string, window *netpolicy.Window, until time.Time, verbose bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(executable, "network-window",
+		"--container", containerName,
+		"--runtime", runtime,
+		"--until", until.UTC().Format(time.RFC3339),
+		"--allow", strings.Join(window.Allow, ","),
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start network window: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Network is open until %s, then limited to %s\n", until.Local().Format(time.Kitchen), strings.Join(window.Allow, ", "))
+	}
+	return nil
+}
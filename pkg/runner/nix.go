@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/imagebuild"
+	"github.com/obra/packnplay/pkg/nixenv"
+)
+
+// projectNixKind returns the kind of Nix dev environment the project's commands run in,
+// detected from flake.nix or devenv.nix unless its config turns that off
+func projectNixKind(dockerClient *docker.Client, projectPath string) (string, error) {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return "", err
+	}
+	switch projectConfig.Nix {
+	case "":
+	case nixenv.Off:
+		return "", nil
+	default:
+		return "", fmt.Errorf("invalid nix '%s' in %s (use %s, or leave it out to detect flake.nix and devenv.nix)", projectConfig.Nix, config.ProjectConfigFile, nixenv.Off)
+	}
+
+	kind := nixenv.Detect(projectPath)
+	if kind != "" && dockerClient.Command() == "container" {
+		fmt.Fprintf(os.Stderr, "Warning: Nix dev environments are not supported with Apple Container; running commands as-is\n")
+		return "", nil
+	}
+	return kind, nil
+}
+
+// ensureNixImage layers Nix (and devenv for devenv projects) onto baseImage, reusing the
+// layer until the base image changes. It also returns the args that mount the project's store
+// volume for that layer, so dev shells are only built once. Projects don't share a store,
+// since any session can write to it and every later session runs what's in it.
+func ensureNixImage(dockerClient *docker.Client, baseImage, user, projectName, workDir, kind, containerHome string, meta container.Metadata, verbose bool) (string, []string, error) {
+	baseID, err := dockerClient.Run("image", "inspect", "--format", "{{.Id}}", baseImage)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to inspect image %s: %w", baseImage, err)
+	}
+	key := imagebuild.NixKey(strings.TrimSpace(baseID), user, kind)
+
+	// An empty volume starts as a copy of the layer's /nix, so the store stays consistent
+	// with the Nix it was installed by
+	storeArgs, _, err := cacheMountArgs(dockerClient, map[string]config.CacheVolume{projectCacheName("nix-"+key, workDir): {Target: nixenv.StorePath}}, containerHome, meta)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if tag := imagebuild.FindNixImage(dockerClient, projectName, key); tag != "" {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Reusing Nix layer %s\n", tag)
+		}
+		return tag, storeArgs, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Installing Nix for the project's %s (cached until the image changes)...\n", nixFile(kind))
+	started := time.Now()
+	tag, err := imagebuild.BuildNixImage(dockerClient, baseImage, user, projectName, key, kind)
+	if err != nil {
+		return "", nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Built Nix layer %s in %s\n", tag, time.Since(started).Round(time.Second))
+	}
+
+	if removed := imagebuild.PruneNixImages(dockerClient, projectName, key); removed > 0 && verbose {
+		fmt.Fprintf(os.Stderr, "Removed %d outdated Nix layers\n", removed)
+	}
+	return tag, storeArgs, nil
+}
+
+// nixFile names the file that defines a kind of dev environment
+func nixFile(kind string) string {
+	if kind == nixenv.Devenv {
+		return "devenv.nix"
+	}
+	return "flake.nix"
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/imagebuild"
+)
+
+// ensurePackagesImage layers the packages declared in the project's .packnplay.yaml onto
+// baseImage, reusing the layer until the base image or the package list changes.
+// It returns baseImage unchanged when the project declares no packages.
+func ensurePackagesImage(dockerClient *docker.Client, baseImage, user, projectName, projectPath string, verbose bool) (string, error) {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return "", err
+	}
+	pkgs := projectConfig.Packages
+	if pkgs.Empty() {
+		return baseImage, nil
+	}
+	if err := imagebuild.ValidatePackages(pkgs); err != nil {
+		return "", err
+	}
+	if dockerClient.Command() == "container" {
+		fmt.Fprintf(os.Stderr, "Warning: %s packages are not supported with Apple Container; skipping\n", config.ProjectConfigFile)
+		return baseImage, nil
+	}
+
+	baseID, err := dockerClient.Run("image", "inspect", "--format", "{{.Id}}", baseImage)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", baseImage, err)
+	}
+	key := imagebuild.PackagesKey(strings.TrimSpace(baseID), user, pkgs)
+
+	if tag := imagebuild.FindPackagesImage(dockerClient, projectName, key); tag != "" {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Reusing packages layer %s\n", tag)
+		}
+		return tag, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Installing packages from %s (cached until they change)...\n", config.ProjectConfigFile)
+	started := time.Now()
+	tag, err := imagebuild.BuildPackagesImage(dockerClient, baseImage, user, projectName, key, pkgs)
+	if err != nil {
+		return "", err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Built packages layer %s in %s\n", tag, time.Since(started).Round(time.Second))
+	}
+
+	if removed := imagebuild.PrunePackagesImages(dockerClient, projectName, key); removed > 0 && verbose {
+		fmt.Fprintf(os.Stderr, "Removed %d outdated packages layers\n", removed)
+	}
+	return tag, nil
+}
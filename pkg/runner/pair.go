@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/nixenv"
+	"github.com/obra/packnplay/pkg/sessionenv"
+)
+
+// execPair runs the session's agent and its pair side by side in host tmux panes, each
+// a docker exec into the one container. Each pane's agent isn't given the variables only
+// the other agent uses, but both run as one user with both config dirs mounted, so they
+// aren't isolated from each other. Detaching from tmux leaves both running; running the
+// same 'packnplay pair' again returns to them.
+func execPair(dockerClient *docker.Client, config *RunConfig, containerID, containerName, workingDir, nixKind string) error {
+	tmuxPath, err := exec.LookPath("tmux")
+	if err != nil {
+		return fmt.Errorf("pairing agents needs tmux on the host: %w", err)
+	}
+	cmdPath, err := exec.LookPath(dockerClient.Command())
+	if err != nil {
+		return fmt.Errorf("failed to find docker command: %w", err)
+	}
+	if config.Record {
+		fmt.Fprintf(os.Stderr, "Warning: paired sessions aren't recorded\n")
+	}
+
+	executor := dockerClient.Executor()
+	session := pairSessionName(containerName)
+	inTmux := os.Getenv("TMUX") != ""
+	if _, err := executor.Exec(tmuxPath, []string{"has-session", "-t", "=" + session}); err == nil {
+		attach := "attach-session"
+		if inTmux {
+			attach = "switch-client"
+		}
+		return executor.Handoff(tmuxPath, []string{attach, "-t", "=" + session}, os.Environ())
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: paired agents run as one user and can read each other's credentials\n")
+	homeDir, _ := os.UserHomeDir()
+	own, partner := agents.Lookup(config.Agent), agents.Lookup(config.PairAgent)
+	if own == nil || partner == nil {
+		return fmt.Errorf("pairing needs two agents, got '%s' and '%s'", config.Agent, config.PairAgent)
+	}
+	left := pairPane(cmdPath, containerID, workingDir, nixKind, config.Command, partnerOnlyEnv(own, partner, homeDir))
+	right := pairPane(cmdPath, containerID, workingDir, nixKind, config.PairCommand, partnerOnlyEnv(partner, own, homeDir))
+
+	argv := pairTmuxArgs(inTmux, session, own.Name(), partner.Name(), left, right)
+	return executor.Handoff(tmuxPath, argv[1:], os.Environ())
+}
+
+// pairSessionName is the tmux session for a container; tmux reserves . and : in names
+func pairSessionName(containerName string) string {
+	return strings.NewReplacer(".", "-", ":", "-").Replace(containerName)
+}
+
+// pairPane returns the shell command a pane runs: the agent's command in the container,
+// without the variables in hidden. Terminal variables are passed by name, so each pane
+// sends the values tmux gives it rather than those of the terminal pair was started in.
+func pairPane(cmdPath, containerID, workingDir, nixKind string, command, hidden []string) string {
+	argv := []string{cmdPath, "exec", "-it", "-w", workingDir}
+	for _, env := range terminalEnv(os.Environ()) {
+		name, _, _ := strings.Cut(env, "=")
+		argv = append(argv, "-e", name)
+	}
+	argv = append(argv, containerID)
+
+	if len(hidden) > 0 {
+		scoped := []string{"env"}
+		for _, key := range hidden {
+			scoped = append(scoped, "-u", key)
+		}
+		command = append(scoped, command...)
+	}
+	return shellJoin(append(argv, sessionenv.Wrap(nixenv.Wrap(nixKind, command))...))
+}
+
+// partnerOnlyEnv returns the variables partner's agent is given that own's doesn't use,
+// such as its API key, so neither agent picks up the other's settings by accident. Both run
+// as the same user in one container, so this doesn't keep credentials from either.
+func partnerOnlyEnv(own, partner agents.Agent, homeDir string) []string {
+	ownKeys := agentEnvKeys(own, homeDir)
+	var hidden []string
+	for key := range agentEnvKeys(partner, homeDir) {
+		if !ownKeys[key] {
+			hidden = append(hidden, key)
+		}
+	}
+	sort.Strings(hidden)
+	return hidden
+}
+
+// agentEnvKeys returns the names of the variables packnplay passes for an agent
+func agentEnvKeys(agent agents.Agent, homeDir string) map[string]bool {
+	keys := map[string]bool{agent.DefaultAPIKeyEnv(): true}
+	if provider, ok := agent.(agents.EnvProvider); ok {
+		for _, env := range provider.GetEnv(homeDir, "") {
+			key, _, _ := strings.Cut(env, "=")
+			keys[key] = true
+		}
+	}
+	delete(keys, "")
+	return keys
+}
+
+// pairTmuxArgs lays the two panes out side by side, titled with their agents, in a new
+// tmux session, or in a new window when pair is run from inside tmux
+func pairTmuxArgs(inTmux bool, session, leftName, rightName, left, right string) []string {
+	argv := []string{"tmux"}
+	if inTmux {
+		argv = append(argv, "new-window", "-n", session, left)
+	} else {
+		argv = append(argv, "new-session", "-s", session, "-n", "pair", left)
+	}
+	return append(argv,
+		";", "select-pane", "-T", leftName,
+		";", "split-window", "-h", right,
+		";", "select-pane", "-T", rightName,
+		";", "set-option", "-w", "pane-border-status", "top",
+		";", "select-layout", "even-horizontal",
+	)
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/agents"
+)
+
+func TestPartnerOnlyEnv(t *testing.T) {
+	claude, codex, crush := agents.Lookup("claude"), agents.Lookup("codex"), agents.Lookup("crush")
+
+	hidden := partnerOnlyEnv(claude, codex, t.TempDir())
+	if !slices.Contains(hidden, "OPENAI_API_KEY") || slices.Contains(hidden, "ANTHROPIC_API_KEY") {
+		t.Errorf("claude's pane hides %v; want codex's key hidden and claude's kept", hidden)
+	}
+
+	// Agents using the same key both keep it
+	if hidden := partnerOnlyEnv(claude, crush, t.TempDir()); slices.Contains(hidden, "ANTHROPIC_API_KEY") {
+		t.Errorf("claude's pane hides its own key when paired with crush: %v", hidden)
+	}
+}
+
+func TestPairPane(t *testing.T) {
+	pane := pairPane("/usr/bin/docker", "abc123", "/workspace", "", []string{"codex"}, []string{"ANTHROPIC_API_KEY"})
+	if !strings.HasPrefix(pane, "/usr/bin/docker exec -it -w /workspace ") {
+		t.Errorf("pane = %s", pane)
+	}
+	// Session env changes are applied first, so they can't put the hidden key back
+	if !strings.HasSuffix(pane, " sh env -u ANTHROPIC_API_KEY codex") {
+		t.Errorf("pane = %s", pane)
+	}
+
+	if pane := pairPane("docker", "abc123", "/workspace", "", []string{"claude"}, nil); strings.Contains(pane, "env -u") {
+		t.Errorf("pane with nothing hidden = %s", pane)
+	}
+}
+
+func TestPairTmuxArgs(t *testing.T) {
+	got := pairTmuxArgs(false, "packnplay-app-main", "claude", "codex", "left", "right")
+	want := []string{
+		"tmux", "new-session", "-s", "packnplay-app-main", "-n", "pair", "left",
+		";", "select-pane", "-T", "claude",
+		";", "split-window", "-h", "right",
+		";", "select-pane", "-T", "codex",
+		";", "set-option", "-w", "pane-border-status", "top",
+		";", "select-layout", "even-horizontal",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pairTmuxArgs() = %v", got)
+	}
+
+	if got := pairTmuxArgs(true, "packnplay-app-main", "claude", "codex", "left", "right"); got[1] != "new-window" {
+		t.Errorf("inside tmux, pairTmuxArgs() = %v; want a new window", got)
+	}
+	if got := pairSessionName("packnplay-app-v1.2"); got != "packnplay-app-v1-2" {
+		t.Errorf("pairSessionName() = %s", got)
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/burner"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/netpolicy"
+	"github.com/obra/packnplay/pkg/session"
+)
+
+// checkpointName is the checkpoint packnplay creates and restores
+const checkpointName = "packnplay"
+
+// containerState returns a container's status: running, paused, exited, ...
+func containerState(dockerClient *docker.Client, name string) (string, error) {
+	output, err := dockerClient.Run("inspect", "--format", "{{.State.Status}}", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w", name, err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// checkpointArgs returns the runtime's commands to checkpoint and restore a container
+func checkpointArgs(runtime, name string) (create, restore []string, err error) {
+	switch runtime {
+	case "docker":
+		return []string{"checkpoint", "create", name, checkpointName}, []string{"start", "--checkpoint", checkpointName, name}, nil
+	case "podman":
+		return []string{"container", "checkpoint", name}, []string{"container", "restore", name}, nil
+	}
+	return nil, nil, fmt.Errorf("%s can't checkpoint containers", runtime)
+}
+
+// Pause freezes a session's processes so they stop using CPU; memory stays allocated
+// (the kernel can swap it out). With checkpoint, CRIU writes the processes to disk
+// and stops the container instead, freeing its memory too.
+func Pause(dockerClient *docker.Client, name string, checkpoint bool) error {
+	if dockerClient.Command() == "container" {
+		return fmt.Errorf("Apple Container can't pause containers")
+	}
+	state, err := containerState(dockerClient, name)
+	if err != nil {
+		return err
+	}
+	if state != "running" {
+		return fmt.Errorf("%s is %s, not running", name, state)
+	}
+
+	if !checkpoint {
+		if output, err := dockerClient.Run("pause", name); err != nil {
+			return fmt.Errorf("failed to pause %s: %w\n%s", name, err, output)
+		}
+		return nil
+	}
+
+	// The window's watcher stops with the container, so nothing would close it after a restore
+	output, err := dockerClient.Run("inspect", "--format", fmt.Sprintf("{{index .Config.Labels %q}}", netpolicy.WindowLabel), name)
+	if err == nil && strings.TrimSpace(output) != "" {
+		return fmt.Errorf("%s has a network window; pause it without --checkpoint", name)
+	}
+
+	if records, err := burner.Load(name); err == nil && len(records) > 0 {
+		return fmt.Errorf("%s has burner keys, which are revoked once it stops; pause it without --checkpoint", name)
+	}
+
+	create, _, err := checkpointArgs(dockerClient.Command(), name)
+	if err != nil {
+		return err
+	}
+	if output, err := dockerClient.Run(create...); err != nil {
+		return fmt.Errorf("failed to checkpoint %s (needs CRIU, and experimental mode for docker): %w\n%s", name, err, output)
+	}
+	return session.SetCheckpointed(name, true)
+}
+
+// Resume continues a paused or checkpointed session, returning how it was resumed
+func Resume(dockerClient *docker.Client, name string) (string, error) {
+	state, err := containerState(dockerClient, name)
+	if err != nil {
+		return "", err
+	}
+
+	switch state {
+	case "paused":
+		if output, err := dockerClient.Run("unpause", name); err != nil {
+			return "", fmt.Errorf("failed to resume %s: %w\n%s", name, err, output)
+		}
+		return "unpaused", nil
+	case "running":
+		return "", fmt.Errorf("%s is already running", name)
+	}
+
+	if !isCheckpointed(name) {
+		return "", fmt.Errorf("%s is %s and wasn't checkpointed by packnplay pause", name, state)
+	}
+	_, restore, err := checkpointArgs(dockerClient.Command(), name)
+	if err != nil {
+		return "", err
+	}
+	if output, err := dockerClient.Run(restore...); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w\n%s", name, err, output)
+	}
+	if err := session.SetCheckpointed(name, false); err != nil {
+		return "", err
+	}
+	return "restored from checkpoint", nil
+}
+
+// isCheckpointed reports whether packnplay checkpointed the container
+func isCheckpointed(name string) bool {
+	entries, err := session.LoadStore()
+	return err == nil && entries[name].Checkpointed
+}
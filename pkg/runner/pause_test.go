@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/session"
+)
+
+// fakeDockerForPause puts a docker on PATH that reports state and logs other commands
+func fakeDockerForPause(t *testing.T, state string) (*docker.Client, string) {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	script := `#!/bin/sh
+case "$1" in
+inspect) case "$3" in *State*) echo "` + state + `";; *) echo "";; esac ;;
+*) echo "$@" >> "` + log + `" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	client, err := docker.NewClientWithRuntime("docker", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, log
+}
+
+func calls(t *testing.T, log string) string {
+	data, _ := os.ReadFile(log)
+	return strings.TrimSpace(string(data))
+}
+
+func TestPauseAndResume(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	client, log := fakeDockerForPause(t, "running")
+	if err := Pause(client, "packnplay-app-main", false); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if got := calls(t, log); got != "pause packnplay-app-main" {
+		t.Errorf("Pause() ran %q", got)
+	}
+	if _, err := Resume(client, "packnplay-app-main"); err == nil {
+		t.Error("Resume() of a running container should fail")
+	}
+
+	client, log = fakeDockerForPause(t, "paused")
+	if how, err := Resume(client, "packnplay-app-main"); err != nil || how != "unpaused" {
+		t.Fatalf("Resume() = %q, %v", how, err)
+	}
+	if got := calls(t, log); got != "unpause packnplay-app-main" {
+		t.Errorf("Resume() ran %q", got)
+	}
+	if err := Pause(client, "packnplay-app-main", false); err == nil {
+		t.Error("Pause() of a paused container should fail")
+	}
+}
+
+func TestCheckpointAndRestore(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	client, log := fakeDockerForPause(t, "running")
+	if err := Pause(client, "packnplay-app-main", true); err != nil {
+		t.Fatalf("Pause(checkpoint) error = %v", err)
+	}
+	if got := calls(t, log); got != "checkpoint create packnplay-app-main packnplay" {
+		t.Errorf("Pause(checkpoint) ran %q", got)
+	}
+	if !isCheckpointed("packnplay-app-main") {
+		t.Fatal("checkpoint not recorded")
+	}
+
+	client, log = fakeDockerForPause(t, "exited")
+	if _, err := Resume(client, "packnplay-other-main"); err == nil {
+		t.Error("Resume() of a container packnplay didn't checkpoint should fail")
+	}
+	if how, err := Resume(client, "packnplay-app-main"); err != nil || how != "restored from checkpoint" {
+		t.Fatalf("Resume() = %q, %v", how, err)
+	}
+	if got := calls(t, log); got != "start --checkpoint packnplay packnplay-app-main" {
+		t.Errorf("Resume() ran %q", got)
+	}
+	if entries, _ := session.LoadStore(); entries["packnplay-app-main"].Checkpointed {
+		t.Error("restored session still marked checkpointed")
+	}
+}
+
+func TestCheckpointArgs(t *testing.T) {
+	create, restore, err := checkpointArgs("podman", "c")
+	if err != nil || strings.Join(create, " ") != "container checkpoint c" || strings.Join(restore, " ") != "container restore c" {
+		t.Errorf("checkpointArgs(podman) = %v, %v, %v", create, restore, err)
+	}
+	if _, _, err := checkpointArgs("container", "c"); err == nil {
+		t.Error("checkpointArgs(container) should fail")
+	}
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/fsguard"
+)
+
+// promptWorkspaceArgs mounts the workspace through a write guard, so writes the project's
+// write policy doesn't allow wait for 'packnplay approve' instead of landing on the host
+func promptWorkspaceArgs(containerName, runtime, mountPath string, verbose bool) ([]string, error) {
+	if err := fsguard.Available(); err != nil {
+		return nil, fmt.Errorf("workspace_mode prompt: %w", err)
+	}
+	// A guard left behind by an earlier session of the same name serves the wrong workspace
+	if err := fsguard.Forget(containerName); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(fsguard.Dir(containerName), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create write guard dir: %w", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	cmd := exec.Command(executable, "fsguard",
+		"--container", containerName,
+		"--runtime", runtime,
+		"--source", mountPath,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start write guard: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	// The container must not start on the bare mount point; the socket appears once it's mounted
+	deadline := time.After(10 * time.Second)
+	for {
+		if _, err := os.Stat(fsguard.SocketPath(containerName)); err == nil {
+			break
+		}
+		select {
+		case err := <-exited:
+			_ = fsguard.Forget(containerName)
+			return nil, fmt.Errorf("write guard failed to mount the workspace (%v); its log has the details", err)
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			_ = fsguard.Forget(containerName)
+			return nil, fmt.Errorf("write guard didn't mount the workspace within 10s")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Writes outside the write policy wait for 'packnplay approve %s'\n", containerName)
+	}
+	return append([]string{"-v", fsguard.MountPoint(containerName) + ":/workspace"}, gitDirArgs(mountPath)...), nil
+}
@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// privateHomeDirs are refused as siblings of a project checked out directly in the home
+// directory; hidden directories (.ssh, .config, ...) always are
+var privateHomeDirs = []string{"Library"}
+
+// specialFiles are file types a mount must not expose: a read-only mount still lets the
+// sandbox connect to a socket or open a device
+const specialFiles = fs.ModeSocket | fs.ModeDevice | fs.ModeCharDevice | fs.ModeNamedPipe | fs.ModeIrregular
+
+// reservedTargets can't be shadowed by an extra mount
+var reservedTargets = []string{"/", "/workspace", "/bin", "/sbin", "/usr", "/lib", "/lib64", "/etc", "/proc", "/sys", "/dev"}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// checkSibling allows only what sits next to the project: the repository's config decides
+// what's mounted, so it can't reach elsewhere on the host. Hidden directories, which hold
+// credentials and tool state, and sockets or devices anywhere inside are refused.
+func checkSibling(mountPath, resolved, projectDir, homeDir string) error {
+	root := filepath.Dir(projectDir)
+	if root == filepath.Dir(root) {
+		return fmt.Errorf("read-only mount %s: the project is at the top of the filesystem, so it has no siblings to mount", mountPath)
+	}
+	if !isWithin(resolved, root) || resolved == root {
+		return fmt.Errorf("read-only mount %s is outside %s; only the project's siblings can be mounted", mountPath, root)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(rel, string(os.PathSeparator))
+	for _, part := range parts {
+		if strings.HasPrefix(part, ".") {
+			return fmt.Errorf("read-only mount %s is in hidden directory %s", mountPath, part)
+		}
+	}
+	if root == homeDir {
+		for _, private := range privateHomeDirs {
+			if parts[0] == private {
+				return fmt.Errorf("read-only mount %s would expose ~/%s", mountPath, private)
+			}
+		}
+	}
+
+	return filepath.WalkDir(resolved, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&specialFiles != 0 {
+			return fmt.Errorf("read-only mount %s contains %s, a socket or device", mountPath, path)
+		}
+		return nil
+	})
+}
+
+// resolveReadOnlyMount validates one allowlisted mount and returns its host and container paths
+// Symlinks are resolved first, so a link can't smuggle in a path that would be refused.
+func resolveReadOnlyMount(mount config.ReadOnlyMount, projectDir, homeDir string) (string, string, error) {
+	if mount.Path == "" {
+		return "", "", fmt.Errorf("read_only_mounts entry has no path")
+	}
+
+	hostPath := mount.Path
+	switch {
+	case strings.HasPrefix(hostPath, "~/"):
+		hostPath = filepath.Join(homeDir, hostPath[2:])
+	case !filepath.IsAbs(hostPath):
+		hostPath = filepath.Join(projectDir, hostPath)
+	}
+	hostPath = filepath.Clean(hostPath)
+
+	resolved, err := filepath.EvalSymlinks(hostPath)
+	if err != nil {
+		return "", "", fmt.Errorf("read-only mount %s: %w", mount.Path, err)
+	}
+
+	switch {
+	case isWithin(homeDir, resolved):
+		return "", "", fmt.Errorf("read-only mount %s would expose your whole home directory", mount.Path)
+	case isWithin(projectDir, resolved):
+		return "", "", fmt.Errorf("read-only mount %s contains the project itself", mount.Path)
+	case isWithin(resolved, projectDir):
+		return "", "", fmt.Errorf("read-only mount %s is inside the project, which is already mounted", mount.Path)
+	}
+	if err := checkSibling(mount.Path, resolved, projectDir, homeDir); err != nil {
+		return "", "", err
+	}
+
+	target := mount.Target
+	if target == "" {
+		if filepath.IsAbs(mount.Path) || strings.HasPrefix(mount.Path, "~/") {
+			target = hostPath
+		} else {
+			// Keep relative paths working from /workspace, e.g. ../protos -> /protos
+			target = filepath.Join("/workspace", mount.Path)
+		}
+	}
+	if !filepath.IsAbs(target) {
+		return "", "", fmt.Errorf("read-only mount target %s must be an absolute path", target)
+	}
+	target = filepath.Clean(target)
+	for _, reserved := range reservedTargets {
+		if target == reserved || (reserved != "/" && isWithin(target, reserved)) {
+			return "", "", fmt.Errorf("read-only mount target %s would shadow %s", target, reserved)
+		}
+	}
+
+	return resolved, target, nil
+}
+
+// readOnlyMountArgs returns docker args for the project's allowlisted read-only mounts
+// Paths that don't exist are skipped with a warning, since a sibling checkout may simply
+// not be present on this machine; anything else that fails validation stops the session.
+func readOnlyMountArgs(projectPath, projectDir, homeDir string, verbose bool) ([]string, error) {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(projectConfig.ReadOnly) == 0 {
+		return nil, nil
+	}
+	// Compare real paths, so a symlinked project or home can't slip past the checks
+	if resolved, err := filepath.EvalSymlinks(projectDir); err == nil {
+		projectDir = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(homeDir); err == nil {
+		homeDir = resolved
+	}
+
+	var args []string
+	for _, mount := range projectConfig.ReadOnly {
+		hostPath, target, err := resolveReadOnlyMount(mount, projectDir, homeDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping read-only mount %s: it doesn't exist\n", mount.Path)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", config.ProjectConfigFile, err)
+		}
+
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", hostPath, target))
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Mounting %s read-only at %s\n", hostPath, target)
+		}
+	}
+	return args, nil
+}
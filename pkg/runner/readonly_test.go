@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestResolveReadOnlyMount(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	project := filepath.Join(root, "src", "app")
+	protos := filepath.Join(root, "src", "protos")
+	sockets := filepath.Join(root, "src", "sockets")
+	for _, dir := range []string{home, project, protos, sockets, filepath.Join(home, ".ssh"), filepath.Join(home, "shared"), filepath.Join(home, "Library"), filepath.Join(root, "src", ".secrets"), filepath.Join(project, "vendor")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(home, ".ssh"), filepath.Join(root, "src", "keys")); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(sockets, "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	tests := []struct {
+		name       string
+		mount      config.ReadOnlyMount
+		wantHost   string
+		wantTarget string
+		wantErr    string
+	}{
+		{"sibling keeps relative layout", config.ReadOnlyMount{Path: "../protos"}, protos, "/protos", ""},
+		{"explicit target", config.ReadOnlyMount{Path: "../protos", Target: "/opt/protos"}, protos, "/opt/protos", ""},
+		{"home path", config.ReadOnlyMount{Path: "~/shared"}, "", "", "only the project's siblings"},
+		{"absolute path", config.ReadOnlyMount{Path: protos}, protos, protos, ""},
+		{"whole home", config.ReadOnlyMount{Path: "~/"}, "", "", "whole home directory"},
+		{"ancestor of project", config.ReadOnlyMount{Path: ".."}, "", "", "contains the project"},
+		{"inside project", config.ReadOnlyMount{Path: "vendor"}, "", "", "already mounted"},
+		{"credentials", config.ReadOnlyMount{Path: "~/.ssh"}, "", "", "only the project's siblings"},
+		{"symlink to credentials", config.ReadOnlyMount{Path: "../keys"}, "", "", "only the project's siblings"},
+		{"hidden sibling", config.ReadOnlyMount{Path: "../.secrets"}, "", "", "hidden directory"},
+		{"sibling with a socket", config.ReadOnlyMount{Path: "../sockets"}, "", "", "socket or device"},
+		{"shadows workspace", config.ReadOnlyMount{Path: "../protos", Target: "/workspace/protos"}, "", "", "shadow /workspace"},
+		{"shadows system dir", config.ReadOnlyMount{Path: "../protos", Target: "/usr/share/protos"}, "", "", "shadow /usr"},
+		{"relative target", config.ReadOnlyMount{Path: "../protos", Target: "protos"}, "", "", "absolute"},
+		{"missing", config.ReadOnlyMount{Path: "../nope"}, "", "", "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, target, err := resolveReadOnlyMount(tt.mount, project, home)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if host != tt.wantHost || target != tt.wantTarget {
+				t.Errorf("got %s -> %s, want %s -> %s", host, target, tt.wantHost, tt.wantTarget)
+			}
+		})
+	}
+
+	// A project checked out directly in home has the home dir's contents as siblings
+	inHome := filepath.Join(home, "app")
+	if _, _, err := resolveReadOnlyMount(config.ReadOnlyMount{Path: "../shared"}, inHome, home); err != nil {
+		t.Errorf("sibling in home: unexpected error: %v", err)
+	}
+	if _, _, err := resolveReadOnlyMount(config.ReadOnlyMount{Path: "~/Library"}, inHome, home); err == nil || !strings.Contains(err.Error(), "~/Library") {
+		t.Errorf("~/Library: error = %v, want refusal", err)
+	}
+}
+
+func TestReadOnlyMountArgsSkipsMissing(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "app")
+	protos := filepath.Join(root, "protos")
+	for _, dir := range []string{project, protos} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	yaml := "read_only_mounts:\n  - path: ../protos\n  - path: ../not-checked-out\n"
+	if err := os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := readOnlyMountArgs(project, project, filepath.Join(root, "home"), false)
+	if err != nil {
+		t.Fatalf("readOnlyMountArgs() error: %v", err)
+	}
+	resolvedProtos, _ := filepath.EvalSymlinks(protos)
+	want := []string{"-v", resolvedProtos + ":/protos:ro"}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
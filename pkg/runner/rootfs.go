@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/imagebuild"
+)
+
+// readOnlyRootArgs runs the container on a read-only root filesystem
+// Each writable path gets an empty tmpfs, unless the other args already mount something
+// there (a cache volume, the home). Returns the extra docker run args.
+func readOnlyRootArgs(args []string, writable []string) ([]string, error) {
+	mounted := make(map[string]bool)
+	for i := 0; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-v":
+			if parts := strings.Split(args[i+1], ":"); len(parts) >= 2 {
+				mounted[path.Clean(parts[1])] = true
+			}
+		case "--tmpfs":
+			target, _, _ := strings.Cut(args[i+1], ":")
+			mounted[path.Clean(target)] = true
+		}
+	}
+
+	result := []string{"--read-only"}
+	paths := append(append([]string{}, imagebuild.DefaultWritablePaths...), writable...)
+	for _, p := range paths {
+		if !path.IsAbs(p) {
+			return nil, fmt.Errorf("writable path %s must be absolute", p)
+		}
+		p = path.Clean(p)
+		for _, reserved := range reservedTargets {
+			if p == reserved {
+				return nil, fmt.Errorf("writable path %s would undo the read-only root", p)
+			}
+		}
+		if mounted[p] {
+			continue
+		}
+		mounted[p] = true
+
+		// Scratch space is world-writable like /tmp; /run keeps its usual root-owned mode
+		mode := "1777"
+		if p == "/run" {
+			mode = "0755"
+		}
+		result = append(result, "--tmpfs", fmt.Sprintf("%s:rw,exec,nosuid,mode=%s", p, mode))
+	}
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadOnlyRootArgs(t *testing.T) {
+	args := []string{
+		"-v", "/src:/workspace",
+		"--tmpfs", "/home/vscode:rw,exec,mode=0755",
+		"-v", "packnplay-cache-playwright:/opt/ms-playwright",
+	}
+	got, err := readOnlyRootArgs(args, []string{"/opt/ms-playwright", "/var/lib/app/", "/var/lib/app"})
+	if err != nil {
+		t.Fatalf("readOnlyRootArgs() error = %v", err)
+	}
+	want := []string{
+		"--read-only",
+		"--tmpfs", "/tmp:rw,exec,nosuid,mode=1777",
+		"--tmpfs", "/var/tmp:rw,exec,nosuid,mode=1777",
+		"--tmpfs", "/run:rw,exec,nosuid,mode=0755",
+		"--tmpfs", "/var/lib/app:rw,exec,nosuid,mode=1777",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readOnlyRootArgs() = %v\nwant %v", got, want)
+	}
+}
+
+func TestReadOnlyRootArgsRejects(t *testing.T) {
+	for _, path := range []string{"relative/dir", "/etc", "/usr/", "/"} {
+		if _, err := readOnlyRootArgs(nil, []string{path}); err == nil {
+			t.Errorf("readOnlyRootArgs() accepted writable path %q", path)
+		}
+	}
+}
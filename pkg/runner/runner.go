@@ -6,14 +6,38 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"runtime"
 	"strings"
-	"syscall"
+	"time"
 
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/bazel"
+	"github.com/obra/packnplay/pkg/burner"
+	"github.com/obra/packnplay/pkg/cmdhistory"
 	"github.com/obra/packnplay/pkg/config"
 	"github.com/obra/packnplay/pkg/container"
 	"github.com/obra/packnplay/pkg/devcontainer"
 	"github.com/obra/packnplay/pkg/docker"
 	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/githubapp"
+	"github.com/obra/packnplay/pkg/gitidentity"
+	"github.com/obra/packnplay/pkg/imagebuild"
+	"github.com/obra/packnplay/pkg/imagetrust"
+	"github.com/obra/packnplay/pkg/lockfile"
+	"github.com/obra/packnplay/pkg/logging"
+	"github.com/obra/packnplay/pkg/metrics"
+	"github.com/obra/packnplay/pkg/netpolicy"
+	"github.com/obra/packnplay/pkg/nixenv"
+	"github.com/obra/packnplay/pkg/policy"
+	"github.com/obra/packnplay/pkg/projecttype"
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/redact"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+	"github.com/obra/packnplay/pkg/sessionenv"
+	"github.com/obra/packnplay/pkg/tailnet"
+	"github.com/obra/packnplay/pkg/userdetect"
+	"github.com/obra/packnplay/pkg/writeback"
 )
 
 type RunConfig struct {
@@ -22,36 +46,104 @@ type RunConfig struct {
 	NoWorktree     bool
 	Env            []string
 	Verbose        bool
-	Runtime        string // docker, podman, or container
-	Reconnect      bool   // Allow reconnecting to existing containers
-	DefaultImage   string // default container image to use
+	Runtime        string            // docker, podman, or container
+	Reconnect      bool              // Allow reconnecting to existing containers
+	DefaultImage   string            // default container image to use
+	LanguageImages map[string]string // project type -> base image, overriding DefaultImage
 	Command        []string
+	Agent          string   // agent the session is for, when the command doesn't say (e.g. run claude -- bash)
+	PairAgent      string   // a second agent run beside Agent in the same container ('packnplay pair')
+	PairCommand    []string // PairAgent's command
 	Credentials    config.Credentials
-	DefaultEnvVars []string // API keys to proxy from host
-	PublishPorts   []string // Port mappings to publish to host
+	DefaultEnvVars []string          // API keys to proxy from host
+	PublishPorts   []string          // Port mappings to publish to host
+	LAN            bool              // publish the session's name on the LAN with mDNS, e.g. myproj-claude.local
+	BlockedDomains []string          // Domains sinkholed by the network policy
+	Hostname       string            // sandbox hostname ("" keeps the runtime's default)
+	ExtraHosts     map[string]string // /etc/hosts entries, name -> IP or host-gateway
+	ReadOnlyRoot   bool              // read-only root filesystem; only scratch, home and WritablePaths can be written
+	WritablePaths  []string          // extra tmpfs paths on a read-only root
+	NetworkWindow  *netpolicy.Window // network cut off (except model APIs) after a while; nil leaves it open
+	BrokerActions  []string          // Host actions the container may request (empty disables the broker)
+	BrokerGitHosts []string          // host[/path] prefixes the broker's git-push may push to
+	Home           config.HomeConfig
+	AgentMounts    map[string]string // agent name -> mount mode (agents.MountModeFull/MountModeMinimal)
+	AgentTemplates map[string]string // agent name -> directory seeding its config dir on hosts without one
+	// Mount kind (MountKindWorkspace etc.) -> Docker Desktop consistency mode
+	MountConsistency map[string]string
+	// Mount kind -> SELinux relabeling option (z, Z or none)
+	SELinuxLabels    map[string]string
+	Policies         []string // Rego policy paths the container spec must satisfy
+	Caches           map[string]config.CacheVolume
+	ApproveMounts    MountApprover // asked about mounts the project hasn't used before (nil skips approval)
+	Logging          config.LoggingConfig
+	Burner           map[string]config.BurnerCredential // providers that mint a per-session key
+	GitHubApp        config.GitHubAppConfig             // mints a repo-scoped token in place of the host's GH_TOKEN
+	Record           bool                               // record the session's terminal in asciicast format
+	HandleOrphan     OrphanHandler                      // asked about untracked session containers (nil just adopts them)
+	StopSession      SessionStopper                     // removes an orphan the user chose to remove
+	MountHooks       []config.MountHook
+	Shell            config.ShellConfig // interactive shell, prompt and utilities baked into the image
+	Profile          string             // env config profile (--config), recorded as a label
+	Version          string             // packnplay version, recorded as a label
+	Labels           map[string]string  // extra labels on the container and any volumes it creates
+	DevCerts         config.DevCertsConfig
+	ImageTrust       config.ImageTrustConfig
+	Display          config.DisplayConfig
+	Tailnet          config.TailnetConfig
+	GitIdentity      config.GitIdentityConfig
+	Ulimits          map[string]string
+	Tags             []string
+	Summaries        config.SummariesConfig
+	Sysctls          map[string]string
+	DetachKeys       string
+	StatusBar        config.StatusBarConfig
+	Integrity        bool     // plant canaries and check host files once the session is torn down
+	IntegrityWatch   []string // more host files to check, besides integrity.DefaultWatch
+	Bazel            config.BazelConfig
+	DisableTelemetry bool   // inject agents.TelemetryOptOutEnv, overriding --env
+	WorkspaceMode    string // WorkspaceBind, WorkspaceSync, WorkspaceSplit or WorkspacePrompt
+	Headless         bool   // no terminal, so output can be piped (packnplay exec)
+	PipeStdin        bool   // headless sessions: forward stdin to the command
 }
 
-func Run(config *RunConfig) error {
+// workspace is where a session's files live on the host
+type workspace struct {
+	workDir        string // directory packnplay was run for
+	mountPath      string // directory mounted at /workspace (the worktree, or workDir itself)
+	worktreeName   string
+	mainRepoGitDir string // main repo's .git directory when using a worktree, mounted at its real path
+	subDir         string // where in the repository packnplay was run, relative to its root
+	sparse         *git.Sparse
+}
+
+// resolveWorkspace determines the session directory, creating the worktree if needed
+func resolveWorkspace(config *RunConfig) (*workspace, error) {
 	// Step 1: Determine working directory
 	workDir := config.Path
 	if workDir == "" {
 		var err error
 		workDir, err = os.Getwd()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return nil, fmt.Errorf("failed to get working directory: %w", err)
 		}
 	}
 
 	// Make absolute
 	workDir, err := filepath.Abs(workDir)
 	if err != nil {
-		return fmt.Errorf("failed to resolve path: %w", err)
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if info, err := os.Stat(workDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("project directory %s does not exist", workDir)
 	}
 
 	// Step 2: Handle worktree logic
 	var mountPath string
 	var worktreeName string
 	var mainRepoGitDir string // Path to main repo's .git directory for mounting
+	var subDir string
+	var sparse *git.Sparse
 
 	if config.NoWorktree {
 		// Use directory directly
@@ -61,13 +153,22 @@ func Run(config *RunConfig) error {
 		// Check if git repo
 		if !git.IsGitRepo(workDir) {
 			if config.Worktree != "" {
-				return fmt.Errorf("--worktree specified but %s is not a git repository", workDir)
+				return nil, fmt.Errorf("--worktree specified but %s is not a git repository", workDir)
 			}
 			// Not a git repo and no worktree flag: use directly
 			mountPath = workDir
 			worktreeName = "no-worktree"
 		} else {
-			// Is a git repo
+			// Is a git repo; sessions are per repository, wherever inside it packnplay was run
+			root, err := git.FindRoot(workDir)
+			if err != nil {
+				return nil, err
+			}
+			if rel, err := filepath.Rel(root, workDir); err == nil && rel != "." {
+				subDir = rel
+			}
+			workDir = root
+
 			explicitWorktree := config.Worktree != ""
 			if explicitWorktree {
 				worktreeName = config.Worktree
@@ -75,22 +176,22 @@ func Run(config *RunConfig) error {
 				// Auto-detect from current branch
 				branch, err := git.GetCurrentBranch(workDir)
 				if err != nil {
-					return fmt.Errorf("failed to get current branch: %w", err)
+					return nil, fmt.Errorf("failed to get current branch: %w", err)
 				}
 				worktreeName = branch
 			}
 
 			// Check if worktree exists
-			exists, err := git.WorktreeExists(worktreeName)
+			exists, err := git.WorktreeExists(workDir, worktreeName)
 			if err != nil {
-				return fmt.Errorf("failed to check worktree: %w", err)
+				return nil, fmt.Errorf("failed to check worktree: %w", err)
 			}
 
 			if exists {
 				// Worktree already exists - just use it
-				actualPath, err := git.GetWorktreePath(worktreeName)
+				actualPath, err := git.GetWorktreePath(workDir, worktreeName)
 				if err != nil {
-					return fmt.Errorf("failed to get worktree path: %w", err)
+					return nil, fmt.Errorf("failed to get worktree path: %w", err)
 				}
 				mountPath = actualPath
 				if config.Verbose {
@@ -103,8 +204,8 @@ func Run(config *RunConfig) error {
 					fmt.Fprintf(os.Stderr, "Creating worktree at %s\n", mountPath)
 				}
 
-				if err := git.CreateWorktree(mountPath, worktreeName, config.Verbose); err != nil {
-					return fmt.Errorf("failed to create worktree: %w", err)
+				if err := git.CreateWorktree(workDir, mountPath, worktreeName, config.Verbose); err != nil {
+					return nil, fmt.Errorf("failed to create worktree: %w", err)
 				}
 			}
 
@@ -115,16 +216,85 @@ func Run(config *RunConfig) error {
 				realWorkDir = workDir // Fallback if can't resolve
 			}
 			mainRepoGitDir = filepath.Join(realWorkDir, ".git")
+
+			sparse, err = git.SparseInfo(mountPath)
+			if err != nil {
+				return nil, err
+			}
+			if sparse != nil && config.Verbose {
+				describeSparse(sparse)
+			}
 		}
 	}
 
+	return &workspace{
+		workDir:        workDir,
+		mountPath:      mountPath,
+		worktreeName:   worktreeName,
+		mainRepoGitDir: mainRepoGitDir,
+		subDir:         subDir,
+		sparse:         sparse,
+	}, nil
+}
+
+func Run(config *RunConfig) (err error) {
+	ws, err := resolveWorkspace(config)
+	if err != nil {
+		return err
+	}
+	workDir, mountPath, worktreeName, mainRepoGitDir := ws.workDir, ws.mountPath, ws.worktreeName, ws.mainRepoGitDir
+	if err := checkAgentRules(mountPath, config); err != nil {
+		return err
+	}
+	if err := validateWorkspaceMode(config.WorkspaceMode); err != nil {
+		return err
+	}
+	if err := checkWritePolicy(mountPath, config.WorkspaceMode, config.Verbose); err != nil {
+		return err
+	}
+	syncWorkspace := config.WorkspaceMode == WorkspaceSync
+	splitWorkspace := config.WorkspaceMode == WorkspaceSplit
+	promptWorkspace := config.WorkspaceMode == WorkspacePrompt
+
 	// Step 3: Load devcontainer config
 	devConfig, err := devcontainer.LoadConfig(mountPath)
 	if err != nil {
 		return fmt.Errorf("failed to load devcontainer config: %w", err)
 	}
 	if devConfig == nil {
-		devConfig = devcontainer.GetDefaultConfig(config.DefaultImage)
+		if err := projecttype.Validate(config.LanguageImages); err != nil {
+			return err
+		}
+		image, projectType := projecttype.Image(mountPath, config.LanguageImages, config.DefaultImage)
+		if projectType != "" && config.Verbose {
+			fmt.Fprintf(os.Stderr, "Using %s for this %s project (language_images)\n", image, projectType)
+		}
+		devConfig = devcontainer.GetDefaultConfig(image)
+	}
+
+	// Pin the image to the lockfile digest so every run gets an identical sandbox
+	committed := false
+	var lockedPackages map[string]string
+	if devConfig.DockerFile == "" {
+		lock, err := lockfile.Load(mountPath)
+		if err != nil {
+			return err
+		}
+		if lock != nil {
+			lockedPackages = lock.AgentPackages
+			pinnedImage, err := lock.PinnedImage(devConfig.Image)
+			if err != nil {
+				return err
+			}
+			if config.Verbose {
+				fmt.Fprintf(os.Stderr, "Using %s pinned by %s\n", pinnedImage, lockfile.FileName)
+			}
+			committed = committedPin(mountPath, devConfig.Image, pinnedImage)
+			if !committed && imagetrust.Enabled(config.ImageTrust) && config.ImageTrust.TrustLockfile {
+				fmt.Fprintf(os.Stderr, "Warning: %s pin of %s isn't committed, so it needs a signature\n", lockfile.FileName, pinnedImage)
+			}
+			devConfig.Image = pinnedImage
+		}
 	}
 
 	// Step 4: Initialize container client
@@ -132,9 +302,22 @@ func Run(config *RunConfig) error {
 	if err != nil {
 		return fmt.Errorf("failed to initialize container runtime: %w", err)
 	}
+	// Flake and devenv projects run their commands in the project's dev shell
+	nixKind, err := projectNixKind(dockerClient, mountPath)
+	if err != nil {
+		return err
+	}
 
 	// Step 5: Ensure image available
+	agentName := config.Agent
+	if agentName == "" {
+		agentName = sessionAgent(config.Command)
+	}
 	if err := ensureImage(dockerClient, devConfig, mountPath, config.Verbose); err != nil {
+		recordEvent(metrics.Event{Type: metrics.EventSessionFailure, Agent: agentName})
+		return err
+	}
+	if devConfig.Image, err = verifyImage(dockerClient, config.ImageTrust, devConfig, mountPath, committed, config.Verbose); err != nil {
 		return err
 	}
 
@@ -142,6 +325,62 @@ func Run(config *RunConfig) error {
 	projectName := filepath.Base(workDir)
 	containerName := container.GenerateContainerName(workDir, worktreeName)
 	labels := container.GenerateLabels(projectName, worktreeName)
+	labels[container.AgentLabel] = agentName
+	owner := ""
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+	}
+	extraLabels, err := sessionLabels(mountPath, config.Labels)
+	if err != nil {
+		return err
+	}
+	meta := container.Metadata{
+		SessionID: container.NewSessionID(),
+		Owner:     owner,
+		Profile:   config.Profile,
+		Version:   config.Version,
+		Created:   time.Now(),
+		Project:   projectName,
+		Worktree:  worktreeName,
+		Agent:     agentName,
+		Extra:     extraLabels,
+	}
+	for k, v := range container.MetadataLabels(meta) {
+		labels[k] = v
+	}
+	if head, err := git.GetHeadCommit(mountPath); err == nil {
+		labels[container.StartCommitLabel] = head
+	}
+	windowStart := time.Now()
+	if config.NetworkWindow != nil {
+		if dockerClient.Command() == "container" {
+			return fmt.Errorf("network windows are not supported with Apple Container")
+		}
+		for k, v := range windowLabels(config.NetworkWindow, windowStart) {
+			labels[k] = v
+		}
+	}
+
+	// Agents and credentials mount under the container user's home, which isn't always /home/<user>
+	sessionImage := devConfig.Image
+	if devConfig.DockerFile != "" {
+		sessionImage = fmt.Sprintf("packnplay-%s-devcontainer:latest", projectName)
+	}
+	containerHomeDir, err := userdetect.ResolveHome(dockerClient.Run, sessionImage, devConfig.RemoteUser)
+	if err != nil {
+		containerHomeDir = userdetect.ConventionalHome(devConfig.RemoteUser)
+		if config.Verbose {
+			fmt.Fprintf(os.Stderr, "Warning: %v; assuming home %s\n", err, containerHomeDir)
+		}
+	}
+
+	// Pick up session containers a crash left untracked before starting another
+	attach, err := adoptOrphans(dockerClient, config.HandleOrphan, config.StopSession, containerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to check for untracked sessions: %v\n", err)
+	} else if attach != "" {
+		return reconnect(config, dockerClient, attach, "/workspace", nixKind)
+	}
 
 	// Step 7: Check if container already running
 	if isRunning, err := containerIsRunning(dockerClient, containerName); err != nil {
@@ -155,25 +394,13 @@ func Run(config *RunConfig) error {
 				worktreeFlag = fmt.Sprintf(" --worktree=%s", worktreeName)
 			}
 
-			var cmdStr strings.Builder
-			for i, arg := range config.Command {
-				if i > 0 {
-					cmdStr.WriteString(" ")
-				}
-				if strings.Contains(arg, " ") {
-					cmdStr.WriteString(fmt.Sprintf("'%s'", arg))
-				} else {
-					cmdStr.WriteString(arg)
-				}
-			}
-
 			return fmt.Errorf(`container already running for this worktree
 
 To run your command in the existing container:
   packnplay run%s --reconnect %s
 
 To stop the existing container:
-  packnplay stop%s`, worktreeFlag, cmdStr.String(), worktreeFlag)
+  packnplay stop%s`, worktreeFlag, shellJoin(config.Command), worktreeFlag)
 		}
 
 		// User explicitly wants to reconnect
@@ -181,38 +408,55 @@ To stop the existing container:
 			fmt.Fprintf(os.Stderr, "Reconnecting to existing container %s\n", containerName)
 		}
 
-		// Get container ID
-		containerID, err := getContainerID(dockerClient, containerName)
-		if err != nil {
-			return fmt.Errorf("failed to get container ID: %w", err)
-		}
-
-		// Exec into existing container
-		cmdPath, err := exec.LookPath(dockerClient.Command())
-		if err != nil {
-			return fmt.Errorf("failed to find docker command: %w", err)
-		}
-
-		// Always use /workspace as working directory
-		execArgs := []string{
-			filepath.Base(cmdPath),
-			"exec",
-			"-it",
-			"-w", "/workspace",
-			containerID,
-		}
-		execArgs = append(execArgs, config.Command...)
-
-		return syscall.Exec(cmdPath, execArgs, os.Environ())
+		return reconnect(config, dockerClient, containerName, sparseWorkingDir(mountPath, ws.subDir, ws.sparse), nixKind)
 	}
 
 	// Remove any stopped containers with same name (required for clean start)
 	if config.Verbose {
 		fmt.Fprintf(os.Stderr, "Checking for stopped container with same name...\n")
 	}
+	// A checkpointed session looks stopped, but removing it would lose the checkpoint
+	if isCheckpointed(containerName) {
+		return fmt.Errorf("%s is checkpointed; continue it with 'packnplay resume %s' or discard it with 'packnplay stop'", containerName, containerName)
+	}
+	// Likewise a sync-mode session's copy of the workspace may hold edits not yet written back
+	if writeback.Active(containerName) {
+		return fmt.Errorf("%s may have edits that weren't written back yet; 'packnplay stop' writes them back and cleans up", containerName)
+	}
+	if sessionbranch.Active(containerName) {
+		return fmt.Errorf("%s may have work that wasn't committed to its session branch yet; 'packnplay stop' commits it and cleans up", containerName)
+	}
 	// Try to remove - ignore errors if container doesn't exist
 	_, _ = dockerClient.Run("rm", containerName)
 
+	// Record the session before anything is created for it, so a crash from here on
+	// can be cleaned up by the next run; a failed start is torn down right away
+	if err := session.Register(session.Entry{
+		ID:        meta.SessionID,
+		Container: containerName,
+		Project:   projectName,
+		Worktree:  worktreeName,
+		Agent:     agentName,
+		Tags:      config.Tags,
+		StartedAt: time.Now().UTC(),
+	}); err != nil {
+		return fmt.Errorf("failed to record session: %w", err)
+	}
+	if err := session.Transition(containerName, session.StateProvisioning); err != nil {
+		return err
+	}
+	provisioned := false
+	defer func() {
+		if !provisioned {
+			// Recorded before teardown moves the session to the history, so 'packnplay sessions --status failed' finds it
+			if err != nil {
+				_ = session.SetFailure(containerName, err.Error())
+			}
+			_ = session.BeginRemoval(containerName)
+			_ = Teardown(dockerClient, containerName)
+		}
+	}()
+
 	// Step 8: Get current user and detect OS
 	currentUser, err := user.Current()
 	if err != nil {
@@ -241,6 +485,11 @@ To stop the existing container:
 	// Add name
 	args = append(args, "--name", containerName)
 
+	// Bound container logs or ship them to syslog/journald
+	if !isApple {
+		args = append(args, logging.ContainerArgs(config.Logging, containerName)...)
+	}
+
 	// Add mounts with or without idmap based on OS
 	homeDir := currentUser.HomeDir
 
@@ -282,42 +531,109 @@ To stop the existing container:
 		}
 	}
 
+	// Bind mount consistency only matters to Docker Desktop for Mac
+	isDockerDesktopMac := runtime.GOOS == "darwin" && dockerClient.Command() == "docker"
+	fileSharing := ""
+	if isDockerDesktopMac {
+		fileSharing = docker.DetectFileSharing(homeDir)
+		if config.Verbose && fileSharing != "" && fileSharing != docker.FileSharingVirtioFS {
+			fmt.Fprintf(os.Stderr, "Docker Desktop uses %s file sharing; switching to VirtioFS makes bind mounts much faster\n", fileSharing)
+		}
+	}
+	consistency := resolveConsistency(config.MountConsistency, isDockerDesktopMac, fileSharing)
+
+	// Without relabeling, an enforcing SELinux host denies the container its bind mounts
+	enforcing := isLinux && !isApple && selinuxEnforcing()
+	selinuxLabels := resolveSELinuxLabels(config.SELinuxLabels, enforcing)
+	if config.Verbose && enforcing {
+		fmt.Fprintf(os.Stderr, "SELinux is enforcing; relabeling mounts %v\n", selinuxLabels)
+	}
+	mountOptions := func(volume, kind string) string {
+		return withMountOption(withMountOption(volume, consistency[kind]), selinuxLabels[kind])
+	}
+
 	// Mount .claude directory
-	args = append(args, "-v", fmt.Sprintf("%s/.claude:/home/%s/.claude", homeDir, devConfig.RemoteUser))
+	claudeDir, err := agentConfigSource(containerName, &agents.ClaudeAgent{}, homeDir, config.AgentTemplates, config.Verbose)
+	if err != nil {
+		return err
+	}
+	args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s/.claude", claudeDir, containerHomeDir), MountKindAgents))
 
 	// Overlay mount credential file after .claude directory mount
 	if needsCredentialOverlay {
-		args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.claude/.credentials.json", credentialFile, devConfig.RemoteUser))
+		args = append(args, "-v", fmt.Sprintf("%s:%s/.claude/.credentials.json", credentialFile, containerHomeDir))
+	}
+
+	// Mount workspace at /workspace, or a copy or overlay of it
+	if (syncWorkspace || splitWorkspace || promptWorkspace) && isApple {
+		return fmt.Errorf("workspace_mode %s needs docker or podman", config.WorkspaceMode)
+	}
+	switch {
+	case syncWorkspace:
+		syncArgs, err := syncWorkspaceArgs(dockerClient, containerName, mountPath, meta)
+		if err != nil {
+			return err
+		}
+		args = append(args, syncArgs...)
+	case splitWorkspace:
+		splitArgs, err := splitWorkspaceArgs(dockerClient, containerName, mountPath, devConfig.RemoteUser, meta, config.Verbose)
+		if err != nil {
+			return err
+		}
+		args = append(args, splitArgs...)
+	case promptWorkspace:
+		promptArgs, err := promptWorkspaceArgs(containerName, dockerClient.Command(), mountPath, config.Verbose)
+		if err != nil {
+			return err
+		}
+		args = append(args, promptArgs...)
+	default:
+		args = append(args, "-v", mountOptions(fmt.Sprintf("%s:/workspace", mountPath), MountKindWorkspace))
 	}
 
-	// Mount workspace at /workspace
-	args = append(args, "-v", fmt.Sprintf("%s:/workspace", mountPath))
+	// Mount AI agent config directories and files if they exist
+	// Claude is handled above because it needs the credential overlay
+	var agentEnv []string
+	var fileMountDirs []string // parents of file mounts, which the runtime creates as root
+	launched := launchedAgents(agentName, config.PairAgent)
+	for _, agent := range agents.GetSupportedAgents() {
+		if agent.RequiresSpecialHandling() {
+			continue
+		}
 
-	// Mount AI agent config directories if they exist
-	agentConfigDirs := []string{".codex", ".gemini", ".copilot", ".qwen", ".cursor", ".deepseek"}
-	for _, configDir := range agentConfigDirs {
-		agentPath := filepath.Join(homeDir, configDir)
-		if fileExists(agentPath) {
-			args = append(args, "-v", fmt.Sprintf("%s:/home/%s/%s", agentPath, devConfig.RemoteUser, configDir))
+		mounts := agents.MountsFor(agent, config.AgentMounts[agent.Name()], homeDir, devConfig.RemoteUser)
+		if !launched[agent.Name()] {
+			mounts = agents.OwnMounts(agent, mounts, homeDir)
+		}
+		for _, mount := range agents.Rehome(mounts, devConfig.RemoteUser, containerHomeDir) {
+			// Only agents with a template get a scratch config dir; the rest make their own
+			if mount.HostPath == filepath.Join(homeDir, agent.ConfigDir()) && config.AgentTemplates[agent.Name()] != "" {
+				if mount.HostPath, err = agentConfigSource(containerName, agent, homeDir, config.AgentTemplates, config.Verbose); err != nil {
+					return err
+				}
+			}
+			if !fileExists(mount.HostPath) {
+				continue
+			}
+			if info, err := os.Stat(mount.HostPath); err == nil && !info.IsDir() {
+				fileMountDirs = append(fileMountDirs, filepath.Dir(mount.ContainerPath))
+			}
+			args = append(args, "-v", mountOptions(mountArg(mount), MountKindAgents))
 			if config.Verbose {
-				fmt.Fprintf(os.Stderr, "Mounting %s config directory\n", configDir)
+				fmt.Fprintf(os.Stderr, "Mounting %s for %s\n", mount.HostPath, agent.Name())
 			}
 		}
-	}
 
-	// Mount .config/amp directory for Sourcegraph Amp CLI if it exists
-	ampConfigPath := filepath.Join(homeDir, ".config", "amp")
-	if fileExists(ampConfigPath) {
-		args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.config/amp", ampConfigPath, devConfig.RemoteUser))
-		if config.Verbose {
-			fmt.Fprintf(os.Stderr, "Mounting amp config directory\n")
+		// Cloud credentials in the environment, like AWS keys, are only for the agent that uses them
+		if provider, ok := agent.(agents.EnvProvider); ok && launched[agent.Name()] {
+			agentEnv = append(agentEnv, agents.RehomeEnv(provider.GetEnv(homeDir, devConfig.RemoteUser), devConfig.RemoteUser, containerHomeDir)...)
 		}
 	}
 
 	// If using a worktree, also mount the main repo's .git directory at its real path
 	// This allows the worktree's .git file (which contains gitdir: <path>) to resolve correctly
 	if mainRepoGitDir != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s", mainRepoGitDir, mainRepoGitDir))
+		args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s", mainRepoGitDir, mainRepoGitDir), MountKindGit))
 	}
 
 	// Mount git config
@@ -333,7 +649,7 @@ To stop the existing container:
 				// Fall back to original path if symlink resolution fails
 				resolvedPath = gitconfigPath
 			}
-			args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.gitconfig:ro", resolvedPath, devConfig.RemoteUser))
+			args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s/.gitconfig:ro", resolvedPath, containerHomeDir), MountKindCredentials))
 		}
 	}
 
@@ -341,7 +657,7 @@ To stop the existing container:
 	if config.Credentials.SSH {
 		sshPath := filepath.Join(homeDir, ".ssh")
 		if fileExists(sshPath) {
-			args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.ssh:ro", sshPath, devConfig.RemoteUser))
+			args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s/.ssh:ro", sshPath, containerHomeDir), MountKindCredentials))
 		}
 	}
 
@@ -350,7 +666,7 @@ To stop the existing container:
 	if config.Credentials.GH && isLinux {
 		ghConfigPath := filepath.Join(homeDir, ".config", "gh")
 		if fileExists(ghConfigPath) {
-			args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.config/gh", ghConfigPath, devConfig.RemoteUser))
+			args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s/.config/gh", ghConfigPath, containerHomeDir), MountKindCredentials))
 		}
 	}
 
@@ -358,7 +674,7 @@ To stop the existing container:
 		// Mount .gnupg directory (read-only for security)
 		gnupgPath := filepath.Join(homeDir, ".gnupg")
 		if fileExists(gnupgPath) {
-			args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.gnupg:ro", gnupgPath, devConfig.RemoteUser))
+			args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s/.gnupg:ro", gnupgPath, containerHomeDir), MountKindCredentials))
 		}
 	}
 
@@ -375,39 +691,216 @@ To stop the existing container:
 				// Fall back to original path if symlink resolution fails
 				resolvedPath = npmrcPath
 			}
-			args = append(args, "-v", fmt.Sprintf("%s:/home/%s/.npmrc:ro", resolvedPath, devConfig.RemoteUser))
+			args = append(args, "-v", mountOptions(fmt.Sprintf("%s:%s/.npmrc:ro", resolvedPath, containerHomeDir), MountKindCredentials))
 		}
 	}
 
-	workingDir := "/workspace"
+	// Make the home a tmpfs so stray writes vanish with the container, keeping only declared subpaths
+	tmpfsHome := !config.Home.Persistent && !isApple
+	if config.ReadOnlyRoot && !tmpfsHome {
+		if isApple {
+			return fmt.Errorf("a read-only root filesystem is not supported with Apple Container")
+		}
+		return fmt.Errorf("a read-only root filesystem needs the tmpfs home; unset home.persistent")
+	}
+	if tmpfsHome {
+		persistDir, err := getHomePersistDir(containerName)
+		if err != nil {
+			return err
+		}
+		homeArgs, err := homeMountArgs(containerHomeDir, persistDir, config.Home)
+		if err != nil {
+			return err
+		}
+		for i := 1; i < len(homeArgs); i++ {
+			if homeArgs[i-1] == "-v" {
+				homeArgs[i] = mountOptions(homeArgs[i], MountKindHome)
+			}
+		}
+		args = append(args, homeArgs...)
+	}
+
+	// Shared dependency caches (local volumes or team network storage)
+	var cacheTargets []string
+	caches, err := withBrowserCache(config.Caches, mountPath, workDir)
+	if err != nil {
+		return err
+	}
+	bazelWorkspace := !config.Bazel.Disabled && bazel.IsWorkspace(mountPath)
+	if bazelWorkspace {
+		caches = withBazelCaches(caches, containerName)
+	}
+	if len(caches) > 0 {
+		cacheArgs, targets, err := cacheMountArgs(dockerClient, caches, containerHomeDir, meta)
+		if err != nil {
+			return err
+		}
+		args = append(args, cacheArgs...)
+		cacheTargets = targets
+	}
+
+	// Project-declared read-only mounts outside the workspace (shared protos, sibling checkouts)
+	readOnlyArgs, err := readOnlyMountArgs(mountPath, workDir, homeDir, config.Verbose)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(readOnlyArgs); i++ {
+		if readOnlyArgs[i-1] == "-v" {
+			readOnlyArgs[i] = withMountOption(readOnlyArgs[i], selinuxLabels[MountKindReadOnly])
+		}
+	}
+	args = append(args, readOnlyArgs...)
+
+	// Locally trusted certificate for HTTPS dev servers on forwarded ports
+	devCertMountArgs, err := devCertArgs(config.DevCerts, config.Verbose)
+	if err != nil {
+		return err
+	}
+	args = append(args, devCertMountArgs...)
+
+	// A screen for agents driving a headed browser
+	displayMountArgs, err := displayArgs(config.Display, containerName, config.Verbose)
+	if err != nil {
+		return err
+	}
+	args = append(args, displayMountArgs...)
+
+	// A private network node of its own, through a sidecar started below
+	if config.Tailnet.Mode != "" && isApple {
+		return fmt.Errorf("tailnet mode %s needs docker or podman", config.Tailnet.Mode)
+	}
+	args = append(args, tailnet.SessionArgs(config.Tailnet.Mode)...)
+
+	// Let user hooks sanitize agent config files; the container gets the transformed copies
+	claudeConfigSrc := filepath.Join(homeDir, ".claude.json")
+	hookArgs, sanitized, err := applyMountHooks(config.MountHooks, args, homeDir, containerName, []string{claudeConfigSrc}, config.Verbose)
+	if err != nil {
+		return err
+	}
+	args = append(args, hookArgs...)
+
+	// In a sparse monorepo, start where the materialized code is rather than at a mostly empty root
+	workingDir := sparseWorkingDir(mountPath, ws.subDir, ws.sparse)
 
 	// Set working directory
 	args = append(args, "-w", workingDir)
 
 	// Add environment variables
 	// Only pass safe terminal/locale variables - nothing else from host
-	safeEnvVars := []string{"TERM", "LANG", "LC_ALL", "LC_CTYPE", "LC_MESSAGES", "COLORTERM"}
-	for _, key := range safeEnvVars {
-		if value := os.Getenv(key); value != "" {
-			args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
-		}
-	}
+	args = append(args, TerminalEnvArgs()...)
 
 	// Set HOME to container user's home directory (don't use host HOME)
-	args = append(args, "-e", fmt.Sprintf("HOME=/home/%s", devConfig.RemoteUser))
+	args = append(args, "-e", fmt.Sprintf("HOME=%s", containerHomeDir))
+
+	// Point agents and `packnplay attach` at the configured shell (Apple Container skips the shell layer)
+	if shellPath := imagebuild.ShellPath(config.Shell); shellPath != "" && dockerClient.Command() != "container" {
+		args = append(args, "-e", "SHELL="+shellPath)
+	}
 
 	// Add IS_SANDBOX marker so tools know they're in a sandbox
 	args = append(args, "-e", "IS_SANDBOX=1")
 
+	// Changes made with 'packnplay env' reach the session's new shells and commands
+	envArgs, err := sessionenv.Prepare(containerName)
+	if err != nil {
+		return err
+	}
+	args = append(args, envArgs...)
+
+	// The session's bash commands are recorded for 'packnplay history'; the hook also applies the env changes above
+	historyArgs, err := cmdhistory.Prepare(containerName)
+	if err != nil {
+		return err
+	}
+	args = append(args, historyArgs...)
+
+	// Session git config from every feature below, set together at the end
+	var gitConfig []string
+
+	// Commits made in the sandbox are authored by the agent and name the session
+	if config.GitIdentity.Enabled {
+		coAuthor := ""
+		if config.GitIdentity.CoAuthor {
+			coAuthor = gitidentity.HostIdentity()
+		}
+		gitDir, err := gitidentity.Prepare(containerName, gitidentity.Trailers(meta.SessionID, coAuthor))
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", gitDir, gitidentity.ContainerDir))
+		args = append(args, gitidentity.EnvArgs(gitidentity.Author(config.GitIdentity, agentName))...)
+		gitConfig = append(gitConfig, gitidentity.GitConfig()...)
+	}
+
+	// Bazel reaches its shared caches and remote cache through a session system rc
+	if bazelWorkspace {
+		bazelMounts, err := bazelArgs(config.Bazel, containerName, containerHomeDir, homeDir)
+		if err != nil {
+			return err
+		}
+		args = append(args, bazelMounts...)
+	}
+
 	// Don't set PATH - use container's default PATH to avoid host pollution
 
+	// Mint per-session provider keys; they stand in for the host's keys for those providers
+	burnerEnv, err := mintBurnerCredentials(dockerClient, config.Burner, containerName, config.Verbose)
+	if err != nil {
+		return err
+	}
+	started := false
+	if len(burnerEnv) > 0 {
+		defer func() {
+			if !started {
+				_ = burner.RevokeSession(containerName)
+			}
+		}()
+	}
+	replacedVars := make(map[string]bool)
+	for _, envVar := range burner.EnvVars(config.Burner) {
+		replacedVars[envVar] = true
+	}
+
+	// A repo-scoped GitHub App token stands in for the host's personal GitHub token
+	githubArgs, githubGitConfig, err := mintGitHubAppToken(config.GitHubApp, containerName, mountPath, config.Verbose)
+	if err != nil {
+		return err
+	}
+	gitConfig = append(gitConfig, githubGitConfig...)
+	if len(githubArgs) > 0 {
+		defer func() {
+			if !started {
+				_ = githubapp.RevokeSession(containerName)
+			}
+		}()
+	}
+	if githubapp.Enabled(config.GitHubApp) {
+		for _, envVar := range githubapp.TokenVars {
+			replacedVars[envVar] = true
+		}
+	}
+
 	// Add default environment variables (API keys for AI agents)
 	for _, envVar := range config.DefaultEnvVars {
+		if replacedVars[envVar] {
+			continue
+		}
 		if value := os.Getenv(envVar); value != "" {
 			args = append(args, "-e", fmt.Sprintf("%s=%s", envVar, value))
 		}
 	}
 
+	for _, env := range burnerEnv {
+		args = append(args, "-e", env)
+	}
+	args = append(args, githubArgs...)
+	args = append(args, gitConfigArgs(gitConfig)...)
+
+	// Add agent-specific env vars (e.g. container-side credential paths)
+	for _, env := range agentEnv {
+		args = append(args, "-e", env)
+	}
+
 	// Add user-specified env vars from --env flags (these can override defaults)
 	for _, env := range config.Env {
 		// Support both --env KEY=value and --env KEY (pass through from host)
@@ -422,39 +915,299 @@ To stop the existing container:
 		}
 	}
 
+	// Privacy policy is set last, so --env can't turn telemetry back on
+	if config.DisableTelemetry {
+		for _, env := range agents.TelemetryOptOutEnv() {
+			args = append(args, "-e", env)
+		}
+	}
+
+	// Start the host command broker and expose its socket
+	if len(config.BrokerActions) > 0 {
+		sparseWorkspace := ""
+		if ws.sparse != nil && len(ws.sparse.Patterns) > 0 {
+			sparseWorkspace = mountPath
+		}
+		brokerArgs, err := startHostBroker(containerName, dockerClient.Command(), config.BrokerActions, config.BrokerGitHosts, mountPath, sparseWorkspace, config.Verbose)
+		if err != nil {
+			return err
+		}
+		args = append(args, brokerArgs...)
+	}
+
 	// Add port mappings
 	for _, port := range config.PublishPorts {
 		args = append(args, "-p", port)
 	}
 
+	// Hostname and /etc/hosts entries, ahead of the sinkhole so an explicit mapping wins
+	hostArgs, err := hostsArgs(config.Hostname, config.ExtraHosts)
+	if err != nil {
+		return err
+	}
+	args = append(args, hostArgs...)
+
+	// Resource limits and kernel settings, e.g. more open files for big test suites
+	if (len(config.Ulimits) > 0 || len(config.Sysctls) > 0) && isApple {
+		return fmt.Errorf("ulimits and sysctls need docker or podman")
+	}
+	limitArgs, err := limitsArgs(config.Ulimits, config.Sysctls)
+	if err != nil {
+		return err
+	}
+	args = append(args, limitArgs...)
+
+	// Sinkhole blocklisted domains so they never resolve inside the container
+	if len(config.BlockedDomains) > 0 {
+		args = append(args, netpolicy.HostArgs(config.BlockedDomains)...)
+		if config.Verbose {
+			fmt.Fprintf(os.Stderr, "Blocking %d domains via network policy\n", len(config.BlockedDomains))
+		}
+	}
+
+	// packnplay's own session files need relabeling too, whatever the user's mounts get
+	if enforcing {
+		xdgDataHome := os.Getenv("XDG_DATA_HOME")
+		if xdgDataHome == "" {
+			xdgDataHome = filepath.Join(homeDir, ".local", "share")
+		}
+		labelStateMounts(args, filepath.Join(xdgDataHome, "packnplay"))
+	}
+
 	// Add image
 	imageName := devConfig.Image
 	if devConfig.DockerFile != "" {
 		imageName = fmt.Sprintf("packnplay-%s-devcontainer:latest", projectName)
 	}
+	baseImage := imageName
+	// Prefer the agent layer from `packnplay upgrade-agents`; the container inherits its version labels
+	if len(lockedPackages) > 0 {
+		agentImage, err := ensureLockedAgents(dockerClient, imageName, devConfig.RemoteUser, projectName, lockedPackages, config.Verbose)
+		if err != nil {
+			return err
+		}
+		imageName = agentImage
+	} else if agentImage, stale := imagebuild.FindAgentImage(dockerClient, projectName, imageName); agentImage != "" {
+		if config.Verbose {
+			fmt.Fprintf(os.Stderr, "Using agent layer %s\n", agentImage)
+		}
+		imageName = agentImage
+	} else if stale {
+		fmt.Fprintf(os.Stderr, "Warning: %s was built on a different image; run 'packnplay upgrade-agents' to rebuild it\n", imagebuild.AgentImageName(projectName))
+	}
+	// Bake in the extra packages the project declares, as their own cached layer
+	packagesImage, err := ensurePackagesImage(dockerClient, imageName, devConfig.RemoteUser, projectName, mountPath, config.Verbose)
+	if err != nil {
+		return err
+	}
+	imageName = packagesImage
+	// Then the user's shell setup, shared by every project
+	shellImage, err := ensureShellImage(dockerClient, imageName, devConfig.RemoteUser, projectName, config.Shell, config.Verbose)
+	if err != nil {
+		return err
+	}
+	imageName = shellImage
+	// Then Nix, with the store in a volume shared across the project's sessions
+	var nixArgs []string
+	if nixKind != "" {
+		nixImage, storeArgs, err := ensureNixImage(dockerClient, imageName, devConfig.RemoteUser, projectName, workDir, nixKind, containerHomeDir, meta, config.Verbose)
+		if err != nil {
+			return err
+		}
+		imageName, nixArgs = nixImage, storeArgs
+		args = append(args, nixArgs...)
+	}
+	// Run postCreateCommand once per lockfile state and reuse the committed layer
+	if len(devConfig.PostCreateCommand) > 0 {
+		if isApple {
+			fmt.Fprintf(os.Stderr, "Warning: postCreateCommand is not supported with Apple Container; skipping\n")
+		} else {
+			setupImage, err := ensureSetupImage(dockerClient, imageName, devConfig.RemoteUser, nixenv.Wrap(nixKind, devConfig.PostCreateCommand), projectName, mountPath, nixArgs, config.Verbose)
+			if err != nil {
+				return err
+			}
+			imageName = setupImage
+		}
+	}
+	// Only scratch space and the paths the image's layers and config declare stay writable
+	if config.ReadOnlyRoot {
+		writable, err := imagebuild.WritablePaths(dockerClient, imageName)
+		if err != nil {
+			return err
+		}
+		rootArgs, err := readOnlyRootArgs(args, append(writable, config.WritablePaths...))
+		if err != nil {
+			return err
+		}
+		args = append(args, rootArgs...)
+	}
 	args = append(args, imageName)
 
 	// Add a command that keeps container alive
 	args = append(args, "sleep", "infinity")
 
+	// Like an editor's "trust this workspace": new mounts need the user's approval once per project
+	if config.ApproveMounts != nil {
+		replacements := map[string]string{
+			homeDir:       "~",
+			containerName: "<session>",
+			workDir:       "<project>",
+		}
+		replacements[mountPath] = "<workspace>"
+		mounts, err := describeMounts(args, replacements)
+		if err != nil {
+			return fmt.Errorf("failed to list mounts for approval: %w", err)
+		}
+		if err := checkMountApproval(workDir, mounts, config.ApproveMounts); err != nil {
+			return err
+		}
+	}
+
+	// Refuse to start specs that violate organization policy
+	if len(config.Policies) > 0 {
+		spec, err := policy.ParseRunArgs(args)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate policy: %w", err)
+		}
+		spec.Runtime = dockerClient.Command()
+		spec.SetBaseImage(baseImage)
+		violations, err := policy.Evaluate(config.Policies, spec)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate policy: %w", err)
+		}
+		if len(violations) > 0 {
+			recordEvent(metrics.Event{Type: metrics.EventSessionFailure, Agent: agentName})
+			return &policy.ViolationError{Violations: violations}
+		}
+	}
+
+	// Canaries go in before anything in the container can run
+	if config.Integrity {
+		if err := plantCanaries(config, args, homeDir, containerName); err != nil {
+			return err
+		}
+	}
+
 	// Step 9: Start container in background
 	if config.Verbose {
 		fmt.Fprintf(os.Stderr, "Starting container %s\n", containerName)
-		fmt.Fprintf(os.Stderr, "Full command: docker %v\n", args)
+		fmt.Fprintf(os.Stderr, "Full command: docker %v\n", redact.FromEnv(config.DefaultEnvVars, os.Getenv).Args(args))
 	}
 
-	containerID, err := dockerClient.Run(args...)
+	// A failed start can leave the named container behind; clear it so the retry doesn't conflict
+	startBackoff := docker.DefaultBackoff
+	startBackoff.BeforeRetry = func() { _, _ = dockerClient.Run("rm", "-f", containerName) }
+	containerID, err := dockerClient.RunWithRetry(startBackoff, args...)
 	if err != nil {
+		recordEvent(metrics.Event{Type: metrics.EventSessionFailure, Agent: agentName})
 		return fmt.Errorf("failed to start container: %w\nDocker output:\n%s", err, containerID)
 	}
+	recordEvent(metrics.Event{Type: metrics.EventSessionStart, Agent: agentName})
+	started = true
+	if config.NetworkWindow != nil {
+		// Fail closed: a window nobody will close must not leave the network open
+		if err := startNetworkWindow(containerName, dockerClient.Command(), config.NetworkWindow, windowStart.Add(config.NetworkWindow.OpenFor), config.Verbose); err != nil {
+			_, _ = dockerClient.Run("rm", "-f", containerName)
+			return err
+		}
+	}
+	if len(githubArgs) > 0 {
+		if err := publishGitHubToken(dockerClient, containerName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; git in the session can't authenticate to GitHub until the token is refreshed\n", err)
+		}
+		if err := startGitHubTokenRefresher(containerName, dockerClient.Command(), config.Verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; the GitHub token expires in an hour and will be revoked by 'packnplay stop'\n", err)
+		}
+	}
+	if len(burnerEnv) > 0 {
+		if err := startBurnerRevoker(containerName, dockerClient.Command(), config.Verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; keys will be revoked by 'packnplay stop' or the next run\n", err)
+		}
+	}
+	if config.LAN {
+		if err := startMDNSPublisher(containerName, dockerClient.Command(), projectName, agentName, config.PublishPorts, config.Verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; published ports are only reachable by address\n", err)
+		}
+	}
 	containerID = strings.TrimSpace(containerID)
 
+	if tmpfsHome {
+		if config.Verbose {
+			fmt.Fprintf(os.Stderr, "Populating tmpfs home from %s\n", imageName)
+		}
+		// An empty home loses the image's dotfiles and tool setup, so don't start the session without it
+		if err := seedHome(dockerClient, imageName, containerID, containerHomeDir, devConfig.RemoteUser, mountedUnder(args, containerHomeDir)); err != nil {
+			_, _ = dockerClient.Run("rm", "-f", containerID)
+			return fmt.Errorf("failed to populate home directory (set \"home\": {\"persistent\": true} to use the image's home as-is): %w", err)
+		}
+	}
+
+	// Agents still need to write next to their mounted credential files (sessions, logs)
+	if len(fileMountDirs) > 0 {
+		chownArgs := append([]string{"exec", "-u", "root", containerID, "chown", fmt.Sprintf("%s:%s", devConfig.RemoteUser, devConfig.RemoteUser)}, fileMountDirs...)
+		if output, err := dockerClient.Run(chownArgs...); err != nil && config.Verbose {
+			fmt.Fprintf(os.Stderr, "Warning: failed to fix agent config dir ownership: %v\n%s", err, output)
+		}
+	}
+
+	// New cache volumes are root-owned; network shares may refuse, which only matters if they aren't writable
+	if len(cacheTargets) > 0 {
+		chownArgs := append([]string{"exec", "-u", "root", containerID, "chown", fmt.Sprintf("%s:%s", devConfig.RemoteUser, devConfig.RemoteUser)}, cacheTargets...)
+		if output, err := dockerClient.Run(chownArgs...); err != nil && config.Verbose {
+			fmt.Fprintf(os.Stderr, "Warning: failed to fix cache volume ownership: %v\n%s", err, output)
+		}
+	}
+
+	// The system trust store is on the read-only root; NODE_EXTRA_CA_CERTS and friends still apply
+	if config.DevCerts.Enabled && !config.ReadOnlyRoot {
+		trustDevCA(dockerClient, containerID)
+	}
+
+	if err := startDisplaySidecar(dockerClient, config.Display, containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := startTailnetSidecar(dockerClient, config.Tailnet, containerName, config.Verbose); err != nil {
+		return err
+	}
+
+	// The agent mustn't start on an empty workspace, and a session nothing writes back is useless
+	if syncWorkspace {
+		if err := writeback.Seed(dockerClient, containerName, mountPath, devConfig.RemoteUser); err != nil {
+			_, _ = dockerClient.Run("rm", "-f", containerName)
+			_, _ = dockerClient.Run("volume", "rm", "-f", writeback.VolumeName(containerName))
+			_ = writeback.Forget(containerName)
+			return err
+		}
+		if err := startWriteback(containerName, dockerClient.Command(), mountPath, config.Verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; edits will be written back by 'packnplay stop'\n", err)
+		}
+	}
+	if splitWorkspace {
+		if err := startSessionSnapshots(containerName, dockerClient.Command(), config.Verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; the agent's work will be committed by 'packnplay stop'\n", err)
+		}
+	}
+	// Summaries are of the recording, so only recorded sessions get them
+	if config.Summaries.Enabled && config.Record {
+		if err := startTranscriptSummaries(config.Summaries, containerName, dockerClient.Command(), agentName, recordingRedactEnv(config), config.Verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; this session won't be summarized\n", err)
+		}
+	}
+
 	// Step 10: Copy config files into container
 
 	// Copy ~/.claude.json
-	claudeConfigSrc := filepath.Join(homeDir, ".claude.json")
+	if copyPath, ok := sanitized[claudeConfigSrc]; ok {
+		claudeConfigSrc = copyPath
+	}
 	if _, err := os.Stat(claudeConfigSrc); err == nil {
-		if err := copyFileToContainer(dockerClient, containerID, claudeConfigSrc, fmt.Sprintf("/home/%s/.claude.json", devConfig.RemoteUser), devConfig.RemoteUser, config.Verbose); err != nil {
+		claudeConfigDst := fmt.Sprintf("%s/.claude.json", containerHomeDir)
+		if tmpfsHome {
+			err = streamFileToContainer(dockerClient, containerID, claudeConfigSrc, claudeConfigDst, devConfig.RemoteUser)
+		} else {
+			err = copyFileToContainer(dockerClient, containerID, claudeConfigSrc, claudeConfigDst, devConfig.RemoteUser, config.Verbose)
+		}
+		if err != nil {
 			_, _ = dockerClient.Run("rm", "-f", containerID)
 			return fmt.Errorf("failed to copy .claude.json: %w", err)
 		}
@@ -467,29 +1220,122 @@ To stop the existing container:
 			fmt.Fprintf(os.Stderr, "Copying container credentials into .claude directory...\n")
 		}
 		// Copy from mounted temp location to .claude directory
-		_, err = dockerClient.Run("exec", containerID, "cp", "/tmp/packnplay-credentials.json", fmt.Sprintf("/home/%s/.claude/.credentials.json", devConfig.RemoteUser))
+		_, err = dockerClient.Run("exec", containerID, "cp", "/tmp/packnplay-credentials.json", fmt.Sprintf("%s/.claude/.credentials.json", containerHomeDir))
 		if err != nil && config.Verbose {
 			fmt.Fprintf(os.Stderr, "Warning: failed to copy credentials: %v\n", err)
 		}
 	}
 
+	if err := session.Transition(containerName, session.StateRunning); err != nil {
+		return err
+	}
+	provisioned = true
+
 	// Step 11: Exec into container with user's command
-	cmdPath, err := exec.LookPath(dockerClient.Command())
+	execArgs := []string{"exec", "-w", workingDir}
+	execArgs = append(execArgs, execIOFlags(config)...)
+	execArgs = append(execArgs, TerminalEnvArgs()...)
+	execArgs = append(execArgs, containerID)
+	execArgs = append(execArgs, sessionenv.Wrap(nixenv.Wrap(nixKind, config.Command))...)
+
+	if config.PairAgent != "" {
+		return execPair(dockerClient, config, containerID, containerName, workingDir, nixKind)
+	}
+	return execSession(dockerClient, config, execArgs, containerName)
+}
+
+// reconnect runs the session's command in an already running container, in the
+// project's dev shell if it has one
+func reconnect(config *RunConfig, dockerClient *docker.Client, containerName, workingDir, nixKind string) error {
+	if err := enforceExpiredWindow(dockerClient, containerName); err != nil {
+		return err
+	}
+
+	if state, err := containerState(dockerClient, containerName); err == nil && state == "paused" {
+		return fmt.Errorf("%s is paused; continue it with 'packnplay resume %s'", containerName, containerName)
+	}
+
+	// Return to the agent left running by detaching, rather than starting another
+	if config.DetachKeys != "" && !config.Headless && config.PairAgent == "" {
+		if socketPath, ok := heldTerminal(containerName); ok {
+			return attachTerminal(config, socketPath, containerName, 0)
+		}
+	}
+
+	// Get container ID
+	containerID, err := getContainerID(dockerClient, containerName)
+	if err != nil {
+		return fmt.Errorf("failed to get container ID: %w", err)
+	}
+
+	// Exec into existing container
+	execArgs := []string{"exec", "-w", workingDir}
+	execArgs = append(execArgs, execIOFlags(config)...)
+	execArgs = append(execArgs, TerminalEnvArgs()...)
+	execArgs = append(execArgs, containerID)
+	execArgs = append(execArgs, sessionenv.Wrap(nixenv.Wrap(nixKind, config.Command))...)
+
+	if config.PairAgent != "" {
+		return execPair(dockerClient, config, containerID, containerName, workingDir, nixKind)
+	}
+	return execSession(dockerClient, config, execArgs, containerName)
+}
+
+// execIOFlags connects the session's command: interactive sessions get a terminal, while
+// headless ones pass output through untouched and only read stdin when it's their input
+func execIOFlags(config *RunConfig) []string {
+	switch {
+	case !config.Headless:
+		return []string{"-it"}
+	case config.PipeStdin:
+		return []string{"-i"}
+	}
+	return nil
+}
+
+// execSession hands the terminal to the session's docker exec, given its args
+// Without recording, packnplay replaces itself with it. With recording, it stays in
+// between to capture the output, then exits with the command's status. With detach keys,
+// a holder process owns the terminal instead, so detaching leaves the command running.
+func execSession(dockerClient *docker.Client, config *RunConfig, execArgs []string, containerName string) error {
+	if config.DetachKeys != "" && !config.Headless {
+		cmdPath, err := exec.LookPath(dockerClient.Command())
+		if err != nil {
+			return fmt.Errorf("failed to find docker command: %w", err)
+		}
+		return holdSession(config, cmdPath, execArgs, containerName)
+	}
+	if !config.Record {
+		return dockerClient.Handoff(execArgs...)
+	}
+
+	path, err := recording.NewPath(containerName, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to find docker command: %w", err)
+		return err
+	}
+	if config.Verbose {
+		fmt.Fprintf(os.Stderr, "Recording session to %s\n", path)
 	}
 
-	execArgs := []string{
-		filepath.Base(cmdPath),
-		"exec",
-		"-it",
-		"-w", workingDir,
-		containerID,
+	redactor := redact.FromEnv(recordingRedactEnv(config), os.Getenv)
+	code, err := recording.Run(path, containerName, dockerClient.Stream(execArgs...), redactor)
+	if err != nil {
+		return err
 	}
-	execArgs = append(execArgs, config.Command...)
+	os.Exit(code)
+	return nil
+}
 
-	// Use syscall.Exec to replace current process
-	return syscall.Exec(cmdPath, execArgs, os.Environ())
+// recordingRedactEnv names the host variables whose values are masked in recordings: the
+// forwarded API keys and the variables passed through with --env KEY
+func recordingRedactEnv(config *RunConfig) []string {
+	names := append([]string{}, config.DefaultEnvVars...)
+	for _, env := range config.Env {
+		if !strings.Contains(env, "=") {
+			names = append(names, env)
+		}
+	}
+	return names
 }
 
 func ensureImage(dockerClient *docker.Client, config *devcontainer.Config, projectPath string, verbose bool) error {
@@ -511,7 +1357,9 @@ func ensureImage(dockerClient *docker.Client, config *devcontainer.Config, proje
 			dockerfilePath := filepath.Join(projectPath, ".devcontainer", config.DockerFile)
 			contextPath := filepath.Join(projectPath, ".devcontainer")
 
-			output, err := dockerClient.Run("build", "-f", dockerfilePath, "-t", imageName, contextPath)
+			started := time.Now()
+			output, err := dockerClient.RunWithRetry(docker.DefaultBackoff, "build", "-f", dockerfilePath, "-t", imageName, contextPath)
+			recordEvent(metrics.Event{Type: metrics.EventImagePull, Image: imageName, Seconds: time.Since(started).Seconds()})
 			if err != nil {
 				return fmt.Errorf("failed to build image from %s: %w\nDocker output:\n%s", config.DockerFile, err, output)
 			}
@@ -528,7 +1376,9 @@ func ensureImage(dockerClient *docker.Client, config *devcontainer.Config, proje
 				fmt.Fprintf(os.Stderr, "Pulling image %s\n", imageName)
 			}
 
-			output, err := dockerClient.Run("pull", imageName)
+			started := time.Now()
+			output, err := dockerClient.RunWithRetry(docker.DefaultBackoff, "pull", imageName)
+			recordEvent(metrics.Event{Type: metrics.EventImagePull, Image: imageName, Seconds: time.Since(started).Seconds()})
 			if err != nil {
 				return fmt.Errorf("failed to pull image %s: %w\nDocker output:\n%s", imageName, err, output)
 			}
@@ -538,6 +1388,55 @@ func ensureImage(dockerClient *docker.Client, config *devcontainer.Config, proje
 	return nil
 }
 
+// shellJoin quotes argv so it can be pasted into a shell
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./:@,+%") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ensureSetupImage returns the setup layer for the current lockfiles, building it if needed
+// The command runs with runArgs, e.g. the Nix store it builds the dev shell into.
+func ensureSetupImage(dockerClient *docker.Client, baseImage, user string, command []string, projectName, projectPath string, runArgs []string, verbose bool) (string, error) {
+	baseID, err := dockerClient.Run("image", "inspect", "--format", "{{.Id}}", baseImage)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", baseImage, err)
+	}
+
+	key, err := imagebuild.SetupKey(strings.TrimSpace(baseID), user, command, projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	if tag := imagebuild.FindSetupImage(dockerClient, projectName, key); tag != "" {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Reusing setup layer %s\n", tag)
+		}
+		return tag, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Running postCreateCommand (cached until lockfiles change)...\n")
+	started := time.Now()
+	tag, err := imagebuild.BuildSetupImage(dockerClient, baseImage, user, projectName, key, projectPath, command, runArgs...)
+	if err != nil {
+		return "", err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Built setup layer %s in %s\n", tag, time.Since(started).Round(time.Second))
+	}
+
+	if removed := imagebuild.PruneSetupImages(dockerClient, projectName, key); removed > 0 && verbose {
+		fmt.Fprintf(os.Stderr, "Removed %d outdated setup layers\n", removed)
+	}
+	return tag, nil
+}
+
 func containerIsRunning(dockerClient *docker.Client, name string) (bool, error) {
 	// Apple Container doesn't support --filter, so get all and filter client-side
 	isApple := dockerClient.Command() == "container"
@@ -613,6 +1512,24 @@ func getContainerID(dockerClient *docker.Client, name string) (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
+// mountArg converts an agent mount to a docker -v value
+func mountArg(mount agents.Mount) string {
+	arg := fmt.Sprintf("%s:%s", mount.HostPath, mount.ContainerPath)
+	if mount.ReadOnly {
+		arg += ":ro"
+	}
+	return arg
+}
+
+// launchedAgents names the agents a session runs: its own, and its partner when pairing
+func launchedAgents(agentName, pairAgent string) map[string]bool {
+	launched := map[string]bool{agentName: true}
+	if pairAgent != "" {
+		launched[pairAgent] = true
+	}
+	return launched
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
@@ -5,7 +5,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/obra/packnplay/pkg/agents"
 	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
 )
 
 func TestGetOrCreateContainerCredentialFile(t *testing.T) {
@@ -114,4 +116,57 @@ func TestRunConfig(t *testing.T) {
 	if len(cfg.DefaultEnvVars) != 1 || cfg.DefaultEnvVars[0] != "ANTHROPIC_API_KEY" {
 		t.Errorf("RunConfig.DefaultEnvVars = %v, want [ANTHROPIC_API_KEY]", cfg.DefaultEnvVars)
 	}
-}
\ No newline at end of file
+}
+func TestMountArg(t *testing.T) {
+	tests := []struct {
+		name  string
+		mount agents.Mount
+		want  string
+	}{
+		{
+			name:  "read-write directory",
+			mount: agents.Mount{HostPath: "/home/test/.codex", ContainerPath: "/home/vscode/.codex"},
+			want:  "/home/test/.codex:/home/vscode/.codex",
+		},
+		{
+			name:  "read-only file",
+			mount: agents.Mount{HostPath: "/host/sa.json", ContainerPath: "/home/vscode/sa.json", ReadOnly: true},
+			want:  "/host/sa.json:/home/vscode/sa.json:ro",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mountArg(tt.mount); got != tt.want {
+				t.Errorf("mountArg() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin([]string{"claude", "--model", "opus", "-p", "fix the 'auth' bug", ""})
+	want := `claude --model opus -p 'fix the '\''auth'\'' bug' ''`
+	if got != want {
+		t.Errorf("shellJoin() = %s, want %s", got, want)
+	}
+}
+
+func TestReconnectHandsOff(t *testing.T) {
+	for _, key := range []string{"COLORTERM", "TERM_PROGRAM", "TERM_PROGRAM_VERSION", "LANG", "LANGUAGE", "LC_ALL", "LC_CTYPE"} {
+		t.Setenv(key, "")
+	}
+	replayer, err := docker.NewReplayer(filepath.Join("testdata", "runtime", "run.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The session's exec replaces packnplay, so the runtime is handed the terminal last
+	config := &RunConfig{Command: []string{"claude"}}
+	if err := reconnect(config, replayer.Client(), "packnplay-app-main", "/workspace", ""); err != nil {
+		t.Fatalf("reconnect() error = %v", err)
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Error(err)
+	}
+}
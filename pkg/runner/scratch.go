@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/agents"
+)
+
+// scratchConfigRoot holds the config dirs made for a session's agents when the host has none
+func scratchConfigRoot(containerName string) string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "scratch-config", containerName)
+}
+
+// agentConfigSource returns the host directory to mount as agent's config dir: the host's
+// own, or on a host without one (a fresh machine, CI) a scratch dir seeded from the
+// agent's template, so the agent has somewhere to write instead of a root-owned stand-in
+// the runtime made up. Scratch dirs last until the session is torn down.
+func agentConfigSource(containerName string, agent agents.Agent, homeDir string, templates map[string]string, verbose bool) (string, error) {
+	hostDir := filepath.Join(homeDir, agent.ConfigDir())
+	if fileExists(hostDir) {
+		return hostDir, nil
+	}
+
+	dir := filepath.Join(scratchConfigRoot(containerName), agent.Name())
+	if fileExists(dir) {
+		return dir, nil // reconnecting: keep what the agent wrote
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create scratch config for %s: %w", agent.Name(), err)
+	}
+	template := templates[agent.Name()]
+	if template != "" {
+		if rest, ok := strings.CutPrefix(template, "~/"); ok {
+			template = filepath.Join(homeDir, rest)
+		}
+		if err := seedConfig(template, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to seed %s config from %s: %w", agent.Name(), template, err)
+		}
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "No ~/%s on this host; %s gets a scratch config dir for this session\n", agent.ConfigDir(), agent.Name())
+	}
+	return dir, nil
+}
+
+// seedConfig copies a template directory's files into dst, keeping their modes so
+// e.g. a credentials file stays private. Symlinks are skipped, so nothing outside is pulled in.
+func seedConfig(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// forgetScratchConfig removes a session's scratch config dirs
+func forgetScratchConfig(containerName string) error {
+	if err := os.RemoveAll(scratchConfigRoot(containerName)); err != nil {
+		return fmt.Errorf("failed to remove scratch config: %w", err)
+	}
+	return nil
+}
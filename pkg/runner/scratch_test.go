@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/agents"
+)
+
+func TestAgentConfigSource(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	home := t.TempDir()
+	template := filepath.Join(t.TempDir(), "codex-template")
+	os.MkdirAll(filepath.Join(template, "prompts"), 0755)
+	os.WriteFile(filepath.Join(template, "config.toml"), []byte("model = \"o3\"\n"), 0600)
+	os.WriteFile(filepath.Join(template, "prompts", "review.md"), []byte("review\n"), 0644)
+	templates := map[string]string{"codex": template}
+
+	// The host's own config dir wins
+	os.MkdirAll(filepath.Join(home, ".claude"), 0755)
+	if dir, err := agentConfigSource("packnplay-app-main", &agents.ClaudeAgent{}, home, templates, false); err != nil || dir != filepath.Join(home, ".claude") {
+		t.Errorf("agentConfigSource(claude) = %s, %v; want the host's", dir, err)
+	}
+
+	dir, err := agentConfigSource("packnplay-app-main", &agents.CodexAgent{}, home, templates, false)
+	if err != nil {
+		t.Fatalf("agentConfigSource(codex) error = %v", err)
+	}
+	if dir != filepath.Join(scratchConfigRoot("packnplay-app-main"), "codex") {
+		t.Errorf("agentConfigSource(codex) = %s, want a scratch dir", dir)
+	}
+	info, err := os.Stat(filepath.Join(dir, "config.toml"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("seeded config.toml: %v, %v; want it private", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prompts", "review.md")); err != nil {
+		t.Errorf("template subdirectory not seeded: %v", err)
+	}
+
+	// Reattaching keeps what the agent wrote rather than seeding again
+	os.WriteFile(filepath.Join(dir, "history.jsonl"), []byte("{}\n"), 0644)
+	if again, _ := agentConfigSource("packnplay-app-main", &agents.CodexAgent{}, home, templates, false); again != dir {
+		t.Errorf("agentConfigSource() on reconnect = %s, want %s", again, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "history.jsonl")); err != nil {
+		t.Error("reconnecting discarded the scratch dir's contents")
+	}
+
+	if err := forgetScratchConfig("packnplay-app-main"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("scratch dir survived teardown: %v", err)
+	}
+
+	if _, err := agentConfigSource("packnplay-app-main", &agents.CodexAgent{}, home, map[string]string{"codex": "/nonexistent"}, false); err == nil {
+		t.Error("agentConfigSource() should fail on a missing template")
+	}
+}
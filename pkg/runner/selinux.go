@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mount kinds that only take an SELinux label
+const (
+	MountKindCredentials = "credentials" // ~/.ssh, ~/.gnupg, ~/.gitconfig, gh config, ~/.npmrc
+	MountKindReadOnly    = "read_only"   // project-declared read_only_mounts
+)
+
+// SELinux label options for bind mounts
+const (
+	labelShared  = "z"    // relabel so any container can use the content
+	labelPrivate = "Z"    // relabel for this container only
+	labelNone    = "none" // leave the host label alone
+)
+
+// selinuxEnforcePath reads "1" when the host enforces SELinux
+var selinuxEnforcePath = "/sys/fs/selinux/enforce"
+
+// selinuxEnforcing reports whether the host enforces SELinux
+func selinuxEnforcing() bool {
+	data, err := os.ReadFile(selinuxEnforcePath)
+	return err == nil && strings.TrimSpace(string(data)) == "1"
+}
+
+// ValidateSELinuxLabels checks a selinux_labels config map
+func ValidateSELinuxLabels(labels map[string]string) error {
+	kinds := map[string]bool{MountKindWorkspace: true, MountKindGit: true, MountKindAgents: true, MountKindHome: true, MountKindCredentials: true, MountKindReadOnly: true}
+	for kind, label := range labels {
+		if !kinds[kind] {
+			return fmt.Errorf("unknown selinux_labels mount '%s' (use workspace, git, agents, home, credentials or read_only)", kind)
+		}
+		if label != labelShared && label != labelPrivate && label != labelNone {
+			return fmt.Errorf("invalid selinux_labels option '%s' for %s (use z, Z or none)", label, kind)
+		}
+	}
+	return nil
+}
+
+// resolveSELinuxLabels decides the relabeling option for each mount kind
+// On an enforcing host, the workspace, git, agent and home mounts default to z, without
+// which the container can't read them. Credentials and read-only mounts are left alone
+// unless configured: relabeling ~/.ssh would lock the host's own sshd out of it.
+func resolveSELinuxLabels(configured map[string]string, enforcing bool) map[string]string {
+	labels := make(map[string]string)
+	if enforcing {
+		for _, kind := range []string{MountKindWorkspace, MountKindGit, MountKindAgents, MountKindHome} {
+			labels[kind] = labelShared
+		}
+	}
+	for kind, label := range configured {
+		labels[kind] = label
+	}
+	for kind, label := range labels {
+		if label == labelNone {
+			delete(labels, kind)
+		}
+	}
+	return labels
+}
+
+// labelStateMounts adds z to unlabeled -v mounts of packnplay's own files under dataDir
+// (session env, tokens, sanitized configs), which nothing outside packnplay reads.
+func labelStateMounts(args []string, dataDir string) {
+	for i := 1; i < len(args); i++ {
+		if args[i-1] != "-v" {
+			continue
+		}
+		parts := strings.Split(args[i], ":")
+		if len(parts) < 2 || !isWithin(filepath.Clean(parts[0]), dataDir) {
+			continue
+		}
+		if len(parts) > 2 && hasLabel(parts[2]) {
+			continue
+		}
+		args[i] = withMountOption(args[i], labelShared)
+	}
+}
+
+// hasLabel reports whether a -v option list already relabels the mount
+func hasLabel(options string) bool {
+	for _, option := range strings.Split(options, ",") {
+		if option == labelShared || option == labelPrivate {
+			return true
+		}
+	}
+	return false
+}
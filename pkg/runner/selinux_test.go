@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSELinuxEnforcing(t *testing.T) {
+	orig := selinuxEnforcePath
+	defer func() { selinuxEnforcePath = orig }()
+
+	selinuxEnforcePath = filepath.Join(t.TempDir(), "enforce")
+	if selinuxEnforcing() {
+		t.Error("selinuxEnforcing() = true without selinuxfs")
+	}
+	os.WriteFile(selinuxEnforcePath, []byte("0"), 0644)
+	if selinuxEnforcing() {
+		t.Error("selinuxEnforcing() = true in permissive mode")
+	}
+	os.WriteFile(selinuxEnforcePath, []byte("1"), 0644)
+	if !selinuxEnforcing() {
+		t.Error("selinuxEnforcing() = false when enforcing")
+	}
+}
+
+func TestResolveSELinuxLabels(t *testing.T) {
+	if labels := resolveSELinuxLabels(nil, false); len(labels) != 0 {
+		t.Errorf("resolveSELinuxLabels() without SELinux = %v, want none", labels)
+	}
+
+	labels := resolveSELinuxLabels(nil, true)
+	want := map[string]string{MountKindWorkspace: "z", MountKindGit: "z", MountKindAgents: "z", MountKindHome: "z"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("resolveSELinuxLabels() enforcing = %v, want %v", labels, want)
+	}
+
+	labels = resolveSELinuxLabels(map[string]string{MountKindAgents: "none", MountKindCredentials: "z", MountKindWorkspace: "Z"}, true)
+	want = map[string]string{MountKindWorkspace: "Z", MountKindGit: "z", MountKindHome: "z", MountKindCredentials: "z"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("resolveSELinuxLabels() = %v, want %v", labels, want)
+	}
+
+	// Explicit labels apply even where packnplay can't tell SELinux is on, e.g. a remote daemon
+	if labels := resolveSELinuxLabels(map[string]string{MountKindWorkspace: "z"}, false); labels[MountKindWorkspace] != "z" {
+		t.Errorf("resolveSELinuxLabels() = %v, want configured workspace label", labels)
+	}
+}
+
+func TestValidateSELinuxLabels(t *testing.T) {
+	if err := ValidateSELinuxLabels(map[string]string{MountKindWorkspace: "z", MountKindReadOnly: "Z", MountKindAgents: "none"}); err != nil {
+		t.Errorf("ValidateSELinuxLabels() error = %v", err)
+	}
+	if err := ValidateSELinuxLabels(map[string]string{"caches": "z"}); err == nil {
+		t.Error("ValidateSELinuxLabels() accepted unknown mount kind")
+	}
+	if err := ValidateSELinuxLabels(map[string]string{MountKindWorkspace: "shared"}); err == nil {
+		t.Error("ValidateSELinuxLabels() accepted unknown option")
+	}
+}
+
+func TestLabelStateMounts(t *testing.T) {
+	args := []string{
+		"-v", "/home/u/.local/share/packnplay/env/c:/run/packnplay-env:ro",
+		"-v", "/home/u/.local/share/packnplay/homes/c/.cache:/home/vscode/.cache:Z",
+		"-v", "/home/u/src/app:/workspace",
+		"-v", "packnplay-cache-npm:/home/vscode/.npm",
+		"-e", "HOME=/home/vscode",
+	}
+	labelStateMounts(args, "/home/u/.local/share/packnplay")
+	want := []string{
+		"-v", "/home/u/.local/share/packnplay/env/c:/run/packnplay-env:ro,z",
+		"-v", "/home/u/.local/share/packnplay/homes/c/.cache:/home/vscode/.cache:Z",
+		"-v", "/home/u/src/app:/workspace",
+		"-v", "packnplay-cache-npm:/home/vscode/.npm",
+		"-e", "HOME=/home/vscode",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("labelStateMounts() = %v\nwant %v", args, want)
+	}
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/imagebuild"
+)
+
+// ensureShellImage layers the configured shell, prompt and utilities onto baseImage,
+// reusing the layer until the base image or the shell config changes.
+// It returns baseImage unchanged when no shell setup is configured.
+func ensureShellImage(dockerClient *docker.Client, baseImage, user, projectName string, shell config.ShellConfig, verbose bool) (string, error) {
+	if shell.Empty() {
+		return baseImage, nil
+	}
+	if dockerClient.Command() == "container" {
+		fmt.Fprintf(os.Stderr, "Warning: shell setup is not supported with Apple Container; skipping\n")
+		return baseImage, nil
+	}
+
+	baseID, err := dockerClient.Run("image", "inspect", "--format", "{{.Id}}", baseImage)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", baseImage, err)
+	}
+	key := imagebuild.ShellKey(strings.TrimSpace(baseID), user, shell)
+
+	if tag := imagebuild.FindShellImage(dockerClient, projectName, key); tag != "" {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Reusing shell layer %s\n", tag)
+		}
+		return tag, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Setting up the sandbox shell (cached until the config changes)...\n")
+	started := time.Now()
+	tag, err := imagebuild.BuildShellImage(dockerClient, baseImage, user, projectName, key, shell)
+	if err != nil {
+		return "", err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Built shell layer %s in %s\n", tag, time.Since(started).Round(time.Second))
+	}
+
+	if removed := imagebuild.PruneShellImages(dockerClient, projectName, key); removed > 0 && verbose {
+		fmt.Fprintf(os.Stderr, "Removed %d outdated shell layers\n", removed)
+	}
+	return tag, nil
+}
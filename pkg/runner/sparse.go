@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/git"
+)
+
+// sparseWorkingDir picks the container directory a session starts in. Outside sparse
+// checkouts it's always /workspace; in one, it's where packnplay was run if that's
+// materialized, or the only directory checked out, so the agent starts among real code.
+func sparseWorkingDir(mountPath, subDir string, sparse *git.Sparse) string {
+	if sparse == nil || len(sparse.Patterns) == 0 {
+		return "/workspace"
+	}
+	dir := subDir
+	if dir == "" && sparse.Cone && len(sparse.Patterns) == 1 {
+		dir = strings.Trim(sparse.Patterns[0], "/")
+	}
+	if dir == "" || !fileExists(filepath.Join(mountPath, dir)) {
+		return "/workspace"
+	}
+	return path.Join("/workspace", filepath.ToSlash(dir))
+}
+
+// describeSparse tells the user how much of the repository the session can see
+func describeSparse(sparse *git.Sparse) {
+	if len(sparse.Patterns) > 0 {
+		fmt.Fprintf(os.Stderr, "Sparse checkout: only %s materialized (allow the sparse-add broker action to let the agent ask for more)\n", strings.Join(sparse.Patterns, ", "))
+	}
+	if sparse.Partial {
+		fmt.Fprintf(os.Stderr, "Partial clone: missing objects are fetched from the remote when needed\n")
+	}
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/git"
+)
+
+func TestSparseWorkingDir(t *testing.T) {
+	mountPath := t.TempDir()
+	os.MkdirAll(filepath.Join(mountPath, "services", "api"), 0755)
+
+	cone := &git.Sparse{Patterns: []string{"services/api"}, Cone: true}
+	tests := []struct {
+		name   string
+		subDir string
+		sparse *git.Sparse
+		want   string
+	}{
+		{"not sparse", "services/api", nil, "/workspace"},
+		{"partial clone only", "services/api", &git.Sparse{Partial: true}, "/workspace"},
+		{"run from a materialized dir", "services", cone, "/workspace/services"},
+		{"run from the root of one cone", "", cone, "/workspace/services/api"},
+		{"run from a dir that isn't materialized", "web", cone, "/workspace"},
+		{"several cones", "", &git.Sparse{Patterns: []string{"services/api", "web"}, Cone: true}, "/workspace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sparseWorkingDir(mountPath, tt.subDir, tt.sparse); got != tt.want {
+				t.Errorf("sparseWorkingDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
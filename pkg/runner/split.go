@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+)
+
+// splitWorkspaceArgs starts the session branch and mounts an overlay of the host checkout
+// at /workspace, so the agent reads the checkout but everything it writes stays in the overlay.
+func splitWorkspaceArgs(dockerClient *docker.Client, containerName, mountPath, user string, meta container.Metadata, verbose bool) ([]string, error) {
+	if goruntime.GOOS != "linux" {
+		return nil, fmt.Errorf("workspace_mode split needs a Linux host, since the overlay's directories must be on the machine running containers")
+	}
+
+	volume := sessionbranch.VolumeName(containerName)
+	_, _ = dockerClient.Run("volume", "rm", "-f", volume)
+
+	labels := container.MetadataLabels(meta)
+	labels["managed-by"] = "packnplay"
+	createArgs, err := sessionbranch.VolumeCreateArgs(containerName, mountPath, labels)
+	if err != nil {
+		return nil, err
+	}
+	if output, err := dockerClient.Run(createArgs...); err != nil {
+		return nil, fmt.Errorf("failed to create workspace overlay: %w\n%s", err, output)
+	}
+
+	state, err := sessionbranch.Start(mountPath, containerName, user, time.Now())
+	if err != nil {
+		_, _ = dockerClient.Run("volume", "rm", "-f", volume)
+		return nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Committing the agent's work to branch %s\n", state.Branch)
+	}
+
+	return append([]string{"-v", volume + ":/workspace"}, gitDirArgs(mountPath)...), nil
+}
+
+// startSessionSnapshots launches a detached process that commits the workspace to the session branch at idle points
+func startSessionSnapshots(containerName, runtime string, verbose bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(executable, "session-snapshot",
+		"--container", containerName,
+		"--runtime", runtime,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start session snapshots: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Snapshots of %s will be committed when the agent is idle\n", containerName)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/netpolicy"
+	"github.com/obra/packnplay/pkg/ptyproxy"
+	"github.com/obra/packnplay/pkg/session"
+)
+
+// Items a status bar can show
+const (
+	StatusSession = "session" // the container
+	StatusElapsed = "elapsed" // time since the session started
+	StatusCost    = "cost"    // elapsed time at cost_per_hour
+	StatusNetwork = "network" // the network window and blocklist
+	StatusDetach  = "detach"  // the detach and toggle keys
+)
+
+var statusItems = []string{StatusSession, StatusElapsed, StatusCost, StatusNetwork, StatusDetach}
+
+// ValidateStatusBar checks a status_bar config; its toggle keys share the terminal's
+// input with the detach keys, so neither may start the other
+func ValidateStatusBar(bar config.StatusBarConfig, detachKeys string) error {
+	if !bar.Enabled {
+		return nil
+	}
+	if detachKeys == "" {
+		return fmt.Errorf("status_bar needs detach_keys: it's drawn while attached to a held session")
+	}
+	if bar.Position != "" && bar.Position != "bottom" && bar.Position != "top" {
+		return fmt.Errorf("invalid status_bar position '%s' (use bottom or top)", bar.Position)
+	}
+	for _, item := range bar.Items {
+		if !slices.Contains(statusItems, item) {
+			return fmt.Errorf("unknown status_bar item '%s' (use %s)", item, strings.Join(statusItems, ", "))
+		}
+	}
+	if bar.CostPerHour < 0 {
+		return fmt.Errorf("status_bar cost_per_hour can't be negative")
+	}
+
+	toggle, err := ptyproxy.ParseKeys(toggleKeys(bar))
+	if err != nil {
+		return fmt.Errorf("invalid status_bar toggle_keys: %w", err)
+	}
+	detach, err := ptyproxy.ParseKeys(detachKeys)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(toggle, detach) || bytes.HasPrefix(detach, toggle) {
+		return fmt.Errorf("status_bar toggle_keys '%s' clash with detach_keys '%s'", toggleKeys(bar), detachKeys)
+	}
+	return nil
+}
+
+func toggleKeys(bar config.StatusBarConfig) string {
+	if bar.ToggleKeys == "" {
+		return ptyproxy.DefaultToggleKeys
+	}
+	return bar.ToggleKeys
+}
+
+// statusBar returns the bar to draw while attached to containerName, or nil without one
+func statusBar(config *RunConfig, containerName string) (*ptyproxy.StatusBar, error) {
+	if !config.StatusBar.Enabled {
+		return nil, nil
+	}
+	toggle, err := ptyproxy.ParseKeys(toggleKeys(config.StatusBar))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status_bar toggle_keys: %w", err)
+	}
+
+	// Reattaching shows the time since the session started, not since this attach
+	info := statusInfo{
+		Session:     containerName,
+		Started:     time.Now(),
+		CostPerHour: config.StatusBar.CostPerHour,
+		Blocked:     len(config.BlockedDomains),
+		Window:      config.NetworkWindow,
+		DetachKeys:  config.DetachKeys,
+		ToggleKeys:  toggleKeys(config.StatusBar),
+	}
+	if entries, err := session.LoadStore(); err == nil && !entries[containerName].StartedAt.IsZero() {
+		info.Started = entries[containerName].StartedAt
+	}
+
+	items := config.StatusBar.Items
+	if len(items) == 0 {
+		items = statusItems
+	}
+	return &ptyproxy.StatusBar{
+		Text:       func(width int) string { return statusText(items, info, time.Now()) },
+		Top:        config.StatusBar.Position == "top",
+		ToggleKeys: toggle,
+	}, nil
+}
+
+// statusInfo is what a status bar can say about its session
+type statusInfo struct {
+	Session     string
+	Started     time.Time
+	CostPerHour float64
+	Blocked     int               // domains the blocklists sinkhole
+	Window      *netpolicy.Window // nil when the network stays open
+	DetachKeys  string
+	ToggleKeys  string
+}
+
+// statusText renders the bar's items at now; items with nothing to say are left out
+func statusText(items []string, info statusInfo, now time.Time) string {
+	elapsed := now.Sub(info.Started)
+	var parts []string
+	for _, item := range items {
+		switch item {
+		case StatusSession:
+			parts = append(parts, info.Session)
+		case StatusElapsed:
+			parts = append(parts, formatElapsed(elapsed))
+		case StatusCost:
+			if info.CostPerHour > 0 {
+				parts = append(parts, fmt.Sprintf("~$%.2f", elapsed.Hours()*info.CostPerHour))
+			}
+		case StatusNetwork:
+			parts = append(parts, networkStatus(info, elapsed))
+		case StatusDetach:
+			parts = append(parts, fmt.Sprintf("%s detach, %s hide", info.DetachKeys, info.ToggleKeys))
+		}
+	}
+	return " " + strings.Join(parts, " │ ")
+}
+
+// networkStatus describes the session's network: open, closing, or cut off to model APIs
+func networkStatus(info statusInfo, elapsed time.Duration) string {
+	status := "net open"
+	if info.Window != nil {
+		if left := info.Window.OpenFor - elapsed; left > 0 {
+			status = "net closes in " + formatElapsed(left)
+		} else {
+			status = "net closed"
+		}
+	}
+	if info.Blocked > 0 {
+		status += fmt.Sprintf(" (%d blocked)", info.Blocked)
+	}
+	return status
+}
+
+// formatElapsed writes a duration the way a clock would, e.g. 1:02:03 or 4:05
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/netpolicy"
+)
+
+func TestStatusText(t *testing.T) {
+	started := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	info := statusInfo{
+		Session:     "packnplay-app-main",
+		Started:     started,
+		CostPerHour: 1.20,
+		Blocked:     3,
+		Window:      &netpolicy.Window{OpenFor: 2 * time.Hour},
+		DetachKeys:  "ctrl-p,ctrl-q",
+		ToggleKeys:  "ctrl-p,ctrl-b",
+	}
+
+	got := statusText(statusItems, info, started.Add(90*time.Minute+5*time.Second))
+	want := " packnplay-app-main │ 1:30:05 │ ~$1.80 │ net closes in 29:55 (3 blocked) │ ctrl-p,ctrl-q detach, ctrl-p,ctrl-b hide"
+	if got != want {
+		t.Errorf("statusText() =\n%q\nwant\n%q", got, want)
+	}
+
+	info.CostPerHour, info.Blocked = 0, 0
+	if got := statusText([]string{StatusCost, StatusNetwork}, info, started.Add(3*time.Hour)); got != " net closed" {
+		t.Errorf("statusText() = %q, want the cost left out and the network closed", got)
+	}
+}
+
+func TestValidateStatusBar(t *testing.T) {
+	valid := config.StatusBarConfig{Enabled: true, Position: "top", Items: []string{"elapsed", "cost"}}
+	if err := ValidateStatusBar(valid, "ctrl-p,ctrl-q"); err != nil {
+		t.Errorf("ValidateStatusBar() error = %v", err)
+	}
+	if err := ValidateStatusBar(config.StatusBarConfig{Position: "side"}, ""); err != nil {
+		t.Errorf("a disabled status bar isn't checked, got %v", err)
+	}
+
+	tests := []struct {
+		bar    config.StatusBarConfig
+		detach string
+		want   string
+	}{
+		{config.StatusBarConfig{Enabled: true}, "", "needs detach_keys"},
+		{config.StatusBarConfig{Enabled: true, Position: "side"}, "ctrl-p,ctrl-q", "position"},
+		{config.StatusBarConfig{Enabled: true, Items: []string{"tokens"}}, "ctrl-p,ctrl-q", "unknown status_bar item"},
+		{config.StatusBarConfig{Enabled: true, ToggleKeys: "ctrl-p"}, "ctrl-p,ctrl-q", "clash"},
+		{config.StatusBarConfig{Enabled: true, ToggleKeys: "ctrl-p,ctrl-q"}, "ctrl-p,ctrl-q", "clash"},
+	}
+	for _, tt := range tests {
+		if err := ValidateStatusBar(tt.bar, tt.detach); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateStatusBar(%+v, %q) error = %v, want %q", tt.bar, tt.detach, err, tt.want)
+		}
+	}
+}
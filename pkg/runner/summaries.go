@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/agents"
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// defaultSummaryInterval is how often a recorded session's new output is summarized
+const defaultSummaryInterval = 2 * time.Minute
+
+// startTranscriptSummaries launches a detached process that keeps a one-line summary of
+// the session's recording up to date. The values of the redactEnv variables are masked in
+// what the summarizer sees and in the summaries kept.
+func startTranscriptSummaries(settings config.SummariesConfig, containerName, runtime, agentName string, redactEnv []string, verbose bool) error {
+	interval := defaultSummaryInterval
+	if settings.Interval != "" {
+		parsed, err := time.ParseDuration(settings.Interval)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid summaries interval '%s'", settings.Interval)
+		}
+		interval = parsed
+	}
+	if len(settings.Command) == 0 {
+		if _, ok := agents.Lookup(agentName).(agents.HeadlessRunner); !ok {
+			return fmt.Errorf("summaries need an agent with a headless mode, or summaries.command")
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	cmd := exec.Command(executable, "session-summarize",
+		"--container", containerName,
+		"--runtime", runtime,
+		"--agent", agentName,
+		"--interval", interval.String(),
+	)
+	for _, name := range redactEnv {
+		cmd.Args = append(cmd.Args, "--redact-env", name)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start session summaries: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Summarizing %s every %s\n", containerName, interval)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/writeback"
+)
+
+// Workspace modes
+const (
+	WorkspaceBind   = "bind"   // /workspace is the host directory; agent writes land immediately
+	WorkspaceSync   = "sync"   // /workspace is a copy; edits are written back to the host at idle points
+	WorkspaceSplit  = "split"  // /workspace reads the host checkout; writes go to an overlay committed to a session branch
+	WorkspacePrompt = "prompt" // /workspace is the host directory; writes the write policy doesn't allow wait for the host user
+)
+
+func validateWorkspaceMode(mode string) error {
+	switch mode {
+	case "", WorkspaceBind, WorkspaceSync, WorkspaceSplit, WorkspacePrompt:
+		return nil
+	}
+	return fmt.Errorf("unknown workspace_mode '%s' (use bind, sync, split or prompt)", mode)
+}
+
+// syncWorkspaceArgs creates a fresh volume for the session's copy of the workspace and mounts it
+// The host's .git directory is bind-mounted over it, so commits and branches stay live.
+func syncWorkspaceArgs(dockerClient *docker.Client, containerName, mountPath string, meta container.Metadata) ([]string, error) {
+	volume := writeback.VolumeName(containerName)
+	_, _ = dockerClient.Run("volume", "rm", "-f", volume)
+
+	labels := container.MetadataLabels(meta)
+	labels["managed-by"] = "packnplay"
+	createArgs := append([]string{"volume", "create"}, container.LabelsToArgs(labels)...)
+	if output, err := dockerClient.Run(append(createArgs, volume)...); err != nil {
+		return nil, fmt.Errorf("failed to create workspace volume: %w\n%s", err, output)
+	}
+
+	return append([]string{"-v", volume + ":/workspace"}, gitDirArgs(mountPath)...), nil
+}
+
+// gitDirArgs bind-mounts a checkout's .git directory over a workspace that isn't the checkout
+// A worktree's .git is a file pointing at the main repository, which is mounted separately.
+func gitDirArgs(mountPath string) []string {
+	gitDir := filepath.Join(mountPath, ".git")
+	if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+		return []string{"-v", gitDir + ":/workspace/.git"}
+	}
+	return nil
+}
+
+// startWriteback launches a detached scheduler that writes the session's edits back to the host
+func startWriteback(containerName, runtime, mountPath string, verbose bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(executable, "writeback",
+		"--container", containerName,
+		"--runtime", runtime,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Detach from parent process group
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start writeback scheduler: %w", err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Edits in %s will be written back to %s when the agent is idle\n", containerName, mountPath)
+	}
+	return nil
+}
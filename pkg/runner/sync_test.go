@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/container"
+)
+
+func TestValidateWorkspaceMode(t *testing.T) {
+	for _, mode := range []string{"", WorkspaceBind, WorkspaceSync, WorkspaceSplit, WorkspacePrompt} {
+		if err := validateWorkspaceMode(mode); err != nil {
+			t.Errorf("validateWorkspaceMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := validateWorkspaceMode("overlay"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestSyncWorkspaceArgs(t *testing.T) {
+	client, log := fakeDockerForPause(t, "running")
+	project := t.TempDir()
+	os.MkdirAll(filepath.Join(project, ".git"), 0755)
+
+	args, err := syncWorkspaceArgs(client, "packnplay-app-main", project, container.Metadata{Owner: "alice"})
+	if err != nil {
+		t.Fatalf("syncWorkspaceArgs() error = %v", err)
+	}
+	want := []string{"-v", "packnplay-app-main-workspace:/workspace", "-v", filepath.Join(project, ".git") + ":/workspace/.git"}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("syncWorkspaceArgs() = %v, want %v", args, want)
+	}
+
+	got := calls(t, log)
+	if !strings.HasPrefix(got, "volume rm -f packnplay-app-main-workspace\nvolume create") ||
+		!strings.Contains(got, "packnplay-owner=alice") || !strings.HasSuffix(got, " packnplay-app-main-workspace") {
+		t.Errorf("docker calls = %q, want a stale volume removed and a labelled one created", got)
+	}
+}
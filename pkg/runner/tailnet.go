@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/secrets"
+	"github.com/obra/packnplay/pkg/tailnet"
+)
+
+// tailnetReadyTimeout is how long a network sidecar gets to join before the session fails
+const tailnetReadyTimeout = 60 * time.Second
+
+// startTailnetSidecar attaches the session to its private network and waits until it's up
+// The sidecar joins the session's network namespace; the agent mustn't start before it
+// can reach what it was promised.
+func startTailnetSidecar(dockerClient *docker.Client, settings config.TailnetConfig, containerName string, verbose bool) error {
+	if settings.Mode == "" {
+		return nil
+	}
+	_, _ = dockerClient.Run("rm", "-f", tailnet.SidecarName(containerName))
+
+	var envFile string
+	if settings.Mode == tailnet.ModeTailscale {
+		authKey, err := tailnet.AuthKey(settings, secrets.Lookup)
+		if err != nil {
+			return err
+		}
+		xdgDataHome := os.Getenv("XDG_DATA_HOME")
+		if xdgDataHome == "" {
+			homeDir, _ := os.UserHomeDir()
+			xdgDataHome = filepath.Join(homeDir, ".local", "share")
+		}
+		envFile = filepath.Join(xdgDataHome, "packnplay", "tailnet", containerName+".env")
+		if err := os.MkdirAll(filepath.Dir(envFile), 0700); err != nil {
+			return fmt.Errorf("failed to create tailnet dir: %w", err)
+		}
+		if err := os.WriteFile(envFile, []byte(tailnet.TailscaleEnv(containerName, authKey, settings.Tags)), 0600); err != nil {
+			return fmt.Errorf("failed to write tailnet env: %w", err)
+		}
+		// docker reads the file when the sidecar is created; the key needn't stay on disk
+		defer os.Remove(envFile)
+	}
+
+	if output, err := dockerClient.Run(tailnet.SidecarArgs(containerName, settings, envFile)...); err != nil {
+		return fmt.Errorf("failed to start %s sidecar: %w\n%s", settings.Mode, err, output)
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Waiting for the %s sidecar to join\n", settings.Mode)
+	}
+
+	ready := append([]string{"exec", tailnet.SidecarName(containerName)}, tailnet.ReadyCommand(settings.Mode)...)
+	deadline := time.Now().Add(tailnetReadyTimeout)
+	for {
+		output, err := dockerClient.Run(ready...)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			logs, _ := dockerClient.Run("logs", "--tail", "20", tailnet.SidecarName(containerName))
+			return fmt.Errorf("%s sidecar didn't come up within %s: %s\n%s", settings.Mode, tailnetReadyTimeout, output, logs)
+		}
+		time.Sleep(time.Second)
+	}
+	if settings.Mode == tailnet.ModeTailscale {
+		fmt.Fprintf(os.Stderr, "Joined the tailnet as %s\n", containerName)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/bazel"
+	"github.com/obra/packnplay/pkg/burner"
+	"github.com/obra/packnplay/pkg/cmdhistory"
+	"github.com/obra/packnplay/pkg/display"
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/fsguard"
+	"github.com/obra/packnplay/pkg/githubapp"
+	"github.com/obra/packnplay/pkg/gitidentity"
+	"github.com/obra/packnplay/pkg/session"
+	"github.com/obra/packnplay/pkg/sessionbranch"
+	"github.com/obra/packnplay/pkg/sessionenv"
+	"github.com/obra/packnplay/pkg/tailnet"
+	"github.com/obra/packnplay/pkg/writeback"
+)
+
+// Teardown removes a session's container and everything packnplay keeps for it
+// It only discards: saving the session's work first is the caller's job. Any step may
+// already be done, so an interrupted teardown can simply be run again.
+func Teardown(dockerClient *docker.Client, containerName string) error {
+	_, err := containerState(dockerClient, containerName)
+	exists := err == nil
+
+	// The overlay's files belong to the container user; the session image can remove them
+	var image string
+	splitWorkspace := sessionbranch.Active(containerName)
+	if splitWorkspace && exists {
+		image, _ = dockerClient.Run("inspect", "--format", "{{.Config.Image}}", containerName)
+	}
+
+	if exists {
+		_, _ = dockerClient.Run("stop", containerName)
+		if output, err := dockerClient.Run("rm", "-f", containerName); err != nil {
+			return fmt.Errorf("failed to remove container: %w\n%s", err, output)
+		}
+	}
+
+	// With the container gone nothing more can change; check nothing it shouldn't touch did
+	reportIntegrity(containerName)
+
+	// A virtual display's sidecar has nothing left to serve
+	_, _ = dockerClient.Run("rm", "-f", display.SidecarName(containerName))
+	// Without its sidecar the session's ephemeral tailnet node goes offline and is dropped
+	_, _ = dockerClient.Run("rm", "-f", tailnet.SidecarName(containerName))
+
+	if writeback.Active(containerName) {
+		if output, err := dockerClient.Run("volume", "rm", "-f", writeback.VolumeName(containerName)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove workspace volume: %v\n%s", err, output)
+		}
+		if err := writeback.Forget(containerName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	// The guard unmounts itself once it notices the container is gone; don't wait for it
+	if err := fsguard.Forget(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if splitWorkspace {
+		if output, err := dockerClient.Run("volume", "rm", "-f", sessionbranch.VolumeName(containerName)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove workspace overlay: %v\n%s", err, output)
+		}
+		if err := sessionbranch.Cleanup(dockerClient, containerName, strings.TrimSpace(image)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if err := sessionbranch.Forget(containerName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if err := burner.RevokeSession(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to revoke burner keys: %v\n", err)
+	}
+	if err := githubapp.RevokeSession(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := cmdhistory.Seal(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := sessionenv.Forget(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := gitidentity.Forget(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := bazel.Forget(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := forgetScratchConfig(containerName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	// Last, so a teardown that fails before here is retried by recovery
+	return session.Forget(containerName)
+}
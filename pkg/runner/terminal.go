@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultTerm is used when the host has no usable TERM, so TUIs don't fall back to a dumb terminal
+const defaultTerm = "xterm-256color"
+
+// terminalEnv picks the terminal and locale variables to forward from the host environment
+// Only TERM, COLORTERM, TERM_PROGRAM(_VERSION), LANG, LANGUAGE and LC_* are passed - nothing else
+func terminalEnv(environ []string) []string {
+	var env []string
+	hasTerm := false
+
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" {
+			continue
+		}
+
+		switch {
+		case key == "TERM":
+			if value == "dumb" {
+				continue
+			}
+			hasTerm = true
+		case key == "COLORTERM", key == "TERM_PROGRAM", key == "TERM_PROGRAM_VERSION":
+		case key == "LANG", key == "LANGUAGE", strings.HasPrefix(key, "LC_"):
+		default:
+			continue
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	if !hasTerm {
+		env = append(env, "TERM="+defaultTerm)
+	}
+
+	sort.Strings(env)
+	return env
+}
+
+// TerminalEnvArgs returns docker -e args carrying the current terminal and locale settings
+// Passed on every `docker exec` so reconnects pick up the attaching terminal, not the one
+// that started the container. Window size and live SIGWINCH resizes are forwarded by the
+// docker/podman CLI itself because we exec it directly with -it.
+func TerminalEnvArgs() []string {
+	var args []string
+	for _, env := range terminalEnv(os.Environ()) {
+		args = append(args, "-e", env)
+	}
+	return args
+}
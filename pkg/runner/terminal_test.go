@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerminalEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		want    []string
+	}{
+		{
+			name: "forwards terminal and locale vars only",
+			environ: []string{
+				"TERM=xterm-kitty",
+				"COLORTERM=truecolor",
+				"LANG=en_US.UTF-8",
+				"LC_TIME=de_DE.UTF-8",
+				"HOME=/home/host",
+				"ANTHROPIC_API_KEY=secret",
+			},
+			want: []string{
+				"COLORTERM=truecolor",
+				"LANG=en_US.UTF-8",
+				"LC_TIME=de_DE.UTF-8",
+				"TERM=xterm-kitty",
+			},
+		},
+		{
+			name:    "defaults TERM when missing",
+			environ: []string{"LANG=C.UTF-8"},
+			want:    []string{"LANG=C.UTF-8", "TERM=xterm-256color"},
+		},
+		{
+			name:    "replaces dumb TERM",
+			environ: []string{"TERM=dumb"},
+			want:    []string{"TERM=xterm-256color"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := terminalEnv(tt.environ)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("terminalEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/writepolicy"
+)
+
+// checkWritePolicy validates the project's write policy before a session relies on it
+// Sync-mode sessions enforce it as edits are written back and prompt-mode ones as files are
+// written; other modes only when 'packnplay verify --auto-pr' publishes, so bind-mode agents
+// are told up front.
+func checkWritePolicy(projectPath, workspaceMode string, verbose bool) error {
+	projectConfig, err := config.LoadProjectConfig(projectPath)
+	if err != nil {
+		return err
+	}
+	policy := projectConfig.Writes
+	if policy.Empty() {
+		return nil
+	}
+	if err := writepolicy.Validate(policy); err != nil {
+		return fmt.Errorf("%s writes: %w", config.ProjectConfigFile, err)
+	}
+	if verbose && workspaceMode != WorkspaceSync && workspaceMode != WorkspacePrompt {
+		fmt.Fprintf(os.Stderr, "This project has a write policy; it's enforced before 'packnplay verify --auto-pr' publishes (set workspace_mode to sync or prompt to enforce it during the session)\n")
+	}
+	return nil
+}
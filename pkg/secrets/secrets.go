@@ -0,0 +1,207 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Stores packnplay can keep its secrets in
+const (
+	StoreKeychain      = "keychain"       // the macOS login keychain
+	StoreSecretService = "secret-service" // GNOME Keyring, KWallet etc. through secret-tool
+	StoreFile          = "file"           // 0600 files, for hosts with no keychain (CI, bare servers)
+)
+
+// StoreEnv overrides which store is used, e.g. "file" on a desktop whose keyring can't be unlocked
+const StoreEnv = "PACKNPLAY_SECRET_STORE"
+
+// service names packnplay's items in the OS keychain
+const service = "packnplay"
+
+// validName keeps names usable as keychain accounts and file names alike
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Store returns the store secrets are kept in on this host
+func Store() string {
+	if store := os.Getenv(StoreEnv); store != "" {
+		return store
+	}
+	if runtime.GOOS == "darwin" {
+		return StoreKeychain
+	}
+	// secret-tool needs a session bus to reach the keyring; without one (ssh, CI) it can only fail
+	if _, err := exec.LookPath("secret-tool"); err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return StoreSecretService
+	}
+	return StoreFile
+}
+
+// ValidateName checks a secret name, e.g. OPENAI_ADMIN_KEY
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name '%s' (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// getFileDir returns where secrets are kept when the host has no keychain
+func getFileDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "secrets")
+}
+
+// Get returns a stored secret, or "" if there is none by that name
+func Get(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	switch store := Store(); store {
+	case StoreKeychain:
+		output, err := exec.Command("security", "find-generic-password", "-s", service, "-a", name, "-w").Output()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 { // errSecItemNotFound
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from the keychain: %w", name, err)
+		}
+		return strings.TrimSuffix(string(output), "\n"), nil
+
+	case StoreSecretService:
+		output, err := exec.Command("secret-tool", "lookup", "service", service, "name", name).Output()
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil // secret-tool exits 1 when there's no such item
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from the secret service: %w", name, err)
+		}
+		return string(output), nil
+
+	case StoreFile:
+		data, err := os.ReadFile(filepath.Join(getFileDir(), name))
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		return string(data), nil
+
+	default:
+		return "", fmt.Errorf("unknown secret store '%s' in $%s (available: %s, %s, %s)", store, StoreEnv, StoreKeychain, StoreSecretService, StoreFile)
+	}
+}
+
+// Set stores a secret, replacing any with the same name
+func Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	switch store := Store(); store {
+	case StoreKeychain:
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("failed to store %s in the keychain: it can't hold multi-line values", name)
+		}
+		// The command goes in on stdin, in security's interactive mode, so the value is never on
+		// a command line. -U updates the item if it's already there.
+		cmd := exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", service, name, quoteKeychainArg(value)))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to store %s in the keychain: %w: %s", name, err, strings.TrimSpace(string(output)))
+		}
+		// Interactive mode exits 0 whether or not the command worked, so check it took
+		if stored, err := Get(name); err != nil || stored != value {
+			return fmt.Errorf("failed to store %s in the keychain", name)
+		}
+		return nil
+
+	case StoreSecretService:
+		// The value goes in on stdin, so it's never on a command line
+		cmd := exec.Command("secret-tool", "store", "--label", "packnplay "+name, "service", service, "name", name)
+		cmd.Stdin = strings.NewReader(value)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to store %s in the secret service: %w: %s", name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+
+	case StoreFile:
+		dir := getFileDir()
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create secrets dir: %w", err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path+".tmp", []byte(value), 0600); err != nil {
+			return fmt.Errorf("failed to write secret %s: %w", name, err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return fmt.Errorf("failed to write secret %s: %w", name, err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown secret store '%s' in $%s (available: %s, %s, %s)", store, StoreEnv, StoreKeychain, StoreSecretService, StoreFile)
+	}
+}
+
+// quoteKeychainArg quotes a value for security's interactive mode, which splits its input
+// like a shell: double quotes group, backslashes escape
+func quoteKeychainArg(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Delete removes a stored secret; deleting one that isn't there is not an error
+func Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	switch store := Store(); store {
+	case StoreKeychain:
+		output, err := exec.Command("security", "delete-generic-password", "-s", service, "-a", name).CombinedOutput()
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 44) {
+			return fmt.Errorf("failed to delete %s from the keychain: %w: %s", name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+
+	case StoreSecretService:
+		if output, err := exec.Command("secret-tool", "clear", "service", service, "name", name).CombinedOutput(); err != nil && len(output) > 0 {
+			return fmt.Errorf("failed to delete %s from the secret service: %w: %s", name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+
+	case StoreFile:
+		if err := os.Remove(filepath.Join(getFileDir(), name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete secret %s: %w", name, err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown secret store '%s' in $%s (available: %s, %s, %s)", store, StoreEnv, StoreKeychain, StoreSecretService, StoreFile)
+	}
+}
+
+// Lookup returns the host variable name if it's set, otherwise the secret stored under it,
+// so keys like OPENAI_ADMIN_KEY can live in the keychain instead of a shell profile
+func Lookup(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	if ValidateName(name) != nil {
+		return ""
+	}
+	value, _ := Get(name)
+	return value
+}
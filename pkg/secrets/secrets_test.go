@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Setenv(StoreEnv, StoreFile)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if value, err := Get("OPENAI_ADMIN_KEY"); err != nil || value != "" {
+		t.Fatalf("Get() of a missing secret = %q, %v; want empty", value, err)
+	}
+	if err := Set("OPENAI_ADMIN_KEY", "sk-admin"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := Set("OPENAI_ADMIN_KEY", "sk-admin-2"); err != nil {
+		t.Fatalf("Set() replacing error = %v", err)
+	}
+	if value, err := Get("OPENAI_ADMIN_KEY"); err != nil || value != "sk-admin-2" {
+		t.Errorf("Get() = %q, %v; want sk-admin-2", value, err)
+	}
+
+	info, err := os.Stat(filepath.Join(getFileDir(), "OPENAI_ADMIN_KEY"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("secret file mode = %v, %v; want 0600", info, err)
+	}
+
+	if err := Delete("OPENAI_ADMIN_KEY"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := Delete("OPENAI_ADMIN_KEY"); err != nil {
+		t.Errorf("Delete() of a missing secret error = %v", err)
+	}
+	if value, _ := Get("OPENAI_ADMIN_KEY"); value != "" {
+		t.Errorf("Get() after Delete() = %q, want empty", value)
+	}
+}
+
+func TestLookupPrefersEnvironment(t *testing.T) {
+	t.Setenv(StoreEnv, StoreFile)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := Set("TS_AUTHKEY", "tskey-stored"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	t.Setenv("TS_AUTHKEY", "")
+	if got := Lookup("TS_AUTHKEY"); got != "tskey-stored" {
+		t.Errorf("Lookup() without the variable = %q, want the stored secret", got)
+	}
+	t.Setenv("TS_AUTHKEY", "tskey-env")
+	if got := Lookup("TS_AUTHKEY"); got != "tskey-env" {
+		t.Errorf("Lookup() with the variable = %q, want tskey-env", got)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"", "../key", "a/b", "key with space"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) should fail", name)
+		}
+	}
+	if err := ValidateName("github-token.packnplay-app-main"); err != nil {
+		t.Errorf("ValidateName() error = %v", err)
+	}
+	if _, err := Get("../escape"); err == nil {
+		t.Error("Get() with a path in the name should fail")
+	}
+}
+
+func TestQuoteKeychainArg(t *testing.T) {
+	tests := map[string]string{
+		"sk-plain":      `"sk-plain"`,
+		`with "quotes"`: `"with \"quotes\""`,
+		`back\slash`:    `"back\\slash"`,
+	}
+	for value, want := range tests {
+		if got := quoteKeychainArg(value); got != want {
+			t.Errorf("quoteKeychainArg(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package session
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/git"
+	"github.com/obra/packnplay/pkg/recording"
+	"github.com/obra/packnplay/pkg/tarball"
+)
+
+// archiveVersion is bumped whenever the archive layout changes incompatibly
+const archiveVersion = 1
+
+// Archive entry names
+const (
+	metadataEntry = "metadata.json"
+	bundleEntry   = "workspace.bundle"
+	patchEntry    = "uncommitted.patch"
+	untrackedDir  = "untracked"
+	workspaceDir  = "workspace"
+)
+
+// Metadata describes an exported session
+type Metadata struct {
+	Version    int       `json:"version"`
+	Container  string    `json:"container"`
+	Project    string    `json:"project"`
+	Worktree   string    `json:"worktree"`
+	Image      string    `json:"image,omitempty"`
+	Git        bool      `json:"git"`
+	Branch     string    `json:"branch,omitempty"`
+	Head       string    `json:"head,omitempty"`
+	AgentFiles []string  `json:"agentFiles,omitempty"` // agent config files, relative to home
+	Transcript []string  `json:"transcript,omitempty"` // recording file names
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// Export writes the session's workspace state, extras and metadata to outPath
+// Git workspaces are captured as a bundle of the branch plus uncommitted and untracked changes;
+// other workspaces are archived as-is. The compression format follows the extension
+// (.tar.zst or .tar.gz); a trailing .age encrypts the archive for recipients.
+func Export(workspace string, meta Metadata, extras Extras, outPath string, recipients []age.Recipient) error {
+	meta.Version = archiveVersion
+	meta.ExportedAt = time.Now().UTC()
+
+	tempDir, err := os.MkdirTemp("", "packnplay-export-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var bundlePath string
+	var patch []byte
+	var untracked []string
+
+	if git.IsGitRepo(workspace) {
+		meta.Git = true
+		if meta.Branch == "" {
+			meta.Branch, err = git.GetCurrentBranch(workspace)
+			if err != nil {
+				return fmt.Errorf("failed to get current branch: %w", err)
+			}
+		}
+		if meta.Branch == "" {
+			return fmt.Errorf("workspace %s has a detached HEAD; check out a branch before exporting", workspace)
+		}
+		if meta.Head, err = git.GetHeadCommit(workspace); err != nil {
+			return err
+		}
+
+		bundlePath = filepath.Join(tempDir, bundleEntry)
+		if err := git.CreateBundle(workspace, meta.Branch, bundlePath); err != nil {
+			return err
+		}
+		if patch, err = git.DiffAgainstHead(workspace); err != nil {
+			return err
+		}
+		if untracked, err = git.ListUntrackedFiles(workspace); err != nil {
+			return err
+		}
+	}
+
+	meta.AgentFiles = nil
+	for name := range extras.AgentFiles {
+		meta.AgentFiles = append(meta.AgentFiles, name)
+	}
+	sort.Strings(meta.AgentFiles)
+	meta.Transcript = nil
+	for _, path := range extras.Transcripts {
+		meta.Transcript = append(meta.Transcript, filepath.Base(path))
+	}
+
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outPath, err)
+	}
+	defer out.Close()
+
+	var sink io.WriteCloser = out
+	if strings.HasSuffix(outPath, encryption.Extension) {
+		if sink, err = encryption.NewWriter(out, recipients); err != nil {
+			return err
+		}
+	}
+
+	compressed, err := tarball.NewCompressedWriter(sink, strings.TrimSuffix(outPath, encryption.Extension))
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(compressed)
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := addBytes(tw, metadataEntry, metaData); err != nil {
+		return err
+	}
+
+	if meta.Git {
+		if err := addFile(tw, bundleEntry, bundlePath); err != nil {
+			return err
+		}
+		if len(patch) > 0 {
+			if err := addBytes(tw, patchEntry, patch); err != nil {
+				return err
+			}
+		}
+		for _, file := range untracked {
+			if err := addFile(tw, filepath.ToSlash(filepath.Join(untrackedDir, file)), filepath.Join(workspace, file)); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := addTree(tw, workspaceDir, workspace); err != nil {
+			return err
+		}
+	}
+	for _, name := range meta.AgentFiles {
+		if err := addFile(tw, agentsDir+"/"+name, extras.AgentFiles[name]); err != nil {
+			return err
+		}
+	}
+	// Decrypted, since the archive is encrypted for its own recipients if at all
+	for _, path := range extras.Transcripts {
+		data, err := recording.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := addBytes(tw, transcriptDir+"/"+filepath.Base(path), data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("failed to finish compression: %w", err)
+	}
+	if sink != out {
+		if err := sink.Close(); err != nil {
+			return fmt.Errorf("failed to finish encryption: %w", err)
+		}
+	}
+	return out.Close()
+}
+
+// Import restores an exported session into projectPath
+// For git sessions the branch is fetched into the project repo and checked out as a new
+// worktree at worktreePath; otherwise the workspace is extracted to worktreePath. Agent
+// config files are restored under homeDir and recordings into the recordings dir, keeping
+// any that already exist. Encrypted archives are decrypted with the first matching identity.
+func Import(archivePath, projectPath, worktreePath, homeDir string, identities []age.Identity) (*Metadata, error) {
+	tempDir, err := os.MkdirTemp("", "packnplay-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := extract(archivePath, tempDir, identities); err != nil {
+		return nil, err
+	}
+
+	meta, err := ReadMetadata(tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := restoreExtras(tempDir, homeDir); err != nil {
+		return nil, err
+	}
+
+	if !meta.Git {
+		if err := copyTree(filepath.Join(tempDir, workspaceDir), worktreePath); err != nil {
+			return nil, err
+		}
+		return meta, nil
+	}
+
+	if !git.IsGitRepo(projectPath) {
+		return nil, fmt.Errorf("session '%s' was exported from a git repository but %s is not one", meta.Container, projectPath)
+	}
+	if err := git.FetchBundle(projectPath, filepath.Join(tempDir, bundleEntry), meta.Branch); err != nil {
+		return nil, err
+	}
+	if err := git.AddWorktree(projectPath, worktreePath, meta.Branch); err != nil {
+		return nil, err
+	}
+
+	patchPath := filepath.Join(tempDir, patchEntry)
+	if _, err := os.Stat(patchPath); err == nil {
+		if err := git.ApplyPatch(worktreePath, patchPath); err != nil {
+			return nil, err
+		}
+	}
+
+	untrackedPath := filepath.Join(tempDir, untrackedDir)
+	if _, err := os.Stat(untrackedPath); err == nil {
+		if err := copyTree(untrackedPath, worktreePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return meta, nil
+}
+
+// restoreExtras puts an extracted archive's agent config files and recordings in place
+func restoreExtras(dir, homeDir string) error {
+	writeAgentFile := func(path string, data []byte) error { return os.WriteFile(path, data, 0600) }
+	restores := []struct {
+		src, dest string
+		write     func(path string, data []byte) error
+	}{
+		{filepath.Join(dir, agentsDir), homeDir, writeAgentFile},
+		{filepath.Join(dir, transcriptDir), recording.GetRecordingsDir(), recording.WriteFile},
+	}
+	for _, restore := range restores {
+		if _, err := os.Stat(restore.src); err != nil {
+			continue
+		}
+		kept, err := restoreNew(restore.src, restore.dest, restore.write)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", restore.dest, err)
+		}
+		for _, name := range kept {
+			fmt.Fprintf(os.Stderr, "Warning: keeping existing %s\n", filepath.Join(restore.dest, name))
+		}
+	}
+	return nil
+}
+
+// ReadMetadata reads metadata.json from an extracted archive directory
+func ReadMetadata(dir string) (*Metadata, error) {
+	data, err := os.ReadFile(filepath.Join(dir, metadataEntry))
+	if err != nil {
+		return nil, fmt.Errorf("archive is missing %s: %w", metadataEntry, err)
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", metadataEntry, err)
+	}
+	if meta.Version > archiveVersion {
+		return nil, fmt.Errorf("archive version %d is newer than supported version %d; upgrade packnplay", meta.Version, archiveVersion)
+	}
+	return &meta, nil
+}
+
+// ReadArchiveMetadata reads only the metadata entry of an archive without extracting it
+func ReadArchiveMetadata(archivePath string, identities []age.Identity) (*Metadata, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	r, err := openArchive(f, archivePath, identities)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("archive is missing %s", metadataEntry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Name != metadataEntry {
+			continue
+		}
+
+		var meta Metadata
+		if err := json.NewDecoder(tr).Decode(&meta); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", metadataEntry, err)
+		}
+		return &meta, nil
+	}
+}
+
+// openArchive decrypts (for .age archives) and decompresses an archive stream
+func openArchive(f io.Reader, archivePath string, identities []age.Identity) (io.ReadCloser, error) {
+	if strings.HasSuffix(archivePath, encryption.Extension) {
+		decrypted, err := encryption.NewReader(f, identities)
+		if err != nil {
+			return nil, err
+		}
+		return tarball.NewDecompressedReader(decrypted, strings.TrimSuffix(archivePath, encryption.Extension))
+	}
+	return tarball.NewDecompressedReader(f, archivePath)
+}
+
+func addBytes(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func addFile(tw *tar.Writer, name, srcPath string) error {
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", srcPath, err)
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(srcPath); err != nil {
+			return fmt.Errorf("failed to read symlink %s: %w", srcPath, err)
+		}
+	} else if !info.Mode().IsRegular() {
+		return nil // Skip sockets, devices, etc.
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("failed to build header for %s: %w", srcPath, err)
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if link != "" {
+		return nil
+	}
+
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func addTree(tw *tar.Writer, prefix, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, filepath.ToSlash(filepath.Join(prefix, rel)), path)
+	})
+}
+
+// checkParents reports an error if any existing directory between root and target is a
+// symlink, which writing target would follow out of root
+func checkParents(root, target string) error {
+	if filepath.Clean(target) == filepath.Clean(root) {
+		return nil
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	path := root
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write %s through symlink %s", target, path)
+		}
+	}
+	return nil
+}
+
+// extract unpacks an archive into destDir, rejecting entries that escape it, directly or
+// through a symlink an earlier entry created, and links other than symlinks
+func extract(archivePath, destDir string, identities []age.Identity) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	r, err := openArchive(f, archivePath, identities)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		target := filepath.Join(destDir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %s escapes destination", header.Name)
+		}
+		if err := checkParents(destDir, target); err != nil {
+			return fmt.Errorf("archive entry %s escapes destination: %w", header.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			// O_EXCL, so a repeated name can't write through a symlink an earlier entry made
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return fmt.Errorf("failed to write %s: %w", target, err)
+			}
+			if err := out.Close(); err != nil {
+				return fmt.Errorf("failed to write %s: %w", target, err)
+			}
+		default:
+			return fmt.Errorf("archive entry %s has unsupported type %q", header.Name, header.Typeflag)
+		}
+	}
+}
+
+// copyTree copies an extracted directory into dest, refusing to overwrite existing files
+func copyTree(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		// The worktree's tracked symlinks come from the archive too
+		if err := checkParents(dest, target); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("refusing to overwrite existing file %s", target)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,273 @@
+package session
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Setenv("XDG_DATA_HOME", tempDir)
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	// Source repo with a feature branch, an uncommitted edit, and an untracked file
+	source := filepath.Join(tempDir, "source")
+	_ = os.MkdirAll(source, 0755)
+	runGit(t, source, "init", "-q", "-b", "main")
+	_ = os.WriteFile(filepath.Join(source, "README.md"), []byte("hello\n"), 0644)
+	runGit(t, source, "add", ".")
+	runGit(t, source, "commit", "-q", "-m", "initial")
+
+	// Destination clone made before the feature branch existed
+	dest := filepath.Join(tempDir, "dest")
+	runGit(t, tempDir, "clone", "-q", source, dest)
+
+	runGit(t, source, "checkout", "-q", "-b", "feature")
+	_ = os.WriteFile(filepath.Join(source, "feature.txt"), []byte("committed\n"), 0644)
+	runGit(t, source, "add", ".")
+	runGit(t, source, "commit", "-q", "-m", "feature work")
+	_ = os.WriteFile(filepath.Join(source, "README.md"), []byte("hello\nedited\n"), 0644)
+	_ = os.WriteFile(filepath.Join(source, "notes.txt"), []byte("untracked\n"), 0644)
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := []age.Recipient{identity.Recipient()}
+	identities := []age.Identity{identity}
+
+	for _, ext := range []string{".tar.zst", ".tar.gz", ".tar.zst.age"} {
+		t.Run(ext, func(t *testing.T) {
+			archive := filepath.Join(tempDir, "session"+ext)
+			meta := Metadata{Container: "packnplay-source-feature", Project: "source", Worktree: "feature"}
+			if err := Export(source, meta, Extras{}, archive, recipients); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			peeked, err := ReadArchiveMetadata(archive, identities)
+			if err != nil {
+				t.Fatalf("ReadArchiveMetadata() error = %v", err)
+			}
+			if !peeked.Git || peeked.Branch != "feature" {
+				t.Errorf("ReadArchiveMetadata() = %+v, want git session on branch feature", peeked)
+			}
+
+			worktree := filepath.Join(tempDir, "worktree"+ext)
+			if _, err := Import(archive, dest, worktree, tempDir, identities); err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+
+			expected := map[string]string{
+				"feature.txt": "committed\n",
+				"README.md":   "hello\nedited\n",
+				"notes.txt":   "untracked\n",
+			}
+			for file, want := range expected {
+				got, err := os.ReadFile(filepath.Join(worktree, file))
+				if err != nil {
+					t.Errorf("Imported worktree missing %s: %v", file, err)
+					continue
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", file, got, want)
+				}
+			}
+
+			// Second import of the same branch must refuse rather than clobber it
+			runGit(t, dest, "worktree", "remove", "--force", worktree)
+			if _, err := Import(archive, dest, worktree, tempDir, identities); err == nil {
+				t.Error("Import() of existing branch should fail")
+			}
+			runGit(t, dest, "branch", "-q", "-D", "feature")
+		})
+	}
+}
+
+func TestEncryptedArchiveRequiresKey(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "source")
+	_ = os.MkdirAll(source, 0755)
+	_ = os.WriteFile(filepath.Join(source, "secret.txt"), []byte("proprietary\n"), 0644)
+
+	archive := filepath.Join(tempDir, "session.tar.gz.age")
+	if err := Export(source, Metadata{Container: "c"}, Extras{}, archive, nil); err == nil {
+		t.Fatal("Export() to .age without recipients should fail")
+	}
+
+	owner, _ := age.GenerateX25519Identity()
+	if err := Export(source, Metadata{Container: "c"}, Extras{}, archive, []age.Recipient{owner.Recipient()}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	if _, err := ReadArchiveMetadata(archive, []age.Identity{other}); err == nil {
+		t.Error("ReadArchiveMetadata() with the wrong key should fail")
+	}
+}
+
+// writeTarGz writes a .tar.gz with the given headers; regular files get body as content
+func writeTarGz(t *testing.T, path string, headers []tar.Header, body string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(body))
+		}
+		if err := tw.WriteHeader(&header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractRejectsLinkEscapes(t *testing.T) {
+	tempDir := t.TempDir()
+	outside := filepath.Join(tempDir, "outside")
+	_ = os.MkdirAll(outside, 0755)
+
+	tests := []struct {
+		name    string
+		headers []tar.Header
+	}{
+		{"through symlink", []tar.Header{
+			{Name: "untracked/link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "untracked/link/pwned", Typeflag: tar.TypeReg, Mode: 0644},
+		}},
+		{"onto symlink", []tar.Header{
+			{Name: "untracked/link", Typeflag: tar.TypeSymlink, Linkname: filepath.Join(outside, "pwned")},
+			{Name: "untracked/link", Typeflag: tar.TypeReg, Mode: 0644},
+		}},
+		{"hardlink", []tar.Header{
+			{Name: "untracked/link", Typeflag: tar.TypeLink, Linkname: filepath.Join(outside, "pwned")},
+		}},
+		{"dot dot", []tar.Header{
+			{Name: "../pwned", Typeflag: tar.TypeReg, Mode: 0644},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(tempDir, strings.ReplaceAll(tt.name, " ", "-")+".tar.gz")
+			writeTarGz(t, archive, tt.headers, "pwned\n")
+			dest := filepath.Join(tempDir, "dest-"+strings.ReplaceAll(tt.name, " ", "-"))
+			_ = os.MkdirAll(dest, 0755)
+
+			if err := extract(archive, dest, nil); err == nil {
+				t.Error("extract() should fail")
+			}
+			if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+				t.Fatal("extract() wrote outside the destination")
+			}
+		})
+	}
+}
+
+func TestCopyTreeRefusesWorktreeSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	outside := filepath.Join(tempDir, "outside")
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+	for _, dir := range []string{outside, filepath.Join(src, "lib"), dest} {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	_ = os.WriteFile(filepath.Join(src, "lib", "pwned"), []byte("pwned\n"), 0644)
+	// A tracked symlink checked out from the archive's bundle
+	_ = os.Symlink(outside, filepath.Join(dest, "lib"))
+
+	if err := copyTree(src, dest); err == nil {
+		t.Error("copyTree() should refuse to write through a symlink")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+		t.Fatal("copyTree() wrote outside the worktree")
+	}
+}
+
+func TestExportImportExtras(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(tempDir, "data"))
+	started := time.Now().Add(-time.Hour)
+
+	// The session's .claude mount: a conversation from this session, one from before it,
+	// and credentials
+	claudeDir := filepath.Join(tempDir, "host-claude")
+	_ = os.MkdirAll(filepath.Join(claudeDir, "projects", "-workspace"), 0755)
+	_ = os.WriteFile(filepath.Join(claudeDir, "projects", "-workspace", "new.jsonl"), []byte("{}\n"), 0644)
+	_ = os.WriteFile(filepath.Join(claudeDir, "projects", "-workspace", "old.jsonl"), []byte("{}\n"), 0644)
+	_ = os.WriteFile(filepath.Join(claudeDir, ".credentials.json"), []byte("secret"), 0600)
+	old := started.Add(-time.Hour)
+	_ = os.Chtimes(filepath.Join(claudeDir, "projects", "-workspace", "old.jsonl"), old, old)
+
+	mounts := []Mount{
+		{Source: claudeDir, Destination: "/home/vscode/.claude"},
+		{Source: filepath.Join(tempDir, "source"), Destination: "/workspace"},
+	}
+	files, err := AgentDeltas(mounts, started)
+	if err != nil {
+		t.Fatalf("AgentDeltas() error = %v", err)
+	}
+	if len(files) != 1 || files[".claude/projects/-workspace/new.jsonl"] == "" {
+		t.Fatalf("AgentDeltas() = %v, want only the new conversation", files)
+	}
+
+	recordingPath := filepath.Join(tempDir, "packnplay-source-main-20260101-120000.cast")
+	_ = os.WriteFile(recordingPath, []byte("cast\n"), 0600)
+
+	source := filepath.Join(tempDir, "source")
+	_ = os.MkdirAll(source, 0755)
+	_ = os.WriteFile(filepath.Join(source, "main.go"), []byte("package main\n"), 0644)
+	archive := filepath.Join(tempDir, "session.tar.gz")
+	extras := Extras{AgentFiles: files, Transcripts: []string{recordingPath}}
+	if err := Export(source, Metadata{Container: "packnplay-source-main"}, extras, archive, nil); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	home := filepath.Join(tempDir, "home")
+	meta, err := Import(archive, filepath.Join(tempDir, "project"), filepath.Join(tempDir, "project"), home, nil)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(meta.AgentFiles) != 1 || len(meta.Transcript) != 1 {
+		t.Errorf("metadata lists %v and %v, want one agent file and one recording", meta.AgentFiles, meta.Transcript)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".claude", "projects", "-workspace", "new.jsonl")); err != nil {
+		t.Errorf("conversation not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".claude", ".credentials.json")); err == nil {
+		t.Error("credentials were exported")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "data", "packnplay", "recordings", filepath.Base(recordingPath))); err != nil {
+		t.Errorf("recording not restored: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/agents"
+)
+
+// Archive entries for what a session keeps outside its workspace
+const (
+	agentsDir     = "agents"     // agent config files the session changed, by path relative to home
+	transcriptDir = "transcript" // the session's recordings
+)
+
+// credentialName matches agent config files that hold logins rather than session state,
+// e.g. .credentials.json, auth.json or oauth_creds.json; they never leave the machine
+var credentialName = regexp.MustCompile(`(?i)(cred|auth|token|secret|key|account|session)`)
+
+// Extras are a session's files outside its workspace
+type Extras struct {
+	AgentFiles  map[string]string // host path of each file, by its path relative to home
+	Transcripts []string          // host paths of the session's recordings
+}
+
+// Mount is a container mount, as docker inspect reports it
+type Mount struct {
+	Source      string
+	Destination string
+}
+
+// AgentDeltas returns the files in the container's agent config dir mounts that changed
+// since the session started, such as the agent's conversation history, so the agent can
+// pick the conversation up after import. Credential files are left out.
+func AgentDeltas(mounts []Mount, started time.Time) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, agent := range agents.GetSupportedAgents() {
+		configDir := agent.ConfigDir()
+		for _, mount := range mounts {
+			if !strings.HasSuffix(mount.Destination, "/"+configDir) {
+				continue
+			}
+			info, err := os.Stat(mount.Source)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			err = filepath.WalkDir(mount.Source, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !entry.Type().IsRegular() || credentialName.MatchString(entry.Name()) {
+					return nil
+				}
+				info, err := entry.Info()
+				if err != nil || !info.ModTime().After(started) {
+					return err
+				}
+				rel, err := filepath.Rel(mount.Source, path)
+				if err != nil {
+					return err
+				}
+				files[filepath.ToSlash(filepath.Join(configDir, rel))] = path
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s config in %s: %w", agent.Name(), mount.Source, err)
+			}
+		}
+	}
+	return files, nil
+}
+
+// restoreNew copies an extracted directory into dest with write, keeping files that already
+// exist there. It returns the files it kept.
+func restoreNew(src, dest string, write func(path string, data []byte) error) ([]string, error) {
+	var kept []string
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if _, err := os.Lstat(target); err == nil {
+			kept = append(kept, rel)
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return write(target, data)
+	})
+	return kept, err
+}
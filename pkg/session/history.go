@@ -0,0 +1,196 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/review"
+)
+
+// StatusFailed is the search status of sessions that failed, whatever state they ended in
+const StatusFailed = "failed"
+
+// tagPattern keeps tags easy to type and to pass on a command line
+var tagPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateTags rejects tags that couldn't be searched for as typed
+func ValidateTags(tags []string) error {
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag '%s' (use letters, digits, '.', '_' and '-')", tag)
+		}
+	}
+	return nil
+}
+
+// Status is what a search matches --status against: "failed", or the lifecycle state
+func (e Entry) Status() string {
+	if e.Failure != "" {
+		return StatusFailed
+	}
+	return string(e.CurrentState())
+}
+
+// SetFailure records why a session failed; "" clears it, e.g. once a verification passes
+func SetFailure(containerName, reason string) error {
+	return Update(func(entries map[string]Entry) error {
+		entry, ok := entries[containerName]
+		if !ok {
+			return fmt.Errorf("%s: %w", containerName, ErrUntracked)
+		}
+		entry.Failure = reason
+		entry.UpdatedAt = time.Now().UTC()
+		entries[containerName] = entry
+		return nil
+	})
+}
+
+// AddReview attaches a reviewer's verdict, replacing that reviewer's earlier one
+func AddReview(containerName string, verdict review.Verdict) error {
+	return Update(func(entries map[string]Entry) error {
+		entry, ok := entries[containerName]
+		if !ok {
+			return fmt.Errorf("%s: %w", containerName, ErrUntracked)
+		}
+		reviews := []review.Verdict{}
+		for _, earlier := range entry.Reviews {
+			if earlier.Reviewer != verdict.Reviewer {
+				reviews = append(reviews, earlier)
+			}
+		}
+		entry.Reviews = append(reviews, verdict)
+		entry.UpdatedAt = time.Now().UTC()
+		entries[containerName] = entry
+		return nil
+	})
+}
+
+// GetHistoryPath returns the log of sessions that have been torn down
+// It's local to this machine even with a shared session store.
+func GetHistoryPath() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "history.jsonl")
+}
+
+// appendHistory records a session that's gone
+func appendHistory(entry Entry) error {
+	path := GetHistoryPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session history: %w", err)
+	}
+	if data, err = encryption.SealLine(data); err != nil {
+		return fmt.Errorf("failed to encrypt session history: %w", err)
+	}
+	// O_APPEND writes of a single line don't interleave between processes
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open session history: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write session history: %w", err)
+	}
+	return nil
+}
+
+// LoadHistory reads the sessions that have been torn down, oldest first, skipping malformed lines
+func LoadHistory() ([]Entry, error) {
+	file, err := os.Open(GetHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, err := encryption.OpenLine(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session history: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session history: %w", err)
+	}
+	return entries, nil
+}
+
+// Query selects sessions; zero fields match everything
+type Query struct {
+	Tags    []string  // all of them
+	Since   time.Time // started at or after
+	Status  string    // "failed" or a lifecycle state
+	Project string
+	Agent   string
+}
+
+// Matches reports whether a session satisfies the query
+func (q Query) Matches(entry Entry) bool {
+	for _, tag := range q.Tags {
+		if !slices.Contains(entry.Tags, tag) {
+			return false
+		}
+	}
+	switch {
+	case !q.Since.IsZero() && entry.StartedAt.Before(q.Since):
+		return false
+	case q.Status != "" && entry.Status() != q.Status:
+		return false
+	case q.Project != "" && entry.Project != q.Project:
+		return false
+	case q.Agent != "" && entry.Agent != q.Agent:
+		return false
+	}
+	return true
+}
+
+// Search returns the current and past sessions matching the query, newest first
+func Search(q Query) ([]Entry, error) {
+	current, err := LoadStore()
+	if err != nil {
+		return nil, err
+	}
+	past, err := LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Entry
+	for _, entry := range current {
+		if q.Matches(entry) {
+			found = append(found, entry)
+		}
+	}
+	for _, entry := range past {
+		if q.Matches(entry) {
+			found = append(found, entry)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].StartedAt.After(found[j].StartedAt) })
+	return found, nil
+}
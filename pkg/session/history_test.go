@@ -0,0 +1,99 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForgetKeepsHistory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	started := time.Now().UTC().Add(-time.Hour)
+	if err := Register(Entry{Container: "packnplay-billing-main", Project: "billing", Worktree: "main", Agent: "claude", Tags: []string{"refactor", "billing-service"}, StartedAt: started}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := SetFailure("packnplay-billing-main", "failed to start container"); err != nil {
+		t.Fatalf("SetFailure() error: %v", err)
+	}
+	if err := Forget("packnplay-billing-main"); err != nil {
+		t.Fatalf("Forget() error: %v", err)
+	}
+	if err := Forget("packnplay-never-registered"); err != nil {
+		t.Fatalf("Forget() of an unknown session error: %v", err)
+	}
+
+	history, err := LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory() error: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("history = %+v, want the one forgotten session", history)
+	}
+	if entry := history[0]; entry.Status() != StatusFailed || entry.EndedAt.IsZero() || len(entry.Tags) != 2 {
+		t.Errorf("history entry = %+v", entry)
+	}
+
+	if err := SetFailure("packnplay-billing-main", "x"); err == nil {
+		t.Error("SetFailure() of a forgotten session should fail")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	now := time.Now().UTC()
+	for _, entry := range []Entry{
+		{Container: "packnplay-billing-old", Project: "billing", Tags: []string{"billing-service"}, StartedAt: now.Add(-40 * 24 * time.Hour)},
+		{Container: "packnplay-billing-failed", Project: "billing", Tags: []string{"billing-service", "refactor"}, StartedAt: now.Add(-2 * 24 * time.Hour)},
+		{Container: "packnplay-billing-live", Project: "billing", Tags: []string{"billing-service"}, StartedAt: now.Add(-time.Hour)},
+		{Container: "packnplay-web-main", Project: "web", StartedAt: now.Add(-time.Hour)},
+	} {
+		if err := Register(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetFailure("packnplay-billing-failed", "verification failed"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"packnplay-billing-old", "packnplay-billing-failed"} {
+		if err := Forget(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(q Query) []string {
+		found, err := Search(q)
+		if err != nil {
+			t.Fatalf("Search() error: %v", err)
+		}
+		var names []string
+		for _, entry := range found {
+			names = append(names, entry.Container)
+		}
+		return names
+	}
+
+	if got := names(Query{Tags: []string{"billing-service"}}); len(got) != 3 || got[0] != "packnplay-billing-live" || got[2] != "packnplay-billing-old" {
+		t.Errorf("by tag, newest first = %v", got)
+	}
+	if got := names(Query{Tags: []string{"billing-service"}, Since: now.Add(-30 * 24 * time.Hour), Status: StatusFailed}); len(got) != 1 || got[0] != "packnplay-billing-failed" {
+		t.Errorf("failed in the last 30 days = %v", got)
+	}
+	if got := names(Query{Tags: []string{"billing-service", "refactor"}}); len(got) != 1 {
+		t.Errorf("every tag must match = %v", got)
+	}
+	if got := names(Query{Project: "web", Status: string(StateCreated)}); len(got) != 1 || got[0] != "packnplay-web-main" {
+		t.Errorf("by project and state = %v", got)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	if err := ValidateTags([]string{"refactor", "billing-service", "v1.2_rc"}); err != nil {
+		t.Errorf("ValidateTags() error: %v", err)
+	}
+	for _, tag := range []string{"", "-x", "two words", "a,b"} {
+		if err := ValidateTags([]string{tag}); err == nil {
+			t.Errorf("ValidateTags(%q) should fail", tag)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sharedDocument holds the sessions of every machine in a team or dev-box fleet
+// Each machine reads and changes only its own part through LoadStore and Update, so its
+// container names and owner pids never mix with another machine's; LoadAllHosts shows everyone's.
+type sharedDocument struct {
+	Hosts map[string][]Entry `json:"hosts"` // host -> its sessions
+}
+
+// documentStore holds the shared document, with a version for optimistic updates
+type documentStore interface {
+	get() ([]byte, string, error)          // nil data and "" version when nothing is stored yet
+	put(data []byte, version string) error // errConflict if the stored version isn't version anymore
+	String() string
+}
+
+// errConflict means another machine changed the document since it was read
+var errConflict = errors.New("session store changed concurrently")
+
+// shared is the configured shared store; nil keeps sessions in the local file
+var shared documentStore
+
+// updateAttempts bounds how often a conflicting update is retried
+const updateAttempts = 8
+
+// Configure keeps sessions in a shared store instead of the local file:
+// s3://bucket/key.json, postgres://..., or sqlite:///path/to/sessions.db. "" uses the local file.
+func Configure(storeURL string) error {
+	if storeURL == "" {
+		shared = nil
+		return nil
+	}
+	store, err := parseStoreURL(storeURL)
+	if err != nil {
+		return err
+	}
+	shared = store
+	return nil
+}
+
+// parseStoreURL picks the backend for a session store URL
+func parseStoreURL(storeURL string) (documentStore, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session store '%s': %w", storeURL, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid session store '%s' (use s3://bucket/key.json)", storeURL)
+		}
+		return &s3Store{bucket: u.Host, key: key, endpoint: u.Query().Get("endpoint")}, nil
+	case "postgres", "postgresql":
+		// The password goes to psql through its environment, since argv is visible to
+		// every user on the machine
+		password, ok := u.User.Password()
+		if ok {
+			u.User = url.User(u.User.Username())
+		}
+		query := u.Query()
+		if query.Has("password") {
+			password = query.Get("password")
+			query.Del("password")
+			u.RawQuery = query.Encode()
+		}
+		return &sqlStore{cli: "psql", target: u.String(), password: password}, nil
+	case "sqlite":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid session store '%s' (use sqlite:///path/to/sessions.db)", storeURL)
+		}
+		return &sqlStore{cli: "sqlite3", target: u.Path}, nil
+	}
+	return nil, fmt.Errorf("unsupported session store '%s' (use s3://, postgres:// or sqlite://)", storeURL)
+}
+
+// HostID names this machine in a shared store: $PACKNPLAY_HOST, or the hostname
+func HostID() string {
+	if host := os.Getenv("PACKNPLAY_HOST"); host != "" {
+		return host
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "localhost"
+	}
+	return host
+}
+
+// LoadAllHosts reads every machine's sessions, keyed by host and then container name
+// With the local file it's just this machine's.
+func LoadAllHosts() (map[string]map[string]Entry, error) {
+	if shared == nil {
+		entries, err := LoadStore()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]map[string]Entry{HostID(): entries}, nil
+	}
+	doc, _, err := readShared()
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]map[string]Entry, len(doc.Hosts))
+	for host, list := range doc.Hosts {
+		all[host] = entriesByContainer(list)
+	}
+	return all, nil
+}
+
+// readShared fetches and parses the shared document
+func readShared() (*sharedDocument, string, error) {
+	data, version, err := shared.get()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read session store %s: %w", shared, err)
+	}
+	doc := &sharedDocument{Hosts: make(map[string][]Entry)}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, doc); err != nil {
+			return nil, "", fmt.Errorf("failed to parse session store %s: %w", shared, err)
+		}
+		if doc.Hosts == nil {
+			doc.Hosts = make(map[string][]Entry)
+		}
+	}
+	return doc, version, nil
+}
+
+// loadShared reads this machine's sessions from the shared store
+func loadShared() (map[string]Entry, error) {
+	doc, _, err := readShared()
+	if err != nil {
+		return nil, err
+	}
+	return entriesByContainer(doc.Hosts[HostID()]), nil
+}
+
+// updateShared changes this machine's sessions, re-reading and retrying when another
+// machine wrote in between
+func updateShared(change func(entries map[string]Entry) error) error {
+	host := HostID()
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		doc, version, err := readShared()
+		if err != nil {
+			return err
+		}
+		entries := entriesByContainer(doc.Hosts[host])
+		if err := change(entries); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			delete(doc.Hosts, host)
+		} else {
+			doc.Hosts[host] = sortedEntries(entries)
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal session store: %w", err)
+		}
+
+		err = shared.put(data, version)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errConflict) {
+			return fmt.Errorf("failed to write session store %s: %w", shared, err)
+		}
+		time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
+	}
+	return fmt.Errorf("failed to write session store %s: %w after %d attempts", shared, errConflict, updateAttempts)
+}
+
+func entriesByContainer(list []Entry) map[string]Entry {
+	entries := make(map[string]Entry, len(list))
+	for _, entry := range list {
+		entries[entry.Container] = entry
+	}
+	return entries
+}
+
+func sortedEntries(entries map[string]Entry) []Entry {
+	list := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Container < list[j].Container })
+	return list
+}
+
+// s3Store keeps the document in one S3 object, written with If-Match on its ETag
+// Uses the aws CLI, which handles credentials; endpoint points it at S3-compatible storage.
+type s3Store struct {
+	bucket, key, endpoint string
+}
+
+func (s *s3Store) String() string { return fmt.Sprintf("s3://%s/%s", s.bucket, s.key) }
+
+func (s *s3Store) aws(args ...string) ([]byte, error) {
+	args = append([]string{"s3api"}, args...)
+	if s.endpoint != "" {
+		args = append(args, "--endpoint-url", s.endpoint)
+	}
+	return exec.Command("aws", args...).CombinedOutput()
+}
+
+func (s *s3Store) get() ([]byte, string, error) {
+	tmp, err := os.CreateTemp("", "packnplay-sessions-")
+	if err != nil {
+		return nil, "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	output, err := s.aws("get-object", "--bucket", s.bucket, "--key", s.key, tmp.Name())
+	if err != nil {
+		if strings.Contains(string(output), "NoSuchKey") {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	var meta struct {
+		ETag string `json:"ETag"`
+	}
+	if err := json.Unmarshal(output, &meta); err != nil || meta.ETag == "" {
+		return nil, "", fmt.Errorf("get-object returned no ETag")
+	}
+	data, err := os.ReadFile(tmp.Name())
+	return data, meta.ETag, err
+}
+
+func (s *s3Store) put(data []byte, version string) error {
+	tmp, err := os.CreateTemp("", "packnplay-sessions-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	args := []string{"put-object", "--bucket", s.bucket, "--key", s.key, "--body", tmp.Name(), "--content-type", "application/json"}
+	if version == "" {
+		args = append(args, "--if-none-match", "*")
+	} else {
+		args = append(args, "--if-match", version)
+	}
+	output, err := s.aws(args...)
+	if err != nil {
+		if strings.Contains(string(output), "PreconditionFailed") || strings.Contains(string(output), "ConditionalRequestConflict") {
+			return errConflict
+		}
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// sqlStore keeps the document in a one-row table with a version column, through the
+// psql or sqlite3 CLI. SQLite suits machines sharing one filesystem, like the users of a
+// shared dev box; Litestream can replicate the file off the box.
+type sqlStore struct {
+	cli      string // psql or sqlite3
+	target   string // connection URL or database path
+	password string // postgres password, taken out of the URL and passed as PGPASSWORD
+}
+
+const sqlStoreTable = "packnplay_sessions"
+
+func (s *sqlStore) String() string {
+	if s.cli == "sqlite3" {
+		return "sqlite://" + s.target
+	}
+	if u, err := url.Parse(s.target); err == nil {
+		if s.password != "" && u.User != nil {
+			u.User = url.UserPassword(u.User.Username(), s.password)
+		}
+		return u.Redacted()
+	}
+	return "postgres"
+}
+
+// query runs SQL and returns the last statement's rows, one per line with | between columns
+func (s *sqlStore) query(sql string) (string, error) {
+	var cmd *exec.Cmd
+	if s.cli == "sqlite3" {
+		cmd = exec.Command("sqlite3", "-batch", "-bail", s.target)
+	} else {
+		cmd = exec.Command("psql", s.target, "-X", "-A", "-t", "-q", "-v", "ON_ERROR_STOP=1")
+		if s.password != "" {
+			cmd.Env = append(os.Environ(), "PGPASSWORD="+s.password)
+		}
+	}
+	cmd.Stdin = strings.NewReader("CREATE TABLE IF NOT EXISTS " + sqlStoreTable + " (id INTEGER PRIMARY KEY, version BIGINT NOT NULL, data TEXT NOT NULL);\n" + sql)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w\n%s", s.cli, err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (s *sqlStore) get() ([]byte, string, error) {
+	row, err := s.query("SELECT version, data FROM " + sqlStoreTable + " WHERE id = 1;\n")
+	if err != nil || row == "" {
+		return nil, "", err
+	}
+	version, data, ok := strings.Cut(row, "|")
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected row %q", row)
+	}
+	return []byte(data), version, nil
+}
+
+func (s *sqlStore) put(data []byte, version string) error {
+	var sql string
+	if version == "" {
+		sql = fmt.Sprintf("INSERT INTO %s (id, version, data) VALUES (1, 1, %s) ON CONFLICT (id) DO NOTHING RETURNING version;\n", sqlStoreTable, sqlQuote(string(data)))
+	} else {
+		if _, err := strconv.ParseInt(version, 10, 64); err != nil {
+			return fmt.Errorf("invalid store version %q", version)
+		}
+		sql = fmt.Sprintf("UPDATE %s SET version = version + 1, data = %s WHERE id = 1 AND version = %s RETURNING version;\n", sqlStoreTable, sqlQuote(string(data)), version)
+	}
+	// No row back means the version moved on, or another machine created the row first
+	row, err := s.query(sql)
+	if err != nil {
+		return err
+	}
+	if row == "" {
+		return errConflict
+	}
+	return nil
+}
+
+// sqlQuote quotes s as an SQL string literal; doubling quotes is all standard SQL needs
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
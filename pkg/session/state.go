@@ -0,0 +1,205 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// State is where a session is in its lifecycle
+type State string
+
+const (
+	StateCreated      State = "created"      // recorded; nothing started yet
+	StateProvisioning State = "provisioning" // container starting and being set up
+	StateRunning      State = "running"      // agent at work
+	StateVerifying    State = "verifying"    // 'packnplay verify' checking the agent's work
+	StateReviewing    State = "reviewing"    // verified, waiting for a person to look
+	StateApplied      State = "applied"      // work published, e.g. a pull request opened
+	StateDiscarded    State = "discarded"    // stopped without its work being applied
+)
+
+// transitions lists the states each state may move to
+var transitions = map[State][]State{
+	StateCreated:      {StateProvisioning, StateDiscarded},
+	StateProvisioning: {StateRunning, StateDiscarded},
+	StateRunning:      {StateVerifying, StateApplied, StateDiscarded},
+	StateVerifying:    {StateRunning, StateReviewing, StateDiscarded},
+	StateReviewing:    {StateRunning, StateVerifying, StateApplied, StateDiscarded},
+	StateApplied:      {},
+	StateDiscarded:    {},
+}
+
+// ErrUntracked is returned for a container the store has no record of
+var ErrUntracked = errors.New("session is not tracked")
+
+// CurrentState is the state an entry is in; sessions recorded before states existed were running
+func (e Entry) CurrentState() State {
+	if e.State == "" {
+		return StateRunning
+	}
+	return e.State
+}
+
+// inFlight reports whether a state is a step some packnplay process is carrying out
+func (s State) inFlight() bool {
+	return s == StateCreated || s == StateProvisioning || s == StateVerifying
+}
+
+// CanTransition reports whether a session may move from one state to another
+func CanTransition(from, to State) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves a session to a new state, recording this process as its owner
+// while the new state is a step in progress
+func Transition(containerName string, to State) error {
+	return Update(func(entries map[string]Entry) error {
+		entry, ok := entries[containerName]
+		if !ok {
+			return fmt.Errorf("%s: %w", containerName, ErrUntracked)
+		}
+		if from := entry.CurrentState(); !CanTransition(from, to) {
+			return fmt.Errorf("session %s can't go from %s to %s", containerName, from, to)
+		}
+		entry.State = to
+		entry.Owner, entry.OwnerStart = 0, ""
+		if to.inFlight() {
+			entry.claim()
+		}
+		entry.UpdatedAt = time.Now().UTC()
+		entries[containerName] = entry
+		return nil
+	})
+}
+
+// BeginRemoval records that this process is tearing a session down
+// Sessions whose work wasn't applied are discarded. Untracked containers are
+// recorded, so an interrupted teardown is still finished later.
+func BeginRemoval(containerName string) error {
+	return Update(func(entries map[string]Entry) error {
+		entry, ok := entries[containerName]
+		if !ok {
+			entry = Entry{Container: containerName, StartedAt: time.Now().UTC()}
+		}
+		if entry.CurrentState() != StateApplied {
+			entry.State = StateDiscarded
+		}
+		entry.Removing = true
+		entry.claim()
+		entry.UpdatedAt = time.Now().UTC()
+		entries[containerName] = entry
+		return nil
+	})
+}
+
+// Recovery is what to do with a session after a crash interrupted it
+type Recovery int
+
+const (
+	RecoverNothing Recovery = iota // consistent as it is
+	RecoverForget                  // the container is gone; drop the record
+	RecoverRemove                  // finish tearing it down: container, volumes and state
+	RecoverResume                  // an interrupted verification; the agent's session carries on running
+)
+
+// Recover decides how to settle a session whose container exists (or not), given
+// whether the process that owns its current step is still alive
+func Recover(entry Entry, exists, ownerAlive bool) Recovery {
+	if entry.Owner != 0 && ownerAlive {
+		return RecoverNothing
+	}
+	switch {
+	case entry.Removing:
+		return RecoverRemove
+	case entry.CurrentState() == StateCreated, entry.CurrentState() == StateProvisioning:
+		// Half set up: nothing in it is worth keeping, and a retry needs the name
+		return RecoverRemove
+	case !exists:
+		return RecoverForget
+	case entry.CurrentState() == StateVerifying:
+		return RecoverResume
+	}
+	return RecoverNothing
+}
+
+// claim records this process as the owner of the entry's current step
+func (e *Entry) claim() {
+	e.Owner = os.Getpid()
+	e.OwnerStart = ProcessStart(e.Owner)
+}
+
+// OwnerAlive reports whether the process that owns the entry's current step is still running
+func (e Entry) OwnerAlive() bool {
+	return ProcessAlive(e.Owner, e.OwnerStart)
+}
+
+// ProcessAlive reports whether a process is still running
+// A pid is reused once its process exits, so when start is known (from ProcessStart) the
+// running process must also have started at that time. Entries recorded before start times
+// were stored pass "" and are judged by the pid alone.
+func ProcessAlive(pid int, start string) bool {
+	if pid <= 0 {
+		return false
+	}
+	if err := syscall.Kill(pid, 0); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	if start == "" {
+		return true
+	}
+	current := ProcessStart(pid)
+	return current == "" || current == start
+}
+
+// ProcessStart identifies when a process started, or "" if that can't be read
+// On Linux it's the boot ID and the start time in clock ticks since boot, so it also
+// changes across reboots; elsewhere it's the start time ps reports.
+func ProcessStart(pid int) string {
+	if runtime.GOOS == "linux" {
+		bootID, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+		if err != nil {
+			return ""
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			return ""
+		}
+		ticks, ok := statStartTime(string(stat))
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(string(bootID)) + ":" + ticks
+	}
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// statStartTime returns the starttime field of a /proc/<pid>/stat line
+// The command name is in parentheses and may itself contain spaces and parentheses, so
+// fields are counted from the last ')', after which starttime is the 20th.
+func statStartTime(stat string) (string, bool) {
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return "", false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return "", false
+	}
+	return fields[19], true
+}
@@ -0,0 +1,144 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestTransition(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := Register(Entry{Container: "packnplay-app-main", Project: "app"}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	entries, _ := LoadStore()
+	if entry := entries["packnplay-app-main"]; entry.State != StateCreated || entry.Owner != os.Getpid() || !entry.OwnerAlive() {
+		t.Fatalf("registered entry = %+v, want created and owned by this process", entry)
+	}
+
+	for _, to := range []State{StateProvisioning, StateRunning, StateVerifying, StateReviewing, StateApplied} {
+		if err := Transition("packnplay-app-main", to); err != nil {
+			t.Fatalf("Transition(%s) error: %v", to, err)
+		}
+	}
+	entries, _ = LoadStore()
+	if entry := entries["packnplay-app-main"]; entry.State != StateApplied || entry.Owner != 0 || entry.OwnerStart != "" || entry.Project != "app" {
+		t.Errorf("entry = %+v, want applied with no owner", entry)
+	}
+
+	if err := Transition("packnplay-app-main", StateRunning); err == nil {
+		t.Error("Transition() out of applied should fail")
+	}
+	if err := Transition("packnplay-app-other", StateRunning); !errors.Is(err, ErrUntracked) {
+		t.Errorf("Transition() of an unknown session = %v, want ErrUntracked", err)
+	}
+}
+
+func TestTransitionLegacyEntry(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// Entries written before states existed were running sessions
+	if err := SaveStore(map[string]Entry{"packnplay-app-main": {Container: "packnplay-app-main"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Transition("packnplay-app-main", StateVerifying); err != nil {
+		t.Errorf("Transition() from a legacy entry error: %v", err)
+	}
+}
+
+func TestBeginRemoval(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	Register(Entry{Container: "packnplay-app-main", State: StateApplied})
+	Register(Entry{Container: "packnplay-app-feature", State: StateRunning})
+	for _, name := range []string{"packnplay-app-main", "packnplay-app-feature", "packnplay-app-untracked"} {
+		if err := BeginRemoval(name); err != nil {
+			t.Fatalf("BeginRemoval(%s) error: %v", name, err)
+		}
+	}
+
+	entries, _ := LoadStore()
+	want := map[string]State{"packnplay-app-main": StateApplied, "packnplay-app-feature": StateDiscarded, "packnplay-app-untracked": StateDiscarded}
+	for name, state := range want {
+		entry := entries[name]
+		if entry.State != state || !entry.Removing || entry.Owner != os.Getpid() {
+			t.Errorf("%s = %+v, want %s and removing", name, entry, state)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      Entry
+		exists     bool
+		ownerAlive bool
+		want       Recovery
+	}{
+		{"running", Entry{State: StateRunning}, true, false, RecoverNothing},
+		{"legacy", Entry{}, true, false, RecoverNothing},
+		{"container gone", Entry{State: StateRunning}, false, false, RecoverForget},
+		{"reviewing, container gone", Entry{State: StateReviewing}, false, false, RecoverForget},
+		{"provisioning", Entry{State: StateProvisioning, Owner: 42}, true, true, RecoverNothing},
+		{"provisioning, owner died", Entry{State: StateProvisioning, Owner: 42}, true, false, RecoverRemove},
+		{"created, owner died", Entry{State: StateCreated, Owner: 42}, false, false, RecoverRemove},
+		{"verifying", Entry{State: StateVerifying, Owner: 42}, true, true, RecoverNothing},
+		{"verifying, owner died", Entry{State: StateVerifying, Owner: 42}, true, false, RecoverResume},
+		{"verifying, container gone", Entry{State: StateVerifying, Owner: 42}, false, false, RecoverForget},
+		{"removing", Entry{State: StateDiscarded, Removing: true, Owner: 42}, true, true, RecoverNothing},
+		{"removing, owner died", Entry{State: StateDiscarded, Removing: true, Owner: 42}, false, false, RecoverRemove},
+	}
+	for _, tt := range tests {
+		if got := Recover(tt.entry, tt.exists, tt.ownerAlive); got != tt.want {
+			t.Errorf("%s: Recover() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateSerializes(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := Register(Entry{Container: "packnplay-app-" + name}); err != nil {
+				t.Errorf("Register() error: %v", err)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if entries, _ := LoadStore(); len(entries) != 8 {
+		t.Errorf("store has %d entries after concurrent registers, want 8", len(entries))
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	start := ProcessStart(os.Getpid())
+	if start == "" {
+		t.Fatal("ProcessStart() = \"\" for this process")
+	}
+	if !ProcessAlive(os.Getpid(), start) || !ProcessAlive(os.Getpid(), "") {
+		t.Error("ProcessAlive() = false for this process")
+	}
+	if ProcessAlive(os.Getpid(), "reused") {
+		t.Error("ProcessAlive() = true for a process that started at another time")
+	}
+	if ProcessAlive(0, "") {
+		t.Error("ProcessAlive(0) = true")
+	}
+}
+
+func TestStatStartTime(t *testing.T) {
+	stat := "1234 (tmux: a) b) S 1 1234 1234 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 1 0 98765 1000 200"
+	if got, ok := statStartTime(stat); !ok || got != "98765" {
+		t.Errorf("statStartTime() = %q, %v; want 98765", got, ok)
+	}
+	if _, ok := statStartTime("1234 (sh) S 1"); ok {
+		t.Error("statStartTime() accepted a truncated line")
+	}
+}
@@ -0,0 +1,201 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/review"
+)
+
+// Entry is a session container packnplay knows about
+type Entry struct {
+	ID           string           `json:"id,omitempty"` // matches the container's packnplay-session label
+	Container    string           `json:"container"`
+	Project      string           `json:"project"`
+	Worktree     string           `json:"worktree"`
+	Agent        string           `json:"agent,omitempty"`
+	Tags         []string         `json:"tags,omitempty"` // labels from --tag, for 'packnplay sessions --tag'
+	StartedAt    time.Time        `json:"started_at"`
+	Adopted      bool             `json:"adopted,omitempty"`      // found running without a record, e.g. after a crash
+	Checkpointed bool             `json:"checkpointed,omitempty"` // stopped by 'packnplay pause --checkpoint', waiting to be restored
+	State        State            `json:"state,omitempty"`        // lifecycle state; "" for sessions recorded before states existed
+	Owner        int              `json:"owner,omitempty"`        // pid of the packnplay process mid-transition (provisioning, verifying or removing)
+	OwnerStart   string           `json:"owner_start,omitempty"`  // when the owner started, so a reused pid isn't taken for it
+	Removing     bool             `json:"removing,omitempty"`     // teardown started; recovery finishes it if the owner died
+	Failure      string           `json:"failure,omitempty"`      // why the session failed, e.g. it didn't start or its verification failed
+	Reviews      []review.Verdict `json:"reviews,omitempty"`      // reviewers' verdicts on its diff, latest per reviewer
+	EndedAt      time.Time        `json:"ended_at,omitempty"`     // when it was torn down; only set in the history
+	UpdatedAt    time.Time        `json:"updated_at,omitempty"`
+	Running      bool             `json:"-"` // live container state, never stored
+}
+
+// GetStorePath returns the session state file
+func GetStorePath() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "sessions.json")
+}
+
+// LoadStore reads the known sessions, keyed by container name
+func LoadStore() (map[string]Entry, error) {
+	if shared != nil {
+		return loadShared()
+	}
+	entries := make(map[string]Entry)
+
+	data, err := os.ReadFile(GetStorePath())
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	var list []Entry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse session store: %w", err)
+	}
+	for _, entry := range list {
+		entries[entry.Container] = entry
+	}
+	return entries, nil
+}
+
+// SaveStore writes the known sessions, replacing the file atomically
+func SaveStore(entries map[string]Entry) error {
+	if shared != nil {
+		return updateShared(func(stored map[string]Entry) error {
+			clear(stored)
+			for name, entry := range entries {
+				stored[name] = entry
+			}
+			return nil
+		})
+	}
+	list := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Container < list[j].Container })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session store: %w", err)
+	}
+
+	path := GetStorePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	return nil
+}
+
+// Update changes the store in one transaction
+// Concurrent packnplay processes take turns, so none of them overwrites another's change.
+func Update(change func(entries map[string]Entry) error) error {
+	if shared != nil {
+		return updateShared(change)
+	}
+	path := GetStorePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	lockFile, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open session store lock: %w", err)
+	}
+	defer lockFile.Close()
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock session store: %w", err)
+	}
+
+	entries, err := LoadStore()
+	if err != nil {
+		return err
+	}
+	if err := change(entries); err != nil {
+		return err
+	}
+	return SaveStore(entries)
+}
+
+// Register records a session container packnplay is about to start
+// A new entry starts out created, owned by this process.
+func Register(entry Entry) error {
+	if entry.State == "" {
+		entry.State = StateCreated
+	}
+	if entry.State.inFlight() {
+		entry.claim()
+	}
+	entry.UpdatedAt = time.Now().UTC()
+	return Update(func(entries map[string]Entry) error {
+		entries[entry.Container] = entry
+		return nil
+	})
+}
+
+// SetCheckpointed records whether a session container is stopped at a checkpoint
+func SetCheckpointed(containerName string, checkpointed bool) error {
+	return Update(func(entries map[string]Entry) error {
+		entry, ok := entries[containerName]
+		if !ok {
+			entry = Entry{Container: containerName, StartedAt: time.Now().UTC()}
+		}
+		entry.Checkpointed = checkpointed
+		entries[containerName] = entry
+		return nil
+	})
+}
+
+// Forget drops a session container from the store, keeping its record in the history
+func Forget(containerName string) error {
+	var forgotten *Entry
+	err := Update(func(entries map[string]Entry) error {
+		if entry, ok := entries[containerName]; ok {
+			forgotten = &entry
+		}
+		delete(entries, containerName)
+		return nil
+	})
+	if err != nil || forgotten == nil {
+		return err
+	}
+	forgotten.EndedAt = time.Now().UTC()
+	return appendHistory(*forgotten)
+}
+
+// Reconcile compares the store with the session containers that actually exist
+// It returns the containers the store has no record of, and the recorded containers
+// that no longer exist.
+func Reconcile(entries map[string]Entry, found []Entry) (orphans []Entry, gone []string) {
+	exists := make(map[string]bool)
+	for _, entry := range found {
+		exists[entry.Container] = true
+		if _, ok := entries[entry.Container]; !ok {
+			orphans = append(orphans, entry)
+		}
+	}
+	for name := range entries {
+		if !exists[name] {
+			gone = append(gone, name)
+		}
+	}
+	sort.Strings(gone)
+	return orphans, gone
+}
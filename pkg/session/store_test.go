@@ -0,0 +1,56 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegisterAndForget(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := Register(Entry{Container: "packnplay-app-main", Project: "app", Worktree: "main", Agent: "claude"}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := Register(Entry{Container: "packnplay-app-feature", Project: "app", Worktree: "feature"}); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+
+	entries, err := LoadStore()
+	if err != nil {
+		t.Fatalf("LoadStore() error: %v", err)
+	}
+	if len(entries) != 2 || entries["packnplay-app-main"].Agent != "claude" {
+		t.Fatalf("LoadStore() = %+v", entries)
+	}
+
+	if err := Forget("packnplay-app-main"); err != nil {
+		t.Fatalf("Forget() error: %v", err)
+	}
+	if err := Forget("packnplay-never-registered"); err != nil {
+		t.Fatalf("Forget() of an unknown session error: %v", err)
+	}
+
+	entries, _ = LoadStore()
+	if _, ok := entries["packnplay-app-main"]; ok || len(entries) != 1 {
+		t.Errorf("after Forget() store = %+v", entries)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	entries := map[string]Entry{
+		"packnplay-app-main": {Container: "packnplay-app-main"},
+		"packnplay-app-old":  {Container: "packnplay-app-old"},
+	}
+	found := []Entry{
+		{Container: "packnplay-app-main", Running: true},
+		{Container: "packnplay-app-crashed", Running: true},
+	}
+
+	orphans, gone := Reconcile(entries, found)
+	if len(orphans) != 1 || orphans[0].Container != "packnplay-app-crashed" {
+		t.Errorf("orphans = %+v, want packnplay-app-crashed", orphans)
+	}
+	if !reflect.DeepEqual(gone, []string{"packnplay-app-old"}) {
+		t.Errorf("gone = %v, want [packnplay-app-old]", gone)
+	}
+}
@@ -0,0 +1,256 @@
+package sessionbranch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/obra/packnplay/pkg/container"
+	"github.com/obra/packnplay/pkg/docker"
+	gitpkg "github.com/obra/packnplay/pkg/git"
+)
+
+// containerIndex is the index the agent's workspace is staged into, inside the container
+// It persists across snapshots so git only rehashes files that changed.
+const containerIndex = "/tmp/packnplay-session-index"
+
+// State is a split-mode session's branch and what was last committed to it
+type State struct {
+	Repo   string `json:"repo"`   // host checkout the session reads from
+	Branch string `json:"branch"` // e.g. packnplay/app-main/20250101-120000
+	User   string `json:"user"`   // container user, so objects in the shared .git stay writable
+	Commit string `json:"commit"` // branch tip
+	Tree   string `json:"tree"`   // tree of the branch tip
+}
+
+func dataDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "split")
+}
+
+func statePath(name string) string {
+	return filepath.Join(dataDir(), name+".json")
+}
+
+// sessionDir holds the overlay's upper (the agent's writes) and work directories
+func sessionDir(name string) string {
+	return filepath.Join(dataDir(), name)
+}
+
+// VolumeName returns the overlay volume mounted at /workspace
+func VolumeName(name string) string {
+	return name + "-split"
+}
+
+// Active reports whether a container is a split-mode session
+func Active(name string) bool {
+	_, err := os.Stat(statePath(name))
+	return err == nil
+}
+
+// Load reads a session's branch state
+func Load(name string) (*State, error) {
+	data, err := os.ReadFile(statePath(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session branch state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse session branch state: %w", err)
+	}
+	return &state, nil
+}
+
+func save(name string, state *State) error {
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create split dir: %w", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session branch state: %w", err)
+	}
+	if err := os.WriteFile(statePath(name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write session branch state: %w", err)
+	}
+	return nil
+}
+
+// Forget drops a session's state once its overlay is gone; the branch stays
+func Forget(name string) error {
+	if err := os.Remove(statePath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session branch state: %w", err)
+	}
+	_ = os.Remove(statePath(name) + ".lock")
+	return nil
+}
+
+// BranchName returns the branch a session started at now commits to
+func BranchName(name string, now time.Time) string {
+	return "packnplay/" + strings.TrimPrefix(name, "packnplay-") + "/" + now.Format("20060102-150405")
+}
+
+// Start creates the session's branch at the host checkout as the agent first sees it
+// Uncommitted host changes get their own commit, so the agent's snapshots show only its work.
+func Start(repo, name, user string, now time.Time) (*State, error) {
+	head, err := git(repo, nil, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("split mode needs a repository with at least one commit: %w", err)
+	}
+
+	index, err := os.MkdirTemp("", "packnplay-split-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(index)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(index, "index")}
+
+	if _, err := git(repo, env, "read-tree", "HEAD"); err != nil {
+		return nil, err
+	}
+	if _, err := git(repo, env, "add", "-A"); err != nil {
+		return nil, err
+	}
+	tree, err := git(repo, env, "write-tree")
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{Repo: repo, Branch: BranchName(name, now), User: user, Commit: head}
+	if headTree, _ := git(repo, nil, "rev-parse", "HEAD^{tree}"); headTree != tree {
+		if state.Commit, err = git(repo, nil, "commit-tree", tree, "-p", head, "-m", "packnplay: uncommitted changes at session start"); err != nil {
+			return nil, err
+		}
+	}
+	state.Tree = tree
+
+	if _, err := git(repo, nil, "update-ref", "refs/heads/"+state.Branch, state.Commit, ""); err != nil {
+		return nil, err
+	}
+	return state, save(name, state)
+}
+
+// VolumeCreateArgs prepares empty overlay directories and returns the docker args creating
+// a volume that reads from lower (the host checkout) and writes to the session's upper dir
+func VolumeCreateArgs(name, lower string, labels map[string]string) ([]string, error) {
+	if strings.ContainsAny(lower, ",:") {
+		return nil, fmt.Errorf("split mode can't overlay %s: overlay options don't allow ',' or ':' in paths", lower)
+	}
+	dir := sessionDir(name)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clear old overlay at %s (files may be owned by the container user): %w", dir, err)
+	}
+	upper, work := filepath.Join(dir, "upper"), filepath.Join(dir, "work")
+	for _, d := range []string{upper, work} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create overlay dir: %w", err)
+		}
+	}
+
+	args := append([]string{"volume", "create"}, container.LabelsToArgs(labels)...)
+	args = append(args,
+		"--driver", "local",
+		"--opt", "type=overlay",
+		"--opt", "device=overlay",
+		"--opt", fmt.Sprintf("o=lowerdir=%s,upperdir=%s,workdir=%s", lower, upper, work),
+		VolumeName(name))
+	return args, nil
+}
+
+// Tree stages the container's workspace as the agent sees it and returns its tree
+// git runs inside the container because only it sees the merged view; objects land in
+// the host repository through the bind-mounted .git. Bazel's bazel-* links into its
+// output base are left out.
+func Tree(dockerClient *docker.Client, name string) (string, error) {
+	state, err := Load(name)
+	if err != nil {
+		return "", err
+	}
+	script := fmt.Sprintf(`cd /workspace && set -- && for f in bazel-*; do [ -L "$f" ] && set -- "$@" ":(exclude)$f"; done; GIT_INDEX_FILE=%[1]s git add -A -- . "$@" >/dev/null && GIT_INDEX_FILE=%[1]s git write-tree`, containerIndex)
+	output, err := dockerClient.Run("exec", "-u", state.User, name, "sh", "-c", script)
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot workspace: %w\n%s", err, output)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// Commit records tree on the session branch, unless nothing changed since the last commit
+func Commit(name, tree, message string) (bool, error) {
+	unlock, err := lock(name)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	state, err := Load(name)
+	if err != nil {
+		return false, err
+	}
+	if tree == state.Tree {
+		return false, nil
+	}
+
+	commit, err := git(state.Repo, nil, "commit-tree", tree, "-p", state.Commit, "-m", message)
+	if err != nil {
+		return false, err
+	}
+	// Compare-and-swap, so a branch moved by hand isn't silently overwritten
+	if _, err := git(state.Repo, nil, "update-ref", "refs/heads/"+state.Branch, commit, state.Commit); err != nil {
+		return false, err
+	}
+	state.Commit, state.Tree = commit, tree
+	return true, save(name, state)
+}
+
+// lock serializes commits for a session (the snapshot daemon and 'packnplay stop')
+func lock(name string) (func(), error) {
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create split dir: %w", err)
+	}
+	file, err := os.OpenFile(statePath(name)+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session branch lock: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock session branch state: %w", err)
+	}
+	return func() { file.Close() }, nil
+}
+
+// Cleanup removes the overlay's directories with a throwaway container of image,
+// since files the agent wrote are owned by the container user
+func Cleanup(dockerClient *docker.Client, name, image string) error {
+	dir := sessionDir(name)
+	if err := os.RemoveAll(dir); err == nil {
+		return nil
+	}
+	output, err := dockerClient.Run("run", "--rm", "-u", "root", "--entrypoint", "rm", "-v", dir+":/session", image, "-rf", "/session/upper", "/session/work")
+	if err != nil {
+		return fmt.Errorf("failed to remove overlay at %s: %w\n%s", dir, err, output)
+	}
+	return os.RemoveAll(dir)
+}
+
+// git runs git in repo with extra environment, returning trimmed stdout
+func git(repo string, env []string, args ...string) (string, error) {
+	cmd, err := gitpkg.Command(repo, args...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Env = append(cmd.Env, env...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w\n%s", args[0], err, stderr.String())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,132 @@
+package sessionbranch
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("node_modules/\n"), 0644)
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-qm", "initial"}} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	return repo
+}
+
+// treeOf stages dir's files into a scratch index, as the container does with the merged view
+func treeOf(t *testing.T, repo string) string {
+	t.Helper()
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(t.TempDir(), "index")}
+	if _, err := git(repo, env, "add", "-A"); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := git(repo, env, "write-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestStartAndCommit(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	repo := initRepo(t)
+	head, _ := git(repo, nil, "rev-parse", "HEAD")
+
+	// Uncommitted host changes get their own commit on the branch
+	os.WriteFile(filepath.Join(repo, "wip.go"), []byte("package main // wip\n"), 0644)
+	state, err := Start(repo, "packnplay-app-main", "dev", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if state.Branch != "packnplay/app-main/20250102-030405" {
+		t.Errorf("Branch = %s", state.Branch)
+	}
+	if parent, _ := git(repo, nil, "rev-parse", state.Commit+"^"); parent != head {
+		t.Errorf("start commit parent = %s, want HEAD %s", parent, head)
+	}
+	if !Active("packnplay-app-main") {
+		t.Error("Active() = false after Start")
+	}
+	// The host checkout itself is left alone
+	if status, _ := git(repo, nil, "status", "--porcelain"); status != "?? wip.go" {
+		t.Errorf("host status = %q, want untouched wip.go", status)
+	}
+
+	// An unchanged workspace commits nothing
+	if committed, err := Commit("packnplay-app-main", state.Tree, "snapshot"); err != nil || committed {
+		t.Errorf("Commit() unchanged = %v, %v; want no commit", committed, err)
+	}
+
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.MkdirAll(filepath.Join(repo, "node_modules", "x"), 0755)
+	os.WriteFile(filepath.Join(repo, "node_modules", "x", "index.js"), []byte("x"), 0644)
+	if committed, err := Commit("packnplay-app-main", treeOf(t, repo), "snapshot"); err != nil || !committed {
+		t.Fatalf("Commit() = %v, %v; want a commit", committed, err)
+	}
+
+	files, _ := git(repo, nil, "ls-tree", "-r", "--name-only", "refs/heads/"+state.Branch)
+	if files != ".gitignore\nmain.go\nwip.go" {
+		t.Errorf("branch files = %q, want ignored files left out", files)
+	}
+	if count, _ := git(repo, nil, "rev-list", "--count", head+".."+state.Branch); count != "2" {
+		t.Errorf("session commits = %s, want start + snapshot", count)
+	}
+}
+
+func TestCommitRefusesMovedBranch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	repo := initRepo(t)
+	state, err := Start(repo, "packnplay-app-main", "dev", time.Now())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main // moved\n"), 0644)
+	moved, _ := git(repo, nil, "commit-tree", treeOf(t, repo), "-p", state.Commit, "-m", "by hand")
+	git(repo, nil, "update-ref", "refs/heads/"+state.Branch, moved)
+
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main // agent\n"), 0644)
+	if _, err := Commit("packnplay-app-main", treeOf(t, repo), "snapshot"); err == nil {
+		t.Error("Commit() overwrote a branch that was moved by hand")
+	}
+}
+
+func TestVolumeCreateArgs(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	args, err := VolumeCreateArgs("packnplay-app-main", "/src/app", nil)
+	if err != nil {
+		t.Fatalf("VolumeCreateArgs() error = %v", err)
+	}
+	dir := sessionDir("packnplay-app-main")
+	want := "volume create --driver local --opt type=overlay --opt device=overlay --opt o=lowerdir=/src/app,upperdir=" +
+		filepath.Join(dir, "upper") + ",workdir=" + filepath.Join(dir, "work") + " packnplay-app-main-split"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("VolumeCreateArgs() = %s\nwant %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "upper")); err != nil {
+		t.Errorf("upper dir not created: %v", err)
+	}
+
+	if _, err := VolumeCreateArgs("packnplay-app-main", "/src/a,b", nil); err == nil {
+		t.Error("expected an error for a path overlay options can't express")
+	}
+}
@@ -0,0 +1,247 @@
+package sessionenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+// ContainerDir is the tmpfs in the container holding the session's script
+// Values only ever exist there and in the secret store, never in a file on the host.
+const ContainerDir = "/run/packnplay-env"
+
+// ContainerFile is the script in ContainerDir that applies the session's changes
+const ContainerFile = ContainerDir + "/session.env"
+
+// Source is the shell snippet that applies the session's changes, if it has any
+const Source = "[ -r " + ContainerFile + " ] && . " + ContainerFile
+
+// Wrap returns argv that starts command in the container with the session's changes applied
+func Wrap(command []string) []string {
+	if len(command) == 0 {
+		return command
+	}
+	return append([]string{"/bin/sh", "-c", Source + `; exec "$@"`, "sh"}, command...)
+}
+
+// Shell returns argv that starts the container's shell with the session's changes applied
+// SHELL is set when a shell is configured; older containers fall back to bash.
+func Shell() []string {
+	return []string{"/bin/sh", "-c", Source + `; exec "${SHELL:-/bin/bash}"`}
+}
+
+// keyPattern is a variable name the shell can export
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// publishScript replaces the script with stdin, renaming it into place so a shell starting
+// in the container never reads half a script
+var publishScript = fmt.Sprintf("umask 022 && cat > %[1]s.tmp && mv -f %[1]s.tmp %[1]s", ContainerFile)
+
+// State is a session's environment changes since it started
+// Only names are kept here; the values set are in the secret store.
+type State struct {
+	Set   []string `json:"keys"`
+	Unset []string `json:"unset"` // removed, including variables the container was started with
+}
+
+func getStateDir() string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "env")
+}
+
+func getStatePath(containerName string) string {
+	return filepath.Join(getStateDir(), containerName+".json")
+}
+
+// valueSecret names the secret holding the value a session's variable was set to
+func valueSecret(containerName, key string) string {
+	return "env." + containerName + "." + key
+}
+
+// legacyDir is the host directory older versions mounted at ContainerDir, values and all
+func legacyDir(containerName string) string {
+	return filepath.Join(getStateDir(), containerName)
+}
+
+// Prepare starts a session with no changes and returns the docker args for its tmpfs
+func Prepare(containerName string) ([]string, error) {
+	if err := save(containerName, &State{}); err != nil {
+		return nil, err
+	}
+	return []string{"--tmpfs", ContainerDir + ":mode=0755"}, nil
+}
+
+// Active reports whether a session was started able to take changes
+// Sessions from older versions mounted a host directory, which can't be published to.
+func Active(containerName string) bool {
+	if _, err := os.Stat(legacyDir(containerName)); err == nil {
+		return false
+	}
+	_, err := os.Stat(getStatePath(containerName))
+	return err == nil
+}
+
+// Load returns a session's changes
+func Load(containerName string) (*State, error) {
+	data, err := os.ReadFile(getStatePath(containerName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session env: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse session env: %w", err)
+	}
+	return &state, nil
+}
+
+// ParseAssignment splits KEY=VALUE; a bare KEY takes its value from getenv, like --env
+func ParseAssignment(arg string, getenv func(string) string) (string, string, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !keyPattern.MatchString(key) {
+		return "", "", fmt.Errorf("invalid variable name '%s'", key)
+	}
+	if !ok {
+		value = getenv(key)
+		if value == "" {
+			return "", "", fmt.Errorf("%s is not set on the host; use %s=VALUE", key, key)
+		}
+	}
+	return key, value, nil
+}
+
+// Set records values for the session's new processes and publishes them to its container
+func Set(dockerClient *docker.Client, containerName string, values map[string]string) error {
+	state, err := Load(containerName)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if err := secrets.Set(valueSecret(containerName, key), value); err != nil {
+			return err
+		}
+		state.Set = append(remove(state.Set, key), key)
+		state.Unset = remove(state.Unset, key)
+	}
+	sort.Strings(state.Set)
+	if err := save(containerName, state); err != nil {
+		return err
+	}
+	return publish(dockerClient, containerName, state)
+}
+
+// Unset removes variables from the session's new processes
+func Unset(dockerClient *docker.Client, containerName string, keys []string) error {
+	for _, key := range keys {
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("invalid variable name '%s'", key)
+		}
+	}
+	state, err := Load(containerName)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := secrets.Delete(valueSecret(containerName, key)); err != nil {
+			return err
+		}
+		state.Set = remove(state.Set, key)
+		state.Unset = append(remove(state.Unset, key), key)
+	}
+	sort.Strings(state.Unset)
+	if err := save(containerName, state); err != nil {
+		return err
+	}
+	return publish(dockerClient, containerName, state)
+}
+
+// publish renders the session's script from the secret store and writes it to the tmpfs at
+// ContainerDir, passing it on stdin so no value is on the host's disk or a command line. It
+// runs as root, since the tmpfs is root's; the script is readable by the container user.
+func publish(dockerClient *docker.Client, containerName string, state *State) error {
+	values := make(map[string]string, len(state.Set))
+	for _, key := range state.Set {
+		value, err := secrets.Get(valueSecret(containerName, key))
+		if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+	cmd := dockerClient.Stream("exec", "-i", "-u", "0", containerName, "sh", "-c", publishScript)
+	cmd.Stdin = strings.NewReader(Script(values, state.Unset))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to publish session env to %s: %w: %s", containerName, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Script renders a session's changes as a POSIX shell script
+func Script(values map[string]string, unset []string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "export %s='%s'\n", key, strings.ReplaceAll(values[key], "'", `'\''`))
+	}
+	for _, key := range unset {
+		fmt.Fprintf(&b, "unset %s\n", key)
+	}
+	return b.String()
+}
+
+// Forget removes a session's env and the values it was given once its container is gone
+func Forget(containerName string) error {
+	if state, err := Load(containerName); err == nil {
+		for _, key := range state.Set {
+			if err := secrets.Delete(valueSecret(containerName, key)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.RemoveAll(legacyDir(containerName)); err != nil {
+		return fmt.Errorf("failed to remove session env: %w", err)
+	}
+	if err := os.Remove(getStatePath(containerName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session env: %w", err)
+	}
+	return nil
+}
+
+// save records which variables a session has changed
+func save(containerName string, state *State) error {
+	if err := os.MkdirAll(getStateDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create session env dir: %w", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session env: %w", err)
+	}
+	if err := os.WriteFile(getStatePath(containerName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write session env: %w", err)
+	}
+	return nil
+}
+
+func remove(keys []string, key string) []string {
+	out := keys[:0]
+	for _, k := range keys {
+		if k != key {
+			out = append(out, k)
+		}
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package sessionenv
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/docker"
+	"github.com/obra/packnplay/pkg/secrets"
+)
+
+func TestSetAndUnset(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv(secrets.StoreEnv, secrets.StoreFile)
+	args, err := Prepare("packnplay-app-main")
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if want := []string{"--tmpfs", ContainerDir + ":mode=0755"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("Prepare() args = %v, want %v", args, want)
+	}
+	if !Active("packnplay-app-main") {
+		t.Error("Active() = false after Prepare")
+	}
+
+	// A fake runtime that keeps what's published to the container
+	published := filepath.Join(t.TempDir(), "published")
+	runtime := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(runtime, []byte("#!/bin/sh\ncat > "+published+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dockerClient := docker.NewClientWithExecutor(runtime, docker.DefaultExecutor(runtime), false)
+
+	if err := Set(dockerClient, "packnplay-app-main", map[string]string{"OPENAI_API_KEY": "sk-new", "GREETING": "it's here"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := Unset(dockerClient, "packnplay-app-main", []string{"ANTHROPIC_API_KEY", "GREETING"}); err != nil {
+		t.Fatalf("Unset() error = %v", err)
+	}
+	if err := Set(dockerClient, "packnplay-app-main", map[string]string{"ANTHROPIC_API_KEY": "sk-ant-rotated"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(published)
+	want := "export ANTHROPIC_API_KEY='sk-ant-rotated'\nexport OPENAI_API_KEY='sk-new'\nunset GREETING\n"
+	if string(data) != want {
+		t.Errorf("published script = %q, want %q", data, want)
+	}
+	if state, _ := os.ReadFile(getStatePath("packnplay-app-main")); strings.Contains(string(state), "sk-") {
+		t.Errorf("state file holds values: %s", state)
+	}
+	if value, err := secrets.Get(valueSecret("packnplay-app-main", "OPENAI_API_KEY")); err != nil || value != "sk-new" {
+		t.Errorf("stored value = %q, %v", value, err)
+	}
+
+	if err := Forget("packnplay-app-main"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if Active("packnplay-app-main") {
+		t.Error("Active() = true after Forget")
+	}
+	if value, _ := secrets.Get(valueSecret("packnplay-app-main", "OPENAI_API_KEY")); value != "" {
+		t.Error("Forget() left a value in the secret store")
+	}
+}
+
+func TestLegacySessionInactive(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if _, err := Prepare("packnplay-app-main"); err != nil {
+		t.Fatal(err)
+	}
+	// Older versions mounted a host directory, which a tmpfs publish can't reach
+	if err := os.MkdirAll(legacyDir("packnplay-app-main"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if Active("packnplay-app-main") {
+		t.Error("Active() = true for a session with a host-mounted env directory")
+	}
+	if err := Forget("packnplay-app-main"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacyDir("packnplay-app-main")); !os.IsNotExist(err) {
+		t.Error("Forget() left the legacy env directory")
+	}
+}
+
+func TestScriptQuoting(t *testing.T) {
+	value := `a 'quoted' $HOME "value"; echo pwned`
+	script := filepath.Join(t.TempDir(), "session.env")
+	os.WriteFile(script, []byte(Script(map[string]string{"TRICKY": value}, []string{"GONE"})), 0644)
+
+	cmd := exec.Command("sh", "-c", `. "$0"; printf '%s|%s' "$TRICKY" "${GONE-unset}"`, script)
+	cmd.Env = append(os.Environ(), "GONE=still here")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sourcing script: %v", err)
+	}
+	if got := string(output); got != value+"|unset" {
+		t.Errorf("sourced values = %q", got)
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	getenv := func(key string) string {
+		if key == "HOST_KEY" {
+			return "from-host"
+		}
+		return ""
+	}
+	for arg, want := range map[string]string{"KEY=a=b": "KEY=a=b", "EMPTY=": "EMPTY=", "HOST_KEY": "HOST_KEY=from-host"} {
+		key, value, err := ParseAssignment(arg, getenv)
+		if err != nil || key+"="+value != want {
+			t.Errorf("ParseAssignment(%q) = %q, %q, %v", arg, key, value, err)
+		}
+	}
+	for _, arg := range []string{"MISSING", "1KEY=x", "BAD-KEY=x", "=x"} {
+		if _, _, err := ParseAssignment(arg, getenv); err == nil {
+			t.Errorf("ParseAssignment(%q) should fail", arg)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	// Arguments pass through untouched, even when the session has no env file
+	command := Wrap([]string{"printf", "%s|%s", "it's", "$HOME"})
+	output, err := exec.Command(command[0], command[1:]...).Output()
+	if err != nil {
+		t.Fatalf("running wrapped command: %v", err)
+	}
+	if string(output) != "it's|$HOME" {
+		t.Errorf("wrapped command printed %q", output)
+	}
+	if Wrap(nil) != nil {
+		t.Error("Wrap(nil) should stay empty")
+	}
+}
@@ -0,0 +1,175 @@
+package summary
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/obra/packnplay/pkg/encryption"
+	"github.com/obra/packnplay/pkg/recording"
+)
+
+// Limits on what's sent to the summarizer and what's kept of its answer
+const (
+	MinNewText   = 2000  // bytes of new output worth a fresh summary
+	ExcerptBytes = 12000 // the tail of the new output the summarizer sees
+	MaxLength    = 120   // a summary is one line of at most this many characters
+)
+
+// escapeSequences matches terminal control sequences: CSI (colors, cursor moves), OSC
+// (titles, hyperlinks) and two-byte escapes
+var escapeSequences = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Text returns the readable output of a recording: escapes stripped, lines a carriage
+// return redrew reduced to their last version, and runs of blank lines collapsed
+func Text(events []recording.Event) string {
+	var raw strings.Builder
+	for _, event := range events {
+		if event.Code == recording.EventOutput {
+			raw.WriteString(event.Data)
+		}
+	}
+	plain := escapeSequences.ReplaceAllString(raw.String(), "")
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(plain, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.TrimRightFunc(strings.Map(dropControl, line), func(r rune) bool { return r == ' ' || r == '\t' })
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func dropControl(r rune) rune {
+	if r < 0x20 && r != '\t' {
+		return -1
+	}
+	return r
+}
+
+// Prompt asks for a one-line summary of the session's latest output, continuing the previous one
+func Prompt(previous, excerpt string) string {
+	var b strings.Builder
+	b.WriteString("You are summarizing a coding agent's terminal session for a dashboard. ")
+	fmt.Fprintf(&b, "In one line of at most %d characters, say what the agent is doing now. ", MaxLength)
+	b.WriteString("Reply with only that line. Don't run tools or change any files.\n\n")
+	if previous != "" {
+		fmt.Fprintf(&b, "Earlier it was: %s\n\n", previous)
+	}
+	b.WriteString("Latest terminal output:\n\n")
+	b.WriteString(excerpt)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Clean reduces the summarizer's answer to one line of at most MaxLength characters
+func Clean(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(escapeSequences.ReplaceAllString(line, ""))
+		if line == "" {
+			continue
+		}
+		if runes := []rune(line); len(runes) > MaxLength {
+			line = string(runes[:MaxLength-1]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// Excerpt returns the tail of text after offset, at most ExcerptBytes long and starting
+// on a line boundary
+func Excerpt(text string, offset int) string {
+	if offset > len(text) {
+		offset = 0 // a new recording, e.g. after a reconnect
+	}
+	text = text[offset:]
+	if len(text) > ExcerptBytes {
+		text = text[len(text)-ExcerptBytes:]
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	return text
+}
+
+// Summary is one summary of a recording, up to Offset bytes of its text
+type Summary struct {
+	Time   time.Time `json:"time"`
+	Offset int       `json:"offset"`
+	Text   string    `json:"text"`
+}
+
+// Path returns where a recording's summaries are kept, alongside it
+func Path(recordingPath string) string {
+	return strings.TrimSuffix(recordingPath, recording.Extension) + ".summaries.jsonl"
+}
+
+// Append adds a summary to a recording's summaries
+func Append(recordingPath string, s Summary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	if data, err = encryption.SealLine(data); err != nil {
+		return fmt.Errorf("failed to encrypt summary: %w", err)
+	}
+	file, err := os.OpenFile(Path(recordingPath), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open summaries: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+// LatestFor returns the newest summary of a recording; ok is false if there's none
+func LatestFor(recordingPath string) (Summary, bool) {
+	file, err := os.Open(Path(recordingPath))
+	if err != nil {
+		return Summary{}, false
+	}
+	defer file.Close()
+
+	var latest Summary
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, err := encryption.OpenLine(scanner.Bytes())
+		if err != nil {
+			return Summary{}, false
+		}
+		var s Summary
+		if json.Unmarshal(line, &s) == nil {
+			latest, found = s, true
+		}
+	}
+	return latest, found
+}
+
+// Latest returns the newest summary of a session's latest recording
+func Latest(session string) (Summary, bool) {
+	path, err := recording.Find(session)
+	if err != nil {
+		return Summary{}, false
+	}
+	return LatestFor(path)
+}
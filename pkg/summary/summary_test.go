@@ -0,0 +1,91 @@
+package summary
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obra/packnplay/pkg/recording"
+)
+
+func TestText(t *testing.T) {
+	events := []recording.Event{
+		{Code: recording.EventOutput, Data: "\x1b[1;32mRunning\x1b[0m tests\r\n"},
+		{Code: recording.EventInput, Data: "secret typed input"},
+		{Code: recording.EventOutput, Data: "\x1b]0;window title\x07progress 10%\rprogress 100%\n\n\n\n"},
+		{Code: recording.EventResize, Data: "80x24"},
+		{Code: recording.EventOutput, Data: "done\x07\n"},
+	}
+	want := "Running tests\nprogress 100%\n\ndone\n"
+	if got := Text(events); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	text := strings.Repeat("old line\n", 10) + "new output\n"
+	if got := Excerpt(text, 90); got != "new output\n" {
+		t.Errorf("Excerpt() after offset = %q", got)
+	}
+	// A shorter text than the offset is a new recording
+	if got := Excerpt("fresh\n", 90); got != "fresh\n" {
+		t.Errorf("Excerpt() of a new recording = %q", got)
+	}
+
+	long := strings.Repeat("0123456789abcdef\n", ExcerptBytes/8)
+	got := Excerpt(long, 0)
+	if len(got) > ExcerptBytes || !strings.HasPrefix(got, "0123") {
+		t.Errorf("Excerpt() of long text isn't a line-aligned tail: %d bytes, starts %q", len(got), got[:10])
+	}
+}
+
+func TestClean(t *testing.T) {
+	if got := Clean("\n  Refactoring the invoice parser  \nmore detail\n"); got != "Refactoring the invoice parser" {
+		t.Errorf("Clean() = %q", got)
+	}
+	long := Clean(strings.Repeat("x", 300))
+	if len([]rune(long)) != MaxLength || !strings.HasSuffix(long, "…") {
+		t.Errorf("Clean() of a long line = %q", long)
+	}
+	if got := Clean("\n \n"); got != "" {
+		t.Errorf("Clean() of nothing = %q", got)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	prompt := Prompt("Writing tests for the parser", "FAIL TestParse")
+	for _, want := range []string{"one line", "Earlier it was: Writing tests for the parser", "FAIL TestParse"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt() missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(Prompt("", "x"), "Earlier") {
+		t.Error("first Prompt() shouldn't mention an earlier summary")
+	}
+}
+
+func TestAppendAndLatest(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	path, err := recording.NewPath("packnplay-app-main", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LatestFor(path); ok {
+		t.Error("LatestFor() found a summary before any was written")
+	}
+	if Path(path) != strings.TrimSuffix(path, ".cast")+".summaries.jsonl" || filepath.Dir(Path(path)) != filepath.Dir(path) {
+		t.Errorf("summaries aren't kept alongside the recording: %s", Path(path))
+	}
+
+	for i, text := range []string{"Reading the codebase", "Fixing the failing test"} {
+		if err := Append(path, Summary{Time: time.Now(), Offset: (i + 1) * 100, Text: text}); err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+	}
+	latest, ok := LatestFor(path)
+	if !ok || latest.Text != "Fixing the failing test" || latest.Offset != 200 {
+		t.Errorf("LatestFor() = %+v, %v", latest, ok)
+	}
+}
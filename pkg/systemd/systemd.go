@@ -0,0 +1,149 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Unit names written by Install
+const (
+	SocketUnit  = "packnplay.socket"
+	ServiceUnit = "packnplay.service"
+)
+
+// listenFdsStart is the first file descriptor systemd passes, after stdin/stdout/stderr
+const listenFdsStart = 3
+
+// Options describes the daemon being installed
+type Options struct {
+	Executable string // absolute path to the packnplay binary
+	Listen     string // address the socket listens on, e.g. 127.0.0.1:9464
+	Runtime    string // container runtime passed to serve, "" to detect
+	System     bool   // a system unit under /etc rather than a user unit
+	User       string // account a system unit runs as
+	HomeDir    string // that account's home, for the paths it may write
+}
+
+// UnitDir returns where units are installed
+func UnitDir(system bool) string {
+	if system {
+		return "/etc/systemd/system"
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "systemd", "user")
+}
+
+// Validate checks options before units are generated from them
+func Validate(opts Options) error {
+	if !filepath.IsAbs(opts.Executable) {
+		return fmt.Errorf("executable must be an absolute path, got '%s'", opts.Executable)
+	}
+	if opts.Listen == "" || strings.ContainsAny(opts.Listen, " \n") {
+		return fmt.Errorf("invalid listen address '%s'", opts.Listen)
+	}
+	if opts.System && (opts.User == "" || opts.User == "root") {
+		return fmt.Errorf("a system daemon needs an unprivileged user to run as (got '%s')", opts.User)
+	}
+	return nil
+}
+
+// Socket renders the socket unit that starts the service on the first connection
+func Socket(opts Options) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=packnplay metrics and session events socket\n\n")
+	b.WriteString("[Socket]\n")
+	fmt.Fprintf(&b, "ListenStream=%s\n", opts.Listen)
+	if strings.HasPrefix(opts.Listen, "/") {
+		b.WriteString("SocketMode=0660\n")
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=sockets.target\n")
+	return b.String()
+}
+
+// Service renders the service unit running 'packnplay serve' on the activated socket
+// System units are sandboxed: packnplay only needs the container runtime's socket, its own
+// config and its data dir, so the rest of the host is read-only or hidden.
+func Service(opts Options) string {
+	exec := []string{opts.Executable, "serve"}
+	if opts.Runtime != "" {
+		exec = append(exec, "--runtime", opts.Runtime)
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=packnplay metrics and session events\n")
+	fmt.Fprintf(&b, "Requires=%s\n", SocketUnit)
+	fmt.Fprintf(&b, "After=%s network.target\n\n", SocketUnit)
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(exec, " "))
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("StandardOutput=journal\n")
+	b.WriteString("StandardError=journal\n")
+	b.WriteString("SyslogIdentifier=packnplay-serve\n")
+	b.WriteString("NoNewPrivileges=yes\n")
+	if opts.System {
+		fmt.Fprintf(&b, "User=%s\n", opts.User)
+		b.WriteString("ProtectSystem=strict\n")
+		b.WriteString("ProtectHome=read-only\n")
+		fmt.Fprintf(&b, "ReadWritePaths=-%s\n", filepath.Join(opts.HomeDir, ".local", "share", "packnplay"))
+		b.WriteString("PrivateTmp=yes\n")
+		b.WriteString("PrivateDevices=yes\n")
+		b.WriteString("ProtectKernelTunables=yes\n")
+		b.WriteString("ProtectKernelModules=yes\n")
+		b.WriteString("ProtectKernelLogs=yes\n")
+		b.WriteString("ProtectControlGroups=yes\n")
+		b.WriteString("ProtectClock=yes\n")
+		b.WriteString("ProtectHostname=yes\n")
+		b.WriteString("RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6\n")
+		b.WriteString("RestrictNamespaces=yes\n")
+		b.WriteString("RestrictRealtime=yes\n")
+		b.WriteString("RestrictSUIDSGID=yes\n")
+		b.WriteString("LockPersonality=yes\n")
+		b.WriteString("MemoryDenyWriteExecute=yes\n")
+		b.WriteString("SystemCallArchitectures=native\n")
+		b.WriteString("CapabilityBoundingSet=\n")
+	}
+	return b.String()
+}
+
+// Listeners returns the sockets systemd passed to this process, or nil if it wasn't
+// socket-activated. The activation variables are cleared so children don't inherit them.
+func Listeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	var listeners []net.Listener
+	for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		listener, err := net.FileListener(file)
+		file.Close() // FileListener holds its own copy
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to use socket from systemd: %w", err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
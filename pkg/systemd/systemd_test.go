@@ -0,0 +1,89 @@
+package systemd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	base := Options{Executable: "/usr/local/bin/packnplay", Listen: "127.0.0.1:9464"}
+	tests := []struct {
+		name    string
+		modify  func(o *Options)
+		wantErr bool
+	}{
+		{"user unit", func(o *Options) {}, false},
+		{"system unit", func(o *Options) { o.System, o.User = true, "packnplay" }, false},
+		{"relative executable", func(o *Options) { o.Executable = "packnplay" }, true},
+		{"no listen address", func(o *Options) { o.Listen = "" }, true},
+		{"system unit as root", func(o *Options) { o.System, o.User = true, "root" }, true},
+		{"system unit without user", func(o *Options) { o.System = true }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := base
+			tt.modify(&opts)
+			if err := Validate(opts); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnits(t *testing.T) {
+	opts := Options{Executable: "/usr/local/bin/packnplay", Listen: "0.0.0.0:9464", Runtime: "podman"}
+
+	socket := Socket(opts)
+	if !strings.Contains(socket, "ListenStream=0.0.0.0:9464\n") || !strings.Contains(socket, "WantedBy=sockets.target") {
+		t.Errorf("socket unit = %s", socket)
+	}
+	if strings.Contains(socket, "SocketMode") {
+		t.Errorf("TCP socket has a mode: %s", socket)
+	}
+	if unix := Socket(Options{Listen: "/run/packnplay.sock"}); !strings.Contains(unix, "SocketMode=0660") {
+		t.Errorf("unix socket unit = %s", unix)
+	}
+
+	user := Service(opts)
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/packnplay serve --runtime podman\n",
+		"Requires=packnplay.socket\n",
+		"SyslogIdentifier=packnplay-serve\n",
+		"NoNewPrivileges=yes\n",
+	} {
+		if !strings.Contains(user, want) {
+			t.Errorf("user service missing %q:\n%s", want, user)
+		}
+	}
+	if strings.Contains(user, "User=") || strings.Contains(user, "ProtectSystem") {
+		t.Errorf("user service has system-only settings:\n%s", user)
+	}
+
+	opts.System, opts.User, opts.HomeDir = true, "packnplay", "/home/packnplay"
+	system := Service(opts)
+	for _, want := range []string{
+		"User=packnplay\n",
+		"ProtectSystem=strict\n",
+		"ProtectHome=read-only\n",
+		"ReadWritePaths=-/home/packnplay/.local/share/packnplay\n",
+		"CapabilityBoundingSet=\n",
+	} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system service missing %q:\n%s", want, system)
+		}
+	}
+}
+
+func TestListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_PID", "1") // another process's sockets
+	listeners, err := Listeners()
+	if err != nil || listeners != nil {
+		t.Errorf("Listeners() = %v, %v; want nil, nil", listeners, err)
+	}
+
+	t.Setenv("LISTEN_PID", "")
+	if listeners, err := Listeners(); err != nil || listeners != nil {
+		t.Errorf("Listeners() without LISTEN_PID = %v, %v", listeners, err)
+	}
+}
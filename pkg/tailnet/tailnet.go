@@ -0,0 +1,155 @@
+package tailnet
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+// Network modes
+const (
+	ModeTailscale = "tailscale" // userspace tailscaled in a sidecar, reached through its proxy
+	ModeWireGuard = "wireguard" // a wg-quick tunnel brought up in the session's network namespace
+)
+
+// Default sidecar images
+const (
+	DefaultTailscaleImage = "tailscale/tailscale:stable"
+	DefaultWireGuardImage = "lscr.io/linuxserver/wireguard:latest"
+)
+
+// DefaultAuthKeyEnv is the host variable the Tailscale auth key is read from
+const DefaultAuthKeyEnv = "TS_AUTHKEY"
+
+// ProxyPort is where the Tailscale sidecar serves SOCKS5 and HTTP proxying into the tailnet
+const ProxyPort = "1055"
+
+// sidecarWireGuardConfig is where the WireGuard config is mounted in its sidecar
+const sidecarWireGuardConfig = "/config/wg-packnplay/wg0.conf"
+
+// Validate rejects unknown modes and settings the mode can't use
+func Validate(settings config.TailnetConfig) error {
+	switch settings.Mode {
+	case "":
+		return nil
+	case ModeTailscale:
+		for _, tag := range settings.Tags {
+			if !strings.HasPrefix(tag, "tag:") {
+				return fmt.Errorf("tailnet tag '%s' must start with tag:", tag)
+			}
+		}
+		return nil
+	case ModeWireGuard:
+		if settings.WireGuardConfig == "" {
+			return fmt.Errorf("tailnet mode wireguard needs wireguard_config, a wg-quick config file")
+		}
+		if _, err := os.Stat(settings.WireGuardConfig); err != nil {
+			return fmt.Errorf("wireguard_config: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown tailnet mode '%s' (use tailscale or wireguard)", settings.Mode)
+}
+
+// SidecarName returns the name of a session's network sidecar
+func SidecarName(container string) string {
+	return container + "-tailnet"
+}
+
+// SessionArgs returns the session container's args for its network sidecar
+// Userspace tailscaled has no tunnel device, so the session reaches the tailnet through
+// its proxy; the two share a network namespace, so it's on localhost.
+func SessionArgs(mode string) []string {
+	if mode != ModeTailscale {
+		return nil
+	}
+	proxy := "127.0.0.1:" + ProxyPort
+	var args []string
+	for _, env := range []string{
+		"ALL_PROXY=socks5h://" + proxy,
+		"HTTP_PROXY=http://" + proxy,
+		"HTTPS_PROXY=http://" + proxy,
+		"NO_PROXY=localhost,127.0.0.1,::1",
+	} {
+		key, value, _ := strings.Cut(env, "=")
+		args = append(args, "-e", env, "-e", strings.ToLower(key)+"="+value)
+	}
+	return args
+}
+
+// AuthKey reads the Tailscale auth key from the host
+// OAuth client secrets mint a key per node; they're asked for an ephemeral, preauthorized one.
+func AuthKey(settings config.TailnetConfig, getenv func(string) string) (string, error) {
+	name := settings.AuthKeyEnv
+	if name == "" {
+		name = DefaultAuthKeyEnv
+	}
+	key := getenv(name)
+	if key == "" {
+		return "", fmt.Errorf("tailnet mode tailscale needs an auth key (an ephemeral key, or an OAuth client secret) in $%s or stored with 'packnplay secret set %s'", name, name)
+	}
+	if strings.HasPrefix(key, "tskey-client-") {
+		if len(settings.Tags) == 0 {
+			return "", fmt.Errorf("an OAuth client secret in $%s needs tailnet tags for the nodes it creates", name)
+		}
+		if !strings.Contains(key, "?") {
+			key += "?ephemeral=true&preauthorized=true"
+		}
+	}
+	return key, nil
+}
+
+// TailscaleEnv returns the sidecar's environment, for an env file so the key isn't on a command line
+// Without TS_STATE_DIR the node's state is kept in memory, so it's an ephemeral node the
+// tailnet forgets once the sidecar is gone.
+func TailscaleEnv(container, authKey string, tags []string) string {
+	lines := []string{
+		"TS_AUTHKEY=" + authKey,
+		"TS_HOSTNAME=" + container,
+		"TS_USERSPACE=true",
+		"TS_SOCKS5_SERVER=127.0.0.1:" + ProxyPort,
+		"TS_OUTBOUND_HTTP_PROXY_LISTEN=127.0.0.1:" + ProxyPort,
+		"TS_ACCEPT_DNS=false",
+	}
+	if len(tags) > 0 {
+		lines = append(lines, "TS_EXTRA_ARGS=--advertise-tags="+strings.Join(tags, ","))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// SidecarArgs returns the docker run args for a session's network sidecar
+// It joins the session's network namespace. envFile holds TailscaleEnv for mode tailscale.
+func SidecarArgs(container string, settings config.TailnetConfig, envFile string) []string {
+	args := []string{"run", "-d", "--rm",
+		"--name", SidecarName(container),
+		"--network", "container:" + container,
+		"--label", "managed-by=packnplay",
+	}
+	image := settings.Image
+	if settings.Mode == ModeWireGuard {
+		if image == "" {
+			image = DefaultWireGuardImage
+		}
+		// Bringing up the interface changes the shared namespace, so only the sidecar gets NET_ADMIN
+		return append(args,
+			"--cap-add", "NET_ADMIN",
+			"-v", settings.WireGuardConfig+":"+sidecarWireGuardConfig+":ro",
+			"--entrypoint", "/bin/sh",
+			image, "-c", "wg-quick up "+sidecarWireGuardConfig+" && exec sleep infinity")
+	}
+	if image == "" {
+		image = DefaultTailscaleImage
+	}
+	return append(args, "--env-file", envFile, image)
+}
+
+// ReadyCommand returns the command run in the sidecar that succeeds once the network is up
+func ReadyCommand(mode string) []string {
+	if mode == ModeWireGuard {
+		return []string{"wg", "show", "wg0"}
+	}
+	// containerboot's tailscaled socket; status fails until the node has logged in
+	return []string{"tailscale", "--socket=/tmp/tailscaled.sock", "status", "--peers=false"}
+}
@@ -0,0 +1,100 @@
+package tailnet
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestValidate(t *testing.T) {
+	wgConfig := filepath.Join(t.TempDir(), "wg0.conf")
+	os.WriteFile(wgConfig, []byte("[Interface]\n"), 0600)
+
+	tests := []struct {
+		name     string
+		settings config.TailnetConfig
+		wantErr  bool
+	}{
+		{"none", config.TailnetConfig{}, false},
+		{"tailscale", config.TailnetConfig{Mode: ModeTailscale, Tags: []string{"tag:agent"}}, false},
+		{"untagged tag", config.TailnetConfig{Mode: ModeTailscale, Tags: []string{"agent"}}, true},
+		{"wireguard", config.TailnetConfig{Mode: ModeWireGuard, WireGuardConfig: wgConfig}, false},
+		{"wireguard without config", config.TailnetConfig{Mode: ModeWireGuard}, true},
+		{"wireguard missing config", config.TailnetConfig{Mode: ModeWireGuard, WireGuardConfig: wgConfig + ".gone"}, true},
+		{"unknown", config.TailnetConfig{Mode: "zerotier"}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.settings); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAuthKey(t *testing.T) {
+	env := map[string]string{"TS_AUTHKEY": "tskey-auth-abc", "AGENT_TS_CLIENT": "tskey-client-xyz"}
+	getenv := func(key string) string { return env[key] }
+
+	if key, err := AuthKey(config.TailnetConfig{Mode: ModeTailscale}, getenv); err != nil || key != "tskey-auth-abc" {
+		t.Errorf("AuthKey() = %q, %v", key, err)
+	}
+	clientSecret := config.TailnetConfig{Mode: ModeTailscale, AuthKeyEnv: "AGENT_TS_CLIENT", Tags: []string{"tag:agent"}}
+	if key, err := AuthKey(clientSecret, getenv); err != nil || key != "tskey-client-xyz?ephemeral=true&preauthorized=true" {
+		t.Errorf("AuthKey() for an OAuth client = %q, %v", key, err)
+	}
+	clientSecret.Tags = nil
+	if _, err := AuthKey(clientSecret, getenv); err == nil {
+		t.Error("AuthKey() should require tags for an OAuth client secret")
+	}
+	if _, err := AuthKey(config.TailnetConfig{Mode: ModeTailscale, AuthKeyEnv: "UNSET"}, getenv); err == nil {
+		t.Error("AuthKey() should fail without a key")
+	}
+}
+
+func TestSidecarArgs(t *testing.T) {
+	args := SidecarArgs("packnplay-app-main", config.TailnetConfig{Mode: ModeTailscale}, "/tmp/app.env")
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"--name packnplay-app-main-tailnet", "--network container:packnplay-app-main", "--env-file /tmp/app.env", DefaultTailscaleImage} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("tailscale sidecar args missing %q: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "NET_ADMIN") {
+		t.Errorf("userspace tailscale shouldn't need NET_ADMIN: %s", joined)
+	}
+
+	args = SidecarArgs("packnplay-app-main", config.TailnetConfig{Mode: ModeWireGuard, WireGuardConfig: "/etc/wg/office.conf"}, "")
+	joined = strings.Join(args, " ")
+	for _, want := range []string{"--cap-add NET_ADMIN", "/etc/wg/office.conf:" + sidecarWireGuardConfig + ":ro", DefaultWireGuardImage} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("wireguard sidecar args missing %q: %s", want, joined)
+		}
+	}
+}
+
+func TestSessionArgs(t *testing.T) {
+	args := SessionArgs(ModeTailscale)
+	for _, want := range []string{"ALL_PROXY=socks5h://127.0.0.1:1055", "https_proxy=http://127.0.0.1:1055", "NO_PROXY=localhost,127.0.0.1,::1"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("SessionArgs() missing %q: %v", want, args)
+		}
+	}
+	if args := SessionArgs(ModeWireGuard); len(args) != 0 {
+		t.Errorf("wireguard sessions route through the tunnel, not a proxy: %v", args)
+	}
+}
+
+func TestTailscaleEnv(t *testing.T) {
+	env := TailscaleEnv("packnplay-app-main", "tskey-auth-abc", []string{"tag:agent", "tag:ci"})
+	for _, want := range []string{"TS_AUTHKEY=tskey-auth-abc\n", "TS_HOSTNAME=packnplay-app-main\n", "TS_USERSPACE=true\n", "TS_EXTRA_ARGS=--advertise-tags=tag:agent,tag:ci\n"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("TailscaleEnv() missing %q:\n%s", want, env)
+		}
+	}
+	if strings.Contains(env, "TS_STATE_DIR") {
+		t.Errorf("state must stay in memory so the node is ephemeral:\n%s", env)
+	}
+}
@@ -0,0 +1,38 @@
+package tarball
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// NewCompressedWriter picks the compressor from the output file extension
+func NewCompressedWriter(w io.Writer, path string) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(path, ".tar.zst"), strings.HasSuffix(path, ".tzst"):
+		return zstd.NewWriter(w)
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported archive extension for %s (use .tar.zst or .tar.gz)", path)
+	}
+}
+
+// NewDecompressedReader picks the decompressor from the archive file extension
+func NewDecompressedReader(r io.Reader, path string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(path, ".tar.zst"), strings.HasSuffix(path, ".tzst"):
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return decoder.IOReadCloser(), nil
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported archive extension for %s (use .tar.zst or .tar.gz)", path)
+	}
+}
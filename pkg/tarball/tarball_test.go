@@ -0,0 +1,40 @@
+package tarball
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, path := range []string{"a.tar.zst", "a.tzst", "a.tar.gz", "a.tgz"} {
+		var buf bytes.Buffer
+		w, err := NewCompressedWriter(&buf, path)
+		if err != nil {
+			t.Fatalf("NewCompressedWriter(%s) error = %v", path, err)
+		}
+		_, _ = w.Write([]byte("hello"))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewDecompressedReader(&buf, path)
+		if err != nil {
+			t.Fatalf("NewDecompressedReader(%s) error = %v", path, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != "hello" {
+			t.Errorf("%s round trip = %q, %v", path, got, err)
+		}
+	}
+}
+
+func TestRejectsUnknownExtension(t *testing.T) {
+	if _, err := NewCompressedWriter(nil, "session.zip"); err == nil {
+		t.Error("NewCompressedWriter() with .zip should fail")
+	}
+	if _, err := NewDecompressedReader(nil, "session.zip"); err == nil {
+		t.Error("NewDecompressedReader() with .zip should fail")
+	}
+}
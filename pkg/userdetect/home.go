@@ -0,0 +1,147 @@
+package userdetect
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RunFunc runs a container runtime command and returns its output
+type RunFunc func(args ...string) (string, error)
+
+// ImageConfig is the part of an image's config that decides who sessions run as
+type ImageConfig struct {
+	User string   `json:"User"`
+	Env  []string `json:"Env"`
+}
+
+// ParseImageConfig parses `image inspect --format '{{json .Config}}'` output
+func ParseImageConfig(data string) (ImageConfig, error) {
+	var cfg ImageConfig
+	if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	return cfg, nil
+}
+
+// imageUser returns the user part of the image's USER (which may be user:group)
+func (c ImageConfig) imageUser() string {
+	user, _, _ := strings.Cut(c.User, ":")
+	if user == "" {
+		return "root"
+	}
+	return user
+}
+
+// env returns an environment variable set by the image
+func (c ImageConfig) env(name string) string {
+	for _, kv := range c.Env {
+		if key, value, ok := strings.Cut(kv, "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
+
+// ConventionalHome is the home directory assumed when an image doesn't say otherwise
+func ConventionalHome(user string) string {
+	if user == "root" {
+		return "/root"
+	}
+	return "/home/" + user
+}
+
+// passwdHome finds a user's home directory in /etc/passwd content, by name or UID
+func passwdHome(passwd, user string) (string, bool) {
+	for _, line := range strings.Split(passwd, "\n") {
+		parts := strings.Split(strings.TrimSpace(line), ":")
+		if len(parts) >= 6 && (parts[0] == user || parts[2] == user) && parts[5] != "" {
+			return parts[5], true
+		}
+	}
+	return "", false
+}
+
+// ResolveHome returns the home directory of user in image
+// The image's own HOME wins when the session runs as the image's user; otherwise the
+// image's /etc/passwd decides. Passwd lookups start a container, so they're cached per image.
+func ResolveHome(run RunFunc, image, user string) (string, error) {
+	output, err := run("image", "inspect", "--format", "{{json .Config}}", image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	cfg, err := ParseImageConfig(output)
+	if err != nil {
+		return "", err
+	}
+	if home := cfg.env("HOME"); home != "" && cfg.imageUser() == user {
+		return home, nil
+	}
+
+	imageID, err := run("image", "inspect", "--format", "{{.Id}}", image)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image ID for %s: %w", image, err)
+	}
+	imageID = strings.TrimSpace(imageID)
+	cachePath := homeCachePath(imageID, user)
+	if cached := readHomeCache(cachePath, imageID, user); cached != "" {
+		return cached, nil
+	}
+
+	passwd, err := run("run", "--rm", "--entrypoint", "cat", image, "/etc/passwd")
+	if err != nil {
+		return "", fmt.Errorf("failed to read /etc/passwd in %s: %w", image, err)
+	}
+	home, ok := passwdHome(passwd, user)
+	if !ok {
+		home = ConventionalHome(user)
+	}
+
+	writeHomeCache(cachePath, CachedUserResult{ImageID: imageID, User: user, HomeDir: home, Source: "passwd"})
+	return home, nil
+}
+
+// homeCachePath returns the cache file for a user's home in an image ("" if there's no cache dir)
+func homeCachePath(imageID, user string) string {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(imageID + "\x00" + user))
+	return filepath.Join(cacheDir, fmt.Sprintf("home-%x.json", hash))
+}
+
+// readHomeCache returns a cached home directory, or "" on a miss
+func readHomeCache(path, imageID, user string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var cached CachedUserResult
+	if err := json.Unmarshal(data, &cached); err != nil || cached.ImageID != imageID || cached.User != user {
+		return ""
+	}
+	return cached.HomeDir
+}
+
+// writeHomeCache stores a resolved home directory, ignoring failures
+func writeHomeCache(path string, cached CachedUserResult) {
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return
+	}
+	_ = os.Rename(tempFile, path)
+}
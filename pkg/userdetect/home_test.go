@@ -0,0 +1,77 @@
+package userdetect
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeRuntime answers the inspect and passwd commands ResolveHome runs
+type fakeRuntime struct {
+	config      string
+	passwd      string
+	passwdReads int
+}
+
+func (f *fakeRuntime) run(args ...string) (string, error) {
+	joined := strings.Join(args, " ")
+	switch {
+	case strings.Contains(joined, "{{json .Config}}"):
+		return f.config, nil
+	case strings.Contains(joined, "{{.Id}}"):
+		return "sha256:abc123\n", nil
+	case strings.HasSuffix(joined, "/etc/passwd"):
+		f.passwdReads++
+		return f.passwd, nil
+	}
+	return "", fmt.Errorf("unexpected command: %s", joined)
+}
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+app:x:1000:1000::/var/lib/app:/bin/sh
+node:x:1001:1001::/home/node:/bin/bash
+`
+
+func TestResolveHome(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		user   string
+		want   string
+	}{
+		{"image HOME for image user", `{"User":"app","Env":["PATH=/usr/bin","HOME=/srv/app"]}`, "app", "/srv/app"},
+		{"passwd for other user", `{"User":"app","Env":["HOME=/srv/app"]}`, "node", "/home/node"},
+		{"passwd non-standard home", `{"User":"app:app","Env":[]}`, "app", "/var/lib/app"},
+		{"uid lookup", `{"User":"","Env":[]}`, "1000", "/var/lib/app"},
+		{"unknown user falls back", `{"User":"","Env":[]}`, "ghost", "/home/ghost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			fake := &fakeRuntime{config: tt.config, passwd: testPasswd}
+
+			got, err := ResolveHome(fake.run, "example:latest", tt.user)
+			if err != nil {
+				t.Fatalf("ResolveHome() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveHome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveHomeCachesPasswdLookups(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := &fakeRuntime{config: `{"User":"","Env":[]}`, passwd: testPasswd}
+
+	for i := 0; i < 2; i++ {
+		if got, err := ResolveHome(fake.run, "example:latest", "app"); err != nil || got != "/var/lib/app" {
+			t.Fatalf("ResolveHome() = %q, %v", got, err)
+		}
+	}
+	if fake.passwdReads != 1 {
+		t.Errorf("read /etc/passwd %d times, want 1", fake.passwdReads)
+	}
+}
@@ -0,0 +1,80 @@
+package verify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// Environment variables telling each shard which slice of the suite to run (1-based)
+const (
+	ShardIndexEnv = "PACKNPLAY_SHARD_INDEX"
+	ShardTotalEnv = "PACKNPLAY_SHARD_TOTAL"
+)
+
+// Result is the outcome of one shard
+type Result struct {
+	Index    int // 1-based
+	Output   string
+	Err      error
+	Duration time.Duration
+}
+
+// ExpandCommand substitutes {shard} and {shards} placeholders, e.g. jest --shard={shard}/{shards}
+func ExpandCommand(command []string, index, total int) []string {
+	replacer := strings.NewReplacer("{shard}", strconv.Itoa(index), "{shards}", strconv.Itoa(total))
+	expanded := make([]string, len(command))
+	for i, arg := range command {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
+
+// shardArgs builds the docker run args for one shard
+// --volumes-from gives every shard the session's exact workspace and git mounts.
+func shardArgs(containerName, image string, command []string, index, total int) []string {
+	args := []string{
+		"run", "--rm",
+		"--volumes-from", containerName,
+		"-w", "/workspace",
+		"-e", fmt.Sprintf("%s=%d", ShardIndexEnv, index),
+		"-e", fmt.Sprintf("%s=%d", ShardTotalEnv, total),
+		image,
+	}
+	return append(args, ExpandCommand(command, index, total)...)
+}
+
+// Run runs command across total containers in parallel, each sharing the session container's mounts
+// Results are returned in shard order.
+func Run(dockerClient *docker.Client, containerName, image string, command []string, total int) []Result {
+	results := make([]Result, total)
+
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			output, err := dockerClient.Run(shardArgs(containerName, image, command, i+1, total)...)
+			results[i] = Result{Index: i + 1, Output: output, Err: err, Duration: time.Since(start)}
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Failed returns the shards that didn't pass
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
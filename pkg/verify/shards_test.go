@@ -0,0 +1,49 @@
+package verify
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandCommand(t *testing.T) {
+	command := []string{"npx", "jest", "--shard={shard}/{shards}"}
+
+	got := ExpandCommand(command, 2, 4)
+	want := []string{"npx", "jest", "--shard=2/4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandCommand() = %v, want %v", got, want)
+	}
+	if command[2] != "--shard={shard}/{shards}" {
+		t.Error("ExpandCommand() modified its input")
+	}
+}
+
+func TestShardArgs(t *testing.T) {
+	got := shardArgs("packnplay-app-main", "img:latest", []string{"make", "test", "SHARD={shard}"}, 1, 3)
+	want := []string{
+		"run", "--rm",
+		"--volumes-from", "packnplay-app-main",
+		"-w", "/workspace",
+		"-e", "PACKNPLAY_SHARD_INDEX=1",
+		"-e", "PACKNPLAY_SHARD_TOTAL=3",
+		"img:latest",
+		"make", "test", "SHARD=1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shardArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	results := []Result{
+		{Index: 1},
+		{Index: 2, Err: errors.New("exit status 1")},
+		{Index: 3},
+	}
+
+	failed := Failed(results)
+	if len(failed) != 1 || failed[0].Index != 2 {
+		t.Errorf("Failed() = %v, want shard 2", failed)
+	}
+}
@@ -0,0 +1,690 @@
+package writeback
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/obra/packnplay/pkg/bazel"
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/depscan"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+// ConflictSuffix marks the sandbox's copy of a file that was also edited on the host
+const ConflictSuffix = ".packnplay-conflict"
+
+// VolumeName returns the volume holding a sync-mode session's copy of the workspace
+func VolumeName(container string) string {
+	return container + "-workspace"
+}
+
+// State is what a session's writebacks remember between rounds
+type State struct {
+	Workspace string            `json:"workspace"` // host directory edits are written back to
+	User      string            `json:"user"`      // container user that owns the sandbox's copy
+	Base      Manifest          `json:"base"`      // content the host and sandbox last agreed on
+	Stats     Stats             `json:"stats"`     // the sandbox's file stats when it was last compared with Base
+	Conflicts map[string]string `json:"conflicts"` // path -> sandbox hash already reported as a conflict
+	Rejected  map[string]string `json:"rejected"`  // path -> sandbox hash already reported as against the write policy
+	Held      map[string]string `json:"held"`      // path -> sandbox hash already reported as held by the dependency scan
+}
+
+func statePath(container string) string {
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "packnplay", "writeback", container+".json")
+}
+
+// Active reports whether a container has a sandbox workspace that writes back to the host
+func Active(container string) bool {
+	_, err := os.Stat(statePath(container))
+	return err == nil
+}
+
+// Forget drops a session's writeback state once its sandbox workspace is gone
+func Forget(container string) error {
+	path := statePath(container)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove writeback state: %w", err)
+	}
+	_ = os.Remove(path + ".lock")
+	return nil
+}
+
+// Workspace returns the host directory a session's edits are written back to
+func Workspace(container string) (string, error) {
+	state, err := loadState(container)
+	if err != nil {
+		return "", err
+	}
+	return state.Workspace, nil
+}
+
+// Held returns the manifest edits the dependency scan is keeping in a session's sandbox
+func Held(container string) ([]string, error) {
+	state, err := loadState(container)
+	if err != nil {
+		return nil, err
+	}
+	held := make([]string, 0, len(state.Held))
+	for path := range state.Held {
+		held = append(held, path)
+	}
+	sort.Strings(held)
+	return held, nil
+}
+
+func loadState(container string) (*State, error) {
+	data, err := os.ReadFile(statePath(container))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read writeback state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse writeback state: %w", err)
+	}
+	if state.Base == nil {
+		state.Base = Manifest{}
+	}
+	if state.Conflicts == nil {
+		state.Conflicts = map[string]string{}
+	}
+	if state.Rejected == nil {
+		state.Rejected = map[string]string{}
+	}
+	if state.Held == nil {
+		state.Held = map[string]string{}
+	}
+	return &state, nil
+}
+
+func saveState(container string, state *State) error {
+	path := statePath(container)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create writeback dir: %w", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode writeback state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write writeback state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// lock serializes writebacks for a container (the scheduler and 'packnplay stop')
+func lock(container string) (func(), error) {
+	path := statePath(container) + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create writeback dir: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open writeback lock: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock writeback state: %w", err)
+	}
+	return func() { file.Close() }, nil
+}
+
+// Seed copies the host workspace into the container's /workspace and records it as the base
+// A .git directory isn't copied: it's bind-mounted so commits land on the host directly.
+func Seed(dockerClient *docker.Client, container, hostDir, user string) error {
+	if output, err := dockerClient.Run("exec", "-u", "root", container, "chown", fmt.Sprintf("%s:%s", user, user), "/workspace"); err != nil {
+		return fmt.Errorf("failed to set workspace owner: %w\n%s", err, output)
+	}
+
+	dst := dockerClient.Stream("exec", "-i", "-u", user, container, "tar", "-C", "/workspace", "-xf", "-")
+	pipeReader, pipeWriter := io.Pipe()
+	dst.Stdin = pipeReader
+
+	base := Manifest{}
+	go func() {
+		pipeWriter.CloseWithError(writeTar(pipeWriter, hostDir, base))
+	}()
+	output, err := dst.CombinedOutput()
+	pipeReader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to copy workspace into container: %w\n%s", err, output)
+	}
+
+	// Without stats, the first writeback hashes the whole workspace instead
+	stats, _ := SandboxStats(dockerClient, container)
+	return saveState(container, &State{Workspace: hostDir, User: user, Base: base, Stats: stats, Conflicts: map[string]string{}})
+}
+
+// writeTar archives dir, hashing the regular files it writes into manifest
+func writeTar(w io.Writer, dir string, manifest Manifest) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if name == ".git" && d.IsDir() {
+			return filepath.SkipDir
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// Host builds' bazel-* links point into the host's output base; the sandbox makes its own
+		if bazel.IsConvenienceSymlink(name, info.Mode()) {
+			return nil
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil // sockets, fifos, devices
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash, err := copyAndHash(tw, file)
+		if err != nil {
+			return err
+		}
+		if name != ".git" {
+			manifest[name] = hash
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive workspace: %w", err)
+	}
+	return tw.Close()
+}
+
+// SandboxStats lists the stats of every file in the container's workspace, except .git
+// It reads no file contents, so it's cheap enough to poll.
+func SandboxStats(dockerClient *docker.Client, container string) (Stats, error) {
+	script := "cd /workspace && find . -path ./.git -prune -o -type f -exec stat -c '%i %s %y\t%n' {} +"
+	output, err := dockerClient.Run("exec", container, "sh", "-c", script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace changes: %w\n%s", err, output)
+	}
+	return ParseStats(output), nil
+}
+
+// Flush applies the sandbox's edits to the host workspace
+// Only files whose stats changed since the last writeback are hashed. Files the host also
+// changed are left alone; the sandbox's version is written next to them with ConflictSuffix,
+// once per conflicting edit. Edits the project's write policy doesn't allow stay in the
+// sandbox, and so do manifest edits adding dependencies the scan blocks, unless scanDeps is
+// false. Returns only new conflicts, rejections and holds.
+func Flush(dockerClient *docker.Client, container string, scanDeps bool) (Plan, error) {
+	unlock, err := lock(container)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer unlock()
+
+	state, err := loadState(container)
+	if err != nil {
+		return Plan{}, err
+	}
+	hostDir := state.Workspace
+	stats, err := SandboxStats(dockerClient, container)
+	if err != nil {
+		return Plan{}, err
+	}
+	candidates := Candidates(state.Base, state.Stats, stats, state.Conflicts, state.Rejected, state.Held)
+	sandbox, err := sandboxHashes(dockerClient, container, candidates)
+	if err != nil {
+		return Plan{}, err
+	}
+	host, err := HashFiles(hostDir, candidates)
+	if err != nil {
+		return Plan{}, err
+	}
+	// The host's project config is the one the agent couldn't edit
+	project, err := config.LoadProjectConfig(hostDir)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Restrict(Diff(baseOf(state.Base, candidates), host, sandbox), project.Writes)
+	if scanDeps {
+		plan = holdDependencies(dockerClient, container, hostDir, plan)
+	}
+
+	var writes []string
+	for _, change := range plan.Changes {
+		if !change.Delete {
+			writes = append(writes, change.Path)
+			continue
+		}
+		if err := os.Remove(filepath.Join(hostDir, filepath.FromSlash(change.Path))); err != nil && !os.IsNotExist(err) {
+			return Plan{}, fmt.Errorf("failed to delete %s: %w", change.Path, err)
+		}
+	}
+	if err := copyOut(dockerClient, container, hostDir, writes, ""); err != nil {
+		return Plan{}, err
+	}
+
+	for _, change := range plan.Changes {
+		setBase(state.Base, change.Path, sandbox[change.Path])
+	}
+	for _, path := range plan.Converged {
+		setBase(state.Base, path, sandbox[path])
+	}
+
+	conflicted := make(map[string]bool, len(plan.Conflicts))
+	var reported, conflictCopies []string
+	for _, path := range plan.Conflicts {
+		conflicted[path] = true
+		if seen, ok := state.Conflicts[path]; ok && seen == sandbox[path] {
+			continue
+		}
+		state.Conflicts[path] = sandbox[path]
+		reported = append(reported, path)
+		if sandbox[path] != "" {
+			conflictCopies = append(conflictCopies, path)
+		}
+	}
+	for path := range state.Conflicts {
+		if !conflicted[path] {
+			delete(state.Conflicts, path)
+		}
+	}
+	if err := copyOut(dockerClient, container, hostDir, conflictCopies, ConflictSuffix); err != nil {
+		return Plan{}, err
+	}
+	plan.Conflicts = reported
+
+	// Rejected and held edits stay in the sandbox, reported once per version like conflicts
+	plan.Rejected = newlyReported(state.Rejected, plan.Rejected, sandbox)
+	plan.Held = newlyReported(state.Held, plan.Held, sandbox)
+
+	state.Stats = stats
+	if err := saveState(container, state); err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+// newlyReported records paths in reported by their sandbox hash, returning those not already
+// reported at that version; paths no longer in paths are forgotten
+func newlyReported(reported map[string]string, paths []string, sandbox Manifest) []string {
+	current := make(map[string]bool, len(paths))
+	var fresh []string
+	for _, path := range paths {
+		current[path] = true
+		if seen, ok := reported[path]; ok && seen == sandbox[path] {
+			continue
+		}
+		reported[path] = sandbox[path]
+		fresh = append(fresh, path)
+	}
+	for path := range reported {
+		if !current[path] {
+			delete(reported, path)
+		}
+	}
+	return fresh
+}
+
+// holdDependencies moves manifest edits out of Changes and into Held when they add
+// dependencies that are critically vulnerable, unknown to their registry, or couldn't be
+// checked, the same gate 'packnplay verify --auto-pr' applies before publishing
+func holdDependencies(dockerClient *docker.Client, container, hostDir string, plan Plan) Plan {
+	var changes []Change
+	for _, change := range plan.Changes {
+		if change.Delete || !depscan.IsManifest(change.Path) || dependenciesSafe(dockerClient, container, hostDir, change.Path) {
+			changes = append(changes, change)
+		} else {
+			plan.Held = append(plan.Held, change.Path)
+		}
+	}
+	plan.Changes = changes
+	return plan
+}
+
+// dependenciesSafe scans the packages the sandbox's version of a manifest adds to the host's
+func dependenciesSafe(dockerClient *docker.Client, container, hostDir, path string) bool {
+	current, err := dockerClient.Run("exec", container, "cat", "--", "/workspace/"+path)
+	if err != nil {
+		return false
+	}
+	after, err := depscan.ParseManifest(path, []byte(current))
+	if err != nil {
+		return false
+	}
+	var before []depscan.Package
+	if previous, err := os.ReadFile(filepath.Join(hostDir, filepath.FromSlash(path))); err == nil {
+		// An unparseable host manifest means everything counts as added
+		before, _ = depscan.ParseManifest(path, previous)
+	}
+	added := depscan.Added(before, after)
+	if len(added) == 0 {
+		return true
+	}
+	report, err := depscan.Scan(added)
+	return err == nil && len(report.Blocking()) == 0
+}
+
+// Pull applies host edits to the sandbox's copy of the workspace, so file watchers in the
+// container (test runners, dev servers) see them as they happen
+// paths are the workspace-relative files or directories the host changed. Files the
+// sandbox also changed are left for Flush, which reports the conflict.
+func Pull(dockerClient *docker.Client, container string, paths []string) (Plan, error) {
+	unlock, err := lock(container)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer unlock()
+
+	state, err := loadState(container)
+	if err != nil {
+		return Plan{}, err
+	}
+	candidates := pullPaths(state.Workspace, state.Base, paths)
+	if len(candidates) == 0 {
+		return Plan{}, nil
+	}
+	host, err := HashFiles(state.Workspace, candidates)
+	if err != nil {
+		return Plan{}, err
+	}
+	sandbox, err := sandboxHashes(dockerClient, container, candidates)
+	if err != nil {
+		return Plan{}, err
+	}
+	// With the sides swapped, Diff finds host edits to files the sandbox left alone
+	plan := Diff(baseOf(state.Base, candidates), sandbox, host)
+
+	var writes, deletes []string
+	for _, change := range plan.Changes {
+		if change.Delete {
+			deletes = append(deletes, change.Path)
+		} else {
+			writes = append(writes, change.Path)
+		}
+	}
+	if err := copyIn(dockerClient, container, state.Workspace, state.User, writes); err != nil {
+		return Plan{}, err
+	}
+	if len(deletes) > 0 {
+		args := append(execArgs(container, state.User), "sh", "-c", `cd /workspace && rm -f -- "$@"`, "sh")
+		if output, err := dockerClient.Run(append(args, deletes...)...); err != nil {
+			return Plan{}, fmt.Errorf("failed to delete files in container: %w\n%s", err, output)
+		}
+	}
+
+	for _, change := range plan.Changes {
+		setBase(state.Base, change.Path, host[change.Path])
+	}
+	for _, path := range plan.Converged {
+		setBase(state.Base, path, host[path])
+	}
+	plan.Conflicts = nil
+	if err := saveState(container, state); err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+// pullPaths expands changed host paths into the files a pull should compare
+// A changed directory stands for every file in it, plus any the base had there,
+// which covers directories created, moved or deleted as a whole.
+func pullPaths(hostDir string, base Manifest, paths []string) []string {
+	seen := map[string]bool{}
+	var files []string
+	add := func(path string) {
+		if !seen[path] && !skipPull(path) {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, path := range paths {
+		if skipPull(path) {
+			continue
+		}
+		full := filepath.Join(hostDir, filepath.FromSlash(path))
+		info, err := os.Lstat(full)
+		if err == nil && info.IsDir() {
+			_ = filepath.WalkDir(full, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				rel, err := filepath.Rel(hostDir, p)
+				if err != nil {
+					return nil
+				}
+				if d.IsDir() {
+					if d.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				add(filepath.ToSlash(rel))
+				return nil
+			})
+		} else if _, known := base[path]; known || err == nil {
+			add(path)
+		}
+		for known := range base {
+			if strings.HasPrefix(known, path+"/") {
+				add(known)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// skipPull reports whether a host path is never copied into the sandbox: the bind-mounted
+// .git directory, and the files writebacks themselves leave on the host
+func skipPull(path string) bool {
+	if path == ".git" || strings.HasPrefix(path, ".git/") {
+		return true
+	}
+	name := filepath.Base(path)
+	return strings.HasSuffix(name, ConflictSuffix) || strings.HasPrefix(name, ".packnplay-writeback-")
+}
+
+// baseOf returns the part of base covering paths
+func baseOf(base Manifest, paths []string) Manifest {
+	subset := Manifest{}
+	for _, path := range paths {
+		if hash, ok := base[path]; ok {
+			subset[path] = hash
+		}
+	}
+	return subset
+}
+
+// sandboxHashes hashes the given workspace files in the container, leaving out missing ones
+func sandboxHashes(dockerClient *docker.Client, container string, paths []string) (Manifest, error) {
+	if len(paths) == 0 {
+		return Manifest{}, nil
+	}
+	script := `cd /workspace && xargs -0 -r sh -c 'for f; do [ -f "$f" ] && [ ! -L "$f" ] && sha256sum "./$f"; done; true' sh`
+	cmd := dockerClient.Stream("exec", "-i", container, "sh", "-c", script)
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00"))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash workspace files: %w\n%s", err, output)
+	}
+	return ParseSums(string(output)), nil
+}
+
+// copyIn copies host workspace files into the container's copy, as the workspace's owner
+func copyIn(dockerClient *docker.Client, container, hostDir, user string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	args := append(execArgs(container, user, "-i"), "tar", "-C", "/workspace", "-xf", "-")
+	dst := dockerClient.Stream(args...)
+	pipeReader, pipeWriter := io.Pipe()
+	dst.Stdin = pipeReader
+
+	go func() {
+		pipeWriter.CloseWithError(writeFiles(pipeWriter, hostDir, paths))
+	}()
+	output, err := dst.CombinedOutput()
+	pipeReader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to copy host edits into container: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// execArgs starts a docker exec in container, as user if one is known
+func execArgs(container, user string, flags ...string) []string {
+	args := append([]string{"exec"}, flags...)
+	if user != "" {
+		args = append(args, "-u", user)
+	}
+	return append(args, container)
+}
+
+// writeFiles archives the given regular files under dir; files gone since they changed are skipped
+func writeFiles(w io.Writer, dir string, paths []string) error {
+	tw := tar.NewWriter(w)
+	for _, path := range paths {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		info, err := os.Lstat(full)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path
+		file, err := os.Open(full)
+		if err != nil {
+			continue
+		}
+		err = tw.WriteHeader(hdr)
+		if err == nil {
+			_, err = io.CopyN(tw, file, hdr.Size)
+		}
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", path, err)
+		}
+	}
+	return tw.Close()
+}
+
+func setBase(base Manifest, path, hash string) {
+	if hash == "" {
+		delete(base, path)
+		return
+	}
+	base[path] = hash
+}
+
+// copyOut copies workspace files out of the container onto the host, appending suffix to their names
+func copyOut(dockerClient *docker.Client, container, hostDir string, paths []string, suffix string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	args := append([]string{"exec", container, "tar", "-C", "/workspace", "-cf", "-", "--"}, paths...)
+	cmd := dockerClient.Stream(args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to read workspace changes: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read workspace changes: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		wanted[path] = true
+	}
+	extractErr := extract(stdout, hostDir, wanted, suffix)
+	_, _ = io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+	if extractErr != nil {
+		return extractErr
+	}
+	if waitErr != nil {
+		// A file deleted since the manifest was taken; the next round picks up the deletion
+		if !strings.Contains(stderr.String(), "No such file") {
+			return fmt.Errorf("failed to read workspace changes: %w\n%s", waitErr, stderr.String())
+		}
+	}
+	return nil
+}
+
+// extract writes the wanted regular files from a tar stream under dir
+// Each file is written to a temp file and renamed, so the host never sees a partial write.
+func extract(r io.Reader, dir string, wanted map[string]bool, suffix string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read workspace changes: %w", err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if hdr.Typeflag != tar.TypeReg || !wanted[name] || !filepath.IsLocal(filepath.FromSlash(name)) {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name)) + suffix
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(target), ".packnplay-writeback-*")
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		_, copyErr := io.Copy(tmp, tr)
+		closeErr := tmp.Close()
+		if err := errors.Join(copyErr, closeErr, os.Chmod(tmp.Name(), hdr.FileInfo().Mode().Perm())); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := os.Rename(tmp.Name(), target); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package writeback
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pullDelay lets a burst of host writes (an editor's save, a git checkout) settle into one pull
+const pullDelay = 300 * time.Millisecond
+
+// WatchHost calls onChange with the workspace-relative paths changed under dir, a burst at
+// a time. fsnotify watches single directories, so every directory but .git is watched and
+// new ones are added as they appear; onError hears about any that can't be (e.g. when the
+// system's watch limit is reached). Call stop to end it.
+func WatchHost(dir string, onChange func(paths []string), onError func(error)) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	if err := watchTree(watcher, dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var timer *time.Timer
+	pending := map[string]bool{}
+	flush := func() {
+		mu.Lock()
+		paths := make([]string, 0, len(pending))
+		for path := range pending {
+			paths = append(paths, path)
+		}
+		pending = map[string]bool{}
+		mu.Unlock()
+		if len(paths) > 0 {
+			sort.Strings(paths)
+			onChange(paths)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				rel, err := filepath.Rel(dir, event.Name)
+				if err != nil || rel == "." || skipPull(filepath.ToSlash(rel)) {
+					continue
+				}
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+						if err := watchTree(watcher, event.Name); err != nil {
+							onError(err)
+						}
+					}
+				}
+				mu.Lock()
+				pending[filepath.ToSlash(rel)] = true
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(pullDelay, flush)
+				mu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onError(err)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			watcher.Close()
+			mu.Lock()
+			if timer != nil {
+				timer.Stop()
+			}
+			mu.Unlock()
+		})
+	}, nil
+}
+
+// watchTree adds root and every directory below it, except .git, to watcher
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return fmt.Errorf("failed to watch %s: %w", path, err)
+			}
+			return nil // removed while walking
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" && path != root {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
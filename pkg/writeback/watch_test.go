@@ -0,0 +1,51 @@
+package writeback
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchHost(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+
+	changes := make(chan []string, 4)
+	stop, err := WatchHost(dir, func(paths []string) {
+		changes <- paths
+	}, func(err error) {
+		t.Errorf("watch error: %v", err)
+	})
+	if err != nil {
+		t.Fatalf("WatchHost() error = %v", err)
+	}
+	defer stop()
+
+	await := func(want string) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case paths := <-changes:
+				for _, path := range paths {
+					if path == want {
+						return
+					}
+				}
+			case <-deadline:
+				t.Fatalf("no change reported for %s", want)
+			}
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, ".git", "index"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644)
+	await("main.go")
+
+	// Files in directories created after the watch started are seen too
+	os.MkdirAll(filepath.Join(dir, "internal"), 0755)
+	time.Sleep(100 * time.Millisecond)
+	os.WriteFile(filepath.Join(dir, "internal", "util.go"), []byte("package internal"), 0644)
+	await("internal/util.go")
+}
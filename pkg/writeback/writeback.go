@@ -0,0 +1,210 @@
+package writeback
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/writepolicy"
+)
+
+// Manifest maps workspace-relative file paths to their content hashes
+type Manifest map[string]string
+
+// ParseSums reads sha256sum output ("<hash>  ./path") into a Manifest
+// sha256sum escapes names containing newlines or backslashes with a leading '\';
+// those files are skipped rather than guessed at.
+func ParseSums(output string) Manifest {
+	manifest := Manifest{}
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || strings.HasPrefix(line, `\`) {
+			continue
+		}
+		hash, path, ok := strings.Cut(line, "  ")
+		if !ok {
+			continue
+		}
+		manifest[strings.TrimPrefix(path, "./")] = hash
+	}
+	return manifest
+}
+
+// Stats maps workspace-relative file paths to their inode, size and modification time
+// They're cheap to list and change with every write, so only files whose stats changed need hashing.
+type Stats map[string]string
+
+// ParseStats reads `stat -c '%i %s %y<tab>%n'` output into Stats
+// A name containing a newline is listed as its first line, which hashes as missing, so
+// such files are never written back, as with ParseSums.
+func ParseStats(output string) Stats {
+	stats := Stats{}
+	for _, line := range strings.Split(output, "\n") {
+		stat, path, ok := strings.Cut(line, "\t")
+		if !ok || !strings.HasPrefix(path, "./") {
+			continue
+		}
+		stats[strings.TrimPrefix(path, "./")] = stat
+	}
+	return stats
+}
+
+// Candidates returns the files a writeback needs to hash: those whose stats changed since
+// seen, those in base that are gone, and those in extra, the conflicts and rejections still
+// outstanding, since the host side of those can change too
+func Candidates(base Manifest, seen, current Stats, extra ...map[string]string) []string {
+	candidate := map[string]bool{}
+	for path, stat := range current {
+		if seen[path] != stat {
+			candidate[path] = true
+		}
+	}
+	for path := range base {
+		if _, ok := current[path]; !ok {
+			candidate[path] = true
+		}
+	}
+	for _, paths := range extra {
+		for path := range paths {
+			candidate[path] = true
+		}
+	}
+	paths := make([]string, 0, len(candidate))
+	for path := range candidate {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// HashFiles hashes the given paths under dir; paths that aren't regular files are left out
+func HashFiles(dir string, paths []string) (Manifest, error) {
+	manifest := Manifest{}
+	for _, path := range paths {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		info, err := os.Lstat(full)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		hash, err := hashFile(full)
+		if err != nil {
+			return nil, err
+		}
+		manifest[path] = hash
+	}
+	return manifest, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+	hash, err := copyAndHash(io.Discard, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hash, nil
+}
+
+// copyAndHash copies r to w, returning the sha256 of what was copied
+func copyAndHash(w io.Writer, r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Change is a sandbox edit to apply to the host
+type Change struct {
+	Path   string
+	Delete bool
+}
+
+// Plan is what a writeback would do
+type Plan struct {
+	Changes   []Change // sandbox edits to files the host left alone
+	Conflicts []string // files edited in the sandbox and on the host since they last agreed
+	Converged []string // files both sides changed to the same content
+	Rejected  []string // sandbox edits the project's write policy doesn't allow; kept in the sandbox
+	Held      []string // manifest edits adding dependencies that are vulnerable, unknown or couldn't be checked; kept in the sandbox
+}
+
+// Diff compares the sandbox and host against the content they last agreed on (base)
+// Only sandbox edits are considered: host-only edits are left for the user, who made them.
+func Diff(base, host, sandbox Manifest) Plan {
+	var plan Plan
+	for _, path := range unionKeys(base, sandbox) {
+		b, s, h := base[path], sandbox[path], host[path]
+		switch {
+		case s == b:
+			continue
+		case h == s:
+			plan.Converged = append(plan.Converged, path)
+		case h != b:
+			plan.Conflicts = append(plan.Conflicts, path)
+		default:
+			plan.Changes = append(plan.Changes, Change{Path: path, Delete: s == ""})
+		}
+	}
+	return plan
+}
+
+// Restrict moves the edits a write policy doesn't allow, conflicting ones included, to Rejected
+func Restrict(plan Plan, policy config.WritePolicy) Plan {
+	if policy.Empty() {
+		return plan
+	}
+	restricted := Plan{Converged: plan.Converged}
+	for _, change := range plan.Changes {
+		if writepolicy.Allowed(policy, change.Path) {
+			restricted.Changes = append(restricted.Changes, change)
+		} else {
+			restricted.Rejected = append(restricted.Rejected, change.Path)
+		}
+	}
+	for _, path := range plan.Conflicts {
+		if writepolicy.Allowed(policy, path) {
+			restricted.Conflicts = append(restricted.Conflicts, path)
+		} else {
+			restricted.Rejected = append(restricted.Rejected, path)
+		}
+	}
+	sort.Strings(restricted.Rejected)
+	return restricted
+}
+
+func unionKeys(a, b Manifest) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []Manifest{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Equal reports whether two listings have the same files with the same stats
+func Equal(a, b Stats) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
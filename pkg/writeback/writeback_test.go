@@ -0,0 +1,211 @@
+package writeback
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/docker"
+)
+
+func TestParseSums(t *testing.T) {
+	output := "aaa  ./src/main.go\nbbb  ./README.md\n\\ccc  ./odd\\nname\n"
+	want := Manifest{"src/main.go": "aaa", "README.md": "bbb"}
+	if got := ParseSums(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSums() = %v, want %v", got, want)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	output := "12 40 2026-10-15 09:00:00.123456789 +0000\t./src/main.go\n7 3 2026-10-15 09:00:01.000000000 +0000\t./odd\nname\n"
+	want := Stats{"src/main.go": "12 40 2026-10-15 09:00:00.123456789 +0000", "odd": "7 3 2026-10-15 09:00:01.000000000 +0000"}
+	if got := ParseStats(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStats() = %v, want %v", got, want)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	base := Manifest{"same": "a", "edited": "b", "deleted": "c", "conflict": "d"}
+	seen := Stats{"same": "1", "edited": "2", "deleted": "3", "conflict": "4", "rejected": "5"}
+	current := Stats{"same": "1", "edited": "2b", "created": "6", "conflict": "4", "rejected": "5"}
+
+	got := Candidates(base, seen, current, map[string]string{"conflict": "x"}, map[string]string{"rejected": "y"})
+	want := []string{"conflict", "created", "deleted", "edited", "rejected"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates() = %v, want %v", got, want)
+	}
+	if got := Candidates(base, current, current); !reflect.DeepEqual(got, []string{"deleted"}) {
+		t.Errorf("Candidates() with unchanged stats = %v, want only the file missing from base", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := Manifest{"same": "1", "edited": "1", "deleted": "1", "both": "1", "converged": "1", "host-only": "1"}
+	host := Manifest{"same": "1", "edited": "1", "deleted": "1", "both": "2", "converged": "3", "host-only": "4"}
+	sandbox := Manifest{"same": "1", "edited": "5", "both": "6", "converged": "3", "host-only": "1", "created": "7"}
+
+	plan := Diff(base, host, sandbox)
+	wantChanges := []Change{{Path: "created"}, {Path: "deleted", Delete: true}, {Path: "edited"}}
+	if !reflect.DeepEqual(plan.Changes, wantChanges) {
+		t.Errorf("Changes = %+v, want %+v", plan.Changes, wantChanges)
+	}
+	if !reflect.DeepEqual(plan.Conflicts, []string{"both"}) {
+		t.Errorf("Conflicts = %v, want [both]", plan.Conflicts)
+	}
+	if !reflect.DeepEqual(plan.Converged, []string{"converged"}) {
+		t.Errorf("Converged = %v, want [converged]", plan.Converged)
+	}
+}
+
+func TestDiffCreatedOnBothSides(t *testing.T) {
+	plan := Diff(Manifest{}, Manifest{"new": "host"}, Manifest{"new": "sandbox"})
+	if len(plan.Changes) != 0 || !reflect.DeepEqual(plan.Conflicts, []string{"new"}) {
+		t.Errorf("Diff() = %+v, want a conflict on new", plan)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	plan := Plan{
+		Changes:   []Change{{Path: "src/app.go"}, {Path: "deploy/prod.yaml", Delete: true}, {Path: ".packnplay.yaml"}},
+		Conflicts: []string{"src/util.go", ".github/workflows/ci.yml"},
+		Converged: []string{"README.md"},
+	}
+	policy := config.WritePolicy{Deny: []string{"deploy/**", ".github/**"}}
+
+	got := Restrict(plan, policy)
+	want := Plan{
+		Changes:   []Change{{Path: "src/app.go"}},
+		Conflicts: []string{"src/util.go"},
+		Converged: []string{"README.md"},
+		Rejected:  []string{".github/workflows/ci.yml", ".packnplay.yaml", "deploy/prod.yaml"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Restrict() = %+v\nwant %+v", got, want)
+	}
+	if got := Restrict(plan, config.WritePolicy{}); !reflect.DeepEqual(got, plan) {
+		t.Errorf("Restrict() without a policy = %+v, want the plan unchanged", got)
+	}
+}
+
+func TestSeedArchiveSkipsGitDirAndBazelLinks(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git", "objects"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0644)
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+	os.Symlink("/home/me/.cache/bazel/_bazel_me/abc/execroot/_main/bazel-out/k8-fastbuild/bin", filepath.Join(dir, "bazel-bin"))
+
+	var buf bytes.Buffer
+	manifest := Manifest{}
+	if err := writeTar(&buf, dir, manifest); err != nil {
+		t.Fatalf("writeTar() error = %v", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"src", "src/main.go"}) {
+		t.Errorf("archived %v, want src and src/main.go", names)
+	}
+
+	hosted, _ := HashFiles(dir, []string{"src/main.go", "missing"})
+	if !reflect.DeepEqual(manifest, hosted) {
+		t.Errorf("seed manifest %v doesn't match host hashes %v", manifest, hosted)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, body := range map[string]string{"src/main.go": "changed", "other": "not asked for", "../escape": "x"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+
+	dir := t.TempDir()
+	wanted := map[string]bool{"src/main.go": true, "../escape": true}
+	if err := extract(&buf, dir, wanted, ConflictSuffix); err != nil {
+		t.Fatalf("extract() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "src", "main.go"+ConflictSuffix))
+	if err != nil || string(data) != "changed" {
+		t.Errorf("conflict copy = %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "src", "main.go"+ConflictSuffix)); err == nil && info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other")); err == nil {
+		t.Error("extracted a file that wasn't asked for")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape"+ConflictSuffix)); err == nil {
+		t.Error("extracted a file outside the workspace")
+	}
+}
+
+func TestPullPaths(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "pkg", "api"), 0755)
+	os.WriteFile(filepath.Join(dir, "pkg", "api", "handler.go"), []byte("package api"), 0644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("hi"), 0644)
+	base := Manifest{"old/gone.go": "1", "old/sub/gone.go": "2", "README.md": "3"}
+
+	got := pullPaths(dir, base, []string{"pkg", "old", "README.md", ".git/index", "README.md" + ConflictSuffix})
+	want := []string{"README.md", "old/gone.go", "old/sub/gone.go", "pkg/api/handler.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pullPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteFilesSkipsMissing(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+
+	var buf bytes.Buffer
+	if err := writeFiles(&buf, dir, []string{"src/main.go", "src/deleted.go"}); err != nil {
+		t.Fatalf("writeFiles() error = %v", err)
+	}
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != "src/main.go" {
+		t.Fatalf("first entry = %v, %v, want src/main.go", hdr, err)
+	}
+	if _, err := tr.Next(); err == nil {
+		t.Error("archived a file that no longer exists")
+	}
+}
+
+func TestHoldDependencies(t *testing.T) {
+	replayer, err := docker.NewReplayer(filepath.Join("testdata", "runtime", "hold.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostDir := t.TempDir()
+	goMod := "module example.com/app\n\nrequire github.com/BurntSushi/toml v1.3.2\n"
+	if err := os.WriteFile(filepath.Join(hostDir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A manifest that can't be checked is held; one that only drops packages needs no scan
+	plan := Plan{Changes: []Change{{Path: "README.md"}, {Path: "package.json"}, {Path: "go.mod"}, {Path: "requirements.txt", Delete: true}}}
+	got := holdDependencies(replayer.Client(), "packnplay-app-main", hostDir, plan)
+	wantChanges := []Change{{Path: "README.md"}, {Path: "go.mod"}, {Path: "requirements.txt", Delete: true}}
+	if !reflect.DeepEqual(got.Changes, wantChanges) || !reflect.DeepEqual(got.Held, []string{"package.json"}) {
+		t.Errorf("holdDependencies() = %+v, want changes %v and package.json held", got, wantChanges)
+	}
+	if err := replayer.Verify(); err != nil {
+		t.Error(err)
+	}
+}
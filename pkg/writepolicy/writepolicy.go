@@ -0,0 +1,134 @@
+package writepolicy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/obra/packnplay/pkg/config"
+	"github.com/obra/packnplay/pkg/git"
+	"gopkg.in/yaml.v3"
+)
+
+// Validate checks a policy's patterns before they're relied on
+func Validate(policy config.WritePolicy) error {
+	for _, pattern := range append(append([]string(nil), policy.Allow...), policy.Deny...) {
+		if pattern == "" || strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "..") {
+			return fmt.Errorf("invalid write pattern '%s' (use a glob relative to the project root, e.g. src/**)", pattern)
+		}
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid write pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether a workspace-relative file path matches a policy pattern
+func Match(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**" // a directory stands for everything in it
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// matchSegments matches path segments, with ** standing for any number of them
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(file); i++ {
+				if matchSegments(pattern[1:], file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], file[0]); !ok {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
+
+// Allowed reports whether the policy lets an agent change a file
+// The project config itself is off limits under any policy, so it can't be loosened from inside.
+func Allowed(policy config.WritePolicy, file string) bool {
+	if policy.Empty() {
+		return true
+	}
+	if file == config.ProjectConfigFile {
+		return false
+	}
+	for _, pattern := range policy.Deny {
+		if Match(pattern, file) {
+			return false
+		}
+	}
+	if len(policy.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range policy.Allow {
+		if Match(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Denied returns the files the policy doesn't let an agent change
+func Denied(policy config.WritePolicy, files []string) []string {
+	var denied []string
+	for _, file := range files {
+		if !Allowed(policy, file) {
+			denied = append(denied, file)
+		}
+	}
+	return denied
+}
+
+// AtCommit reads the write policy from the project config as committed at rev, so an
+// agent's edits to the working copy can't change the policy its own changes are held to
+func AtCommit(workspace, rev string) (config.WritePolicy, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	data, err := git.ShowFile(workspace, rev, config.ProjectConfigFile)
+	if err != nil || data == nil {
+		return config.WritePolicy{}, err
+	}
+	var project config.ProjectConfig
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return config.WritePolicy{}, fmt.Errorf("failed to parse %s at %s: %w", config.ProjectConfigFile, rev, err)
+	}
+	return project.Writes, nil
+}
+
+// Report describes denied changes for the user, naming the rules they broke
+func Report(policy config.WritePolicy, denied []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d change(s) outside the project's write policy:\n", len(denied))
+	for _, file := range denied {
+		fmt.Fprintf(&b, "  %s (%s)\n", file, reason(policy, file))
+	}
+	return b.String()
+}
+
+// reason names what denies a file
+func reason(policy config.WritePolicy, file string) string {
+	if file == config.ProjectConfigFile {
+		return "the project config can't be changed by agents"
+	}
+	for _, pattern := range policy.Deny {
+		if Match(pattern, file) {
+			return "denied by " + pattern
+		}
+	}
+	return "not in allow: " + strings.Join(policy.Allow, ", ")
+}
@@ -0,0 +1,95 @@
+package writepolicy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obra/packnplay/pkg/config"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"src/**", "src/main.go", true},
+		{"src/**", "src/pkg/deep/file.go", true},
+		{"src/**", "lib/src/main.go", false},
+		{".github/**", ".github/workflows/ci.yml", true},
+		{"deploy/", "deploy/prod/values.yaml", true},
+		{"Makefile", "Makefile", true},
+		{"Makefile", "tools/Makefile", true},
+		{"*.tf", "infra/main.tf", true},
+		{"tests/**/*_test.go", "tests/api/user_test.go", true},
+		{"tests/**/*_test.go", "tests/user_test.go", true},
+		{"tests/**/*_test.go", "tests/api/helpers.go", false},
+		{"src/*.go", "src/pkg/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestDenied(t *testing.T) {
+	policy := config.WritePolicy{
+		Allow: []string{"src/**", "tests/**", "Makefile"},
+		Deny:  []string{"deploy/**", ".github/**", "Makefile"},
+	}
+	files := []string{"src/app.go", "tests/app_test.go", "deploy/prod.yaml", ".github/workflows/ci.yml", "Makefile", "README.md", ".packnplay.yaml"}
+	want := []string{"deploy/prod.yaml", ".github/workflows/ci.yml", "Makefile", "README.md", ".packnplay.yaml"}
+	if got := Denied(policy, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("Denied() = %v, want %v", got, want)
+	}
+	if got := Denied(config.WritePolicy{}, files); got != nil {
+		t.Errorf("Denied() with no policy = %v, want nothing", got)
+	}
+
+	report := Report(policy, want)
+	for _, line := range []string{"deploy/prod.yaml (denied by deploy/**)", "README.md (not in allow: src/**, tests/**, Makefile)", ".packnplay.yaml (the project config"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Report() missing %q:\n%s", line, report)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, pattern := range []string{"", "/etc/**", "../other/**", "src/[a"} {
+		if err := Validate(config.WritePolicy{Deny: []string{pattern}}); err == nil {
+			t.Errorf("Validate(%q) should fail", pattern)
+		}
+	}
+	if err := Validate(config.WritePolicy{Allow: []string{"src/**", "*.md"}}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestAtCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	os.WriteFile(filepath.Join(repo, config.ProjectConfigFile), []byte("writes:\n  deny: [\"deploy/**\"]\n"), 0644)
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "."},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "init"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	// An agent loosening the policy in the working copy changes nothing
+	os.WriteFile(filepath.Join(repo, config.ProjectConfigFile), []byte("writes: {}\n"), 0644)
+
+	policy, err := AtCommit(repo, "")
+	if err != nil || !reflect.DeepEqual(policy.Deny, []string{"deploy/**"}) {
+		t.Errorf("AtCommit() = %+v, %v; want the committed policy", policy, err)
+	}
+}